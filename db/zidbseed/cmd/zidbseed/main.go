@@ -0,0 +1,68 @@
+// Command zidbseed seeds a database with YAML fixtures, see zidbseed.Load:
+//
+//	zidbseed seed -driver postgres -dsn "$DATABASE_DSN" -env local fixtures/
+//
+// Seeding is refused outside of the local, development and test
+// environments, -allow-env overrides them.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/divikraf/lumos/db/zidbseed"
+	"github.com/divikraf/lumos/db/zisqlx"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "seed" {
+		fmt.Fprintln(os.Stderr, "usage: zidbseed seed -driver mysql|postgres -dsn DSN -env ENV FILE|DIR...")
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("seed", flag.ExitOnError)
+	driver := flags.String("driver", "", "database driver, mysql or postgres")
+	dsn := flags.String("dsn", "", "database DSN")
+	env := flags.String("env", "", "environment of the database, e.g. local")
+	allowEnv := flags.String("allow-env", strings.Join(zidbseed.DefaultAllowedEnvironments, ","), "comma separated environments seeding is allowed in")
+	_ = flags.Parse(os.Args[2:])
+
+	if *driver == "" || *dsn == "" || *env == "" || flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	fixtures, err := zidbseed.LoadFiles(flags.Args()...)
+	if err != nil {
+		fatal(err)
+	}
+
+	db, err := sqlx.Open(*driver, *dsn)
+	if err != nil {
+		fatal(err)
+	}
+	defer db.Close()
+
+	seeder := zidbseed.New(zisqlx.New(db), zidbseed.Dialect(*driver), *env,
+		zidbseed.WithAllowedEnvironments(strings.Split(*allowEnv, ",")...))
+	if err := seeder.Seed(context.Background(), fixtures...); err != nil {
+		fatal(err)
+	}
+
+	rows := 0
+	for _, f := range fixtures {
+		rows += len(f.Rows)
+	}
+	fmt.Printf("seeded %d rows in %d fixtures\n", rows, len(fixtures))
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,66 @@
+package zidbseed
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Load decodes YAML fixtures, a list of tables with their rows:
+//
+//	- table: users
+//	  key: [id]
+//	  rows:
+//	    - {id: 1, name: alice}
+//	- table: orders
+//	  key: [id]
+//	  depends_on: [users]
+//	  rows:
+//	    - {id: 1, user_id: 1, total: 42}
+func Load(r io.Reader) ([]Fixture, error) {
+	var fixtures []Fixture
+	if err := yaml.NewDecoder(r).Decode(&fixtures); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("zidbseed: failed to decode fixtures: %w", err)
+	}
+	for i, f := range fixtures {
+		if f.Table == "" {
+			return nil, fmt.Errorf("zidbseed: fixture %d has no table", i)
+		}
+	}
+	return fixtures, nil
+}
+
+// LoadFiles loads the fixtures of YAML files, or of the *.yaml and *.yml files
+// of directories, in lexical order.
+func LoadFiles(paths ...string) ([]Fixture, error) {
+	var fixtures []Fixture
+	for _, path := range paths {
+		files := []string{path}
+		if info, err := os.Stat(path); err != nil {
+			return nil, err
+		} else if info.IsDir() {
+			yamls, _ := filepath.Glob(filepath.Join(path, "*.yaml"))
+			ymls, _ := filepath.Glob(filepath.Join(path, "*.yml"))
+			files = append(yamls, ymls...)
+			sort.Strings(files)
+		}
+
+		for _, file := range files {
+			f, err := os.Open(file)
+			if err != nil {
+				return nil, err
+			}
+			loaded, err := Load(f)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			fixtures = append(fixtures, loaded...)
+		}
+	}
+	return fixtures, nil
+}
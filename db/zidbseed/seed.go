@@ -0,0 +1,239 @@
+// Package zidbseed loads fixtures into databases, for local environments and
+// tests. Fixtures are upserted in foreign key order, so seeding twice is
+// harmless, and refused outside of the allowed environments.
+package zidbseed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/divikraf/lumos/db/zisqlx"
+)
+
+// Dialect is the SQL dialect of the seeded database.
+type Dialect string
+
+const (
+	MySQL    Dialect = "mysql"
+	Postgres Dialect = "postgres"
+)
+
+// DefaultAllowedEnvironments are the environments seeding is allowed in.
+var DefaultAllowedEnvironments = []string{"local", "development", "test"}
+
+// ErrEnvironmentNotAllowed is returned when seeding an environment that is not
+// allowed, e.g. production.
+var ErrEnvironmentNotAllowed = errors.New("zidbseed: seeding is not allowed in this environment")
+
+// Fixture holds the rows of a table.
+type Fixture struct {
+	Table string `yaml:"table"`
+	// Key are the columns identifying rows, rows with the same key are
+	// updated instead of inserted. MySQL uses the table unique keys instead.
+	Key []string `yaml:"key"`
+	// DependsOn are the tables referenced by foreign keys, seeded first when
+	// they have fixtures.
+	DependsOn []string         `yaml:"depends_on"`
+	Rows      []map[string]any `yaml:"rows"`
+}
+
+// Seeder upserts fixtures into a database.
+type Seeder struct {
+	db          zisqlx.BasicQueryerExecuter
+	dialect     Dialect
+	environment string
+	allowed     []string
+}
+
+// Option configures a Seeder.
+type Option func(*Seeder)
+
+// WithAllowedEnvironments overrides DefaultAllowedEnvironments.
+func WithAllowedEnvironments(envs ...string) Option {
+	return func(s *Seeder) {
+		s.allowed = envs
+	}
+}
+
+// New returns a Seeder of the database db, running in the environment env,
+// usually ziconf.Config.GetEnvironment().
+func New(db zisqlx.BasicQueryerExecuter, dialect Dialect, env string, opts ...Option) *Seeder {
+	s := &Seeder{
+		db:          db,
+		dialect:     dialect,
+		environment: env,
+		allowed:     DefaultAllowedEnvironments,
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+// Seed upserts fixtures in a single transaction, tables referenced by foreign
+// keys first. It returns ErrEnvironmentNotAllowed outside of the allowed
+// environments.
+func (s *Seeder) Seed(ctx context.Context, fixtures ...Fixture) (err error) {
+	if !slices.Contains(s.allowed, s.environment) {
+		return fmt.Errorf("%w: %q", ErrEnvironmentNotAllowed, s.environment)
+	}
+	if s.dialect != MySQL && s.dialect != Postgres {
+		return fmt.Errorf("zidbseed: unsupported dialect %q", s.dialect)
+	}
+	ordered, err := Order(fixtures)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, "zidbseed.Seed", nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for _, f := range ordered {
+		for i, row := range f.Rows {
+			query, args, err := s.upsert(f, row)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, "zidbseed.Upsert", query, args...); err != nil {
+				return fmt.Errorf("zidbseed: failed to seed row %d of %s: %w", i, f.Table, err)
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// Order sorts fixtures so that tables come after the tables they depend on.
+// Fixtures of the same table are kept in order. It fails on dependency
+// cycles.
+func Order(fixtures []Fixture) ([]Fixture, error) {
+	byTable := map[string][]int{}
+	for i, f := range fixtures {
+		byTable[f.Table] = append(byTable[f.Table], i)
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	ordered := make([]Fixture, 0, len(fixtures))
+	var visit func(table string, path []string) error
+	visit = func(table string, path []string) error {
+		switch state[table] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("zidbseed: dependency cycle: %s", strings.Join(append(path, table), " -> "))
+		}
+		state[table] = visiting
+		for _, i := range byTable[table] {
+			for _, dep := range fixtures[i].DependsOn {
+				// Tables without fixtures are expected to exist already
+				if _, ok := byTable[dep]; ok && dep != table {
+					if err := visit(dep, append(path, table)); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		state[table] = visited
+		for _, i := range byTable[table] {
+			ordered = append(ordered, fixtures[i])
+		}
+		return nil
+	}
+	for _, f := range fixtures {
+		if err := visit(f.Table, nil); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// upsert returns the statement inserting row into the table of f, or
+// updating it when it already exists.
+func (s *Seeder) upsert(f Fixture, row map[string]any) (string, []any, error) {
+	if len(row) == 0 {
+		return "", nil, fmt.Errorf("zidbseed: empty row in %s", f.Table)
+	}
+	columns := make([]string, 0, len(row))
+	for c := range row {
+		columns = append(columns, c)
+	}
+	sort.Strings(columns)
+
+	args := make([]any, len(columns))
+	quoted := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	for i, c := range columns {
+		args[i] = row[c]
+		quoted[i] = s.quote(c)
+		placeholders[i] = "?"
+		if s.dialect == Postgres {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "INSERT INTO %s (%s) VALUES (%s)", s.quote(f.Table), strings.Join(quoted, ", "), strings.Join(placeholders, ", "))
+
+	var updates []string
+	for i, c := range columns {
+		if slices.Contains(f.Key, c) {
+			continue
+		}
+		if s.dialect == Postgres {
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", quoted[i], quoted[i]))
+		} else {
+			updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", quoted[i], quoted[i]))
+		}
+	}
+
+	switch s.dialect {
+	case Postgres:
+		if len(f.Key) == 0 {
+			return "", nil, fmt.Errorf("zidbseed: fixture of %s has no key", f.Table)
+		}
+		keys := make([]string, len(f.Key))
+		for i, k := range f.Key {
+			keys[i] = s.quote(k)
+		}
+		fmt.Fprintf(&b, " ON CONFLICT (%s)", strings.Join(keys, ", "))
+		if len(updates) == 0 {
+			b.WriteString(" DO NOTHING")
+		} else {
+			fmt.Fprintf(&b, " DO UPDATE SET %s", strings.Join(updates, ", "))
+		}
+	default:
+		if len(updates) == 0 {
+			// Keeps the row as is
+			updates = []string{fmt.Sprintf("%s = %s", quoted[0], quoted[0])}
+		}
+		fmt.Fprintf(&b, " ON DUPLICATE KEY UPDATE %s", strings.Join(updates, ", "))
+	}
+	return b.String(), args, nil
+}
+
+// quote quotes an identifier, schema prefixes included.
+func (s *Seeder) quote(ident string) string {
+	q := `"`
+	if s.dialect == MySQL {
+		q = "`"
+	}
+	parts := strings.Split(ident, ".")
+	for i, p := range parts {
+		parts[i] = q + strings.ReplaceAll(p, q, q+q) + q
+	}
+	return strings.Join(parts, ".")
+}
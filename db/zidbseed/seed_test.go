@@ -0,0 +1,127 @@
+package zidbseed
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/divikraf/lumos/db/zisqlx"
+)
+
+// recorder records the statements executed in transactions.
+type recorder struct {
+	zisqlx.BasicQueryerExecuter
+	queries   []string
+	args      [][]any
+	committed bool
+}
+
+func (r *recorder) BeginTx(ctx context.Context, operationName string, opts *sql.TxOptions) (zisqlx.TxInterface, error) {
+	return &recorderTx{r: r}, nil
+}
+
+type recorderTx struct {
+	zisqlx.TxInterface
+	r *recorder
+}
+
+func (tx *recorderTx) ExecContext(ctx context.Context, operationName string, query string, args ...any) (sql.Result, error) {
+	tx.r.queries = append(tx.r.queries, query)
+	tx.r.args = append(tx.r.args, args)
+	return nil, nil
+}
+
+func (tx *recorderTx) Commit() error {
+	tx.r.committed = true
+	return nil
+}
+
+func (tx *recorderTx) Rollback() error { return nil }
+
+const fixturesYAML = `
+- table: orders
+  key: [id]
+  depends_on: [users, products]
+  rows:
+    - {id: 10, user_id: 1, total: 42}
+- table: users
+  key: [id]
+  depends_on: [tenants]
+  rows:
+    - {id: 1, name: alice}
+- table: user_roles
+  key: [user_id, role]
+  depends_on: [users]
+  rows:
+    - {user_id: 1, role: admin}
+`
+
+func TestSeed(t *testing.T) {
+	fixtures, err := Load(strings.NewReader(fixturesYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		dialect Dialect
+		want    []string
+	}{
+		{
+			dialect: Postgres,
+			want: []string{
+				`INSERT INTO "users" ("id", "name") VALUES ($1, $2) ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name"`,
+				`INSERT INTO "orders" ("id", "total", "user_id") VALUES ($1, $2, $3) ON CONFLICT ("id") DO UPDATE SET "total" = EXCLUDED."total", "user_id" = EXCLUDED."user_id"`,
+				`INSERT INTO "user_roles" ("role", "user_id") VALUES ($1, $2) ON CONFLICT ("user_id", "role") DO NOTHING`,
+			},
+		},
+		{
+			dialect: MySQL,
+			want: []string{
+				"INSERT INTO `users` (`id`, `name`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `name` = VALUES(`name`)",
+				"INSERT INTO `orders` (`id`, `total`, `user_id`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `total` = VALUES(`total`), `user_id` = VALUES(`user_id`)",
+				"INSERT INTO `user_roles` (`role`, `user_id`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `role` = `role`",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.dialect), func(t *testing.T) {
+			db := &recorder{}
+			if err := New(db, tt.dialect, "local").Seed(context.Background(), fixtures...); err != nil {
+				t.Fatalf("Seed() error = %v", err)
+			}
+			if !reflect.DeepEqual(db.queries, tt.want) {
+				t.Errorf("queries:\n%s\nwant:\n%s", strings.Join(db.queries, "\n"), strings.Join(tt.want, "\n"))
+			}
+			if want := []any{1, "alice"}; !reflect.DeepEqual(db.args[0], want) {
+				t.Errorf("args = %v, want %v", db.args[0], want)
+			}
+			if !db.committed {
+				t.Error("transaction not committed")
+			}
+		})
+	}
+}
+
+func TestSeedEnvironmentGuard(t *testing.T) {
+	db := &recorder{}
+	err := New(db, Postgres, "production").Seed(context.Background(), Fixture{Table: "users", Key: []string{"id"}, Rows: []map[string]any{{"id": 1}}})
+	if !errors.Is(err, ErrEnvironmentNotAllowed) {
+		t.Errorf("Seed() error = %v, want ErrEnvironmentNotAllowed", err)
+	}
+	if len(db.queries) != 0 {
+		t.Errorf("executed %d queries in production", len(db.queries))
+	}
+}
+
+func TestOrderCycle(t *testing.T) {
+	_, err := Order([]Fixture{
+		{Table: "a", DependsOn: []string{"b"}},
+		{Table: "b", DependsOn: []string{"a"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "a -> b -> a") {
+		t.Errorf("Order() error = %v, want a dependency cycle", err)
+	}
+}
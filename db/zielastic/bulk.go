@@ -0,0 +1,342 @@
+package zielastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ErrBulkIndexerClosed is returned when adding items to a closed BulkIndexer.
+var ErrBulkIndexerClosed = errors.New("zielastic: bulk indexer closed")
+
+// BulkConfig configures a BulkIndexer.
+type BulkConfig struct {
+	// FlushBytes and FlushItems are the size and the number of items flushing
+	// a batch (default: 5MB and 1000).
+	FlushBytes int `json:"flushBytes" yaml:"flushBytes"`
+	FlushItems int `json:"flushItems" yaml:"flushItems"`
+	// FlushInterval is the interval flushing the pending items (default: 1s).
+	FlushInterval time.Duration `json:"flushInterval" yaml:"flushInterval"`
+	// Workers is the number of batches sent concurrently (default: 2).
+	Workers int `json:"workers" yaml:"workers"`
+	// MaxRetries is the number of retries of the items rejected with a 429
+	// (default: 3), after Backoff doubled at every retry (default: 500ms).
+	MaxRetries int           `json:"maxRetries" yaml:"maxRetries"`
+	Backoff    time.Duration `json:"backoff" yaml:"backoff"`
+}
+
+func (c *BulkConfig) setDefaults() {
+	if c.FlushBytes <= 0 {
+		c.FlushBytes = 5 << 20
+	}
+	if c.FlushItems <= 0 {
+		c.FlushItems = 1000
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = time.Second
+	}
+	if c.Workers <= 0 {
+		c.Workers = 2
+	}
+	if c.MaxRetries <= 0 {
+		c.MaxRetries = 3
+	}
+	if c.Backoff <= 0 {
+		c.Backoff = 500 * time.Millisecond
+	}
+}
+
+// BulkItem is an operation of the bulk API.
+type BulkItem struct {
+	// Action is "index" (default), "create", "update" or "delete".
+	Action string
+	Index  string
+	ID     string
+	// Document is the document, or the body of an update, encoded in JSON.
+	Document any
+	// OnFailure, if set, is called when the item fails, instead of logging
+	// the failure.
+	OnFailure func(ctx context.Context, item BulkItem, err error)
+}
+
+// lines returns the NDJSON lines of the item.
+func (i BulkItem) lines() ([]byte, error) {
+	action := i.Action
+	if action == "" {
+		action = "index"
+	}
+	meta := map[string]string{}
+	if i.Index != "" {
+		meta["_index"] = i.Index
+	}
+	if i.ID != "" {
+		meta["_id"] = i.ID
+	}
+	b, err := json.Marshal(map[string]any{action: meta})
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, '\n')
+	if action == "delete" {
+		return b, nil
+	}
+	doc, err := json.Marshal(i.Document)
+	if err != nil {
+		return nil, fmt.Errorf("zielastic: failed to encode document %s: %w", i.ID, err)
+	}
+	return append(append(b, doc...), '\n'), nil
+}
+
+type bulkEntry struct {
+	item  BulkItem
+	lines []byte
+}
+
+type bulkBatch struct {
+	entries []bulkEntry
+	size    int
+}
+
+// BulkIndexer indexes items with the bulk API, in batches flushed by size,
+// number of items or interval, and sent by a fixed number of workers. Adding
+// items blocks while all the workers are busy, slowing the producers down to
+// the pace of the cluster. The items rejected with a 429 are retried with
+// backoff, the other failures are reported to the items. The items are
+// recorded in the elastic_bulk_items_total metric by result (indexed, failed,
+// retried).
+type BulkIndexer struct {
+	client *Client
+	config BulkConfig
+	logger *zerolog.Logger
+	items  metric.Int64Counter
+
+	mu       sync.Mutex
+	pending  bulkBatch
+	closed   bool
+	inflight sync.WaitGroup
+
+	batches chan bulkBatch
+	stop    chan struct{}
+	flusher chan struct{}
+	workers sync.WaitGroup
+}
+
+// NewBulkIndexer returns a BulkIndexer of the client, started. It must be
+// closed to flush the pending items.
+func (c *Client) NewBulkIndexer(config BulkConfig, logger *zerolog.Logger) *BulkIndexer {
+	config.setDefaults()
+	b := &BulkIndexer{
+		client:  c,
+		config:  config,
+		logger:  logger,
+		items:   revelio.MustInt64Counter("elastic_bulk_items_total", "Number of items of Elasticsearch bulk requests, by result (indexed, failed, retried)"),
+		batches: make(chan bulkBatch),
+		stop:    make(chan struct{}),
+		flusher: make(chan struct{}),
+	}
+	for range config.Workers {
+		b.workers.Add(1)
+		go func() {
+			defer b.workers.Done()
+			for batch := range b.batches {
+				b.index(context.Background(), batch.entries)
+			}
+		}()
+	}
+	go b.flushPeriodically()
+	return b
+}
+
+// Add adds item to the pending batch, sending the batch when full. It blocks
+// until a worker takes the batch, or ctx is done.
+func (b *BulkIndexer) Add(ctx context.Context, item BulkItem) error {
+	lines, err := item.lines()
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrBulkIndexerClosed
+	}
+	b.pending.entries = append(b.pending.entries, bulkEntry{item: item, lines: lines})
+	b.pending.size += len(lines)
+	if len(b.pending.entries) < b.config.FlushItems && b.pending.size < b.config.FlushBytes {
+		b.mu.Unlock()
+		return nil
+	}
+	batch := b.take()
+	b.mu.Unlock()
+	return b.send(ctx, batch)
+}
+
+// Flush sends the pending batch to the workers, without waiting for it to be
+// indexed.
+func (b *BulkIndexer) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	if b.closed || len(b.pending.entries) == 0 {
+		b.mu.Unlock()
+		return nil
+	}
+	batch := b.take()
+	b.mu.Unlock()
+	return b.send(ctx, batch)
+}
+
+// Close flushes the pending items, and waits for the batches to be indexed
+// until ctx is done.
+func (b *BulkIndexer) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	batch := b.take()
+	b.mu.Unlock()
+
+	close(b.stop)
+	<-b.flusher
+	var err error
+	if len(batch.entries) > 0 {
+		err = b.send(ctx, batch)
+	} else {
+		b.inflight.Done()
+	}
+	b.inflight.Wait()
+	close(b.batches)
+
+	done := make(chan struct{})
+	go func() {
+		b.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// take returns the pending batch, to be sent, with b.mu held.
+func (b *BulkIndexer) take() bulkBatch {
+	batch := b.pending
+	b.pending = bulkBatch{}
+	b.inflight.Add(1)
+	return batch
+}
+
+// send hands batch to a worker, failing its items if ctx is done first.
+func (b *BulkIndexer) send(ctx context.Context, batch bulkBatch) error {
+	defer b.inflight.Done()
+	select {
+	case b.batches <- batch:
+		return nil
+	case <-ctx.Done():
+		b.fail(ctx, batch.entries, ctx.Err())
+		return ctx.Err()
+	}
+}
+
+func (b *BulkIndexer) flushPeriodically() {
+	defer close(b.flusher)
+	ticker := time.NewTicker(b.config.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.Flush(context.Background())
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// index indexes entries, retrying the ones rejected with a 429.
+func (b *BulkIndexer) index(ctx context.Context, entries []bulkEntry) {
+	for attempt := 0; ; attempt++ {
+		retry, err := b.bulk(ctx, entries)
+		var e *Error
+		if errors.As(err, &e) && e.Status == http.StatusTooManyRequests {
+			retry, err = entries, nil
+		}
+		if err != nil {
+			b.fail(ctx, entries, err)
+			return
+		}
+		if len(retry) == 0 {
+			return
+		}
+		if attempt == b.config.MaxRetries {
+			b.fail(ctx, retry, &Error{Status: http.StatusTooManyRequests, Type: "es_rejected_execution_exception", Reason: "retries exhausted"})
+			return
+		}
+		b.items.Add(ctx, int64(len(retry)), metric.WithAttributes(attribute.String("result", "retried")))
+		time.Sleep(b.config.Backoff << attempt)
+		entries = retry
+	}
+}
+
+// bulk sends entries to the bulk API, and returns the ones to retry.
+func (b *BulkIndexer) bulk(ctx context.Context, entries []bulkEntry) ([]bulkEntry, error) {
+	var body bytes.Buffer
+	for _, e := range entries {
+		body.Write(e.lines)
+	}
+	var resp struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  *struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := b.client.do(ctx, http.MethodPost, "/_bulk", "application/x-ndjson", &body, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Items) != len(entries) {
+		return nil, fmt.Errorf("zielastic: bulk response has %d items, want %d", len(resp.Items), len(entries))
+	}
+
+	var retry []bulkEntry
+	indexed := 0
+	for i, item := range resp.Items {
+		for _, result := range item {
+			switch {
+			case result.Status == http.StatusTooManyRequests:
+				retry = append(retry, entries[i])
+			case result.Error != nil:
+				b.fail(ctx, entries[i:i+1], &Error{Status: result.Status, Type: result.Error.Type, Reason: result.Error.Reason})
+			default:
+				indexed++
+			}
+		}
+	}
+	b.items.Add(ctx, int64(indexed), metric.WithAttributes(attribute.String("result", "indexed")))
+	return retry, nil
+}
+
+// fail reports the failure of entries.
+func (b *BulkIndexer) fail(ctx context.Context, entries []bulkEntry, err error) {
+	b.items.Add(ctx, int64(len(entries)), metric.WithAttributes(attribute.String("result", "failed")))
+	for _, e := range entries {
+		if e.item.OnFailure != nil {
+			e.item.OnFailure(ctx, e.item, err)
+			continue
+		}
+		b.logger.Error().Err(err).Str("index", e.item.Index).Str("id", e.item.ID).Msg("Failed to index item")
+	}
+}
@@ -0,0 +1,131 @@
+package zielastic
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// bulkCluster serves the bulk API, rejecting the first attempt of the items
+// with id "busy" with a 429, and failing the items with id "bad".
+type bulkCluster struct {
+	mu       sync.Mutex
+	requests int
+	ids      []string
+	busy     bool
+}
+
+func (f *bulkCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+	if ct := r.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var items []string
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var action map[string]map[string]string
+		json.Unmarshal(scanner.Bytes(), &action)
+		meta := action["index"]
+		id := meta["_id"]
+		f.ids = append(f.ids, id)
+		scanner.Scan() // document
+		switch {
+		case id == "busy" && !f.busy:
+			f.busy = true
+			items = append(items, `{"index":{"status":429}}`)
+		case id == "bad":
+			items = append(items, `{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"bad"}}}`)
+		default:
+			items = append(items, `{"index":{"status":201}}`)
+		}
+	}
+	w.Write([]byte(`{"errors":true,"items":[` + strings.Join(items, ",") + `]}`))
+}
+
+func TestBulkIndexer(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	cluster := &bulkCluster{}
+	srv := httptest.NewServer(cluster)
+	defer srv.Close()
+
+	logger := zerolog.Nop()
+	b := NewClient(srv.URL).NewBulkIndexer(BulkConfig{FlushItems: 2, FlushInterval: time.Hour, Backoff: time.Millisecond}, &logger)
+	var failed []string
+	onFailure := func(ctx context.Context, item BulkItem, err error) {
+		failed = append(failed, item.ID+": "+err.(*Error).Type)
+	}
+	ctx := context.Background()
+	for _, id := range []string{"1", "busy", "bad", "2", "3"} {
+		item := BulkItem{Index: "products", ID: id, Document: map[string]string{"name": id}, OnFailure: onFailure}
+		if err := b.Add(ctx, item); err != nil {
+			t.Fatalf("Add() = %v", err)
+		}
+	}
+	if err := b.Close(ctx); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if err := b.Add(ctx, BulkItem{ID: "4"}); err != ErrBulkIndexerClosed {
+		t.Errorf("Add() after Close() = %v, want ErrBulkIndexerClosed", err)
+	}
+
+	// 3 batches of at most 2 items, and the retry of "busy"
+	if cluster.requests != 4 || len(cluster.ids) != 6 {
+		t.Errorf("%d requests of %v, want 4 requests of 6 items", cluster.requests, cluster.ids)
+	}
+	if len(failed) != 1 || failed[0] != "bad: mapper_parsing_exception" {
+		t.Errorf("failures = %v", failed)
+	}
+	reveliotest.AssertCounterValue(t, s, "elastic_bulk_items_total", 4, attribute.String("result", "indexed"))
+	reveliotest.AssertCounterValue(t, s, "elastic_bulk_items_total", 1, attribute.String("result", "retried"))
+	reveliotest.AssertCounterValue(t, s, "elastic_bulk_items_total", 1, attribute.String("result", "failed"))
+}
+
+func TestBulkIndexerFlushInterval(t *testing.T) {
+	cluster := &bulkCluster{}
+	srv := httptest.NewServer(cluster)
+	defer srv.Close()
+
+	logger := zerolog.Nop()
+	b := NewClient(srv.URL).NewBulkIndexer(BulkConfig{FlushInterval: 10 * time.Millisecond}, &logger)
+	defer b.Close(context.Background())
+	if err := b.Add(context.Background(), BulkItem{ID: "1", Document: json.RawMessage(`{"name": "1"}`)}); err != nil {
+		t.Fatalf("Add() = %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		cluster.mu.Lock()
+		n := len(cluster.ids)
+		cluster.mu.Unlock()
+		if n == 1 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("pending item not flushed after the flush interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBulkItemLines(t *testing.T) {
+	lines, err := BulkItem{Action: "delete", Index: "products", ID: "1"}.lines()
+	if err != nil || string(lines) != `{"delete":{"_id":"1","_index":"products"}}`+"\n" {
+		t.Errorf("lines() = %q, %v", lines, err)
+	}
+	lines, err = BulkItem{ID: "1", Document: json.RawMessage("{\n\"a\": 1\n}")}.lines()
+	if err != nil || string(lines) != `{"index":{"_id":"1"}}`+"\n"+`{"a":1}`+"\n" {
+		t.Errorf("lines() = %q, %v", lines, err)
+	}
+}
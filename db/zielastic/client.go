@@ -0,0 +1,208 @@
+// Package zielastic is a client of the REST API of Elasticsearch and
+// OpenSearch clusters: its calls are traced and timed, documents are indexed
+// in bulk with backpressure, and indices are managed with versioned
+// migrations: every version of an index is created from its mapping
+// template, filled by reindexing the previous version, and swapped behind an
+// alias, so search index migrations follow the discipline of SQL ones.
+package zielastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/divikraf/lumos/db/zielastic"
+
+// Error is an error response of Elasticsearch.
+type Error struct {
+	Status int
+	Type   string
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("zielastic: %d %s: %s", e.Status, e.Type, e.Reason)
+}
+
+// ErrorType returns the type of the error, as the normalized error type of
+// metrics.
+func (e *Error) ErrorType() string {
+	if e.Type == "" {
+		return "http_" + strconv.Itoa(e.Status)
+	}
+	return e.Type
+}
+
+// Client calls the REST API of an Elasticsearch cluster. Every call is
+// traced, and recorded in the elastic_request_duration_ms and
+// elastic_requests_total metrics by operation, the endpoint of the call,
+// e.g. "_search" or "_bulk".
+type Client struct {
+	url    string
+	client *http.Client
+	header http.Header
+
+	duration revelio.DurationRecorder
+	requests revelio.ResultCounter
+}
+
+// ClientOption configures a Client.
+type ClientOption func(*Client)
+
+// WithHTTPClient sets the HTTP client of the calls (default:
+// http.DefaultClient), e.g. an instrumented one.
+func WithHTTPClient(client *http.Client) ClientOption {
+	return func(c *Client) {
+		c.client = client
+	}
+}
+
+// WithBasicAuth authenticates the calls with a username and a password.
+func WithBasicAuth(username, password string) ClientOption {
+	return func(c *Client) {
+		r := &http.Request{Header: http.Header{}}
+		r.SetBasicAuth(username, password)
+		c.header.Set("Authorization", r.Header.Get("Authorization"))
+	}
+}
+
+// WithAPIKey authenticates the calls with an encoded API key.
+func WithAPIKey(key string) ClientOption {
+	return func(c *Client) {
+		c.header.Set("Authorization", "ApiKey "+key)
+	}
+}
+
+// NewClient returns a Client of the cluster at url, e.g.
+// "http://localhost:9200".
+func NewClient(url string, opts ...ClientOption) *Client {
+	c := &Client{
+		url:      strings.TrimRight(url, "/"),
+		client:   http.DefaultClient,
+		header:   http.Header{},
+		duration: revelio.MustDuration("elastic_request_duration_ms", "Duration of Elasticsearch requests in milliseconds, by operation and status"),
+		requests: revelio.MustResultCounter("elastic_requests_total", "Number of Elasticsearch requests, by operation and status"),
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// Do sends a request with the JSON body in, if not nil, and decodes the JSON
+// response in out, if not nil. Error responses are returned as *Error.
+func (c *Client) Do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	return c.do(ctx, method, path, "application/json", body, out)
+}
+
+// do sends a request with body of contentType, and decodes the JSON response
+// in out, if not nil.
+func (c *Client) do(ctx context.Context, method, path, contentType string, body io.Reader, out any) (err error) {
+	op := operation(path)
+	attrs := []attribute.KeyValue{attribute.String("db.operation.name", op)}
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "elasticsearch "+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "elasticsearch"),
+			attribute.String("http.request.method", method),
+		),
+	)
+	start := time.Now()
+	defer func() {
+		c.duration.Record(ctx, time.Since(start), append(attrs, revelio.ResultAttributes(err)...)...)
+		c.requests.Record(ctx, err, attrs...)
+		if err != nil {
+			observe.RecordError(span, err)
+		}
+		span.End()
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, body)
+	if err != nil {
+		return err
+	}
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Error struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+		return &Error{Status: resp.StatusCode, Type: e.Error.Type, Reason: e.Error.Reason}
+	}
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// operation returns the endpoint of path, its last segment starting with an
+// underscore, e.g. "_search" for "/products/_search", and "index" for the
+// paths of an index. Unlike the paths, the operations are bounded.
+func operation(path string) string {
+	path, _, _ = strings.Cut(path, "?")
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if strings.HasPrefix(segments[i], "_") {
+			return segments[i]
+		}
+	}
+	if segments[0] == "" {
+		return "root"
+	}
+	return "index"
+}
+
+// ClusterHealthRed is the status of a cluster with unassigned primary shards.
+const ClusterHealthRed = "red"
+
+// Ping checks the health of the cluster, failing when it is red.
+func (c *Client) Ping(ctx context.Context) error {
+	var health struct {
+		Status string `json:"status"`
+	}
+	if err := c.Do(ctx, http.MethodGet, "/_cluster/health", nil, &health); err != nil {
+		return err
+	}
+	if health.Status == ClusterHealthRed {
+		return fmt.Errorf("zielastic: cluster health is %s", health.Status)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package zielastic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestOperation(t *testing.T) {
+	for path, want := range map[string]string{
+		"/products/_search?size=10": "_search",
+		"/products/_doc/42":         "_doc",
+		"/_cluster/health":          "_cluster",
+		"/products_v2":              "index",
+		"/":                         "root",
+	} {
+		if got := operation(path); got != want {
+			t.Errorf("operation(%s) = %s, want %s", path, got, want)
+		}
+	}
+}
+
+func TestClientMetrics(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	status := "green"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/_cluster/health":
+			w.Write([]byte(`{"status":"` + status + `"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"type":"index_not_found_exception","reason":"no such index"}}`))
+		}
+	}))
+	defer srv.Close()
+	c := NewClient(srv.URL)
+	ctx := context.Background()
+
+	if err := c.Ping(ctx); err != nil {
+		t.Errorf("Ping() = %v", err)
+	}
+	status = ClusterHealthRed
+	if err := c.Ping(ctx); err == nil {
+		t.Error("Ping() of a red cluster succeeded")
+	}
+	var e *Error
+	if err := c.Do(ctx, http.MethodGet, "/missing/_search", nil, nil); !errors.As(err, &e) || e.Status != http.StatusNotFound {
+		t.Errorf("Do() = %v, want a 404 *Error", err)
+	}
+
+	reveliotest.AssertCounterValue(t, s, "elastic_requests_total", 2,
+		attribute.String("db.operation.name", "_cluster"), attribute.String("status", "success"))
+	reveliotest.AssertCounterValue(t, s, "elastic_requests_total", 1,
+		attribute.String("db.operation.name", "_search"), attribute.String("error.type", "index_not_found_exception"))
+	if h := reveliotest.CollectHistogram(t, s, "elastic_request_duration_ms", attribute.String("db.operation.name", "_cluster")); h.Count != 2 {
+		t.Errorf("health requests count = %d, want 2", h.Count)
+	}
+}
@@ -0,0 +1,60 @@
+// Command zielastic migrates search indices to the latest versions of their
+// mapping templates, see zielastic.LoadMigrations:
+//
+//	zielastic migrate -url "$ELASTICSEARCH_URL" mappings/
+//
+// Previous index versions are deleted once migrated, -keep-old keeps them.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/divikraf/lumos/db/zielastic"
+	"github.com/rs/zerolog"
+)
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "migrate" {
+		fmt.Fprintln(os.Stderr, "usage: zielastic migrate -url URL [-api-key KEY] [-keep-old] DIR")
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	url := flags.String("url", "", "Elasticsearch URL, e.g. http://localhost:9200")
+	apiKey := flags.String("api-key", os.Getenv("ELASTICSEARCH_API_KEY"), "encoded API key")
+	keepOld := flags.Bool("keep-old", false, "keep the previous index versions")
+	_ = flags.Parse(os.Args[2:])
+
+	if *url == "" || flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	migrations, err := zielastic.LoadMigrations(os.DirFS(flags.Arg(0)), ".")
+	if err != nil {
+		fatal(err)
+	}
+
+	var clientOpts []zielastic.ClientOption
+	if *apiKey != "" {
+		clientOpts = append(clientOpts, zielastic.WithAPIKey(*apiKey))
+	}
+	var migratorOpts []zielastic.MigratorOption
+	if *keepOld {
+		migratorOpts = append(migratorOpts, zielastic.WithKeepOld())
+	}
+	logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).With().Timestamp().Logger()
+	migrator := zielastic.NewMigrator(zielastic.NewClient(*url, clientOpts...), &logger, migratorOpts...)
+	if err := migrator.Migrate(context.Background(), migrations...); err != nil {
+		fatal(err)
+	}
+	fmt.Printf("migrated %d indices\n", len(migrations))
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
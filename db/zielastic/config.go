@@ -0,0 +1,73 @@
+package zielastic
+
+import (
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zihttpc"
+	"github.com/rs/zerolog"
+	"go.uber.org/fx"
+)
+
+// Config configures the Client of a cluster. Basic authentication is used
+// when Username is set, the API key when APIKey is set.
+type Config struct {
+	URL      string `json:"url" yaml:"url" validate:"required"`
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+	APIKey   string `json:"apiKey" yaml:"apiKey"`
+	// HTTP configures the HTTP client of the calls, see zihttpc.New.
+	HTTP zihttpc.ClientConfig `json:"http" yaml:"http"`
+	// Bulk configures the BulkIndexer provided with the Client.
+	Bulk BulkConfig `json:"bulk" yaml:"bulk"`
+}
+
+// elasticConfig is implemented by configurations of a cluster.
+type elasticConfig interface {
+	GetElastic() Config
+}
+
+// NewClientFromConfig returns the Client of the cluster of config.
+func NewClientFromConfig(config Config) *Client {
+	opts := []ClientOption{WithHTTPClient(zihttpc.New(config.HTTP))}
+	if config.Username != "" {
+		opts = append(opts, WithBasicAuth(config.Username, config.Password))
+	}
+	if config.APIKey != "" {
+		opts = append(opts, WithAPIKey(config.APIKey))
+	}
+	return NewClient(config.URL, opts...)
+}
+
+// configOf returns the elastic config of config, when it has one.
+func configOf(config ziconf.Config) Config {
+	if c, ok := config.(elasticConfig); ok {
+		return c.GetElastic()
+	}
+	return Config{}
+}
+
+type NewConfiguredClientParams struct {
+	fx.In
+	Config ziconf.Config
+}
+
+// NewConfiguredClient returns the Client configured by the elastic config.
+func NewConfiguredClient(params NewConfiguredClientParams) *Client {
+	return NewClientFromConfig(configOf(params.Config))
+}
+
+type NewConfiguredBulkIndexerParams struct {
+	fx.In
+	Config ziconf.Config
+	Client *Client
+	Logger *zerolog.Logger
+	LC     fx.Lifecycle
+}
+
+// NewConfiguredBulkIndexer returns the BulkIndexer of the Client, configured
+// by the bulk section of the elastic config, flushed and closed when the
+// application stops.
+func NewConfiguredBulkIndexer(params NewConfiguredBulkIndexerParams) *BulkIndexer {
+	b := params.Client.NewBulkIndexer(configOf(params.Config).Bulk, params.Logger)
+	params.LC.Append(fx.StopHook(b.Close))
+	return b
+}
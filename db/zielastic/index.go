@@ -0,0 +1,256 @@
+package zielastic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/rs/zerolog"
+)
+
+// Migration is a version of an index, served behind its alias.
+type Migration struct {
+	// Alias is the name the index is searched by, e.g. "products".
+	Alias   string
+	Version int
+	// Body is the body creating the index, with its settings and mappings.
+	Body json.RawMessage
+	// Policy, if set, is the ILM policy applied to the index before it is
+	// created, named after the alias.
+	Policy json.RawMessage
+}
+
+// Index returns the name of the index of the migration, e.g. "products_v3".
+func (m Migration) Index() string {
+	return VersionedIndex(m.Alias, m.Version)
+}
+
+// VersionedIndex returns the name of the version of the index alias.
+func VersionedIndex(alias string, version int) string {
+	return fmt.Sprintf("%s_v%d", alias, version)
+}
+
+var migrationFile = regexp.MustCompile(`^(.+)\.v(\d+)\.json$`)
+
+// LoadMigrations loads the latest migration of every alias from the mapping
+// templates of dir in fsys, named "<alias>.v<version>.json", e.g.
+// "products.v3.json". A "<alias>.policy.json" file holds the ILM policy of
+// the alias. Migrations are sorted by alias.
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	latest := map[string]Migration{}
+	for _, e := range entries {
+		match := migrationFile.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.Atoi(match[2])
+		if err != nil {
+			return nil, fmt.Errorf("zielastic: invalid version of %s: %w", e.Name(), err)
+		}
+		if m, ok := latest[match[1]]; ok && m.Version >= version {
+			continue
+		}
+		body, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if !json.Valid(body) {
+			return nil, fmt.Errorf("zielastic: invalid JSON in %s", e.Name())
+		}
+		latest[match[1]] = Migration{Alias: match[1], Version: version, Body: body}
+	}
+
+	migrations := make([]Migration, 0, len(latest))
+	for alias, m := range latest {
+		policy, err := fs.ReadFile(fsys, path.Join(dir, alias+".policy.json"))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		if policy != nil {
+			if !json.Valid(policy) {
+				return nil, fmt.Errorf("zielastic: invalid JSON in %s.policy.json", alias)
+			}
+			m.Policy = policy
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Alias < migrations[j].Alias })
+	return migrations, nil
+}
+
+// Migrator applies migrations to a cluster.
+type Migrator struct {
+	client  *Client
+	logger  *zerolog.Logger
+	keepOld bool
+}
+
+// MigratorOption configures a Migrator.
+type MigratorOption func(*Migrator)
+
+// WithKeepOld keeps the previous versions of the indices once migrated,
+// e.g. to roll back by swapping the alias back. They are deleted by default.
+func WithKeepOld() MigratorOption {
+	return func(m *Migrator) {
+		m.keepOld = true
+	}
+}
+
+// NewMigrator returns a Migrator of the cluster of client.
+func NewMigrator(client *Client, logger *zerolog.Logger, opts ...MigratorOption) *Migrator {
+	m := &Migrator{client: client, logger: logger}
+	for _, o := range opts {
+		o(m)
+	}
+	return m
+}
+
+// Migrate applies migrations in order, see MigrateIndex.
+func (m *Migrator) Migrate(ctx context.Context, migrations ...Migration) error {
+	for _, migration := range migrations {
+		if err := m.MigrateIndex(ctx, migration); err != nil {
+			return fmt.Errorf("zielastic: migrating %s: %w", migration.Index(), err)
+		}
+	}
+	return nil
+}
+
+// MigrateIndex migrates the alias of migration to its version, without
+// downtime: the index of the version is created with the ILM policy of the
+// migration, filled by reindexing the index currently behind the alias, if
+// any, then the alias is swapped atomically to it. Migrating to the current
+// version does nothing, so migrations can run on every deployment. Searches
+// don't see the writes made to the previous index during the reindex, so
+// writers should be paused or replayed meanwhile.
+func (m *Migrator) MigrateIndex(ctx context.Context, migration Migration) error {
+	current, err := m.Current(ctx, migration.Alias)
+	if err != nil {
+		return err
+	}
+	target := migration.Index()
+	if current == target {
+		return nil
+	}
+
+	if migration.Policy != nil {
+		if err := m.ApplyPolicy(ctx, migration.Alias, migration.Policy); err != nil {
+			return err
+		}
+	}
+	if err := m.createIndex(ctx, migration); err != nil {
+		return err
+	}
+	if current != "" {
+		if err := m.reindex(ctx, current, target); err != nil {
+			return err
+		}
+	}
+	if err := m.swapAlias(ctx, migration.Alias, current, target); err != nil {
+		return err
+	}
+	m.logger.Info().Str("alias", migration.Alias).Str("from", current).Str("to", target).Msg("search index migrated")
+
+	if current != "" && !m.keepOld {
+		return m.client.Do(ctx, http.MethodDelete, "/"+current, nil, nil)
+	}
+	return nil
+}
+
+// Current returns the index behind alias, empty when the alias doesn't
+// exist.
+func (m *Migrator) Current(ctx context.Context, alias string) (string, error) {
+	var indices map[string]json.RawMessage
+	err := m.client.Do(ctx, http.MethodGet, "/_alias/"+alias, nil, &indices)
+	var e *Error
+	if errors.As(err, &e) && e.Status == http.StatusNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(indices) > 1 {
+		return "", fmt.Errorf("zielastic: alias %s points to %d indices", alias, len(indices))
+	}
+	for index := range indices {
+		return index, nil
+	}
+	return "", nil
+}
+
+// ApplyPolicy creates or updates the ILM policy name.
+func (m *Migrator) ApplyPolicy(ctx context.Context, name string, policy json.RawMessage) error {
+	return m.client.Do(ctx, http.MethodPut, "/_ilm/policy/"+name, map[string]json.RawMessage{"policy": policy}, nil)
+}
+
+// createIndex creates the index of migration, managed by its ILM policy if
+// any. An index left by an interrupted migration is reused.
+func (m *Migrator) createIndex(ctx context.Context, migration Migration) error {
+	body := map[string]json.RawMessage{}
+	if err := json.Unmarshal(migration.Body, &body); err != nil {
+		return err
+	}
+	if migration.Policy != nil {
+		settings := map[string]any{}
+		if s, ok := body["settings"]; ok {
+			if err := json.Unmarshal(s, &settings); err != nil {
+				return err
+			}
+		}
+		settings["index.lifecycle.name"] = migration.Alias
+		b, err := json.Marshal(settings)
+		if err != nil {
+			return err
+		}
+		body["settings"] = b
+	}
+
+	err := m.client.Do(ctx, http.MethodPut, "/"+migration.Index(), body, nil)
+	var e *Error
+	if errors.As(err, &e) && e.Type == "resource_already_exists_exception" {
+		return nil
+	}
+	return err
+}
+
+// reindex copies the documents of from to to, and refreshes to so they are
+// searchable once the alias is swapped.
+func (m *Migrator) reindex(ctx context.Context, from, to string) error {
+	var result struct {
+		Total    int               `json:"total"`
+		Failures []json.RawMessage `json:"failures"`
+	}
+	err := m.client.Do(ctx, http.MethodPost, "/_reindex?wait_for_completion=true", map[string]any{
+		"source": map[string]string{"index": from},
+		"dest":   map[string]string{"index": to},
+	}, &result)
+	if err != nil {
+		return err
+	}
+	if len(result.Failures) > 0 {
+		return fmt.Errorf("zielastic: %d failures reindexing %s to %s: %s", len(result.Failures), from, to, result.Failures[0])
+	}
+	m.logger.Info().Str("from", from).Str("to", to).Int("documents", result.Total).Msg("search index reindexed")
+	return m.client.Do(ctx, http.MethodPost, "/"+to+"/_refresh", nil, nil)
+}
+
+// swapAlias moves alias from the index from, if any, to the index to, in a
+// single atomic action.
+func (m *Migrator) swapAlias(ctx context.Context, alias, from, to string) error {
+	var actions []map[string]map[string]string
+	if from != "" {
+		actions = append(actions, map[string]map[string]string{"remove": {"index": from, "alias": alias}})
+	}
+	actions = append(actions, map[string]map[string]string{"add": {"index": to, "alias": alias}})
+	return m.client.Do(ctx, http.MethodPost, "/_aliases", map[string]any{"actions": actions}, nil)
+}
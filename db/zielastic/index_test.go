@@ -0,0 +1,121 @@
+package zielastic
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/rs/zerolog"
+)
+
+// fakeCluster serves the calls of Migrator, with a single alias.
+type fakeCluster struct {
+	alias string
+	calls []string
+}
+
+func (f *fakeCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	call := r.Method + " " + r.URL.Path
+	f.calls = append(f.calls, call)
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/_alias/"):
+		if f.alias == "" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"type":"aliases_not_found_exception","reason":"missing"}}`))
+			return
+		}
+		w.Write([]byte(`{"` + f.alias + `":{"aliases":{"products":{}}}}`))
+	case call == "POST /_aliases":
+		var body struct {
+			Actions []map[string]map[string]string `json:"actions"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.alias = body.Actions[len(body.Actions)-1]["add"]["index"]
+		w.Write([]byte(`{"acknowledged":true}`))
+	case call == "POST /_reindex":
+		w.Write([]byte(`{"total":3,"failures":[]}`))
+	default:
+		w.Write([]byte(`{"acknowledged":true}`))
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"mappings/products.v1.json":     {Data: []byte(`{"mappings":{}}`)},
+		"mappings/products.v2.json":     {Data: []byte(`{"mappings":{"properties":{}}}`)},
+		"mappings/products.policy.json": {Data: []byte(`{"phases":{}}`)},
+		"mappings/orders.v1.json":       {Data: []byte(`{}`)},
+		"mappings/README.md":            {Data: []byte(`ignored`)},
+	}
+	migrations, err := LoadMigrations(fsys, "mappings")
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("Migrations = %d, want 2", len(migrations))
+	}
+	if m := migrations[0]; m.Index() != "orders_v1" || m.Policy != nil {
+		t.Errorf("Migration = %s with policy %s, want orders_v1 without policy", m.Index(), m.Policy)
+	}
+	if m := migrations[1]; m.Index() != "products_v2" || string(m.Policy) != `{"phases":{}}` {
+		t.Errorf("Migration = %s with policy %s, want products_v2 with policy", m.Index(), m.Policy)
+	}
+}
+
+func TestMigrateIndex(t *testing.T) {
+	cluster := &fakeCluster{alias: "products_v1"}
+	srv := httptest.NewServer(cluster)
+	defer srv.Close()
+
+	logger := zerolog.Nop()
+	m := NewMigrator(NewClient(srv.URL), &logger)
+	migration := Migration{Alias: "products", Version: 2, Body: json.RawMessage(`{"mappings":{}}`), Policy: json.RawMessage(`{}`)}
+	ctx := context.Background()
+	if err := m.MigrateIndex(ctx, migration); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	want := []string{
+		"GET /_alias/products",
+		"PUT /_ilm/policy/products",
+		"PUT /products_v2",
+		"POST /_reindex",
+		"POST /products_v2/_refresh",
+		"POST /_aliases",
+		"DELETE /products_v1",
+	}
+	if !reflect.DeepEqual(cluster.calls, want) {
+		t.Errorf("Calls = %v, want %v", cluster.calls, want)
+	}
+
+	cluster.calls = nil
+	if err := m.MigrateIndex(ctx, migration); err != nil {
+		t.Fatalf("Failed to migrate again: %v", err)
+	}
+	if want := []string{"GET /_alias/products"}; !reflect.DeepEqual(cluster.calls, want) {
+		t.Errorf("Calls = %v, want %v", cluster.calls, want)
+	}
+}
+
+func TestMigrateNewIndex(t *testing.T) {
+	cluster := &fakeCluster{}
+	srv := httptest.NewServer(cluster)
+	defer srv.Close()
+
+	logger := zerolog.Nop()
+	m := NewMigrator(NewClient(srv.URL), &logger)
+	if err := m.MigrateIndex(context.Background(), Migration{Alias: "products", Version: 1, Body: json.RawMessage(`{}`)}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	want := []string{"GET /_alias/products", "PUT /products_v1", "POST /_aliases"}
+	if !reflect.DeepEqual(cluster.calls, want) {
+		t.Errorf("Calls = %v, want %v", cluster.calls, want)
+	}
+	if cluster.alias != "products_v1" {
+		t.Errorf("Alias = %q, want products_v1", cluster.alias)
+	}
+}
@@ -0,0 +1,36 @@
+package zielasticfx
+
+import (
+	"github.com/divikraf/lumos/db/zielastic"
+	"github.com/divikraf/lumos/zihealth"
+	"go.uber.org/fx"
+)
+
+type clientParams struct {
+	fx.In
+	zielastic.NewConfiguredClientParams
+
+	LC fx.Lifecycle
+}
+
+type fxResult struct {
+	fx.Out
+
+	Client  *zielastic.Client
+	Checker zihealth.Checker `group:"health.checker"`
+}
+
+// Provider provides the Client configured by the elastic config, pinged when
+// the application starts and checked by the "elasticsearch" health check, and
+// its BulkIndexer.
+var Provider = fx.Provide(
+	func(params clientParams) fxResult {
+		client := zielastic.NewConfiguredClient(params.NewConfiguredClientParams)
+		params.LC.Append(fx.StartHook(client.Ping))
+		return fxResult{
+			Client:  client,
+			Checker: zihealth.NewChecker("elasticsearch", client.Ping),
+		}
+	},
+	zielastic.NewConfiguredBulkIndexer,
+)
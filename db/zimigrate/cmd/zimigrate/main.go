@@ -0,0 +1,58 @@
+// Command zimigrate migrates a database with the migrations of a directory,
+// see zimigrate.Load:
+//
+//	zimigrate -driver postgres -dsn "$DATABASE_DSN" -dir migrations up
+//	zimigrate -driver mysql -dsn "$DATABASE_DSN" -dir migrations down 2
+//	zimigrate -driver postgres -dsn "$DATABASE_DSN" -dir migrations status
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/divikraf/lumos/db/zimigrate"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	flags := flag.NewFlagSet("zimigrate", flag.ExitOnError)
+	driver := flags.String("driver", "", "database driver, mysql or postgres")
+	dsn := flags.String("dsn", "", "database DSN")
+	dir := flags.String("dir", "migrations", "directory of the migrations")
+	table := flags.String("table", zimigrate.DefaultTable, "history table of the applied migrations")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: zimigrate -driver mysql|postgres -dsn DSN [-dir DIR] [-table TABLE] COMMAND")
+		flags.PrintDefaults()
+		fmt.Fprintln(os.Stderr, zimigrate.Usage)
+	}
+	_ = flags.Parse(os.Args[1:])
+
+	if *driver == "" || *dsn == "" || flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	migrations, err := zimigrate.Load(os.DirFS(*dir))
+	if err != nil {
+		fatal(err)
+	}
+	db, err := sqlx.Open(*driver, *dsn)
+	if err != nil {
+		fatal(err)
+	}
+	defer db.Close()
+
+	m := zimigrate.New(db, zimigrate.Dialect(*driver), migrations, zimigrate.WithTable(*table))
+	if err := zimigrate.Run(context.Background(), m, flags.Args(), os.Stdout); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,59 @@
+package zimigrate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Usage is the usage of the commands run by Run.
+const Usage = `commands:
+  up          apply the pending migrations
+  down [N]    revert the last N applied migrations (default: 1)
+  status      list the migrations and when they were applied`
+
+// Run runs the migration command of args, up, down or status, printing its
+// result to out. It lets services ship a migration entrypoint, e.g. run as
+// "service migrate up" before the deployment.
+func Run(ctx context.Context, m *Migrator, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("zimigrate: missing command\n%s", Usage)
+	}
+	switch command := args[0]; {
+	case command == "up" && len(args) == 1:
+		n, err := m.Up(ctx)
+		fmt.Fprintf(out, "applied %d migrations\n", n)
+		return err
+	case command == "down" && len(args) <= 2:
+		steps := 1
+		if len(args) == 2 {
+			var err error
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps <= 0 {
+				return fmt.Errorf("zimigrate: invalid number of migrations %q", args[1])
+			}
+		}
+		n, err := m.Down(ctx, steps)
+		fmt.Fprintf(out, "reverted %d migrations\n", n)
+		return err
+	case command == "status" && len(args) == 1:
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			name, applied := s.Name, "pending"
+			if name == "" {
+				name = "(missing)"
+			}
+			if s.Applied {
+				applied = "applied " + s.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(out, "%d\t%s\t%s\n", s.Version, name, applied)
+		}
+		return nil
+	default:
+		return fmt.Errorf("zimigrate: invalid command %q\n%s", args, Usage)
+	}
+}
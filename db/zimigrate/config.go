@@ -0,0 +1,45 @@
+package zimigrate
+
+import (
+	"time"
+
+	"github.com/divikraf/lumos/ziconf"
+)
+
+// Config configures the Migrator.
+type Config struct {
+	// Table is the history table (default: DefaultTable).
+	Table string `json:"table" yaml:"table"`
+	// LockTimeout is the maximum duration waiting for the migration lock
+	// (default: 10m).
+	LockTimeout time.Duration `json:"lock_timeout" yaml:"lock_timeout"`
+	// Disabled skips the migrations on startup, e.g. when they are run by
+	// a deployment job instead.
+	Disabled bool `json:"disabled" yaml:"disabled"`
+}
+
+// migrateConfig is implemented by configurations of services migrating
+// their database.
+type migrateConfig interface {
+	GetMigrate() Config
+}
+
+// ConfigOf returns the migration config of c, if any.
+func ConfigOf(c ziconf.Config) Config {
+	if mc, ok := c.(migrateConfig); ok {
+		return mc.GetMigrate()
+	}
+	return Config{}
+}
+
+// Options returns the options of the Migrator configured by c.
+func (c Config) Options() []Option {
+	var opts []Option
+	if c.Table != "" {
+		opts = append(opts, WithTable(c.Table))
+	}
+	if c.LockTimeout > 0 {
+		opts = append(opts, WithLockTimeout(c.LockTimeout))
+	}
+	return opts
+}
@@ -0,0 +1,386 @@
+// Package zimigrate migrates the schema of PostgreSQL and MySQL databases
+// with versioned SQL migrations, usually embedded in the service, see Load.
+// The applied migrations are recorded in a history table, and the runs are
+// serialized by an advisory lock, so the replicas of a deployment can all
+// migrate on startup.
+package zimigrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/divikraf/lumos/db/zimigrate"
+
+var tracer = observe.LibraryTracer(instrumentationName)
+
+// Dialect is the SQL dialect of the migrated database.
+type Dialect string
+
+const (
+	MySQL    Dialect = "mysql"
+	Postgres Dialect = "postgres"
+)
+
+// DefaultTable is the default history table of the applied migrations.
+const DefaultTable = "schema_migrations"
+
+var (
+	// ErrLockTimeout is returned when the migration lock isn't acquired
+	// within the lock timeout, e.g. held by a long migration of another
+	// replica.
+	ErrLockTimeout = errors.New("zimigrate: timed out acquiring the migration lock")
+	// ErrIrreversible is returned when reverting a migration without down
+	// file.
+	ErrIrreversible = errors.New("zimigrate: irreversible migration")
+)
+
+// Status is the status of a migration.
+type Status struct {
+	Version int64
+	// Name is empty for the applied migrations missing from the loaded
+	// ones.
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies and reverts migrations.
+type Migrator struct {
+	db          *sqlx.DB
+	dialect     Dialect
+	migrations  []Migration
+	table       string
+	lockName    string
+	lockTimeout time.Duration
+}
+
+// Option configures a Migrator.
+type Option func(*Migrator)
+
+// WithTable sets the history table (default: DefaultTable).
+func WithTable(table string) Option {
+	return func(m *Migrator) {
+		m.table = table
+	}
+}
+
+// WithLockName sets the name of the advisory lock serializing the runs
+// (default: zimigrate.<table>).
+func WithLockName(name string) Option {
+	return func(m *Migrator) {
+		m.lockName = name
+	}
+}
+
+// WithLockTimeout sets the maximum duration waiting for the lock (default:
+// 10m).
+func WithLockTimeout(d time.Duration) Option {
+	return func(m *Migrator) {
+		m.lockTimeout = d
+	}
+}
+
+// New returns a Migrator of migrations, e.g. returned by Load, on db. MySQL
+// migrations are run one statement at a time, so the DSN doesn't need
+// multiStatements, but MySQL commits the schema changes right away: a
+// failing MySQL migration may be partially applied.
+func New(db *sqlx.DB, dialect Dialect, migrations []Migration, opts ...Option) *Migrator {
+	m := &Migrator{
+		db:          db,
+		dialect:     dialect,
+		migrations:  append([]Migration(nil), migrations...),
+		table:       DefaultTable,
+		lockTimeout: 10 * time.Minute,
+	}
+	for _, o := range opts {
+		o(m)
+	}
+	if m.lockName == "" {
+		m.lockName = "zimigrate." + m.table
+	}
+	sort.Slice(m.migrations, func(i, j int) bool {
+		return m.migrations[i].Version < m.migrations[j].Version
+	})
+	return m
+}
+
+// Up applies the pending migrations, in version order, each in a
+// transaction recording it in the history table, and returns the number of
+// migrations applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.run(ctx, "up", func(ctx context.Context, conn *sqlx.Conn, history map[int64]time.Time) error {
+		for _, migration := range m.migrations {
+			if _, ok := history[migration.Version]; ok {
+				continue
+			}
+			err := m.apply(ctx, conn, migration, "up", migration.Up,
+				"INSERT INTO %s (version, name, applied_at) VALUES (?, ?, ?)",
+				migration.Version, migration.Name, time.Now().UTC())
+			if err != nil {
+				return err
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the last steps applied migrations, and returns the number of
+// migrations reverted. It returns ErrIrreversible for migrations without
+// down file.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	reverted := 0
+	err := m.run(ctx, "down", func(ctx context.Context, conn *sqlx.Conn, history map[int64]time.Time) error {
+		versions := make([]int64, 0, len(history))
+		for v := range history {
+			versions = append(versions, v)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+		for _, v := range versions[:min(steps, len(versions))] {
+			migration, ok := m.migration(v)
+			if !ok {
+				return fmt.Errorf("zimigrate: applied migration %d not found", v)
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("%w: %d_%s", ErrIrreversible, migration.Version, migration.Name)
+			}
+			err := m.apply(ctx, conn, migration, "down", migration.Down,
+				"DELETE FROM %s WHERE version = ?", migration.Version)
+			if err != nil {
+				return err
+			}
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status returns the status of the migrations, and of the applied
+// migrations missing from them, in version order.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.run(ctx, "status", func(ctx context.Context, conn *sqlx.Conn, history map[int64]time.Time) error {
+		for _, migration := range m.migrations {
+			appliedAt, ok := history[migration.Version]
+			statuses = append(statuses, Status{Version: migration.Version, Name: migration.Name, Applied: ok, AppliedAt: appliedAt})
+		}
+		for v, appliedAt := range history {
+			if _, ok := m.migration(v); !ok {
+				statuses = append(statuses, Status{Version: v, Applied: true, AppliedAt: appliedAt})
+			}
+		}
+		return nil
+	})
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, err
+}
+
+func (m *Migrator) migration(version int64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+func (m *Migrator) query(q string) string {
+	return sqlx.Rebind(sqlx.BindType(string(m.dialect)), fmt.Sprintf(q, m.table))
+}
+
+// run runs fn with a connection holding the migration lock, and the applied
+// migrations.
+func (m *Migrator) run(ctx context.Context, command string, fn func(ctx context.Context, conn *sqlx.Conn, history map[int64]time.Time) error) error {
+	ctx, span := tracer.Start(ctx, "migrate "+command, trace.WithAttributes(
+		attribute.String("db.system", string(m.dialect)),
+		attribute.String("zimigrate.table", m.table),
+	))
+	defer span.End()
+
+	err := func() error {
+		// The advisory locks are held by the session
+		conn, err := m.db.Connx(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if err := m.lock(ctx, conn); err != nil {
+			return err
+		}
+		defer m.unlock(context.WithoutCancel(ctx), conn)
+
+		if _, err := conn.ExecContext(ctx, m.historySchema()); err != nil {
+			return fmt.Errorf("zimigrate: failed to create the history table: %w", err)
+		}
+		history, err := m.history(ctx, conn)
+		if err != nil {
+			return err
+		}
+		return fn(ctx, conn, history)
+	}()
+	if err != nil {
+		observe.RecordError(span, err)
+	}
+	return err
+}
+
+func (m *Migrator) historySchema() string {
+	timestamp := "TIMESTAMPTZ"
+	if m.dialect == MySQL {
+		timestamp = "DATETIME(6)"
+	}
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	version BIGINT PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	applied_at %s NOT NULL
+)`, m.table, timestamp)
+}
+
+// history returns the applied migrations, with the time they were applied.
+func (m *Migrator) history(ctx context.Context, conn *sqlx.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, m.query("SELECT version, applied_at FROM %s"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	history := map[int64]time.Time{}
+	for rows.Next() {
+		var (
+			version   int64
+			appliedAt timestamp
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		history[version] = time.Time(appliedAt)
+	}
+	return history, rows.Err()
+}
+
+// apply runs the script of the migration in direction, then the history
+// statement, in a transaction.
+func (m *Migrator) apply(ctx context.Context, conn *sqlx.Conn, migration Migration, direction, script, history string, args ...any) error {
+	ctx, span := tracer.Start(ctx, fmt.Sprintf("migrate %s %d", direction, migration.Version), trace.WithAttributes(
+		attribute.Int64("zimigrate.version", migration.Version),
+		attribute.String("zimigrate.name", migration.Name),
+	))
+	defer span.End()
+	logger := zilog.FromContext(ctx).With().
+		Int64("version", migration.Version).Str("name", migration.Name).Str("direction", direction).
+		Logger()
+
+	start := time.Now()
+	err := func() error {
+		tx, err := conn.BeginTxx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		statements := []string{script}
+		if m.dialect == MySQL {
+			statements = splitStatements(script)
+		}
+		for _, statement := range statements {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, m.query(history), args...); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}()
+	if err != nil {
+		observe.RecordError(span, err)
+		logger.Error().Err(err).Msg("Migration failed")
+		return fmt.Errorf("zimigrate: migration %d_%s %s failed: %w", migration.Version, migration.Name, direction, err)
+	}
+	logger.Info().Dur("duration", time.Since(start)).Msg("Migration applied")
+	return nil
+}
+
+// lock acquires the advisory lock of the migrations on conn, waiting up to
+// the lock timeout.
+func (m *Migrator) lock(ctx context.Context, conn *sqlx.Conn) error {
+	if m.dialect == MySQL {
+		var locked sql.NullInt64
+		if err := conn.GetContext(ctx, &locked, "SELECT GET_LOCK(?, ?)", m.lockName, int(m.lockTimeout.Seconds())); err != nil {
+			return err
+		}
+		if locked.Int64 != 1 {
+			return ErrLockTimeout
+		}
+		return nil
+	}
+
+	lockCtx, cancel := context.WithTimeout(ctx, m.lockTimeout)
+	defer cancel()
+	_, err := conn.ExecContext(lockCtx, "SELECT pg_advisory_lock($1)", m.lockKey())
+	if err != nil && ctx.Err() == nil && errors.Is(lockCtx.Err(), context.DeadlineExceeded) {
+		return ErrLockTimeout
+	}
+	return err
+}
+
+func (m *Migrator) unlock(ctx context.Context, conn *sqlx.Conn) {
+	var err error
+	if m.dialect == MySQL {
+		_, err = conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", m.lockName)
+	} else {
+		_, err = conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", m.lockKey())
+	}
+	if err != nil {
+		// The lock is released with the session anyway
+		zilog.FromContext(ctx).Warn().Err(err).Msg("Failed to release the migration lock")
+	}
+}
+
+// lockKey returns the key of the PostgreSQL advisory lock named lockName.
+func (m *Migrator) lockKey() int64 {
+	h := fnv.New64a()
+	h.Write([]byte(m.lockName))
+	return int64(h.Sum64())
+}
+
+// timestamp scans the times of the history table, which the MySQL driver
+// returns as text unless parseTime is set.
+type timestamp time.Time
+
+func (t *timestamp) Scan(src any) error {
+	switch v := src.(type) {
+	case time.Time:
+		*t = timestamp(v)
+	case []byte:
+		return t.parse(string(v))
+	case string:
+		return t.parse(v)
+	case nil:
+	default:
+		return fmt.Errorf("zimigrate: unsupported time %T", src)
+	}
+	return nil
+}
+
+func (t *timestamp) parse(s string) error {
+	parsed, err := time.Parse("2006-01-02 15:04:05.999999", s)
+	if err != nil {
+		return err
+	}
+	*t = timestamp(parsed)
+	return nil
+}
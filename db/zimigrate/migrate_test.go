@@ -0,0 +1,329 @@
+package zimigrate
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// fakeDatabase is a database of the fake driver, recording the migration
+// statements, with an advisory lock and a history table.
+type fakeDatabase struct {
+	lock chan struct{}
+
+	mu         sync.Mutex
+	history    map[int64]time.Time
+	statements []string
+}
+
+var (
+	fakeDatabasesMu sync.Mutex
+	fakeDatabases   = map[string]*fakeDatabase{}
+)
+
+func init() {
+	sql.Register("zimigratetest", fakeDriver{})
+}
+
+// openFake opens a new fake database.
+func openFake(t *testing.T) (*sqlx.DB, *fakeDatabase) {
+	fake := &fakeDatabase{lock: make(chan struct{}, 1), history: map[int64]time.Time{}}
+	fakeDatabasesMu.Lock()
+	fakeDatabases[t.Name()] = fake
+	fakeDatabasesMu.Unlock()
+	db, err := sqlx.Open("zimigratetest", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, fake
+}
+
+func (f *fakeDatabase) executed() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.statements...)
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeDatabasesMu.Lock()
+	defer fakeDatabasesMu.Unlock()
+	return &fakeConn{db: fakeDatabases[name]}, nil
+}
+
+type fakeConn struct {
+	db *fakeDatabase
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	snapshot := map[int64]time.Time{}
+	for k, v := range c.db.history {
+		snapshot[k] = v
+	}
+	return &fakeTx{conn: c, snapshot: snapshot}, nil
+}
+
+func (c *fakeConn) acquire(ctx context.Context) error {
+	select {
+	case c.db.lock <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	switch {
+	case strings.HasPrefix(query, "SELECT pg_advisory_lock"):
+		return driver.ResultNoRows, c.acquire(ctx)
+	case strings.HasPrefix(query, "SELECT pg_advisory_unlock"), strings.HasPrefix(query, "SELECT RELEASE_LOCK"):
+		<-c.db.lock
+		return driver.ResultNoRows, nil
+	case strings.HasPrefix(query, "CREATE TABLE IF NOT EXISTS schema_migrations"):
+		return driver.ResultNoRows, nil
+	}
+
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	switch {
+	case strings.HasPrefix(query, "INSERT INTO schema_migrations"):
+		c.db.history[args[0].Value.(int64)] = args[2].Value.(time.Time)
+	case strings.HasPrefix(query, "DELETE FROM schema_migrations"):
+		delete(c.db.history, args[0].Value.(int64))
+	default:
+		c.db.statements = append(c.db.statements, query)
+		if strings.Contains(query, "FAIL") {
+			return nil, errors.New("syntax error")
+		}
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	switch {
+	case strings.HasPrefix(query, "SELECT GET_LOCK"):
+		lockCtx, cancel := context.WithTimeout(ctx, time.Duration(args[1].Value.(int64))*time.Second)
+		defer cancel()
+		locked := int64(1)
+		if err := c.acquire(lockCtx); err != nil {
+			locked = 0
+		}
+		return &fakeRows{columns: []string{"locked"}, values: [][]driver.Value{{locked}}}, nil
+	case strings.HasPrefix(query, "SELECT version, applied_at FROM schema_migrations"):
+		c.db.mu.Lock()
+		defer c.db.mu.Unlock()
+		rows := &fakeRows{columns: []string{"version", "applied_at"}}
+		for v, at := range c.db.history {
+			// Returned as text, as by MySQL without parseTime
+			rows.values = append(rows.values, []driver.Value{v, []byte(at.Format("2006-01-02 15:04:05.999999"))})
+		}
+		return rows, nil
+	}
+	return nil, errors.New("unexpected query " + query)
+}
+
+type fakeTx struct {
+	conn     *fakeConn
+	snapshot map[int64]time.Time
+}
+
+func (t *fakeTx) Commit() error { return nil }
+
+func (t *fakeTx) Rollback() error {
+	t.conn.db.mu.Lock()
+	defer t.conn.db.mu.Unlock()
+	t.conn.db.history = t.snapshot
+	return nil
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+var testMigrations = fstest.MapFS{
+	"0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id INT)")},
+	"0002_add_email.up.sql":      {Data: []byte("ALTER TABLE users ADD email TEXT")},
+	"0002_add_email.down.sql":    {Data: []byte("ALTER TABLE users DROP email")},
+	"0003_index_email.up.sql":    {Data: []byte("CREATE INDEX users_email ON users (email)")},
+	"0003_index_email.down.sql":  {Data: []byte("DROP INDEX users_email")},
+	"0001_create_users.down.sql": {Data: []byte("")},
+}
+
+func newTestMigrator(t *testing.T, db *sqlx.DB, dialect Dialect, fsys fstest.MapFS, opts ...Option) *Migrator {
+	t.Helper()
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(db, dialect, migrations, opts...)
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	db, fake := openFake(t)
+	m := newTestMigrator(t, db, Postgres, testMigrations)
+
+	if n, err := m.Up(ctx); err != nil || n != 3 {
+		t.Fatalf("Up() = %d, %v, want 3", n, err)
+	}
+	if n, err := m.Up(ctx); err != nil || n != 0 {
+		t.Fatalf("Up() again = %d, %v, want 0", n, err)
+	}
+	want := []string{"CREATE TABLE users (id INT)", "ALTER TABLE users ADD email TEXT", "CREATE INDEX users_email ON users (email)"}
+	if got := fake.executed(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("statements = %q, want %q", got, want)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 3 || !statuses[2].Applied || statuses[2].Name != "index_email" || statuses[2].AppliedAt.IsZero() {
+		t.Errorf("Status() = %+v", statuses)
+	}
+
+	if n, err := m.Down(ctx, 1); err != nil || n != 1 {
+		t.Fatalf("Down(1) = %d, %v, want 1", n, err)
+	}
+	// The first migration is irreversible
+	if n, err := m.Down(ctx, 5); !errors.Is(err, ErrIrreversible) || n != 1 {
+		t.Fatalf("Down(5) = %d, %v, want 1, ErrIrreversible", n, err)
+	}
+	if _, ok := fake.history[1]; !ok || len(fake.history) != 1 {
+		t.Errorf("history = %v, want [1]", fake.history)
+	}
+}
+
+func TestMigratorFailure(t *testing.T) {
+	ctx := context.Background()
+	db, fake := openFake(t)
+	m := newTestMigrator(t, db, Postgres, fstest.MapFS{
+		"0001_create_users.up.sql": {Data: []byte("CREATE TABLE users (id INT)")},
+		"0002_broken.up.sql":       {Data: []byte("FAIL")},
+		"0003_add_email.up.sql":    {Data: []byte("ALTER TABLE users ADD email TEXT")},
+	})
+
+	n, err := m.Up(ctx)
+	if err == nil || n != 1 || !strings.Contains(err.Error(), "2_broken") {
+		t.Fatalf("Up() = %d, %v, want 1 and the error of 2_broken", n, err)
+	}
+	if len(fake.history) != 1 || len(fake.executed()) != 2 {
+		t.Errorf("history = %v, statements = %q", fake.history, fake.executed())
+	}
+}
+
+func TestMigratorConcurrentRuns(t *testing.T) {
+	db, fake := openFake(t)
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		applied int
+	)
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := newTestMigrator(t, db, Postgres, testMigrations).Up(context.Background())
+			if err != nil {
+				t.Error(err)
+			}
+			mu.Lock()
+			applied += n
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if applied != 3 || len(fake.executed()) != 3 {
+		t.Errorf("applied %d migrations with %d statements, want 3", applied, len(fake.executed()))
+	}
+}
+
+func TestMigratorLockTimeout(t *testing.T) {
+	for _, dialect := range []Dialect{Postgres, MySQL} {
+		db, fake := openFake(t)
+		fake.lock <- struct{}{} // held by another replica
+		m := newTestMigrator(t, db, dialect, testMigrations, WithLockTimeout(10*time.Millisecond))
+		if _, err := m.Up(context.Background()); !errors.Is(err, ErrLockTimeout) {
+			t.Errorf("%s: Up() = %v, want ErrLockTimeout", dialect, err)
+		}
+	}
+}
+
+func TestMigratorMySQL(t *testing.T) {
+	db, fake := openFake(t)
+	m := newTestMigrator(t, db, MySQL, fstest.MapFS{
+		"0001_create_users.up.sql": {Data: []byte("CREATE TABLE users (id INT);\nCREATE INDEX users_id ON users (id);\n")},
+	}, WithLockTimeout(time.Second))
+	if n, err := m.Up(context.Background()); err != nil || n != 1 {
+		t.Fatalf("Up() = %d, %v, want 1", n, err)
+	}
+	want := []string{"CREATE TABLE users (id INT)", "CREATE INDEX users_id ON users (id)"}
+	if got := fake.executed(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("statements = %q, want %q", got, want)
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	db, _ := openFake(t)
+	m := newTestMigrator(t, db, Postgres, testMigrations)
+
+	var out bytes.Buffer
+	if err := Run(ctx, m, []string{"up"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if err := Run(ctx, m, []string{"down", "2"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if err := Run(ctx, m, []string{"status"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 || lines[0] != "applied 3 migrations" || lines[1] != "reverted 2 migrations" ||
+		!strings.HasPrefix(lines[2], "1\tcreate_users\tapplied ") || lines[3] != "2\tadd_email\tpending" {
+		t.Errorf("output = %q", lines)
+	}
+
+	for _, args := range [][]string{nil, {"sideways"}, {"down", "zero"}, {"up", "now"}} {
+		if err := Run(ctx, m, args, io.Discard); err == nil {
+			t.Errorf("Run(%q) succeeded", args)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package zimigrate
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migration is a versioned change of the schema of a database.
+type Migration struct {
+	Version int64
+	Name    string
+	// Up applies the migration, and Down reverts it, empty when the
+	// migration is irreversible.
+	Up   string
+	Down string
+}
+
+// Load loads the migrations of the root of fsys, e.g. an embed.FS, sorted by
+// version. A migration is a <version>_<name>.up.sql file applying it, with an
+// optional <version>_<name>.down.sql file reverting it:
+//
+//	migrations/
+//		0001_create_users.up.sql
+//		0001_create_users.down.sql
+//		0002_add_users_email.up.sql
+//
+// The other files are ignored.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("zimigrate: failed to read the migrations: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		file := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(file, ".sql") {
+			continue
+		}
+		version, name, direction, err := parseFileName(file)
+		if err != nil {
+			return nil, err
+		}
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("zimigrate: migrations %q and %q have the same version %d", m.Name, name, version)
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("zimigrate: migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// parseFileName parses the name of the file of a migration,
+// <version>_<name>.<up|down>.sql.
+func parseFileName(file string) (version int64, name, direction string, err error) {
+	base := strings.TrimSuffix(file, ".sql")
+	base, direction, ok := cut(base)
+	if !ok || (direction != "up" && direction != "down") {
+		return 0, "", "", fmt.Errorf("zimigrate: migration file %q is not <version>_<name>.up.sql or .down.sql", file)
+	}
+	v, name, _ := strings.Cut(base, "_")
+	if version, err = strconv.ParseInt(v, 10, 64); err != nil || version <= 0 {
+		return 0, "", "", fmt.Errorf("zimigrate: migration file %q has no positive version", file)
+	}
+	return version, name, direction, nil
+}
+
+// cut cuts s around its last dot.
+func cut(s string) (before, after string, found bool) {
+	if i := strings.LastIndexByte(s, '.'); i >= 0 {
+		return s[:i], s[i+1:], true
+	}
+	return s, "", false
+}
+
+// splitStatements splits script on the semicolons ending its statements,
+// outside of quotes and comments, for the databases running one statement at
+// a time.
+func splitStatements(script string) []string {
+	var (
+		statements []string
+		current    strings.Builder
+		quote      byte
+	)
+	flush := func() {
+		if s := strings.TrimSpace(current.String()); s != "" {
+			statements = append(statements, s)
+		}
+		current.Reset()
+	}
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote != '`' && i+1 < len(script) {
+				current.WriteByte(c)
+				i++
+				c = script[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '#' || (c == '-' && strings.HasPrefix(script[i:], "-- ")):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				end = len(script) - i
+			}
+			i += end - 1
+			continue
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				end = len(script) - i - 2
+			}
+			i += end + 3
+			continue
+		case c == ';':
+			flush()
+			continue
+		}
+		current.WriteByte(c)
+	}
+	flush()
+	return statements
+}
@@ -0,0 +1,62 @@
+package zimigrate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_add_email.up.sql":      {Data: []byte("ALTER TABLE users ADD email TEXT")},
+		"0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id INT)")},
+		"0001_create_users.down.sql": {Data: []byte("DROP TABLE users")},
+		"README.md":                  {Data: []byte("migrations")},
+		"seeds/users.sql":            {Data: []byte("INSERT INTO users VALUES (1)")},
+	}
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Migration{
+		{Version: 1, Name: "create_users", Up: "CREATE TABLE users (id INT)", Down: "DROP TABLE users"},
+		{Version: 2, Name: "add_email", Up: "ALTER TABLE users ADD email TEXT"},
+	}
+	if !reflect.DeepEqual(migrations, want) {
+		t.Errorf("Load() = %+v, want %+v", migrations, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for name, fsys := range map[string]fstest.MapFS{
+		"no version":        {"create_users.up.sql": {Data: []byte("SELECT 1")}},
+		"no direction":      {"0001_create_users.sql": {Data: []byte("SELECT 1")}},
+		"duplicate version": {"0001_a.up.sql": {Data: []byte("SELECT 1")}, "0001_b.up.sql": {Data: []byte("SELECT 1")}},
+		"no up file":        {"0001_create_users.down.sql": {Data: []byte("DROP TABLE users")}},
+	} {
+		if _, err := Load(fsys); err == nil {
+			t.Errorf("%s: Load() succeeded", name)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- users; with emails
+CREATE TABLE users (id INT, note TEXT DEFAULT 'a;b');
+/* index; */ CREATE INDEX users_note ON users (note);
+# trailing statement without semicolon
+INSERT INTO users VALUES (1, "it\"s;")`
+	want := []string{
+		"CREATE TABLE users (id INT, note TEXT DEFAULT 'a;b')",
+		"CREATE INDEX users_note ON users (note)",
+		`INSERT INTO users VALUES (1, "it\"s;")`,
+	}
+	got := splitStatements(script)
+	for i := range got {
+		got[i] = strings.TrimSpace(got[i])
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitStatements() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,96 @@
+package zimigratefx
+
+import (
+	"context"
+	"io/fs"
+
+	"github.com/divikraf/lumos/db/zimigrate"
+	"github.com/divikraf/lumos/db/zimysql"
+	"github.com/divikraf/lumos/db/zimysql/zimysqlfx"
+	"github.com/divikraf/lumos/db/zipg"
+	"github.com/divikraf/lumos/db/zipg/zipgfx"
+	"github.com/divikraf/lumos/ziconf"
+	"go.uber.org/fx"
+)
+
+// WithMigrations provides the migrations, e.g. an embed.FS, see
+// zimigrate.Load.
+func WithMigrations(fsys fs.FS) fx.Option {
+	return fx.Provide(fx.Annotate(
+		func() fs.FS { return fsys },
+		fx.ResultTags(`name:"zimigrate-migrations"`),
+	))
+}
+
+type postgresParams struct {
+	fx.In
+
+	Config     ziconf.Config
+	Connector  zipgfx.Connector
+	Input      zipg.Input `name:"zimigrate-postgres"`
+	Migrations fs.FS      `name:"zimigrate-migrations"`
+}
+
+// Postgres provides the *zimigrate.Migrator of the migrations on the
+// PostgreSQL database of the zipg.Input returned by input, a constructor
+// e.g. func(*AppConfig) zipg.Input, connected with the zipgfx connector.
+func Postgres(input any) fx.Option {
+	return fx.Options(
+		fx.Provide(fx.Annotate(input, fx.ResultTags(`name:"zimigrate-postgres"`))),
+		fx.Provide(func(params postgresParams) (*zimigrate.Migrator, error) {
+			migrations, err := zimigrate.Load(params.Migrations)
+			if err != nil {
+				return nil, err
+			}
+			db, err := params.Connector.Connect(context.Background(), params.Input)
+			if err != nil {
+				return nil, err
+			}
+			return zimigrate.New(db, zimigrate.Postgres, migrations, zimigrate.ConfigOf(params.Config).Options()...), nil
+		}),
+	)
+}
+
+type mysqlParams struct {
+	fx.In
+
+	Config     ziconf.Config
+	Connector  zimysqlfx.Connector
+	Input      zimysql.Input `name:"zimigrate-mysql"`
+	Migrations fs.FS         `name:"zimigrate-migrations"`
+}
+
+// MySQL provides the *zimigrate.Migrator of the migrations on the MySQL
+// database of the zimysql.Input returned by input, a constructor e.g.
+// func(*AppConfig) zimysql.Input, connected with the zimysqlfx connector.
+func MySQL(input any) fx.Option {
+	return fx.Options(
+		fx.Provide(fx.Annotate(input, fx.ResultTags(`name:"zimigrate-mysql"`))),
+		fx.Provide(func(params mysqlParams) (*zimigrate.Migrator, error) {
+			migrations, err := zimigrate.Load(params.Migrations)
+			if err != nil {
+				return nil, err
+			}
+			db, err := params.Connector.Connect(context.Background(), params.Input)
+			if err != nil {
+				return nil, err
+			}
+			return zimigrate.New(db, zimigrate.MySQL, migrations, zimigrate.ConfigOf(params.Config).Options()...), nil
+		}),
+	)
+}
+
+// Invoker applies the pending migrations on start, before the start hooks
+// of the components depending on the *zimigrate.Migrator, unless disabled
+// by the migration config.
+var Invoker = fx.Invoke(
+	func(lc fx.Lifecycle, config ziconf.Config, m *zimigrate.Migrator) {
+		if zimigrate.ConfigOf(config).Disabled {
+			return
+		}
+		lc.Append(fx.StartHook(func(ctx context.Context) error {
+			_, err := m.Up(ctx)
+			return err
+		}))
+	},
+)
@@ -0,0 +1,283 @@
+// Package zimongo provides a MongoDB connector and helpers independent of the
+// driver: the driver types are adapted to the small interfaces of the
+// package, e.g. Client for a *mongo.Client and Stream for a
+// *mongo.ChangeStream.
+package zimongo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/divikraf/lumos/internal/wait"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// OperationInvalidate is the operation type of the event closing a change
+// stream, e.g. when its collection is dropped or renamed.
+const OperationInvalidate = "invalidate"
+
+var errStreamClosed = errors.New("zimongo: change stream closed")
+
+// ChangeEvent is an event of a change stream.
+type ChangeEvent struct {
+	// ResumeToken is the _id of the event.
+	ResumeToken   []byte
+	OperationType string
+	Database      string
+	Collection    string
+	// DocumentKey and FullDocument are the raw BSON of the document key and
+	// of the document, if requested.
+	DocumentKey  []byte
+	FullDocument []byte
+	ClusterTime  time.Time
+}
+
+// Stream is an open change stream, e.g. an adapted *mongo.ChangeStream.
+type Stream interface {
+	// Next blocks until the next event, returning false on error or when
+	// ctx is done.
+	Next(ctx context.Context) bool
+	// TryNext returns the next event if available, without blocking.
+	TryNext(ctx context.Context) bool
+	// Event returns the current event.
+	Event() (ChangeEvent, error)
+	Err() error
+	Close(ctx context.Context) error
+}
+
+// ResumeOptions are the options resuming a change stream.
+type ResumeOptions struct {
+	// ResumeAfter resumes after the event of the token.
+	ResumeAfter []byte
+	// StartAfter starts after the event of the token, which may be an
+	// invalidate event, unlike ResumeAfter.
+	StartAfter []byte
+}
+
+// WatchFunc opens a change stream, e.g. calling Collection.Watch with the
+// options and the pipeline of the subscriber.
+type WatchFunc func(ctx context.Context, opts ResumeOptions) (Stream, error)
+
+// BatchHandler handles a batch of events, in order. The resume token of the
+// batch is saved once it returns nil, a failing batch being retried.
+type BatchHandler func(ctx context.Context, events []ChangeEvent) error
+
+// SubscriberOption configures a Subscriber.
+type SubscriberOption func(*Subscriber)
+
+// WithBatchSize sets the maximum number of events of a batch (default: 100).
+// Smaller batches are dispatched as soon as the stream is caught up.
+func WithBatchSize(n int) SubscriberOption {
+	return func(s *Subscriber) {
+		s.batchSize = n
+	}
+}
+
+// WithBackoff sets the initial and maximum delays before retrying a failed
+// batch or reopening a failed stream (default: 100ms and 30s).
+func WithBackoff(initial, maximum time.Duration) SubscriberOption {
+	return func(s *Subscriber) {
+		s.initialBackoff, s.maxBackoff = initial, maximum
+	}
+}
+
+// Subscriber consumes a change stream in batches, saving the resume token of
+// every handled batch so that it resumes where it stopped after a restart.
+// Failed streams are reopened after a backoff, and invalidated streams are
+// restarted after the invalidate event.
+//
+// Events are counted in mongo_change_events_total by operation type, batches
+// are traced and timed in mongo_change_batch_duration_ms, and restarts are
+// counted in mongo_change_stream_restarts_total by reason.
+type Subscriber struct {
+	name   string
+	watch  WatchFunc
+	tokens TokenStore
+	handle BatchHandler
+	logger *zerolog.Logger
+
+	batchSize      int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+
+	events   metric.Int64Counter
+	restarts metric.Int64Counter
+	duration revelio.DurationRecorder
+	attrs    attribute.KeyValue
+}
+
+// NewSubscriber returns a Subscriber named name, consuming the streams of
+// watch with handle, and saving its resume token in tokens under its name.
+func NewSubscriber(name string, watch WatchFunc, tokens TokenStore, handle BatchHandler, logger *zerolog.Logger, opts ...SubscriberOption) *Subscriber {
+	s := &Subscriber{
+		name:           name,
+		watch:          watch,
+		tokens:         tokens,
+		handle:         handle,
+		logger:         logger,
+		batchSize:      100,
+		initialBackoff: 100 * time.Millisecond,
+		maxBackoff:     30 * time.Second,
+		events: revelio.MustInt64Counter(
+			"mongo_change_events_total",
+			"Number of change stream events handled, by subscriber and operation type",
+		),
+		restarts: revelio.MustInt64Counter(
+			"mongo_change_stream_restarts_total",
+			"Number of change stream restarts, by subscriber and reason",
+		),
+		duration: revelio.MustDuration("mongo_change_batch_duration_ms", "Duration of change stream batches in milliseconds"),
+		attrs:    attribute.String("subscriber", name),
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	if s.batchSize <= 0 {
+		s.batchSize = 1
+	}
+	return s
+}
+
+// Run consumes the change stream until ctx is done, resuming after the
+// saved token, if any.
+func (s *Subscriber) Run(ctx context.Context) error {
+	token, err := s.tokens.Load(ctx, s.name)
+	if err != nil {
+		return err
+	}
+	opts := ResumeOptions{ResumeAfter: token}
+	backoff := s.initialBackoff
+	for {
+		next, err := s.consume(ctx, opts)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		reason := "invalidate"
+		if err != nil {
+			reason = "error"
+			s.logger.Error().Err(err).Str("subscriber", s.name).Dur("backoff", backoff).Msg("change stream failed")
+			if !wait.Sleep(ctx, backoff) {
+				return ctx.Err()
+			}
+			backoff = min(2*backoff, s.maxBackoff)
+		} else {
+			s.logger.Warn().Str("subscriber", s.name).Msg("change stream invalidated, restarting")
+			backoff = s.initialBackoff
+		}
+		s.restarts.Add(ctx, 1, metric.WithAttributes(s.attrs, attribute.String("reason", reason)))
+		opts = next
+	}
+}
+
+// consume consumes a stream opened with opts until it is invalidated or
+// fails, returning the options to reopen it with.
+func (s *Subscriber) consume(ctx context.Context, opts ResumeOptions) (ResumeOptions, error) {
+	stream, err := s.watch(ctx, opts)
+	if err != nil {
+		return opts, err
+	}
+	defer stream.Close(context.WithoutCancel(ctx))
+
+	batch := make([]ChangeEvent, 0, s.batchSize)
+	for {
+		// Events are batched while available, and dispatched once the
+		// batch is full or the stream is caught up.
+		blocking := len(batch) == 0
+		var ok bool
+		if blocking {
+			ok = stream.Next(ctx)
+		} else {
+			ok = stream.TryNext(ctx)
+		}
+		if !ok {
+			if len(batch) > 0 {
+				if err := s.dispatch(ctx, batch); err != nil {
+					return opts, err
+				}
+				opts = ResumeOptions{ResumeAfter: batch[len(batch)-1].ResumeToken}
+				batch = batch[:0]
+			}
+			if err := stream.Err(); err != nil {
+				return opts, err
+			}
+			if ctx.Err() != nil {
+				return opts, ctx.Err()
+			}
+			if blocking {
+				return opts, errStreamClosed
+			}
+			continue
+		}
+
+		event, err := stream.Event()
+		if err != nil {
+			return opts, err
+		}
+		if event.OperationType == OperationInvalidate {
+			if err := s.dispatch(ctx, batch); err != nil {
+				return opts, err
+			}
+			// The token of the invalidate event isn't saved, as it can't be
+			// resumed after: a restarted subscriber replays the event.
+			return ResumeOptions{StartAfter: event.ResumeToken}, nil
+		}
+		batch = append(batch, event)
+		if len(batch) == s.batchSize {
+			if err := s.dispatch(ctx, batch); err != nil {
+				return opts, err
+			}
+			opts = ResumeOptions{ResumeAfter: event.ResumeToken}
+			batch = batch[:0]
+		}
+	}
+}
+
+// dispatch handles batch, retrying with backoff until it succeeds or ctx is
+// done, and saves its resume token.
+func (s *Subscriber) dispatch(ctx context.Context, batch []ChangeEvent) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	backoff := s.initialBackoff
+	for {
+		err := s.handleBatch(ctx, batch)
+		if err == nil {
+			break
+		}
+		s.logger.Error().Err(err).Str("subscriber", s.name).Int("events", len(batch)).Dur("backoff", backoff).Msg("change stream batch failed")
+		if !wait.Sleep(ctx, backoff) {
+			return ctx.Err()
+		}
+		backoff = min(2*backoff, s.maxBackoff)
+	}
+
+	for _, e := range batch {
+		s.events.Add(ctx, 1, metric.WithAttributes(s.attrs, attribute.String("operation", e.OperationType)))
+	}
+	// The token is saved even if ctx is done, the batch being handled.
+	return s.tokens.Save(context.WithoutCancel(ctx), s.name, batch[len(batch)-1].ResumeToken)
+}
+
+func (s *Subscriber) handleBatch(ctx context.Context, batch []ChangeEvent) (err error) {
+	ctx, span := observe.FromContext(ctx).Start(ctx, "mongo.change_stream.batch")
+	defer span.End()
+	span.SetAttributes(s.attrs, attribute.Int("batch.size", len(batch)))
+
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			observe.RecordPanic(span, r)
+			err = errors.New("zimongo: batch handler panicked")
+		}
+		s.duration.Record(ctx, time.Since(start), s.attrs)
+		if err != nil {
+			observe.RecordError(span, err)
+		}
+	}()
+	return s.handle(ctx, batch)
+}
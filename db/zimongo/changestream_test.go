@@ -0,0 +1,111 @@
+package zimongo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+type fakeStream struct {
+	events []ChangeEvent
+	next   int
+}
+
+func (s *fakeStream) TryNext(ctx context.Context) bool {
+	if s.next >= len(s.events) {
+		return false
+	}
+	s.next++
+	return true
+}
+
+func (s *fakeStream) Next(ctx context.Context) bool {
+	if s.TryNext(ctx) {
+		return true
+	}
+	<-ctx.Done()
+	return false
+}
+
+func (s *fakeStream) Event() (ChangeEvent, error)     { return s.events[s.next-1], nil }
+func (s *fakeStream) Err() error                      { return nil }
+func (s *fakeStream) Close(ctx context.Context) error { return nil }
+
+type memoryTokens struct {
+	mu     sync.Mutex
+	tokens map[string][]byte
+}
+
+func (m *memoryTokens) Load(ctx context.Context, name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.tokens[name], nil
+}
+
+func (m *memoryTokens) Save(ctx context.Context, name string, token []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[name] = token
+	return nil
+}
+
+func event(token, op string) ChangeEvent {
+	return ChangeEvent{ResumeToken: []byte(token), OperationType: op}
+}
+
+func TestSubscriber(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tokens := &memoryTokens{tokens: map[string][]byte{"sync": []byte("t0")}}
+	streams := []*fakeStream{
+		{events: []ChangeEvent{event("t1", "insert"), event("t2", "update"), event("t3", "delete"), event("t4", OperationInvalidate)}},
+		{events: []ChangeEvent{event("t5", "insert")}},
+	}
+	var opened []ResumeOptions
+	watch := func(ctx context.Context, opts ResumeOptions) (Stream, error) {
+		opened = append(opened, opts)
+		return streams[len(opened)-1], nil
+	}
+
+	var batches [][]string
+	failed := false
+	handle := func(ctx context.Context, events []ChangeEvent) error {
+		if !failed {
+			failed = true
+			return errors.New("boom")
+		}
+		var batch []string
+		for _, e := range events {
+			batch = append(batch, string(e.ResumeToken))
+		}
+		batches = append(batches, batch)
+		if batch[len(batch)-1] == "t5" {
+			cancel()
+		}
+		return nil
+	}
+
+	logger := zerolog.Nop()
+	s := NewSubscriber("sync", watch, tokens, handle, &logger,
+		WithBatchSize(2), WithBackoff(time.Millisecond, time.Millisecond))
+	if err := s.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run = %v, want context.Canceled", err)
+	}
+
+	if want := [][]string{{"t1", "t2"}, {"t3"}, {"t5"}}; !reflect.DeepEqual(batches, want) {
+		t.Errorf("Batches = %v, want %v", batches, want)
+	}
+	want := []ResumeOptions{{ResumeAfter: []byte("t0")}, {StartAfter: []byte("t4")}}
+	if !reflect.DeepEqual(opened, want) {
+		t.Errorf("Opened = %v, want %v", opened, want)
+	}
+	if token, _ := tokens.Load(context.Background(), "sync"); string(token) != "t5" {
+		t.Errorf("Token = %q, want t5", token)
+	}
+}
@@ -0,0 +1,213 @@
+package zimongo
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/go-playground/validator/v10"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Client is a connected MongoDB client, e.g. an adapted *mongo.Client.
+type Client interface {
+	// Ping checks the connection to the primary.
+	Ping(ctx context.Context) error
+	Disconnect(ctx context.Context) error
+}
+
+// Dialer connects a Client to the connection string uri, reporting its
+// commands to monitor. DriverDialer is the Dialer of the official driver,
+// tracing the commands with otelmongo; other Dialers, e.g. fakes in tests,
+// adapt their client to Client.
+type Dialer func(ctx context.Context, uri string, monitor *CommandMonitor) (Client, error)
+
+// New returns connection creator, connecting the clients with dial.
+func New(validator *validator.Validate, logger *zerolog.Logger, dial Dialer) *mongoConnector {
+	return &mongoConnector{
+		validator: validator,
+		logger:    logger,
+		dial:      dial,
+		monitor:   NewCommandMonitor(),
+		conns:     &sync.Map{},
+	}
+}
+
+type HostPort struct {
+	Host string
+	Port string `validate:"required"`
+}
+
+func (hp HostPort) String() string {
+	return hp.Host + ":" + hp.Port
+}
+
+type PoolConfig struct {
+	MaxPoolSize uint `validate:"required"`
+	MinPoolSize uint
+	// MaxConnecting is the number of connections established concurrently,
+	// the driver default when zero.
+	MaxConnecting   uint
+	MaxConnIdleTime time.Duration
+}
+
+type Input struct {
+	// Hosts are the members of the replica set, or the mongos routers.
+	Hosts        []HostPort `validate:"required,min=1,dive"`
+	Username     string
+	Password     string
+	AuthSource   string
+	DatabaseName string `validate:"required"`
+	ReplicaSet   string
+	// AppName is reported in the server logs and currentOp, usually the
+	// service name.
+	AppName        string
+	ConnectTimeout time.Duration
+	PoolConfig     PoolConfig
+	QueryParams    url.Values
+}
+
+// hosts returns the comma separated hosts of input.
+func (input Input) hosts() string {
+	hosts := make([]string, len(input.Hosts))
+	for i, hp := range input.Hosts {
+		hosts[i] = hp.String()
+	}
+	return strings.Join(hosts, ",")
+}
+
+// URI returns the connection string of input.
+func (input Input) URI() string {
+	q := url.Values{}
+	for key, values := range input.QueryParams {
+		q[key] = append([]string(nil), values...)
+	}
+	set := func(key, value string) {
+		if value != "" && value != "0" {
+			q.Set(key, value)
+		}
+	}
+	set("authSource", input.AuthSource)
+	set("replicaSet", input.ReplicaSet)
+	set("appName", input.AppName)
+	set("connectTimeoutMS", strconv.FormatInt(input.ConnectTimeout.Milliseconds(), 10))
+	set("maxPoolSize", strconv.FormatUint(uint64(input.PoolConfig.MaxPoolSize), 10))
+	set("minPoolSize", strconv.FormatUint(uint64(input.PoolConfig.MinPoolSize), 10))
+	set("maxConnecting", strconv.FormatUint(uint64(input.PoolConfig.MaxConnecting), 10))
+	set("maxIdleTimeMS", strconv.FormatInt(input.PoolConfig.MaxConnIdleTime.Milliseconds(), 10))
+
+	u := url.URL{
+		Scheme:   "mongodb",
+		Host:     input.hosts(),
+		Path:     "/" + input.DatabaseName,
+		RawQuery: q.Encode(),
+	}
+	if input.Username != "" {
+		u.User = url.UserPassword(input.Username, input.Password)
+	}
+	return u.String()
+}
+
+type mongoConnector struct {
+	validator *validator.Validate
+	logger    *zerolog.Logger
+	dial      Dialer
+	monitor   *CommandMonitor
+	conns     *sync.Map
+}
+
+func (mc *mongoConnector) PingAll(ctx context.Context) error {
+	var returnErr error
+	mc.conns.Range(func(_, conn any) bool {
+		if err := conn.(Client).Ping(ctx); err != nil {
+			mc.logger.Error().Err(err).
+				Msg("failed to ping MongoDB")
+			returnErr = err
+			return false
+		}
+		return true
+	})
+	return returnErr
+}
+
+func (mc *mongoConnector) CloseAll(ctx context.Context) error {
+	var returnErr error
+	mc.conns.Range(func(addr, conn any) bool {
+		if err := conn.(Client).Disconnect(ctx); err != nil {
+			mc.logger.Error().Err(err).
+				Msgf("failed to disconnect MongoDB: %s", addr)
+			returnErr = err
+			return false
+		}
+		return true
+	})
+	return returnErr
+}
+
+func (mc *mongoConnector) MustConnect(ctx context.Context, input Input) Client {
+	client, err := mc.Connect(ctx, input)
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
+
+func (mc *mongoConnector) Connect(ctx context.Context, input Input) (Client, error) {
+	errValidate := mc.validator.StructCtx(ctx, input)
+	if errValidate != nil {
+		mc.logger.Error().Err(errValidate).Msg(errValidate.Error())
+		return nil, errValidate
+	}
+
+	logger := mc.logger.With().
+		Str("hosts", input.hosts()).
+		Str("dbname", input.DatabaseName).
+		Str("replicaset", input.ReplicaSet).
+		Logger()
+
+	client, err := mc.dial(ctx, input.URI(), mc.monitor)
+	if err != nil {
+		logger.Error().Err(err).Msg(err.Error())
+		return nil, err
+	}
+
+	mc.conns.Store(input.hosts()+"/"+input.DatabaseName, client)
+	return client, nil
+}
+
+// CommandEvent is a finished command, e.g. adapted from the
+// CommandSucceededEvent or CommandFailedEvent of the driver.
+type CommandEvent struct {
+	CommandName  string
+	DatabaseName string
+	Duration     time.Duration
+	// Failure is the error of a failed command.
+	Failure error
+}
+
+// CommandMonitor records the duration of the commands in the
+// mongo_command_duration_ms histogram, by command, database and status.
+type CommandMonitor struct {
+	duration revelio.DurationRecorder
+}
+
+// NewCommandMonitor returns a CommandMonitor.
+func NewCommandMonitor() *CommandMonitor {
+	return &CommandMonitor{
+		duration: revelio.MustDuration("mongo_command_duration_ms", "Duration of MongoDB commands in milliseconds"),
+	}
+}
+
+// Finished records a finished command.
+func (m *CommandMonitor) Finished(ctx context.Context, e CommandEvent) {
+	attrs := append([]attribute.KeyValue{
+		attribute.String("db.operation.name", e.CommandName),
+		attribute.String("db.namespace", e.DatabaseName),
+	}, revelio.ResultAttributes(e.Failure)...)
+	m.duration.Record(ctx, e.Duration, attrs...)
+}
@@ -0,0 +1,70 @@
+package zimongo
+
+import (
+	"context"
+	"errors"
+
+	mongoevent "go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+)
+
+// DriverClient is a Client of the official driver. Connections made by
+// DriverDialer are DriverClients, e.g.
+//
+//	client, _ := connector.Connect(ctx, input)
+//	orders := client.(zimongo.DriverClient).Database("orders").Collection("orders")
+type DriverClient struct {
+	*mongo.Client
+}
+
+// Ping checks the connection to the primary.
+func (c DriverClient) Ping(ctx context.Context) error {
+	return c.Client.Ping(ctx, readpref.Primary())
+}
+
+// DriverDialer returns the Dialer of the official driver, the default of
+// the zimongofx module. The commands are traced by the otelmongo monitor and
+// recorded by the CommandMonitor of the connector, see NewDriverMonitor.
+// opts are applied after the connection string, and must not set a monitor.
+func DriverDialer(opts ...*options.ClientOptions) Dialer {
+	return func(ctx context.Context, uri string, monitor *CommandMonitor) (Client, error) {
+		clientOpts := append([]*options.ClientOptions{
+			options.Client().ApplyURI(uri).SetMonitor(NewDriverMonitor(monitor)),
+		}, opts...)
+		client, err := mongo.Connect(ctx, clientOpts...)
+		if err != nil {
+			return nil, err
+		}
+		return DriverClient{Client: client}, nil
+	}
+}
+
+// NewDriverMonitor returns the command monitor of the driver tracing the
+// commands with otelmongo, configured by opts, and recording them with
+// monitor.
+func NewDriverMonitor(monitor *CommandMonitor, opts ...otelmongo.Option) *mongoevent.CommandMonitor {
+	tracing := otelmongo.NewMonitor(opts...)
+	return &mongoevent.CommandMonitor{
+		Started: tracing.Started,
+		Succeeded: func(ctx context.Context, e *mongoevent.CommandSucceededEvent) {
+			tracing.Succeeded(ctx, e)
+			monitor.Finished(ctx, CommandEvent{
+				CommandName:  e.CommandName,
+				DatabaseName: e.DatabaseName,
+				Duration:     e.Duration,
+			})
+		},
+		Failed: func(ctx context.Context, e *mongoevent.CommandFailedEvent) {
+			tracing.Failed(ctx, e)
+			monitor.Finished(ctx, CommandEvent{
+				CommandName:  e.CommandName,
+				DatabaseName: e.DatabaseName,
+				Duration:     e.Duration,
+				Failure:      errors.New(e.Failure),
+			})
+		},
+	}
+}
@@ -0,0 +1,64 @@
+package zimongo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"go.mongodb.org/mongo-driver/bson"
+	mongoevent "go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestDriverMonitor(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	recorder := tracetest.NewSpanRecorder()
+	m := NewDriverMonitor(NewCommandMonitor(),
+		otelmongo.WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))))
+
+	ctx := context.Background()
+	command, _ := bson.Marshal(bson.D{{Key: "find", Value: "orders"}})
+	finished := mongoevent.CommandFinishedEvent{CommandName: "find", DatabaseName: "shop", RequestID: 1, ConnectionID: "mongo-0", Duration: 3 * time.Millisecond}
+	m.Started(ctx, &mongoevent.CommandStartedEvent{Command: command, CommandName: "find", DatabaseName: "shop", RequestID: 1, ConnectionID: "mongo-0"})
+	m.Succeeded(ctx, &mongoevent.CommandSucceededEvent{CommandFinishedEvent: finished})
+	finished.RequestID = 2
+	m.Started(ctx, &mongoevent.CommandStartedEvent{Command: command, CommandName: "find", DatabaseName: "shop", RequestID: 2, ConnectionID: "mongo-0"})
+	m.Failed(ctx, &mongoevent.CommandFailedEvent{CommandFinishedEvent: finished, Failure: "timeout"})
+
+	spans := recorder.Ended()
+	if len(spans) != 2 || spans[0].Name() != "orders.find" {
+		t.Fatalf("spans = %v, want 2 orders.find spans", spans)
+	}
+	if spans[1].Status().Code != codes.Error {
+		t.Errorf("failed command span status = %v, want Error", spans[1].Status())
+	}
+	for status, want := range map[string]uint64{"success": 1, "failure": 1} {
+		h := reveliotest.CollectHistogram(t, s, "mongo_command_duration_ms",
+			attribute.String("db.operation.name", "find"),
+			attribute.String("db.namespace", "shop"),
+			attribute.String("status", status),
+		)
+		if h.Count != want {
+			t.Errorf("%s commands count = %d, want %d", status, h.Count, want)
+		}
+	}
+}
+
+func TestDriverDialer(t *testing.T) {
+	client, err := DriverDialer()(context.Background(), "mongodb://localhost:27017/orders", NewCommandMonitor())
+	if err != nil {
+		t.Fatalf("dial = %v", err)
+	}
+	c, ok := client.(DriverClient)
+	if !ok {
+		t.Fatalf("client = %T, want a DriverClient", client)
+	}
+	if err := c.Disconnect(context.Background()); err != nil {
+		t.Errorf("Disconnect() = %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package zimongo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/divikraf/lumos/db/zisqlx"
+	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultTokensTable is the default table of SQLTokenStore.
+const DefaultTokensTable = "change_stream_tokens"
+
+// TokenStore persists the resume tokens of subscribers, by name.
+type TokenStore interface {
+	// Load returns the token of name, nil when none is saved.
+	Load(ctx context.Context, name string) ([]byte, error)
+	Save(ctx context.Context, name string, token []byte) error
+}
+
+// RedisTokenStore saves the resume tokens in Redis, under a key prefix.
+type RedisTokenStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisTokenStore returns a RedisTokenStore of client, saving the token of
+// name under prefix+name.
+func NewRedisTokenStore(client redis.UniversalClient, prefix string) *RedisTokenStore {
+	return &RedisTokenStore{client: client, prefix: prefix}
+}
+
+func (s *RedisTokenStore) Load(ctx context.Context, name string) ([]byte, error) {
+	token, err := s.client.Get(ctx, s.prefix+name).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return token, err
+}
+
+func (s *RedisTokenStore) Save(ctx context.Context, name string, token []byte) error {
+	return s.client.Set(ctx, s.prefix+name, token, 0).Err()
+}
+
+// SQLTokenStore saves the resume tokens in a table expected to be:
+//
+//	CREATE TABLE change_stream_tokens (
+//		name         VARCHAR(255) PRIMARY KEY,
+//		resume_token BLOB NOT NULL,
+//		updated_at   TIMESTAMP NOT NULL
+//	);
+type SQLTokenStore struct {
+	db       zisqlx.BasicQueryerExecuter
+	bindType int
+	table    string
+}
+
+// SQLTokenStoreOption configures a SQLTokenStore.
+type SQLTokenStoreOption func(*SQLTokenStore)
+
+// WithTokensTable sets the table of the tokens (default: DefaultTokensTable).
+func WithTokensTable(table string) SQLTokenStoreOption {
+	return func(s *SQLTokenStore) {
+		s.table = table
+	}
+}
+
+// NewSQLTokenStore returns a SQLTokenStore saving the tokens in db. bindType
+// is the placeholder style of the database, e.g. sqlx.DOLLAR for PostgreSQL
+// and sqlx.QUESTION for MySQL.
+func NewSQLTokenStore(db zisqlx.BasicQueryerExecuter, bindType int, opts ...SQLTokenStoreOption) *SQLTokenStore {
+	s := &SQLTokenStore{db: db, bindType: bindType, table: DefaultTokensTable}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+func (s *SQLTokenStore) query(q string) string {
+	return sqlx.Rebind(s.bindType, fmt.Sprintf(q, s.table))
+}
+
+func (s *SQLTokenStore) Load(ctx context.Context, name string) ([]byte, error) {
+	var token []byte
+	err := s.db.GetContext(ctx, "zimongo.load_resume_token", &token,
+		s.query("SELECT resume_token FROM %s WHERE name = ?"), name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return token, err
+}
+
+// Save updates the token of name, inserting it when missing, without relying
+// on a dialect-specific upsert.
+func (s *SQLTokenStore) Save(ctx context.Context, name string, token []byte) error {
+	now := time.Now().UTC()
+	res, err := s.db.ExecContext(ctx, "zimongo.save_resume_token",
+		s.query("UPDATE %s SET resume_token = ?, updated_at = ? WHERE name = ?"), token, now, name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, "zimongo.save_resume_token",
+		s.query("INSERT INTO %s (name, resume_token, updated_at) VALUES (?, ?, ?)"), name, token, now)
+	return err
+}
@@ -0,0 +1,54 @@
+package zimongofx
+
+import (
+	"context"
+
+	"github.com/divikraf/lumos/db/zimongo"
+	"github.com/divikraf/lumos/zihealth"
+	"github.com/go-playground/validator/v10"
+	"github.com/rs/zerolog"
+	"go.uber.org/fx"
+)
+
+type Connector interface {
+	// Connect attempts to connect to a MongoDB database, err is returned if
+	// failed to do so.
+	Connect(ctx context.Context, input zimongo.Input) (zimongo.Client, error)
+	// MustConnect attempts to connect to a MongoDB database, then panics if
+	// failed to do so.
+	MustConnect(ctx context.Context, input zimongo.Input) zimongo.Client
+}
+
+type connParams struct {
+	fx.In
+
+	LC        fx.Lifecycle
+	Validator *validator.Validate
+	Logger    *zerolog.Logger
+	Dialer    zimongo.Dialer `optional:"true"`
+}
+
+type fxResult struct {
+	fx.Out
+
+	Connector Connector
+	Checker   zihealth.Checker `group:"health.checker"`
+}
+
+// Provider provides the Connector, connecting the clients with the
+// zimongo.Dialer provided, zimongo.DriverDialer by default.
+var Provider = fx.Provide(
+	func(params connParams) fxResult {
+		dial := params.Dialer
+		if dial == nil {
+			dial = zimongo.DriverDialer()
+		}
+		conn := zimongo.New(params.Validator, params.Logger, dial)
+		params.LC.Append(fx.StartHook(conn.PingAll))
+		params.LC.Append(fx.StopHook(conn.CloseAll))
+		return fxResult{
+			Connector: conn,
+			Checker:   zihealth.NewChecker("mongo", conn.PingAll),
+		}
+	},
+)
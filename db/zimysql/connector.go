@@ -2,24 +2,49 @@ package zimysql
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"net/url"
 	"sync"
 	"time"
 
+	"github.com/divikraf/lumos/db/zisqlx"
 	"github.com/go-playground/validator/v10"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	"github.com/rs/zerolog"
 )
 
 // New returns connection creator.
-func New(validator *validator.Validate, logger *zerolog.Logger) *mysqlConnector {
-	return &mysqlConnector{
+func New(validator *validator.Validate, logger *zerolog.Logger, opts ...Option) *mysqlConnector {
+	myc := &mysqlConnector{
 		validator: validator,
 		logger:    logger,
 		conns:     &sync.Map{},
 	}
+	for _, o := range opts {
+		o(myc)
+	}
+	return myc
+}
+
+// Option configures the connection creator.
+type Option func(*mysqlConnector)
+
+// WithSQLComments appends sqlcommenter comments with the trace context,
+// service and route to the statements of the connections, see
+// zisqlx.WithSQLComments.
+func WithSQLComments(service string) Option {
+	return func(myc *mysqlConnector) {
+		myc.comments = &service
+	}
+}
+
+// Defaults are settings applied to every connection.
+type Defaults struct {
+	// SQLComments appends sqlcommenter comments to the statements, tagged
+	// with the service name, see WithSQLComments.
+	SQLComments bool `json:"sql_comments"`
 }
 
 type HostPort struct {
@@ -45,12 +70,20 @@ type Input struct {
 	DatabaseName string           `validate:"required"`
 	ConnConfig   ConnectionConfig `validate:"required"`
 	QueryParams  url.Values
+	// ReadOnly opens every session in read-only mode, so the server rejects
+	// writes. Point HostPort to a replica to keep the load off the primary,
+	// and wrap the connection with zisqlx.ReadOnly to reject Exec operations
+	// before they reach the server.
+	ReadOnly bool
 }
 
 type mysqlConnector struct {
 	validator *validator.Validate
 	logger    *zerolog.Logger
 	conns     *sync.Map
+	// comments is the service of the sqlcommenter comments, nil disabling
+	// them
+	comments *string
 }
 
 func (myc *mysqlConnector) PingAll(ctx context.Context) error {
@@ -104,15 +137,21 @@ func (myc *mysqlConnector) Connect(ctx context.Context, input Input) (*sqlx.DB,
 		}
 	}
 
+	if input.ReadOnly {
+		// Unknown DSN parameters are set as session system variables
+		queryParams.Set("transaction_read_only", "1")
+	}
+
 	dsn := fmt.Sprintf("%s:%s@tcp(%s)/%s?%s", input.Username, input.Password, input.HostPort.String(), input.DatabaseName, queryParams.Encode())
 
 	logger := myc.logger.With().
 		Str("hostport", input.HostPort.String()).
 		Str("dbname", input.DatabaseName).
 		Interface("queryparams", queryParams).
+		Bool("readonly", input.ReadOnly).
 		Logger()
 
-	sqldb, err := sqlx.Open("mysql", dsn)
+	sqldb, err := myc.open(dsn)
 	if err != nil {
 		logger.Error().Err(err).Msg(err.Error())
 		return nil, err
@@ -123,6 +162,27 @@ func (myc *mysqlConnector) Connect(ctx context.Context, input Input) (*sqlx.DB,
 	sqldb.DB.SetConnMaxLifetime(input.ConnConfig.ConnMaxLifetime)
 	sqldb.DB.SetConnMaxIdleTime(input.ConnConfig.ConnMaxIdleTime)
 
-	myc.conns.Store(input.HostPort.String(), sqldb)
+	key := input.HostPort.String()
+	if input.ReadOnly {
+		// Don't replace a read-write connection to the same server
+		key += "?readonly"
+	}
+	myc.conns.Store(key, sqldb)
 	return sqldb, nil
 }
+
+// open returns the database of dsn, its statements commented when enabled.
+func (myc *mysqlConnector) open(dsn string) (*sqlx.DB, error) {
+	if myc.comments == nil {
+		return sqlx.Open("mysql", dsn)
+	}
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return sqlx.NewDb(sql.OpenDB(zisqlx.CommentConnector(connector, *myc.comments)), "mysql"), nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 
 	"github.com/divikraf/lumos/db/zimysql"
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zihealth"
 	"github.com/go-playground/validator/v10"
 	"github.com/jmoiron/sqlx"
 	"github.com/rs/zerolog"
@@ -25,13 +27,39 @@ type connParams struct {
 	LC        fx.Lifecycle
 	Validator *validator.Validate
 	Logger    *zerolog.Logger
+	Config    ziconf.Config `optional:"true"`
+}
+
+// mysqlConfig is implemented by configs setting the defaults of MySQL
+// connections.
+type mysqlConfig interface {
+	GetMySQL() zimysql.Defaults
+}
+
+// options returns the connector options of config.
+func options(config ziconf.Config) []zimysql.Option {
+	c, ok := config.(mysqlConfig)
+	if !ok || !c.GetMySQL().SQLComments {
+		return nil
+	}
+	return []zimysql.Option{zimysql.WithSQLComments(config.GetService().Name)}
+}
+
+type fxResult struct {
+	fx.Out
+
+	Connector Connector
+	Checker   zihealth.Checker `group:"health.checker"`
 }
 
 var Provider = fx.Provide(
-	fx.Annotate(func(params connParams) Connector {
-		conn := zimysql.New(params.Validator, params.Logger)
+	func(params connParams) fxResult {
+		conn := zimysql.New(params.Validator, params.Logger, options(params.Config)...)
 		params.LC.Append(fx.StartHook(conn.PingAll))
 		params.LC.Append(fx.StopHook(conn.CloseAll))
-		return conn
-	}, fx.As(new(Connector))),
+		return fxResult{
+			Connector: conn,
+			Checker:   zihealth.NewChecker("mysql", conn.PingAll),
+		}
+	},
 )
@@ -0,0 +1,43 @@
+package zioutbox
+
+import (
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/jmoiron/sqlx"
+)
+
+// Config configures the outbox and its Publisher.
+type Config struct {
+	// Driver is the database driver of the outbox, setting its placeholder
+	// style and schema: "postgres" (default) or "mysql".
+	Driver string `json:"driver" yaml:"driver"`
+	// Table is the outbox table (default: DefaultTable).
+	Table     string          `json:"table" yaml:"table"`
+	Publisher PublisherConfig `json:"publisher" yaml:"publisher"`
+}
+
+// outboxConfig is implemented by configurations of services using an
+// outbox.
+type outboxConfig interface {
+	GetOutbox() Config
+}
+
+// ConfigOf returns the outbox config of c, if any.
+func ConfigOf(c ziconf.Config) Config {
+	var config Config
+	if oc, ok := c.(outboxConfig); ok {
+		config = oc.GetOutbox()
+	}
+	if config.Driver == "" {
+		config.Driver = "postgres"
+	}
+	return config
+}
+
+// NewConfigured returns the Outbox configured by config.
+func NewConfigured(config Config) *Outbox {
+	var opts []Option
+	if config.Table != "" {
+		opts = append(opts, WithTable(config.Table))
+	}
+	return New(sqlx.BindType(config.Driver), opts...)
+}
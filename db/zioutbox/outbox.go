@@ -0,0 +1,212 @@
+// Package zioutbox implements the transactional outbox pattern on top of
+// zisqlx: events are written to an outbox table in the transaction of the
+// changes they announce, then published to a Sink, e.g. a Kafka topic or a
+// queue, by a Publisher polling the table. An event is published if and only
+// if its transaction commits, at least once: consumers should deduplicate
+// the events by ID.
+package zioutbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/divikraf/lumos/db/zisqlx"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const instrumentationName = "github.com/divikraf/lumos/db/zioutbox"
+
+var (
+	tracer = observe.LibraryTracer(instrumentationName)
+	scope  = revelio.Extend(revelio.Library(instrumentationName))
+)
+
+// DefaultTable is the default outbox table.
+const DefaultTable = "outbox_events"
+
+// Event is an event of the outbox.
+type Event struct {
+	// ID identifies the event, to deduplicate it (default: random).
+	ID string
+	// Topic is the topic, or the queue, the event is published to.
+	Topic string
+	// Key, if set, is the key of the event, e.g. the Kafka partition key.
+	// The events of a key are published in order.
+	Key     []byte
+	Payload []byte
+	Headers map[string]string
+	// CreatedAt is the time the event was enqueued, set by Enqueue.
+	CreatedAt time.Time
+}
+
+// Outbox writes events to an outbox table. The table is created by the
+// statements of Schema.
+type Outbox struct {
+	bindType int
+	table    string
+	enqueued metric.Int64Counter
+}
+
+// Option configures an Outbox.
+type Option func(*Outbox)
+
+// WithTable sets the outbox table (default: DefaultTable).
+func WithTable(table string) Option {
+	return func(o *Outbox) {
+		o.table = table
+	}
+}
+
+// New returns an Outbox. bindType is the placeholder style of the database,
+// e.g. sqlx.DOLLAR for PostgreSQL and sqlx.QUESTION for MySQL. The enqueued
+// events are counted in outbox_events_enqueued_total by topic.
+func New(bindType int, opts ...Option) *Outbox {
+	o := &Outbox{
+		bindType: bindType,
+		table:    DefaultTable,
+		enqueued: revelio.Must(scope.Int64Counter(
+			"outbox_events_enqueued_total",
+			"Number of events written to the outbox, by topic",
+		)),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Table returns the outbox table.
+func (o *Outbox) Table() string {
+	return o.table
+}
+
+func (o *Outbox) query(q string) string {
+	return sqlx.Rebind(o.bindType, fmt.Sprintf(q, o.table))
+}
+
+const postgresSchema = `CREATE TABLE IF NOT EXISTS %[1]s (
+	id BIGSERIAL PRIMARY KEY,
+	event_id VARCHAR(64) NOT NULL UNIQUE,
+	topic VARCHAR(255) NOT NULL,
+	event_key BYTEA,
+	payload BYTEA NOT NULL,
+	headers TEXT,
+	attempts INT NOT NULL DEFAULT 0,
+	last_error TEXT,
+	created_at TIMESTAMPTZ NOT NULL,
+	available_at TIMESTAMPTZ NOT NULL,
+	published_at TIMESTAMPTZ,
+	failed_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS %[1]s_pending_idx ON %[1]s (available_at, id)
+	WHERE published_at IS NULL AND failed_at IS NULL;
+CREATE INDEX IF NOT EXISTS %[1]s_pending_key_idx ON %[1]s (topic, event_key, id)
+	WHERE published_at IS NULL AND failed_at IS NULL;`
+
+const mysqlSchema = `CREATE TABLE IF NOT EXISTS %[1]s (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	event_id VARCHAR(64) NOT NULL UNIQUE,
+	topic VARCHAR(255) NOT NULL,
+	event_key VARBINARY(767),
+	payload LONGBLOB NOT NULL,
+	headers TEXT,
+	attempts INT NOT NULL DEFAULT 0,
+	last_error TEXT,
+	created_at DATETIME(6) NOT NULL,
+	available_at DATETIME(6) NOT NULL,
+	published_at DATETIME(6) NULL,
+	failed_at DATETIME(6) NULL,
+	INDEX %[1]s_pending_idx (published_at, failed_at, available_at, id),
+	INDEX %[1]s_pending_key_idx (topic, event_key, published_at, failed_at, id)
+);`
+
+// Schema returns the statements creating the outbox table, for PostgreSQL
+// when the bind type is sqlx.DOLLAR, and for MySQL otherwise.
+func (o *Outbox) Schema() []string {
+	schema := mysqlSchema
+	if o.bindType == sqlx.DOLLAR {
+		schema = postgresSchema
+	}
+	statements := strings.Split(fmt.Sprintf(schema, o.table), ";")
+	schemas := make([]string, 0, len(statements))
+	for _, s := range statements {
+		if s = strings.TrimSpace(s); s != "" {
+			schemas = append(schemas, s)
+		}
+	}
+	return schemas
+}
+
+// CreateSchema creates the outbox table, if it doesn't exist, in db.
+func (o *Outbox) CreateSchema(ctx context.Context, db zisqlx.BasicExecuter) error {
+	for _, statement := range o.Schema() {
+		if _, err := db.ExecContext(ctx, "zioutbox.create_schema", statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Enqueue writes events to the outbox in tx, the transaction of the changes
+// they announce, setting their ID when empty and their CreatedAt. The trace
+// context of ctx is propagated in the headers of the events, so their
+// publication is traced in the trace of the transaction.
+func (o *Outbox) Enqueue(ctx context.Context, tx zisqlx.TxInterface, events ...*Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	rows := make([][]any, len(events))
+	for i, e := range events {
+		if e.Topic == "" {
+			return fmt.Errorf("zioutbox: event %q without topic", e.ID)
+		}
+		if e.ID == "" {
+			e.ID = newID()
+		}
+		e.CreatedAt = now
+		if e.Headers == nil {
+			e.Headers = map[string]string{}
+		}
+		otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(e.Headers))
+		var headers any
+		if len(e.Headers) > 0 {
+			encoded, err := json.Marshal(e.Headers)
+			if err != nil {
+				return err
+			}
+			headers = string(encoded)
+		}
+		rows[i] = []any{e.ID, e.Topic, e.Key, e.Payload, headers, now, now}
+	}
+
+	insert := zisqlx.BatchInsert{
+		Table:    o.table,
+		Columns:  []string{"event_id", "topic", "event_key", "payload", "headers", "created_at", "available_at"},
+		BindType: o.bindType,
+	}
+	if _, err := insert.Exec(ctx, tx, "zioutbox.enqueue", rows); err != nil {
+		return err
+	}
+	for _, e := range events {
+		o.enqueued.Add(ctx, 1, metric.WithAttributes(attribute.String("topic", e.Topic)))
+	}
+	return nil
+}
+
+func newID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
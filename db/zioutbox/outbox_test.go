@@ -0,0 +1,200 @@
+package zioutbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/db/zisqlx"
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"github.com/jmoiron/sqlx"
+)
+
+// outboxRow is a row of the outbox table of outboxDB.
+type outboxRow struct {
+	eventRow
+	availableAt time.Time
+	publishedAt time.Time
+	failedAt    time.Time
+	lastError   string
+}
+
+func (r *outboxRow) pending() bool {
+	return r.publishedAt.IsZero() && r.failedAt.IsZero()
+}
+
+// outboxDB is an in-memory outbox table, with transactions buffering their
+// writes until commit, dispatching the queries on their operation name.
+type outboxDB struct {
+	rows      []*outboxRow
+	queries   []string
+	commitErr error
+}
+
+func (d *outboxDB) GetContext(ctx context.Context, operationName string, dest interface{}, query string, args ...any) error {
+	d.queries = append(d.queries, query)
+	var pending int64
+	for _, r := range d.rows {
+		if r.pending() {
+			pending++
+		}
+	}
+	*dest.(*int64) = pending
+	return nil
+}
+
+func (d *outboxDB) SelectContext(ctx context.Context, operationName string, dest interface{}, query string, args ...any) error {
+	d.queries = append(d.queries, query)
+	now, limit := args[0].(time.Time), args[1].(int)
+	var rows []eventRow
+	for i, r := range d.rows {
+		if len(rows) < limit && r.pending() && !r.availableAt.After(now) && !d.heldBack(i) {
+			rows = append(rows, r.eventRow)
+		}
+	}
+	*dest.(*[]eventRow) = rows
+	return nil
+}
+
+func (d *outboxDB) ExecContext(ctx context.Context, operationName string, query string, args ...any) (sql.Result, error) {
+	d.queries = append(d.queries, query)
+	return nil, errors.New("unexpected statement")
+}
+
+func (d *outboxDB) BeginTx(ctx context.Context, operationName string, opts *sql.TxOptions) (zisqlx.TxInterface, error) {
+	return &outboxTx{outboxDB: d}, nil
+}
+
+// heldBack reports whether an earlier event of the key of the i-th row is
+// pending.
+func (d *outboxDB) heldBack(i int) bool {
+	r := d.rows[i]
+	for _, o := range d.rows[:i] {
+		if o.pending() && o.Key != nil && r.Key != nil && o.Topic == r.Topic && string(o.Key) == string(r.Key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *outboxDB) row(id int64) *outboxRow {
+	for _, r := range d.rows {
+		if r.ID == id {
+			return r
+		}
+	}
+	return nil
+}
+
+type outboxTx struct {
+	*outboxDB
+	writes []func()
+}
+
+func (t *outboxTx) ExecContext(ctx context.Context, operationName string, query string, args ...any) (sql.Result, error) {
+	t.queries = append(t.queries, query)
+	switch operationName {
+	case "zioutbox.enqueue":
+		for i := 0; i < len(args); i += 7 {
+			r := &outboxRow{eventRow: eventRow{
+				EventID:   args[i].(string),
+				Topic:     args[i+1].(string),
+				Key:       args[i+2].([]byte),
+				Payload:   args[i+3].([]byte),
+				CreatedAt: args[i+5].(time.Time),
+			}, availableAt: args[i+6].(time.Time)}
+			if h, ok := args[i+4].(string); ok {
+				r.Headers = sql.NullString{String: h, Valid: true}
+			}
+			t.writes = append(t.writes, func() {
+				r.ID = int64(len(t.rows) + 1)
+				t.rows = append(t.rows, r)
+			})
+		}
+	case "zioutbox.mark_published":
+		t.writes = append(t.writes, func() {
+			r := t.row(args[2].(int64))
+			r.Attempts, r.publishedAt = args[0].(int), args[1].(time.Time)
+		})
+	case "zioutbox.mark_failed":
+		t.writes = append(t.writes, func() {
+			r := t.row(args[4].(int64))
+			r.Attempts, r.lastError, r.availableAt = args[0].(int), args[1].(string), args[2].(time.Time)
+			if failedAt := args[3].(sql.NullTime); failedAt.Valid {
+				r.failedAt = failedAt.Time
+			}
+		})
+	default:
+		return nil, errors.New("unexpected statement " + operationName)
+	}
+	return nil, nil
+}
+
+func (t *outboxTx) Commit() error {
+	if t.commitErr != nil {
+		return t.commitErr
+	}
+	for _, w := range t.writes {
+		w()
+	}
+	return nil
+}
+
+func (t *outboxTx) Rollback() error { return nil }
+
+func TestEnqueue(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	ctx := context.Background()
+	db := &outboxDB{}
+	outbox := New(sqlx.DOLLAR)
+
+	tx, _ := db.BeginTx(ctx, "create_order", nil)
+	events := []*Event{
+		{Topic: "orders", Key: []byte("o-1"), Payload: []byte(`{"id":"o-1"}`)},
+		{ID: "e-2", Topic: "orders", Payload: []byte(`{"id":"o-2"}`), Headers: map[string]string{"source": "api"}},
+	}
+	if err := outbox.Enqueue(ctx, tx, events...); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.rows) != 0 {
+		t.Fatal("events written before commit")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(db.rows) != 2 {
+		t.Fatalf("rows = %d, want 2", len(db.rows))
+	}
+	if events[0].ID == "" || events[0].CreatedAt.IsZero() {
+		t.Errorf("event = %+v, want ID and CreatedAt set", events[0])
+	}
+	if db.rows[1].EventID != "e-2" || db.rows[1].Headers.String != `{"source":"api"}` {
+		t.Errorf("row = %+v", db.rows[1])
+	}
+	want := "INSERT INTO outbox_events (event_id, topic, event_key, payload, headers, created_at, available_at) VALUES ($1"
+	if !strings.HasPrefix(db.queries[0], want) {
+		t.Errorf("query = %q, want prefix %q", db.queries[0], want)
+	}
+	reveliotest.AssertCounterValue(t, s, "outbox_events_enqueued_total", 2)
+
+	if err := outbox.Enqueue(ctx, tx, &Event{Payload: []byte("{}")}); err == nil {
+		t.Error("enqueued an event without topic")
+	}
+}
+
+func TestSchema(t *testing.T) {
+	postgres := New(sqlx.DOLLAR).Schema()
+	if len(postgres) != 3 || !strings.Contains(postgres[0], "BIGSERIAL") || !strings.HasPrefix(postgres[1], "CREATE INDEX IF NOT EXISTS outbox_events_pending_idx") ||
+		!strings.HasPrefix(postgres[2], "CREATE INDEX IF NOT EXISTS outbox_events_pending_key_idx") {
+		t.Errorf("postgres schema = %q", postgres)
+	}
+
+	mysql := New(sqlx.QUESTION, WithTable("events_outbox")).Schema()
+	if len(mysql) != 1 || !strings.HasPrefix(mysql[0], "CREATE TABLE IF NOT EXISTS events_outbox (") || !strings.Contains(mysql[0], "AUTO_INCREMENT") {
+		t.Errorf("mysql schema = %q", mysql)
+	}
+}
@@ -0,0 +1,321 @@
+package zioutbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/divikraf/lumos/db/zisqlx"
+	"github.com/divikraf/lumos/ziqueue"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PublisherConfig configures a Publisher.
+type PublisherConfig struct {
+	// Interval is the interval of the polls of the outbox (default: 1s).
+	Interval time.Duration `json:"interval" yaml:"interval"`
+	// BatchSize is the maximum number of events published per transaction
+	// (default: 100).
+	BatchSize int `json:"batch_size" yaml:"batch_size"`
+	// Retry is the policy of the events failing to publish: they are
+	// abandoned after Retry.MaxAttempts attempts (default: 10), and retried
+	// after the backoff otherwise (default: 1s, doubling up to 5m).
+	Retry ziqueue.RetryPolicy `json:"retry" yaml:"retry"`
+}
+
+func (c *PublisherConfig) setDefaults() {
+	if c.Interval <= 0 {
+		c.Interval = time.Second
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
+	}
+	if c.Retry.MaxAttempts <= 0 {
+		c.Retry.MaxAttempts = 10
+	}
+	if c.Retry.Backoff <= 0 {
+		c.Retry.Backoff = time.Second
+	}
+	if c.Retry.MaxBackoff <= 0 {
+		c.Retry.MaxBackoff = 5 * time.Minute
+	}
+}
+
+// Publisher publishes the events of an Outbox to a Sink, in the order they
+// were enqueued. The events are locked with FOR UPDATE SKIP LOCKED while
+// being published, so several replicas can publish the same outbox.
+//
+// An event is marked published in the transaction locking it: when the
+// transaction fails to commit after the sink accepted the event, the event is
+// published again. These duplicates are counted in
+// outbox_events_duplicated_total by topic, the publications in
+// outbox_events_published_total by topic and status, the abandoned events in
+// outbox_events_abandoned_total by topic, the delay between the enqueuing and
+// the publication of the events in outbox_publish_lag_ms by topic, and the
+// events left to publish in outbox_events_pending.
+type Publisher struct {
+	db     zisqlx.BasicQueryerExecuter
+	outbox *Outbox
+	sink   Sink
+	config PublisherConfig
+	logger *zerolog.Logger
+
+	pending atomic.Int64
+	gauge   metric.Registration
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	published  revelio.ResultCounter
+	abandoned  metric.Int64Counter
+	duplicated metric.Int64Counter
+	lag        revelio.DurationRecorder
+}
+
+// NewPublisher returns a Publisher of the events of outbox, stored in db, to
+// sink.
+func NewPublisher(db zisqlx.BasicQueryerExecuter, outbox *Outbox, sink Sink, config PublisherConfig, logger *zerolog.Logger) *Publisher {
+	config.setDefaults()
+	return &Publisher{
+		db:     db,
+		outbox: outbox,
+		sink:   sink,
+		config: config,
+		logger: logger,
+		published: revelio.Must(scope.ResultCounter(
+			"outbox_events_published_total",
+			"Number of publications of outbox events, by topic and status",
+		)),
+		abandoned: revelio.Must(scope.Int64Counter(
+			"outbox_events_abandoned_total",
+			"Number of outbox events abandoned after failing to publish on their last attempt, by topic",
+		)),
+		duplicated: revelio.Must(scope.Int64Counter(
+			"outbox_events_duplicated_total",
+			"Number of outbox events published but not marked as such, to be published again, by topic",
+		)),
+		lag: revelio.Must(scope.Duration(
+			"outbox_publish_lag_ms",
+			"Delay between the enqueuing and the publication of outbox events in milliseconds, by topic",
+		)),
+	}
+}
+
+type eventRow struct {
+	ID        int64          `db:"id"`
+	EventID   string         `db:"event_id"`
+	Topic     string         `db:"topic"`
+	Key       []byte         `db:"event_key"`
+	Payload   []byte         `db:"payload"`
+	Headers   sql.NullString `db:"headers"`
+	Attempts  int            `db:"attempts"`
+	CreatedAt time.Time      `db:"created_at"`
+}
+
+func (r eventRow) event() (*Event, error) {
+	e := &Event{
+		ID:        r.EventID,
+		Topic:     r.Topic,
+		Key:       r.Key,
+		Payload:   r.Payload,
+		CreatedAt: r.CreatedAt,
+	}
+	if r.Headers.Valid {
+		if err := json.Unmarshal([]byte(r.Headers.String), &e.Headers); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// PublishPending publishes a batch of the pending events, and returns the
+// number of events published. The events failing to publish are retried
+// after a backoff, and the following events of their key wait for them: only
+// the earliest pending event of each key is selected, so neither a backoff nor
+// a concurrent publisher locking it lets a later event of its key go first.
+func (p *Publisher) PublishPending(ctx context.Context) (int, error) {
+	ctx, span := tracer.Start(ctx, "outbox publish", trace.WithAttributes(
+		attribute.String("outbox.table", p.outbox.table),
+	))
+	defer span.End()
+
+	tx, err := p.db.BeginTx(ctx, "zioutbox.publish", nil)
+	if err != nil {
+		observe.RecordError(span, err)
+		return 0, err
+	}
+	var rows []eventRow
+	err = tx.SelectContext(ctx, "zioutbox.select_pending", &rows, p.outbox.query(
+		`SELECT id, event_id, topic, event_key, payload, headers, attempts, created_at FROM %[1]s t
+		WHERE published_at IS NULL AND failed_at IS NULL AND available_at <= ?
+		AND NOT EXISTS (
+			SELECT 1 FROM %[1]s o WHERE o.topic = t.topic AND o.event_key = t.event_key
+			AND o.id < t.id AND o.published_at IS NULL AND o.failed_at IS NULL
+		)
+		ORDER BY id LIMIT ? FOR UPDATE SKIP LOCKED`), time.Now().UTC(), p.config.BatchSize)
+	if err != nil {
+		_ = tx.Rollback()
+		observe.RecordError(span, err)
+		return 0, err
+	}
+
+	var published []*Event
+	for _, r := range rows {
+		e, err := r.event()
+		if err == nil {
+			err = p.publish(ctx, e)
+		}
+		if err != nil {
+			if err := p.fail(ctx, tx, r, err); err != nil {
+				_ = tx.Rollback()
+				p.duplicate(ctx, published)
+				observe.RecordError(span, err)
+				return 0, err
+			}
+			continue
+		}
+		_, err = tx.ExecContext(ctx, "zioutbox.mark_published",
+			p.outbox.query("UPDATE %s SET attempts = ?, published_at = ? WHERE id = ?"),
+			r.Attempts+1, time.Now().UTC(), r.ID)
+		published = append(published, e)
+		if err != nil {
+			_ = tx.Rollback()
+			p.duplicate(ctx, published)
+			observe.RecordError(span, err)
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		p.duplicate(ctx, published)
+		observe.RecordError(span, err)
+		return 0, err
+	}
+	span.SetAttributes(attribute.Int("outbox.published", len(published)))
+	p.refreshPending(ctx)
+	return len(published), nil
+}
+
+// publish publishes e to the sink, in the trace of its enqueuing.
+func (p *Publisher) publish(ctx context.Context, e *Event) error {
+	topic := attribute.String("topic", e.Topic)
+	sinkCtx := otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(e.Headers))
+	err := p.sink.Publish(sinkCtx, e)
+	p.published.Record(ctx, err, topic)
+	if err == nil {
+		p.lag.Record(ctx, time.Since(e.CreatedAt), topic)
+	}
+	return err
+}
+
+// fail records the failed attempt of r, abandoning it on its last attempt.
+func (p *Publisher) fail(ctx context.Context, tx zisqlx.TxInterface, r eventRow, cause error) error {
+	attempts := r.Attempts + 1
+	now := time.Now().UTC()
+	var failedAt sql.NullTime
+	if attempts >= p.config.Retry.MaxAttempts {
+		failedAt = sql.NullTime{Time: now, Valid: true}
+		p.abandoned.Add(ctx, 1, metric.WithAttributes(attribute.String("topic", r.Topic)))
+		p.logger.Error().Err(cause).Str("event_id", r.EventID).Str("topic", r.Topic).Int("attempts", attempts).
+			Msg("Outbox event abandoned")
+	} else {
+		p.logger.Warn().Err(cause).Str("event_id", r.EventID).Str("topic", r.Topic).Int("attempts", attempts).
+			Msg("Failed to publish outbox event")
+	}
+	_, err := tx.ExecContext(ctx, "zioutbox.mark_failed",
+		p.outbox.query("UPDATE %s SET attempts = ?, last_error = ?, available_at = ?, failed_at = ? WHERE id = ?"),
+		attempts, cause.Error(), now.Add(p.config.Retry.Delay(attempts)), failedAt, r.ID)
+	return err
+}
+
+// duplicate counts the events published but not marked as such.
+func (p *Publisher) duplicate(ctx context.Context, events []*Event) {
+	for _, e := range events {
+		p.duplicated.Add(ctx, 1, metric.WithAttributes(attribute.String("topic", e.Topic)))
+	}
+	if len(events) > 0 {
+		p.logger.Warn().Int("events", len(events)).Msg("Outbox events published but not marked, they will be published again")
+	}
+}
+
+// refreshPending counts the events left to publish, for the
+// outbox_events_pending gauge.
+func (p *Publisher) refreshPending(ctx context.Context) {
+	var pending int64
+	err := p.db.GetContext(ctx, "zioutbox.count_pending", &pending,
+		p.outbox.query("SELECT COUNT(*) FROM %s WHERE published_at IS NULL AND failed_at IS NULL"))
+	if err != nil {
+		p.logger.Warn().Err(err).Msg("Failed to count the pending outbox events")
+		return
+	}
+	p.pending.Store(pending)
+}
+
+// Purge deletes the events published before olderThan ago.
+func (p *Publisher) Purge(ctx context.Context, olderThan time.Duration) (int64, error) {
+	res, err := p.db.ExecContext(ctx, "zioutbox.purge",
+		p.outbox.query("DELETE FROM %s WHERE published_at < ?"), time.Now().UTC().Add(-olderThan))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Start polls the outbox every interval until Stop, publishing its pending
+// events until a batch isn't full.
+func (p *Publisher) Start() {
+	gauge, err := scope.GaugeFunc("outbox_events_pending", "Number of outbox events left to publish", p.pending.Load)
+	if err != nil {
+		p.logger.Warn().Err(err).Msg("Failed to register the outbox_events_pending gauge")
+	}
+	p.gauge = gauge
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel, p.done = cancel, make(chan struct{})
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(p.config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			for ctx.Err() == nil {
+				n, err := p.PublishPending(ctx)
+				if err != nil && ctx.Err() == nil {
+					p.logger.Warn().Err(err).Msg("Failed to publish the outbox events")
+				}
+				if err != nil || n < p.config.BatchSize {
+					break
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops polling the outbox, waiting for the current poll until ctx is
+// done.
+func (p *Publisher) Stop(ctx context.Context) error {
+	if p.cancel == nil {
+		return nil
+	}
+	if p.gauge != nil {
+		_ = p.gauge.Unregister()
+	}
+	p.cancel()
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
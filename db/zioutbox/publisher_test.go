@@ -0,0 +1,219 @@
+package zioutbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zikafka"
+	"github.com/divikraf/lumos/ziqueue"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// enqueue writes events to the outbox of db, committed.
+func enqueue(t *testing.T, db *outboxDB, outbox *Outbox, events ...*Event) {
+	t.Helper()
+	ctx := context.Background()
+	tx, _ := db.BeginTx(ctx, "enqueue", nil)
+	if err := outbox.Enqueue(ctx, tx, events...); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPublishPending(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	db := &outboxDB{}
+	outbox := New(sqlx.QUESTION)
+	enqueue(t, db, outbox,
+		&Event{ID: "e-1", Topic: "orders", Key: []byte("o-1"), Payload: []byte("created")},
+		&Event{ID: "e-2", Topic: "orders", Key: []byte("o-1"), Payload: []byte("paid")},
+		&Event{ID: "e-3", Topic: "orders", Key: []byte("o-2"), Payload: []byte("created")},
+	)
+
+	var published []string
+	failing := map[string]bool{"e-1": true}
+	sink := SinkFunc(func(ctx context.Context, e *Event) error {
+		if failing[e.ID] {
+			return errors.New("broker unavailable")
+		}
+		published = append(published, e.ID)
+		return nil
+	})
+	config := PublisherConfig{Retry: ziqueue.RetryPolicy{Backoff: time.Hour}}
+	p := NewPublisher(db, outbox, sink, config, &logger)
+
+	// e-2 waits for e-1, of the same key
+	n, err := p.PublishPending(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || len(published) != 1 || published[0] != "e-3" {
+		t.Fatalf("published %d: %v, want [e-3]", n, published)
+	}
+	if r := db.rows[0]; r.Attempts != 1 || r.lastError != "broker unavailable" || !r.failedAt.IsZero() {
+		t.Errorf("failed row = %+v", r)
+	}
+	if p.pending.Load() != 2 {
+		t.Errorf("pending = %d, want 2", p.pending.Load())
+	}
+
+	// e-2 still waits while e-1 backs off
+	if n, err = p.PublishPending(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 || len(published) != 1 {
+		t.Fatalf("published %d: %v while e-1 backs off, want [e-3]", n, published)
+	}
+
+	delete(failing, "e-1")
+	db.rows[0].availableAt = time.Time{}
+	for range 2 {
+		if n, err = p.PublishPending(ctx); err != nil || n != 1 {
+			t.Fatalf("PublishPending() = %d, %v, want 1 event", n, err)
+		}
+	}
+	if len(published) != 3 || published[1] != "e-1" || published[2] != "e-2" {
+		t.Fatalf("published %v, want [e-3 e-1 e-2]", published)
+	}
+	if r := db.rows[0]; r.Attempts != 2 || r.publishedAt.IsZero() {
+		t.Errorf("published row = %+v", r)
+	}
+	if p.pending.Load() != 0 {
+		t.Errorf("pending = %d, want 0", p.pending.Load())
+	}
+
+	topic := attribute.String("topic", "orders")
+	reveliotest.AssertCounterValue(t, s, "outbox_events_published_total", 3, topic, revelio.StatusKey.String(revelio.StatusSuccess))
+	reveliotest.AssertCounterValue(t, s, "outbox_events_published_total", 1, topic, revelio.StatusKey.String(revelio.StatusFailure))
+	if h := reveliotest.CollectHistogram(t, s, "outbox_publish_lag_ms", topic); h.Count != 3 {
+		t.Errorf("lag count = %d, want 3", h.Count)
+	}
+}
+
+func TestPublishPendingAbandons(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	logger := zerolog.Nop()
+	db := &outboxDB{}
+	outbox := New(sqlx.QUESTION)
+	enqueue(t, db, outbox, &Event{Topic: "orders", Payload: []byte("created")})
+
+	sink := SinkFunc(func(ctx context.Context, e *Event) error {
+		return errors.New("rejected")
+	})
+	p := NewPublisher(db, outbox, sink, PublisherConfig{Retry: ziqueue.RetryPolicy{MaxAttempts: 1}}, &logger)
+	if _, err := p.PublishPending(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if db.rows[0].failedAt.IsZero() {
+		t.Error("event not abandoned on its last attempt")
+	}
+	reveliotest.AssertCounterValue(t, s, "outbox_events_abandoned_total", 1, attribute.String("topic", "orders"))
+}
+
+func TestPublishPendingCommitFailure(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	db := &outboxDB{}
+	outbox := New(sqlx.QUESTION)
+	enqueue(t, db, outbox,
+		&Event{Topic: "orders", Payload: []byte("created")},
+		&Event{Topic: "orders", Payload: []byte("paid")},
+	)
+
+	published := 0
+	sink := SinkFunc(func(ctx context.Context, e *Event) error {
+		published++
+		return nil
+	})
+	p := NewPublisher(db, outbox, sink, PublisherConfig{}, &logger)
+
+	db.commitErr = errors.New("connection reset")
+	if _, err := p.PublishPending(ctx); err == nil {
+		t.Fatal("want the commit error")
+	}
+	reveliotest.AssertCounterValue(t, s, "outbox_events_duplicated_total", 2, attribute.String("topic", "orders"))
+
+	// The events weren't marked published, so they're published again
+	db.commitErr = nil
+	if n, err := p.PublishPending(ctx); err != nil || n != 2 {
+		t.Fatalf("PublishPending() = %d, %v, want 2", n, err)
+	}
+	if published != 4 {
+		t.Errorf("published = %d, want 4", published)
+	}
+}
+
+func TestPublisherStartStop(t *testing.T) {
+	reveliotest.NewDefaultTestScope(t)
+	logger := zerolog.Nop()
+	db := &outboxDB{}
+	outbox := New(sqlx.QUESTION)
+	enqueue(t, db, outbox, &Event{Topic: "orders", Payload: []byte("created")})
+
+	published := make(chan *Event, 1)
+	sink := SinkFunc(func(ctx context.Context, e *Event) error {
+		published <- e
+		return nil
+	})
+	p := NewPublisher(db, outbox, sink, PublisherConfig{Interval: time.Millisecond}, &logger)
+	p.Start()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("event not published")
+	}
+	if err := p.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type recordingClient struct {
+	records []*zikafka.Record
+}
+
+func (c *recordingClient) Produce(ctx context.Context, record *zikafka.Record) error {
+	c.records = append(c.records, record)
+	return nil
+}
+
+func (c *recordingClient) Close() error { return nil }
+
+func TestSinks(t *testing.T) {
+	ctx := context.Background()
+	e := &Event{ID: "e-1", Topic: "orders", Key: []byte("o-1"), Payload: []byte("created"), Headers: map[string]string{"source": "api"}}
+
+	client := &recordingClient{}
+	if err := KafkaSink(zikafka.NewProducer(client)).Publish(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+	r := client.records[0]
+	if id, _ := r.Header(EventIDHeader); r.Topic != "orders" || string(r.Key) != "o-1" || string(id) != "e-1" {
+		t.Errorf("record = %+v", r)
+	}
+	if source, _ := r.Header("source"); string(source) != "api" {
+		t.Errorf("source header = %q, want api", source)
+	}
+
+	driver := ziqueue.NewMemoryDriver()
+	if err := QueueSink(ziqueue.NewBroker(ziqueue.SystemMemory, driver, nil)).Publish(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err := driver.Receive(ctx, "orders", 1)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("Receive() = %v, %v", deliveries, err)
+	}
+	if msg := deliveries[0].Message(); msg.ID != "e-1" || string(msg.Body) != "created" || msg.Headers["source"] != "api" {
+		t.Errorf("message = %+v", msg)
+	}
+}
@@ -0,0 +1,56 @@
+package zioutbox
+
+import (
+	"context"
+
+	"github.com/divikraf/lumos/zikafka"
+	"github.com/divikraf/lumos/ziqueue"
+)
+
+// EventIDHeader is the header of the Kafka records holding the ID of their
+// event, see KafkaSink.
+const EventIDHeader = "outbox-event-id"
+
+// Sink publishes the events of the outbox.
+type Sink interface {
+	Publish(ctx context.Context, event *Event) error
+}
+
+// SinkFunc is a function implementing Sink.
+type SinkFunc func(ctx context.Context, event *Event) error
+
+// Publish implements Sink.
+func (f SinkFunc) Publish(ctx context.Context, event *Event) error {
+	return f(ctx, event)
+}
+
+// QueueSink returns the Sink publishing the events to the queue of broker
+// named after their topic, with their ID as message ID.
+func QueueSink(broker *ziqueue.Broker) Sink {
+	return SinkFunc(func(ctx context.Context, event *Event) error {
+		opts := make([]ziqueue.PublishOption, 0, len(event.Headers)+1)
+		opts = append(opts, ziqueue.WithMessageID(event.ID))
+		for k, v := range event.Headers {
+			opts = append(opts, ziqueue.WithHeader(k, v))
+		}
+		return broker.Publish(ctx, event.Topic, event.Payload, opts...)
+	})
+}
+
+// KafkaSink returns the Sink producing the events to their topic with
+// producer, keyed by their key, with their ID in the EventIDHeader header.
+func KafkaSink(producer *zikafka.Producer) Sink {
+	return SinkFunc(func(ctx context.Context, event *Event) error {
+		record := &zikafka.Record{
+			Topic:     event.Topic,
+			Key:       event.Key,
+			Value:     event.Payload,
+			Timestamp: event.CreatedAt,
+		}
+		for k, v := range event.Headers {
+			record.SetHeader(k, []byte(v))
+		}
+		record.SetHeader(EventIDHeader, []byte(event.ID))
+		return producer.Produce(ctx, record)
+	})
+}
@@ -0,0 +1,47 @@
+package zioutboxfx
+
+import (
+	"github.com/divikraf/lumos/db/zioutbox"
+	"github.com/divikraf/lumos/db/zisqlx"
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/rs/zerolog"
+	"go.uber.org/fx"
+)
+
+type publisherParams struct {
+	fx.In
+
+	LC     fx.Lifecycle
+	Config ziconf.Config
+	DB     zisqlx.BasicQueryerExecuter
+	Outbox *zioutbox.Outbox
+	Sink   zioutbox.Sink
+	Logger *zerolog.Logger
+}
+
+// Provider provides the *zioutbox.Outbox of the outbox config, and its
+// *zioutbox.Publisher, started and stopped with the application. A
+// zisqlx.BasicQueryerExecuter and a zioutbox.Sink must be provided, see
+// WithQueueSink and WithKafkaSink.
+var Provider = fx.Provide(
+	func(config ziconf.Config) *zioutbox.Outbox {
+		return zioutbox.NewConfigured(zioutbox.ConfigOf(config))
+	},
+	func(params publisherParams) *zioutbox.Publisher {
+		p := zioutbox.NewPublisher(params.DB, params.Outbox, params.Sink, zioutbox.ConfigOf(params.Config).Publisher, params.Logger)
+		params.LC.Append(fx.StartStopHook(p.Start, p.Stop))
+		return p
+	},
+)
+
+// Invoker starts the publisher, which is otherwise only started when
+// required by another component.
+var Invoker = fx.Invoke(func(*zioutbox.Publisher) {})
+
+// WithQueueSink publishes the events to the queues of the provided
+// *ziqueue.Broker, see zioutbox.QueueSink.
+var WithQueueSink = fx.Provide(zioutbox.QueueSink)
+
+// WithKafkaSink publishes the events to the Kafka topics of the provided
+// *zikafka.Producer, see zioutbox.KafkaSink.
+var WithKafkaSink = fx.Provide(zioutbox.KafkaSink)
@@ -2,24 +2,69 @@ package zipg
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"net/url"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/divikraf/lumos/db/zisqlx"
 	"github.com/go-playground/validator/v10"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/rs/zerolog"
 )
 
 // New returns connection creator.
-func New(validator *validator.Validate, logger *zerolog.Logger) *pgConnector {
-	return &pgConnector{
+func New(validator *validator.Validate, logger *zerolog.Logger, opts ...Option) *pgConnector {
+	pgc := &pgConnector{
 		validator: validator,
 		logger:    logger,
 		conns:     &sync.Map{},
 	}
+	for _, o := range opts {
+		o(pgc)
+	}
+	return pgc
+}
+
+// Option configures the connection creator.
+type Option func(*pgConnector)
+
+// WithDefaults sets the session settings of connections whose Input doesn't
+// set them.
+func WithDefaults(defaults Defaults) Option {
+	return func(pgc *pgConnector) {
+		pgc.defaults = defaults
+	}
+}
+
+// WithSQLComments appends sqlcommenter comments with the trace context,
+// service and route to the statements of the connections, see
+// zisqlx.WithSQLComments.
+func WithSQLComments(service string) Option {
+	return func(pgc *pgConnector) {
+		pgc.comments = &service
+	}
+}
+
+// Defaults are session settings applied to every connection, so that DBAs can
+// attribute connections and runaway queries to services.
+type Defaults struct {
+	// ApplicationName is reported in pg_stat_activity, usually the service
+	// name.
+	ApplicationName string `json:"application_name"`
+	// StatementTimeout aborts statements running longer, zero keeps the
+	// server default.
+	StatementTimeout time.Duration `json:"statement_timeout"`
+	// IdleInTransactionSessionTimeout terminates sessions idle in a
+	// transaction for longer, zero keeps the server default.
+	IdleInTransactionSessionTimeout time.Duration `json:"idle_in_transaction_session_timeout"`
+	// SQLComments appends sqlcommenter comments to the statements, tagged
+	// with the service name, see WithSQLComments.
+	SQLComments bool `json:"sql_comments"`
 }
 
 type HostPort struct {
@@ -45,12 +90,23 @@ type Input struct {
 	DatabaseName string   `validate:"required"`
 	ConnConfig   ConnectionConfig
 	QueryParams  url.Values
+
+	// ApplicationName, StatementTimeout and IdleInTransactionSessionTimeout
+	// override the connector Defaults. Negative timeouts keep the server
+	// default.
+	ApplicationName                 string
+	StatementTimeout                time.Duration
+	IdleInTransactionSessionTimeout time.Duration
 }
 
 type pgConnector struct {
 	validator *validator.Validate
 	logger    *zerolog.Logger
 	conns     *sync.Map
+	defaults  Defaults
+	// comments is the service of the sqlcommenter comments, nil disabling
+	// them
+	comments *string
 }
 
 func (pgc *pgConnector) PingAll(ctx context.Context) error {
@@ -96,14 +152,14 @@ func (pgc *pgConnector) Connect(ctx context.Context, input Input) (*sqlx.DB, err
 		return nil, errValidate
 	}
 
+	input = pgc.withDefaults(input)
 	logger := pgc.logger.With().
 		Str("hostport", input.HostPort.String()).
 		Str("dbname", input.DatabaseName).
+		Str("application_name", input.ApplicationName).
 		Logger()
 
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", input.HostPort.Host, input.HostPort.Post, input.Username, input.Password, input.DatabaseName)
-
-	sqldb, err := sqlx.Open("postgres", dsn)
+	sqldb, err := pgc.open(dsn(input))
 	if err != nil {
 		logger.Error().Err(err).Msg(err.Error())
 		return nil, err
@@ -117,3 +173,55 @@ func (pgc *pgConnector) Connect(ctx context.Context, input Input) (*sqlx.DB, err
 	pgc.conns.Store(input.HostPort.String(), sqldb)
 	return sqldb, nil
 }
+
+// open returns the database of dsn, its statements commented when enabled.
+func (pgc *pgConnector) open(dsn string) (*sqlx.DB, error) {
+	if pgc.comments == nil {
+		return sqlx.Open("postgres", dsn)
+	}
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return sqlx.NewDb(sql.OpenDB(zisqlx.CommentConnector(connector, *pgc.comments)), "postgres"), nil
+}
+
+// withDefaults fills the session settings input doesn't set with the
+// connector defaults.
+func (pgc *pgConnector) withDefaults(input Input) Input {
+	if input.ApplicationName == "" {
+		input.ApplicationName = pgc.defaults.ApplicationName
+	}
+	if input.StatementTimeout == 0 {
+		input.StatementTimeout = pgc.defaults.StatementTimeout
+	}
+	if input.IdleInTransactionSessionTimeout == 0 {
+		input.IdleInTransactionSessionTimeout = pgc.defaults.IdleInTransactionSessionTimeout
+	}
+	return input
+}
+
+// dsn returns the key/value connection string of input. Session settings are
+// sent as run-time parameters when the connection starts.
+func dsn(input Input) string {
+	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		dsnValue(input.HostPort.Host), dsnValue(input.HostPort.Post), dsnValue(input.Username), dsnValue(input.Password), dsnValue(input.DatabaseName))
+	if input.ApplicationName != "" {
+		dsn += " application_name=" + dsnValue(input.ApplicationName)
+	}
+	if input.StatementTimeout > 0 {
+		dsn += " statement_timeout=" + strconv.FormatInt(input.StatementTimeout.Milliseconds(), 10)
+	}
+	if input.IdleInTransactionSessionTimeout > 0 {
+		dsn += " idle_in_transaction_session_timeout=" + strconv.FormatInt(input.IdleInTransactionSessionTimeout.Milliseconds(), 10)
+	}
+	return dsn
+}
+
+// dsnValue quotes v when needed by the key/value connection string format.
+func dsnValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, " '\\") {
+		return v
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
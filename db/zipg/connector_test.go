@@ -0,0 +1,52 @@
+package zipg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestDSN(t *testing.T) {
+	logger := zerolog.Nop()
+	pgc := New(nil, &logger, WithDefaults(Defaults{
+		ApplicationName:                 "orders",
+		StatementTimeout:                30 * time.Second,
+		IdleInTransactionSessionTimeout: time.Minute,
+	}))
+	base := Input{
+		HostPort:     HostPort{Host: "db", Post: "5432"},
+		Username:     "app",
+		Password:     "it's secret",
+		DatabaseName: "orders",
+	}
+
+	tests := []struct {
+		name  string
+		input func(Input) Input
+		want  string
+	}{
+		{
+			name:  "defaults",
+			input: func(in Input) Input { return in },
+			want:  `host=db port=5432 user=app password='it\'s secret' dbname=orders sslmode=disable application_name=orders statement_timeout=30000 idle_in_transaction_session_timeout=60000`,
+		},
+		{
+			name: "overrides",
+			input: func(in Input) Input {
+				in.ApplicationName = "orders worker"
+				in.StatementTimeout = 5 * time.Second
+				in.IdleInTransactionSessionTimeout = -1
+				return in
+			},
+			want: `host=db port=5432 user=app password='it\'s secret' dbname=orders sslmode=disable application_name='orders worker' statement_timeout=5000`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dsn(pgc.withDefaults(tt.input(base))); got != tt.want {
+				t.Errorf("dsn() = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
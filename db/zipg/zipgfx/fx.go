@@ -4,6 +4,8 @@ import (
 	"context"
 
 	"github.com/divikraf/lumos/db/zipg"
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zihealth"
 	"github.com/go-playground/validator/v10"
 	"github.com/jmoiron/sqlx"
 	"github.com/rs/zerolog"
@@ -25,13 +27,51 @@ type connParams struct {
 	LC        fx.Lifecycle
 	Validator *validator.Validate
 	Logger    *zerolog.Logger
+	Config    ziconf.Config `optional:"true"`
+}
+
+// postgresConfig is implemented by configs setting the session defaults of
+// PostgreSQL connections.
+type postgresConfig interface {
+	GetPostgres() zipg.Defaults
+}
+
+// defaults returns the session defaults from config, the application name
+// defaulting to the service name.
+func defaults(config ziconf.Config) zipg.Defaults {
+	var d zipg.Defaults
+	if config == nil {
+		return d
+	}
+	if c, ok := config.(postgresConfig); ok {
+		d = c.GetPostgres()
+	}
+	if d.ApplicationName == "" {
+		d.ApplicationName = config.GetService().Name
+	}
+	return d
+}
+
+type fxResult struct {
+	fx.Out
+
+	Connector Connector
+	Checker   zihealth.Checker `group:"health.checker"`
 }
 
 var Provider = fx.Provide(
-	fx.Annotate(func(params connParams) Connector {
-		conn := zipg.New(params.Validator, params.Logger)
+	func(params connParams) fxResult {
+		d := defaults(params.Config)
+		opts := []zipg.Option{zipg.WithDefaults(d)}
+		if d.SQLComments {
+			opts = append(opts, zipg.WithSQLComments(params.Config.GetService().Name))
+		}
+		conn := zipg.New(params.Validator, params.Logger, opts...)
 		params.LC.Append(fx.StartHook(conn.PingAll))
 		params.LC.Append(fx.StopHook(conn.CloseAll))
-		return conn
-	}, fx.As(new(Connector))),
+		return fxResult{
+			Connector: conn,
+			Checker:   zihealth.NewChecker("postgres", conn.PingAll),
+		}
+	},
 )
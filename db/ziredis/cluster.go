@@ -0,0 +1,171 @@
+package ziredis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// migrationWindow is how long a slot is considered migrating after an ASK
+// redirect.
+const migrationWindow = time.Minute
+
+// migratingSlots tracks the slots recently redirected with ASK, i.e. being
+// migrated between nodes, for all cluster clients.
+var migratingSlots = &slotTracker{seen: map[string]time.Time{}}
+
+var registerMigratingSlots sync.Once
+
+type slotTracker struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func (t *slotTracker) mark(slot string) {
+	t.mu.Lock()
+	t.seen[slot] = time.Now()
+	t.mu.Unlock()
+}
+
+// count returns the number of slots redirected with ASK within the migration
+// window, forgetting the older ones.
+func (t *slotTracker) count() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for slot, at := range t.seen {
+		if time.Since(at) > migrationWindow {
+			delete(t.seen, slot)
+		}
+	}
+	return int64(len(t.seen))
+}
+
+// clusterObserver is a hook added to the clients of every cluster node. It
+// sees the redirections and topology refreshes hidden by the cluster client,
+// to make resharding windows observable:
+//
+//   - redis_cluster_redirects_total{type} counts MOVED, ASK and TRYAGAIN
+//     replies,
+//   - redis_cluster_topology_refreshes_total{status} counts slot map loads,
+//   - redis_cluster_migrating_slots is the number of slots redirected with ASK
+//     in the last minute.
+//
+// Redirections are logged as warnings, sampled to avoid flooding the logs
+// during resharding.
+type clusterObserver struct {
+	logger    zerolog.Logger
+	redirects metric.Int64Counter
+	refreshes metric.Int64Counter
+}
+
+func newClusterObserver(logger zerolog.Logger) *clusterObserver {
+	registerMigratingSlots.Do(func() {
+		_, err := scope.GaugeFunc(
+			"redis_cluster_migrating_slots",
+			"Number of Redis cluster slots redirected with ASK in the last minute",
+			migratingSlots.count,
+		)
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to register redis_cluster_migrating_slots")
+		}
+	})
+
+	return &clusterObserver{
+		logger: logger.Sample(&zerolog.BurstSampler{Burst: 10, Period: time.Second}),
+		redirects: revelio.Must(scope.Int64Counter(
+			"redis_cluster_redirects_total",
+			"Number of Redis cluster redirections, by type",
+		)),
+		refreshes: revelio.Must(scope.Int64Counter(
+			"redis_cluster_topology_refreshes_total",
+			"Number of Redis cluster topology refreshes",
+		)),
+	}
+}
+
+func (o *clusterObserver) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (o *clusterObserver) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		o.observe(ctx, cmd, err)
+		return err
+	}
+}
+
+func (o *clusterObserver) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := next(ctx, cmds)
+		for _, cmd := range cmds {
+			o.observe(ctx, cmd, cmd.Err())
+		}
+		return err
+	}
+}
+
+func (o *clusterObserver) observe(ctx context.Context, cmd redis.Cmder, err error) {
+	if isTopologyCmd(cmd) {
+		status := "success"
+		if err != nil {
+			status = "error"
+			o.logger.Warn().Err(err).Msg("failed to refresh Redis cluster topology")
+		}
+		o.refreshes.Add(ctx, 1, metric.WithAttributes(attribute.String("status", status)))
+		return
+	}
+
+	var redisErr redis.Error
+	if !errors.As(err, &redisErr) {
+		return
+	}
+	// MOVED <slot> <addr>, ASK <slot> <addr>, TRYAGAIN <message>
+	fields := strings.Fields(redisErr.Error())
+	if len(fields) == 0 {
+		return
+	}
+	var kind string
+	switch fields[0] {
+	case "MOVED":
+		kind = "moved"
+	case "ASK":
+		kind = "ask"
+	case "TRYAGAIN":
+		kind = "tryagain"
+	default:
+		return
+	}
+	o.redirects.Add(ctx, 1, metric.WithAttributes(attribute.String("type", kind)))
+
+	event := o.logger.Warn().Str("command", cmd.Name()).Str("redirect", kind)
+	if len(fields) == 3 && kind != "tryagain" {
+		event = event.Str("slot", fields[1]).Str("addr", fields[2])
+		if kind == "ask" {
+			migratingSlots.mark(fields[1])
+		}
+	}
+	event.Msg("Redis cluster redirection, slots may be migrating")
+}
+
+// isTopologyCmd reports whether cmd loads the cluster slot map.
+func isTopologyCmd(cmd redis.Cmder) bool {
+	args := cmd.Args()
+	if len(args) < 2 || cmd.Name() != "cluster" {
+		return false
+	}
+	sub, _ := args[1].(string)
+	switch strings.ToLower(sub) {
+	case "slots", "shards", "nodes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package ziredis
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// testRedisError is a reply error, as returned by Redis servers.
+type testRedisError string
+
+func (e testRedisError) Error() string { return string(e) }
+
+func (testRedisError) RedisError() {}
+
+func TestClusterObserver(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	o := newClusterObserver(zerolog.Nop())
+	ctx := context.Background()
+
+	process := o.ProcessHook(func(ctx context.Context, cmd redis.Cmder) error {
+		return cmd.Err()
+	})
+	run := func(cmd redis.Cmder, err error) {
+		cmd.SetErr(err)
+		process(ctx, cmd)
+	}
+	run(redis.NewStringCmd(ctx, "get", "a"), testRedisError("MOVED 3999 127.0.0.1:6381"))
+	run(redis.NewStringCmd(ctx, "get", "b"), testRedisError("ASK 3999 127.0.0.1:6381"))
+	run(redis.NewStringCmd(ctx, "get", "c"), testRedisError("ASK 42 127.0.0.1:6381"))
+	run(redis.NewStringCmd(ctx, "get", "d"), testRedisError("TRYAGAIN Multiple keys request during rehashing of slot"))
+	run(redis.NewStringCmd(ctx, "get", "e"), testRedisError("WRONGTYPE Operation against a key"))
+	run(redis.NewStringCmd(ctx, "get", "f"), errors.New("i/o timeout"))
+	run(redis.NewClusterSlotsCmd(ctx, "cluster", "slots"), nil)
+	run(redis.NewClusterSlotsCmd(ctx, "cluster", "slots"), errors.New("connection refused"))
+
+	reveliotest.AssertCounterValue(t, s, "redis_cluster_redirects_total", 1, attribute.String("type", "moved"))
+	reveliotest.AssertCounterValue(t, s, "redis_cluster_redirects_total", 2, attribute.String("type", "ask"))
+	reveliotest.AssertCounterValue(t, s, "redis_cluster_redirects_total", 1, attribute.String("type", "tryagain"))
+	reveliotest.AssertCounterValue(t, s, "redis_cluster_topology_refreshes_total", 1, attribute.String("status", "success"))
+	reveliotest.AssertCounterValue(t, s, "redis_cluster_topology_refreshes_total", 1, attribute.String("status", "error"))
+
+	if got := migratingSlots.count(); got != 2 {
+		t.Errorf("migrating slots = %d, want 2", got)
+	}
+}
@@ -138,6 +138,20 @@ type InputCluster struct {
 	Username   string
 	Password   string
 	ConnConfig ConnectionConfig
+	Routing    ClusterRouting
+}
+
+// ClusterRouting configures how cluster commands are routed.
+type ClusterRouting struct {
+	// RouteByLatency routes read-only commands to the closest master or
+	// replica node.
+	RouteByLatency bool
+	// RouteRandomly routes read-only commands to a random master or replica
+	// node.
+	RouteRandomly bool
+	// MaxRedirects is the maximum number of MOVED/ASK redirections followed
+	// by a command (default: 3, -1 to disable them).
+	MaxRedirects int
 }
 
 func (c *connector) MustConnectCluster(ctx context.Context, input InputCluster) *redis.ClusterClient {
@@ -169,9 +183,18 @@ func (c *connector) ConnectCluster(ctx context.Context, input InputCluster) (*re
 		MaxIdleConns:          int(input.ConnConfig.MaxIdleConn),
 		ConnMaxIdleTime:       input.ConnConfig.MaxIdleTime,
 		ConnMaxLifetime:       input.ConnConfig.MaxLifeTime,
+		MaxRedirects:          input.Routing.MaxRedirects,
+		RouteByLatency:        input.Routing.RouteByLatency,
+		RouteRandomly:         input.Routing.RouteRandomly,
 	}
 
 	cl := redis.NewClusterClient(opt)
+	logger := c.logger.With().Str("client", input.ClientName).Logger()
+	observer := newClusterObserver(logger)
+	cl.OnNewNode(func(rdb *redis.Client) {
+		logger.Debug().Str("addr", rdb.Options().Addr).Msg("connected to Redis cluster node")
+		rdb.AddHook(observer)
+	})
 
 	var stor redis.UniversalClient = cl
 	c.conns.Store(strings.Join(multiHostPort(input.HostPorts).Strings(), ","), stor)
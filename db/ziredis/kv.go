@@ -0,0 +1,237 @@
+package ziredis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const instrumentationName = "github.com/divikraf/lumos/db/ziredis"
+
+var (
+	tracer = observe.LibraryTracer(instrumentationName)
+	scope  = revelio.Extend(revelio.Library(instrumentationName))
+)
+
+// Codec encodes the values of a KV.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec encodes values in JSON.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (JSONCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// KV provides typed helpers on a Redis client, encoding values with a codec.
+// Operations are traced, timed in redis_kv_operation_duration_ms and counted
+// in redis_kv_operations_total, by operation.
+type KV struct {
+	client   redis.UniversalClient
+	codec    Codec
+	duration revelio.DurationRecorder
+	results  revelio.ResultCounter
+}
+
+// KVOption configures a KV.
+type KVOption func(*KV)
+
+// WithCodec sets the codec of the values (default: JSONCodec).
+func WithCodec(codec Codec) KVOption {
+	return func(kv *KV) {
+		kv.codec = codec
+	}
+}
+
+// NewKV returns a KV of client.
+func NewKV(client redis.UniversalClient, opts ...KVOption) *KV {
+	kv := &KV{
+		client:   client,
+		codec:    JSONCodec{},
+		duration: revelio.Must(scope.Duration("redis_kv_operation_duration_ms", "Duration of Redis KV operations in milliseconds")),
+		results:  revelio.Must(scope.ResultCounter("redis_kv_operations_total", "Number of Redis KV operations by status and error type")),
+	}
+	for _, o := range opts {
+		o(kv)
+	}
+	return kv
+}
+
+// Client returns the underlying client.
+func (kv *KV) Client() redis.UniversalClient {
+	return kv.client
+}
+
+// do runs the operation op on key, instrumented. redis.Nil isn't a failure.
+func (kv *KV) do(ctx context.Context, op, key string, f func(ctx context.Context) error) error {
+	ctx, span := tracer.Start(ctx, "redis.kv."+op)
+	defer span.End()
+	span.SetAttributes(attribute.String("db.system", "redis"), attribute.String("db.operation", op), attribute.String("db.redis.key", key))
+
+	start := time.Now()
+	err := f(ctx)
+	recorded := err
+	if errors.Is(err, redis.Nil) {
+		recorded = nil
+	}
+	attr := attribute.String("operation", op)
+	kv.duration.Record(ctx, time.Since(start), attr)
+	kv.results.Record(ctx, recorded, attr)
+	if recorded != nil {
+		observe.RecordError(span, recorded)
+	}
+	return err
+}
+
+// decode decodes data into a T.
+func decode[T any](kv *KV, key string, data []byte) (T, error) {
+	var v T
+	if err := kv.codec.Unmarshal(data, &v); err != nil {
+		return v, fmt.Errorf("ziredis: failed to decode %s: %w", key, err)
+	}
+	return v, nil
+}
+
+// Get returns the value of key, and false when it doesn't exist.
+func Get[T any](ctx context.Context, kv *KV, key string) (T, bool, error) {
+	var data []byte
+	err := kv.do(ctx, "get", key, func(ctx context.Context) (err error) {
+		data, err = kv.client.Get(ctx, key).Bytes()
+		return err
+	})
+	return decoded[T](kv, key, data, err)
+}
+
+// GetEx returns the value of key like Get, setting its expiration to ttl, or
+// removing it when ttl is zero.
+func GetEx[T any](ctx context.Context, kv *KV, key string, ttl time.Duration) (T, bool, error) {
+	var data []byte
+	err := kv.do(ctx, "getex", key, func(ctx context.Context) (err error) {
+		if ttl > 0 {
+			data, err = kv.client.GetEx(ctx, key, ttl).Bytes()
+		} else {
+			var s string
+			s, err = kv.client.Do(ctx, "getex", key, "persist").Text()
+			data = []byte(s)
+		}
+		return err
+	})
+	return decoded[T](kv, key, data, err)
+}
+
+func decoded[T any](kv *KV, key string, data []byte, err error) (T, bool, error) {
+	var v T
+	if errors.Is(err, redis.Nil) {
+		return v, false, nil
+	}
+	if err != nil {
+		return v, false, err
+	}
+	v, err = decode[T](kv, key, data)
+	return v, err == nil, err
+}
+
+// Set sets the value of key, expiring after ttl, or never when ttl is zero.
+func (kv *KV) Set(ctx context.Context, key string, v any, ttl time.Duration) error {
+	data, err := kv.codec.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("ziredis: failed to encode %s: %w", key, err)
+	}
+	return kv.do(ctx, "set", key, func(ctx context.Context) error {
+		return kv.client.Set(ctx, key, data, ttl).Err()
+	})
+}
+
+// Delete deletes keys, and returns the number of keys deleted.
+func (kv *KV) Delete(ctx context.Context, keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	var n int64
+	err := kv.do(ctx, "del", keys[0], func(ctx context.Context) (err error) {
+		n, err = kv.client.Del(ctx, keys...).Result()
+		return err
+	})
+	return n, err
+}
+
+// SetNX sets the value of key like Set when it doesn't exist, and reports
+// whether it was set.
+func (kv *KV) SetNX(ctx context.Context, key string, v any, ttl time.Duration) (bool, error) {
+	data, err := kv.codec.Marshal(v)
+	if err != nil {
+		return false, fmt.Errorf("ziredis: failed to encode %s: %w", key, err)
+	}
+	var set bool
+	err = kv.do(ctx, "setnx", key, func(ctx context.Context) (err error) {
+		set, err = kv.client.SetNX(ctx, key, data, ttl).Result()
+		return err
+	})
+	return set, err
+}
+
+// casScript sets KEYS[1] to ARGV[2] when its value is ARGV[1], expiring after
+// ARGV[3] milliseconds, or keeping its expiration when zero.
+var casScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+else
+	redis.call('SET', KEYS[1], ARGV[2], 'KEEPTTL')
+end
+return 1
+`)
+
+// CompareAndSwap atomically sets the value of key to value when it is old, and
+// reports whether it was swapped. Values are compared encoded, so the codec
+// must be deterministic, as JSONCodec is. A positive ttl resets the
+// expiration, otherwise it is kept.
+func CompareAndSwap[T any](ctx context.Context, kv *KV, key string, old, value T, ttl time.Duration) (bool, error) {
+	oldData, err := kv.codec.Marshal(old)
+	if err != nil {
+		return false, fmt.Errorf("ziredis: failed to encode %s: %w", key, err)
+	}
+	newData, err := kv.codec.Marshal(value)
+	if err != nil {
+		return false, fmt.Errorf("ziredis: failed to encode %s: %w", key, err)
+	}
+	var swapped int64
+	err = kv.do(ctx, "cas", key, func(ctx context.Context) (err error) {
+		swapped, err = casScript.Run(ctx, kv.client, []string{key}, oldData, newData, ttl.Milliseconds()).Int64()
+		return err
+	})
+	return swapped == 1, err
+}
+
+// incrScript increments KEYS[1] by ARGV[1], setting its expiration to ARGV[2]
+// milliseconds when it has none.
+var incrScript = redis.NewScript(`
+local v = redis.call('INCRBY', KEYS[1], ARGV[1])
+if tonumber(ARGV[2]) > 0 and redis.call('PTTL', KEYS[1]) == -1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return v
+`)
+
+// Incr atomically increments the counter key by delta and returns its value.
+// New counters expire after ttl, e.g. for fixed window counters; the
+// expiration isn't extended by later increments.
+func (kv *KV) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	var v int64
+	err := kv.do(ctx, "incr", key, func(ctx context.Context) (err error) {
+		v, err = incrScript.Run(ctx, kv.client, []string{key}, delta, ttl.Milliseconds()).Int64()
+		return err
+	})
+	return v, err
+}
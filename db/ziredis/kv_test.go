@@ -0,0 +1,220 @@
+package ziredis
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// fakeStore answers the commands of a client from memory, as a hook never
+// reaching the network. The scripts are emulated by their hash.
+type fakeStore struct {
+	values map[string]string
+	ttls   map[string]time.Duration
+}
+
+func newFakeClient(t *testing.T) (*redis.Client, *fakeStore) {
+	t.Helper()
+	store := &fakeStore{values: map[string]string{}, ttls: map[string]time.Duration{}}
+	client := redis.NewClient(&redis.Options{Addr: "fake:6379"})
+	client.AddHook(store)
+	t.Cleanup(func() { client.Close() })
+	return client, store
+}
+
+func (s *fakeStore) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, fmt.Errorf("fake store does not dial")
+	}
+}
+
+func (s *fakeStore) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func (s *fakeStore) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		args := make([]string, len(cmd.Args()))
+		for i, a := range cmd.Args() {
+			if b, ok := a.([]byte); ok {
+				a = string(b)
+			}
+			args[i] = fmt.Sprint(a)
+		}
+		s.process(cmd, strings.ToLower(args[0]), args[1:])
+		return cmd.Err()
+	}
+}
+
+func (s *fakeStore) process(cmd redis.Cmder, name string, args []string) {
+	switch name {
+	case "get", "getex":
+		v, ok := s.values[args[0]]
+		if !ok {
+			cmd.SetErr(redis.Nil)
+			return
+		}
+		if name == "getex" {
+			s.expire(args[0], args[1:])
+		}
+		setVal(cmd, v)
+	case "set":
+		key := args[0]
+		nx := len(args) > 2 && strings.EqualFold(args[len(args)-1], "nx")
+		if _, exists := s.values[key]; nx && exists {
+			if c, ok := cmd.(*redis.BoolCmd); ok {
+				c.SetVal(false)
+			} else {
+				cmd.SetErr(redis.Nil)
+			}
+			return
+		}
+		s.values[key] = args[1]
+		delete(s.ttls, key)
+		s.expire(key, args[2:])
+		if c, ok := cmd.(*redis.BoolCmd); ok {
+			c.SetVal(true)
+		}
+	case "del":
+		var n int64
+		for _, key := range args {
+			if _, ok := s.values[key]; ok {
+				delete(s.values, key)
+				delete(s.ttls, key)
+				n++
+			}
+		}
+		setVal(cmd, n)
+	case "evalsha":
+		key, argv := args[2], args[3:]
+		switch args[0] {
+		case casScript.Hash():
+			if s.values[key] != argv[0] {
+				setVal(cmd, int64(0))
+				return
+			}
+			s.values[key] = argv[1]
+			if ms, _ := strconv.Atoi(argv[2]); ms > 0 {
+				s.ttls[key] = time.Duration(ms) * time.Millisecond
+			}
+			setVal(cmd, int64(1))
+		case incrScript.Hash():
+			n, _ := strconv.ParseInt(s.values[key], 10, 64)
+			delta, _ := strconv.ParseInt(argv[0], 10, 64)
+			n += delta
+			s.values[key] = strconv.FormatInt(n, 10)
+			if ms, _ := strconv.Atoi(argv[1]); ms > 0 && s.ttls[key] == 0 {
+				s.ttls[key] = time.Duration(ms) * time.Millisecond
+			}
+			setVal(cmd, n)
+		}
+	default:
+		cmd.SetErr(fmt.Errorf("fake store: unsupported command %s", name))
+	}
+}
+
+// expire applies the EX, PX and PERSIST arguments of key.
+func (s *fakeStore) expire(key string, args []string) {
+	for i := 0; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "ex":
+			n, _ := strconv.Atoi(args[i+1])
+			s.ttls[key] = time.Duration(n) * time.Second
+		case "px":
+			n, _ := strconv.Atoi(args[i+1])
+			s.ttls[key] = time.Duration(n) * time.Millisecond
+		case "persist":
+			delete(s.ttls, key)
+		}
+	}
+}
+
+func setVal(cmd redis.Cmder, v any) {
+	switch c := cmd.(type) {
+	case *redis.StringCmd:
+		c.SetVal(fmt.Sprint(v))
+	case *redis.Cmd:
+		c.SetVal(v)
+	case *redis.StatusCmd:
+		c.SetVal(fmt.Sprint(v))
+	case *redis.IntCmd:
+		c.SetVal(v.(int64))
+	}
+}
+
+type session struct {
+	UserID string `json:"user_id"`
+	Visits int    `json:"visits"`
+}
+
+func TestKV(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	ctx := context.Background()
+	client, store := newFakeClient(t)
+	kv := NewKV(client)
+
+	if _, ok, err := Get[session](ctx, kv, "session:1"); ok || err != nil {
+		t.Fatalf("Get() of a missing key = %v, %v", ok, err)
+	}
+	if err := kv.Set(ctx, "session:1", session{UserID: "u-1", Visits: 1}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if store.values["session:1"] != `{"user_id":"u-1","visits":1}` || store.ttls["session:1"] != time.Minute {
+		t.Fatalf("stored %q with ttl %s", store.values["session:1"], store.ttls["session:1"])
+	}
+	got, ok, err := GetEx[session](ctx, kv, "session:1", time.Hour)
+	if err != nil || !ok || got != (session{UserID: "u-1", Visits: 1}) {
+		t.Fatalf("GetEx() = %+v, %v, %v", got, ok, err)
+	}
+	if store.ttls["session:1"] != time.Hour {
+		t.Errorf("ttl = %s, want GetEx to extend it", store.ttls["session:1"])
+	}
+
+	if set, err := kv.SetNX(ctx, "session:1", session{UserID: "u-2"}, time.Minute); set || err != nil {
+		t.Errorf("SetNX() of an existing key = %v, %v", set, err)
+	}
+
+	swapped, err := CompareAndSwap(ctx, kv, "session:1", session{UserID: "u-1", Visits: 1}, session{UserID: "u-1", Visits: 2}, 0)
+	if err != nil || !swapped {
+		t.Fatalf("CompareAndSwap() = %v, %v, want swapped", swapped, err)
+	}
+	swapped, err = CompareAndSwap(ctx, kv, "session:1", session{UserID: "u-1", Visits: 1}, session{UserID: "u-1", Visits: 3}, 0)
+	if err != nil || swapped {
+		t.Fatalf("CompareAndSwap() of a stale value = %v, %v", swapped, err)
+	}
+	if got, _, _ := Get[session](ctx, kv, "session:1"); got.Visits != 2 {
+		t.Errorf("visits = %d, want 2", got.Visits)
+	}
+
+	for want := int64(1); want <= 3; want++ {
+		if n, err := kv.Incr(ctx, "hits", 1, time.Minute); err != nil || n != want {
+			t.Fatalf("Incr() = %d, %v, want %d", n, err, want)
+		}
+	}
+	if store.ttls["hits"] != time.Minute {
+		t.Errorf("counter ttl = %s, want 1m", store.ttls["hits"])
+	}
+
+	if n, err := kv.Delete(ctx, "session:1", "missing"); err != nil || n != 1 {
+		t.Errorf("Delete() = %d, %v, want 1", n, err)
+	}
+	if _, ok := store.values["session:1"]; ok {
+		t.Error("session:1 not deleted")
+	}
+
+	store.values["broken"] = "{"
+	if _, ok, err := Get[session](ctx, kv, "broken"); ok || err == nil {
+		t.Errorf("Get() of an undecodable value = %v, %v, want an error", ok, err)
+	}
+
+	reveliotest.AssertCounterValue(t, s, "redis_kv_operations_total", 3, attribute.String("operation", "get"), attribute.String("status", "success"))
+	reveliotest.AssertCounterValue(t, s, "redis_kv_operations_total", 3, attribute.String("operation", "incr"))
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/divikraf/lumos/db/ziredis"
+	"github.com/divikraf/lumos/zihealth"
 	"github.com/go-playground/validator/v10"
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog"
@@ -47,6 +48,7 @@ type fxResult struct {
 	All     Connector
 	Single  SingleConnector
 	Cluster ClusterConnector
+	Checker zihealth.Checker `group:"health.checker"`
 }
 
 var Provider = fx.Provide(
@@ -58,6 +60,7 @@ var Provider = fx.Provide(
 			All:     conn,
 			Single:  conn,
 			Cluster: conn,
+			Checker: zihealth.NewChecker("redis", conn.PingAll),
 		}
 	},
 )
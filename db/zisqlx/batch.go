@@ -0,0 +1,77 @@
+package zisqlx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// maxBindParams is the number of bind parameters of a statement supported by
+// both PostgreSQL and MySQL.
+const maxBindParams = 65535
+
+// BatchInsert inserts rows in Table with multi-row INSERT statements, split
+// to stay under the limit of bind parameters of the databases.
+type BatchInsert struct {
+	Table   string
+	Columns []string
+	// Suffix is appended to the statements, e.g. an upsert clause such as
+	// "ON CONFLICT (sku) DO UPDATE SET price = EXCLUDED.price".
+	Suffix string
+	// BindType is the placeholder style of the database, e.g. sqlx.DOLLAR
+	// for PostgreSQL and sqlx.QUESTION for MySQL.
+	BindType int
+}
+
+// Exec inserts rows, each holding the values of Columns, and returns the
+// number of rows affected. The statements are not run in a transaction: pass
+// a TxInterface as db to insert the rows atomically.
+func (b BatchInsert) Exec(ctx context.Context, db BasicExecuter, operationName string, rows [][]any) (int64, error) {
+	if len(b.Columns) == 0 {
+		return 0, fmt.Errorf("zisqlx: batch insert in %s without columns", b.Table)
+	}
+	per := maxBindParams / len(b.Columns)
+	var affected int64
+	for len(rows) > 0 {
+		n := min(per, len(rows))
+		query, args, err := b.query(rows[:n])
+		if err != nil {
+			return affected, err
+		}
+		result, err := db.ExecContext(ctx, operationName, query, args...)
+		if err != nil {
+			return affected, err
+		}
+		if result != nil {
+			if a, err := result.RowsAffected(); err == nil {
+				affected += a
+			}
+		}
+		rows = rows[n:]
+	}
+	return affected, nil
+}
+
+// query returns the statement inserting rows, and its arguments.
+func (b BatchInsert) query(rows [][]any) (string, []any, error) {
+	placeholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(b.Columns)), ", ") + ")"
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "INSERT INTO %s (%s) VALUES ", b.Table, strings.Join(b.Columns, ", "))
+	args := make([]any, 0, len(rows)*len(b.Columns))
+	for i, r := range rows {
+		if len(r) != len(b.Columns) {
+			return "", nil, fmt.Errorf("zisqlx: batch insert in %s: row %d has %d values for %d columns", b.Table, i, len(r), len(b.Columns))
+		}
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(placeholders)
+		args = append(args, r...)
+	}
+	if b.Suffix != "" {
+		sb.WriteString(" " + b.Suffix)
+	}
+	return sqlx.Rebind(b.BindType, sb.String()), args, nil
+}
@@ -0,0 +1,73 @@
+package zisqlx
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type execRecorder struct {
+	queries []string
+	args    [][]any
+}
+
+func (e *execRecorder) ExecContext(ctx context.Context, operationName string, query string, args ...any) (sql.Result, error) {
+	e.queries = append(e.queries, query)
+	e.args = append(e.args, args)
+	return driverResult(len(args)), nil
+}
+
+// driverResult reports n affected rows.
+type driverResult int64
+
+func (r driverResult) LastInsertId() (int64, error) { return 0, nil }
+func (r driverResult) RowsAffected() (int64, error) { return int64(r), nil }
+
+func TestBatchInsert(t *testing.T) {
+	db := &execRecorder{}
+	b := BatchInsert{
+		Table:    "products",
+		Columns:  []string{"sku", "price"},
+		Suffix:   "ON CONFLICT (sku) DO NOTHING",
+		BindType: sqlx.DOLLAR,
+	}
+	if _, err := b.Exec(context.Background(), db, "insert_products", [][]any{{"a", 1}, {"b", 2}}); err != nil {
+		t.Fatal(err)
+	}
+	want := "INSERT INTO products (sku, price) VALUES ($1, $2), ($3, $4) ON CONFLICT (sku) DO NOTHING"
+	if len(db.queries) != 1 || db.queries[0] != want {
+		t.Fatalf("queries = %q, want %q", db.queries, want)
+	}
+	if len(db.args[0]) != 4 || db.args[0][2] != "b" {
+		t.Errorf("args = %v", db.args[0])
+	}
+}
+
+func TestBatchInsertSplitsStatements(t *testing.T) {
+	db := &execRecorder{}
+	b := BatchInsert{Table: "t", Columns: make([]string, 1000), BindType: sqlx.QUESTION}
+	rows := make([][]any, 100)
+	for i := range rows {
+		rows[i] = make([]any, 1000)
+	}
+	affected, err := b.Exec(context.Background(), db, "insert_t", rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 65 rows of 1000 columns per statement
+	if len(db.queries) != 2 {
+		t.Errorf("statements = %d, want 2", len(db.queries))
+	}
+	if affected != 100000 {
+		t.Errorf("affected = %d, want the arguments of both statements", affected)
+	}
+}
+
+func TestBatchInsertRowLength(t *testing.T) {
+	b := BatchInsert{Table: "t", Columns: []string{"a", "b"}}
+	if _, err := b.Exec(context.Background(), &execRecorder{}, "insert_t", [][]any{{1}}); err == nil {
+		t.Error("expected an error for a short row")
+	}
+}
@@ -0,0 +1,138 @@
+package zisqlx
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithSQLComments appends a sqlcommenter comment to the statements, so slow
+// query logs of the database can be correlated back to traces:
+//
+//	SELECT * FROM users WHERE id = $1 /*route='%2Fusers%2F%3Aid',service='orders',traceparent='00-...-01'*/
+//
+// The comment holds the trace context of the statement span, the service and
+// the route of the context, set by the zin router, or with WithRoute.
+// Statements already ending with a block comment are left as is, the comment
+// goes on a new line after a trailing line comment. Comments make every
+// traced statement unique, which defeats statement caches keyed by the SQL
+// text, e.g. pg_stat_statements before PostgreSQL 14. See CommentConnector
+// to comment the statements of connections not wrapped by a DB, e.g. the ones
+// of zipg and zimysql.
+func WithSQLComments(service string) Option {
+	return func(db *DB) {
+		db.comments = &commenter{service: service}
+	}
+}
+
+// WithRoute returns a copy of ctx with the route, e.g. the HTTP route or the
+// RPC method, added to the comments of statements run with it, see
+// observe.WithRoute.
+func WithRoute(ctx context.Context, route string) context.Context {
+	return observe.WithRoute(ctx, route)
+}
+
+// commenter appends sqlcommenter comments to statements.
+type commenter struct {
+	service string
+}
+
+// annotate returns query with the comment of ctx appended, query when c is
+// nil.
+func (c *commenter) annotate(ctx context.Context, query string) string {
+	if c == nil || query == "" {
+		return query
+	}
+	block, line := trailingComment(query)
+	if block {
+		return query
+	}
+
+	tags := map[string]string{}
+	if c.service != "" {
+		tags["service"] = c.service
+	}
+	if route := observe.RouteFromContext(ctx); route != "" {
+		tags["route"] = route
+	}
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		propagation.TraceContext{}.Inject(ctx, propagation.MapCarrier(tags))
+	}
+	if len(tags) == 0 {
+		return query
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(query)
+	if line {
+		// The comment would be part of the line comment
+		b.WriteString("\n/*")
+	} else {
+		b.WriteString(" /*")
+	}
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(escapeComment(k))
+		b.WriteString("='")
+		b.WriteString(escapeComment(tags[k]))
+		b.WriteByte('\'')
+	}
+	b.WriteString("*/")
+	return b.String()
+}
+
+// trailingComment reports whether query ends with a block comment, or in a
+// line comment, skipping the string literals and quoted identifiers. An
+// unterminated block comment or literal counts as a block comment, for the
+// statement to be left as is.
+func trailingComment(query string) (block, line bool) {
+	query = strings.TrimRightFunc(query, unicode.IsSpace)
+	for i := 0; i < len(query); {
+		switch c := query[i]; {
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return false, true
+			}
+			i += end + 1
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return true, false
+			}
+			i += end + 4
+			if i == len(query) {
+				return true, false
+			}
+		case c == '\'' || c == '"' || c == '`':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				return true, false
+			}
+			i += end + 2
+		default:
+			i++
+		}
+	}
+	return false, false
+}
+
+// escapeComment URL encodes s, as required by sqlcommenter, which also keeps
+// quotes and comment delimiters out of the comment.
+func escapeComment(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
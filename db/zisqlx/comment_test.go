@@ -0,0 +1,98 @@
+package zisqlx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestCommenterAnnotate(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	traced := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	tests := []struct {
+		name      string
+		commenter *commenter
+		ctx       context.Context
+		query     string
+		want      string
+	}{
+		{
+			name:      "disabled",
+			commenter: nil,
+			ctx:       traced,
+			query:     "SELECT 1",
+			want:      "SELECT 1",
+		},
+		{
+			name:      "traced with route",
+			commenter: &commenter{service: "orders"},
+			ctx:       WithRoute(traced, "/users/:id"),
+			query:     "SELECT * FROM users WHERE id = $1",
+			want:      "SELECT * FROM users WHERE id = $1 /*route='%2Fusers%2F%3Aid',service='orders',traceparent='00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01'*/",
+		},
+		{
+			name:      "escaped",
+			commenter: &commenter{service: "it's */ here"},
+			ctx:       context.Background(),
+			query:     "SELECT 1",
+			want:      "SELECT 1 /*service='it%27s%20%2A%2F%20here'*/",
+		},
+		{
+			name:      "already commented",
+			commenter: &commenter{service: "orders"},
+			ctx:       traced,
+			query:     "SELECT 1 /*app='x'*/",
+			want:      "SELECT 1 /*app='x'*/",
+		},
+		{
+			name:      "trailing line comment",
+			commenter: &commenter{service: "orders"},
+			ctx:       context.Background(),
+			query:     "SELECT 1 -- health check",
+			want:      "SELECT 1 -- health check\n/*service='orders'*/",
+		},
+		{
+			name:      "line comment in literal",
+			commenter: &commenter{service: "orders"},
+			ctx:       context.Background(),
+			query:     "SELECT '-- not a comment', '*/'",
+			want:      "SELECT '-- not a comment', '*/' /*service='orders'*/",
+		},
+		{
+			name:      "leading comments",
+			commenter: &commenter{service: "orders"},
+			ctx:       context.Background(),
+			query:     "-- fetch\n/* hint */ SELECT 1",
+			want:      "-- fetch\n/* hint */ SELECT 1 /*service='orders'*/",
+		},
+		{
+			name:      "route of the router",
+			commenter: &commenter{},
+			ctx:       observe.WithRoute(context.Background(), "/orders"),
+			query:     "SELECT 1",
+			want:      "SELECT 1 /*route='%2Forders'*/",
+		},
+		{
+			name:      "no tags",
+			commenter: &commenter{},
+			ctx:       context.Background(),
+			query:     "SELECT 1",
+			want:      "SELECT 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.commenter.annotate(tt.ctx, tt.query); got != tt.want {
+				t.Errorf("annotate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
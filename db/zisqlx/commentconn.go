@@ -0,0 +1,116 @@
+package zisqlx
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+)
+
+// CommentConnector returns connector with the comments of WithSQLComments
+// appended to the statements run on its connections, whether they go through
+// a DB or not, e.g.
+//
+//	db := sqlx.NewDb(sql.OpenDB(zisqlx.CommentConnector(connector, "orders")), "postgres")
+//
+// Statements of a DB with WithSQLComments are commented once.
+func CommentConnector(connector driver.Connector, service string) driver.Connector {
+	return &commentConnector{Connector: connector, comments: &commenter{service: service}}
+}
+
+type commentConnector struct {
+	driver.Connector
+	comments *commenter
+}
+
+func (c *commentConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &commentConn{Conn: conn, comments: c.comments}, nil
+}
+
+// commentConn comments the statements of a driver connection, forwarding
+// the optional interfaces of the driver, and skipping the ones it doesn't
+// implement for database/sql to fall back.
+type commentConn struct {
+	driver.Conn
+	comments *commenter
+}
+
+var (
+	_ driver.ConnPrepareContext = (*commentConn)(nil)
+	_ driver.ExecerContext      = (*commentConn)(nil)
+	_ driver.QueryerContext     = (*commentConn)(nil)
+	_ driver.ConnBeginTx        = (*commentConn)(nil)
+	_ driver.Pinger             = (*commentConn)(nil)
+	_ driver.SessionResetter    = (*commentConn)(nil)
+	_ driver.Validator          = (*commentConn)(nil)
+	_ driver.NamedValueChecker  = (*commentConn)(nil)
+)
+
+func (c *commentConn) Prepare(query string) (driver.Stmt, error) {
+	return c.Conn.Prepare(c.comments.annotate(context.Background(), query))
+}
+
+func (c *commentConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	query = c.comments.annotate(ctx, query)
+	if pc, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return pc.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *commentConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ec, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return ec.ExecContext(ctx, c.comments.annotate(ctx, query), args)
+}
+
+func (c *commentConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	qc, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return qc.QueryContext(ctx, c.comments.annotate(ctx, query), args)
+}
+
+func (c *commentConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if bt, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return bt.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != 0 || opts.ReadOnly {
+		return nil, errors.New("zisqlx: the driver doesn't support transaction options")
+	}
+	return c.Conn.Begin() //nolint:staticcheck // fallback of drivers without BeginTx
+}
+
+func (c *commentConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *commentConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *commentConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *commentConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nc, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return nc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
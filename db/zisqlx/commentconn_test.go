@@ -0,0 +1,93 @@
+package zisqlx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// recordingDriver records the statements run on its connections.
+type recordingDriver struct {
+	queries []string
+}
+
+func (d *recordingDriver) Connect(context.Context) (driver.Conn, error) {
+	return &recordingConn{d}, nil
+}
+func (d *recordingDriver) Driver() driver.Driver            { return d }
+func (d *recordingDriver) Open(string) (driver.Conn, error) { return &recordingConn{d}, nil }
+
+type recordingConn struct{ d *recordingDriver }
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	c.d.queries = append(c.d.queries, query)
+	return recordingStmt{}, nil
+}
+func (c *recordingConn) Close() error              { return nil }
+func (c *recordingConn) Begin() (driver.Tx, error) { return recordingTx{}, nil }
+
+func (c *recordingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.d.queries = append(c.d.queries, query)
+	return driver.RowsAffected(1), nil
+}
+
+type recordingStmt struct{}
+
+func (recordingStmt) Close() error                               { return nil }
+func (recordingStmt) NumInput() int                              { return -1 }
+func (recordingStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(0), nil }
+func (recordingStmt) Query([]driver.Value) (driver.Rows, error)  { return recordingRows{}, nil }
+
+type recordingRows struct{}
+
+func (recordingRows) Columns() []string         { return []string{"n"} }
+func (recordingRows) Close() error              { return nil }
+func (recordingRows) Next([]driver.Value) error { return io.EOF }
+
+type recordingTx struct{}
+
+func (recordingTx) Commit() error   { return nil }
+func (recordingTx) Rollback() error { return nil }
+
+func TestCommentConnector(t *testing.T) {
+	d := &recordingDriver{}
+	db := sql.OpenDB(CommentConnector(d, "orders"))
+	defer db.Close()
+	ctx := WithRoute(context.Background(), "/orders/:id")
+
+	if _, err := db.ExecContext(ctx, "UPDATE orders SET state = $1 -- cancel", "cancelled"); err != nil {
+		t.Fatalf("ExecContext() = %v", err)
+	}
+	// The driver has no QueryerContext, database/sql prepares the query
+	rows, err := db.QueryContext(ctx, "SELECT 1")
+	if err != nil {
+		t.Fatalf("QueryContext() = %v", err)
+	}
+	rows.Close()
+	// Statements of a DB commenting them are commented once
+	w := New(sqlx.NewDb(db, "postgres"), WithSQLComments("orders"))
+	if _, err := w.ExecContext(ctx, "cancel", "DELETE FROM carts"); err != nil {
+		t.Fatalf("ExecContext() = %v", err)
+	}
+	if _, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true}); err == nil {
+		t.Error("BeginTx() = nil, want the error of the options the driver doesn't support")
+	}
+
+	want := []string{
+		"UPDATE orders SET state = $1 -- cancel\n/*route='%2Forders%2F%3Aid',service='orders'*/",
+		"SELECT 1 /*route='%2Forders%2F%3Aid',service='orders'*/",
+		"DELETE FROM carts /*route='%2Forders%2F%3Aid',service='orders'*/",
+	}
+	if len(d.queries) != len(want) {
+		t.Fatalf("queries = %q, want %q", d.queries, want)
+	}
+	for i := range want {
+		if d.queries[i] != want[i] {
+			t.Errorf("query %d = %q, want %q", i, d.queries[i], want[i])
+		}
+	}
+}
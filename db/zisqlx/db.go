@@ -13,29 +13,57 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
+const instrumentationName = "github.com/divikraf/lumos/db/zisqlx"
+
+var (
+	tracer = observe.LibraryTracer(instrumentationName)
+	scope  = revelio.Extend(revelio.Library(instrumentationName))
+)
+
+func init() {
+	revelio.RegisterErrorType(sql.ErrNoRows, "no_rows")
+	revelio.RegisterErrorType(sql.ErrTxDone, "tx_done")
+	revelio.RegisterErrorType(sql.ErrConnDone, "conn_done")
+}
+
 // DB wraps a sqlx.DB to provide metrics and tracing capabilities
 type DB struct {
 	db                *sqlx.DB
 	durationHistogram metric.Int64Histogram
+	resultCounter     revelio.ResultCounter
 	errorCounter      metric.Int64Counter
+	tables            *tableParser
+	comments          *commenter
 }
 
-// New creates a new SQLx wrapper
-func New(db *sqlx.DB) *DB {
-	durationHistogram := revelio.MustInt64Histogram(
+// New creates a new SQLx wrapper. The operations are counted in
+// database_operations_total by status and error type, and the failed ones
+// in database_operation_errors_total as well, by error type. The latter is
+// deprecated, and kept for the dashboards and alerts using it.
+func New(db *sqlx.DB, opts ...Option) *DB {
+	durationHistogram := revelio.Must(scope.Int64Histogram(
 		"database_operation_duration_ms",
 		"Duration of database operations in milliseconds",
 		metric.WithUnit("ms"),
-	)
-	errorCounter := revelio.MustInt64Counter(
+	))
+	resultCounter := revelio.Must(scope.ResultCounter(
+		"database_operations_total",
+		"Number of database operations by status and error type",
+	))
+	errorCounter := revelio.Must(scope.Int64Counter(
 		"database_operation_errors_total",
-		"Number of database operation errors",
-	)
-	return &DB{
+		"Number of database operation errors by error type (deprecated: use database_operations_total)",
+	))
+	w := &DB{
 		db:                db,
 		durationHistogram: durationHistogram,
+		resultCounter:     resultCounter,
 		errorCounter:      errorCounter,
 	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
 }
 
 // Compile-time interface compliance checks
@@ -49,18 +77,15 @@ var (
 func (w *DB) GetContext(ctx context.Context, operationName string, dest interface{}, query string, args ...any) error {
 	start := time.Now()
 
-	span := w.startSpan(ctx, operationName, "get", query)
+	table := w.tables.table(ctx, query)
+	ctx, span := w.startSpan(ctx, operationName, "get", query, table)
 	defer span.End()
 
 	var err error
-	err = w.db.GetContext(ctx, dest, query, args...)
+	err = w.db.GetContext(ctx, dest, w.comments.annotate(ctx, query), args...)
 
 	duration := time.Since(start)
-	w.recordMetrics(ctx, operationName, duration, err)
-
-	if err != nil {
-		w.errorCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("error", err.Error())))
-	}
+	w.recordMetrics(ctx, operationName, table, duration, err)
 
 	return err
 }
@@ -69,18 +94,15 @@ func (w *DB) GetContext(ctx context.Context, operationName string, dest interfac
 func (w *DB) SelectContext(ctx context.Context, operationName string, dest interface{}, query string, args ...any) error {
 	start := time.Now()
 
-	span := w.startSpan(ctx, operationName, "select", query)
+	table := w.tables.table(ctx, query)
+	ctx, span := w.startSpan(ctx, operationName, "select", query, table)
 	defer span.End()
 
 	var err error
-	err = w.db.SelectContext(ctx, dest, query, args...)
+	err = w.db.SelectContext(ctx, dest, w.comments.annotate(ctx, query), args...)
 
 	duration := time.Since(start)
-	w.recordMetrics(ctx, operationName, duration, err)
-
-	if err != nil {
-		w.errorCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("error", err.Error())))
-	}
+	w.recordMetrics(ctx, operationName, table, duration, err)
 
 	return err
 }
@@ -89,20 +111,17 @@ func (w *DB) SelectContext(ctx context.Context, operationName string, dest inter
 func (w *DB) ExecContext(ctx context.Context, operationName string, query string, args ...any) (sql.Result, error) {
 	start := time.Now()
 
-	span := w.startSpan(ctx, operationName, "exec", query)
+	table := w.tables.table(ctx, query)
+	ctx, span := w.startSpan(ctx, operationName, "exec", query, table)
 	defer span.End()
 
 	var result sql.Result
 	var err error
 
-	result, err = w.db.ExecContext(ctx, query, args...)
+	result, err = w.db.ExecContext(ctx, w.comments.annotate(ctx, query), args...)
 
 	duration := time.Since(start)
-	w.recordMetrics(ctx, operationName, duration, err)
-
-	if err != nil {
-		w.errorCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("error", err.Error())))
-	}
+	w.recordMetrics(ctx, operationName, table, duration, err)
 
 	return result, err
 }
@@ -111,26 +130,25 @@ func (w *DB) ExecContext(ctx context.Context, operationName string, query string
 func (w *DB) BeginTx(ctx context.Context, operationName string, opts *sql.TxOptions) (TxInterface, error) {
 	start := time.Now()
 
-	span := w.startSpan(ctx, operationName, "begin_tx", "")
+	_, span := w.startSpan(ctx, operationName, "begin_tx", "", "")
 	defer span.End()
 
 	tx, err := w.db.BeginTxx(ctx, opts)
 	duration := time.Since(start)
 
-	w.recordMetrics(ctx, operationName, duration, err)
+	w.recordMetrics(ctx, operationName, "", duration, err)
 
 	if err != nil {
-		w.errorCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("error", err.Error())))
 		return nil, err
 	}
 
-	return newTx(tx, w.durationHistogram, w.errorCounter), nil
+	return newTx(tx, w.durationHistogram, w.resultCounter, w.errorCounter, w.tables, w.comments), nil
 }
 
 // Helper methods
 
-func (w *DB) startSpan(ctx context.Context, operationName, operation, query string) trace.Span {
-	ctx, span := observe.FromContext(ctx).Start(ctx, operationName+"."+operation)
+func (w *DB) startSpan(ctx context.Context, operationName, operation, query, table string) (context.Context, trace.Span) {
+	ctx, span := tracer.Start(ctx, operationName+"."+operation)
 	span.SetAttributes(
 		attribute.String("db.operation", operation),
 		attribute.String("db.operation_name", operationName),
@@ -139,27 +157,41 @@ func (w *DB) startSpan(ctx context.Context, operationName, operation, query stri
 	if query != "" {
 		span.SetAttributes(attribute.String("db.statement", query))
 	}
+	if table != "" {
+		span.SetAttributes(attribute.String("db.sql.table", table))
+	}
 
-	return span
+	return ctx, span
 }
 
-func (w *DB) recordMetrics(ctx context.Context, operationName string, duration time.Duration, err error) {
-	if w.durationHistogram == nil || w.errorCounter == nil {
+func (w *DB) recordMetrics(ctx context.Context, operationName, table string, duration time.Duration, err error) {
+	if w.durationHistogram == nil || w.resultCounter == nil {
 		return
 	}
 
 	attrs := []attribute.KeyValue{
 		attribute.String("operation_name", operationName),
 	}
-
-	if err != nil {
-		attrs = append(attrs, attribute.String("error", err.Error()))
-		w.errorCounter.Add(ctx, 1, metric.WithAttributes(attrs...))
+	if table != "" {
+		attrs = append(attrs, attribute.String("table", table))
 	}
 
+	w.resultCounter.Record(ctx, err, attrs...)
+	recordError(ctx, w.errorCounter, err, attrs)
+
+	attrs = append(attrs, revelio.ResultAttributes(err)...)
 	w.durationHistogram.Record(ctx, duration.Milliseconds(), metric.WithAttributes(attrs...))
 }
 
+// recordError counts err, if any, in the deprecated errorCounter.
+func recordError(ctx context.Context, errorCounter metric.Int64Counter, err error, attrs []attribute.KeyValue) {
+	if err == nil || errorCounter == nil {
+		return
+	}
+	attrs = append(attrs[:len(attrs):len(attrs)], revelio.ErrorTypeKey.String(revelio.ErrorType(err)))
+	errorCounter.Add(ctx, 1, metric.WithAttributes(attrs...))
+}
+
 // GetDB returns the underlying sqlx.DB for advanced usage
 func (w *DB) GetDB() *sqlx.DB {
 	return w.db
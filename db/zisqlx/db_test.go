@@ -0,0 +1,31 @@
+package zisqlx
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestDBRecordMetrics(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	ctx := context.Background()
+	w := New(nil)
+
+	w.recordMetrics(ctx, "get_user", "users", time.Millisecond, nil)
+	w.recordMetrics(ctx, "get_user", "users", time.Millisecond, sql.ErrNoRows)
+	newTx(nil, w.durationHistogram, w.resultCounter, w.errorCounter, w.tables, w.comments).
+		recordMetrics(ctx, "get_user", "users", time.Millisecond, sql.ErrNoRows)
+
+	reveliotest.AssertCounterValue(t, s, "database_operations_total", 1, attribute.String("operation_name", "get_user"), attribute.String("status", "success"))
+	reveliotest.AssertCounterValue(t, s, "database_operations_total", 2, attribute.String("operation_name", "get_user"), attribute.String("status", "failure"))
+	// The deprecated counter of the errors is still recorded
+	reveliotest.AssertCounterValue(t, s, "database_operation_errors_total", 2,
+		attribute.String("operation_name", "get_user"),
+		attribute.String("table", "users"),
+		attribute.String("error.type", "no_rows"),
+	)
+}
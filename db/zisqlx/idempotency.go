@@ -0,0 +1,141 @@
+package zisqlx
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// DefaultIdempotencyTable is the default dedupe table of Idempotency.
+const DefaultIdempotencyTable = "idempotency_keys"
+
+// Idempotency runs writes at most once per idempotency token, e.g. a message
+// ID, recording the token and the result of the write in a dedupe table, in
+// the same transaction. The tokens are scoped by operation, so operations
+// sharing a token don't replay each other. The table is expected to be:
+//
+//	CREATE TABLE idempotency_keys (
+//		operation       VARCHAR(255) NOT NULL,
+//		idempotency_key VARCHAR(255) NOT NULL,
+//		result          TEXT NOT NULL,
+//		created_at      TIMESTAMP NOT NULL,
+//		PRIMARY KEY (operation, idempotency_key)
+//	);
+type Idempotency struct {
+	db       BasicQueryerExecuter
+	bindType int
+	table    string
+	replays  metric.Int64Counter
+}
+
+// IdempotencyOption configures an Idempotency.
+type IdempotencyOption func(*Idempotency)
+
+// WithIdempotencyTable sets the dedupe table (default:
+// DefaultIdempotencyTable).
+func WithIdempotencyTable(table string) IdempotencyOption {
+	return func(i *Idempotency) {
+		i.table = table
+	}
+}
+
+// NewIdempotency returns an Idempotency recording tokens in db. bindType is
+// the placeholder style of the database, e.g. sqlx.DOLLAR for PostgreSQL and
+// sqlx.QUESTION for MySQL.
+func NewIdempotency(db BasicQueryerExecuter, bindType int, opts ...IdempotencyOption) *Idempotency {
+	i := &Idempotency{
+		db:       db,
+		bindType: bindType,
+		table:    DefaultIdempotencyTable,
+		replays: revelio.Must(scope.Int64Counter(
+			"database_idempotent_replays_total",
+			"Number of idempotent writes replayed, by operation name",
+		)),
+	}
+	for _, o := range opts {
+		o(i)
+	}
+	return i
+}
+
+func (i *Idempotency) query(q string) string {
+	return sqlx.Rebind(i.bindType, fmt.Sprintf(q, i.table))
+}
+
+// RunIdempotent runs fn in a transaction recording key and the result of fn,
+// JSON encoded. When key was already recorded for operationName, fn isn't run
+// and the recorded result is returned, with replayed set. Failed runs aren't
+// recorded, so they can be retried.
+//
+// Concurrent runs of the same key are serialized by the primary key of the
+// table: the loser is rolled back and returns the result of the winner.
+func RunIdempotent[T any](ctx context.Context, i *Idempotency, operationName, key string, fn func(ctx context.Context, tx TxInterface) (T, error)) (result T, replayed bool, err error) {
+	if replayed, err = i.recorded(ctx, operationName, key, &result); err != nil || replayed {
+		return result, replayed, err
+	}
+
+	tx, err := i.db.BeginTx(ctx, operationName, nil)
+	if err != nil {
+		return result, false, err
+	}
+	if result, err = fn(ctx, tx); err != nil {
+		_ = tx.Rollback()
+		return result, false, err
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		_ = tx.Rollback()
+		return result, false, fmt.Errorf("zisqlx: failed to encode the result of %s: %w", operationName, err)
+	}
+	_, err = tx.ExecContext(ctx, operationName+".record_idempotency_key",
+		i.query("INSERT INTO %s (idempotency_key, operation, result, created_at) VALUES (?, ?, ?, ?)"),
+		key, operationName, string(encoded), time.Now().UTC())
+	if err != nil {
+		_ = tx.Rollback()
+		// A concurrent run may have recorded the key first
+		var recorded T
+		if ok, lookupErr := i.recorded(ctx, operationName, key, &recorded); lookupErr == nil && ok {
+			return recorded, true, nil
+		}
+		return result, false, err
+	}
+	return result, false, tx.Commit()
+}
+
+// recorded looks up the result recorded for key by operationName, decoding it
+// into dest.
+func (i *Idempotency) recorded(ctx context.Context, operationName, key string, dest any) (bool, error) {
+	var encoded string
+	err := i.db.GetContext(ctx, operationName+".lookup_idempotency_key", &encoded,
+		i.query("SELECT result FROM %s WHERE operation = ? AND idempotency_key = ?"), operationName, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal([]byte(encoded), dest); err != nil {
+		return false, fmt.Errorf("zisqlx: failed to decode the result of %s: %w", operationName, err)
+	}
+	i.replays.Add(ctx, 1, metric.WithAttributes(attribute.String("operation_name", operationName)))
+	return true, nil
+}
+
+// Purge deletes the keys recorded before olderThan ago, after which they can
+// run again.
+func (i *Idempotency) Purge(ctx context.Context, olderThan time.Duration) (int64, error) {
+	res, err := i.db.ExecContext(ctx, "zisqlx.purge_idempotency_keys",
+		i.query("DELETE FROM %s WHERE created_at < ?"), time.Now().UTC().Add(-olderThan))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,137 @@
+package zisqlx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// keysDB is an in-memory dedupe table, keyed by operation/key, with
+// transactions buffering their inserts until commit.
+type keysDB struct {
+	rows    map[string]string
+	queries []string
+}
+
+func (d *keysDB) GetContext(ctx context.Context, operationName string, dest interface{}, query string, args ...any) error {
+	d.queries = append(d.queries, query)
+	result, ok := d.rows[args[0].(string)+"/"+args[1].(string)]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	*dest.(*string) = result
+	return nil
+}
+
+func (d *keysDB) SelectContext(ctx context.Context, operationName string, dest interface{}, query string, args ...any) error {
+	return nil
+}
+
+func (d *keysDB) ExecContext(ctx context.Context, operationName string, query string, args ...any) (sql.Result, error) {
+	return nil, nil
+}
+
+func (d *keysDB) BeginTx(ctx context.Context, operationName string, opts *sql.TxOptions) (TxInterface, error) {
+	return &keysTx{keysDB: d, pending: map[string]string{}}, nil
+}
+
+type keysTx struct {
+	*keysDB
+	pending map[string]string
+}
+
+func (t *keysTx) ExecContext(ctx context.Context, operationName string, query string, args ...any) (sql.Result, error) {
+	t.queries = append(t.queries, query)
+	if !strings.HasPrefix(query, "INSERT INTO idempotency_keys") {
+		return nil, nil
+	}
+	key := args[1].(string) + "/" + args[0].(string)
+	if _, ok := t.rows[key]; ok {
+		return nil, errors.New("duplicate key")
+	}
+	t.pending[key] = args[2].(string)
+	return nil, nil
+}
+
+func (t *keysTx) Commit() error {
+	for k, v := range t.pending {
+		t.rows[k] = v
+	}
+	return nil
+}
+
+func (t *keysTx) Rollback() error { return nil }
+
+type order struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+func TestRunIdempotent(t *testing.T) {
+	ctx := context.Background()
+	db := &keysDB{rows: map[string]string{}}
+	idem := NewIdempotency(db, sqlx.DOLLAR)
+
+	runs := 0
+	create := func(ctx context.Context, tx TxInterface) (order, error) {
+		runs++
+		if _, err := tx.ExecContext(ctx, "create_order", "INSERT INTO orders (id) VALUES ($1)", "o-1"); err != nil {
+			return order{}, err
+		}
+		return order{ID: "o-1", Status: "created"}, nil
+	}
+
+	got, replayed, err := RunIdempotent(ctx, idem, "create_order", "msg-1", create)
+	if err != nil || replayed || got.ID != "o-1" {
+		t.Fatalf("first run = %+v, %v, %v", got, replayed, err)
+	}
+	got, replayed, err = RunIdempotent(ctx, idem, "create_order", "msg-1", create)
+	if err != nil || !replayed || got != (order{ID: "o-1", Status: "created"}) {
+		t.Fatalf("replay = %+v, %v, %v, want the recorded result", got, replayed, err)
+	}
+	if runs != 1 {
+		t.Errorf("fn ran %d times, want 1", runs)
+	}
+	if q := db.queries[0]; q != "SELECT result FROM idempotency_keys WHERE operation = $1 AND idempotency_key = $2" {
+		t.Errorf("lookup query = %q, want dollar placeholders", q)
+	}
+
+	t.Run("failed runs are not recorded", func(t *testing.T) {
+		failure := errors.New("boom")
+		_, _, err := RunIdempotent(ctx, idem, "create_order", "msg-2", func(context.Context, TxInterface) (order, error) {
+			return order{}, failure
+		})
+		if !errors.Is(err, failure) {
+			t.Fatalf("err = %v, want %v", err, failure)
+		}
+		if _, ok := db.rows["create_order/msg-2"]; ok {
+			t.Error("failed run recorded its key")
+		}
+	})
+
+	t.Run("concurrent run recorded first", func(t *testing.T) {
+		got, replayed, err := RunIdempotent(ctx, idem, "create_order", "msg-3", func(context.Context, TxInterface) (order, error) {
+			// Another instance commits the key while this run is in flight
+			db.rows["create_order/msg-3"] = `{"id":"o-3","status":"created"}`
+			return order{ID: "o-3bis"}, nil
+		})
+		if err != nil || !replayed || got.ID != "o-3" {
+			t.Errorf("RunIdempotent() = %+v, %v, %v, want the winner result", got, replayed, err)
+		}
+	})
+	t.Run("operations sharing a key", func(t *testing.T) {
+		got, replayed, err := RunIdempotent(ctx, idem, "notify_customer", "msg-1", func(context.Context, TxInterface) (order, error) {
+			return order{ID: "o-1", Status: "notified"}, nil
+		})
+		if err != nil || replayed || got.Status != "notified" {
+			t.Errorf("RunIdempotent() = %+v, %v, %v, want a run of its own", got, replayed, err)
+		}
+		if len(db.rows) != 3 {
+			t.Errorf("recorded %d keys, want create_order and notify_customer ones", len(db.rows))
+		}
+	})
+}
@@ -0,0 +1,82 @@
+package zisqlx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrReadOnly is matched, with errors.Is, by the errors returned when writing
+// through a read-only wrapper.
+var ErrReadOnly = errors.New("zisqlx: read-only connection")
+
+// ReadOnlyError is returned when an Exec operation is attempted through a
+// read-only wrapper.
+type ReadOnlyError struct {
+	OperationName string
+	Query         string
+}
+
+func (e *ReadOnlyError) Error() string {
+	return fmt.Sprintf("zisqlx: %s rejected, the connection is read-only", e.OperationName)
+}
+
+// Is makes ReadOnlyError match ErrReadOnly.
+func (e *ReadOnlyError) Is(target error) bool {
+	return target == ErrReadOnly
+}
+
+// ErrorType implements revelio.ErrorTyper.
+func (e *ReadOnlyError) ErrorType() string {
+	return "read_only"
+}
+
+// readOnly wraps a BasicQueryerExecuter, rejecting every Exec operation.
+type readOnly struct {
+	db BasicQueryerExecuter
+}
+
+// ReadOnly wraps db so Exec operations are rejected with a *ReadOnlyError,
+// before reaching the database. Transactions are started read-only and
+// reject Exec operations as well.
+//
+// The database session should be read-only too, e.g. with
+// zimysql.Input.ReadOnly, since queries run through GetContext and
+// SelectContext are not inspected.
+func ReadOnly(db BasicQueryerExecuter) BasicQueryerExecuter {
+	return &readOnly{db: db}
+}
+
+func (r *readOnly) GetContext(ctx context.Context, operationName string, dest interface{}, query string, args ...any) error {
+	return r.db.GetContext(ctx, operationName, dest, query, args...)
+}
+
+func (r *readOnly) SelectContext(ctx context.Context, operationName string, dest interface{}, query string, args ...any) error {
+	return r.db.SelectContext(ctx, operationName, dest, query, args...)
+}
+
+func (r *readOnly) ExecContext(ctx context.Context, operationName string, query string, args ...any) (sql.Result, error) {
+	return nil, &ReadOnlyError{OperationName: operationName, Query: query}
+}
+
+func (r *readOnly) BeginTx(ctx context.Context, operationName string, opts *sql.TxOptions) (TxInterface, error) {
+	txOpts := sql.TxOptions{ReadOnly: true}
+	if opts != nil {
+		txOpts.Isolation = opts.Isolation
+	}
+	tx, err := r.db.BeginTx(ctx, operationName, &txOpts)
+	if err != nil {
+		return nil, err
+	}
+	return &readOnlyTx{TxInterface: tx}, nil
+}
+
+// readOnlyTx wraps a TxInterface, rejecting every Exec operation.
+type readOnlyTx struct {
+	TxInterface
+}
+
+func (t *readOnlyTx) ExecContext(ctx context.Context, operationName string, query string, args ...any) (sql.Result, error) {
+	return nil, &ReadOnlyError{OperationName: operationName, Query: query}
+}
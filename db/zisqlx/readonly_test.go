@@ -0,0 +1,73 @@
+package zisqlx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+)
+
+type fakeDB struct {
+	execs  int
+	gets   int
+	txOpts *sql.TxOptions
+}
+
+func (f *fakeDB) GetContext(ctx context.Context, operationName string, dest interface{}, query string, args ...any) error {
+	f.gets++
+	return nil
+}
+
+func (f *fakeDB) SelectContext(ctx context.Context, operationName string, dest interface{}, query string, args ...any) error {
+	return nil
+}
+
+func (f *fakeDB) ExecContext(ctx context.Context, operationName string, query string, args ...any) (sql.Result, error) {
+	f.execs++
+	return nil, nil
+}
+
+func (f *fakeDB) BeginTx(ctx context.Context, operationName string, opts *sql.TxOptions) (TxInterface, error) {
+	f.txOpts = opts
+	return &fakeTx{fakeDB: f}, nil
+}
+
+type fakeTx struct{ *fakeDB }
+
+func (f *fakeTx) Commit() error   { return nil }
+func (f *fakeTx) Rollback() error { return nil }
+
+func TestReadOnly(t *testing.T) {
+	ctx := context.Background()
+	db := &fakeDB{}
+	ro := ReadOnly(db)
+
+	if err := ro.GetContext(ctx, "get_user", nil, "SELECT * FROM users"); err != nil || db.gets != 1 {
+		t.Errorf("GetContext() = %v, want reads to go through", err)
+	}
+
+	_, err := ro.ExecContext(ctx, "delete_user", "DELETE FROM users")
+	var roErr *ReadOnlyError
+	if !errors.Is(err, ErrReadOnly) || !errors.As(err, &roErr) || roErr.OperationName != "delete_user" {
+		t.Errorf("ExecContext() = %v, want a *ReadOnlyError", err)
+	}
+	if got := revelio.ErrorType(err); got != "read_only" {
+		t.Errorf("ErrorType() = %q, want read_only", got)
+	}
+
+	tx, err := ro.BeginTx(ctx, "report", &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		t.Fatalf("BeginTx() error = %v", err)
+	}
+	if !db.txOpts.ReadOnly || db.txOpts.Isolation != sql.LevelRepeatableRead {
+		t.Errorf("TxOptions = %+v, want a read-only transaction keeping the isolation level", db.txOpts)
+	}
+	if _, err := tx.ExecContext(ctx, "update_user", "UPDATE users SET name = ''"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("tx.ExecContext() = %v, want ErrReadOnly", err)
+	}
+	if db.execs != 0 {
+		t.Errorf("%d Exec operations reached the database", db.execs)
+	}
+}
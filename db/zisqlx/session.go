@@ -0,0 +1,135 @@
+package zisqlx
+
+import (
+	"context"
+	"sync"
+)
+
+// Session tracks the writes of a user-facing flow, so its reads through a
+// Splitter with session consistency read its writes. Its token, the
+// replication position of its last write, can be handed to the client, e.g.
+// in a cookie, to carry the session across requests.
+type Session struct {
+	mu       sync.Mutex
+	token    string
+	pinned   bool
+	replicas map[int]string
+}
+
+type sessionKey struct{}
+
+// NewSession returns ctx with a new Session, resumed from token, the Token of
+// a previous session, if not empty.
+func NewSession(ctx context.Context, token string) (context.Context, *Session) {
+	s := &Session{token: token, replicas: map[int]string{}}
+	return context.WithValue(ctx, sessionKey{}, s), s
+}
+
+// SessionFromContext returns the Session of ctx, nil if none.
+func SessionFromContext(ctx context.Context) *Session {
+	s, _ := ctx.Value(sessionKey{}).(*Session)
+	return s
+}
+
+// Token returns the replication position of the last write of the session,
+// empty before its first write.
+func (s *Session) Token() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token
+}
+
+// state returns the position the reads of the session must see, and whether
+// they must read from the primary.
+func (s *Session) state() (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token, s.pinned
+}
+
+// wrote records the position of a write of the session.
+func (s *Session) wrote(position string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = position
+}
+
+// pin makes the session read from the primary, when the position of a write
+// is unknown.
+func (s *Session) pin() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pinned = true
+}
+
+// reached records that replica has replayed position. Positions only move
+// forward, so the replica keeps being up to date with it.
+func (s *Session) reached(replica int, position string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replicas[replica] = position
+}
+
+// hasReached reports whether replica is known to have replayed position.
+func (s *Session) hasReached(replica int, position string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.replicas[replica] == position
+}
+
+// Positioner reads the replication positions of a database and its
+// replicas.
+type Positioner interface {
+	// Position returns the current position of the primary.
+	Position(ctx context.Context, primary BasicQueryer) (string, error)
+	// Reached reports whether replica has replayed position.
+	Reached(ctx context.Context, replica BasicQueryer, position string) (bool, error)
+}
+
+type postgresPositions struct{}
+
+// PostgresPositions returns the Positioner of PostgreSQL streaming
+// replication, comparing the WAL LSN of the primary with the replay LSN of
+// the replicas.
+func PostgresPositions() Positioner {
+	return postgresPositions{}
+}
+
+func (postgresPositions) Position(ctx context.Context, primary BasicQueryer) (string, error) {
+	var lsn string
+	err := primary.GetContext(ctx, "zisqlx.replication_position", &lsn, "SELECT pg_current_wal_lsn()::text")
+	return lsn, err
+}
+
+func (postgresPositions) Reached(ctx context.Context, replica BasicQueryer, position string) (bool, error) {
+	var reached bool
+	// A server out of recovery, without replay LSN, is up to date
+	err := replica.GetContext(ctx, "zisqlx.replica_caught_up", &reached,
+		"SELECT COALESCE(pg_last_wal_replay_lsn() >= $1::pg_lsn, true)", position)
+	return reached, err
+}
+
+type mysqlPositions struct{}
+
+// MySQLPositions returns the Positioner of MySQL GTID replication, checking
+// that the GTIDs executed by the primary are a subset of the ones executed
+// by the replicas.
+func MySQLPositions() Positioner {
+	return mysqlPositions{}
+}
+
+func (mysqlPositions) Position(ctx context.Context, primary BasicQueryer) (string, error) {
+	var gtids string
+	err := primary.GetContext(ctx, "zisqlx.replication_position", &gtids, "SELECT @@GLOBAL.gtid_executed")
+	return gtids, err
+}
+
+func (mysqlPositions) Reached(ctx context.Context, replica BasicQueryer, position string) (bool, error) {
+	var reached bool
+	err := replica.GetContext(ctx, "zisqlx.replica_caught_up", &reached,
+		"SELECT GTID_SUBSET(?, @@GLOBAL.gtid_executed)", position)
+	return reached, err
+}
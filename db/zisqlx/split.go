@@ -0,0 +1,167 @@
+package zisqlx
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Splitter is a BasicQueryerExecuter splitting the reads from the writes: the
+// queries run on the replicas, in turn, and the Exec operations and the
+// transactions on the primary.
+//
+// With WithSessionConsistency, the reads of a Session, see NewSession, read
+// their writes: after a write, the session records the replication position
+// of the primary, and its reads go to the replicas which have replayed it, or
+// to the primary when every replica lags behind.
+type Splitter struct {
+	primary   BasicQueryerExecuter
+	replicas  []BasicQueryerExecuter
+	positions Positioner
+	next      atomic.Uint64
+	reads     metric.Int64Counter
+}
+
+// SplitterOption configures a Splitter.
+type SplitterOption func(*Splitter)
+
+// WithSessionConsistency makes the reads of sessions read their writes,
+// with the replication positions of positions, e.g. PostgresPositions or
+// MySQLPositions.
+func WithSessionConsistency(positions Positioner) SplitterOption {
+	return func(s *Splitter) {
+		s.positions = positions
+	}
+}
+
+// NewSplitter returns a Splitter of primary and its replicas. Without
+// replicas, everything runs on the primary. The reads are counted in
+// database_split_reads_total by target, primary or replica.
+func NewSplitter(primary BasicQueryerExecuter, replicas []BasicQueryerExecuter, opts ...SplitterOption) *Splitter {
+	s := &Splitter{
+		primary:  primary,
+		replicas: replicas,
+		reads: revelio.Must(scope.Int64Counter(
+			"database_split_reads_total",
+			"Number of reads of read/write splitters, by operation name and target (primary or replica)",
+		)),
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+// Compile-time interface compliance check
+var _ BasicQueryerExecuter = (*Splitter)(nil)
+
+func (s *Splitter) GetContext(ctx context.Context, operationName string, dest interface{}, query string, args ...any) error {
+	return s.reader(ctx, operationName).GetContext(ctx, operationName, dest, query, args...)
+}
+
+func (s *Splitter) SelectContext(ctx context.Context, operationName string, dest interface{}, query string, args ...any) error {
+	return s.reader(ctx, operationName).SelectContext(ctx, operationName, dest, query, args...)
+}
+
+func (s *Splitter) ExecContext(ctx context.Context, operationName string, query string, args ...any) (sql.Result, error) {
+	res, err := s.primary.ExecContext(ctx, operationName, query, args...)
+	if err == nil {
+		s.track(ctx)
+	}
+	return res, err
+}
+
+func (s *Splitter) BeginTx(ctx context.Context, operationName string, opts *sql.TxOptions) (TxInterface, error) {
+	tx, err := s.primary.BeginTx(ctx, operationName, opts)
+	if err != nil {
+		return nil, err
+	}
+	if s.positions == nil || SessionFromContext(ctx) == nil {
+		return tx, nil
+	}
+	return &trackedTx{TxInterface: tx, ctx: ctx, splitter: s}, nil
+}
+
+// reader returns the database the read operationName runs on.
+func (s *Splitter) reader(ctx context.Context, operationName string) BasicQueryer {
+	if len(s.replicas) == 0 {
+		return s.primary
+	}
+	n := uint64(len(s.replicas))
+	start := s.next.Add(1)
+	session := SessionFromContext(ctx)
+	position, pinned := session.state()
+	if s.positions != nil && pinned {
+		s.count(ctx, operationName, "primary")
+		return s.primary
+	}
+	if s.positions == nil || position == "" {
+		s.count(ctx, operationName, "replica")
+		return s.replicas[start%n]
+	}
+
+	for i := range n {
+		replica := int((start + i) % n)
+		if session.hasReached(replica, position) {
+			s.count(ctx, operationName, "replica")
+			return s.replicas[replica]
+		}
+		ok, err := s.positions.Reached(ctx, s.replicas[replica], position)
+		if err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Int("replica", replica).Msg("Failed to check the replication position of a replica")
+			continue
+		}
+		if ok {
+			session.reached(replica, position)
+			s.count(ctx, operationName, "replica")
+			return s.replicas[replica]
+		}
+	}
+	s.count(ctx, operationName, "primary")
+	return s.primary
+}
+
+func (s *Splitter) count(ctx context.Context, operationName, target string) {
+	s.reads.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("operation_name", operationName),
+		attribute.String("target", target),
+	))
+}
+
+// track records the replication position of the primary in the session of
+// ctx, after a write.
+func (s *Splitter) track(ctx context.Context) {
+	session := SessionFromContext(ctx)
+	if s.positions == nil || session == nil {
+		return
+	}
+	position, err := s.positions.Position(ctx, s.primary)
+	if err != nil {
+		// The session reads from the primary rather than risking stale reads
+		zerolog.Ctx(ctx).Warn().Err(err).Msg("Failed to read the replication position of the primary")
+		session.pin()
+		return
+	}
+	session.wrote(position)
+}
+
+// trackedTx records the replication position in the session of its context
+// once committed.
+type trackedTx struct {
+	TxInterface
+	ctx      context.Context
+	splitter *Splitter
+}
+
+func (t *trackedTx) Commit() error {
+	if err := t.TxInterface.Commit(); err != nil {
+		return err
+	}
+	t.splitter.track(t.ctx)
+	return nil
+}
@@ -0,0 +1,163 @@
+package zisqlx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// splitDB is a database of a Splitter, recording the operations it runs.
+type splitDB struct {
+	name       string
+	operations []string
+	commits    int
+}
+
+func (d *splitDB) GetContext(ctx context.Context, operationName string, dest interface{}, query string, args ...any) error {
+	d.operations = append(d.operations, operationName)
+	return nil
+}
+
+func (d *splitDB) SelectContext(ctx context.Context, operationName string, dest interface{}, query string, args ...any) error {
+	d.operations = append(d.operations, operationName)
+	return nil
+}
+
+func (d *splitDB) ExecContext(ctx context.Context, operationName string, query string, args ...any) (sql.Result, error) {
+	d.operations = append(d.operations, operationName)
+	return nil, nil
+}
+
+func (d *splitDB) BeginTx(ctx context.Context, operationName string, opts *sql.TxOptions) (TxInterface, error) {
+	d.operations = append(d.operations, operationName)
+	return &splitTx{splitDB: d}, nil
+}
+
+type splitTx struct {
+	*splitDB
+}
+
+func (t *splitTx) Commit() error   { t.commits++; return nil }
+func (t *splitTx) Rollback() error { return nil }
+
+// fakePositions is a Positioner of a primary at position, and replicas at
+// the positions of replayed.
+type fakePositions struct {
+	position    string
+	positionErr error
+	replayed    map[BasicQueryer]string
+	checks      int
+}
+
+func (p *fakePositions) Position(ctx context.Context, primary BasicQueryer) (string, error) {
+	return p.position, p.positionErr
+}
+
+func (p *fakePositions) Reached(ctx context.Context, replica BasicQueryer, position string) (bool, error) {
+	p.checks++
+	return p.replayed[replica] >= position, nil
+}
+
+func TestSplitter(t *testing.T) {
+	ctx := context.Background()
+	primary, r1, r2 := &splitDB{name: "primary"}, &splitDB{name: "r1"}, &splitDB{name: "r2"}
+	s := NewSplitter(primary, []BasicQueryerExecuter{r1, r2})
+
+	for range 4 {
+		if err := s.GetContext(ctx, "get_user", nil, "SELECT 1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.ExecContext(ctx, "update_user", "UPDATE users SET name = 'a'"); err != nil {
+		t.Fatal(err)
+	}
+	if len(r1.operations) != 2 || len(r2.operations) != 2 {
+		t.Errorf("replica reads = %d and %d, want 2 each", len(r1.operations), len(r2.operations))
+	}
+	if len(primary.operations) != 1 || primary.operations[0] != "update_user" {
+		t.Errorf("primary operations = %v, want [update_user]", primary.operations)
+	}
+}
+
+func TestSplitterSessionConsistency(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	primary, r1, r2 := &splitDB{name: "primary"}, &splitDB{name: "r1"}, &splitDB{name: "r2"}
+	positions := &fakePositions{position: "0/10", replayed: map[BasicQueryer]string{r1: "0/05", r2: "0/05"}}
+	splitter := NewSplitter(primary, []BasicQueryerExecuter{r1, r2}, WithSessionConsistency(positions))
+
+	ctx, session := NewSession(context.Background(), "")
+	// Before writing, the session reads from any replica
+	if err := splitter.GetContext(ctx, "get_cart", nil, "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	if positions.checks != 0 {
+		t.Errorf("checked %d replicas before the first write", positions.checks)
+	}
+
+	tx, err := splitter.BeginTx(ctx, "add_item", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if session.Token() != "0/10" {
+		t.Fatalf("token = %q, want 0/10", session.Token())
+	}
+
+	// Both replicas lag behind the write
+	primaryReads := len(primary.operations)
+	if err := splitter.SelectContext(ctx, "list_items", nil, "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(primary.operations) != primaryReads+1 {
+		t.Error("stale replicas read instead of the primary")
+	}
+
+	// r2 caught up
+	positions.replayed[r2] = "0/10"
+	reads := len(r2.operations)
+	for range 2 {
+		if err := splitter.SelectContext(ctx, "list_items", nil, "SELECT 1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(r2.operations) != reads+2 {
+		t.Errorf("r2 reads = %d, want %d", len(r2.operations), reads+2)
+	}
+
+	// Another request of the session, resumed from its token, and of
+	// another session
+	resumed, _ := NewSession(context.Background(), session.Token())
+	positions.replayed[r2] = "0/05"
+	if err := splitter.GetContext(resumed, "get_cart", nil, "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := splitter.GetContext(context.Background(), "get_cart", nil, "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	reveliotest.AssertCounterValue(t, s, "database_split_reads_total", 2, attribute.String("target", "primary"))
+	reveliotest.AssertCounterValue(t, s, "database_split_reads_total", 4, attribute.String("target", "replica"))
+}
+
+func TestSplitterSessionWithoutPosition(t *testing.T) {
+	primary, r1 := &splitDB{name: "primary"}, &splitDB{name: "r1"}
+	positions := &fakePositions{positionErr: errors.New("permission denied")}
+	splitter := NewSplitter(primary, []BasicQueryerExecuter{r1}, WithSessionConsistency(positions))
+
+	ctx, _ := NewSession(context.Background(), "")
+	if _, err := splitter.ExecContext(ctx, "add_item", "INSERT INTO items VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := splitter.GetContext(ctx, "get_cart", nil, "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(r1.operations) != 0 || len(primary.operations) != 2 {
+		t.Errorf("primary operations = %v, replica operations = %v, want every operation on the primary", primary.operations, r1.operations)
+	}
+}
@@ -0,0 +1,166 @@
+package zisqlx
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// maxCachedTables caps the number of queries whose table is cached.
+const maxCachedTables = 4096
+
+// Option configures a DB.
+type Option func(*DB)
+
+// WithTableParsing tags spans and metrics with the primary table of each
+// query, parsed from the SQL, see ParseTable. Tables given with WithTable
+// take precedence.
+func WithTableParsing() Option {
+	return func(db *DB) {
+		db.tables = &tableParser{}
+	}
+}
+
+type tableKey struct{}
+
+// WithTable returns a copy of ctx hinting that queries run with it touch
+// table, which is added to their spans and metrics. It is useful for queries
+// ParseTable can't handle, or when table parsing is disabled.
+func WithTable(ctx context.Context, table string) context.Context {
+	return context.WithValue(ctx, tableKey{}, table)
+}
+
+// tableParser caches the tables parsed from queries, which are mostly
+// constants.
+type tableParser struct {
+	cache sync.Map
+	size  int
+	mu    sync.Mutex
+}
+
+// table returns the table hinted in ctx, or parsed from query when parsing is
+// enabled.
+func (p *tableParser) table(ctx context.Context, query string) string {
+	if table, ok := ctx.Value(tableKey{}).(string); ok {
+		return table
+	}
+	if p == nil || query == "" {
+		return ""
+	}
+	if table, ok := p.cache.Load(query); ok {
+		return table.(string)
+	}
+
+	table := ParseTable(query)
+	p.mu.Lock()
+	if p.size < maxCachedTables {
+		p.cache.Store(query, table)
+		p.size++
+	}
+	p.mu.Unlock()
+	return table
+}
+
+// ParseTable returns the primary table of a SQL statement: the first table
+// selected or deleted from, inserted into, or updated. It returns an empty
+// string when no table is found, e.g. for sub-queries in FROM clauses.
+// Comments are ignored, quotes are removed and schema prefixes are kept.
+func ParseTable(query string) string {
+	tokens := tokenizeSQL(query)
+	if len(tokens) == 0 {
+		return ""
+	}
+
+	var keyword string
+	switch strings.ToLower(tokens[0]) {
+	case "select", "delete", "with":
+		keyword = "from"
+	case "insert", "replace":
+		keyword = "into"
+	case "update":
+		return tableName(tokens[1:])
+	default:
+		return ""
+	}
+
+	depth := 0
+	for i, tok := range tokens {
+		switch tok {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		default:
+			if depth == 0 && strings.EqualFold(tok, keyword) {
+				return tableName(tokens[i+1:])
+			}
+		}
+	}
+	return ""
+}
+
+// tableName returns the table name starting tokens, skipping modifiers.
+func tableName(tokens []string) string {
+	for _, tok := range tokens {
+		switch strings.ToLower(tok) {
+		case "only", "low_priority", "ignore", "quick":
+			continue
+		case "(":
+			return ""
+		}
+		return strings.NewReplacer(`"`, "", "`", "", "[", "", "]", "").Replace(tok)
+	}
+	return ""
+}
+
+// tokenizeSQL splits query into words, quoted identifiers and parentheses,
+// skipping comments, string literals and punctuation.
+func tokenizeSQL(query string) []string {
+	var tokens []string
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return tokens
+			}
+			i += end + 1
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+			i += end + 4
+		case c == '\'':
+			end := strings.IndexByte(query[i+1:], '\'')
+			if end < 0 {
+				return tokens
+			}
+			i += end + 2
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case isIdentChar(c) || c == '"' || c == '`' || c == '[':
+			start := i
+			for i < len(query) && (isIdentChar(query[i]) || strings.IndexByte("\"`[].", query[i]) >= 0) {
+				if q := query[i]; q == '"' || q == '`' {
+					// Quoted identifiers may contain any character
+					if end := strings.IndexByte(query[i+1:], q); end >= 0 {
+						i += end + 1
+					}
+				}
+				i++
+			}
+			tokens = append(tokens, query[start:i])
+		default:
+			i++
+		}
+	}
+	return tokens
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
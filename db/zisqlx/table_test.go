@@ -0,0 +1,56 @@
+package zisqlx
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseTable(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT id, name FROM users WHERE id = $1", "users"},
+		{"select count(*) from public.orders o join users u on u.id = o.user_id", "public.orders"},
+		{`SELECT * FROM "Order Items"`, "Order Items"},
+		{"SELECT * FROM `orders` LIMIT 1", "orders"},
+		{"SELECT (SELECT max(id) FROM audit) AS m FROM events", "events"},
+		{"-- fetch\n/* from comments */ SELECT 'from literal' FROM accounts", "accounts"},
+		{"WITH recent AS (SELECT * FROM orders) SELECT * FROM recent", "recent"},
+		{"SELECT * FROM (SELECT 1) sub", ""},
+		{"INSERT INTO orders (id) VALUES ($1)", "orders"},
+		{"INSERT IGNORE INTO events SET id = ?", "events"},
+		{"UPDATE ONLY users SET name = $1", "users"},
+		{"DELETE FROM sessions WHERE expires_at < now()", "sessions"},
+		{"SELECT 1", ""},
+		{"VACUUM", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := ParseTable(tt.query); got != tt.want {
+			t.Errorf("ParseTable(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestTableParser(t *testing.T) {
+	var disabled *tableParser
+	if got := disabled.table(context.Background(), "SELECT * FROM users"); got != "" {
+		t.Errorf("table() = %q, want no table when parsing is disabled", got)
+	}
+
+	ctx := WithTable(context.Background(), "accounts")
+	if got := disabled.table(ctx, "SELECT * FROM users"); got != "accounts" {
+		t.Errorf("table() = %q, want the hinted table", got)
+	}
+
+	p := &tableParser{}
+	for range 2 {
+		if got := p.table(context.Background(), "SELECT * FROM users"); got != "users" {
+			t.Errorf("table() = %q, want users", got)
+		}
+	}
+	if got := p.table(ctx, "SELECT * FROM users"); got != "accounts" {
+		t.Errorf("table() = %q, hints should take precedence over parsing", got)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"time"
 
+	"github.com/divikraf/lumos/zitelemetry/revelio"
 	"github.com/jmoiron/sqlx"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
@@ -15,15 +16,21 @@ import (
 type TxWrapper struct {
 	tx                *sqlx.Tx
 	durationHistogram metric.Int64Histogram
+	resultCounter     revelio.ResultCounter
 	errorCounter      metric.Int64Counter
+	tables            *tableParser
+	comments          *commenter
 }
 
 // newTx creates a new transaction wrapper
-func newTx(tx *sqlx.Tx, durationHistogram metric.Int64Histogram, errorCounter metric.Int64Counter) *TxWrapper {
+func newTx(tx *sqlx.Tx, durationHistogram metric.Int64Histogram, resultCounter revelio.ResultCounter, errorCounter metric.Int64Counter, tables *tableParser, comments *commenter) *TxWrapper {
 	return &TxWrapper{
 		tx:                tx,
 		durationHistogram: durationHistogram,
+		resultCounter:     resultCounter,
 		errorCounter:      errorCounter,
+		tables:            tables,
+		comments:          comments,
 	}
 }
 
@@ -38,14 +45,15 @@ var (
 func (t *TxWrapper) GetContext(ctx context.Context, operationName string, dest interface{}, query string, args ...any) error {
 	start := time.Now()
 
-	span := t.startSpan(ctx, operationName, "get", query)
+	table := t.tables.table(ctx, query)
+	ctx, span := t.startSpan(ctx, operationName, "get", query, table)
 	defer span.End()
 
 	var err error
-	err = t.tx.GetContext(ctx, dest, query, args...)
+	err = t.tx.GetContext(ctx, dest, t.comments.annotate(ctx, query), args...)
 
 	duration := time.Since(start)
-	t.recordMetrics(ctx, operationName, duration, err)
+	t.recordMetrics(ctx, operationName, table, duration, err)
 	t.logQuery(ctx, operationName, query, args, duration, err)
 
 	return err
@@ -55,14 +63,15 @@ func (t *TxWrapper) GetContext(ctx context.Context, operationName string, dest i
 func (t *TxWrapper) SelectContext(ctx context.Context, operationName string, dest interface{}, query string, args ...any) error {
 	start := time.Now()
 
-	span := t.startSpan(ctx, operationName, "select", query)
+	table := t.tables.table(ctx, query)
+	ctx, span := t.startSpan(ctx, operationName, "select", query, table)
 	defer span.End()
 
 	var err error
-	err = t.tx.SelectContext(ctx, dest, query, args...)
+	err = t.tx.SelectContext(ctx, dest, t.comments.annotate(ctx, query), args...)
 
 	duration := time.Since(start)
-	t.recordMetrics(ctx, operationName, duration, err)
+	t.recordMetrics(ctx, operationName, table, duration, err)
 	t.logQuery(ctx, operationName, query, args, duration, err)
 
 	return err
@@ -72,16 +81,17 @@ func (t *TxWrapper) SelectContext(ctx context.Context, operationName string, des
 func (t *TxWrapper) ExecContext(ctx context.Context, operationName string, query string, args ...any) (sql.Result, error) {
 	start := time.Now()
 
-	span := t.startSpan(ctx, operationName, "exec", query)
+	table := t.tables.table(ctx, query)
+	ctx, span := t.startSpan(ctx, operationName, "exec", query, table)
 	defer span.End()
 
 	var result sql.Result
 	var err error
 
-	result, err = t.tx.ExecContext(ctx, query, args...)
+	result, err = t.tx.ExecContext(ctx, t.comments.annotate(ctx, query), args...)
 
 	duration := time.Since(start)
-	t.recordMetrics(ctx, operationName, duration, err)
+	t.recordMetrics(ctx, operationName, table, duration, err)
 	t.logQuery(ctx, operationName, query, args, duration, err)
 
 	return result, err
@@ -91,13 +101,13 @@ func (t *TxWrapper) ExecContext(ctx context.Context, operationName string, query
 func (t *TxWrapper) Commit() error {
 	start := time.Now()
 
-	span := t.startSpan(context.Background(), "commit", "tx_commit", "")
+	_, span := t.startSpan(context.Background(), "commit", "tx_commit", "", "")
 	defer span.End()
 
 	err := t.tx.Commit()
 	duration := time.Since(start)
 
-	t.recordMetrics(context.Background(), "commit", duration, err)
+	t.recordMetrics(context.Background(), "commit", "", duration, err)
 	t.logOperation(context.Background(), "commit", "tx_commit", duration, err)
 
 	return err
@@ -107,13 +117,13 @@ func (t *TxWrapper) Commit() error {
 func (t *TxWrapper) Rollback() error {
 	start := time.Now()
 
-	span := t.startSpan(context.Background(), "rollback", "tx_rollback", "")
+	_, span := t.startSpan(context.Background(), "rollback", "tx_rollback", "", "")
 	defer span.End()
 
 	err := t.tx.Rollback()
 	duration := time.Since(start)
 
-	t.recordMetrics(context.Background(), "rollback", duration, err)
+	t.recordMetrics(context.Background(), "rollback", "", duration, err)
 	t.logOperation(context.Background(), "rollback", "tx_rollback", duration, err)
 
 	return err
@@ -126,12 +136,7 @@ func (t *TxWrapper) GetTx() *sqlx.Tx {
 
 // Helper methods
 
-func (t *TxWrapper) startSpan(ctx context.Context, operationName, operation, query string) trace.Span {
-	tracer := trace.SpanFromContext(ctx).TracerProvider()
-	if tracer == nil {
-		return trace.SpanFromContext(ctx)
-	}
-
+func (t *TxWrapper) startSpan(ctx context.Context, operationName, operation, query, table string) (context.Context, trace.Span) {
 	// Get service name from context logger if available
 	serviceName := "unknown"
 	// Try to get service name from span attributes if available
@@ -142,7 +147,7 @@ func (t *TxWrapper) startSpan(ctx context.Context, operationName, operation, que
 	}
 
 	spanName := serviceName + "." + operationName + "." + operation
-	ctx, span := tracer.Tracer("zisqlx").Start(ctx, spanName)
+	ctx, span := tracer.Start(ctx, spanName)
 
 	span.SetAttributes(
 		attribute.String("db.operation", operation),
@@ -153,12 +158,15 @@ func (t *TxWrapper) startSpan(ctx context.Context, operationName, operation, que
 	if query != "" {
 		span.SetAttributes(attribute.String("db.statement", query))
 	}
+	if table != "" {
+		span.SetAttributes(attribute.String("db.sql.table", table))
+	}
 
-	return span
+	return ctx, span
 }
 
-func (t *TxWrapper) recordMetrics(ctx context.Context, operationName string, duration time.Duration, err error) {
-	if t.durationHistogram == nil || t.errorCounter == nil {
+func (t *TxWrapper) recordMetrics(ctx context.Context, operationName, table string, duration time.Duration, err error) {
+	if t.durationHistogram == nil || t.resultCounter == nil {
 		return
 	}
 
@@ -166,12 +174,14 @@ func (t *TxWrapper) recordMetrics(ctx context.Context, operationName string, dur
 		attribute.String("operation_name", operationName),
 		attribute.Bool("transaction", true),
 	}
-
-	if err != nil {
-		attrs = append(attrs, attribute.String("error", err.Error()))
-		t.errorCounter.Add(ctx, 1, metric.WithAttributes(attrs...))
+	if table != "" {
+		attrs = append(attrs, attribute.String("table", table))
 	}
 
+	t.resultCounter.Record(ctx, err, attrs...)
+	recordError(ctx, t.errorCounter, err, attrs)
+
+	attrs = append(attrs, revelio.ResultAttributes(err)...)
 	t.durationHistogram.Record(ctx, duration.Milliseconds(), metric.WithAttributes(attrs...))
 }
 
@@ -0,0 +1,29 @@
+package i18n
+
+import (
+	"golang.org/x/text/language"
+)
+
+// Matcher matches requested languages against a set of supported ones, so
+// that regional variants resolve to their language, e.g. "en-GB" to "en".
+type Matcher struct {
+	supported []language.Tag
+	matcher   language.Matcher
+}
+
+// NewMatcher returns a Matcher of the supported languages, the first one
+// being the default.
+func NewMatcher(supported ...language.Tag) *Matcher {
+	return &Matcher{supported: supported, matcher: language.NewMatcher(supported)}
+}
+
+// Match returns the supported language best matching the requested ones, in
+// order of preference, and its index. The default language is returned when
+// none matches.
+func (m *Matcher) Match(requested ...language.Tag) (language.Tag, int) {
+	_, i, confidence := m.matcher.Match(requested...)
+	if confidence == language.No {
+		i = 0
+	}
+	return m.supported[i], i
+}
@@ -0,0 +1,56 @@
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"golang.org/x/text/language"
+)
+
+var (
+	messagesMu sync.RWMutex
+	messages   = map[language.Base]map[string]string{}
+)
+
+// AddMessages registers the messages of lang, by key. Messages are looked up
+// by the base language, e.g. messages added for English serve "en-US".
+func AddMessages(lang language.Tag, msgs map[string]string) {
+	base, _ := lang.Base()
+
+	messagesMu.Lock()
+	defer messagesMu.Unlock()
+	if messages[base] == nil {
+		messages[base] = make(map[string]string, len(msgs))
+	}
+	for key, msg := range msgs {
+		messages[base][key] = msg
+	}
+}
+
+// Lookup returns the message of key in the language of ctx, falling back to
+// FallbackLanguage. Messages are formatted with args, as fmt.Sprintf does.
+func Lookup(ctx context.Context, key string, args ...any) (string, bool) {
+	messagesMu.RLock()
+	defer messagesMu.RUnlock()
+
+	for _, lang := range []language.Tag{FromContext(ctx), FallbackLanguage} {
+		base, _ := lang.Base()
+		if msg, ok := messages[base][key]; ok {
+			if len(args) > 0 {
+				msg = fmt.Sprintf(msg, args...)
+			}
+			return msg, true
+		}
+	}
+	return "", false
+}
+
+// Translate returns the message of key in the language of ctx, or key itself
+// when no message is registered.
+func Translate(ctx context.Context, key string, args ...any) string {
+	if msg, ok := Lookup(ctx, key, args...); ok {
+		return msg
+	}
+	return key
+}
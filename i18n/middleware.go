@@ -14,7 +14,7 @@ func LanguageMiddleware() gin.HandlerFunc {
 		// Check for language in the "Accept-Language" header
 		if langHeader := c.GetHeader("Accept-Language"); langHeader != "" {
 			parsedPrefLags, _, err := language.ParseAcceptLanguage(langHeader)
-			if err != nil || parsedPrefLags == nil {
+			if err == nil && len(parsedPrefLags) > 0 {
 				lang = parsedPrefLags[0]
 			}
 		}
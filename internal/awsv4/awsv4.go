@@ -0,0 +1,191 @@
+// Package awsv4 signs requests to AWS APIs, and to compatible services, with
+// AWS Signature Version 4.
+package awsv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Algorithm is the signing algorithm.
+	Algorithm = "AWS4-HMAC-SHA256"
+	// UnsignedPayload is the payload hash of requests whose payload isn't
+	// signed, e.g. S3 uploads streamed from a reader.
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+	// DateFormat is the format of the X-Amz-Date header.
+	DateFormat = "20060102T150405Z"
+)
+
+// Credentials are the credentials requests are signed with.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// CredentialsFromEnv returns creds, or, if it has no AccessKeyID, the
+// credentials of the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN variables.
+func CredentialsFromEnv(creds Credentials) Credentials {
+	if creds.AccessKeyID != "" {
+		return creds
+	}
+	return Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// Sign signs req, of payload body, in its Authorization header, for service
+// in region at now.
+func Sign(req *http.Request, body []byte, creds Credentials, region, service string, now time.Time) {
+	hash := sha256.Sum256(body)
+	SignPayload(req, hex.EncodeToString(hash[:]), creds, region, service, now)
+}
+
+// SignPayload signs req like Sign, with the hash of its payload, e.g.
+// UnsignedPayload.
+func SignPayload(req *http.Request, payloadHash string, creds Credentials, region, service string, now time.Time) {
+	now = now.UTC()
+	req.Header.Set("X-Amz-Date", now.Format(DateFormat))
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": host(req)}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") || name == "content-type" || name == "content-md5" || name == "range" {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	signedHeaders, canonicalHeaders := canonicalHeaders(headers)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := credentialScope(now, region, service)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		Algorithm, creds.AccessKeyID, scope, signedHeaders, signature(creds, now, region, service, scope, canonicalRequest)))
+}
+
+// Presign returns u signed in its query for method until it expires, with
+// the headers to send, for service in region at now. The payload isn't
+// signed.
+func Presign(method string, u *url.URL, headers map[string]string, expires time.Duration, creds Credentials, region, service string, now time.Time) string {
+	now = now.UTC()
+	signed := map[string]string{"host": u.Host}
+	for name, value := range headers {
+		signed[strings.ToLower(name)] = strings.TrimSpace(value)
+	}
+	signedHeaders, canonicalHeaders := canonicalHeaders(signed)
+	scope := credentialScope(now, region, service)
+	q := url.Values{
+		"X-Amz-Algorithm":     {Algorithm},
+		"X-Amz-Credential":    {creds.AccessKeyID + "/" + scope},
+		"X-Amz-Date":          {now.Format(DateFormat)},
+		"X-Amz-Expires":       {strconv.Itoa(int(expires.Seconds()))},
+		"X-Amz-SignedHeaders": {signedHeaders},
+	}
+	if creds.SessionToken != "" {
+		q.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	canonicalRequest := strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		canonicalQuery(q),
+		canonicalHeaders,
+		signedHeaders,
+		UnsignedPayload,
+	}, "\n")
+	presigned := *u
+	presigned.RawQuery = canonicalQuery(q) + "&X-Amz-Signature=" + signature(creds, now, region, service, scope, canonicalRequest)
+	return presigned.String()
+}
+
+func credentialScope(t time.Time, region, service string) string {
+	return t.Format("20060102") + "/" + region + "/" + service + "/aws4_request"
+}
+
+// signature returns the signature of canonicalRequest at t.
+func signature(creds Credentials, t time.Time, region, service, scope, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := Algorithm + "\n" + t.Format(DateFormat) + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), t.Format("20060102"))
+	for _, s := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func host(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+	return req.URL.Host
+}
+
+// canonicalHeaders returns the signed headers, and their canonical form.
+func canonicalHeaders(headers map[string]string) (string, string) {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + ":" + headers[name] + "\n")
+	}
+	return strings.Join(names, ";"), b.String()
+}
+
+// canonicalQuery returns the query q sorted and URI encoded.
+func canonicalQuery(q url.Values) string {
+	pairs := make([]string, 0, len(q))
+	for key, values := range q {
+		for _, v := range values {
+			pairs = append(pairs, URIEncode(key, true)+"="+URIEncode(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// URIEncode percent encodes s but its unreserved characters, and its slashes
+// unless encodeSlash.
+func URIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
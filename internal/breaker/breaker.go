@@ -0,0 +1,207 @@
+// Package breaker implements the circuit breaker over a rolling window of
+// the zin routes and of the zihttpc hosts.
+package breaker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Breaker states
+const (
+	Closed   = "closed"
+	Open     = "open"
+	HalfOpen = "half_open"
+)
+
+// buckets is the number of buckets of the breaker window.
+const buckets = 10
+
+// Config configures a circuit breaker. The breaker opens when, over Window,
+// at least MinRequests requests were made and the rate of failures, or of
+// slow requests, reaches its threshold. Open breakers reject requests for
+// OpenDuration, then let HalfOpenRequests probes through, closing once they
+// all succeed.
+type Config struct {
+	// Window is the duration over which requests are counted (default: 10s).
+	Window time.Duration
+	// MinRequests is the minimum number of requests of the window before the
+	// breaker can open (default: 20).
+	MinRequests int
+	// ErrorRate is the failure rate opening the breaker (default: 0.5).
+	ErrorRate float64
+	// SlowDuration is the duration above which requests are slow, not
+	// checked when zero.
+	SlowDuration time.Duration
+	// SlowRate is the slow request rate opening the breaker (default: 0.5).
+	SlowRate float64
+	// OpenDuration is how long the breaker stays open (default: 30s).
+	OpenDuration time.Duration
+	// HalfOpenRequests is the number of probes of a half-open breaker
+	// (default: 1).
+	HalfOpenRequests int
+}
+
+func (c Config) withDefaults() Config {
+	if c.Window <= 0 {
+		c.Window = 10 * time.Second
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = 20
+	}
+	if c.ErrorRate <= 0 {
+		c.ErrorRate = 0.5
+	}
+	if c.SlowRate <= 0 {
+		c.SlowRate = 0.5
+	}
+	if c.OpenDuration <= 0 {
+		c.OpenDuration = 30 * time.Second
+	}
+	if c.HalfOpenRequests <= 0 {
+		c.HalfOpenRequests = 1
+	}
+	return c
+}
+
+// TransitionFunc is called with the context of the request moving a breaker
+// from a state to another, the breaker being locked.
+type TransitionFunc func(ctx context.Context, from, to string)
+
+// bucket counts the requests of a slice of the breaker window.
+type bucket struct {
+	start    time.Time
+	total    int
+	failures int
+	slow     int
+}
+
+// Breaker is a circuit breaker over a rolling window.
+type Breaker struct {
+	// Now returns the current time, time.Now unless replaced before the
+	// breaker is used.
+	Now func() time.Time
+
+	config       Config
+	onTransition TransitionFunc
+
+	mu       sync.Mutex
+	state    string
+	openedAt time.Time
+	probes   int
+	passed   int
+	buckets  [buckets]bucket
+}
+
+// New returns a closed breaker, calling onTransition, if not nil, on its
+// transitions.
+func New(config Config, onTransition TransitionFunc) *Breaker {
+	return &Breaker{Now: time.Now, config: config.withDefaults(), onTransition: onTransition, state: Closed}
+}
+
+// State returns the state of the breaker.
+func (b *Breaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Allow reports whether a request can go through, moving open breakers to
+// half-open once OpenDuration elapsed. Allowed requests must be followed by
+// Done, or by Release.
+func (b *Breaker) Allow(ctx context.Context) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		if b.Now().Sub(b.openedAt) < b.config.OpenDuration {
+			return false
+		}
+		b.transition(ctx, HalfOpen)
+		fallthrough
+	case HalfOpen:
+		if b.probes >= b.config.HalfOpenRequests {
+			return false
+		}
+		b.probes++
+	}
+	return true
+}
+
+// Release gives back the probe of a request without outcome.
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == HalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+// Done records the outcome of a request which took d.
+func (b *Breaker) Done(ctx context.Context, failed bool, d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	slow := b.config.SlowDuration > 0 && d >= b.config.SlowDuration
+	if b.state == HalfOpen {
+		if failed || slow {
+			b.transition(ctx, Open)
+			return
+		}
+		if b.passed++; b.passed >= b.config.HalfOpenRequests {
+			b.transition(ctx, Closed)
+		}
+		return
+	}
+	if b.state != Closed {
+		return
+	}
+
+	now := b.Now()
+	width := b.config.Window / buckets
+	bk := &b.buckets[(now.UnixNano()/int64(width))%buckets]
+	if start := now.Truncate(width); !bk.start.Equal(start) {
+		*bk = bucket{start: start}
+	}
+	bk.total++
+	if failed {
+		bk.failures++
+	}
+	if slow {
+		bk.slow++
+	}
+
+	var total, failures, slowCount int
+	for _, bk := range b.buckets {
+		if now.Sub(bk.start) < b.config.Window {
+			total += bk.total
+			failures += bk.failures
+			slowCount += bk.slow
+		}
+	}
+	if total < b.config.MinRequests {
+		return
+	}
+	if float64(failures)/float64(total) >= b.config.ErrorRate ||
+		(b.config.SlowDuration > 0 && float64(slowCount)/float64(total) >= b.config.SlowRate) {
+		b.transition(ctx, Open)
+	}
+}
+
+// transition moves the breaker to state, resetting its counts, locked.
+func (b *Breaker) transition(ctx context.Context, state string) {
+	from := b.state
+	b.state = state
+	b.probes, b.passed = 0, 0
+	switch state {
+	case Open:
+		b.openedAt = b.Now()
+	case Closed:
+		b.buckets = [buckets]bucket{}
+	}
+	if b.onTransition != nil {
+		b.onTransition(ctx, from, state)
+	}
+}
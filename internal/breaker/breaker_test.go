@@ -0,0 +1,55 @@
+package breaker
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Unix(0, 0)
+	var transitions []string
+	b := New(Config{MinRequests: 4, OpenDuration: time.Minute, HalfOpenRequests: 2}, func(_ context.Context, from, to string) {
+		transitions = append(transitions, from+">"+to)
+	})
+	b.Now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 4; i++ {
+		if !b.Allow(ctx) {
+			t.Fatalf("request %d rejected by a closed breaker", i)
+		}
+		b.Done(ctx, i%2 == 0, time.Millisecond)
+	}
+	if b.Allow(ctx) {
+		t.Fatal("request allowed by an open breaker")
+	}
+
+	now = now.Add(time.Minute)
+	if !b.Allow(ctx) || !b.Allow(ctx) {
+		t.Fatal("probes rejected by a half-open breaker")
+	}
+	if b.Allow(ctx) {
+		t.Fatal("request allowed beyond HalfOpenRequests")
+	}
+	// Released probes can be taken again
+	b.Release()
+	if !b.Allow(ctx) {
+		t.Fatal("released probe rejected")
+	}
+	b.Done(ctx, false, time.Millisecond)
+	b.Done(ctx, false, time.Millisecond)
+	if state := b.State(); state != Closed {
+		t.Fatalf("state = %s, want closed once the probes succeeded", state)
+	}
+
+	want := []string{"closed>open", "open>half_open", "half_open>closed"}
+	if len(transitions) != len(want) {
+		t.Fatalf("transitions = %q, want %q", transitions, want)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Errorf("transition %d = %q, want %q", i, transitions[i], want[i])
+		}
+	}
+}
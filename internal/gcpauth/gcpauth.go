@@ -0,0 +1,95 @@
+// Package gcpauth gets the access tokens authenticating the requests to GCP
+// APIs, configured or of the metadata server.
+package gcpauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// MetadataTokenURL is the URL of the access tokens of the service account of
+// the instances, e.g. of GKE workload identity.
+const MetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// AccessTokenEnv is the variable of the access token of the processes
+// without one configured.
+const AccessTokenEnv = "GOOGLE_OAUTH_ACCESS_TOKEN"
+
+// StatusError is the error of the metadata server failing to return a token.
+type StatusError struct {
+	Status int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("gcpauth: metadata server token: status %d", e.Status)
+}
+
+// TokenSource returns the access tokens of the requests.
+type TokenSource struct {
+	// AccessToken is returned, when set, instead of the tokens of the
+	// metadata server.
+	AccessToken string
+	// URL is the URL of the tokens of the metadata server.
+	URL string
+
+	client *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewTokenSource returns the TokenSource of accessToken, or of the
+// AccessTokenEnv variable if empty, falling back to the tokens of the
+// metadata server, got with client, or http.DefaultClient if nil.
+func NewTokenSource(accessToken string, client *http.Client) *TokenSource {
+	if accessToken == "" {
+		accessToken = os.Getenv(AccessTokenEnv)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &TokenSource{AccessToken: accessToken, URL: MetadataTokenURL, client: client}
+}
+
+// Token returns the configured access token, or a token of the metadata
+// server, cached until shortly before it expires. Error responses of the
+// metadata server are returned as *StatusError.
+func (s *TokenSource) Token(ctx context.Context) (string, error) {
+	if s.AccessToken != "" {
+		return s.AccessToken, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Now().Before(s.expires) {
+		return s.token, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("gcpauth: failed to get an access token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return "", &StatusError{Status: resp.StatusCode}
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("gcpauth: invalid access token: %w", err)
+	}
+	s.token = token.AccessToken
+	s.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return s.token, nil
+}
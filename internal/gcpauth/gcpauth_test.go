@@ -0,0 +1,61 @@
+package gcpauth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTokenSource(t *testing.T) {
+	tokens := 0
+	failing := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		tokens++
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			t.Error("metadata request without Metadata-Flavor")
+		}
+		io.WriteString(w, `{"access_token":"ya29","expires_in":3600}`)
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	t.Run("configured", func(t *testing.T) {
+		s := NewTokenSource("static", srv.Client())
+		s.URL = srv.URL
+		if token, err := s.Token(ctx); err != nil || token != "static" {
+			t.Errorf("Token() = %q, %v, want the configured token", token, err)
+		}
+	})
+
+	t.Run("metadata server", func(t *testing.T) {
+		s := NewTokenSource("", srv.Client())
+		s.AccessToken = ""
+		s.URL = srv.URL
+		for i := 0; i < 2; i++ {
+			if token, err := s.Token(ctx); err != nil || token != "ya29" {
+				t.Fatalf("Token() = %q, %v, want the token of the metadata server", token, err)
+			}
+		}
+		if tokens != 1 {
+			t.Errorf("%d tokens got, want the token cached", tokens)
+		}
+	})
+
+	t.Run("metadata server error", func(t *testing.T) {
+		failing = true
+		s := NewTokenSource("", srv.Client())
+		s.AccessToken = ""
+		s.URL = srv.URL
+		var statusErr *StatusError
+		if _, err := s.Token(ctx); !errors.As(err, &statusErr) || statusErr.Status != http.StatusForbidden {
+			t.Errorf("Token() = %v, want the status of the metadata server", err)
+		}
+	})
+}
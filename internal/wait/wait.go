@@ -0,0 +1,20 @@
+// Package wait waits for durations interrupted by the end of a context, e.g.
+// between the retries of a consumer.
+package wait
+
+import (
+	"context"
+	"time"
+)
+
+// Sleep waits for d, returning false when ctx is done first.
+func Sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,23 @@
+package wait
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSleep(t *testing.T) {
+	if !Sleep(context.Background(), time.Millisecond) {
+		t.Error("Sleep() = false, want true once the duration elapsed")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if Sleep(ctx, time.Hour) {
+		t.Error("Sleep() = true, want false once the context is done")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Sleep() returned after %s, want right away", elapsed)
+	}
+}
@@ -0,0 +1,82 @@
+// Package webhook posts JSON payloads to webhooks, e.g. to notify the outcome
+// of the exports of ziexport and the imports of ziingest.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Notifier is told about the outcome of jobs, results of type T.
+type Notifier[T any] interface {
+	// Notify notifies result, err being the failure of the job.
+	Notify(ctx context.Context, result T, err error) error
+}
+
+// NotifierFunc is a Notifier function.
+type NotifierFunc[T any] func(ctx context.Context, result T, err error) error
+
+func (f NotifierFunc[T]) Notify(ctx context.Context, result T, err error) error {
+	return f(ctx, result, err)
+}
+
+// Notification statuses
+const (
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+// status is appended to the fields of the results in the payloads.
+type status struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type notifier[T any] struct {
+	sender *Sender
+	url    string
+}
+
+// NewNotifier returns a Notifier posting the results in JSON to url with
+// sender. The payloads are the fields of the result, which must encode to a
+// JSON object, followed by the status and the error, if any, e.g.
+//
+//	{"name": "orders_report", "records": 42, "status": "failed", "error": "..."}
+func NewNotifier[T any](sender *Sender, url string) Notifier[T] {
+	return &notifier[T]{sender: sender, url: url}
+}
+
+func (n *notifier[T]) Notify(ctx context.Context, result T, err error) error {
+	s := status{Status: StatusCompleted}
+	if err != nil {
+		s.Status, s.Error = StatusFailed, err.Error()
+	}
+	b, err := payload(result, s)
+	if err != nil {
+		return fmt.Errorf("%s: failed to encode the webhook payload: %w", n.sender.system, err)
+	}
+	return n.sender.Send(ctx, n.url, b)
+}
+
+// payload returns the JSON object of the fields of result followed by those
+// of s.
+func payload(result any, s status) ([]byte, error) {
+	r, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	r = bytes.TrimSpace(r)
+	if len(r) < 2 || r[0] != '{' || r[len(r)-1] != '}' {
+		return nil, fmt.Errorf("result %T doesn't encode to a JSON object", result)
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(r) == 2 {
+		return b, nil
+	}
+	return append(append(r[:len(r)-1:len(r)-1], ','), b[1:]...), nil
+}
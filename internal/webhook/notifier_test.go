@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type result struct {
+	Name    string `json:"name"`
+	Records int64  `json:"records"`
+}
+
+func TestNotifier(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = string(b)
+	}))
+	defer srv.Close()
+
+	n := NewNotifier[result](NewSender("test", nil, false), srv.URL)
+	if err := n.Notify(context.Background(), result{Name: "users", Records: 3}, nil); err != nil {
+		t.Fatalf("Notify() = %v", err)
+	}
+	if want := `{"name":"users","records":3,"status":"completed"}`; got != want {
+		t.Errorf("payload = %s, want %s", got, want)
+	}
+
+	if err := n.Notify(context.Background(), result{Name: "users"}, errors.New("boom")); err != nil {
+		t.Fatalf("Notify() = %v", err)
+	}
+	if want := `{"name":"users","records":0,"status":"failed","error":"boom"}`; got != want {
+		t.Errorf("payload = %s, want %s", got, want)
+	}
+}
+
+func TestNotifierErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	if err := NewNotifier[result](NewSender("test", nil, false), srv.URL).Notify(context.Background(), result{}, nil); err == nil {
+		t.Error("Notify() = nil, want the status of the webhook")
+	}
+	if err := NewNotifier[int](NewSender("test", nil, false), srv.URL).Notify(context.Background(), 1, nil); err == nil {
+		t.Error("Notify() = nil, want an error for results not encoding to objects")
+	}
+}
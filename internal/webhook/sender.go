@@ -0,0 +1,104 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/divikraf/lumos/zihttpc"
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/divikraf/lumos/internal/webhook"
+
+var (
+	tracer = observe.LibraryTracer(instrumentationName)
+	scope  = revelio.Extend(revelio.Library(instrumentationName))
+)
+
+// Sender posts JSON payloads to webhooks. The deliveries are traced, timed
+// in the webhook_send_duration_ms metric, and counted in webhook_sent_total,
+// by system, dry_run and status.
+//
+// In dry-run mode, e.g. in staging environments, the payloads are logged
+// instead of being posted.
+type Sender struct {
+	system string
+	client *http.Client
+	dryRun bool
+
+	duration revelio.DurationRecorder
+	results  revelio.ResultCounter
+}
+
+// NewSender returns a Sender of the webhooks of system, e.g. "ziexport",
+// posting with client, or a client of zihttpc.New if nil, or logging the
+// payloads when dryRun is set.
+func NewSender(system string, client *http.Client, dryRun bool) *Sender {
+	if client == nil {
+		client = zihttpc.New(zihttpc.ClientConfig{})
+	}
+	return &Sender{
+		system:   system,
+		client:   client,
+		dryRun:   dryRun,
+		duration: revelio.Must(scope.Duration("webhook_send_duration_ms", "Duration of webhook deliveries in milliseconds")),
+		results:  revelio.Must(scope.ResultCounter("webhook_sent_total", "Number of webhook deliveries, by system, dry_run and status")),
+	}
+}
+
+// Send posts the JSON payload to url, failing when the webhook doesn't
+// respond with a 2xx status.
+func (s *Sender) Send(ctx context.Context, url string, payload []byte) error {
+	ctx, span := tracer.Start(ctx, "webhook send",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("webhook.system", s.system),
+			attribute.Bool("webhook.dry_run", s.dryRun),
+		),
+	)
+	defer span.End()
+
+	start := time.Now()
+	var err error
+	if s.dryRun {
+		zilog.FromContext(ctx).Info().
+			Str("system", s.system).
+			Str("url", url).
+			RawJSON("payload", payload).
+			Msg("Webhook not sent, dry-run mode")
+	} else {
+		err = s.post(ctx, url, payload)
+	}
+	attrs := []attribute.KeyValue{attribute.String("system", s.system), attribute.Bool("dry_run", s.dryRun)}
+	s.duration.Record(ctx, time.Since(start), append(attrs, revelio.ResultAttributes(err)...)...)
+	s.results.Record(ctx, err, attrs...)
+	if err != nil {
+		observe.RecordError(span, err)
+		zilog.FromContext(ctx).Error().Err(err).Str("system", s.system).Msg("Failed to send webhook")
+	}
+	return err
+}
+
+func (s *Sender) post(ctx context.Context, url string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: webhook responded %s", s.system, resp.Status)
+	}
+	return nil
+}
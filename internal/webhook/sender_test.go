@@ -0,0 +1,50 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestSenderDryRun(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	posted := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted++
+	}))
+	defer srv.Close()
+
+	var logs bytes.Buffer
+	logger := zerolog.New(&logs)
+	ctx := logger.WithContext(context.Background())
+
+	n := NewNotifier[result](NewSender("test", nil, true), srv.URL)
+	if err := n.Notify(ctx, result{Name: "users", Records: 3}, nil); err != nil {
+		t.Fatalf("Notify() = %v", err)
+	}
+	if posted != 0 {
+		t.Error("webhook posted in dry-run mode")
+	}
+	if got := logs.String(); !strings.Contains(got, `"payload":{"name":"users","records":3,"status":"completed"}`) || !strings.Contains(got, srv.URL) {
+		t.Errorf("logs = %s, want the payload and the url", got)
+	}
+	reveliotest.AssertCounterValue(t, s, "webhook_sent_total", 1,
+		attribute.String("system", "test"), attribute.Bool("dry_run", true), revelio.StatusKey.String(revelio.StatusSuccess))
+
+	if err := NewSender("test", nil, false).Send(ctx, srv.URL, []byte(`{}`)); err != nil {
+		t.Fatalf("Send() = %v", err)
+	}
+	if posted != 1 {
+		t.Errorf("webhook posted %d times, want 1", posted)
+	}
+	reveliotest.AssertCounterValue(t, s, "webhook_sent_total", 1,
+		attribute.String("system", "test"), attribute.Bool("dry_run", false), revelio.StatusKey.String(revelio.StatusSuccess))
+}
@@ -0,0 +1,343 @@
+// Package ziauthz enforces the authorization decisions of a policy engine,
+// e.g. OPA, caching them and keeping an audit trail of them.
+//
+// Decisions are cached for the request, see WithRequestCache, and for a short
+// TTL across requests. The cache is flushed when the policy version of the
+// decisions changes, and can be invalidated when permissions change, e.g. as
+// a role is revoked. Every decision not already made in the request is
+// written to the zilog audit channel with its subject, action, resource and
+// policy version:
+//
+//	authz := ziauthz.New(opa, ziauthz.Config{CacheTTL: 10 * time.Second})
+//	decision, err := authz.Authorize(ctx, ziauthz.Input{Subject: userID, Action: "refund", Resource: "orders/" + id})
+//	...
+//	authz.Invalidate(ctx, ziauthz.Invalidation{Subject: userID})
+package ziauthz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const instrumentationName = "github.com/divikraf/lumos/ziauthz"
+
+var scope = revelio.Extend(revelio.Library(instrumentationName))
+
+// Input is what an authorization is asked for: may Subject do Action on
+// Resource.
+type Input struct {
+	Subject  string `json:"subject"`
+	Action   string `json:"action"`
+	Resource string `json:"resource"`
+	// Attributes are the other inputs of the policy, e.g. the tenant of the
+	// subject. They are part of the cache key.
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// Decision is the decision of the policy on an Input.
+type Decision struct {
+	Allowed bool
+	// PolicyVersion is the version of the policy deciding, e.g. the revision
+	// of the OPA bundle.
+	PolicyVersion string
+	// Reason explains the decision, e.g. the rule denying it.
+	Reason string
+}
+
+// Authorizer decides on the inputs, e.g. a client of OPA.
+type Authorizer interface {
+	Authorize(ctx context.Context, input Input) (Decision, error)
+}
+
+// AuthorizerFunc is an Authorizer function.
+type AuthorizerFunc func(ctx context.Context, input Input) (Decision, error)
+
+func (f AuthorizerFunc) Authorize(ctx context.Context, input Input) (Decision, error) {
+	return f(ctx, input)
+}
+
+// Config configures the caching of the decisions.
+type Config struct {
+	// CacheTTL is how long decisions are cached across requests (default:
+	// 10s), negative disabling the cross-request cache.
+	CacheTTL time.Duration `json:"cache_ttl" yaml:"cache_ttl"`
+	// CacheSize is the maximum number of decisions cached across requests
+	// (default: 10000).
+	CacheSize int `json:"cache_size" yaml:"cache_size"`
+}
+
+// Invalidation selects the cached decisions to invalidate: the ones of
+// Subject, of Resource, or of both. The zero Invalidation selects them all.
+type Invalidation struct {
+	Subject  string
+	Resource string
+}
+
+func (inv Invalidation) matches(input Input) bool {
+	return (inv.Subject == "" || inv.Subject == input.Subject) &&
+		(inv.Resource == "" || inv.Resource == input.Resource)
+}
+
+// Authz enforces the decisions of an Authorizer, see the package doc. The
+// decisions are counted in authz_decisions_total by decision, allow, deny or
+// error, and cache, request, shared or none.
+type Authz struct {
+	authorizer Authorizer
+	config     Config
+	now        func() time.Time
+
+	mu      sync.Mutex
+	version string
+	entries map[string]*entry
+	// gen counts the invalidations, for the decisions made while one runs
+	// not to be cached
+	gen uint64
+
+	hooksMu sync.Mutex
+	hooks   []func(ctx context.Context, inv Invalidation)
+
+	decisions metric.Int64Counter
+}
+
+type entry struct {
+	input     Input
+	decision  Decision
+	expiresAt time.Time
+}
+
+// New returns the Authz of the decisions of authorizer.
+func New(authorizer Authorizer, config Config) *Authz {
+	if config.CacheTTL == 0 {
+		config.CacheTTL = 10 * time.Second
+	}
+	if config.CacheSize <= 0 {
+		config.CacheSize = 10000
+	}
+	return &Authz{
+		authorizer: authorizer,
+		config:     config,
+		now:        time.Now,
+		entries:    map[string]*entry{},
+		decisions: revelio.Must(scope.Int64Counter(
+			"authz_decisions_total",
+			"Number of authorization decisions by decision and cache",
+		)),
+	}
+}
+
+// Authorize returns the decision on input, from the cache of the request or
+// the shared one when cached. Failures are denials, and are not cached.
+func (a *Authz) Authorize(ctx context.Context, input Input) (Decision, error) {
+	key, err := cacheKey(input)
+	if err != nil {
+		return Decision{}, err
+	}
+	rc := requestCacheFrom(ctx)
+	if d, ok := rc.get(key); ok {
+		a.count(ctx, d, nil, "request")
+		return d, nil
+	}
+	if d, ok := a.cached(key); ok {
+		rc.set(key, d)
+		a.count(ctx, d, nil, "shared")
+		a.audit(ctx, input, d, nil, "shared")
+		return d, nil
+	}
+
+	a.mu.Lock()
+	gen := a.gen
+	a.mu.Unlock()
+	d, err := a.authorizer.Authorize(ctx, input)
+	if err != nil {
+		err = fmt.Errorf("ziauthz: failed to authorize %s on %s: %w", input.Action, input.Resource, err)
+		d = Decision{}
+	} else {
+		rc.set(key, d)
+		a.store(key, input, d, gen)
+	}
+	a.count(ctx, d, err, "none")
+	a.audit(ctx, input, d, err, "none")
+	return d, err
+}
+
+// cached returns the decision cached under key, unless expired.
+func (a *Authz) cached(key string) (Decision, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	e, ok := a.entries[key]
+	if !ok {
+		return Decision{}, false
+	}
+	if !a.now().Before(e.expiresAt) {
+		delete(a.entries, key)
+		return Decision{}, false
+	}
+	return e.decision, true
+}
+
+// store caches the decision on input under key, made at the generation gen.
+// A new policy version flushes the decisions of the previous one.
+func (a *Authz) store(key string, input Input, d Decision, gen uint64) {
+	if a.config.CacheTTL < 0 {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if gen != a.gen {
+		return
+	}
+	if d.PolicyVersion != a.version {
+		clear(a.entries)
+		a.version = d.PolicyVersion
+	}
+	now := a.now()
+	if len(a.entries) >= a.config.CacheSize {
+		for k, e := range a.entries {
+			if !now.Before(e.expiresAt) {
+				delete(a.entries, k)
+			}
+		}
+	}
+	if len(a.entries) >= a.config.CacheSize {
+		// Evict any, the entries are short-lived anyway
+		for k := range a.entries {
+			delete(a.entries, k)
+			break
+		}
+	}
+	a.entries[key] = &entry{input: input, decision: d, expiresAt: now.Add(a.config.CacheTTL)}
+}
+
+// Invalidate drops the cached decisions selected by inv, e.g. as the roles
+// of a subject change, and runs the hooks registered with OnInvalidate. The
+// caches of the requests in flight are kept.
+func (a *Authz) Invalidate(ctx context.Context, inv Invalidation) {
+	a.invalidate(inv)
+	a.hooksMu.Lock()
+	hooks := a.hooks
+	a.hooksMu.Unlock()
+	for _, fn := range hooks {
+		fn(ctx, inv)
+	}
+}
+
+// InvalidateLocal drops the cached decisions selected by inv without running
+// the hooks, e.g. for an invalidation received from another instance.
+func (a *Authz) InvalidateLocal(inv Invalidation) {
+	a.invalidate(inv)
+}
+
+func (a *Authz) invalidate(inv Invalidation) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.gen++
+	for k, e := range a.entries {
+		if inv.matches(e.input) {
+			delete(a.entries, k)
+		}
+	}
+}
+
+// OnInvalidate registers fn to run on the invalidations, e.g. to publish them
+// to the other instances, which apply them with InvalidateLocal.
+func (a *Authz) OnInvalidate(fn func(ctx context.Context, inv Invalidation)) {
+	a.hooksMu.Lock()
+	a.hooks = append(a.hooks, fn)
+	a.hooksMu.Unlock()
+}
+
+func (a *Authz) count(ctx context.Context, d Decision, err error, cache string) {
+	a.decisions.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("decision", decisionName(d, err)),
+		attribute.String("cache", cache),
+	))
+}
+
+// audit writes the decision to the audit channel.
+func (a *Authz) audit(ctx context.Context, input Input, d Decision, err error, cache string) {
+	e := zilog.Audit(ctx).
+		Str("audit", "authz").
+		Str("subject", input.Subject).
+		Str("action", input.Action).
+		Str("resource", input.Resource).
+		Str("decision", decisionName(d, err)).
+		Str("policy_version", d.PolicyVersion).
+		Str("cache", cache)
+	if len(input.Attributes) > 0 {
+		e = e.Interface("attributes", input.Attributes)
+	}
+	if d.Reason != "" {
+		e = e.Str("reason", d.Reason)
+	}
+	if err != nil {
+		e = e.Err(err)
+	}
+	e.Msg("authorization decision")
+}
+
+func decisionName(d Decision, err error) string {
+	switch {
+	case err != nil:
+		return "error"
+	case d.Allowed:
+		return "allow"
+	default:
+		return "deny"
+	}
+}
+
+// cacheKey returns the cache key of input, its attributes encoded with sorted
+// keys.
+func cacheKey(input Input) (string, error) {
+	b, err := json.Marshal(input)
+	if err != nil {
+		return "", fmt.Errorf("ziauthz: invalid input attributes: %w", err)
+	}
+	return string(b), nil
+}
+
+type requestCacheKey struct{}
+
+// requestCache is the cache of the decisions of a request.
+type requestCache struct {
+	mu        sync.Mutex
+	decisions map[string]Decision
+}
+
+// WithRequestCache returns ctx caching the decisions made with it, for the
+// request it serves, see Middleware.
+func WithRequestCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestCacheKey{}, &requestCache{decisions: map[string]Decision{}})
+}
+
+func requestCacheFrom(ctx context.Context) *requestCache {
+	rc, _ := ctx.Value(requestCacheKey{}).(*requestCache)
+	return rc
+}
+
+func (rc *requestCache) get(key string) (Decision, bool) {
+	if rc == nil {
+		return Decision{}, false
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	d, ok := rc.decisions[key]
+	return d, ok
+}
+
+func (rc *requestCache) set(key string, d Decision) {
+	if rc == nil {
+		return
+	}
+	rc.mu.Lock()
+	rc.decisions[key] = d
+	rc.mu.Unlock()
+}
@@ -0,0 +1,194 @@
+package ziauthz
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zin"
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// policy allows the admins, counting its decisions.
+type policy struct {
+	version string
+	admins  map[string]bool
+	calls   int
+	err     error
+}
+
+func (p *policy) Authorize(ctx context.Context, input Input) (Decision, error) {
+	p.calls++
+	if p.err != nil {
+		return Decision{}, p.err
+	}
+	if !p.admins[input.Subject] {
+		return Decision{PolicyVersion: p.version, Reason: "not an admin"}, nil
+	}
+	return Decision{Allowed: true, PolicyVersion: p.version}, nil
+}
+
+// auditEvents decodes the audit events written to logs.
+func auditEvents(t *testing.T, logs *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var events []map[string]any
+	dec := json.NewDecoder(logs)
+	for dec.More() {
+		var e map[string]any
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestAuthz(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	var logs bytes.Buffer
+	ctx := zilog.WithAuditLogger(context.Background(), zilog.NewAuditLogger(&logs))
+	p := &policy{version: "v1", admins: map[string]bool{"alice": true}}
+	authz := New(p, Config{CacheTTL: time.Minute})
+	now := time.Now()
+	authz.now = func() time.Time { return now }
+	refund := Input{Subject: "alice", Action: "refund", Resource: "orders/1"}
+
+	// Decisions are cached for the request, then across requests
+	reqCtx := WithRequestCache(ctx)
+	for range 2 {
+		if d, err := authz.Authorize(reqCtx, refund); err != nil || !d.Allowed {
+			t.Fatalf("Authorize() = %+v, %v, want allowed", d, err)
+		}
+	}
+	if d, _ := authz.Authorize(WithRequestCache(ctx), refund); !d.Allowed || p.calls != 1 {
+		t.Errorf("Authorize() = %+v after %d calls, want the cached decision", d, p.calls)
+	}
+	if d, _ := authz.Authorize(ctx, Input{Subject: "bob", Action: "refund", Resource: "orders/1"}); d.Allowed || d.Reason != "not an admin" {
+		t.Errorf("Authorize() = %+v, want denied", d)
+	}
+	now = now.Add(time.Minute)
+	if authz.Authorize(ctx, refund); p.calls != 3 {
+		t.Errorf("%d calls, want the expired decision made again", p.calls)
+	}
+
+	events := auditEvents(t, &logs)
+	if len(events) != 4 {
+		t.Fatalf("%d audit events, want one per decision not made in the request", len(events))
+	}
+	want := map[string]any{
+		"channel":        zilog.AuditChannel,
+		"audit":          "authz",
+		"subject":        "bob",
+		"action":         "refund",
+		"resource":       "orders/1",
+		"decision":       "deny",
+		"policy_version": "v1",
+		"reason":         "not an admin",
+		"cache":          "none",
+	}
+	for k, v := range want {
+		if events[2][k] != v {
+			t.Errorf("audit event %s = %v, want %v", k, events[2][k], v)
+		}
+	}
+	if events[1]["cache"] != "shared" {
+		t.Errorf("audit event of the cached decision = %v", events[1])
+	}
+	reveliotest.AssertCounterValue(t, s, "authz_decisions_total", 1,
+		attribute.String("decision", "allow"), attribute.String("cache", "request"))
+	reveliotest.AssertCounterValue(t, s, "authz_decisions_total", 1,
+		attribute.String("decision", "allow"), attribute.String("cache", "shared"))
+
+	// Failures are denials, audited and not cached
+	p.err = errors.New("opa unavailable")
+	if d, err := authz.Authorize(ctx, Input{Subject: "carol", Action: "refund"}); err == nil || d.Allowed {
+		t.Errorf("Authorize() = %+v, %v, want the error denied", d, err)
+	}
+	if events := auditEvents(t, &logs); len(events) != 1 || events[0]["decision"] != "error" || events[0]["error"] == nil {
+		t.Errorf("audit events = %v, want the error", events)
+	}
+	p.err = nil
+	if authz.Authorize(ctx, Input{Subject: "carol", Action: "refund"}); p.calls != 5 {
+		t.Errorf("%d calls, want the failure not cached", p.calls)
+	}
+}
+
+func TestAuthzInvalidate(t *testing.T) {
+	reveliotest.NewDefaultTestScope(t)
+	ctx := zilog.WithAuditLogger(context.Background(), zilog.NewAuditLogger(&bytes.Buffer{}))
+	p := &policy{version: "v1", admins: map[string]bool{"alice": true, "bob": true}}
+	authz := New(p, Config{})
+	var published []Invalidation
+	authz.OnInvalidate(func(ctx context.Context, inv Invalidation) {
+		published = append(published, inv)
+	})
+	alice := Input{Subject: "alice", Action: "refund", Resource: "orders/1"}
+	bob := Input{Subject: "bob", Action: "refund", Resource: "orders/2"}
+	authz.Authorize(ctx, alice)
+	authz.Authorize(ctx, bob)
+
+	// The role of alice is revoked
+	delete(p.admins, "alice")
+	authz.Invalidate(ctx, Invalidation{Subject: "alice"})
+	if d, _ := authz.Authorize(ctx, alice); d.Allowed {
+		t.Error("Authorize() = allowed, want the decision of the revoked role")
+	}
+	if authz.Authorize(ctx, bob); p.calls != 3 {
+		t.Errorf("%d calls, want the decisions of bob kept", p.calls)
+	}
+	if len(published) != 1 || published[0].Subject != "alice" {
+		t.Errorf("hooks ran with %v, want the invalidation of alice", published)
+	}
+	authz.InvalidateLocal(Invalidation{Resource: "orders/2"})
+	if authz.Authorize(ctx, bob); p.calls != 4 || len(published) != 1 {
+		t.Errorf("%d calls and %d hook runs, want the decision of orders/2 made again without hook", p.calls, len(published))
+	}
+
+	// A new policy version flushes the decisions of the previous one
+	p.version = "v2"
+	authz.Invalidate(ctx, Invalidation{Subject: "carol"})
+	authz.Authorize(ctx, Input{Subject: "carol", Action: "refund"})
+	if authz.Authorize(ctx, bob); p.calls != 6 {
+		t.Errorf("%d calls, want the decisions of v1 flushed", p.calls)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	reveliotest.NewDefaultTestScope(t)
+	gin.SetMode(gin.TestMode)
+	p := &policy{version: "v1", admins: map[string]bool{"alice": true}}
+	authz := New(p, Config{})
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		ctx := zilog.WithAuditLogger(c.Request.Context(), zilog.NewAuditLogger(&bytes.Buffer{}))
+		if sub := c.GetHeader("X-Subject"); sub != "" {
+			ctx = zin.WithClaims(ctx, &zin.Claims{Subject: sub})
+		}
+		c.Request = c.Request.WithContext(ctx)
+	}, Middleware())
+	router.POST("/orders/:id/refund",
+		Require(authz, "refund", func(c *gin.Context) string { return "orders/" + c.Param("id") }),
+		Require(authz, "refund", func(c *gin.Context) string { return "orders/" + c.Param("id") }),
+		func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	for subject, want := range map[string]int{"alice": http.StatusNoContent, "bob": http.StatusForbidden, "": http.StatusUnauthorized} {
+		req := httptest.NewRequest(http.MethodPost, "/orders/1/refund", nil)
+		req.Header.Set("X-Subject", subject)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("%q: status = %d, want %d", subject, w.Code, want)
+		}
+	}
+	if p.calls != 2 {
+		t.Errorf("%d calls, want one per authenticated request", p.calls)
+	}
+}
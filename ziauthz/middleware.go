@@ -0,0 +1,43 @@
+package ziauthz
+
+import (
+	"github.com/divikraf/lumos/zin"
+	"github.com/gin-gonic/gin"
+)
+
+// ResourceFunc returns the resource a request acts on, e.g. from its path.
+type ResourceFunc func(c *gin.Context) string
+
+// Middleware caches the decisions of the requests for their duration, see
+// WithRequestCache.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(WithRequestCache(c.Request.Context()))
+		c.Next()
+	}
+}
+
+// Require aborts the requests with zin.ErrForbidden unless the subject of
+// their claims may do action on their resource. Unauthenticated requests
+// are aborted with zin.ErrUnauthorized, so it must run after the
+// authentication middleware.
+func Require(a *Authz, action string, resource ResourceFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		claims, ok := zin.ClaimsFromContext(ctx)
+		if !ok {
+			zin.AbortWithError(c, zin.ErrUnauthorized)
+			return
+		}
+		d, err := a.Authorize(ctx, Input{Subject: claims.Subject, Action: action, Resource: resource(c)})
+		if err != nil {
+			zin.AbortWithError(c, zin.ErrInternal.Wrap(err))
+			return
+		}
+		if !d.Allowed {
+			zin.AbortWithError(c, zin.ErrForbidden)
+			return
+		}
+		c.Next()
+	}
+}
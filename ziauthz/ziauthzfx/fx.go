@@ -0,0 +1,28 @@
+package ziauthzfx
+
+import (
+	"github.com/divikraf/lumos/ziauthz"
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zin/zinfx"
+	"go.uber.org/fx"
+)
+
+// authzConfig is implemented by configs of services authorizing requests,
+// their caching config under the "authz" key.
+type authzConfig interface {
+	GetAuthz() ziauthz.Config
+}
+
+// Provider provides the *ziauthz.Authz of the ziauthz.Authorizer provided,
+// e.g. a client of OPA, and caches the decisions of the requests of the main
+// router for their duration.
+var Provider = fx.Options(
+	fx.Provide(func(authorizer ziauthz.Authorizer, c ziconf.Config) *ziauthz.Authz {
+		var config ziauthz.Config
+		if ac, ok := c.(authzConfig); ok {
+			config = ac.GetAuthz()
+		}
+		return ziauthz.New(authorizer, config)
+	}),
+	zinfx.AddMiddleware(ziauthz.Middleware),
+)
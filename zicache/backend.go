@@ -0,0 +1,99 @@
+package zicache
+
+import (
+	"context"
+	"time"
+
+	"github.com/divikraf/lumos/db/ziredis"
+	"github.com/redis/go-redis/v9"
+)
+
+// Backend stores the encoded values of caches.
+type Backend interface {
+	// Get returns the value of key, and false when it doesn't exist.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set sets the value of key, expiring after ttl, or never when zero.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete deletes keys.
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// rawCodec passes the encoded values of a Redis backend through a KV.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) ([]byte, error) { return v.([]byte), nil }
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	*v.(*[]byte) = data
+	return nil
+}
+
+// Redis is a Backend storing the values in Redis, shared by every instance.
+// Its operations are instrumented as those of a ziredis.KV.
+type Redis struct {
+	kv *ziredis.KV
+}
+
+// NewRedis returns a Redis backend of client.
+func NewRedis(client redis.UniversalClient) *Redis {
+	return &Redis{kv: ziredis.NewKV(client, ziredis.WithCodec(rawCodec{}))}
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return ziredis.Get[[]byte](ctx, r.kv, key)
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.kv.Set(ctx, key, value, ttl)
+}
+
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	_, err := r.kv.Delete(ctx, keys...)
+	return err
+}
+
+// twoTier is a Backend of a local tier in front of a remote one.
+type twoTier struct {
+	local    Backend
+	remote   Backend
+	localTTL time.Duration
+}
+
+// TwoTier returns a Backend reading from local, e.g. an LRU, then from
+// remote, e.g. Redis, keeping the values read remotely locally for up to
+// localTTL. Writes go to both tiers. The local tiers of other instances
+// aren't invalidated, so they may serve a stale value for up to localTTL.
+func TwoTier(local, remote Backend, localTTL time.Duration) Backend {
+	return &twoTier{local: local, remote: remote, localTTL: localTTL}
+}
+
+func (t *twoTier) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	if value, ok, err := t.local.Get(ctx, key); err == nil && ok {
+		return value, true, nil
+	}
+	value, ok, err := t.remote.Get(ctx, key)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	// Best effort, the value is still read remotely
+	_ = t.local.Set(ctx, key, value, t.localTTL)
+	return value, true, nil
+}
+
+func (t *twoTier) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := t.remote.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	localTTL := t.localTTL
+	if ttl > 0 && (localTTL <= 0 || ttl < localTTL) {
+		localTTL = ttl
+	}
+	return t.local.Set(ctx, key, value, localTTL)
+}
+
+func (t *twoTier) Delete(ctx context.Context, keys ...string) error {
+	if err := t.local.Delete(ctx, keys...); err != nil {
+		return err
+	}
+	return t.remote.Delete(ctx, keys...)
+}
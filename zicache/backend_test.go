@@ -0,0 +1,116 @@
+package zicache
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeRedis answers the get, set and del commands of a client from memory,
+// as a hook never reaching the network.
+type fakeRedis struct {
+	values map[string]string
+	ttls   map[string]string
+}
+
+func (f *fakeRedis) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, fmt.Errorf("fake redis does not dial")
+	}
+}
+
+func (f *fakeRedis) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func (f *fakeRedis) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		args := make([]string, len(cmd.Args()))
+		for i, a := range cmd.Args() {
+			if b, ok := a.([]byte); ok {
+				a = string(b)
+			}
+			args[i] = fmt.Sprint(a)
+		}
+		switch c := cmd.(type) {
+		case *redis.StringCmd:
+			v, ok := f.values[args[1]]
+			if !ok {
+				c.SetErr(redis.Nil)
+			}
+			c.SetVal(v)
+		case *redis.StatusCmd:
+			f.values[args[1]] = args[2]
+			f.ttls[args[1]] = strings.Join(args[3:], " ")
+			c.SetVal("OK")
+		case *redis.IntCmd:
+			for _, key := range args[1:] {
+				delete(f.values, key)
+			}
+		}
+		return cmd.Err()
+	}
+}
+
+func TestRedis(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeRedis{values: map[string]string{}, ttls: map[string]string{}}
+	client := redis.NewClient(&redis.Options{Addr: "fake:6379"})
+	client.AddHook(fake)
+	t.Cleanup(func() { client.Close() })
+	backend := NewRedis(client)
+
+	if _, ok, err := backend.Get(ctx, "users:u-1"); ok || err != nil {
+		t.Fatalf("Get() of a missing key = %v, %v", ok, err)
+	}
+	if err := backend.Set(ctx, "users:u-1", []byte(`{"id":"u-1"}`), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if fake.values["users:u-1"] != `{"id":"u-1"}` || fake.ttls["users:u-1"] != "ex 60" {
+		t.Errorf("stored %q with %q", fake.values["users:u-1"], fake.ttls["users:u-1"])
+	}
+	if v, ok, err := backend.Get(ctx, "users:u-1"); err != nil || !ok || string(v) != `{"id":"u-1"}` {
+		t.Errorf("Get() = %q, %v, %v", v, ok, err)
+	}
+	if err := backend.Delete(ctx, "users:u-1"); err != nil || len(fake.values) != 0 {
+		t.Errorf("Delete() = %v, values = %v", err, fake.values)
+	}
+}
+
+func TestTwoTier(t *testing.T) {
+	ctx := context.Background()
+	local, remote := NewLRU(10), NewLRU(10)
+	now := time.Unix(0, 0)
+	local.now = func() time.Time { return now }
+	backend := TwoTier(local, remote, time.Minute)
+
+	remote.Set(ctx, "a", []byte("1"), 0)
+	if v, ok, err := backend.Get(ctx, "a"); err != nil || !ok || string(v) != "1" {
+		t.Fatalf("Get() = %q, %v, %v", v, ok, err)
+	}
+	if _, ok, _ := local.Get(ctx, "a"); !ok {
+		t.Error("value read remotely not kept locally")
+	}
+	// The local value expires after the local TTL, and is read again
+	remote.Set(ctx, "a", []byte("2"), 0)
+	now = now.Add(time.Minute)
+	if v, _, _ := backend.Get(ctx, "a"); string(v) != "2" {
+		t.Errorf("Get() = %q after the local TTL, want 2", v)
+	}
+
+	backend.Set(ctx, "b", []byte("3"), 10*time.Second)
+	now = now.Add(10 * time.Second)
+	if _, ok, _ := local.Get(ctx, "b"); ok {
+		t.Error("local value outlived its shorter TTL")
+	}
+
+	backend.Delete(ctx, "a", "b")
+	if local.Len()+remote.Len() != 0 {
+		t.Errorf("%d local and %d remote values left", local.Len(), remote.Len())
+	}
+}
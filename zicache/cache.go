@@ -0,0 +1,229 @@
+// Package zicache provides typed caches of values encoded in pluggable
+// backends: Redis, shared by every instance, an in-process LRU, or both in
+// two tiers.
+//
+// GetOrLoad loads the missing values once per key and instance however many
+// requests miss them concurrently, and the TTLs are shortened by a random
+// jitter, so that values cached together don't expire, and get reloaded,
+// together:
+//
+//	users := zicache.New[User]("users", zicache.NewRedis(client), zicache.WithTTL(10*time.Minute))
+//	user, err := users.GetOrLoad(ctx, id, func(ctx context.Context) (User, error) {
+//		return repo.User(ctx, id)
+//	})
+package zicache
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const instrumentationName = "github.com/divikraf/lumos/zicache"
+
+var (
+	tracer = observe.LibraryTracer(instrumentationName)
+	scope  = revelio.Extend(revelio.Library(instrumentationName))
+)
+
+// Loader loads the value of a key missing from a cache.
+type Loader[T any] func(ctx context.Context) (T, error)
+
+type options struct {
+	codec  Codec
+	ttl    time.Duration
+	jitter float64
+}
+
+// Option configures a Cache.
+type Option func(*options)
+
+// WithCodec sets the codec of the values (default: JSONCodec).
+func WithCodec(codec Codec) Option {
+	return func(o *options) {
+		o.codec = codec
+	}
+}
+
+// WithTTL sets the TTL of the values (default: 5m), zero caching them
+// until evicted.
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// WithJitter sets the fraction of the TTL randomly taken off the TTL of each
+// value (default: 0.1), from 0 to 1.
+func WithJitter(jitter float64) Option {
+	return func(o *options) {
+		o.jitter = min(max(jitter, 0), 1)
+	}
+}
+
+// Cache is a cache of T values in a backend, under keys prefixed with the
+// name of the cache. Lookups are counted in cache_requests_total by cache
+// and result, hit or miss, the backend operations timed in
+// cache_operation_duration_ms, and the loads counted in cache_loads_total,
+// or cache_loads_deduplicated_total for the ones sharing a concurrent load.
+type Cache[T any] struct {
+	name    string
+	backend Backend
+	options
+	group group[T]
+
+	requests     metric.Int64Counter
+	duration     revelio.DurationRecorder
+	loads        revelio.ResultCounter
+	deduplicated metric.Int64Counter
+}
+
+// New returns the cache name of T values in backend.
+func New[T any](name string, backend Backend, opts ...Option) *Cache[T] {
+	c := &Cache[T]{
+		name:    name,
+		backend: backend,
+		options: options{codec: JSONCodec{}, ttl: 5 * time.Minute, jitter: 0.1},
+		requests: revelio.Must(scope.Int64Counter(
+			"cache_requests_total",
+			"Number of cache lookups by cache and result (hit or miss)",
+		)),
+		duration: revelio.Must(scope.Duration("cache_operation_duration_ms", "Duration of cache backend operations in milliseconds")),
+		loads:    revelio.Must(scope.ResultCounter("cache_loads_total", "Number of loads of missing cache values by status and error type")),
+		deduplicated: revelio.Must(scope.Int64Counter(
+			"cache_loads_deduplicated_total",
+			"Number of cache misses served by a concurrent load of the same key",
+		)),
+	}
+	for _, o := range opts {
+		o(&c.options)
+	}
+	return c
+}
+
+// Name returns the name of the cache.
+func (c *Cache[T]) Name() string {
+	return c.name
+}
+
+func (c *Cache[T]) key(key string) string {
+	return c.name + ":" + key
+}
+
+// do runs the backend operation op, timed.
+func (c *Cache[T]) do(ctx context.Context, op string, f func(ctx context.Context) error) error {
+	start := time.Now()
+	err := f(ctx)
+	status := revelio.StatusSuccess
+	if err != nil {
+		status = revelio.StatusFailure
+	}
+	c.duration.Record(ctx, time.Since(start),
+		attribute.String("cache", c.name),
+		attribute.String("operation", op),
+		revelio.StatusKey.String(status),
+	)
+	return err
+}
+
+// Get returns the value of key, and false when it isn't cached.
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	var (
+		v     T
+		data  []byte
+		found bool
+	)
+	err := c.do(ctx, "get", func(ctx context.Context) (err error) {
+		data, found, err = c.backend.Get(ctx, c.key(key))
+		return err
+	})
+	if err != nil {
+		return v, false, err
+	}
+	result := "miss"
+	if found {
+		result = "hit"
+	}
+	c.requests.Add(ctx, 1, metric.WithAttributes(attribute.String("cache", c.name), attribute.String("result", result)))
+	if !found {
+		return v, false, nil
+	}
+	if err := c.codec.Unmarshal(data, &v); err != nil {
+		return v, false, fmt.Errorf("zicache: failed to decode %s: %w", c.key(key), err)
+	}
+	return v, true, nil
+}
+
+// Set caches the value of key.
+func (c *Cache[T]) Set(ctx context.Context, key string, v T) error {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("zicache: failed to encode %s: %w", c.key(key), err)
+	}
+	return c.do(ctx, "set", func(ctx context.Context) error {
+		return c.backend.Set(ctx, c.key(key), data, c.jittered())
+	})
+}
+
+// Delete deletes the values of keys.
+func (c *Cache[T]) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.key(key)
+	}
+	return c.do(ctx, "delete", func(ctx context.Context) error {
+		return c.backend.Delete(ctx, prefixed...)
+	})
+}
+
+// GetOrLoad returns the value of key, loaded with loader and cached when
+// missing. The concurrent misses of key share a single load. The cache is
+// best effort: when the backend fails, the value is loaded, and the failures
+// are logged.
+func (c *Cache[T]) GetOrLoad(ctx context.Context, key string, loader Loader[T]) (T, error) {
+	v, found, err := c.Get(ctx, key)
+	if err != nil {
+		zilog.FromContext(ctx).Warn().Err(err).Str("cache", c.name).Str("key", key).Msg("Failed to read a cached value")
+	} else if found {
+		return v, nil
+	}
+
+	v, shared, err := c.group.do(ctx, key, func(ctx context.Context) (T, error) {
+		ctx, span := tracer.Start(ctx, "cache.load")
+		defer span.End()
+		span.SetAttributes(attribute.String("cache.name", c.name), attribute.String("cache.key", key))
+
+		v, err := loader(ctx)
+		c.loads.Record(ctx, err, attribute.String("cache", c.name))
+		if err != nil {
+			observe.RecordError(span, err)
+			return v, err
+		}
+		if err := c.Set(ctx, key, v); err != nil {
+			zilog.FromContext(ctx).Warn().Err(err).Str("cache", c.name).Str("key", key).Msg("Failed to cache a loaded value")
+		}
+		return v, nil
+	})
+	if shared {
+		c.deduplicated.Add(ctx, 1, metric.WithAttributes(attribute.String("cache", c.name)))
+	}
+	return v, err
+}
+
+// jittered returns the TTL of a value, with a random jitter taken off.
+func (c *Cache[T]) jittered() time.Duration {
+	if c.ttl <= 0 || c.jitter == 0 {
+		return c.ttl
+	}
+	return c.ttl - time.Duration(rand.Float64()*c.jitter*float64(c.ttl))
+}
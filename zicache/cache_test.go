@@ -0,0 +1,181 @@
+package zicache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type user struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// failingBackend is a Backend whose operations fail.
+type failingBackend struct{}
+
+func (failingBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return nil, false, errors.New("connection refused")
+}
+
+func (failingBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return errors.New("connection refused")
+}
+
+func (failingBackend) Delete(ctx context.Context, keys ...string) error {
+	return errors.New("connection refused")
+}
+
+// countingBackend is a Backend counting the lookups of another.
+type countingBackend struct {
+	Backend
+	gets atomic.Int32
+}
+
+func (b *countingBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	defer b.gets.Add(1)
+	return b.Backend.Get(ctx, key)
+}
+
+func TestCache(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	ctx := context.Background()
+	lru := NewLRU(10)
+	users := New[user]("users", lru)
+
+	if _, ok, err := users.Get(ctx, "u-1"); ok || err != nil {
+		t.Fatalf("Get() of a missing key = %v, %v", ok, err)
+	}
+	if err := users.Set(ctx, "u-1", user{ID: "u-1", Name: "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _, _ := lru.Get(ctx, "users:u-1"); string(data) != `{"id":"u-1","name":"Ada"}` {
+		t.Errorf("stored %q under users:u-1", data)
+	}
+	got, ok, err := users.Get(ctx, "u-1")
+	if err != nil || !ok || got != (user{ID: "u-1", Name: "Ada"}) {
+		t.Fatalf("Get() = %+v, %v, %v", got, ok, err)
+	}
+
+	if err := users.Delete(ctx, "u-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := users.Get(ctx, "u-1"); ok {
+		t.Error("u-1 still cached after Delete()")
+	}
+
+	lru.Set(ctx, "users:broken", []byte("{"), 0)
+	if _, ok, err := users.Get(ctx, "broken"); ok || err == nil {
+		t.Errorf("Get() of an undecodable value = %v, %v, want an error", ok, err)
+	}
+
+	reveliotest.AssertCounterValue(t, s, "cache_requests_total", 2, attribute.String("cache", "users"), attribute.String("result", "hit"))
+	reveliotest.AssertCounterValue(t, s, "cache_requests_total", 2, attribute.String("result", "miss"))
+}
+
+func TestCacheGetOrLoad(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	ctx := context.Background()
+	backend := &countingBackend{Backend: NewLRU(10)}
+	users := New[user]("users", backend)
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	loader := func(ctx context.Context) (user, error) {
+		loads.Add(1)
+		<-release
+		return user{ID: "u-1", Name: "Ada"}, nil
+	}
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, err := users.GetOrLoad(ctx, "u-1", loader); err != nil || got.Name != "Ada" {
+				t.Errorf("GetOrLoad() = %+v, %v", got, err)
+			}
+		}()
+	}
+	// Wait for the callers to miss, and join the load, before loading
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if backend.gets.Load() == 5 {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads.Load() != 1 {
+		t.Errorf("loaded %d times, want once", loads.Load())
+	}
+	if _, err := users.GetOrLoad(ctx, "u-1", loader); err != nil || loads.Load() != 1 {
+		t.Errorf("GetOrLoad() of a cached value = %v, loaded %d times", err, loads.Load())
+	}
+
+	failed := errors.New("not found")
+	if _, err := users.GetOrLoad(ctx, "u-2", func(ctx context.Context) (user, error) { return user{}, failed }); !errors.Is(err, failed) {
+		t.Errorf("GetOrLoad() = %v, want the loader error", err)
+	}
+	if _, ok, _ := users.Get(ctx, "u-2"); ok {
+		t.Error("cached the value of a failed load")
+	}
+
+	reveliotest.AssertCounterValue(t, s, "cache_loads_total", 1, attribute.String("status", "success"))
+	reveliotest.AssertCounterValue(t, s, "cache_loads_total", 1, attribute.String("status", "failure"))
+	reveliotest.AssertCounterValue(t, s, "cache_loads_deduplicated_total", 4, attribute.String("cache", "users"))
+}
+
+func TestCacheGetOrLoadBackendFailure(t *testing.T) {
+	users := New[user]("users", failingBackend{})
+	got, err := users.GetOrLoad(context.Background(), "u-1", func(ctx context.Context) (user, error) {
+		return user{ID: "u-1"}, nil
+	})
+	if err != nil || got.ID != "u-1" {
+		t.Errorf("GetOrLoad() = %+v, %v, want the loaded value", got, err)
+	}
+}
+
+func TestCacheGetOrLoadCanceled(t *testing.T) {
+	users := New[user]("users", NewLRU(10))
+	release := make(chan struct{})
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := users.GetOrLoad(ctx, "u-1", func(ctx context.Context) (user, error) {
+		<-release
+		return user{}, ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("GetOrLoad() = %v, want context.Canceled", err)
+	}
+}
+
+func TestCacheGetOrLoadPanic(t *testing.T) {
+	users := New[user]("users", NewLRU(10))
+	if _, err := users.GetOrLoad(context.Background(), "u-1", func(ctx context.Context) (user, error) {
+		panic("boom")
+	}); err == nil {
+		t.Error("GetOrLoad() of a panicking loader succeeded")
+	}
+}
+
+func TestCacheJitter(t *testing.T) {
+	c := New[user]("users", NewLRU(10), WithTTL(time.Minute), WithJitter(0.2))
+	for range 100 {
+		if ttl := c.jittered(); ttl <= 48*time.Second || ttl > time.Minute {
+			t.Fatalf("jittered ttl = %s, want within (48s, 1m]", ttl)
+		}
+	}
+	if ttl := New[user]("users", NewLRU(10), WithTTL(0)).jittered(); ttl != 0 {
+		t.Errorf("jittered ttl = %s, want no expiration", ttl)
+	}
+}
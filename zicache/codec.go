@@ -0,0 +1,41 @@
+package zicache
+
+import (
+	"encoding/json"
+
+	"github.com/ugorji/go/codec"
+)
+
+// Codec encodes the values of a Cache.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec encodes values in JSON.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (JSONCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// msgpackHandle encodes structs as maps keyed by their codec or json tags,
+// and times with the msgpack timestamp extension.
+var msgpackHandle = func() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{WriteExt: true}
+	h.RawToString = true
+	return h
+}()
+
+// MsgpackCodec encodes values in MessagePack, more compact and faster to
+// decode than JSON.
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) Marshal(v any) ([]byte, error) {
+	var data []byte
+	err := codec.NewEncoderBytes(&data, msgpackHandle).Encode(v)
+	return data, err
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v any) error {
+	return codec.NewDecoderBytes(data, msgpackHandle).Decode(v)
+}
@@ -0,0 +1,37 @@
+package zicache
+
+import (
+	"testing"
+	"time"
+)
+
+type order struct {
+	ID      string            `json:"id"`
+	Total   int64             `json:"total"`
+	Items   []string          `json:"items"`
+	Labels  map[string]string `json:"labels"`
+	Created time.Time         `json:"created"`
+}
+
+func TestCodecs(t *testing.T) {
+	want := order{
+		ID:      "o-1",
+		Total:   4200,
+		Items:   []string{"book", "pen"},
+		Labels:  map[string]string{"channel": "web"},
+		Created: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+	}
+	for name, codec := range map[string]Codec{"json": JSONCodec{}, "msgpack": MsgpackCodec{}} {
+		data, err := codec.Marshal(want)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var got order
+		if err := codec.Unmarshal(data, &got); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got.ID != want.ID || got.Total != want.Total || len(got.Items) != 2 || got.Labels["channel"] != "web" || !got.Created.Equal(want.Created) {
+			t.Errorf("%s: decoded %+v, want %+v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package zicache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// group deduplicates the concurrent loads of a key.
+type group[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*call[T]
+}
+
+type call[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+// do runs fn once for the concurrent calls of key, and reports whether the
+// result was shared with another call. fn runs detached from the
+// cancellation of ctx, so that a caller giving up doesn't fail the others,
+// while each caller stops waiting when its own ctx is done.
+func (g *group[T]) do(ctx context.Context, key string, fn func(ctx context.Context) (T, error)) (T, bool, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*call[T]{}
+	}
+	c, shared := g.calls[key]
+	if !shared {
+		c = &call[T]{done: make(chan struct{})}
+		g.calls[key] = c
+		go g.run(context.WithoutCancel(ctx), key, c, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.value, shared, c.err
+	case <-ctx.Done():
+		var zero T
+		return zero, shared, ctx.Err()
+	}
+}
+
+func (g *group[T]) run(ctx context.Context, key string, c *call[T], fn func(ctx context.Context) (T, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = fmt.Errorf("zicache: loader of %s panicked: %v", key, r)
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.value, c.err = fn(ctx)
+}
@@ -0,0 +1,98 @@
+package zicache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// LRU is an in-process Backend holding up to a number of values, evicting
+// the least recently used ones.
+type LRU struct {
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewLRU returns an LRU of up to size values (default: 10000).
+func NewLRU(size int) *LRU {
+	if size <= 0 {
+		size = 10000
+	}
+	return &LRU{
+		size:    size,
+		now:     time.Now,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+func (l *LRU) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := e.Value.(*lruEntry)
+	if !entry.expires.IsZero() && !l.now().Before(entry.expires) {
+		l.remove(e)
+		return nil, false, nil
+	}
+	l.order.MoveToFront(e)
+	return entry.value, true, nil
+}
+
+func (l *LRU) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	var expires time.Time
+	if ttl > 0 {
+		expires = l.now().Add(ttl)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.entries[key]; ok {
+		entry := e.Value.(*lruEntry)
+		entry.value, entry.expires = value, expires
+		l.order.MoveToFront(e)
+		return nil
+	}
+	l.entries[key] = l.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for l.order.Len() > l.size {
+		l.remove(l.order.Back())
+	}
+	return nil
+}
+
+func (l *LRU) Delete(ctx context.Context, keys ...string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		if e, ok := l.entries[key]; ok {
+			l.remove(e)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of values held, including expired ones not evicted
+// yet.
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+func (l *LRU) remove(e *list.Element) {
+	l.order.Remove(e)
+	delete(l.entries, e.Value.(*lruEntry).key)
+}
@@ -0,0 +1,39 @@
+package zicache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	lru := NewLRU(2)
+	lru.now = func() time.Time { return now }
+
+	lru.Set(ctx, "a", []byte("1"), 0)
+	lru.Set(ctx, "b", []byte("2"), time.Minute)
+	lru.Get(ctx, "a")
+	lru.Set(ctx, "c", []byte("3"), 0)
+	if _, ok, _ := lru.Get(ctx, "b"); ok {
+		t.Error("b not evicted as the least recently used")
+	}
+	if v, ok, _ := lru.Get(ctx, "a"); !ok || string(v) != "1" {
+		t.Errorf("Get(a) = %q, %v", v, ok)
+	}
+
+	lru.Set(ctx, "c", []byte("4"), time.Minute)
+	now = now.Add(time.Minute)
+	if _, ok, _ := lru.Get(ctx, "c"); ok {
+		t.Error("c not expired")
+	}
+	if lru.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", lru.Len())
+	}
+
+	lru.Delete(ctx, "a", "missing")
+	if _, ok, _ := lru.Get(ctx, "a"); ok || lru.Len() != 0 {
+		t.Errorf("a not deleted, Len() = %d", lru.Len())
+	}
+}
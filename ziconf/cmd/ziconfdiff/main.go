@@ -0,0 +1,72 @@
+// Command ziconfdiff prints the differences between the effective values of
+// two configurations, e.g. staging and production:
+//
+//	ziconfdiff -from config.yaml,config.staging.yaml -to config.yaml,config.production.yaml
+//
+// Each side is a base file followed by the overlays merged on top of it.
+// Secret values are masked. With -fail-on-removed, the command exits with
+// status 1 when keys of -from are missing from -to.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/divikraf/lumos/ziconf"
+)
+
+func main() {
+	from := flag.String("from", "", "comma separated base config and overlays to compare from")
+	to := flag.String("to", "", "comma separated base config and overlays to compare to")
+	secretKeys := flag.String("secret-keys", strings.Join(ziconf.DefaultSecretKeys, ","), "comma separated key fragments whose values are masked")
+	asJSON := flag.Bool("json", false, "print the changes as JSON")
+	failOnRemoved := flag.Bool("fail-on-removed", false, "exit with status 1 when keys are missing from -to")
+	flag.Parse()
+
+	if *from == "" || *to == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	fromSettings, err := load(*from)
+	if err != nil {
+		fatal(err)
+	}
+	toSettings, err := load(*to)
+	if err != nil {
+		fatal(err)
+	}
+
+	changes := ziconf.Diff(fromSettings, toSettings, strings.Split(*secretKeys, ",")...)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(changes)
+	} else {
+		err = ziconf.WriteDiff(os.Stdout, changes)
+	}
+	if err != nil {
+		fatal(err)
+	}
+
+	if *failOnRemoved {
+		for _, c := range changes {
+			if c.Kind == ziconf.ChangeRemoved {
+				os.Exit(1)
+			}
+		}
+	}
+}
+
+func load(files string) (map[string]any, error) {
+	paths := strings.Split(files, ",")
+	return ziconf.LoadSettings(paths[0], paths[1:]...)
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(2)
+}
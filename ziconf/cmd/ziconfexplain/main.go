@@ -0,0 +1,83 @@
+// Command ziconfexplain prints the effective values of a configuration and
+// the sources supplying them:
+//
+//	ziconfexplain -config config.yaml,config.production.yaml -env -env-prefix app http.port
+//	http.port = 9090 (env APP_HTTP_PORT)
+//
+// The configuration is a base file followed by the overlays merged on top of
+// it. Without keys, every key of the files is explained. Secret values are
+// masked.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/divikraf/lumos/ziconf"
+)
+
+func main() {
+	config := flag.String("config", "config.yaml", "comma separated base config and overlays")
+	env := flag.Bool("env", false, "let environment variables override the files")
+	envPrefix := flag.String("env-prefix", "", "prefix of the environment variables")
+	secretKeys := flag.String("secret-keys", strings.Join(ziconf.DefaultSecretKeys, ","), "comma separated key fragments whose values are masked")
+	asJSON := flag.Bool("json", false, "print the values as JSON")
+	flag.Parse()
+
+	files := strings.Split(*config, ",")
+	layers := ziconf.Layers{
+		File:      files[0],
+		Overlays:  files[1:],
+		Env:       *env,
+		EnvPrefix: *envPrefix,
+	}
+
+	var explained []ziconf.Provenance
+	if flag.NArg() == 0 {
+		var err error
+		if explained, err = layers.ExplainAll(); err != nil {
+			fatal(err)
+		}
+	}
+	for _, key := range flag.Args() {
+		p, err := layers.Explain(key)
+		if errors.Is(err, ziconf.ErrUnknownKey) {
+			fmt.Fprintf(os.Stderr, "%s is not set\n", key)
+			continue
+		}
+		if err != nil {
+			fatal(err)
+		}
+		explained = append(explained, p)
+	}
+
+	secrets := strings.Split(*secretKeys, ",")
+	for i, p := range explained {
+		for _, s := range secrets {
+			if strings.Contains(p.Key, s) {
+				explained[i].Value = ziconf.MaskedValue
+			}
+		}
+	}
+
+	var err error
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(explained)
+	} else {
+		err = ziconf.WriteExplained(os.Stdout, explained)
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(2)
+}
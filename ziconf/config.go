@@ -34,6 +34,14 @@ func ReadConfig[T Config]() *T {
 
 		return viper.Unmarshal(&cfg, func(dc *mapstructure.DecoderConfig) {
 			dc.TagName = "json"
+			// Keep viper's default hooks, and let fields implementing
+			// encoding.TextUnmarshaler (e.g. zicron.Expression) validate
+			// themselves at load
+			dc.DecodeHook = mapstructure.ComposeDecodeHookFunc(
+				mapstructure.StringToTimeDurationHookFunc(),
+				mapstructure.StringToSliceHookFunc(","),
+				mapstructure.TextUnmarshallerHookFunc(),
+			)
 		})
 	}
 
@@ -41,5 +49,9 @@ func ReadConfig[T Config]() *T {
 		panic(err)
 	}
 
+	loadedMu.Lock()
+	loaded = &Layers{File: viper.ConfigFileUsed()}
+	loadedMu.Unlock()
+
 	return &cfg
 }
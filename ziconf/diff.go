@@ -0,0 +1,114 @@
+package ziconf
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// MaskedValue replaces the values of secret keys in diffs.
+const MaskedValue = "******"
+
+// DefaultSecretKeys are the key fragments identifying secret values.
+var DefaultSecretKeys = []string{"password", "secret", "token", "api_key", "apikey", "private_key", "credential", "dsn"}
+
+// ChangeKind is the kind of a configuration change.
+type ChangeKind string
+
+const (
+	ChangeAdded   ChangeKind = "added"
+	ChangeRemoved ChangeKind = "removed"
+	ChangeUpdated ChangeKind = "updated"
+)
+
+// Change is a difference between two configurations, for a single key.
+type Change struct {
+	Key  string     `json:"key"`
+	Kind ChangeKind `json:"kind"`
+	From any        `json:"from,omitempty"`
+	To   any        `json:"to,omitempty"`
+}
+
+// LoadSettings loads the effective settings of a configuration made of a base
+// file and optional overlays merged on top of it, in order. Keys are
+// flattened with dots, e.g. "telemetry.tracing.enabled".
+func LoadSettings(base string, overlays ...string) (map[string]any, error) {
+	v := viper.New()
+	v.SetConfigFile(base)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("ziconf: failed to read %s: %w", base, err)
+	}
+	for _, overlay := range overlays {
+		v.SetConfigFile(overlay)
+		if err := v.MergeInConfig(); err != nil {
+			return nil, fmt.Errorf("ziconf: failed to merge %s: %w", overlay, err)
+		}
+	}
+
+	settings := make(map[string]any, len(v.AllKeys()))
+	for _, key := range v.AllKeys() {
+		settings[key] = v.Get(key)
+	}
+	return settings, nil
+}
+
+// Diff returns the changes from the settings from to the settings to, sorted
+// by key. Values of keys containing one of secretKeys are masked, changes to
+// them are still reported. DefaultSecretKeys are used when none are given.
+func Diff(from, to map[string]any, secretKeys ...string) []Change {
+	if len(secretKeys) == 0 {
+		secretKeys = DefaultSecretKeys
+	}
+	mask := func(key string, value any) any {
+		lower := strings.ToLower(key)
+		for _, s := range secretKeys {
+			if strings.Contains(lower, s) {
+				return MaskedValue
+			}
+		}
+		return value
+	}
+
+	var changes []Change
+	for key, fromValue := range from {
+		toValue, ok := to[key]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Key: key, Kind: ChangeRemoved, From: mask(key, fromValue)})
+		case !reflect.DeepEqual(fromValue, toValue):
+			changes = append(changes, Change{Key: key, Kind: ChangeUpdated, From: mask(key, fromValue), To: mask(key, toValue)})
+		}
+	}
+	for key, toValue := range to {
+		if _, ok := from[key]; !ok {
+			changes = append(changes, Change{Key: key, Kind: ChangeAdded, To: mask(key, toValue)})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// WriteDiff writes changes to w, one per line: "+" for added keys, "-" for
+// removed keys and "~" for updated ones.
+func WriteDiff(w io.Writer, changes []Change) error {
+	for _, c := range changes {
+		var err error
+		switch c.Kind {
+		case ChangeAdded:
+			_, err = fmt.Fprintf(w, "+ %s: %v\n", c.Key, c.To)
+		case ChangeRemoved:
+			_, err = fmt.Fprintf(w, "- %s: %v\n", c.Key, c.From)
+		default:
+			_, err = fmt.Fprintf(w, "~ %s: %v -> %v\n", c.Key, c.From, c.To)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
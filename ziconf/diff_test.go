@@ -0,0 +1,64 @@
+package ziconf
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	base := write("config.yaml", `
+service:
+  name: orders
+http_port: ":8080"
+db:
+  host: localhost
+  password: local
+`)
+	staging := write("config.staging.yaml", `
+db:
+  host: staging-db
+  password: staging
+cache:
+  ttl: 1m
+`)
+	production := write("config.production.yaml", `
+db:
+  host: prod-db
+  password: production
+`)
+
+	from, err := LoadSettings(base, staging)
+	if err != nil {
+		t.Fatalf("LoadSettings() error = %v", err)
+	}
+	to, err := LoadSettings(base, production)
+	if err != nil {
+		t.Fatalf("LoadSettings() error = %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := WriteDiff(&out, Diff(from, to)); err != nil {
+		t.Fatalf("WriteDiff() error = %v", err)
+	}
+	want := "- cache.ttl: 1m\n" +
+		"~ db.host: staging-db -> prod-db\n" +
+		"~ db.password: ****** -> ******\n"
+	if out.String() != want {
+		t.Errorf("diff =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	if _, err := LoadSettings(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("LoadSettings() should fail on missing files")
+	}
+}
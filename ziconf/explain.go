@@ -0,0 +1,228 @@
+package ziconf
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// ErrUnknownKey is returned by Explain for keys set by no source.
+var ErrUnknownKey = errors.New("ziconf: unknown key")
+
+// Source is a source of configuration values, see Layers.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceRemote  Source = "remote"
+	SourceFile    Source = "file"
+	SourceOverlay Source = "overlay"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+// Provenance is the effective value of a key and the source supplying it.
+type Provenance struct {
+	Key    string `json:"key"`
+	Value  any    `json:"value"`
+	Source Source `json:"source"`
+	// Origin locates the value in its source, e.g. the file path, the
+	// environment variable or the flag name.
+	Origin string `json:"origin,omitempty"`
+}
+
+// String returns e.g. "http.port = 8080 (overlay config.production.yaml)".
+func (p Provenance) String() string {
+	if p.Origin == "" {
+		return fmt.Sprintf("%s = %v (%s)", p.Key, p.Value, p.Source)
+	}
+	return fmt.Sprintf("%s = %v (%s %s)", p.Key, p.Value, p.Source, p.Origin)
+}
+
+// Layers are the sources of a configuration, by increasing precedence, as
+// with viper: defaults, remote values, the file, its overlays in order,
+// environment variables and flags. Keys are flattened with dots, e.g.
+// "http.port".
+type Layers struct {
+	Defaults map[string]any
+	Remote   map[string]any
+	File     string
+	Overlays []string
+	// Env enables the environment variables, named after the keys with
+	// EnvPrefix, see EnvName.
+	Env       bool
+	EnvPrefix string
+	// Flags are the command line flags, named after the keys. Only the flags
+	// set override the other sources.
+	Flags *flag.FlagSet
+}
+
+// EnvName returns the environment variable of key, e.g. "APP_HTTP_PORT" for
+// "http.port" with the prefix "app".
+func EnvName(prefix, key string) string {
+	name := strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+	if prefix != "" {
+		name = strings.ToUpper(prefix) + "_" + name
+	}
+	return name
+}
+
+// layer is a source with its flattened settings.
+type layer struct {
+	source   Source
+	origin   string
+	settings map[string]any
+}
+
+// lookup returns the value of key in the layer, and its origin. Environment
+// variables are looked up per key, the origin of the layer being the prefix.
+func (ly layer) lookup(key string) (any, string, bool) {
+	if ly.source == SourceEnv {
+		name := EnvName(ly.origin, key)
+		value, ok := os.LookupEnv(name)
+		return value, name, ok
+	}
+	value, ok := ly.settings[key]
+	if ly.source == SourceFlag {
+		return value, "-" + key, ok
+	}
+	return value, ly.origin, ok
+}
+
+// load returns the layers of the sources, by decreasing precedence.
+func (l Layers) load() ([]layer, error) {
+	var layers []layer
+	if l.Flags != nil {
+		settings := map[string]any{}
+		l.Flags.Visit(func(f *flag.Flag) {
+			settings[strings.ToLower(f.Name)] = f.Value.String()
+		})
+		layers = append(layers, layer{source: SourceFlag, settings: settings})
+	}
+	if l.Env {
+		layers = append(layers, layer{source: SourceEnv, origin: l.EnvPrefix})
+	}
+	for i := len(l.Overlays) - 1; i >= 0; i-- {
+		settings, err := readSettings(l.Overlays[i])
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer{source: SourceOverlay, origin: l.Overlays[i], settings: settings})
+	}
+	if l.File != "" {
+		settings, err := readSettings(l.File)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer{source: SourceFile, origin: l.File, settings: settings})
+	}
+	return append(layers,
+		layer{source: SourceRemote, settings: flatten(l.Remote)},
+		layer{source: SourceDefault, settings: flatten(l.Defaults)},
+	), nil
+}
+
+// Explain returns the effective value of key and the source supplying it.
+func (l Layers) Explain(key string) (Provenance, error) {
+	layers, err := l.load()
+	if err != nil {
+		return Provenance{}, err
+	}
+	if p, ok := explain(layers, strings.ToLower(key)); ok {
+		return p, nil
+	}
+	return Provenance{}, fmt.Errorf("%w: %s", ErrUnknownKey, key)
+}
+
+// ExplainAll explains every key set by the sources, sorted. Environment
+// variables are only looked up for the keys of the other sources.
+func (l Layers) ExplainAll() ([]Provenance, error) {
+	layers, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string]bool{}
+	for _, ly := range layers {
+		for key := range ly.settings {
+			keys[key] = true
+		}
+	}
+	explained := make([]Provenance, 0, len(keys))
+	for key := range keys {
+		p, _ := explain(layers, key)
+		explained = append(explained, p)
+	}
+	sort.Slice(explained, func(i, j int) bool { return explained[i].Key < explained[j].Key })
+	return explained, nil
+}
+
+// explain returns the value of key in the first layer setting it.
+func explain(layers []layer, key string) (Provenance, bool) {
+	for _, ly := range layers {
+		if value, origin, ok := ly.lookup(key); ok {
+			return Provenance{Key: key, Value: value, Source: ly.source, Origin: origin}, true
+		}
+	}
+	return Provenance{}, false
+}
+
+// readSettings reads the flattened settings of a file.
+func readSettings(path string) (map[string]any, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("ziconf: failed to read %s: %w", path, err)
+	}
+	settings := make(map[string]any, len(v.AllKeys()))
+	for _, key := range v.AllKeys() {
+		settings[key] = v.Get(key)
+	}
+	return settings, nil
+}
+
+// flatten flattens nested maps, joining their keys with dots.
+func flatten(m map[string]any) map[string]any {
+	v := viper.New()
+	for key, value := range m {
+		v.Set(key, value)
+	}
+	settings := make(map[string]any, len(m))
+	for _, key := range v.AllKeys() {
+		settings[key] = v.Get(key)
+	}
+	return settings
+}
+
+var (
+	loadedMu sync.RWMutex
+	loaded   *Layers
+)
+
+// Explain returns the effective value of key in the configuration read by
+// ReadConfig, and the source supplying it.
+func Explain(key string) (Provenance, error) {
+	loadedMu.RLock()
+	l := loaded
+	loadedMu.RUnlock()
+	if l == nil {
+		return Provenance{}, errors.New("ziconf: no configuration read")
+	}
+	return l.Explain(key)
+}
+
+// WriteExplained writes explained to w, one key per line.
+func WriteExplained(w io.Writer, explained []Provenance) error {
+	for _, p := range explained {
+		if _, err := fmt.Fprintln(w, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
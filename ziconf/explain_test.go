@@ -0,0 +1,82 @@
+package ziconf
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	base := write("config.yaml", `
+http:
+  port: 8080
+  timeout: 5s
+db:
+  host: localhost
+log:
+  level: info
+`)
+	production := write("config.production.yaml", `
+db:
+  host: prod-db
+`)
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.String("log.level", "info", "")
+	if err := flags.Parse([]string{"-log.level=debug"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("APP_HTTP_PORT", "9090")
+
+	layers := Layers{
+		Defaults:  map[string]any{"http": map[string]any{"idle_timeout": "1m"}},
+		File:      base,
+		Overlays:  []string{production},
+		Env:       true,
+		EnvPrefix: "app",
+		Flags:     flags,
+	}
+	tests := []struct {
+		key    string
+		value  any
+		source Source
+		origin string
+	}{
+		{key: "http.port", value: "9090", source: SourceEnv, origin: "APP_HTTP_PORT"},
+		{key: "http.timeout", value: "5s", source: SourceFile, origin: base},
+		{key: "http.idle_timeout", value: "1m", source: SourceDefault},
+		{key: "db.host", value: "prod-db", source: SourceOverlay, origin: production},
+		{key: "log.level", value: "debug", source: SourceFlag, origin: "-log.level"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			p, err := layers.Explain(tt.key)
+			if err != nil {
+				t.Fatalf("Explain: %v", err)
+			}
+			if p.Value != tt.value || p.Source != tt.source || p.Origin != tt.origin {
+				t.Errorf("Explain = %v, want %v from %s %s", p, tt.value, tt.source, tt.origin)
+			}
+		})
+	}
+
+	if _, err := layers.Explain("http.unknown"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Explain of an unknown key = %v, want %v", err, ErrUnknownKey)
+	}
+	all, err := layers.ExplainAll()
+	if err != nil {
+		t.Fatalf("ExplainAll: %v", err)
+	}
+	if len(all) != len(tests) || all[0].Key != "db.host" {
+		t.Errorf("ExplainAll = %v, want the %d keys sorted", all, len(tests))
+	}
+}
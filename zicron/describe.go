@@ -0,0 +1,162 @@
+package zicron
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxListedTimes is the maximum number of HH:MM times listed in a description
+const maxListedTimes = 6
+
+// Describe returns a human readable description of the schedule, e.g. "every
+// 5 minutes" or "at 09:00 on Monday through Friday".
+func (s *Schedule) Describe() string {
+	if s.every > 0 {
+		return "every " + s.every.String()
+	}
+
+	phrases := []string{s.describeTime()}
+
+	domStar, dowStar := s.dom.star(), s.dow.star()
+	switch {
+	case !domStar && !dowStar:
+		phrases = append(phrases, "on "+describeField(s.dom)+" of the month or on "+describeField(s.dow))
+	case !domStar:
+		phrases = append(phrases, "on "+describeField(s.dom)+" of the month")
+	case !dowStar:
+		phrases = append(phrases, "on "+describeField(s.dow))
+	}
+
+	if !s.month.star() {
+		phrases = append(phrases, "in "+describeField(s.month))
+	}
+
+	desc := strings.Join(phrases, " ")
+	if s.loc != nil && s.loc.String() != "Local" {
+		desc += " (" + s.loc.String() + ")"
+	}
+	return desc
+}
+
+func (s *Schedule) describeTime() string {
+	m, h := s.minute, s.hour
+
+	switch {
+	case m.star() && h.star():
+		return "every minute"
+	case m.star():
+		return "every minute of " + describeField(h)
+	case m.singles() && h.singles() && len(m.parts)*len(h.parts) <= maxListedTimes:
+		times := make([]string, 0, len(m.parts)*len(h.parts))
+		for _, hp := range h.parts {
+			for _, mp := range m.parts {
+				times = append(times, fmt.Sprintf("%02d:%02d", hp.lo, mp.lo))
+			}
+		}
+		return "at " + joinAnd(times)
+	case h.star():
+		if m.singles() {
+			return "at " + describeField(m) + " past every hour"
+		}
+		return describeField(m)
+	default:
+		if m.singles() {
+			return "at " + describeField(m) + " past " + describeField(h)
+		}
+		return describeField(m) + " of " + describeField(h)
+	}
+}
+
+// singles reports whether the field only lists single values
+func (f field) singles() bool {
+	for _, p := range f.parts {
+		if p.star || p.lo != p.hi {
+			return false
+		}
+	}
+	return true
+}
+
+// describeField describes the values of a field, e.g. "minutes 0 and 30",
+// "every 2 hours", or "Monday through Friday".
+func describeField(f field) string {
+	named := f.unit.names != nil
+
+	if f.singles() {
+		values := make([]string, len(f.parts))
+		for i, p := range f.parts {
+			values[i] = f.unit.value(p.lo)
+		}
+		if named {
+			return joinAnd(values)
+		}
+		return f.unit.plural(len(values)) + " " + joinAnd(values)
+	}
+
+	descs := make([]string, len(f.parts))
+	for i, p := range f.parts {
+		descs[i] = describePart(p, f.unit)
+	}
+	return joinAnd(descs)
+}
+
+func describePart(p part, u unit) string {
+	named := u.names != nil
+
+	var every string
+	switch {
+	case p.star && p.step == 1:
+		return "every " + u.name
+	case p.lo == p.hi:
+		if named {
+			return u.value(p.lo)
+		}
+		return u.name + " " + u.value(p.lo)
+	case p.step == 1:
+		// Range without step
+		if named {
+			return u.value(p.lo) + " through " + u.value(p.hi)
+		}
+		return u.plural(2) + " " + u.value(p.lo) + " through " + u.value(p.hi)
+	default:
+		every = fmt.Sprintf("every %d %s", p.step, u.plural(p.step))
+	}
+
+	switch {
+	case p.star:
+		return every
+	case p.hi == u.max:
+		return every + " starting at " + u.name + " " + u.value(p.lo)
+	default:
+		return every + " from " + u.value(p.lo) + " through " + u.value(p.hi)
+	}
+}
+
+func (u unit) value(v int) string {
+	if u.names != nil {
+		return u.names[v]
+	}
+	return fmt.Sprint(v)
+}
+
+func (u unit) plural(n int) string {
+	if n == 1 {
+		return u.name
+	}
+	if u.name == dowUnit.name {
+		return "days of the week"
+	}
+	return u.name + "s"
+}
+
+// joinAnd joins items as "a, b and c"
+func joinAnd(items []string) string {
+	switch len(items) {
+	case 0:
+		return ""
+	case 1:
+		return items[0]
+	default:
+		return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+	}
+}
@@ -0,0 +1,390 @@
+// Package zicron parses and describes cron expressions, and runs jobs on
+// them with a Scheduler.
+//
+// The standard five field format (minute, hour, day of month, month, day of
+// week) is supported, with lists, ranges, steps, and month/weekday names, as
+// well as the @yearly, @monthly, @weekly, @daily, @hourly and @every <duration>
+// descriptors. An expression may be prefixed with CRON_TZ=<zone> (or TZ=) to
+// be evaluated in that timezone.
+//
+// A Scheduler runs every job on its schedule without overlapping itself,
+// and on a single instance of a cluster with a Locker, e.g. NewRedisLocker.
+// Its jobs, with their next runs, are listed by its admin endpoint, see
+// Scheduler.RegisterRoutes.
+package zicron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const packageName = "zicron"
+
+func errorf(format string, args ...any) error {
+	return fmt.Errorf(packageName+": "+format, args...)
+}
+
+// unit describes the valid values of a cron field
+type unit struct {
+	name  string
+	min   int
+	max   int
+	names []string
+}
+
+var (
+	minuteUnit = unit{name: "minute", min: 0, max: 59}
+	hourUnit   = unit{name: "hour", min: 0, max: 23}
+	domUnit    = unit{name: "day", min: 1, max: 31}
+	monthUnit  = unit{name: "month", min: 1, max: 12, names: []string{
+		"", "January", "February", "March", "April", "May", "June", "July",
+		"August", "September", "October", "November", "December",
+	}}
+	dowUnit = unit{name: "day of the week", min: 0, max: 6, names: []string{
+		"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday",
+	}}
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// part is one comma separated element of a field
+type part struct {
+	lo, hi, step int
+	star         bool
+}
+
+// field is a parsed cron field
+type field struct {
+	unit  unit
+	bits  uint64
+	parts []part
+}
+
+func (f field) has(v int) bool {
+	return f.bits&(1<<uint(v)) != 0
+}
+
+// star reports whether the field matches every value
+func (f field) star() bool {
+	return len(f.parts) == 1 && f.parts[0].star && f.parts[0].step == 1
+}
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+	expr  string
+	loc   *time.Location
+	every time.Duration
+
+	minute, hour, dom, month, dow field
+}
+
+// Parse parses a cron expression evaluated in the local timezone, unless the
+// expression carries a CRON_TZ= prefix.
+func Parse(expr string) (*Schedule, error) {
+	return ParseInLocation(expr, time.Local)
+}
+
+// MustParse is a syntactic sugar for [Parse].
+// This function will trigger panic when err is occurred.
+func MustParse(expr string) *Schedule {
+	s, err := Parse(expr)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// ParseInLocation parses a cron expression evaluated in loc, unless the
+// expression carries a CRON_TZ= prefix.
+func ParseInLocation(expr string, loc *time.Location) (*Schedule, error) {
+	s := &Schedule{expr: expr, loc: loc}
+
+	spec := strings.TrimSpace(expr)
+	if spec == "" {
+		return nil, errorf("empty expression")
+	}
+
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		tz, rest, _ := strings.Cut(spec, " ")
+		_, name, _ := strings.Cut(tz, "=")
+		l, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, errorf("invalid timezone %q: %w", name, err)
+		}
+		s.loc = l
+		spec = strings.TrimSpace(rest)
+	}
+
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, errorf("invalid @every duration: %w", err)
+		}
+		if d < time.Second {
+			return nil, errorf("@every duration must be at least 1s, got %s", d)
+		}
+		s.every = d
+		return s, nil
+	}
+
+	if strings.HasPrefix(spec, "@") {
+		std, ok := descriptors[spec]
+		if !ok {
+			return nil, errorf("unknown descriptor %q", spec)
+		}
+		spec = std
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, errorf("expected 5 fields, got %d in %q", len(fields), spec)
+	}
+
+	var err error
+	targets := []struct {
+		dst  *field
+		unit unit
+	}{
+		{&s.minute, minuteUnit},
+		{&s.hour, hourUnit},
+		{&s.dom, domUnit},
+		{&s.month, monthUnit},
+		{&s.dow, dowUnit},
+	}
+	for i, t := range targets {
+		if *t.dst, err = parseField(fields[i], t.unit); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func parseField(expr string, u unit) (field, error) {
+	f := field{unit: u}
+	for _, item := range strings.Split(expr, ",") {
+		p, err := parsePart(item, u)
+		if err != nil {
+			return field{}, errorf("invalid %s field %q: %w", u.name, expr, err)
+		}
+		parts := []part{p}
+		if u.name == dowUnit.name {
+			parts = sundays(p)
+		}
+		for _, p := range parts {
+			for v := p.lo; v <= p.hi; v += p.step {
+				f.bits |= 1 << uint(v)
+			}
+			f.parts = append(f.parts, p)
+		}
+	}
+	return f, nil
+}
+
+func parsePart(item string, u unit) (part, error) {
+	p := part{step: 1}
+
+	rng, stepStr, hasStep := strings.Cut(item, "/")
+	if hasStep {
+		step, err := strconv.Atoi(stepStr)
+		if err != nil || step <= 0 {
+			return part{}, fmt.Errorf("invalid step %q", stepStr)
+		}
+		p.step = step
+	}
+
+	switch {
+	case rng == "*" || rng == "?":
+		p.star = true
+		p.lo, p.hi = u.min, u.max
+	case strings.Contains(rng, "-"):
+		loStr, hiStr, _ := strings.Cut(rng, "-")
+		var err error
+		if p.lo, err = parseValue(loStr, u); err != nil {
+			return part{}, err
+		}
+		if p.hi, err = parseValue(hiStr, u); err != nil {
+			return part{}, err
+		}
+		if p.lo > p.hi {
+			return part{}, fmt.Errorf("range %q is reversed", rng)
+		}
+	default:
+		v, err := parseValue(rng, u)
+		if err != nil {
+			return part{}, err
+		}
+		p.lo, p.hi = v, v
+		if hasStep {
+			// a/n means starting at a through the end of the range
+			p.hi = max(u.max, v)
+		}
+	}
+	return p, nil
+}
+
+func parseValue(s string, u unit) (int, error) {
+	for i, name := range u.names {
+		if len(name) >= 3 && strings.EqualFold(s, name[:3]) {
+			return i, nil
+		}
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	hi := u.max
+	if u.name == dowUnit.name {
+		// 7 is an alias of Sunday, turned into 0 by parseField once the
+		// ranges are validated, so that 5-7 is Friday through Sunday
+		hi = 7
+	}
+	if v < u.min || v > hi {
+		return 0, fmt.Errorf("value %d out of range [%d, %d]", v, u.min, u.max)
+	}
+	return v, nil
+}
+
+// sundays splits a part of the day of the week field ending on 7 into the
+// part ending on Saturday, and Sunday (0) when the part includes it.
+func sundays(p part) []part {
+	if p.hi != 7 {
+		return []part{p}
+	}
+	sunday := part{lo: 0, hi: 0, step: 1}
+	if p.lo == 7 {
+		return []part{sunday}
+	}
+	parts := []part{{lo: p.lo, hi: 6, step: p.step}}
+	if p.lo > 0 && (7-p.lo)%p.step == 0 {
+		parts = append(parts, sunday)
+	}
+	return parts
+}
+
+// String returns the expression the schedule was parsed from.
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// Location returns the timezone the schedule is evaluated in.
+func (s *Schedule) Location() *time.Location {
+	return s.loc
+}
+
+// Next returns the first activation time strictly after t, in the location of
+// t. The zero time is returned when the schedule never activates, e.g. on
+// February 30th. The @every activations are multiples of the duration since
+// the Unix epoch, so they are the same on every instance of a cluster.
+func (s *Schedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		n := t.UnixNano() / int64(s.every)
+		return time.Unix(0, (n+1)*int64(s.every)).In(t.Location())
+	}
+
+	origLoc := t.Location()
+	t = t.In(s.loc)
+
+	// Start at the next whole minute
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, s.loc)
+	yearLimit := t.Year() + 5
+
+WRAP:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for !s.month.has(int(t.Month())) {
+		t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc)
+		if t.Month() == time.January {
+			goto WRAP
+		}
+	}
+
+	for !s.dayMatches(t) {
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc)
+		if t.Day() == 1 {
+			goto WRAP
+		}
+	}
+
+	for !s.hour.has(t.Hour()) {
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.loc)
+		if t.Hour() == 0 {
+			goto WRAP
+		}
+	}
+
+	for !s.minute.has(t.Minute()) {
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto WRAP
+		}
+	}
+
+	return t.In(origLoc)
+}
+
+// NextN returns the next n activation times after t.
+func (s *Schedule) NextN(t time.Time, n int) []time.Time {
+	out := make([]time.Time, 0, n)
+	for i := 0; i < n; i++ {
+		t = s.Next(t)
+		if t.IsZero() {
+			break
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// dayMatches follows the cron convention: when both the day of month and the
+// day of week are restricted, a day matching either of them is a match.
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom.has(t.Day())
+	dowMatch := s.dow.has(int(t.Weekday()))
+	if s.dom.star() || s.dow.star() {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Expression is a cron expression that can be used in config structs, it is
+// validated when the config is decoded.
+type Expression struct {
+	*Schedule
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *Expression) UnmarshalText(text []byte) error {
+	s, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	e.Schedule = s
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (e Expression) MarshalText() ([]byte, error) {
+	if e.Schedule == nil {
+		return nil, nil
+	}
+	return []byte(e.expr), nil
+}
+
+// Validate returns an error when expr is not a valid cron expression.
+func Validate(expr string) error {
+	_, err := Parse(expr)
+	return err
+}
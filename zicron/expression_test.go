@@ -0,0 +1,144 @@
+package zicron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * * 7-1",
+		"* * * * 8",
+		"@fortnightly",
+		"@every 10ms",
+		"CRON_TZ=Mars/Olympus 0 0 * * *",
+	}
+	for _, expr := range tests {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) expected an error", expr)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"* * * * *", "every minute"},
+		{"*/5 * * * *", "every 5 minutes"},
+		{"0 * * * *", "at minute 0 past every hour"},
+		{"@hourly", "at minute 0 past every hour"},
+		{"0,30 * * * *", "at minutes 0 and 30 past every hour"},
+		{"30 9 * * *", "at 09:30"},
+		{"0 9,17 * * *", "at 09:00 and 17:00"},
+		{"0 9 * * 1-5", "at 09:00 on Monday through Friday"},
+		{"0 0 1 * *", "at 00:00 on day 1 of the month"},
+		{"0 0 1 1 *", "at 00:00 on day 1 of the month in January"},
+		{"0 */2 * * *", "at minute 0 past every 2 hours"},
+		{"*/15 9-17 * * MON-FRI", "every 15 minutes of hours 9 through 17 on Monday through Friday"},
+		{"0 12 * JAN,JUL SUN", "at 12:00 on Sunday in January and July"},
+		{"0 12 * * 7", "at 12:00 on Sunday"},
+		{"0 12 * * 5-7", "at 12:00 on Friday through Saturday and Sunday"},
+		{"@every 90s", "every 1m30s"},
+		{"CRON_TZ=Asia/Jakarta 0 8 * * *", "at 08:00 (Asia/Jakarta)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			s, err := ParseInLocation(tt.expr, time.Local)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.expr, err)
+			}
+			if got := s.Describe(); got != tt.want {
+				t.Errorf("Describe() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	from := time.Date(2024, time.January, 31, 10, 7, 30, 0, time.UTC) // Wednesday
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/5 * * * *", time.Date(2024, time.January, 31, 10, 10, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2024, time.February, 1, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * MON-FRI", time.Date(2024, time.February, 1, 9, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		// Day of month and day of week are OR-ed when both are restricted
+		{"0 0 15 * SAT", time.Date(2024, time.February, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 5-7", time.Date(2024, time.February, 2, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 1-7", time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 1h", time.Date(2024, time.January, 31, 11, 0, 0, 0, time.UTC)},
+		{"@every 7m", time.Date(2024, time.January, 31, 10, 14, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			s, err := ParseInLocation(tt.expr, time.UTC)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.expr, err)
+			}
+			if got := s.Next(from); !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDayOfWeekSeven(t *testing.T) {
+	from := time.Date(2024, time.February, 2, 12, 0, 0, 0, time.UTC) // Friday
+	for _, expr := range []string{"0 0 * * 5-7", "0 0 * * 7", "0 0 * * 7/2", "0 0 * * 0-7"} {
+		next := MustParse(expr).NextN(from, 7)
+		sunday := false
+		for _, t := range next {
+			sunday = sunday || t.Weekday() == time.Sunday
+		}
+		if !sunday {
+			t.Errorf("%s activations %v skip Sunday", expr, next)
+		}
+	}
+}
+
+func TestNextInLocation(t *testing.T) {
+	s := MustParse("CRON_TZ=Asia/Jakarta 0 8 * * *")
+	from := time.Date(2024, time.January, 31, 0, 0, 0, 0, time.UTC) // 07:00 in Jakarta
+
+	got := s.Next(from)
+	want := time.Date(2024, time.January, 31, 1, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("Next() = %v, want %v", got, want)
+	}
+	if got.Location() != time.UTC {
+		t.Errorf("Next() location = %v, want UTC", got.Location())
+	}
+
+	next := s.NextN(from, 3)
+	if len(next) != 3 || !next[2].Equal(want.Add(48*time.Hour)) {
+		t.Errorf("NextN() = %v", next)
+	}
+}
+
+func TestExpressionUnmarshalText(t *testing.T) {
+	var e Expression
+	if err := e.UnmarshalText([]byte("*/10 * * * *")); err != nil {
+		t.Fatalf("UnmarshalText() error = %v", err)
+	}
+	if e.Describe() != "every 10 minutes" {
+		t.Errorf("Describe() = %q", e.Describe())
+	}
+	if err := e.UnmarshalText([]byte("nope")); err == nil {
+		t.Error("expected an error for an invalid expression")
+	}
+}
@@ -0,0 +1,51 @@
+package zicron
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/divikraf/lumos/zin"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminPath is the path of the jobs admin endpoint.
+const AdminPath = "/cron/jobs"
+
+// JobInfo describes a scheduled job, as listed by the admin endpoint.
+type JobInfo struct {
+	Name        string      `json:"name"`
+	Expression  string      `json:"expression"`
+	Description string      `json:"description"`
+	Timezone    string      `json:"timezone"`
+	NextRuns    []time.Time `json:"next_runs"`
+}
+
+// RegisterRoutes mounts the admin endpoint on router, best on an internal
+// server: GET AdminPath?next=N lists the jobs with their next N runs
+// (default: 5, at most 100).
+func (s *Scheduler) RegisterRoutes(router gin.IRouter) {
+	router.GET(AdminPath, func(ctx *gin.Context) {
+		n := 5
+		if next := ctx.Query("next"); next != "" {
+			v, err := strconv.Atoi(next)
+			if err != nil || v < 0 || v > 100 {
+				zin.AbortWithError(ctx, zin.ErrBadRequest)
+				return
+			}
+			n = v
+		}
+		now := time.Now()
+		jobs := s.Jobs()
+		infos := make([]JobInfo, len(jobs))
+		for i, j := range jobs {
+			infos[i] = JobInfo{
+				Name:        j.Name,
+				Expression:  j.Schedule.String(),
+				Description: j.Schedule.Describe(),
+				Timezone:    j.Schedule.Location().String(),
+				NextRuns:    j.Schedule.NextN(now, n),
+			}
+		}
+		zin.OK(ctx, gin.H{"jobs": infos})
+	})
+}
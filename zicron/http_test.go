@@ -0,0 +1,56 @@
+package zicron
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zin"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+func TestSchedulerHTTP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := zerolog.Nop()
+	s := New(&logger)
+	run := func(context.Context) error { return nil }
+	s.Add(Job{Name: "report", Schedule: MustParse("CRON_TZ=UTC 0 9 * * 1-5"), Run: run})
+	s.Add(Job{Name: "sync", Schedule: MustParse("@every 10m"), Run: run})
+
+	router := gin.New()
+	router.Use(zin.ErrorMiddleware())
+	s.RegisterRoutes(router)
+	do := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := do(AdminPath + "?next=3")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s = %d %s", AdminPath, w.Code, w.Body)
+	}
+	var body struct {
+		Data struct {
+			Jobs []JobInfo `json:"jobs"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	jobs := body.Data.Jobs
+	if len(jobs) != 2 || jobs[0].Name != "report" || jobs[0].Description != "at 09:00 on Monday through Friday (UTC)" || jobs[0].Timezone != "UTC" {
+		t.Fatalf("jobs = %+v", jobs)
+	}
+	if len(jobs[1].NextRuns) != 3 || jobs[1].NextRuns[1].Sub(jobs[1].NextRuns[0]) != 10*time.Minute {
+		t.Errorf("next runs = %v, want 3 runs 10m apart", jobs[1].NextRuns)
+	}
+
+	if w := do(AdminPath + "?next=1000"); w.Code != http.StatusBadRequest {
+		t.Errorf("GET %s?next=1000 = %d, want 400", AdminPath, w.Code)
+	}
+}
@@ -0,0 +1,28 @@
+package zicron
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisLocker is a Locker acquiring the locks in Redis.
+type redisLocker struct {
+	client redis.Cmdable
+	prefix string
+	owner  string
+}
+
+// NewRedisLocker returns a Locker storing the locks in Redis, at prefix +
+// key, shared by every instance. The value of a lock is the hostname of the
+// instance holding it.
+func NewRedisLocker(client redis.Cmdable, prefix string) Locker {
+	owner, _ := os.Hostname()
+	return &redisLocker{client: client, prefix: prefix, owner: owner}
+}
+
+func (l *redisLocker) Lock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return l.client.SetNX(ctx, l.prefix+key, l.owner, ttl).Result()
+}
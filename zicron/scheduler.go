@@ -0,0 +1,273 @@
+package zicron
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/divikraf/lumos/zicron"
+
+// Reasons of the skipped activations, the reason attribute of the
+// cron_job_skipped_total metric.
+const (
+	SkipOverlap    = "overlap"
+	SkipLocked     = "locked"
+	SkipLockFailed = "lock_failed"
+)
+
+// Job is a function run on a schedule.
+type Job struct {
+	Name     string
+	Schedule *Schedule
+	Run      func(ctx context.Context) error
+	// Timeout bounds every run, unbounded when zero.
+	Timeout time.Duration
+}
+
+// Locker acquires cluster wide locks, see NewRedisLocker.
+type Locker interface {
+	// Lock acquires key for ttl, reporting false when it is held by another
+	// instance.
+	Lock(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// Option configures a Scheduler.
+type Option func(*Scheduler)
+
+// WithLocker runs every activation of a job on a single instance of the
+// cluster, the one acquiring its lock with locker.
+func WithLocker(locker Locker) Option {
+	return func(s *Scheduler) {
+		s.locker = locker
+	}
+}
+
+// Scheduler runs jobs on their schedule. A job doesn't overlap itself: an
+// activation is skipped while the previous run is still running. Every run
+// is traced in a new trace, recorded in the cron_job_runs_total and
+// cron_job_duration_ms metrics, and its panics are recovered as errors.
+type Scheduler struct {
+	logger *zerolog.Logger
+	locker Locker
+
+	mu      sync.Mutex
+	jobs    []*job
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	started bool
+
+	runs     revelio.ResultCounter
+	duration revelio.DurationRecorder
+	skipped  metric.Int64Counter
+}
+
+type job struct {
+	Job
+	running atomic.Bool
+}
+
+// New returns a Scheduler logging to logger.
+func New(logger *zerolog.Logger, opts ...Option) *Scheduler {
+	s := &Scheduler{
+		logger:   logger,
+		runs:     revelio.MustResultCounter("cron_job_runs_total", "Number of cron job runs, by job and status"),
+		duration: revelio.MustDuration("cron_job_duration_ms", "Duration of the cron job runs, by job and status"),
+		skipped: revelio.MustInt64Counter(
+			"cron_job_skipped_total",
+			"Number of skipped cron job activations, by job and reason",
+		),
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+// Add adds job to the scheduler, before it starts.
+func (s *Scheduler) Add(j Job) error {
+	if j.Name == "" {
+		return errorf("job has no name")
+	}
+	if j.Schedule == nil || j.Run == nil {
+		return errorf("job %q has no schedule or no run function", j.Name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return errorf("job %q added to a started scheduler", j.Name)
+	}
+	for _, existing := range s.jobs {
+		if existing.Name == j.Name {
+			return errorf("duplicate job %q", j.Name)
+		}
+	}
+	s.jobs = append(s.jobs, &job{Job: j})
+	return nil
+}
+
+// Jobs returns the jobs of the scheduler, in the order they were added.
+func (s *Scheduler) Jobs() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]Job, len(s.jobs))
+	for i, j := range s.jobs {
+		jobs[i] = j.Job
+	}
+	return jobs
+}
+
+// Start schedules the jobs until Stop.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.schedule(ctx, j)
+		}()
+		s.logger.Info().Str("job", j.Name).Str("schedule", j.Schedule.String()).Msg("cron job scheduled")
+	}
+}
+
+// Stop stops scheduling the jobs and cancels the context of the running
+// ones, then waits for them until ctx is done.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// schedule activates j on its schedule until ctx is done.
+func (s *Scheduler) schedule(ctx context.Context, j *job) {
+	for {
+		next := j.Schedule.Next(time.Now())
+		if next.IsZero() {
+			s.logger.Warn().Str("job", j.Name).Msg("cron job never activates")
+			return
+		}
+		t := time.NewTimer(time.Until(next))
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return
+		}
+		s.activate(ctx, j, next)
+	}
+}
+
+// activate runs j for its activation at, unless it is still running.
+func (s *Scheduler) activate(ctx context.Context, j *job, at time.Time) {
+	if !j.running.CompareAndSwap(false, true) {
+		s.skip(ctx, j, SkipOverlap)
+		s.logger.Warn().Str("job", j.Name).Time("activation", at).Msg("cron job still running, activation skipped")
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer j.running.Store(false)
+		s.run(ctx, j, at)
+	}()
+}
+
+// run runs j for its activation at, once in the cluster with a locker.
+func (s *Scheduler) run(ctx context.Context, j *job, at time.Time) {
+	logger := s.logger.With().Str("job", j.Name).Time("activation", at).Logger()
+	if s.locker != nil {
+		// The lock of an activation is held until the next one, so that an
+		// instance whose clock lags doesn't run it again
+		ttl := max(time.Until(j.Schedule.Next(at)), time.Minute)
+		locked, err := s.locker.Lock(ctx, j.Name+":"+strconv.FormatInt(at.Unix(), 10), ttl)
+		if err != nil {
+			s.skip(ctx, j, SkipLockFailed)
+			logger.Error().Err(err).Msg("cron job lock failed, activation skipped")
+			return
+		}
+		if !locked {
+			s.skip(ctx, j, SkipLocked)
+			logger.Debug().Msg("cron job run by another instance")
+			return
+		}
+	}
+
+	attrs := []attribute.KeyValue{attribute.String("job", j.Name)}
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "cron "+j.Name,
+		trace.WithNewRoot(),
+		trace.WithAttributes(attrs...),
+	)
+	defer span.End()
+	ctx = logger.WithContext(ctx)
+	if j.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := safeRun(ctx, j.Run)
+	elapsed := time.Since(start)
+	s.duration.Record(ctx, elapsed, append(attrs, revelio.ResultAttributes(err)...)...)
+	s.runs.Record(ctx, err, attrs...)
+	if err != nil {
+		observe.RecordError(span, err)
+		logger.Error().Err(err).Dur("duration", elapsed).Msg("cron job failed")
+		return
+	}
+	logger.Debug().Dur("duration", elapsed).Msg("cron job done")
+}
+
+func (s *Scheduler) skip(ctx context.Context, j *job, reason string) {
+	s.skipped.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("job", j.Name),
+		attribute.String("reason", reason),
+	))
+}
+
+// safeRun calls run, turning its panics into errors.
+func safeRun(ctx context.Context, run func(context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			observe.RecordPanic(trace.SpanFromContext(ctx), r)
+			err = fmt.Errorf("zicron: job panicked: %v", r)
+		}
+	}()
+	return run(ctx)
+}
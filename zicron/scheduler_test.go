@@ -0,0 +1,183 @@
+package zicron
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+type fakeLocker struct {
+	mu   sync.Mutex
+	held map[string]bool
+	err  error
+}
+
+func (l *fakeLocker) Lock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return false, l.err
+	}
+	if l.held[key] {
+		return false, nil
+	}
+	l.held[key] = true
+	return true, nil
+}
+
+func TestSchedulerAdd(t *testing.T) {
+	logger := zerolog.Nop()
+	s := New(&logger)
+	run := func(context.Context) error { return nil }
+
+	if err := s.Add(Job{Name: "a", Schedule: MustParse("@hourly"), Run: run}); err != nil {
+		t.Fatalf("Add() = %v", err)
+	}
+	for name, j := range map[string]Job{
+		"no name":     {Schedule: MustParse("@hourly"), Run: run},
+		"no schedule": {Name: "b", Run: run},
+		"duplicate":   {Name: "a", Schedule: MustParse("@daily"), Run: run},
+	} {
+		if err := s.Add(j); err == nil {
+			t.Errorf("Add() of a job with %s succeeded", name)
+		}
+	}
+	s.Start()
+	defer s.Stop(context.Background())
+	if err := s.Add(Job{Name: "c", Schedule: MustParse("@hourly"), Run: run}); err == nil {
+		t.Error("Add() to a started scheduler succeeded")
+	}
+}
+
+func TestSchedulerActivate(t *testing.T) {
+	logger := zerolog.Nop()
+	locker := &fakeLocker{held: map[string]bool{}}
+	// Two instances sharing the locker
+	s1 := New(&logger, WithLocker(locker))
+	s2 := New(&logger, WithLocker(locker))
+
+	var runs atomic.Int32
+	release := make(chan struct{})
+	j1 := &job{Job: Job{Name: "report", Schedule: MustParse("* * * * *"), Run: func(context.Context) error {
+		runs.Add(1)
+		<-release
+		return nil
+	}}}
+	j2 := &job{Job: j1.Job}
+
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+	s1.activate(ctx, j1, at)
+	s2.activate(ctx, j2, at)
+	// The next activation overlaps the running one on the first instance
+	s1.activate(ctx, j1, at.Add(time.Minute))
+	close(release)
+	s1.wg.Wait()
+	s2.wg.Wait()
+
+	if n := runs.Load(); n != 1 {
+		t.Errorf("%d runs, want 1", n)
+	}
+	if j1.running.Load() || j2.running.Load() {
+		t.Error("job still marked running")
+	}
+}
+
+func TestSchedulerEveryAcrossInstances(t *testing.T) {
+	logger := zerolog.Nop()
+	locker := &fakeLocker{held: map[string]bool{}}
+	s1 := New(&logger, WithLocker(locker))
+	s2 := New(&logger, WithLocker(locker))
+
+	var runs atomic.Int32
+	schedule := MustParse("@every 1m")
+	j := Job{Name: "sync", Schedule: schedule, Run: func(context.Context) error {
+		runs.Add(1)
+		return nil
+	}}
+	// The instances started 25.5s apart
+	started := time.Date(2026, 1, 1, 10, 0, 12, 0, time.UTC)
+	at1 := schedule.Next(started)
+	at2 := schedule.Next(started.Add(25500 * time.Millisecond))
+	if !at1.Equal(at2) {
+		t.Fatalf("activations %v and %v differ", at1, at2)
+	}
+
+	ctx := context.Background()
+	s1.run(ctx, &job{Job: j}, at1)
+	s2.run(ctx, &job{Job: j}, at2)
+	if n := runs.Load(); n != 1 {
+		t.Errorf("%d runs, want 1", n)
+	}
+}
+
+func TestSchedulerRun(t *testing.T) {
+	logger := zerolog.Nop()
+	s := New(&logger)
+	at := time.Now()
+
+	tests := []struct {
+		name string
+		run  func(context.Context) error
+		err  bool
+	}{
+		{name: "success", run: func(context.Context) error { return nil }},
+		{name: "failure", run: func(context.Context) error { return errors.New("boom") }, err: true},
+		{name: "panic", run: func(context.Context) error { panic("boom") }, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := safeRun(context.Background(), tt.run)
+			if (err != nil) != tt.err {
+				t.Errorf("safeRun() = %v, want error: %v", err, tt.err)
+			}
+			// run must not propagate panics
+			s.run(context.Background(), &job{Job: Job{Name: tt.name, Schedule: MustParse("@hourly"), Run: tt.run}}, at)
+		})
+	}
+
+	locker := &fakeLocker{err: errors.New("redis down")}
+	s = New(&logger, WithLocker(locker))
+	called := false
+	s.run(context.Background(), &job{Job: Job{Name: "locked", Schedule: MustParse("@hourly"), Run: func(context.Context) error {
+		called = true
+		return nil
+	}}}, at)
+	if called {
+		t.Error("job run although the lock failed")
+	}
+}
+
+func TestSchedulerStartStop(t *testing.T) {
+	logger := zerolog.Nop()
+	s := New(&logger)
+	ran := make(chan struct{}, 1)
+	err := s.Add(Job{Name: "tick", Schedule: MustParse("@every 1s"), Run: func(ctx context.Context) error {
+		select {
+		case ran <- struct{}{}:
+		default:
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	if err != nil {
+		t.Fatalf("Add() = %v", err)
+	}
+	s.Start()
+
+	select {
+	case <-ran:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job not run")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil {
+		t.Fatalf("Stop() = %v, the running job should be canceled", err)
+	}
+}
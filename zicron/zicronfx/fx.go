@@ -0,0 +1,61 @@
+package zicronfx
+
+import (
+	"github.com/divikraf/lumos/zicron"
+	"github.com/divikraf/lumos/zin"
+	"github.com/divikraf/lumos/zin/zinfx"
+	"github.com/rs/zerolog"
+	"go.uber.org/fx"
+)
+
+type schedulerParams struct {
+	fx.In
+
+	LC     fx.Lifecycle
+	Logger *zerolog.Logger
+	// Locker runs every activation on a single instance when provided.
+	Locker zicron.Locker `optional:"true"`
+	Jobs   []zicron.Job  `group:"zicron-jobs"`
+}
+
+// Provider provides a *zicron.Scheduler of the jobs of the "zicron-jobs"
+// group, started and stopped with the application.
+var Provider = fx.Provide(
+	func(params schedulerParams) (*zicron.Scheduler, error) {
+		var opts []zicron.Option
+		if params.Locker != nil {
+			opts = append(opts, zicron.WithLocker(params.Locker))
+		}
+		s := zicron.New(params.Logger, opts...)
+		for _, j := range params.Jobs {
+			if err := s.Add(j); err != nil {
+				return nil, err
+			}
+		}
+		params.LC.Append(fx.StartStopHook(s.Start, s.Stop))
+		return s, nil
+	},
+)
+
+// Invoker starts the scheduler even when nothing depends on it.
+var Invoker = fx.Invoke(func(*zicron.Scheduler) {})
+
+// WithJob schedules job.
+func WithJob(job zicron.Job) fx.Option {
+	return fx.Supply(fx.Annotate(job, fx.ResultTags(`group:"zicron-jobs"`)))
+}
+
+// AsJob annotates a constructor of a zicron.Job, e.g. a job with
+// dependencies, to provide it in the jobs group.
+func AsJob(constructor any) any {
+	return fx.Annotate(constructor, fx.ResultTags(`group:"zicron-jobs"`))
+}
+
+// AdminRoutes mounts the jobs admin endpoint on the additional server name,
+// see zinfx.Server, or on the main router when empty.
+func AdminRoutes(server string) fx.Option {
+	return fx.Provide(fx.Annotate(
+		func(s *zicron.Scheduler) zin.RouteRegistrar { return s },
+		fx.ResultTags(zinfx.RoutesGroup(server)),
+	))
+}
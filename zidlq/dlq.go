@@ -0,0 +1,334 @@
+// Package zidlq standardizes dead-letter storage and management.
+//
+// Consumers hand messages they gave up on to a [Manager], which persists them
+// in a [Store] (Postgres table, Redis stream, or memory). Dead messages can
+// then be listed, inspected, redriven to their source, or purged through the
+// Manager or its admin API, and the depth and age of every dead-letter queue
+// are exposed as metrics.
+package zidlq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const packageName = "zidlq"
+
+var (
+	// ErrNotFound is returned when a dead message does not exist.
+	ErrNotFound = errors.New(packageName + ": message not found")
+	// ErrNoRedriver is returned when redriving a queue without a registered
+	// Redriver.
+	ErrNoRedriver = errors.New(packageName + ": no redriver registered for queue")
+)
+
+// Message is a dead message.
+type Message struct {
+	// ID is assigned by the Store.
+	ID string `json:"id"`
+	// Queue is the name of the source queue, topic, or job the message
+	// failed in.
+	Queue    string            `json:"queue"`
+	Payload  []byte            `json:"payload"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Error    string            `json:"error"`
+	Attempts int               `json:"attempts"`
+	FailedAt time.Time         `json:"failed_at"`
+}
+
+// ListOptions paginates dead messages, ordered from the oldest.
+type ListOptions struct {
+	// After lists messages after the message with this ID.
+	After string
+	// Limit is the maximum number of messages returned, DefaultListLimit when
+	// zero.
+	Limit int
+}
+
+// DefaultListLimit is the default number of messages listed.
+const DefaultListLimit = 100
+
+func (o ListOptions) limit() int {
+	if o.Limit <= 0 {
+		return DefaultListLimit
+	}
+	return o.Limit
+}
+
+// QueueStats describes a dead-letter queue.
+type QueueStats struct {
+	Queue          string    `json:"queue"`
+	Depth          int64     `json:"depth"`
+	OldestFailedAt time.Time `json:"oldest_failed_at,omitempty"`
+}
+
+// Store persists dead messages.
+type Store interface {
+	// Put stores msg and sets its ID.
+	Put(ctx context.Context, msg *Message) error
+	// List returns the dead messages of queue.
+	List(ctx context.Context, queue string, opts ListOptions) ([]Message, error)
+	// Get returns a dead message, ErrNotFound is returned when it does not
+	// exist.
+	Get(ctx context.Context, queue string, id string) (Message, error)
+	// Delete removes dead messages.
+	Delete(ctx context.Context, queue string, ids ...string) error
+	// Purge removes every dead message of queue and returns their number.
+	Purge(ctx context.Context, queue string) (int64, error)
+	// Stats returns the stats of every non-empty queue.
+	Stats(ctx context.Context) ([]QueueStats, error)
+}
+
+// Redriver sends a dead message back to its source. The message is removed
+// from the dead-letter queue when it returns nil.
+type Redriver func(ctx context.Context, msg Message) error
+
+// Option configures a Manager.
+type Option func(m *Manager)
+
+// WithRedriver registers the Redriver of queue.
+func WithRedriver(queue string, redriver Redriver) Option {
+	return func(m *Manager) {
+		m.redrivers[queue] = redriver
+	}
+}
+
+// WithStatsTimeout sets the timeout of the store query made on every metric
+// collection, 5s by default.
+func WithStatsTimeout(d time.Duration) Option {
+	return func(m *Manager) {
+		m.statsTimeout = d
+	}
+}
+
+const (
+	eventDead     = "dead"
+	eventRedriven = "redriven"
+	eventPurged   = "purged"
+)
+
+// Manager stores, redrives, and purges dead messages.
+type Manager struct {
+	store        Store
+	logger       *zerolog.Logger
+	statsTimeout time.Duration
+
+	mu        sync.RWMutex
+	redrivers map[string]Redriver
+
+	messages     metric.Int64Counter
+	registration metric.Registration
+}
+
+// New returns a Manager persisting dead messages in store.
+func New(store Store, logger *zerolog.Logger, opts ...Option) (*Manager, error) {
+	m := &Manager{
+		store:        store,
+		logger:       logger,
+		statsTimeout: 5 * time.Second,
+		redrivers:    map[string]Redriver{},
+	}
+	for _, o := range opts {
+		o(m)
+	}
+
+	var err error
+	m.messages, err = revelio.Int64Counter("dlq_messages_total",
+		"Number of dead messages by event (dead, redriven, purged)")
+	if err != nil {
+		return nil, err
+	}
+
+	depth, err := revelio.Int64ObservableGauge("dlq_depth", "Number of messages in the dead-letter queue")
+	if err != nil {
+		return nil, err
+	}
+	age, err := revelio.Float64ObservableGauge("dlq_oldest_age_seconds",
+		"Age of the oldest message in the dead-letter queue", metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	m.registration, err = revelio.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		ctx, cancel := context.WithTimeout(ctx, m.statsTimeout)
+		defer cancel()
+
+		stats, err := m.store.Stats(ctx)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, s := range stats {
+			attrs := metric.WithAttributes(attribute.String("queue", s.Queue))
+			o.ObserveInt64(depth, s.Depth, attrs)
+			if !s.OldestFailedAt.IsZero() {
+				o.ObserveFloat64(age, now.Sub(s.OldestFailedAt).Seconds(), attrs)
+			}
+		}
+		return nil
+	}, depth, age)
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Close stops reporting metrics.
+func (m *Manager) Close() error {
+	return m.registration.Unregister()
+}
+
+// RegisterRedriver registers the Redriver of queue, replacing the existing
+// one.
+func (m *Manager) RegisterRedriver(queue string, redriver Redriver) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.redrivers[queue] = redriver
+}
+
+// Dead stores a message that failed in queue with cause.
+func (m *Manager) Dead(ctx context.Context, msg Message, cause error) error {
+	if msg.Queue == "" {
+		return errors.New(packageName + ": message queue must not be empty")
+	}
+	if cause != nil {
+		msg.Error = cause.Error()
+	}
+	if msg.FailedAt.IsZero() {
+		msg.FailedAt = time.Now()
+	}
+
+	if err := m.store.Put(ctx, &msg); err != nil {
+		m.logger.Error().Err(err).Msgf("failed to store dead message of queue %s", msg.Queue)
+		return err
+	}
+	m.count(ctx, msg.Queue, eventDead, 1)
+	return nil
+}
+
+// List returns the dead messages of queue.
+func (m *Manager) List(ctx context.Context, queue string, opts ListOptions) ([]Message, error) {
+	return m.store.List(ctx, queue, opts)
+}
+
+// Get returns a dead message.
+func (m *Manager) Get(ctx context.Context, queue string, id string) (Message, error) {
+	return m.store.Get(ctx, queue, id)
+}
+
+// Stats returns the stats of every non-empty queue.
+func (m *Manager) Stats(ctx context.Context) ([]QueueStats, error) {
+	return m.store.Stats(ctx)
+}
+
+// Redrive sends the dead messages back to their source and removes them from
+// the dead-letter queue. It stops at the first failure.
+func (m *Manager) Redrive(ctx context.Context, queue string, ids ...string) error {
+	redriver, err := m.redriver(queue)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		msg, err := m.store.Get(ctx, queue, id)
+		if err != nil {
+			return err
+		}
+		if err := m.redrive(ctx, redriver, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RedriveAll sends up to limit dead messages of queue back to their source,
+// from the oldest, and returns the number of redriven messages. Every message
+// is redriven when limit is zero. It stops at the first failure.
+func (m *Manager) RedriveAll(ctx context.Context, queue string, limit int) (int, error) {
+	redriver, err := m.redriver(queue)
+	if err != nil {
+		return 0, err
+	}
+
+	var redriven int
+	for limit <= 0 || redriven < limit {
+		pageSize := DefaultListLimit
+		if limit > 0 && limit-redriven < pageSize {
+			pageSize = limit - redriven
+		}
+
+		// Redriven messages are deleted, so the next page starts from the
+		// oldest remaining message
+		msgs, err := m.store.List(ctx, queue, ListOptions{Limit: pageSize})
+		if err != nil {
+			return redriven, err
+		}
+		if len(msgs) == 0 {
+			break
+		}
+		for _, msg := range msgs {
+			if err := m.redrive(ctx, redriver, msg); err != nil {
+				return redriven, err
+			}
+			redriven++
+		}
+	}
+	return redriven, nil
+}
+
+func (m *Manager) redriver(queue string) (Redriver, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	redriver, ok := m.redrivers[queue]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoRedriver, queue)
+	}
+	return redriver, nil
+}
+
+func (m *Manager) redrive(ctx context.Context, redriver Redriver, msg Message) error {
+	if err := redriver(ctx, msg); err != nil {
+		m.logger.Error().Err(err).Msgf("failed to redrive dead message %s of queue %s", msg.ID, msg.Queue)
+		return err
+	}
+	if err := m.store.Delete(ctx, msg.Queue, msg.ID); err != nil {
+		return err
+	}
+	m.count(ctx, msg.Queue, eventRedriven, 1)
+	return nil
+}
+
+// Delete removes dead messages without redriving them.
+func (m *Manager) Delete(ctx context.Context, queue string, ids ...string) error {
+	if err := m.store.Delete(ctx, queue, ids...); err != nil {
+		return err
+	}
+	m.count(ctx, queue, eventPurged, int64(len(ids)))
+	return nil
+}
+
+// Purge removes every dead message of queue and returns their number.
+func (m *Manager) Purge(ctx context.Context, queue string) (int64, error) {
+	n, err := m.store.Purge(ctx, queue)
+	if err != nil {
+		return 0, err
+	}
+	m.count(ctx, queue, eventPurged, n)
+	m.logger.Info().Msgf("purged %d dead messages of queue %s", n, queue)
+	return n, nil
+}
+
+func (m *Manager) count(ctx context.Context, queue string, event string, n int64) {
+	m.messages.Add(ctx, n, metric.WithAttributes(
+		attribute.String("queue", queue),
+		attribute.String("event", event),
+	))
+}
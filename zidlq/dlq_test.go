@@ -0,0 +1,142 @@
+package zidlq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func newTestManager(t *testing.T, opts ...Option) (*Manager, *reveliotest.Scope) {
+	t.Helper()
+
+	s := reveliotest.NewDefaultTestScope(t)
+	logger := zerolog.Nop()
+	m, err := New(NewMemoryStore(), &logger, opts...)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { _ = m.Close() })
+	return m, s
+}
+
+func TestManagerRedrive(t *testing.T) {
+	var redriven []string
+	m, s := newTestManager(t, WithRedriver("orders", func(_ context.Context, msg Message) error {
+		if string(msg.Payload) == "poison" {
+			return errors.New("still failing")
+		}
+		redriven = append(redriven, string(msg.Payload))
+		return nil
+	}))
+
+	ctx := context.Background()
+	for _, payload := range []string{"a", "b", "poison", "c"} {
+		if err := m.Dead(ctx, Message{Queue: "orders", Payload: []byte(payload)}, errors.New("boom")); err != nil {
+			t.Fatalf("Dead() error = %v", err)
+		}
+	}
+
+	msgs, err := m.List(ctx, "orders", ListOptions{Limit: 2})
+	if err != nil || len(msgs) != 2 {
+		t.Fatalf("List() = %v, %v", msgs, err)
+	}
+	if msgs[0].Error != "boom" || msgs[0].FailedAt.IsZero() {
+		t.Errorf("unexpected message %+v", msgs[0])
+	}
+	next, _ := m.List(ctx, "orders", ListOptions{After: msgs[1].ID})
+	if len(next) != 2 || string(next[0].Payload) != "poison" {
+		t.Fatalf("List(after) = %v", next)
+	}
+
+	if err := m.Redrive(ctx, "orders", msgs[0].ID); err != nil {
+		t.Fatalf("Redrive() error = %v", err)
+	}
+
+	n, err := m.RedriveAll(ctx, "orders", 0)
+	if err == nil || n != 1 {
+		t.Fatalf("RedriveAll() = %d, %v, want to stop at the poison message", n, err)
+	}
+	if len(redriven) != 2 || redriven[0] != "a" || redriven[1] != "b" {
+		t.Errorf("redriven = %v", redriven)
+	}
+
+	if err := m.Redrive(ctx, "payments", "1"); !errors.Is(err, ErrNoRedriver) {
+		t.Errorf("Redrive() without redriver error = %v", err)
+	}
+
+	stats, _ := m.Stats(ctx)
+	if len(stats) != 1 || stats[0].Depth != 2 {
+		t.Fatalf("Stats() = %+v", stats)
+	}
+
+	reveliotest.AssertCounterValue(t, s, "dlq_messages_total", 4, attribute.String("event", "dead"))
+	reveliotest.AssertCounterValue(t, s, "dlq_messages_total", 2, attribute.String("event", "redriven"))
+
+	depth, ok := s.Metric(t, "dlq_depth")
+	if !ok {
+		t.Fatal("dlq_depth was not reported")
+	}
+	if dp := depth.Data.(metricdata.Gauge[int64]).DataPoints; len(dp) != 1 || dp[0].Value != 2 {
+		t.Errorf("dlq_depth = %+v", dp)
+	}
+
+	purged, err := m.Purge(ctx, "orders")
+	if err != nil || purged != 2 {
+		t.Errorf("Purge() = %d, %v", purged, err)
+	}
+}
+
+func TestRegisterRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m, _ := newTestManager(t, WithRedriver("orders", func(context.Context, Message) error { return nil }))
+
+	ctx := context.Background()
+	_ = m.Dead(ctx, Message{Queue: "orders", Payload: []byte("a"), FailedAt: time.Now().Add(-time.Minute)}, nil)
+	_ = m.Dead(ctx, Message{Queue: "orders", Payload: []byte("b")}, nil)
+
+	r := gin.New()
+	RegisterRoutes(r.Group("/admin/dlq"), m)
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	w := do(http.MethodGet, "/admin/dlq/queues")
+	var stats struct {
+		Queues []QueueStats `json:"queues"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil || len(stats.Queues) != 1 || stats.Queues[0].Depth != 2 {
+		t.Fatalf("GET /queues = %d %s", w.Code, w.Body)
+	}
+
+	if w := do(http.MethodGet, "/admin/dlq/queues/orders/messages/1"); w.Code != http.StatusOK {
+		t.Errorf("GET message = %d %s", w.Code, w.Body)
+	}
+	if w := do(http.MethodGet, "/admin/dlq/queues/orders/messages/42"); w.Code != http.StatusNotFound {
+		t.Errorf("GET missing message = %d, want 404", w.Code)
+	}
+	if w := do(http.MethodGet, "/admin/dlq/queues/orders/messages?limit=x"); w.Code != http.StatusBadRequest {
+		t.Errorf("GET with invalid limit = %d, want 400", w.Code)
+	}
+	if w := do(http.MethodPost, "/admin/dlq/queues/orders/messages/1/redrive"); w.Code != http.StatusOK {
+		t.Errorf("POST redrive = %d %s", w.Code, w.Body)
+	}
+	if w := do(http.MethodPost, "/admin/dlq/queues/payments/redrive"); w.Code != http.StatusBadRequest {
+		t.Errorf("POST redrive without redriver = %d, want 400", w.Code)
+	}
+	if w := do(http.MethodDelete, "/admin/dlq/queues/orders"); w.Code != http.StatusOK || w.Body.String() != `{"purged":1}` {
+		t.Errorf("DELETE queue = %d %s", w.Code, w.Body)
+	}
+}
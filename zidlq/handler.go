@@ -0,0 +1,140 @@
+package zidlq
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes registers the dead-letter admin API on r:
+//
+//	GET    /queues                               stats of every queue
+//	GET    /queues/:queue/messages?after=&limit= list dead messages
+//	GET    /queues/:queue/messages/:id           inspect a dead message
+//	POST   /queues/:queue/messages/:id/redrive   redrive a dead message
+//	POST   /queues/:queue/redrive?limit=         redrive the oldest dead messages
+//	DELETE /queues/:queue/messages/:id           delete a dead message
+//	DELETE /queues/:queue                        purge a queue
+//
+// The routes should be mounted on a protected group, e.g. /admin/dlq.
+func RegisterRoutes(r gin.IRouter, m *Manager) {
+	h := handler{manager: m}
+	r.GET("/queues", h.stats)
+	r.GET("/queues/:queue/messages", h.list)
+	r.GET("/queues/:queue/messages/:id", h.get)
+	r.POST("/queues/:queue/messages/:id/redrive", h.redrive)
+	r.POST("/queues/:queue/redrive", h.redriveAll)
+	r.DELETE("/queues/:queue/messages/:id", h.delete)
+	r.DELETE("/queues/:queue", h.purge)
+}
+
+type handler struct {
+	manager *Manager
+}
+
+func (h handler) stats(c *gin.Context) {
+	stats, err := h.manager.Stats(c.Request.Context())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"queues": stats})
+}
+
+func (h handler) list(c *gin.Context) {
+	limit, err := queryInt(c, "limit")
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	msgs, err := h.manager.List(c.Request.Context(), c.Param("queue"), ListOptions{
+		After: c.Query("after"),
+		Limit: limit,
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"messages": msgs})
+}
+
+func (h handler) get(c *gin.Context) {
+	msg, err := h.manager.Get(c.Request.Context(), c.Param("queue"), c.Param("id"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, msg)
+}
+
+func (h handler) redrive(c *gin.Context) {
+	if err := h.manager.Redrive(c.Request.Context(), c.Param("queue"), c.Param("id")); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"redriven": 1})
+}
+
+func (h handler) redriveAll(c *gin.Context) {
+	limit, err := queryInt(c, "limit")
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	n, err := h.manager.RedriveAll(c.Request.Context(), c.Param("queue"), limit)
+	if err != nil {
+		c.JSON(statusOf(err), gin.H{"redriven": n, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"redriven": n})
+}
+
+func (h handler) delete(c *gin.Context) {
+	if err := h.manager.Delete(c.Request.Context(), c.Param("queue"), c.Param("id")); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h handler) purge(c *gin.Context) {
+	n, err := h.manager.Purge(c.Request.Context(), c.Param("queue"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"purged": n})
+}
+
+var errInvalidQuery = errors.New(packageName + ": invalid query parameter")
+
+func queryInt(c *gin.Context, key string) (int, error) {
+	v := c.Query(key)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, errInvalidQuery
+	}
+	return n, nil
+}
+
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrNoRedriver), errors.Is(err, errInvalidQuery):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func abortWithError(c *gin.Context, err error) {
+	c.AbortWithStatusJSON(statusOf(err), gin.H{"error": err.Error()})
+}
@@ -0,0 +1,124 @@
+package zidlq
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// MemoryStore is a Store keeping dead messages in memory. It is meant for
+// tests and local development, messages are lost on restart.
+type MemoryStore struct {
+	mu     sync.Mutex
+	nextID uint64
+	queues map[string][]Message
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		queues: map[string][]Message{},
+	}
+}
+
+// Put implements Store.
+func (s *MemoryStore) Put(_ context.Context, msg *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	msg.ID = strconv.FormatUint(s.nextID, 10)
+	s.queues[msg.Queue] = append(s.queues[msg.Queue], *msg)
+	return nil
+}
+
+// List implements Store.
+func (s *MemoryStore) List(_ context.Context, queue string, opts ListOptions) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msgs := s.queues[queue]
+	start := 0
+	if opts.After != "" {
+		after, err := strconv.ParseUint(opts.After, 10, 64)
+		if err != nil {
+			return nil, ErrNotFound
+		}
+		// IDs are increasing, find the first message after the cursor
+		start = sort.Search(len(msgs), func(i int) bool {
+			id, _ := strconv.ParseUint(msgs[i].ID, 10, 64)
+			return id > after
+		})
+	}
+
+	end := min(start+opts.limit(), len(msgs))
+	return append([]Message(nil), msgs[start:end]...), nil
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(_ context.Context, queue string, id string) (Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, msg := range s.queues[queue] {
+		if msg.ID == id {
+			return msg, nil
+		}
+	}
+	return Message{}, ErrNotFound
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(_ context.Context, queue string, ids ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	remove := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		remove[id] = struct{}{}
+	}
+
+	msgs := s.queues[queue][:0]
+	for _, msg := range s.queues[queue] {
+		if _, ok := remove[msg.ID]; !ok {
+			msgs = append(msgs, msg)
+		}
+	}
+	if len(msgs) == 0 {
+		delete(s.queues, queue)
+		return nil
+	}
+	s.queues[queue] = msgs
+	return nil
+}
+
+// Purge implements Store.
+func (s *MemoryStore) Purge(_ context.Context, queue string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := int64(len(s.queues[queue]))
+	delete(s.queues, queue)
+	return n, nil
+}
+
+// Stats implements Store.
+func (s *MemoryStore) Stats(_ context.Context) ([]QueueStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make([]QueueStats, 0, len(s.queues))
+	for queue, msgs := range s.queues {
+		stats = append(stats, QueueStats{
+			Queue:          queue,
+			Depth:          int64(len(msgs)),
+			OldestFailedAt: msgs[0].FailedAt,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Queue < stats[j].Queue })
+	return stats, nil
+}
+
+// Compile-time interface compliance check
+var _ Store = (*MemoryStore)(nil)
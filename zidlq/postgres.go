@@ -0,0 +1,194 @@
+package zidlq
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/divikraf/lumos/db/zisqlx"
+	"github.com/lib/pq"
+)
+
+// PostgresSchema creates the table used by PostgresStore, with the default
+// table name.
+const PostgresSchema = `
+CREATE TABLE IF NOT EXISTS dead_letters (
+	id BIGSERIAL PRIMARY KEY,
+	queue TEXT NOT NULL,
+	payload BYTEA NOT NULL,
+	headers JSONB,
+	error TEXT NOT NULL,
+	attempts INT NOT NULL DEFAULT 0,
+	failed_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS dead_letters_queue_id_idx ON dead_letters (queue, id);
+`
+
+// PostgresStore is a Store keeping dead messages in a Postgres table, see
+// PostgresSchema.
+type PostgresStore struct {
+	db    zisqlx.BasicQueryerExecuter
+	table string
+}
+
+// NewPostgresStore returns a PostgresStore using the dead_letters table.
+func NewPostgresStore(db zisqlx.BasicQueryerExecuter) *PostgresStore {
+	return NewPostgresStoreWithTable(db, "dead_letters")
+}
+
+// NewPostgresStoreWithTable returns a PostgresStore using the given table.
+func NewPostgresStoreWithTable(db zisqlx.BasicQueryerExecuter, table string) *PostgresStore {
+	return &PostgresStore{
+		db:    db,
+		table: pq.QuoteIdentifier(table),
+	}
+}
+
+type deadLetterRow struct {
+	ID       int64     `db:"id"`
+	Queue    string    `db:"queue"`
+	Payload  []byte    `db:"payload"`
+	Headers  []byte    `db:"headers"`
+	Error    string    `db:"error"`
+	Attempts int       `db:"attempts"`
+	FailedAt time.Time `db:"failed_at"`
+}
+
+func (r deadLetterRow) message() (Message, error) {
+	msg := Message{
+		ID:       strconv.FormatInt(r.ID, 10),
+		Queue:    r.Queue,
+		Payload:  r.Payload,
+		Error:    r.Error,
+		Attempts: r.Attempts,
+		FailedAt: r.FailedAt,
+	}
+	if len(r.Headers) > 0 {
+		if err := json.Unmarshal(r.Headers, &msg.Headers); err != nil {
+			return Message{}, err
+		}
+	}
+	return msg, nil
+}
+
+// Put implements Store.
+func (s *PostgresStore) Put(ctx context.Context, msg *Message) error {
+	var headers []byte
+	if len(msg.Headers) > 0 {
+		var err error
+		if headers, err = json.Marshal(msg.Headers); err != nil {
+			return err
+		}
+	}
+
+	var id int64
+	query := fmt.Sprintf(`INSERT INTO %s (queue, payload, headers, error, attempts, failed_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`, s.table)
+	err := s.db.GetContext(ctx, "zidlq.put", &id, query,
+		msg.Queue, msg.Payload, headers, msg.Error, msg.Attempts, msg.FailedAt)
+	if err != nil {
+		return err
+	}
+	msg.ID = strconv.FormatInt(id, 10)
+	return nil
+}
+
+// List implements Store.
+func (s *PostgresStore) List(ctx context.Context, queue string, opts ListOptions) ([]Message, error) {
+	var after int64
+	if opts.After != "" {
+		var err error
+		if after, err = strconv.ParseInt(opts.After, 10, 64); err != nil {
+			return nil, ErrNotFound
+		}
+	}
+
+	var rows []deadLetterRow
+	query := fmt.Sprintf(`SELECT id, queue, payload, headers, error, attempts, failed_at
+		FROM %s WHERE queue = $1 AND id > $2 ORDER BY id LIMIT $3`, s.table)
+	if err := s.db.SelectContext(ctx, "zidlq.list", &rows, query, queue, after, opts.limit()); err != nil {
+		return nil, err
+	}
+
+	msgs := make([]Message, 0, len(rows))
+	for _, r := range rows {
+		msg, err := r.message()
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// Get implements Store.
+func (s *PostgresStore) Get(ctx context.Context, queue string, id string) (Message, error) {
+	rowID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return Message{}, ErrNotFound
+	}
+
+	var row deadLetterRow
+	query := fmt.Sprintf(`SELECT id, queue, payload, headers, error, attempts, failed_at
+		FROM %s WHERE queue = $1 AND id = $2`, s.table)
+	if err := s.db.GetContext(ctx, "zidlq.get", &row, query, queue, rowID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Message{}, ErrNotFound
+		}
+		return Message{}, err
+	}
+	return row.message()
+}
+
+// Delete implements Store.
+func (s *PostgresStore) Delete(ctx context.Context, queue string, ids ...string) error {
+	rowIDs := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		rowID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return ErrNotFound
+		}
+		rowIDs = append(rowIDs, rowID)
+	}
+
+	query := fmt.Sprintf(`DELETE FROM %s WHERE queue = $1 AND id = ANY($2)`, s.table)
+	_, err := s.db.ExecContext(ctx, "zidlq.delete", query, queue, pq.Array(rowIDs))
+	return err
+}
+
+// Purge implements Store.
+func (s *PostgresStore) Purge(ctx context.Context, queue string) (int64, error) {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE queue = $1`, s.table)
+	res, err := s.db.ExecContext(ctx, "zidlq.purge", query, queue)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Stats implements Store.
+func (s *PostgresStore) Stats(ctx context.Context) ([]QueueStats, error) {
+	var rows []struct {
+		Queue          string    `db:"queue"`
+		Depth          int64     `db:"depth"`
+		OldestFailedAt time.Time `db:"oldest_failed_at"`
+	}
+	query := fmt.Sprintf(`SELECT queue, COUNT(*) AS depth, MIN(failed_at) AS oldest_failed_at
+		FROM %s GROUP BY queue ORDER BY queue`, s.table)
+	if err := s.db.SelectContext(ctx, "zidlq.stats", &rows, query); err != nil {
+		return nil, err
+	}
+
+	stats := make([]QueueStats, 0, len(rows))
+	for _, r := range rows {
+		stats = append(stats, QueueStats(r))
+	}
+	return stats, nil
+}
+
+// Compile-time interface compliance check
+var _ Store = (*PostgresStore)(nil)
@@ -0,0 +1,180 @@
+package zidlq
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore is a Store keeping dead messages in Redis streams, one stream per
+// queue. The queue names are kept in a set so they can be listed.
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisStore returns a RedisStore using keys prefixed with prefix, e.g.
+// "dlq:".
+func NewRedisStore(client redis.UniversalClient, prefix string) *RedisStore {
+	return &RedisStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (s *RedisStore) streamKey(queue string) string {
+	return s.prefix + "{" + queue + "}"
+}
+
+func (s *RedisStore) queuesKey() string {
+	return s.prefix + "queues"
+}
+
+// Put implements Store.
+func (s *RedisStore) Put(ctx context.Context, msg *Message) error {
+	values := map[string]any{
+		"payload":   msg.Payload,
+		"error":     msg.Error,
+		"attempts":  msg.Attempts,
+		"failed_at": msg.FailedAt.Format(time.RFC3339Nano),
+	}
+	if len(msg.Headers) > 0 {
+		headers, err := json.Marshal(msg.Headers)
+		if err != nil {
+			return err
+		}
+		values["headers"] = headers
+	}
+
+	id, err := s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.streamKey(msg.Queue),
+		Values: values,
+	}).Result()
+	if err != nil {
+		return err
+	}
+	if err := s.client.SAdd(ctx, s.queuesKey(), msg.Queue).Err(); err != nil {
+		return err
+	}
+	msg.ID = id
+	return nil
+}
+
+// List implements Store.
+func (s *RedisStore) List(ctx context.Context, queue string, opts ListOptions) ([]Message, error) {
+	start := "-"
+	if opts.After != "" {
+		start = "(" + opts.After
+	}
+
+	entries, err := s.client.XRangeN(ctx, s.streamKey(queue), start, "+", int64(opts.limit())).Result()
+	if err != nil {
+		return nil, err
+	}
+	return s.messages(queue, entries)
+}
+
+// Get implements Store.
+func (s *RedisStore) Get(ctx context.Context, queue string, id string) (Message, error) {
+	entries, err := s.client.XRange(ctx, s.streamKey(queue), id, id).Result()
+	if err != nil {
+		return Message{}, err
+	}
+	if len(entries) == 0 {
+		return Message{}, ErrNotFound
+	}
+	msgs, err := s.messages(queue, entries)
+	if err != nil {
+		return Message{}, err
+	}
+	return msgs[0], nil
+}
+
+func (s *RedisStore) messages(queue string, entries []redis.XMessage) ([]Message, error) {
+	msgs := make([]Message, 0, len(entries))
+	for _, e := range entries {
+		msg := Message{
+			ID:    e.ID,
+			Queue: queue,
+		}
+		if v, ok := e.Values["payload"].(string); ok {
+			msg.Payload = []byte(v)
+		}
+		if v, ok := e.Values["error"].(string); ok {
+			msg.Error = v
+		}
+		if v, ok := e.Values["attempts"].(string); ok {
+			msg.Attempts, _ = strconv.Atoi(v)
+		}
+		if v, ok := e.Values["failed_at"].(string); ok {
+			msg.FailedAt, _ = time.Parse(time.RFC3339Nano, v)
+		}
+		if v, ok := e.Values["headers"].(string); ok && v != "" {
+			if err := json.Unmarshal([]byte(v), &msg.Headers); err != nil {
+				return nil, err
+			}
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// Delete implements Store.
+func (s *RedisStore) Delete(ctx context.Context, queue string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.client.XDel(ctx, s.streamKey(queue), ids...).Err()
+}
+
+// Purge implements Store.
+func (s *RedisStore) Purge(ctx context.Context, queue string) (int64, error) {
+	key := s.streamKey(queue)
+	n, err := s.client.XLen(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if err := s.client.Del(ctx, key).Err(); err != nil {
+		return 0, err
+	}
+	return n, s.client.SRem(ctx, s.queuesKey(), queue).Err()
+}
+
+// Stats implements Store.
+func (s *RedisStore) Stats(ctx context.Context) ([]QueueStats, error) {
+	queues, err := s.client.SMembers(ctx, s.queuesKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(queues)
+
+	stats := make([]QueueStats, 0, len(queues))
+	for _, queue := range queues {
+		key := s.streamKey(queue)
+		depth, err := s.client.XLen(ctx, key).Result()
+		if err != nil {
+			return nil, err
+		}
+		if depth == 0 {
+			continue
+		}
+
+		st := QueueStats{Queue: queue, Depth: depth}
+		oldest, err := s.client.XRangeN(ctx, key, "-", "+", 1).Result()
+		if err != nil {
+			return nil, err
+		}
+		if msgs, err := s.messages(queue, oldest); err == nil && len(msgs) > 0 {
+			st.OldestFailedAt = msgs[0].FailedAt
+		}
+		stats = append(stats, st)
+	}
+	return stats, nil
+}
+
+// Compile-time interface compliance check
+var _ Store = (*RedisStore)(nil)
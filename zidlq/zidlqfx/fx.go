@@ -0,0 +1,55 @@
+package zidlqfx
+
+import (
+	"github.com/divikraf/lumos/zidlq"
+	"github.com/rs/zerolog"
+	"go.uber.org/fx"
+)
+
+// RedriverRegistration registers the Redriver of a queue, provide it in the
+// "zidlq-redrivers" group.
+type RedriverRegistration struct {
+	Queue    string
+	Redriver zidlq.Redriver
+}
+
+type managerParams struct {
+	fx.In
+
+	LC        fx.Lifecycle
+	Store     zidlq.Store
+	Logger    *zerolog.Logger
+	Redrivers []RedriverRegistration `group:"zidlq-redrivers"`
+}
+
+// Provider provides a *zidlq.Manager, a zidlq.Store must be provided.
+var Provider = fx.Provide(
+	func(params managerParams) (*zidlq.Manager, error) {
+		opts := make([]zidlq.Option, 0, len(params.Redrivers))
+		for _, r := range params.Redrivers {
+			opts = append(opts, zidlq.WithRedriver(r.Queue, r.Redriver))
+		}
+
+		m, err := zidlq.New(params.Store, params.Logger, opts...)
+		if err != nil {
+			return nil, err
+		}
+		params.LC.Append(fx.StopHook(m.Close))
+		return m, nil
+	},
+)
+
+type redriverResult struct {
+	fx.Out
+
+	Registration RedriverRegistration `group:"zidlq-redrivers"`
+}
+
+// WithRedriver registers the Redriver of queue.
+func WithRedriver(queue string, redriver zidlq.Redriver) fx.Option {
+	return fx.Provide(func() redriverResult {
+		return redriverResult{
+			Registration: RedriverRegistration{Queue: queue, Redriver: redriver},
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package zidr
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/divikraf/lumos/zin"
+	"github.com/gin-gonic/gin"
+)
+
+// Headers of the responses served while degraded
+const (
+	LevelHeader    = "X-Degradation-Level"
+	FeaturesHeader = "X-Degraded-Features"
+)
+
+// AdminPath is the path of the degradation admin endpoint.
+const AdminPath = "/degradation"
+
+// Middleware returns a zin middleware adding the LevelHeader and
+// FeaturesHeader headers to the responses served while degraded, so
+// downstream services and clients know they got a degraded response.
+func (c *Coordinator) Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if level := c.Level(); level > LevelNormal {
+			ctx.Header(LevelHeader, strconv.Itoa(level))
+			if features := c.DegradedFeatures(); len(features) > 0 {
+				ctx.Header(FeaturesHeader, strings.Join(features, ","))
+			}
+		}
+		ctx.Next()
+	}
+}
+
+// levelRequest is the body of the PUT admin endpoint.
+type levelRequest struct {
+	Level *int `json:"level" binding:"required"`
+}
+
+// RegisterRoutes mounts the admin endpoint on router, best on an internal
+// server: GET AdminPath returns the state, PUT sets the level manually from
+// a {"level": 2} body, and DELETE clears it.
+func (c *Coordinator) RegisterRoutes(router gin.IRouter) {
+	group := router.Group("")
+	if c.config.Token != "" {
+		group.Use(zin.RequireBearerToken(c.config.Token))
+	}
+	group.GET(AdminPath, func(ctx *gin.Context) {
+		zin.OK(ctx, c.state())
+	})
+	group.PUT(AdminPath, func(ctx *gin.Context) {
+		var req levelRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil || *req.Level < LevelNormal {
+			zin.AbortWithError(ctx, zin.ErrBadRequest)
+			return
+		}
+		c.SetLevel(ctx.Request.Context(), *req.Level)
+		zin.OK(ctx, c.state())
+	})
+	group.DELETE(AdminPath, func(ctx *gin.Context) {
+		c.ClearLevel(ctx.Request.Context())
+		zin.OK(ctx, c.state())
+	})
+}
@@ -0,0 +1,238 @@
+// Package zidr coordinates the graceful degradation of a service: features
+// register the degradation level from which they are turned off or served
+// in a cheaper way, e.g. disabling recommendations at level 1 and serving
+// cached prices at level 2. The current level is raised automatically by
+// triggers, e.g. an unhealthy dependency or an open circuit, or set manually
+// through the admin endpoint.
+package zidr
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/divikraf/lumos/zin/health"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// LevelNormal is the level of a service not degraded.
+const LevelNormal = 0
+
+// Config configures a Coordinator.
+type Config struct {
+	// Interval is the interval between trigger evaluations (default: 5s).
+	Interval time.Duration `json:"interval" yaml:"interval"`
+	// Timeout is the maximum duration of an evaluation (default: 2s).
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+	// Token, if set, must be sent as a bearer token to reach the admin
+	// endpoint.
+	Token string `json:"token" yaml:"token"`
+}
+
+// Trigger raises the degradation level to Level while active.
+type Trigger struct {
+	// Name identifies the trigger in the logs and the admin endpoint.
+	Name  string
+	Level int
+	// Active reports whether the trigger is active.
+	Active func(ctx context.Context) bool
+}
+
+// HealthTrigger returns a Trigger raising the level to level while the
+// checker named checker of h is down.
+func HealthTrigger(h *health.Health, checker string, level int) Trigger {
+	return Trigger{
+		Name:  "health:" + checker,
+		Level: level,
+		Active: func(ctx context.Context) bool {
+			result, ok := h.Check(ctx).Checks[checker]
+			return ok && result.Status == health.StatusDown
+		},
+	}
+}
+
+// Coordinator holds the degradation level of the service, and the features
+// degraded by it. The level is recorded in the degradation_level gauge.
+type Coordinator struct {
+	config Config
+	logger *zerolog.Logger
+	gauge  metric.Int64Gauge
+
+	mu       sync.RWMutex
+	features map[string]int
+	triggers []Trigger
+	active   []string
+	auto     int
+	override *int
+}
+
+// New returns a Coordinator at LevelNormal.
+func New(config Config, logger *zerolog.Logger) *Coordinator {
+	if config.Interval <= 0 {
+		config.Interval = 5 * time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 2 * time.Second
+	}
+	c := &Coordinator{
+		config:   config,
+		logger:   logger,
+		features: map[string]int{},
+		gauge: revelio.MustInt64Gauge(
+			"degradation_level",
+			"Current degradation level of the service, 0 when not degraded",
+		),
+	}
+	c.gauge.Record(context.Background(), LevelNormal)
+	return c
+}
+
+// Register registers feature, degraded from level.
+func (c *Coordinator) Register(feature string, level int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.features[feature] = level
+}
+
+// AddTrigger adds a trigger, evaluated by Evaluate.
+func (c *Coordinator) AddTrigger(t Trigger) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.triggers = append(c.triggers, t)
+}
+
+// Level returns the current level: the manual level when set, the highest
+// level of the active triggers otherwise.
+func (c *Coordinator) Level() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.level()
+}
+
+func (c *Coordinator) level() int {
+	if c.override != nil {
+		return *c.override
+	}
+	return c.auto
+}
+
+// Degraded reports whether feature is degraded at the current level.
+// Features not registered are never degraded.
+func (c *Coordinator) Degraded(feature string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	from, ok := c.features[feature]
+	return ok && c.level() >= from
+}
+
+// DegradedFeatures returns the features degraded at the current level,
+// sorted.
+func (c *Coordinator) DegradedFeatures() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.degradedFeatures()
+}
+
+func (c *Coordinator) degradedFeatures() []string {
+	level := c.level()
+	var features []string
+	for f, from := range c.features {
+		if level >= from {
+			features = append(features, f)
+		}
+	}
+	sort.Strings(features)
+	return features
+}
+
+// SetLevel sets the level manually, whatever the triggers, until
+// ClearLevel. Setting LevelNormal forces the service out of degradation,
+// e.g. when a trigger misfires.
+func (c *Coordinator) SetLevel(ctx context.Context, level int) {
+	c.update(ctx, func() { c.override = &level }, "manual")
+}
+
+// ClearLevel clears the manual level, back to the level of the triggers.
+func (c *Coordinator) ClearLevel(ctx context.Context) {
+	c.update(ctx, func() { c.override = nil }, "manual")
+}
+
+// Evaluate evaluates the triggers and updates the level.
+func (c *Coordinator) Evaluate(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+
+	c.mu.RLock()
+	triggers := append([]Trigger(nil), c.triggers...)
+	c.mu.RUnlock()
+
+	level := LevelNormal
+	var active []string
+	for _, t := range triggers {
+		if t.Active(ctx) {
+			active = append(active, t.Name)
+			level = max(level, t.Level)
+		}
+	}
+	c.update(ctx, func() { c.auto, c.active = level, active }, "trigger")
+}
+
+// update applies f, logging and recording the level when it changed.
+func (c *Coordinator) update(ctx context.Context, f func(), source string) {
+	c.mu.Lock()
+	from := c.level()
+	f()
+	to := c.level()
+	features, active := c.degradedFeatures(), c.active
+	c.mu.Unlock()
+
+	if from == to {
+		return
+	}
+	c.gauge.Record(ctx, int64(to))
+	event := c.logger.Info()
+	if to > from {
+		event = c.logger.Warn()
+	}
+	event.Int("from", from).Int("to", to).
+		Str("source", source).
+		Strs("triggers", active).
+		Strs("degraded_features", features).
+		Msg("degradation level changed")
+}
+
+// Run evaluates the triggers every interval until ctx is done.
+func (c *Coordinator) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.config.Interval)
+	defer ticker.Stop()
+	for {
+		c.Evaluate(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// state is the state of a Coordinator, returned by the admin endpoint.
+type state struct {
+	Level            int      `json:"level"`
+	Manual           bool     `json:"manual"`
+	ActiveTriggers   []string `json:"active_triggers"`
+	DegradedFeatures []string `json:"degraded_features"`
+}
+
+func (c *Coordinator) state() state {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return state{
+		Level:            c.level(),
+		Manual:           c.override != nil,
+		ActiveTriggers:   append([]string{}, c.active...),
+		DegradedFeatures: append([]string{}, c.degradedFeatures()...),
+	}
+}
@@ -0,0 +1,92 @@
+package zidr
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/divikraf/lumos/zin"
+	"github.com/divikraf/lumos/zin/health"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+func TestCoordinator(t *testing.T) {
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	c := New(Config{}, &logger)
+	c.Register("recommendations", 1)
+	c.Register("live_prices", 2)
+
+	var pricingDown bool
+	c.AddTrigger(Trigger{Name: "pricing", Level: 2, Active: func(context.Context) bool { return pricingDown }})
+	h := health.New(health.Config{CacheTTL: -1}, health.NewChecker("search", func(context.Context) error {
+		return errors.New("unreachable")
+	}))
+	c.AddTrigger(HealthTrigger(h, "search", 1))
+
+	c.Evaluate(ctx)
+	if c.Level() != 1 || !c.Degraded("recommendations") || c.Degraded("live_prices") || c.Degraded("unknown") {
+		t.Fatalf("level = %d, degraded = %v, want level 1 from the health trigger", c.Level(), c.DegradedFeatures())
+	}
+
+	pricingDown = true
+	c.Evaluate(ctx)
+	if got := c.DegradedFeatures(); c.Level() != 2 || len(got) != 2 {
+		t.Fatalf("level = %d, degraded = %v, want every feature degraded", c.Level(), got)
+	}
+
+	c.SetLevel(ctx, LevelNormal)
+	if c.Level() != LevelNormal || c.Degraded("recommendations") {
+		t.Errorf("level = %d, want the manual level", c.Level())
+	}
+	c.Evaluate(ctx)
+	if c.Level() != LevelNormal {
+		t.Errorf("level = %d, want the manual level to win over the triggers", c.Level())
+	}
+	c.ClearLevel(ctx)
+	if c.Level() != 2 {
+		t.Errorf("level = %d after clearing the manual level, want 2", c.Level())
+	}
+}
+
+func TestCoordinatorHTTP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := zerolog.Nop()
+	c := New(Config{Token: "secret"}, &logger)
+	c.Register("recommendations", 1)
+	c.Register("live_prices", 2)
+
+	router := gin.New()
+	router.Use(zin.ErrorMiddleware(), c.Middleware())
+	router.GET("/products", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	c.RegisterRoutes(router)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodGet, "/products", ""); w.Header().Get(LevelHeader) != "" {
+		t.Errorf("%s = %q while not degraded", LevelHeader, w.Header().Get(LevelHeader))
+	}
+	if w := do(http.MethodPut, AdminPath, `{"level": -1}`); w.Code != http.StatusBadRequest {
+		t.Errorf("PUT %s of a negative level = %d, want 400", AdminPath, w.Code)
+	}
+	if w := do(http.MethodPut, AdminPath, `{"level": 2}`); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"manual":true`) {
+		t.Fatalf("PUT %s = %d %s", AdminPath, w.Code, w.Body)
+	}
+	w := do(http.MethodGet, "/products", "")
+	if w.Header().Get(LevelHeader) != "2" || w.Header().Get(FeaturesHeader) != "live_prices,recommendations" {
+		t.Errorf("headers = %v, want the level and the degraded features", w.Header())
+	}
+	if w := do(http.MethodDelete, AdminPath, ""); w.Code != http.StatusOK || c.Level() != LevelNormal {
+		t.Errorf("DELETE %s = %d, level %d", AdminPath, w.Code, c.Level())
+	}
+}
@@ -0,0 +1,104 @@
+package zidrfx
+
+import (
+	"context"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zidr"
+	"github.com/divikraf/lumos/zin"
+	"github.com/divikraf/lumos/zin/zinfx"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"go.uber.org/fx"
+)
+
+// FeatureRegistration registers a feature degraded from Level, provide it in
+// the "zidr-features" group.
+type FeatureRegistration struct {
+	Name  string
+	Level int
+}
+
+// degradationConfig is implemented by configs customizing the coordinator.
+type degradationConfig interface {
+	GetDegradation() zidr.Config
+}
+
+type coordinatorParams struct {
+	fx.In
+
+	LC       fx.Lifecycle
+	Config   ziconf.Config
+	Logger   *zerolog.Logger
+	Features []FeatureRegistration `group:"zidr-features"`
+	Triggers []zidr.Trigger        `group:"zidr-triggers"`
+}
+
+// Provider provides a *zidr.Coordinator evaluating its triggers while the
+// app runs, and adds its response headers to the main router.
+var Provider = fx.Options(
+	fx.Provide(func(params coordinatorParams) *zidr.Coordinator {
+		var config zidr.Config
+		if c, ok := params.Config.(degradationConfig); ok {
+			config = c.GetDegradation()
+		}
+		c := zidr.New(config, params.Logger)
+		for _, f := range params.Features {
+			c.Register(f.Name, f.Level)
+		}
+		for _, t := range params.Triggers {
+			c.AddTrigger(t)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		params.LC.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				go func() {
+					defer close(done)
+					c.Run(ctx)
+				}()
+				return nil
+			},
+			OnStop: func(stopCtx context.Context) error {
+				cancel()
+				select {
+				case <-done:
+					return nil
+				case <-stopCtx.Done():
+					return stopCtx.Err()
+				}
+			},
+		})
+		return c
+	}),
+	zinfx.AddMiddleware(func(c *zidr.Coordinator) gin.HandlerFunc { return c.Middleware() }),
+)
+
+type featureResult struct {
+	fx.Out
+
+	Registration FeatureRegistration `group:"zidr-features"`
+}
+
+// WithFeature registers feature, degraded from level.
+func WithFeature(feature string, level int) fx.Option {
+	return fx.Provide(func() featureResult {
+		return featureResult{Registration: FeatureRegistration{Name: feature, Level: level}}
+	})
+}
+
+// AsTrigger annotates a constructor of a zidr.Trigger, e.g. built with
+// zidr.HealthTrigger, to provide it in the "zidr-triggers" group.
+func AsTrigger(constructor any) any {
+	return fx.Annotate(constructor, fx.ResultTags(`group:"zidr-triggers"`))
+}
+
+// AdminRoutes mounts the admin endpoint on the additional server name, see
+// zinfx.Server, or on the main router when empty.
+func AdminRoutes(server string) fx.Option {
+	return fx.Provide(fx.Annotate(
+		func(c *zidr.Coordinator) zin.RouteRegistrar { return c },
+		fx.ResultTags(zinfx.RoutesGroup(server)),
+	))
+}
@@ -0,0 +1,352 @@
+// Package ziexport runs long exports of records, e.g. reports of database
+// rows, to object storage without holding them in memory.
+//
+// The records are read by pages from a Source, encoded in a Format and
+// uploaded to a zistorage.Bucket by parts, bounded in size, buffered in
+// temporary files. A checkpoint stored next to the export after every part
+// records the progress, so an interrupted export resumes from its last part
+// when run again. The parts are then concatenated into the file of the
+// export, and the Notifier is told about the outcome.
+package ziexport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zistorage"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/divikraf/lumos/ziworker"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const tracerName = "github.com/divikraf/lumos/ziexport"
+
+// Job is an export.
+type Job struct {
+	// Name identifies the kind of export in the telemetry, e.g.
+	// "orders_report". It must come from a small bounded set.
+	Name string
+	// Key is the object key of the export file, identifying the export: an
+	// export of the same key resumes from its checkpoint.
+	Key     string
+	Columns []string
+	Source  Source
+	// Format is the format of the file (default: CSV).
+	Format Format
+	// PageSize is the number of records read at once (default: 1000).
+	PageSize int
+	// PartRecords is the number of records of a part, uploaded and
+	// checkpointed at once (default: 100000).
+	PartRecords int
+	// URLExpires, if set, is the validity of the signed URL of the file in
+	// the Result.
+	URLExpires time.Duration
+}
+
+func (j Job) withDefaults() Job {
+	if j.Format == nil {
+		j.Format = CSV
+	}
+	if j.PageSize <= 0 {
+		j.PageSize = 1000
+	}
+	if j.PartRecords <= 0 {
+		j.PartRecords = 100000
+	}
+	return j
+}
+
+// Result is the outcome of an export.
+type Result struct {
+	Name    string `json:"name"`
+	Key     string `json:"key"`
+	Records int64  `json:"records"`
+	Size    int64  `json:"size"`
+	// URL is the signed URL of the file, when Job.URLExpires is set.
+	URL string `json:"url,omitempty"`
+}
+
+// Checkpoint is the progress of an export, stored after every part.
+type Checkpoint struct {
+	Cursor string `json:"cursor"`
+	// Parts are the sizes of the uploaded parts.
+	Parts   []int64 `json:"parts"`
+	Records int64   `json:"records"`
+}
+
+// Exporter runs exports to a bucket.
+type Exporter struct {
+	bucket   zistorage.Bucket
+	pool     *ziworker.Pool
+	notifier Notifier
+	tempDir  string
+
+	records  metric.Int64Counter
+	jobs     revelio.ResultCounter
+	duration revelio.DurationRecorder
+}
+
+// Option configures an Exporter.
+type Option func(*Exporter)
+
+// WithNotifier sets the notifier of the outcome of the exports.
+func WithNotifier(n Notifier) Option {
+	return func(e *Exporter) {
+		e.notifier = n
+	}
+}
+
+// WithTempDir sets the directory of the parts being written (default: the
+// default directory for temporary files).
+func WithTempDir(dir string) Option {
+	return func(e *Exporter) {
+		e.tempDir = dir
+	}
+}
+
+// New returns an Exporter writing to bucket, running the exports started
+// in pool. The exports are recorded in the export_records_total,
+// export_jobs_total and export_duration_ms metrics, by name.
+func New(bucket zistorage.Bucket, pool *ziworker.Pool, opts ...Option) *Exporter {
+	e := &Exporter{
+		bucket:   bucket,
+		pool:     pool,
+		records:  revelio.MustInt64Counter("export_records_total", "Number of records exported, by export"),
+		jobs:     revelio.MustResultCounter("export_jobs_total", "Number of exports, by export and status"),
+		duration: revelio.MustDuration("export_duration_ms", "Duration of exports in milliseconds, by export and status"),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Start queues job in the pool of the Exporter, blocking while its queue is
+// full until ctx is done.
+func (e *Exporter) Start(ctx context.Context, job Job) error {
+	return e.pool.Submit(ctx, "export "+job.Name, func(ctx context.Context) error {
+		_, err := e.Run(ctx, job)
+		return err
+	})
+}
+
+// Run runs job, resuming it from its checkpoint if any, and notifies its
+// outcome. Once the file is written, the parts and the checkpoint are
+// deleted.
+func (e *Exporter) Run(ctx context.Context, job Job) (result Result, err error) {
+	job = job.withDefaults()
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "export "+job.Name)
+	attrs := []attribute.KeyValue{attribute.String("export", job.Name)}
+	start := time.Now()
+	result = Result{Name: job.Name, Key: job.Key}
+	defer func() {
+		e.duration.Record(ctx, time.Since(start), append(attrs, revelio.ResultAttributes(err)...)...)
+		e.jobs.Record(ctx, err, attrs...)
+		if err != nil {
+			observe.RecordError(span, err)
+		}
+		span.End()
+		if e.notifier != nil {
+			if nerr := e.notifier.Notify(ctx, result, err); nerr != nil {
+				zilog.FromContext(ctx).Error().Err(nerr).Str("export", job.Name).Msg("Failed to notify export")
+			}
+		}
+	}()
+
+	cp, err := e.loadCheckpoint(ctx, job.Key)
+	if err != nil {
+		return result, err
+	}
+	if len(cp.Parts) > 0 {
+		zilog.FromContext(ctx).Info().Str("export", job.Name).Str("key", job.Key).Int64("records", cp.Records).Msg("Resuming export")
+	}
+	for done := false; !done; {
+		if done, err = e.writePart(ctx, job, &cp); err != nil {
+			return result, err
+		}
+	}
+
+	size, err := e.assemble(ctx, job, cp)
+	if err != nil {
+		return result, err
+	}
+	result.Records, result.Size = cp.Records, size
+	if job.URLExpires > 0 {
+		if result.URL, err = e.bucket.SignedURL(ctx, job.Key, "GET", job.URLExpires); err != nil {
+			return result, err
+		}
+	}
+	return result, e.cleanup(ctx, job.Key, len(cp.Parts))
+}
+
+// writePart writes the next part of job, and checkpoints it. It reports
+// whether the source is exhausted.
+func (e *Exporter) writePart(ctx context.Context, job Job, cp *Checkpoint) (bool, error) {
+	f, err := os.CreateTemp(e.tempDir, "ziexport-*")
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+
+	enc := job.Format.NewEncoder(f)
+	first := len(cp.Parts) == 0
+	if first && len(job.Columns) > 0 {
+		if err := enc.Write(job.Columns); err != nil {
+			return false, err
+		}
+	}
+	cursor, records, done := cp.Cursor, 0, false
+	for records < job.PartRecords {
+		page, next, err := job.Source.Next(ctx, cursor, job.PageSize)
+		if err != nil {
+			return false, err
+		}
+		if len(page) == 0 {
+			done = true
+			break
+		}
+		for _, r := range page {
+			if err := enc.Write(r); err != nil {
+				return false, err
+			}
+		}
+		cursor, records = next, records+len(page)
+	}
+	if err := enc.Flush(); err != nil {
+		return false, err
+	}
+	if records == 0 && !first {
+		return true, nil
+	}
+
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return false, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	part := partKey(job.Key, len(cp.Parts))
+	if _, err := e.bucket.Put(ctx, part, f, zistorage.PutOptions{ContentType: job.Format.ContentType(), Size: size}); err != nil {
+		return false, err
+	}
+	cp.Cursor, cp.Parts, cp.Records = cursor, append(cp.Parts, size), cp.Records+int64(records)
+	e.records.Add(ctx, int64(records), metric.WithAttributes(attribute.String("export", job.Name)))
+	if err := e.saveCheckpoint(ctx, job.Key, *cp); err != nil {
+		return false, err
+	}
+	zilog.FromContext(ctx).Debug().Str("export", job.Name).Str("key", job.Key).Int64("records", cp.Records).Msg("Export part written")
+	return done, nil
+}
+
+// assemble concatenates the parts of job into its file, and returns its
+// size.
+func (e *Exporter) assemble(ctx context.Context, job Job, cp Checkpoint) (int64, error) {
+	var size int64
+	for _, s := range cp.Parts {
+		size += s
+	}
+	r := &partsReader{ctx: ctx, bucket: e.bucket, key: job.Key, parts: len(cp.Parts)}
+	defer r.Close()
+	_, err := e.bucket.Put(ctx, job.Key, r, zistorage.PutOptions{ContentType: job.Format.ContentType(), Size: size})
+	return size, err
+}
+
+// cleanup deletes the parts and the checkpoint of the export key.
+func (e *Exporter) cleanup(ctx context.Context, key string, parts int) error {
+	var errs []error
+	for i := range parts {
+		errs = append(errs, e.bucket.Delete(ctx, partKey(key, i)))
+	}
+	errs = append(errs, e.bucket.Delete(ctx, checkpointKey(key)))
+	return errors.Join(errs...)
+}
+
+func (e *Exporter) loadCheckpoint(ctx context.Context, key string) (Checkpoint, error) {
+	var cp Checkpoint
+	rc, _, err := e.bucket.Get(ctx, checkpointKey(key))
+	if errors.Is(err, zistorage.ErrObjectNotFound) {
+		return cp, nil
+	}
+	if err != nil {
+		return cp, err
+	}
+	defer rc.Close()
+	if err := json.NewDecoder(rc).Decode(&cp); err != nil {
+		return cp, fmt.Errorf("ziexport: invalid checkpoint of %s: %w", key, err)
+	}
+	return cp, nil
+}
+
+func (e *Exporter) saveCheckpoint(ctx context.Context, key string, cp Checkpoint) error {
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	_, err = e.bucket.Put(ctx, checkpointKey(key), bytes.NewReader(b), zistorage.PutOptions{ContentType: "application/json", Size: int64(len(b))})
+	return err
+}
+
+func partKey(key string, i int) string {
+	return fmt.Sprintf("%s.parts/%05d", key, i)
+}
+
+func checkpointKey(key string) string {
+	return key + ".checkpoint.json"
+}
+
+// partsReader reads the parts of an export in order, opening them one at a
+// time.
+type partsReader struct {
+	ctx    context.Context
+	bucket zistorage.Bucket
+	key    string
+	parts  int
+	next   int
+	cur    io.ReadCloser
+}
+
+func (r *partsReader) Read(p []byte) (int, error) {
+	for {
+		if r.cur == nil {
+			if r.next == r.parts {
+				return 0, io.EOF
+			}
+			rc, _, err := r.bucket.Get(r.ctx, partKey(r.key, r.next))
+			if err != nil {
+				return 0, err
+			}
+			r.cur, r.next = rc, r.next+1
+		}
+		n, err := r.cur.Read(p)
+		if err == io.EOF {
+			r.cur.Close()
+			r.cur = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *partsReader) Close() error {
+	if r.cur != nil {
+		return r.cur.Close()
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package ziexport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/divikraf/lumos/zistorage"
+)
+
+// numbers is a Source of the records 1 to n, failing once after failAfter
+// records when set.
+type numbers struct {
+	n         int
+	failAfter int
+	cursors   []string
+}
+
+func (s *numbers) Next(ctx context.Context, cursor string, limit int) ([][]string, string, error) {
+	s.cursors = append(s.cursors, cursor)
+	from, _ := strconv.Atoi(cursor)
+	if s.failAfter > 0 && from >= s.failAfter {
+		s.failAfter = 0
+		return nil, "", errors.New("connection reset")
+	}
+	var records [][]string
+	for i := from + 1; i <= s.n && len(records) < limit; i++ {
+		records = append(records, []string{strconv.Itoa(i), "n" + strconv.Itoa(i)})
+	}
+	return records, strconv.Itoa(from + len(records)), nil
+}
+
+func newTestBucket(t *testing.T) zistorage.Bucket {
+	b, err := zistorage.NewFSBucket(zistorage.FSConfig{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func readObject(t *testing.T, b zistorage.Bucket, key string) string {
+	t.Helper()
+	rc, _, err := b.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get(%s) = %v", key, err)
+	}
+	defer rc.Close()
+	content, _ := io.ReadAll(rc)
+	return string(content)
+}
+
+func TestExporterResumes(t *testing.T) {
+	bucket := newTestBucket(t)
+	var notified []error
+	e := New(bucket, nil, WithTempDir(t.TempDir()), WithNotifier(NotifierFunc(func(ctx context.Context, result Result, err error) error {
+		notified = append(notified, err)
+		return nil
+	})))
+	source := &numbers{n: 7, failAfter: 4}
+	job := Job{Name: "numbers", Key: "exports/numbers.csv", Columns: []string{"id", "name"}, Source: source, PageSize: 2, PartRecords: 4}
+	ctx := context.Background()
+
+	// The first run fails after its first part
+	if _, err := e.Run(ctx, job); err == nil {
+		t.Fatal("Run() succeeded with a failing source")
+	}
+	if _, err := bucket.Stat(ctx, "exports/numbers.csv.checkpoint.json"); err != nil {
+		t.Fatalf("no checkpoint after a failure: %v", err)
+	}
+
+	// The second run resumes after the last checkpointed record
+	source.cursors = nil
+	result, err := e.Run(ctx, job)
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if source.cursors[0] != "4" {
+		t.Errorf("resumed from cursor %q, want 4", source.cursors[0])
+	}
+	want := "id,name\n1,n1\n2,n2\n3,n3\n4,n4\n5,n5\n6,n6\n7,n7\n"
+	if got := readObject(t, bucket, job.Key); got != want {
+		t.Errorf("export =\n%s\nwant\n%s", got, want)
+	}
+	if result.Records != 7 || result.Size != int64(len(want)) {
+		t.Errorf("Result = %+v", result)
+	}
+	if len(notified) != 2 || notified[0] == nil || notified[1] != nil {
+		t.Errorf("notifications = %v, want a failure then a success", notified)
+	}
+
+	var left []string
+	bucket.List(ctx, "exports/", func(info zistorage.ObjectInfo) bool {
+		left = append(left, info.Key)
+		return true
+	})
+	if !reflect.DeepEqual(left, []string{job.Key}) {
+		t.Errorf("objects = %v, want the export only", left)
+	}
+}
+
+func TestExporterEmpty(t *testing.T) {
+	bucket := newTestBucket(t)
+	e := New(bucket, nil)
+	job := Job{Name: "empty", Key: "empty.csv", Columns: []string{"id"}, Source: &numbers{}}
+	if _, err := e.Run(context.Background(), job); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if got := readObject(t, bucket, "empty.csv"); got != "id\n" {
+		t.Errorf("export = %q, want the header only", got)
+	}
+}
+
+type usersDB struct {
+	queries int
+}
+
+type user struct {
+	ID    int64
+	Email string
+}
+
+func (db *usersDB) GetContext(ctx context.Context, operationName string, dest any, query string, args ...any) error {
+	return errors.New("unexpected GetContext")
+}
+
+func (db *usersDB) SelectContext(ctx context.Context, operationName string, dest any, query string, args ...any) error {
+	db.queries++
+	after, _ := strconv.ParseInt(args[0].(string), 10, 64)
+	var rows []user
+	for id := after + 1; id <= 3 && len(rows) < args[1].(int); id++ {
+		rows = append(rows, user{ID: id, Email: "u" + strconv.FormatInt(id, 10) + "@example.com"})
+	}
+	*dest.(*[]user) = rows
+	return nil
+}
+
+func TestSQLSource(t *testing.T) {
+	db := &usersDB{}
+	source := NewSQLSource(db, "export_users", "SELECT id, email FROM users WHERE id > CAST(? AS BIGINT) ORDER BY id LIMIT ?", "0",
+		func(u user) string { return strconv.FormatInt(u.ID, 10) },
+		func(u user) []string { return []string{strconv.FormatInt(u.ID, 10), strings.ToUpper(u.Email)} },
+	)
+	bucket := newTestBucket(t)
+	if _, err := New(bucket, nil).Run(context.Background(), Job{Name: "users", Key: "users.csv", Source: source, PageSize: 2}); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	want := "1,U1@EXAMPLE.COM\n2,U2@EXAMPLE.COM\n3,U3@EXAMPLE.COM\n"
+	if got := readObject(t, bucket, "users.csv"); got != want || db.queries != 3 {
+		t.Errorf("export = %q after %d queries, want %q after 3", got, db.queries, want)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	n := NewWebhookNotifier(srv.URL, nil)
+	if err := n.Notify(context.Background(), Result{Name: "users", Key: "users.csv", Records: 3}, errors.New("boom")); err != nil {
+		t.Fatalf("Notify() = %v", err)
+	}
+	if got["status"] != StatusFailed || got["error"] != "boom" || got["records"] != float64(3) {
+		t.Errorf("payload = %v", got)
+	}
+}
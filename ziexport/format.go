@@ -0,0 +1,47 @@
+package ziexport
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// Format is the file format of an export, e.g. CSV. The parts of an export
+// are concatenated into its file, so a format must support it.
+type Format interface {
+	// ContentType is the media type of the files.
+	ContentType() string
+	// NewEncoder returns an encoder of the records of a part, header
+	// included in the first one only.
+	NewEncoder(w io.Writer) Encoder
+}
+
+// Encoder encodes records.
+type Encoder interface {
+	Write(record []string) error
+	// Flush writes the buffered records.
+	Flush() error
+}
+
+// CSV exports records in CSV, as defined by RFC 4180.
+var CSV Format = csvFormat{}
+
+type csvFormat struct{}
+
+func (csvFormat) ContentType() string { return "text/csv" }
+
+func (csvFormat) NewEncoder(w io.Writer) Encoder {
+	return csvEncoder{csv.NewWriter(w)}
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e csvEncoder) Write(record []string) error {
+	return e.w.Write(record)
+}
+
+func (e csvEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
@@ -0,0 +1,57 @@
+package ziexport
+
+import (
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zistorage"
+	"github.com/divikraf/lumos/ziworker"
+	"go.uber.org/fx"
+)
+
+// Config configures the Exporter.
+type Config struct {
+	// Bucket is the name of the bucket of the exports, see zistorage.Config.
+	Bucket string `json:"bucket" yaml:"bucket" validate:"required"`
+	// WebhookURL, if set, is notified of the outcome of the exports, see
+	// NewWebhookNotifier.
+	WebhookURL string `json:"webhook_url" yaml:"webhook_url"`
+	// WebhookDryRun, if set, logs the notifications of WebhookURL instead
+	// of posting them, see NewDryRunWebhookNotifier.
+	WebhookDryRun bool `json:"webhook_dry_run" yaml:"webhook_dry_run"`
+}
+
+// exportConfig is implemented by configurations of the exports.
+type exportConfig interface {
+	GetExport() Config
+}
+
+type NewConfiguredExporterParams struct {
+	fx.In
+	Config   ziconf.Config
+	Buckets  zistorage.Buckets
+	Pool     *ziworker.Pool
+	Notifier Notifier `optional:"true"`
+}
+
+// NewConfiguredExporter returns the Exporter configured by the export
+// config, running the exports in the default pool. The provided Notifier, if
+// any, takes precedence over the webhook of the config, dry-run or not.
+func NewConfiguredExporter(params NewConfiguredExporterParams) (*Exporter, error) {
+	var config Config
+	if c, ok := params.Config.(exportConfig); ok {
+		config = c.GetExport()
+	}
+	bucket, err := params.Buckets.Bucket(config.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	var opts []Option
+	switch {
+	case params.Notifier != nil:
+		opts = append(opts, WithNotifier(params.Notifier))
+	case config.WebhookURL != "" && config.WebhookDryRun:
+		opts = append(opts, WithNotifier(NewDryRunWebhookNotifier(config.WebhookURL)))
+	case config.WebhookURL != "":
+		opts = append(opts, WithNotifier(NewWebhookNotifier(config.WebhookURL, nil)))
+	}
+	return New(bucket, params.Pool, opts...), nil
+}
@@ -0,0 +1,36 @@
+package ziexport
+
+import (
+	"net/http"
+
+	"github.com/divikraf/lumos/internal/webhook"
+)
+
+// Notifier is told about the outcome of the exports, e.g. to send the link
+// of the file to the user requesting it.
+type Notifier = webhook.Notifier[Result]
+
+// NotifierFunc is a Notifier function.
+type NotifierFunc = webhook.NotifierFunc[Result]
+
+// Notification statuses
+const (
+	StatusCompleted = webhook.StatusCompleted
+	StatusFailed    = webhook.StatusFailed
+)
+
+// NewWebhookNotifier returns a Notifier posting the results in JSON to url
+// with client, or a client of zihttpc.New if nil, e.g.
+//
+//	{"name": "orders_report", "key": "reports/1.csv", "records": 42, "size": 1024, "url": "https://...", "status": "completed"}
+func NewWebhookNotifier(url string, client *http.Client) Notifier {
+	return webhook.NewNotifier[Result](webhook.NewSender("ziexport", client, false), url)
+}
+
+// NewDryRunWebhookNotifier returns a Notifier logging the results it would
+// post to url instead of posting them, e.g. in staging environments. The
+// notifications are measured like those of NewWebhookNotifier, tagged
+// dry_run=true.
+func NewDryRunWebhookNotifier(url string) Notifier {
+	return webhook.NewNotifier[Result](webhook.NewSender("ziexport", nil, true), url)
+}
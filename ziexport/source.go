@@ -0,0 +1,69 @@
+package ziexport
+
+import (
+	"context"
+
+	"github.com/divikraf/lumos/db/zisqlx"
+)
+
+// Source reads the records of an export by pages, in a stable order.
+type Source interface {
+	// Next returns at most limit records following cursor, the empty cursor
+	// being the start, and the cursor of the last one. No records ends the
+	// export.
+	Next(ctx context.Context, cursor string, limit int) (records [][]string, next string, err error)
+}
+
+// SourceFunc is a Source function.
+type SourceFunc func(ctx context.Context, cursor string, limit int) ([][]string, string, error)
+
+func (f SourceFunc) Next(ctx context.Context, cursor string, limit int) ([][]string, string, error) {
+	return f(ctx, cursor, limit)
+}
+
+type sqlSource[T any] struct {
+	db            zisqlx.BasicQueryer
+	operationName string
+	query         string
+	start         string
+	cursor        func(T) string
+	record        func(T) []string
+}
+
+// NewSQLSource returns a Source paging through the rows of query with keyset
+// pagination, so that memory is bounded by the page, and the pages do not
+// slow down like with OFFSET. query selects the rows following its first
+// argument, the cursor, ordered by it, limited by its second argument, e.g.
+//
+//	SELECT id, email FROM users WHERE id > CAST(? AS BIGINT) ORDER BY id LIMIT ?
+//
+// start is the cursor of the first page, e.g. "0". cursor returns the cursor
+// of a row, and record transforms it into the record exported.
+func NewSQLSource[T any](db zisqlx.BasicQueryer, operationName, query, start string, cursor func(T) string, record func(T) []string) Source {
+	return &sqlSource[T]{
+		db:            db,
+		operationName: operationName,
+		query:         query,
+		start:         start,
+		cursor:        cursor,
+		record:        record,
+	}
+}
+
+func (s *sqlSource[T]) Next(ctx context.Context, cursor string, limit int) ([][]string, string, error) {
+	if cursor == "" {
+		cursor = s.start
+	}
+	var rows []T
+	if err := s.db.SelectContext(ctx, s.operationName, &rows, s.query, cursor, limit); err != nil {
+		return nil, "", err
+	}
+	if len(rows) == 0 {
+		return nil, cursor, nil
+	}
+	records := make([][]string, len(rows))
+	for i, row := range rows {
+		records[i] = s.record(row)
+	}
+	return records, s.cursor(rows[len(rows)-1]), nil
+}
@@ -0,0 +1,11 @@
+package ziexportfx
+
+import (
+	"github.com/divikraf/lumos/ziexport"
+	"go.uber.org/fx"
+)
+
+// Provider provides the *ziexport.Exporter configured by the export config,
+// given the zistorage.Buckets and the default *ziworker.Pool, see
+// zistoragefx.BucketsProvider and ziworkerfx.Provider.
+var Provider = fx.Provide(ziexport.NewConfiguredExporter)
@@ -0,0 +1,63 @@
+package zifeature
+
+import (
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zin"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminPath is the path of the flags admin endpoints.
+const AdminPath = "/features"
+
+// KillSwitchInfo describes a kill switch, as listed by the admin endpoint.
+type KillSwitchInfo struct {
+	Name   string `json:"name"`
+	Killed bool   `json:"killed"`
+}
+
+// RegisterRoutes mounts the admin endpoints on router, best on an internal
+// server:
+//
+//	GET AdminPath                         lists the flags and the kill switches
+//	PUT AdminPath/kill-switches/:name     kills or revives a kill switch, with {"killed": bool}
+//
+// The flags are listed when the provider implements Lister. Kill switches
+// are toggled on the instance serving the request only.
+func (c *Client) RegisterRoutes(router gin.IRouter) {
+	router.GET(AdminPath, func(ctx *gin.Context) {
+		var flags []Flag
+		if l, ok := c.provider.(Lister); ok {
+			var err error
+			if flags, err = l.Flags(ctx.Request.Context()); err != nil {
+				zin.AbortWithError(ctx, err)
+				return
+			}
+		}
+		switches := c.KillSwitches()
+		infos := make([]KillSwitchInfo, len(switches))
+		for i, k := range switches {
+			infos[i] = KillSwitchInfo{Name: k.Name(), Killed: k.Killed()}
+		}
+		zin.OK(ctx, gin.H{"flags": flags, "kill_switches": infos})
+	})
+	router.PUT(AdminPath+"/kill-switches/:name", func(ctx *gin.Context) {
+		k, ok := c.lookupKillSwitch(ctx.Param("name"))
+		if !ok {
+			zin.AbortWithError(ctx, zin.ErrNotFound)
+			return
+		}
+		var body struct {
+			Killed *bool `json:"killed"`
+		}
+		if err := ctx.ShouldBindJSON(&body); err != nil || body.Killed == nil {
+			zin.AbortWithError(ctx, zin.ErrBadRequest)
+			return
+		}
+		k.Set(*body.Killed)
+		zilog.FromContext(ctx.Request.Context()).Warn().
+			Str("kill_switch", k.Name()).
+			Bool("killed", *body.Killed).
+			Msg("Kill switch toggled")
+		zin.OK(ctx, KillSwitchInfo{Name: k.Name(), Killed: k.Killed()})
+	})
+}
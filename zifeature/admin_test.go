@@ -0,0 +1,61 @@
+package zifeature
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/divikraf/lumos/zin"
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c := NewClient(NewStaticProvider(Flag{Key: "search-v2", Value: true}, Flag{Key: "checkout", Value: false}))
+	c.KillSwitch("recommendations")
+
+	router := gin.New()
+	router.Use(zin.ErrorMiddleware())
+	c.RegisterRoutes(router)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	path := AdminPath + "/kill-switches/recommendations"
+	if w := do(http.MethodPut, path, `{"killed": true}`); w.Code != http.StatusOK {
+		t.Fatalf("PUT %s = %d %s", path, w.Code, w.Body)
+	}
+	if !c.KillSwitch("recommendations").Killed() {
+		t.Error("kill switch alive once killed by the admin endpoint")
+	}
+	if w := do(http.MethodPut, AdminPath+"/kill-switches/unknown", `{"killed": true}`); w.Code != http.StatusNotFound {
+		t.Errorf("PUT of an unknown kill switch = %d, want 404", w.Code)
+	}
+	if w := do(http.MethodPut, path, `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("PUT without state = %d, want 400", w.Code)
+	}
+
+	w := do(http.MethodGet, AdminPath, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s = %d %s", AdminPath, w.Code, w.Body)
+	}
+	var body struct {
+		Data struct {
+			Flags        []Flag           `json:"flags"`
+			KillSwitches []KillSwitchInfo `json:"kill_switches"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Data.Flags) != 2 || body.Data.Flags[0].Key != "checkout" {
+		t.Errorf("flags = %+v, want checkout and search-v2", body.Data.Flags)
+	}
+	if want := []KillSwitchInfo{{Name: "recommendations", Killed: true}}; len(body.Data.KillSwitches) != 1 || body.Data.KillSwitches[0] != want[0] {
+		t.Errorf("kill switches = %+v, want %+v", body.Data.KillSwitches, want)
+	}
+}
@@ -0,0 +1,125 @@
+package zifeature
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ErrFlagNotFound is returned by providers for unknown flags.
+var ErrFlagNotFound = errors.New("zifeature: flag not found")
+
+// Provider evaluates flags.
+type Provider interface {
+	// Evaluate returns the evaluation of the flag key for target, or
+	// ErrFlagNotFound.
+	Evaluate(ctx context.Context, key string, target Target) (Evaluation, error)
+}
+
+// Lister is implemented by the providers listing their flags, e.g. for the
+// admin endpoints of the Client.
+type Lister interface {
+	// Flags returns the flags of the provider, sorted by key.
+	Flags(ctx context.Context) ([]Flag, error)
+}
+
+// Client evaluates flags for the target of the contexts, falling back to the
+// default values of the callers when the evaluations fail.
+type Client struct {
+	provider    Provider
+	evaluations metric.Int64Counter
+
+	mu       sync.Mutex
+	switches map[string]*KillSwitch
+}
+
+// NewClient returns a Client evaluating flags with provider.
+func NewClient(provider Provider) *Client {
+	return &Client{
+		provider: provider,
+		evaluations: revelio.MustInt64Counter(
+			"feature_flag_evaluations_total",
+			"Number of feature flag evaluations, by flag, variant and reason",
+		),
+	}
+}
+
+// Evaluate returns the evaluation of the flag key for the target of ctx, the
+// value being def when it fails or the flag is disabled.
+func (c *Client) Evaluate(ctx context.Context, key string, def any) Evaluation {
+	e, err := c.provider.Evaluate(ctx, key, TargetFromContext(ctx))
+	if err != nil {
+		if !errors.Is(err, ErrFlagNotFound) {
+			zilog.FromContext(ctx).Warn().Err(err).Str("flag", key).Msg("Failed to evaluate feature flag")
+		}
+		e = Evaluation{Reason: ReasonError}
+	}
+	if e.Value == nil {
+		e.Value = def
+	}
+	c.evaluations.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("flag", key),
+		attribute.String("variant", e.Variant),
+		attribute.String("reason", e.Reason),
+	))
+	return e
+}
+
+// Bool returns the value of the bool flag key, or def.
+func (c *Client) Bool(ctx context.Context, key string, def bool) bool {
+	v, ok := c.Evaluate(ctx, key, def).Value.(bool)
+	if !ok {
+		return def
+	}
+	return v
+}
+
+// String returns the value of the string flag key, or def.
+func (c *Client) String(ctx context.Context, key, def string) string {
+	v, ok := c.Evaluate(ctx, key, def).Value.(string)
+	if !ok {
+		return def
+	}
+	return v
+}
+
+// Float64 returns the value of the number flag key, or def.
+func (c *Client) Float64(ctx context.Context, key string, def float64) float64 {
+	switch v := c.Evaluate(ctx, key, def).Value.(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	}
+	return def
+}
+
+type clientKey struct{}
+
+// WithClient returns a copy of ctx carrying c, for Bool and String.
+func WithClient(ctx context.Context, c *Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, c)
+}
+
+// Bool returns the value of the bool flag key for the target of ctx, with
+// the client of ctx, or def without client.
+func Bool(ctx context.Context, key string, def bool) bool {
+	if c, ok := ctx.Value(clientKey{}).(*Client); ok {
+		return c.Bool(ctx, key, def)
+	}
+	return def
+}
+
+// String returns the value of the string flag key for the target of ctx,
+// with the client of ctx, or def without client.
+func String(ctx context.Context, key, def string) string {
+	if c, ok := ctx.Value(clientKey{}).(*Client); ok {
+		return c.String(ctx, key, def)
+	}
+	return def
+}
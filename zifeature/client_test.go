@@ -0,0 +1,102 @@
+package zifeature
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/divikraf/lumos/zin"
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestClient(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	c := NewClient(NewStaticProvider(
+		Flag{Key: "beta", Value: false, Rules: []Rule{{Attribute: AttributeUserID, Values: []string{"u1"}, Value: true}}},
+		Flag{Key: "theme", Value: "dark"},
+		Flag{Key: "ratio", Value: 0.5},
+	))
+	ctx := WithTarget(context.Background(), Target{UserID: "u1"})
+
+	if !c.Bool(ctx, "beta", false) {
+		t.Error("Bool() of a targeted user = false")
+	}
+	if c.Bool(context.Background(), "beta", true) {
+		t.Error("Bool() of an anonymous user = true")
+	}
+	if got := c.String(ctx, "theme", "light"); got != "dark" {
+		t.Errorf("String() = %s, want dark", got)
+	}
+	if got := c.Float64(ctx, "ratio", 0); got != 0.5 {
+		t.Errorf("Float64() = %v, want 0.5", got)
+	}
+	if got := c.String(ctx, "missing", "light"); got != "light" {
+		t.Errorf("String() of a missing flag = %s, want the default", got)
+	}
+	if got := c.String(ctx, "beta", "x"); got != "x" {
+		t.Errorf("String() of a bool flag = %s, want the default", got)
+	}
+
+	reveliotest.AssertCounterValue(t, s, "feature_flag_evaluations_total", 2,
+		attribute.String("flag", "beta"), attribute.String("reason", ReasonTargetingMatch))
+	reveliotest.AssertCounterValue(t, s, "feature_flag_evaluations_total", 1,
+		attribute.String("flag", "missing"), attribute.String("reason", ReasonError))
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c := NewClient(NewStaticProvider(Flag{Key: "beta", Value: false, Rules: []Rule{{Attribute: AttributeTenant, Values: []string{"acme"}, Value: true}}}))
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		claims := &zin.Claims{Subject: "u1", Raw: map[string]any{"org": "acme"}}
+		c.Request = c.Request.WithContext(zin.WithClaims(c.Request.Context(), claims))
+	})
+	r.Use(Middleware(c, ClaimsTarget("org")))
+	r.GET("/", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"beta": Bool(c.Request.Context(), "beta", false)})
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Body.String() != `{"beta":true}` {
+		t.Errorf("response = %s, want the flag of the tenant", w.Body)
+	}
+	if Bool(context.Background(), "beta", true) != true {
+		t.Error("Bool() without client != default")
+	}
+}
+
+func TestOFREPProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body struct {
+			Context map[string]string `json:"context"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/ofrep/v1/evaluate/flags/beta":
+			enabled := body.Context["targetingKey"] == "u1" && body.Context["tenant"] == "acme"
+			json.NewEncoder(w).Encode(map[string]any{"key": "beta", "value": enabled, "reason": ReasonTargetingMatch, "variant": "on"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"key":"missing","errorCode":"FLAG_NOT_FOUND"}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(NewOFREPProvider(OFREPConfig{URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer key"}}, nil))
+	ctx := WithTarget(context.Background(), Target{UserID: "u1", Tenant: "acme"})
+	if e := c.Evaluate(ctx, "beta", false); e.Value != true || e.Variant != "on" {
+		t.Errorf("Evaluate() = %+v", e)
+	}
+	if e := c.Evaluate(ctx, "missing", "def"); e.Value != "def" || e.Reason != ReasonError {
+		t.Errorf("Evaluate() of a missing flag = %+v", e)
+	}
+}
@@ -0,0 +1,84 @@
+package zifeature
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zihttpc"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/fx"
+)
+
+// Provider types
+const (
+	ProviderStatic = "static"
+	ProviderRedis  = "redis"
+	ProviderOFREP  = "ofrep"
+)
+
+// Config configures the Provider of the flags.
+type Config struct {
+	// Provider is ProviderStatic (default), ProviderRedis or ProviderOFREP.
+	Provider string `json:"provider" yaml:"provider" validate:"omitempty,oneof=static redis ofrep"`
+	// Flags are the flags of the static provider.
+	Flags []Flag `json:"flags" yaml:"flags"`
+	// KillSwitches are the names of the kill switches killed at start, see
+	// Client.KillSwitch.
+	KillSwitches []string `json:"kill_switches" yaml:"kill_switches"`
+	// RedisPrefix and RedisCacheTTL configure the Redis provider, see
+	// NewRedisProvider.
+	RedisPrefix   string        `json:"redis_prefix" yaml:"redis_prefix"`
+	RedisCacheTTL time.Duration `json:"redis_cache_ttl" yaml:"redis_cache_ttl"`
+	OFREP         OFREPConfig   `json:"ofrep" yaml:"ofrep"`
+	// HTTP configures the HTTP client of the OFREP provider, see
+	// zihttpc.New.
+	HTTP zihttpc.ClientConfig `json:"http" yaml:"http"`
+}
+
+// featuresConfig is implemented by configurations of the flags.
+type featuresConfig interface {
+	GetFeatures() Config
+}
+
+type NewConfiguredClientParams struct {
+	fx.In
+	Config ziconf.Config
+	// Redis is the client of the Redis provider.
+	Redis redis.Cmdable `optional:"true"`
+}
+
+// NewConfiguredClient returns the Client of the provider of the features
+// config, static without flags when the config has none, with the kill
+// switches of the config killed.
+func NewConfiguredClient(params NewConfiguredClientParams) (*Client, error) {
+	var config Config
+	if c, ok := params.Config.(featuresConfig); ok {
+		config = c.GetFeatures()
+	}
+	provider, err := newProvider(params, config)
+	if err != nil {
+		return nil, err
+	}
+	c := NewClient(provider)
+	for _, name := range config.KillSwitches {
+		c.KillSwitch(name).Set(true)
+	}
+	return c, nil
+}
+
+// newProvider returns the Provider of config.
+func newProvider(params NewConfiguredClientParams, config Config) (Provider, error) {
+	switch config.Provider {
+	case "", ProviderStatic:
+		return NewStaticProvider(config.Flags...), nil
+	case ProviderRedis:
+		if params.Redis == nil {
+			return nil, fmt.Errorf("zifeature: the redis provider needs a redis.Cmdable")
+		}
+		return NewRedisProvider(params.Redis, config.RedisPrefix, config.RedisCacheTTL), nil
+	case ProviderOFREP:
+		return NewOFREPProvider(config.OFREP, zihttpc.New(config.HTTP)), nil
+	}
+	return nil, fmt.Errorf("zifeature: unknown provider %q", config.Provider)
+}
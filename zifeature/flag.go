@@ -0,0 +1,151 @@
+// Package zifeature evaluates feature flags for the targets of the requests,
+// e.g. their user and tenant.
+//
+// Flags are evaluated by a Client with the target of the context, set by
+// Middleware from the claims of the requests, against a Provider: static
+// flags from the config, flags stored in Redis, or a backend implementing the
+// OpenFeature Remote Evaluation Protocol. The evaluations are recorded in the
+// feature_flag_evaluations_total metric by flag, variant and reason.
+//
+// Kill switches, turning features off for every target during incidents,
+// are checked without evaluation, see KillSwitch. The flags and the kill
+// switches are listed, and the kill switches toggled, by the admin endpoints
+// of the Client.
+package zifeature
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"slices"
+	"strconv"
+)
+
+// Target is the subject of the evaluations.
+type Target struct {
+	UserID string
+	Tenant string
+	// Attributes are custom attributes rules can match, e.g. "country".
+	Attributes map[string]string
+}
+
+// attribute returns the value of the attribute name of t, "user_id" and
+// "tenant" included.
+func (t Target) attribute(name string) string {
+	switch name {
+	case AttributeUserID:
+		return t.UserID
+	case AttributeTenant:
+		return t.Tenant
+	}
+	return t.Attributes[name]
+}
+
+// Built-in attributes
+const (
+	AttributeUserID = "user_id"
+	AttributeTenant = "tenant"
+)
+
+type targetKey struct{}
+
+// WithTarget returns a copy of ctx carrying target.
+func WithTarget(ctx context.Context, target Target) context.Context {
+	return context.WithValue(ctx, targetKey{}, target)
+}
+
+// TargetFromContext returns the target of ctx, or an anonymous one.
+func TargetFromContext(ctx context.Context) Target {
+	t, _ := ctx.Value(targetKey{}).(Target)
+	return t
+}
+
+// Evaluation reasons
+const (
+	// ReasonStatic is the reason of the values of flags without rules nor
+	// rollout.
+	ReasonStatic = "STATIC"
+	// ReasonDefault is the reason of the value of a flag matched by no rule
+	// nor rollout.
+	ReasonDefault = "DEFAULT"
+	// ReasonTargetingMatch is the reason of the value of a matching rule.
+	ReasonTargetingMatch = "TARGETING_MATCH"
+	// ReasonSplit is the reason of the value of a rollout.
+	ReasonSplit = "SPLIT"
+	// ReasonDisabled is the reason of the default value of disabled flags.
+	ReasonDisabled = "DISABLED"
+	// ReasonError is the reason of the default value of the evaluations
+	// failing.
+	ReasonError = "ERROR"
+)
+
+// Evaluation is the value of a flag for a target.
+type Evaluation struct {
+	// Value is a bool, a string or a float64.
+	Value   any    `json:"value"`
+	Variant string `json:"variant,omitempty"`
+	Reason  string `json:"reason"`
+}
+
+// Flag is the definition of a flag evaluated locally, e.g. by the static and
+// Redis providers.
+type Flag struct {
+	Key string `json:"key" yaml:"key"`
+	// Disabled flags evaluate to the default value of the callers.
+	Disabled bool `json:"disabled" yaml:"disabled"`
+	// Value is the value when no rule nor rollout matches.
+	Value any `json:"value" yaml:"value"`
+	// Rules are matched in order, the first matching one gives the value.
+	Rules   []Rule   `json:"rules" yaml:"rules"`
+	Rollout *Rollout `json:"rollout" yaml:"rollout"`
+}
+
+// Rule gives Value to the targets whose Attribute, e.g. "tenant", is one of
+// Values.
+type Rule struct {
+	Attribute string   `json:"attribute" yaml:"attribute"`
+	Values    []string `json:"values" yaml:"values"`
+	Value     any      `json:"value" yaml:"value"`
+}
+
+// Rollout gives Value to Percentage percents of the targets, picked by a
+// stable hash of the flag key and their attribute By, so a target keeps its
+// value while the percentage grows.
+type Rollout struct {
+	Percentage float64 `json:"percentage" yaml:"percentage"`
+	// By is the attribute of the targets hashed (default: user_id). Targets
+	// without it are not rolled out.
+	By    string `json:"by" yaml:"by"`
+	Value any    `json:"value" yaml:"value"`
+}
+
+// Evaluate returns the evaluation of f for target.
+func (f Flag) Evaluate(target Target) Evaluation {
+	if f.Disabled {
+		return Evaluation{Reason: ReasonDisabled}
+	}
+	for i, r := range f.Rules {
+		if slices.Contains(r.Values, target.attribute(r.Attribute)) {
+			return Evaluation{Value: r.Value, Variant: "rule-" + strconv.Itoa(i), Reason: ReasonTargetingMatch}
+		}
+	}
+	if r := f.Rollout; r != nil {
+		by := r.By
+		if by == "" {
+			by = AttributeUserID
+		}
+		if id := target.attribute(by); id != "" && bucket(f.Key, id) < r.Percentage {
+			return Evaluation{Value: r.Value, Variant: "rollout", Reason: ReasonSplit}
+		}
+	}
+	if len(f.Rules) == 0 && f.Rollout == nil {
+		return Evaluation{Value: f.Value, Variant: "default", Reason: ReasonStatic}
+	}
+	return Evaluation{Value: f.Value, Variant: "default", Reason: ReasonDefault}
+}
+
+// bucket returns the percentage in [0, 100) of id for the flag key.
+func bucket(key, id string) float64 {
+	sum := sha256.Sum256([]byte(key + "/" + id))
+	return float64(binary.BigEndian.Uint64(sum[:8])%10000) / 100
+}
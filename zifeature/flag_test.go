@@ -0,0 +1,68 @@
+package zifeature
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestFlagEvaluate(t *testing.T) {
+	f := Flag{
+		Key:   "new-checkout",
+		Value: false,
+		Rules: []Rule{
+			{Attribute: AttributeTenant, Values: []string{"acme"}, Value: true},
+			{Attribute: "country", Values: []string{"FR", "DE"}, Value: true},
+		},
+		Rollout: &Rollout{Percentage: 20, Value: true},
+	}
+	tests := []struct {
+		target Target
+		want   Evaluation
+	}{
+		{Target{Tenant: "acme"}, Evaluation{Value: true, Variant: "rule-0", Reason: ReasonTargetingMatch}},
+		{Target{Attributes: map[string]string{"country": "DE"}}, Evaluation{Value: true, Variant: "rule-1", Reason: ReasonTargetingMatch}},
+		{Target{Tenant: "globex"}, Evaluation{Value: false, Variant: "default", Reason: ReasonDefault}},
+	}
+	for _, tt := range tests {
+		if got := f.Evaluate(tt.target); got != tt.want {
+			t.Errorf("Evaluate(%+v) = %+v, want %+v", tt.target, got, tt.want)
+		}
+	}
+
+	if got := (Flag{Key: "banner", Value: "blue"}).Evaluate(Target{}); got.Value != "blue" || got.Reason != ReasonStatic {
+		t.Errorf("Evaluate() of a static flag = %+v", got)
+	}
+	if got := (Flag{Key: "banner", Value: "blue", Disabled: true}).Evaluate(Target{}); got.Value != nil || got.Reason != ReasonDisabled {
+		t.Errorf("Evaluate() of a disabled flag = %+v", got)
+	}
+}
+
+func TestRollout(t *testing.T) {
+	f := Flag{Key: "new-search", Value: false, Rollout: &Rollout{Percentage: 30, Value: true}}
+	rolledOut := map[string]bool{}
+	for i := range 10000 {
+		id := "user-" + strconv.Itoa(i)
+		rolledOut[id] = f.Evaluate(Target{UserID: id}).Value == true
+	}
+	n := 0
+	for _, in := range rolledOut {
+		if in {
+			n++
+		}
+	}
+	if n < 2800 || n > 3200 {
+		t.Errorf("%d targets of 10000 rolled out at 30%%", n)
+	}
+
+	// Growing the percentage keeps the targets rolled out
+	f.Rollout.Percentage = 60
+	for id, in := range rolledOut {
+		if in && f.Evaluate(Target{UserID: id}).Value != true {
+			t.Fatalf("%s no longer rolled out at 60%%", id)
+		}
+	}
+
+	if got := f.Evaluate(Target{}); got.Reason != ReasonDefault {
+		t.Errorf("Evaluate() of an anonymous target = %+v, want the default value", got)
+	}
+}
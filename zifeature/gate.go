@@ -0,0 +1,66 @@
+package zifeature
+
+import (
+	"strconv"
+
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zin"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AttributeRequestID is the attribute of the targets of RequestTarget.
+// Flags rolled out by it gate a share of the traffic.
+const AttributeRequestID = "request_id"
+
+// RequestTarget targets the requests by their ID, see
+// zin.RequestIDMiddleware, e.g. to gate the middlewares running before the
+// authentication for a share of the traffic.
+func RequestTarget(c *gin.Context) Target {
+	return Target{Attributes: map[string]string{
+		AttributeRequestID: zin.RequestIDFromContext(c.Request.Context()),
+	}}
+}
+
+// Gate runs middleware for the requests for which the bool flag is true,
+// false by default, and skips it for the others, e.g. to roll out a new
+// middleware incrementally. The flag is evaluated for the target of the
+// request, with target, or the target set by Middleware when nil. The
+// variant is added to the span, in a feature_flag event, and to the request
+// logger, as feature_flag.<flag>.
+func Gate(client *Client, flag string, target TargetFunc, middleware gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		evalCtx := ctx
+		if target != nil {
+			evalCtx = WithTarget(ctx, target(c))
+		}
+		e := client.Evaluate(evalCtx, flag, false)
+		enabled, _ := e.Value.(bool)
+
+		trace.SpanFromContext(ctx).AddEvent("feature_flag", trace.WithAttributes(
+			attribute.String("feature_flag.key", flag),
+			attribute.String("feature_flag.variant", e.Variant),
+			attribute.String("feature_flag.reason", e.Reason),
+			attribute.Bool("feature_flag.enabled", enabled),
+		))
+		logger := zilog.FromContext(ctx).With().Str("feature_flag."+flag, variantOf(e, enabled)).Logger()
+		c.Request = c.Request.WithContext(logger.WithContext(ctx))
+
+		if enabled {
+			middleware(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// variantOf returns the variant of e, or whether the flag is enabled when
+// the provider gives none.
+func variantOf(e Evaluation, enabled bool) string {
+	if e.Variant != "" {
+		return e.Variant
+	}
+	return strconv.FormatBool(enabled)
+}
@@ -0,0 +1,62 @@
+package zifeature
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zin"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+func TestGate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c := NewClient(NewStaticProvider(Flag{Key: "new-auth", Value: false, Rules: []Rule{{Attribute: AttributeRequestID, Values: []string{"req-1"}, Value: true}}}))
+	var logs bytes.Buffer
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		ctx := zin.WithRequestID(c.Request.Context(), c.GetHeader(zin.RequestIDHeader))
+		logger := zerolog.New(&logs)
+		c.Request = c.Request.WithContext(logger.WithContext(ctx))
+	})
+	r.Use(Gate(c, "new-auth", RequestTarget, func(c *gin.Context) {
+		c.AbortWithStatus(http.StatusUnauthorized)
+	}))
+	r.GET("/", func(c *gin.Context) {
+		zilog.FromContext(c.Request.Context()).Info().Msg("handled")
+		c.Status(http.StatusOK)
+	})
+
+	for id, want := range map[string]int{"req-1": http.StatusUnauthorized, "req-2": http.StatusOK} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(zin.RequestIDHeader, id)
+		r.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("request %s = %d, want %d", id, w.Code, want)
+		}
+	}
+	if !strings.Contains(logs.String(), `"feature_flag.new-auth":"default"`) {
+		t.Errorf("logs = %s, want the variant", logs.String())
+	}
+}
+
+func TestGateDefault(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c := NewClient(NewStaticProvider())
+	r := gin.New()
+	r.Use(Gate(c, "missing", nil, func(c *gin.Context) {
+		c.AbortWithStatus(http.StatusUnauthorized)
+	}))
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want the middleware skipped for an unknown flag", w.Code)
+	}
+}
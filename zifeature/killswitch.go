@@ -0,0 +1,72 @@
+package zifeature
+
+import (
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// KillSwitch is a global flag turning a feature off for every target, e.g.
+// a dependency during an incident. It isn't evaluated by the provider: its
+// state is a single atomic value, so that checking it on hot paths costs one
+// load and no allocation.
+//
+// Kill switches are killed at start by the config, see Config.KillSwitches,
+// and toggled with the admin endpoints of the Client, on the instance
+// serving the request.
+type KillSwitch struct {
+	name   string
+	killed atomic.Bool
+}
+
+// Name returns the name of k.
+func (k *KillSwitch) Name() string {
+	return k.name
+}
+
+// Killed reports whether the feature of k is turned off.
+func (k *KillSwitch) Killed() bool {
+	return k.killed.Load()
+}
+
+// Set kills or revives the feature of k.
+func (k *KillSwitch) Set(killed bool) {
+	k.killed.Store(killed)
+}
+
+// KillSwitch returns the kill switch name of c, registered on the first call,
+// alive unless killed by the config. Callers keep it, e.g. in a field, rather
+// than looking it up on every check.
+func (c *Client) KillSwitch(name string) *KillSwitch {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k, ok := c.switches[name]
+	if !ok {
+		k = &KillSwitch{name: name}
+		if c.switches == nil {
+			c.switches = map[string]*KillSwitch{}
+		}
+		c.switches[name] = k
+	}
+	return k
+}
+
+// KillSwitches returns the kill switches of c, sorted by name.
+func (c *Client) KillSwitches() []*KillSwitch {
+	c.mu.Lock()
+	switches := make([]*KillSwitch, 0, len(c.switches))
+	for _, k := range c.switches {
+		switches = append(switches, k)
+	}
+	c.mu.Unlock()
+	slices.SortFunc(switches, func(a, b *KillSwitch) int { return strings.Compare(a.name, b.name) })
+	return switches
+}
+
+// lookupKillSwitch returns the registered kill switch name of c.
+func (c *Client) lookupKillSwitch(name string) (*KillSwitch, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k, ok := c.switches[name]
+	return k, ok
+}
@@ -0,0 +1,24 @@
+package zifeature
+
+import (
+	"testing"
+)
+
+func TestKillSwitch(t *testing.T) {
+	c := NewClient(NewStaticProvider())
+	k := c.KillSwitch("payments")
+	if k.Killed() {
+		t.Error("kill switch killed at registration")
+	}
+	if c.KillSwitch("payments") != k {
+		t.Error("KillSwitch returned another switch for the same name")
+	}
+	k.Set(true)
+	if !k.Killed() {
+		t.Error("kill switch alive once killed")
+	}
+
+	if allocs := testing.AllocsPerRun(1000, func() { _ = k.Killed() }); allocs != 0 {
+		t.Errorf("Killed() allocates %v times, want 0", allocs)
+	}
+}
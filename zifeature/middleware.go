@@ -0,0 +1,37 @@
+package zifeature
+
+import (
+	"github.com/divikraf/lumos/zin"
+	"github.com/gin-gonic/gin"
+)
+
+// TargetFunc returns the target of a request.
+type TargetFunc func(c *gin.Context) Target
+
+// ClaimsTarget returns a TargetFunc targeting the subject of the claims of
+// the authenticated request, and its tenant from the claim tenantClaim when
+// set. Unauthenticated requests are anonymous.
+func ClaimsTarget(tenantClaim string) TargetFunc {
+	return func(c *gin.Context) Target {
+		claims, ok := zin.ClaimsFromContext(c.Request.Context())
+		if !ok {
+			return Target{}
+		}
+		t := Target{UserID: claims.Subject}
+		if tenantClaim != "" {
+			t.Tenant = claims.String(tenantClaim)
+		}
+		return t
+	}
+}
+
+// Middleware exposes the flags to the handlers: it sets the target of the
+// requests, with target, and client in their context, for Bool and String.
+// It must run after the authentication middleware.
+func Middleware(client *Client, target TargetFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := WithTarget(c.Request.Context(), target(c))
+		c.Request = c.Request.WithContext(WithClient(ctx, client))
+		c.Next()
+	}
+}
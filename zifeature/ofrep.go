@@ -0,0 +1,90 @@
+package zifeature
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OFREPConfig configures an OFREPProvider.
+type OFREPConfig struct {
+	// URL is the base URL of the service, e.g. https://flags.example.com.
+	URL string `json:"url" yaml:"url" validate:"required"`
+	// Headers are sent with the evaluations, e.g. an Authorization header.
+	Headers map[string]string `json:"headers" yaml:"headers"`
+}
+
+// OFREPProvider evaluates flags remotely, with a backend implementing the
+// OpenFeature Remote Evaluation Protocol, e.g. flagd or GO Feature Flag.
+type OFREPProvider struct {
+	config OFREPConfig
+	client *http.Client
+}
+
+// NewOFREPProvider returns an OFREPProvider calling the service of config
+// with client, or http.DefaultClient if nil.
+func NewOFREPProvider(config OFREPConfig, client *http.Client) *OFREPProvider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	config.URL = strings.TrimRight(config.URL, "/")
+	return &OFREPProvider{config: config, client: client}
+}
+
+type ofrepResponse struct {
+	Value        any    `json:"value"`
+	Variant      string `json:"variant"`
+	Reason       string `json:"reason"`
+	ErrorCode    string `json:"errorCode"`
+	ErrorDetails string `json:"errorDetails"`
+}
+
+func (p *OFREPProvider) Evaluate(ctx context.Context, key string, target Target) (Evaluation, error) {
+	evalCtx := map[string]string{}
+	for k, v := range target.Attributes {
+		evalCtx[k] = v
+	}
+	if target.UserID != "" {
+		evalCtx["targetingKey"] = target.UserID
+	}
+	if target.Tenant != "" {
+		evalCtx[AttributeTenant] = target.Tenant
+	}
+	body, err := json.Marshal(map[string]any{"context": evalCtx})
+	if err != nil {
+		return Evaluation{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		p.config.URL+"/ofrep/v1/evaluate/flags/"+url.PathEscape(key), bytes.NewReader(body))
+	if err != nil {
+		return Evaluation{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range p.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return Evaluation{}, err
+	}
+	defer resp.Body.Close()
+	var r ofrepResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil && resp.StatusCode == http.StatusOK {
+		return Evaluation{}, fmt.Errorf("zifeature: invalid evaluation of %s: %w", key, err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound || r.ErrorCode == "FLAG_NOT_FOUND":
+		return Evaluation{}, fmt.Errorf("%w: %s", ErrFlagNotFound, key)
+	case resp.StatusCode != http.StatusOK || r.ErrorCode != "":
+		return Evaluation{}, fmt.Errorf("zifeature: failed to evaluate %s: %s %s %s", key, resp.Status, r.ErrorCode, r.ErrorDetails)
+	}
+	if r.Reason == ReasonDisabled {
+		r.Value = nil
+	}
+	return Evaluation{Value: r.Value, Variant: r.Variant, Reason: r.Reason}, nil
+}
@@ -0,0 +1,135 @@
+package zifeature
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisProvider evaluates flags stored in Redis as JSON Flag values, under
+// keys prefixed with a prefix, so they can be changed without deploying. The
+// flags are cached for a TTL, bounding the delay of the changes.
+type RedisProvider struct {
+	client redis.Cmdable
+	prefix string
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedFlag
+}
+
+type cachedFlag struct {
+	flag    Flag
+	found   bool
+	expires time.Time
+}
+
+// NewRedisProvider returns a RedisProvider reading the flags under keys
+// prefixed with prefix, e.g. "features:", cached for ttl (default: 10s).
+func NewRedisProvider(client redis.Cmdable, prefix string, ttl time.Duration) *RedisProvider {
+	if ttl <= 0 {
+		ttl = 10 * time.Second
+	}
+	return &RedisProvider{
+		client: client,
+		prefix: prefix,
+		ttl:    ttl,
+		now:    time.Now,
+		cache:  map[string]cachedFlag{},
+	}
+}
+
+func (p *RedisProvider) Evaluate(ctx context.Context, key string, target Target) (Evaluation, error) {
+	f, found, err := p.flag(ctx, key)
+	if err != nil {
+		return Evaluation{}, err
+	}
+	if !found {
+		return Evaluation{}, fmt.Errorf("%w: %s", ErrFlagNotFound, key)
+	}
+	return f.Evaluate(target), nil
+}
+
+// flag returns the flag key, from the cache while fresh. A stale flag is
+// served when Redis fails.
+func (p *RedisProvider) flag(ctx context.Context, key string) (Flag, bool, error) {
+	p.mu.Lock()
+	cached, ok := p.cache[key]
+	p.mu.Unlock()
+	if ok && p.now().Before(cached.expires) {
+		return cached.flag, cached.found, nil
+	}
+
+	f, found, err := p.load(ctx, key)
+	if err != nil {
+		if ok {
+			return cached.flag, cached.found, nil
+		}
+		return Flag{}, false, err
+	}
+	p.mu.Lock()
+	p.cache[key] = cachedFlag{flag: f, found: found, expires: p.now().Add(p.ttl)}
+	p.mu.Unlock()
+	return f, found, nil
+}
+
+func (p *RedisProvider) load(ctx context.Context, key string) (Flag, bool, error) {
+	b, err := p.client.Get(ctx, p.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return Flag{}, false, nil
+	}
+	if err != nil {
+		return Flag{}, false, err
+	}
+	var f Flag
+	if err := json.Unmarshal(b, &f); err != nil {
+		return Flag{}, false, fmt.Errorf("zifeature: invalid flag %s: %w", key, err)
+	}
+	f.Key = key
+	return f, true, nil
+}
+
+// Flags returns the flags stored under the prefix of p, sorted by key,
+// read from Redis rather than from the cache.
+func (p *RedisProvider) Flags(ctx context.Context) ([]Flag, error) {
+	var flags []Flag
+	iter := p.client.Scan(ctx, 0, p.prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		f, found, err := p.load(ctx, strings.TrimPrefix(iter.Val(), p.prefix))
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			flags = append(flags, f)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	slices.SortFunc(flags, func(a, b Flag) int { return strings.Compare(a.Key, b.Key) })
+	return flags, nil
+}
+
+// Set stores f, for the admin tools. The caches of the other providers
+// expire within their TTL.
+func (p *RedisProvider) Set(ctx context.Context, f Flag) error {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if err := p.client.Set(ctx, p.prefix+f.Key, b, 0).Err(); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	delete(p.cache, f.Key)
+	p.mu.Unlock()
+	return nil
+}
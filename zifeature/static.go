@@ -0,0 +1,40 @@
+package zifeature
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// StaticProvider evaluates flags defined once, e.g. in the config.
+type StaticProvider struct {
+	flags map[string]Flag
+}
+
+// NewStaticProvider returns a StaticProvider of flags.
+func NewStaticProvider(flags ...Flag) *StaticProvider {
+	p := &StaticProvider{flags: make(map[string]Flag, len(flags))}
+	for _, f := range flags {
+		p.flags[f.Key] = f
+	}
+	return p
+}
+
+func (p *StaticProvider) Evaluate(ctx context.Context, key string, target Target) (Evaluation, error) {
+	f, ok := p.flags[key]
+	if !ok {
+		return Evaluation{}, fmt.Errorf("%w: %s", ErrFlagNotFound, key)
+	}
+	return f.Evaluate(target), nil
+}
+
+// Flags returns the flags of p, sorted by key.
+func (p *StaticProvider) Flags(ctx context.Context) ([]Flag, error) {
+	flags := make([]Flag, 0, len(p.flags))
+	for _, f := range p.flags {
+		flags = append(flags, f)
+	}
+	slices.SortFunc(flags, func(a, b Flag) int { return strings.Compare(a.Key, b.Key) })
+	return flags, nil
+}
@@ -0,0 +1,41 @@
+package zifeaturefx
+
+import (
+	"github.com/divikraf/lumos/zifeature"
+	"github.com/divikraf/lumos/zin"
+	"github.com/divikraf/lumos/zin/zinfx"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/fx"
+)
+
+// Provider provides the *zifeature.Client configured by the features config.
+// The Redis provider needs a redis.Cmdable to be provided.
+var Provider = fx.Provide(zifeature.NewConfiguredClient)
+
+// AddGatedMiddleware is zinfx.AddMiddleware for a middleware gated by the
+// bool flag, see zifeature.Gate. As the middlewares of the main router run
+// before the authentication, target should not depend on it, e.g.
+// zifeature.RequestTarget.
+func AddGatedMiddleware(flag string, target zifeature.TargetFunc, constructor any) fx.Option {
+	return fx.Module("zifeature.gate."+flag,
+		fx.Provide(fx.Private, fx.Annotate(constructor, fx.ResultTags(`name:"gated"`))),
+		zinfx.AddMiddleware(func(params gatedParams) gin.HandlerFunc {
+			return zifeature.Gate(params.Client, flag, target, params.Middleware)
+		}),
+	)
+}
+
+type gatedParams struct {
+	fx.In
+	Client     *zifeature.Client
+	Middleware gin.HandlerFunc `name:"gated"`
+}
+
+// AdminRoutes mounts the flags admin endpoints on the additional server
+// name, see zinfx.Server, or on the main router when empty.
+func AdminRoutes(server string) fx.Option {
+	return fx.Provide(fx.Annotate(
+		func(c *zifeature.Client) zin.RouteRegistrar { return c },
+		fx.ResultTags(zinfx.RoutesGroup(server)),
+	))
+}
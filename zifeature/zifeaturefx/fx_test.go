@@ -0,0 +1,55 @@
+package zifeaturefx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zifeature"
+	"github.com/divikraf/lumos/zin/zinfx"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+type testConfig struct{}
+
+func (testConfig) GetService() ziconf.ServiceConfig { return ziconf.ServiceConfig{Name: "test"} }
+func (testConfig) GetEnvironment() string           { return "test" }
+func (testConfig) GetLog() ziconf.LogConfig         { return ziconf.LogConfig{} }
+func (testConfig) GetHttpPort() string              { return ":0" }
+func (testConfig) GetTelemetry() observe.Config     { return observe.Config{} }
+
+// header returns a constructor of a middleware setting the header name.
+func header(name string) func() gin.HandlerFunc {
+	return func() gin.HandlerFunc {
+		return func(c *gin.Context) { c.Header(name, "1") }
+	}
+}
+
+func TestAddGatedMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var router *gin.Engine
+	app := fxtest.New(t,
+		fx.Supply(fx.Annotate(testConfig{}, fx.As(new(ziconf.Config)))),
+		fx.Supply(zifeature.NewClient(zifeature.NewStaticProvider(
+			zifeature.Flag{Key: "on", Value: true},
+			zifeature.Flag{Key: "off", Value: false},
+		))),
+		zinfx.Provider,
+		AddGatedMiddleware("on", nil, header("X-On")),
+		AddGatedMiddleware("off", nil, header("X-Off")),
+		zinfx.AddRoutes(),
+		fx.Populate(&router),
+	)
+	app.RequireStart().RequireStop()
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Header().Get("X-On") != "1" || w.Header().Get("X-Off") != "" {
+		t.Errorf("headers = %v, want the middleware of the enabled flag only", w.Header())
+	}
+}
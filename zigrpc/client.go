@@ -0,0 +1,288 @@
+package zigrpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/fx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+)
+
+// ClientConfig configures the connection to a gRPC target.
+type ClientConfig struct {
+	// Target is the target of the connection, e.g. "dns:///orders:9090".
+	Target string          `json:"target" yaml:"target"`
+	TLS    ClientTLSConfig `json:"tls" yaml:"tls"`
+	// Keepalive pings idle connections, so broken ones are detected.
+	Keepalive KeepaliveConfig `json:"keepalive" yaml:"keepalive"`
+	// LoadBalancing is the load-balancing policy, e.g. "round_robin"
+	// (default: "pick_first").
+	LoadBalancing string `json:"load_balancing" yaml:"load_balancing"`
+	// Retry and Hedging are exclusive policies applied to every method.
+	Retry   *RetryPolicy   `json:"retry" yaml:"retry"`
+	Hedging *HedgingPolicy `json:"hedging" yaml:"hedging"`
+}
+
+// ClientTLSConfig configures TLS, disabled by default.
+type ClientTLSConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// CAFile holds the PEM encoded CAs verifying the server, the system
+	// ones by default.
+	CAFile string `json:"ca_file" yaml:"ca_file"`
+	// CertFile and KeyFile are the client certificate, for mTLS.
+	CertFile   string `json:"cert_file" yaml:"cert_file"`
+	KeyFile    string `json:"key_file" yaml:"key_file"`
+	ServerName string `json:"server_name" yaml:"server_name"`
+}
+
+// KeepaliveConfig configures the keepalive pings of a connection, disabled
+// when Time is zero.
+type KeepaliveConfig struct {
+	// Time is the idle duration after which the server is pinged.
+	Time time.Duration `json:"time" yaml:"time"`
+	// Timeout is the maximum duration to wait for a ping ack (default: 20s).
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+	// PermitWithoutStream pings even without active calls.
+	PermitWithoutStream bool `json:"permit_without_stream" yaml:"permit_without_stream"`
+}
+
+// RetryPolicy retries failed calls, see the gRPC retry design.
+type RetryPolicy struct {
+	MaxAttempts       int           `json:"max_attempts" yaml:"max_attempts"`
+	InitialBackoff    time.Duration `json:"initial_backoff" yaml:"initial_backoff"`
+	MaxBackoff        time.Duration `json:"max_backoff" yaml:"max_backoff"`
+	BackoffMultiplier float64       `json:"backoff_multiplier" yaml:"backoff_multiplier"`
+	// RetryableStatusCodes are the codes retried, e.g. "UNAVAILABLE".
+	RetryableStatusCodes []string `json:"retryable_status_codes" yaml:"retryable_status_codes"`
+}
+
+// HedgingPolicy sends the same call to several backends, the first response
+// winning, for latency-sensitive idempotent methods.
+type HedgingPolicy struct {
+	MaxAttempts  int           `json:"max_attempts" yaml:"max_attempts"`
+	HedgingDelay time.Duration `json:"hedging_delay" yaml:"hedging_delay"`
+	// NonFatalStatusCodes are the codes not canceling the other attempts.
+	NonFatalStatusCodes []string `json:"non_fatal_status_codes" yaml:"non_fatal_status_codes"`
+}
+
+// clientsConfig is implemented by configs of services calling gRPC targets,
+// by name.
+type clientsConfig interface {
+	GetGrpcClients() map[string]ClientConfig
+}
+
+// serviceConfig returns the JSON service config of c.
+func (c ClientConfig) serviceConfig() (string, error) {
+	if c.Retry != nil && c.Hedging != nil {
+		return "", errors.New("zigrpc: retry and hedging policies are exclusive")
+	}
+	config := map[string]any{}
+	if c.LoadBalancing != "" {
+		config["loadBalancingConfig"] = []map[string]any{{c.LoadBalancing: map[string]any{}}}
+	}
+	method := map[string]any{"name": []map[string]any{{}}}
+	switch {
+	case c.Retry != nil:
+		method["retryPolicy"] = map[string]any{
+			"maxAttempts":          c.Retry.MaxAttempts,
+			"initialBackoff":       protoDuration(c.Retry.InitialBackoff),
+			"maxBackoff":           protoDuration(c.Retry.MaxBackoff),
+			"backoffMultiplier":    c.Retry.BackoffMultiplier,
+			"retryableStatusCodes": c.Retry.RetryableStatusCodes,
+		}
+		config["methodConfig"] = []any{method}
+	case c.Hedging != nil:
+		method["hedgingPolicy"] = map[string]any{
+			"maxAttempts":         c.Hedging.MaxAttempts,
+			"hedgingDelay":        protoDuration(c.Hedging.HedgingDelay),
+			"nonFatalStatusCodes": c.Hedging.NonFatalStatusCodes,
+		}
+		config["methodConfig"] = []any{method}
+	}
+	b, err := json.Marshal(config)
+	return string(b), err
+}
+
+// protoDuration formats d as a JSON protobuf duration, e.g. "0.100s".
+func protoDuration(d time.Duration) string {
+	return fmt.Sprintf("%.9fs", d.Seconds())
+}
+
+func (c ClientTLSConfig) credentials() (credentials.TransportCredentials, error) {
+	if !c.Enabled {
+		return insecure.NewCredentials(), nil
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: c.ServerName}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("zigrpc: no certificate in %s", c.CAFile)
+		}
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(config), nil
+}
+
+// DialOptions returns the options of a connection configured by c, with the
+// otelgrpc stats handler tracing the calls, and the metrics and logging
+// interceptors.
+func (c ClientConfig) DialOptions() ([]grpc.DialOption, error) {
+	creds, err := c.TLS.credentials()
+	if err != nil {
+		return nil, err
+	}
+	serviceConfig, err := c.serviceConfig()
+	if err != nil {
+		return nil, err
+	}
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultServiceConfig(serviceConfig),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(UnaryClientInterceptor(), LogUnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(StreamClientInterceptor()),
+	}
+	if c.Keepalive.Time > 0 {
+		timeout := c.Keepalive.Timeout
+		if timeout <= 0 {
+			timeout = 20 * time.Second
+		}
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                c.Keepalive.Time,
+			Timeout:             timeout,
+			PermitWithoutStream: c.Keepalive.PermitWithoutStream,
+		}))
+	}
+	return opts, nil
+}
+
+// UnaryClientInterceptor records the duration of unary calls in
+// grpc_client_duration_ms{method, code, class}, classified by their error,
+// see ClassifyError.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	duration := revelio.MustDuration(
+		"grpc_client_duration_ms",
+		"Duration of gRPC unary calls made",
+		revelio.WithUnit("ms"),
+	)
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		duration.Record(ctx, time.Since(start),
+			attribute.String("method", method),
+			attribute.String("code", errorCode(err).String()),
+			attribute.String("class", ClassifyError(err)),
+		)
+		return err
+	}
+}
+
+// LogUnaryClientInterceptor logs the failed unary calls with the logger of
+// their context, with their status code and duration.
+func LogUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if class := ClassifyError(err); class != ClassOK && class != ClassCanceled {
+			zilog.FromContext(ctx).Warn().Err(err).
+				Str("grpc.method", method).
+				Str("grpc.target", cc.Target()).
+				Str("grpc.code", errorCode(err).String()).
+				Dur("grpc.dur", time.Since(start)).
+				Msg("gRPC call failed")
+		}
+		return err
+	}
+}
+
+// Clients creates the connections to the gRPC targets of the config, by
+// name, once, and closes them with the lifecycle.
+type Clients struct {
+	configs map[string]ClientConfig
+	logger  *zerolog.Logger
+
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn
+}
+
+type NewClientsParams struct {
+	fx.In
+	LC     fx.Lifecycle
+	Config ziconf.Config
+	Logger *zerolog.Logger
+}
+
+// NewClients returns the Clients of the targets of the config.
+func NewClients(params NewClientsParams) *Clients {
+	c := &Clients{logger: params.Logger, conns: map[string]*grpc.ClientConn{}}
+	if cc, ok := params.Config.(clientsConfig); ok {
+		c.configs = cc.GetGrpcClients()
+	}
+	params.LC.Append(fx.StopHook(c.Close))
+	return c
+}
+
+// Conn returns the connection to the target name, created on first use.
+// Connections are lazy: the target is resolved and connected on the first
+// call, so a target down doesn't prevent the service from starting.
+func (c *Clients) Conn(name string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if conn, ok := c.conns[name]; ok {
+		return conn, nil
+	}
+	config, ok := c.configs[name]
+	if !ok || config.Target == "" {
+		return nil, fmt.Errorf("zigrpc: no client configured for the %q target", name)
+	}
+	dialOpts, err := config.DialOptions()
+	if err != nil {
+		return nil, fmt.Errorf("zigrpc: client %q: %w", name, err)
+	}
+	conn, err := grpc.NewClient(config.Target, append(dialOpts, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("zigrpc: client %q: %w", name, err)
+	}
+	c.conns[name] = conn
+	return conn, nil
+}
+
+// Close closes the connections.
+func (c *Clients) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var errs []error
+	for name, conn := range c.conns {
+		if err := conn.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("zigrpc: closing client %q: %w", name, err))
+		}
+		delete(c.conns, name)
+	}
+	return errors.Join(errs...)
+}
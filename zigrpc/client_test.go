@@ -0,0 +1,111 @@
+package zigrpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/rs/zerolog"
+	"go.uber.org/fx/fxtest"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type clientsTestConfig struct {
+	testConfig
+	clients map[string]ClientConfig
+}
+
+func (c clientsTestConfig) GetGrpcClients() map[string]ClientConfig { return c.clients }
+
+func TestServiceConfig(t *testing.T) {
+	c := ClientConfig{
+		LoadBalancing: "round_robin",
+		Retry: &RetryPolicy{
+			MaxAttempts:          3,
+			InitialBackoff:       100 * time.Millisecond,
+			MaxBackoff:           time.Second,
+			BackoffMultiplier:    2,
+			RetryableStatusCodes: []string{"UNAVAILABLE"},
+		},
+	}
+	got, err := c.serviceConfig()
+	if err != nil {
+		t.Fatalf("Failed to build the service config: %v", err)
+	}
+	var config struct {
+		LoadBalancingConfig []map[string]any `json:"loadBalancingConfig"`
+		MethodConfig        []struct {
+			RetryPolicy struct {
+				MaxAttempts    int    `json:"maxAttempts"`
+				InitialBackoff string `json:"initialBackoff"`
+			} `json:"retryPolicy"`
+		} `json:"methodConfig"`
+	}
+	if err := json.Unmarshal([]byte(got), &config); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := config.LoadBalancingConfig[0]["round_robin"]; !ok {
+		t.Errorf("Load balancing = %v, want round_robin", config.LoadBalancingConfig)
+	}
+	if p := config.MethodConfig[0].RetryPolicy; p.MaxAttempts != 3 || p.InitialBackoff != "0.100000000s" {
+		t.Errorf("Retry policy = %+v", p)
+	}
+
+	c.Hedging = &HedgingPolicy{MaxAttempts: 2}
+	if _, err := c.serviceConfig(); err == nil {
+		t.Error("Retry and hedging policies = nil error, want an error")
+	}
+}
+
+func TestClients(t *testing.T) {
+	logger := zerolog.Nop()
+	srv := NewServer(NewServerParams{
+		Config: testConfig{},
+		Logger: &logger,
+		Services: []ServiceRegistrar{ServiceRegistrarFunc(func(s grpc.ServiceRegistrar) {
+			s.RegisterService(&echoDesc, nil)
+		})},
+	})
+	lc := fxtest.NewLifecycle(t)
+	StartServer(StartServerParams{LC: lc, Logger: &logger, Server: srv})
+	lc.RequireStart()
+	defer lc.RequireStop()
+
+	clientsLC := fxtest.NewLifecycle(t)
+	var config ziconf.Config = clientsTestConfig{clients: map[string]ClientConfig{
+		"echo": {
+			Target:        "passthrough:///" + srv.Addr().String(),
+			LoadBalancing: "round_robin",
+			Retry:         &RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, BackoffMultiplier: 1, RetryableStatusCodes: []string{"UNAVAILABLE"}},
+		},
+	}}
+	clients := NewClients(NewClientsParams{LC: clientsLC, Config: config, Logger: &logger})
+	clientsLC.RequireStart()
+
+	if _, err := clients.Conn("missing"); err == nil {
+		t.Error("Conn of a missing target = nil error, want an error")
+	}
+	conn, err := clients.Conn("echo")
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if again, _ := clients.Conn("echo"); again != conn {
+		t.Error("Conn returned a new connection, want the same")
+	}
+
+	resp := &wrapperspb.StringValue{}
+	if err := conn.Invoke(context.Background(), "/test.Echo/Echo", wrapperspb.String("hello"), resp); err != nil {
+		t.Fatalf("Failed to echo: %v", err)
+	}
+	if resp.Value != "hello" {
+		t.Errorf("Echo = %q, want hello", resp.Value)
+	}
+
+	clientsLC.RequireStop()
+	if err := conn.Invoke(context.Background(), "/test.Echo/Echo", wrapperspb.String("hello"), resp); err == nil {
+		t.Error("Call on a closed connection = nil error, want an error")
+	}
+}
@@ -0,0 +1,157 @@
+package zigrpc
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zilog/hook"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor records the duration of unary calls in
+// grpc_server_duration_ms{method, code, class}, classified by their error,
+// see ClassifyError, and adds the class to the call span started by the
+// otelgrpc stats handler of the server.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	duration := revelio.MustDuration(
+		"grpc_server_duration_ms",
+		"Duration of gRPC unary calls served",
+		revelio.WithUnit("ms"),
+	)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		class, code := ClassifyError(err), errorCode(err)
+		duration.Record(ctx, time.Since(start),
+			attribute.String("method", info.FullMethod),
+			attribute.String("code", code.String()),
+			attribute.String("class", class),
+		)
+		recordClass(trace.SpanFromContext(ctx), class, err)
+		return resp, err
+	}
+}
+
+// recordClass adds the error class of a call served to its span, and records
+// the error of the server.
+func recordClass(span trace.Span, class string, err error) {
+	span.SetAttributes(attribute.String("rpc.grpc.error_class", class))
+	if class == ClassServer || class == ClassTimeout {
+		observe.RecordError(span, err)
+	}
+}
+
+// LogUnaryServerInterceptor adds a logger derived from logger to the context
+// of unary calls, carrying the method and the trace, and logs every call
+// with its status code and duration: server errors as errors, client errors
+// as warnings.
+func LogUnaryServerInterceptor(logger *zerolog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx = logContext(ctx, logger, info.FullMethod)
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logCall(ctx, info.FullMethod, time.Since(start), err)
+		return resp, err
+	}
+}
+
+// LogStreamServerInterceptor is the stream counterpart of
+// LogUnaryServerInterceptor, logging streams once ended.
+func LogStreamServerInterceptor(logger *zerolog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := logContext(ss.Context(), logger, info.FullMethod)
+		start := time.Now()
+		err := handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+		logCall(ctx, info.FullMethod, time.Since(start), err)
+		return err
+	}
+}
+
+func logContext(ctx context.Context, logger *zerolog.Logger, method string) context.Context {
+	l := logger.With().Str("grpc.method", method).Logger()
+	ctx, _ = zilog.NewContext(l.WithContext(ctx), hook.NewOpenTelemetryHook())
+	return ctx
+}
+
+func logCall(ctx context.Context, method string, d time.Duration, err error) {
+	logger := zilog.FromContext(ctx)
+	event := logger.Info()
+	switch ClassifyError(err) {
+	case ClassServer, ClassTimeout:
+		event = logger.Error().Err(err)
+	case ClassClient:
+		event = logger.Warn().Err(err)
+	}
+	event.Str("grpc.code", errorCode(err).String()).
+		Dur("grpc.dur", d).
+		Msg(method)
+}
+
+// RecoveryUnaryServerInterceptor recovers the panics of unary handlers: the
+// panic is logged with its stack by the call logger, recorded on the call
+// span, counted in grpc_server_panics_total by method, and answered with
+// codes.Internal.
+func RecoveryUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	panics := newPanicCounter()
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ctx, panics, info.FullMethod, r)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStreamServerInterceptor is the stream counterpart of
+// RecoveryUnaryServerInterceptor.
+func RecoveryStreamServerInterceptor() grpc.StreamServerInterceptor {
+	panics := newPanicCounter()
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ss.Context(), panics, info.FullMethod, r)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func newPanicCounter() metric.Int64Counter {
+	return revelio.MustInt64Counter(
+		"grpc_server_panics_total",
+		"Number of panics recovered while serving gRPC calls, by method",
+	)
+}
+
+func recovered(ctx context.Context, panics metric.Int64Counter, method string, r any) error {
+	observe.RecordPanic(trace.SpanFromContext(ctx), r)
+	panics.Add(ctx, 1, metric.WithAttributes(attribute.String("method", method)))
+	zilog.FromContext(ctx).Error().
+		Str("panic", fmt.Sprint(r)).
+		Str("grpc.method", method).
+		Str("stack", string(debug.Stack())).
+		Msg("recovered from panic")
+	return status.Error(grpccodes.Internal, "internal error")
+}
+
+// contextStream overrides the context of a server stream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,194 @@
+package zigrpc
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.uber.org/fx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// ServerConfig configures the gRPC server.
+type ServerConfig struct {
+	// Addr is the address the server listens on (default: ":9090").
+	Addr string `json:"addr" yaml:"addr"`
+	// Reflection registers the reflection service, e.g. for grpcurl.
+	Reflection bool `json:"reflection" yaml:"reflection"`
+	// DrainTimeout is the maximum duration to wait for in-flight calls on
+	// shutdown, remaining ones are then canceled (default: 10s).
+	DrainTimeout time.Duration `json:"drain_timeout" yaml:"drain_timeout"`
+	// MaxRecvMsgSize and MaxSendMsgSize cap the size of messages (default:
+	// 4MiB received, unlimited sent).
+	MaxRecvMsgSize int `json:"max_recv_msg_size" yaml:"max_recv_msg_size"`
+	MaxSendMsgSize int `json:"max_send_msg_size" yaml:"max_send_msg_size"`
+}
+
+// DefaultServerConfig returns the default gRPC server configuration.
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		Addr:         ":9090",
+		DrainTimeout: 10 * time.Second,
+	}
+}
+
+// serverConfig is implemented by configs of services serving gRPC.
+type serverConfig interface {
+	GetGrpcServer() ServerConfig
+}
+
+// ServiceRegistrar registers gRPC services on a server.
+type ServiceRegistrar interface {
+	RegisterServices(s grpc.ServiceRegistrar)
+}
+
+// ServiceRegistrarFunc is a function implementing ServiceRegistrar.
+type ServiceRegistrarFunc func(s grpc.ServiceRegistrar)
+
+// RegisterServices calls f(s).
+func (f ServiceRegistrarFunc) RegisterServices(s grpc.ServiceRegistrar) {
+	f(s)
+}
+
+// Server is a gRPC server with its health service.
+type Server struct {
+	*grpc.Server
+	// Health is the health service, serving once the server started and
+	// not serving once it stops.
+	Health *health.Server
+
+	config   ServerConfig
+	listener net.Listener
+}
+
+type NewServerParams struct {
+	fx.In
+	Config ziconf.Config
+	Logger *zerolog.Logger
+	// Unary and Stream interceptors are chained after the built-in ones, in
+	// no particular order.
+	Unary    []grpc.UnaryServerInterceptor  `group:"grpc-unary-interceptors"`
+	Stream   []grpc.StreamServerInterceptor `group:"grpc-stream-interceptors"`
+	Services []ServiceRegistrar             `group:"grpc-service-registrars"`
+}
+
+// NewServer returns a Server configured by the config, see ServerConfig. Calls
+// are traced by the otelgrpc stats handler, and go through the metrics,
+// logging and recovery interceptors,
+// followed by the interceptors of the params, and the services of the params
+// are registered with the health service and, if enabled, the reflection
+// service.
+func NewServer(params NewServerParams) *Server {
+	config := DefaultServerConfig()
+	if c, ok := params.Config.(serverConfig); ok {
+		config = c.GetGrpcServer()
+		if config.Addr == "" {
+			config.Addr = DefaultServerConfig().Addr
+		}
+		if config.DrainTimeout == 0 {
+			config.DrainTimeout = DefaultServerConfig().DrainTimeout
+		}
+	}
+
+	unary := append([]grpc.UnaryServerInterceptor{
+		UnaryServerInterceptor(),
+		LogUnaryServerInterceptor(params.Logger),
+		RecoveryUnaryServerInterceptor(),
+	}, params.Unary...)
+	stream := append([]grpc.StreamServerInterceptor{
+		StreamServerInterceptor(),
+		LogStreamServerInterceptor(params.Logger),
+		RecoveryStreamServerInterceptor(),
+	}, params.Stream...)
+	opts := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}
+	if config.MaxRecvMsgSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(config.MaxRecvMsgSize))
+	}
+	if config.MaxSendMsgSize > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(config.MaxSendMsgSize))
+	}
+
+	s := &Server{
+		Server: grpc.NewServer(opts...),
+		Health: health.NewServer(),
+		config: config,
+	}
+	s.Health.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(s.Server, s.Health)
+	if config.Reflection {
+		reflection.Register(s.Server)
+	}
+	for _, r := range params.Services {
+		r.RegisterServices(s.Server)
+	}
+	return s
+}
+
+// Addr returns the address the server listens on, once started.
+func (s *Server) Addr() net.Addr {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+type StartServerParams struct {
+	fx.In
+	LC     fx.Lifecycle
+	Logger *zerolog.Logger
+	Server *Server
+}
+
+// StartServer starts serving the server with the lifecycle. On stop, the
+// health service stops serving, and in-flight calls are drained up to the
+// drain timeout, or until the fx stop timeout.
+func StartServer(params StartServerParams) {
+	s := params.Server
+	params.LC.Append(fx.StartHook(func() error {
+		lis, err := net.Listen("tcp", s.config.Addr)
+		if err != nil {
+			return err
+		}
+		s.listener = lis
+		s.Health.Resume()
+		go func() {
+			if err := s.Serve(lis); err != nil {
+				params.Logger.Error().Err(err).Str("addr", s.config.Addr).Msg("gRPC server stopped")
+			}
+		}()
+		params.Logger.Info().Str("addr", lis.Addr().String()).Msg("gRPC server started")
+		return nil
+	}))
+
+	params.LC.Append(fx.StopHook(func(ctx context.Context) {
+		s.Health.Shutdown()
+		if s.config.DrainTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, s.config.DrainTimeout)
+			defer cancel()
+		}
+
+		done := make(chan struct{})
+		go func() {
+			s.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			params.Logger.Warn().Msg("gRPC server drain timeout exceeded, canceling in-flight calls")
+			s.Stop()
+			<-done
+		}
+	}))
+}
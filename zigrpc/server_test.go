@@ -0,0 +1,163 @@
+package zigrpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"go.uber.org/fx/fxtest"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type testConfig struct{}
+
+func (testConfig) GetService() ziconf.ServiceConfig { return ziconf.ServiceConfig{Name: "test"} }
+func (testConfig) GetEnvironment() string           { return "test" }
+func (testConfig) GetLog() ziconf.LogConfig         { return ziconf.LogConfig{} }
+func (testConfig) GetHttpPort() string              { return ":0" }
+func (testConfig) GetTelemetry() observe.Config     { return observe.Config{} }
+func (testConfig) GetGrpcServer() ServerConfig      { return ServerConfig{Addr: "127.0.0.1:0"} }
+
+// echoDesc describes a unary service echoing its request, panicking on
+// "panic".
+var echoDesc = grpc.ServiceDesc{
+	ServiceName: "test.Echo",
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Echo",
+		Handler: func(_ any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			req := &wrapperspb.StringValue{}
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, req any) (any, error) {
+				if req.(*wrapperspb.StringValue).Value == "panic" {
+					panic("boom")
+				}
+				return req, nil
+			}
+			return interceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: "/test.Echo/Echo"}, handler)
+		},
+	}},
+}
+
+func TestServer(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	logger := zerolog.Nop()
+	srv := NewServer(NewServerParams{
+		Config: testConfig{},
+		Logger: &logger,
+		Services: []ServiceRegistrar{ServiceRegistrarFunc(func(s grpc.ServiceRegistrar) {
+			s.RegisterService(&echoDesc, nil)
+		})},
+	})
+	lc := fxtest.NewLifecycle(t)
+	StartServer(StartServerParams{LC: lc, Logger: &logger, Server: srv})
+	lc.RequireStart()
+	defer lc.RequireStop()
+
+	conn, err := grpc.NewClient(srv.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx := context.Background()
+
+	check, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Failed to check health: %v", err)
+	}
+	if check.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("Health = %v, want SERVING", check.Status)
+	}
+
+	resp := &wrapperspb.StringValue{}
+	if err := conn.Invoke(ctx, "/test.Echo/Echo", wrapperspb.String("hello"), resp); err != nil {
+		t.Fatalf("Failed to echo: %v", err)
+	}
+	if resp.Value != "hello" {
+		t.Errorf("Echo = %q, want hello", resp.Value)
+	}
+
+	err = conn.Invoke(ctx, "/test.Echo/Echo", wrapperspb.String("panic"), resp)
+	if status.Code(err) != grpccodes.Internal {
+		t.Errorf("Panicking call = %v, want Internal", err)
+	}
+	reveliotest.AssertCounterValue(t, s, "grpc_server_panics_total", 1, attribute.String("method", "/test.Echo/Echo"))
+}
+
+func TestServerTracing(t *testing.T) {
+	reveliotest.NewDefaultTestScope(t)
+	recorder := tracetest.NewSpanRecorder()
+	provider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	propagator := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	}()
+
+	logger := zerolog.Nop()
+	srv := NewServer(NewServerParams{
+		Config: testConfig{},
+		Logger: &logger,
+		Services: []ServiceRegistrar{ServiceRegistrarFunc(func(s grpc.ServiceRegistrar) {
+			s.RegisterService(&echoDesc, nil)
+		})},
+	})
+	lc := fxtest.NewLifecycle(t)
+	StartServer(StartServerParams{LC: lc, Logger: &logger, Server: srv})
+	lc.RequireStart()
+	defer lc.RequireStop()
+
+	opts, err := ClientConfig{}.DialOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := grpc.NewClient(srv.Addr().String(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.Invoke(context.Background(), "/test.Echo/Echo", wrapperspb.String("hello"), &wrapperspb.StringValue{}); err != nil {
+		t.Fatalf("Failed to echo: %v", err)
+	}
+
+	spans := map[oteltrace.SpanKind]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		if span.Name() == "test.Echo/Echo" {
+			spans[span.SpanKind()] = span
+		}
+	}
+	server, client := spans[oteltrace.SpanKindServer], spans[oteltrace.SpanKindClient]
+	if server == nil || client == nil {
+		t.Fatalf("spans = %v, want a client and a server span", recorder.Ended())
+	}
+	if server.Parent().SpanID() != client.SpanContext().SpanID() {
+		t.Error("server span isn't a child of the client span")
+	}
+	var class string
+	for _, attr := range server.Attributes() {
+		if attr.Key == "rpc.grpc.error_class" {
+			class = attr.Value.AsString()
+		}
+	}
+	if class != ClassOK {
+		t.Errorf("rpc.grpc.error_class = %q, want %q", class, ClassOK)
+	}
+}
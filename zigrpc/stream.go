@@ -0,0 +1,230 @@
+package zigrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Error classes of finished streams, see ClassifyError.
+const (
+	ClassOK       = "ok"
+	ClassCanceled = "canceled"
+	ClassTimeout  = "timeout"
+	ClassClient   = "client_error"
+	ClassServer   = "server_error"
+)
+
+// ClassifyError returns the class of the error ending a stream or a call:
+// ClassOK, ClassCanceled, ClassTimeout, ClassClient when the peer sent an
+// invalid request, or ClassServer.
+func ClassifyError(err error) string {
+	if err == nil || errors.Is(err, io.EOF) {
+		return ClassOK
+	}
+	switch errorCode(err) {
+	case grpccodes.OK:
+		return ClassOK
+	case grpccodes.Canceled:
+		return ClassCanceled
+	case grpccodes.DeadlineExceeded:
+		return ClassTimeout
+	case grpccodes.InvalidArgument, grpccodes.NotFound, grpccodes.AlreadyExists,
+		grpccodes.PermissionDenied, grpccodes.Unauthenticated, grpccodes.FailedPrecondition,
+		grpccodes.OutOfRange:
+		return ClassClient
+	}
+	return ClassServer
+}
+
+// streamMetrics are the instruments shared by the stream interceptors:
+//
+//   - grpc_stream_messages_total{side, method, direction} counts the messages
+//     sent and received,
+//   - grpc_stream_message_size_bytes{side, method, direction} is the size of
+//     protobuf messages,
+//   - grpc_stream_duration_ms{side, method, code, class} is the lifetime of
+//     streams, by status code and error class.
+type streamMetrics struct {
+	messages metric.Int64Counter
+	sizes    metric.Int64Histogram
+	duration revelio.DurationRecorder
+}
+
+func newStreamMetrics() *streamMetrics {
+	return &streamMetrics{
+		messages: revelio.MustInt64Counter(
+			"grpc_stream_messages_total",
+			"Number of gRPC stream messages, by direction",
+		),
+		sizes: revelio.MustInt64Histogram(
+			"grpc_stream_message_size_bytes",
+			"Size of gRPC stream messages",
+			metric.WithUnit("By"),
+			metric.WithExplicitBucketBoundaries(64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304),
+		),
+		duration: revelio.MustDuration(
+			"grpc_stream_duration_ms",
+			"Duration of gRPC streams",
+			revelio.WithUnit("ms"),
+			revelio.WithExplicitBucketBoundaries(10, 100, 1000, 10000, 60000, 300000, 900000, 3600000),
+		),
+	}
+}
+
+// StreamServerInterceptor instruments server streams: it records a counter
+// and the size of every message, and the stream duration classified by its
+// error, see ClassifyError, added to the stream span started by the otelgrpc
+// stats handler of the server.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	m := newStreamMetrics()
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		s := &serverStream{ServerStream: ss, counter: newMessageCounter(m, "server", info.FullMethod)}
+		start := time.Now()
+		err := handler(srv, s)
+		class := s.counter.finish(ctx, time.Since(start), err)
+		recordClass(trace.SpanFromContext(ctx), class, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor instruments client streams the same way as
+// StreamServerInterceptor. The stream ends when a receive fails, io.EOF
+// included, or when the context is done.
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	m := newStreamMetrics()
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		counter := newMessageCounter(m, "client", method)
+		start := time.Now()
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			counter.finish(ctx, time.Since(start), err)
+			return nil, err
+		}
+
+		s := &clientStream{ClientStream: cs, counter: counter, start: start}
+		go func() {
+			// Ends streams abandoned before being read until the end, gRPC
+			// cancels the stream context once it is done
+			<-cs.Context().Done()
+			s.finish(cs.Context().Err())
+		}()
+		return s, nil
+	}
+}
+
+// messageCounter records the messages of a stream.
+type messageCounter struct {
+	metrics *streamMetrics
+	side    string
+	method  string
+}
+
+func newMessageCounter(m *streamMetrics, side, method string) *messageCounter {
+	return &messageCounter{metrics: m, side: side, method: method}
+}
+
+func (c *messageCounter) record(ctx context.Context, direction string, msg any) {
+	attrs := metric.WithAttributes(
+		attribute.String("side", c.side),
+		attribute.String("method", c.method),
+		attribute.String("direction", direction),
+	)
+	c.metrics.messages.Add(ctx, 1, attrs)
+	if pm, ok := msg.(proto.Message); ok {
+		c.metrics.sizes.Record(ctx, int64(proto.Size(pm)), attrs)
+	}
+}
+
+// finish records the end of the stream, ended with err, and returns its
+// error class.
+func (c *messageCounter) finish(ctx context.Context, d time.Duration, err error) string {
+	class := ClassifyError(err)
+	code := errorCode(err)
+	if class == ClassOK {
+		code = grpccodes.OK
+	}
+	c.metrics.duration.Record(ctx, d,
+		attribute.String("side", c.side),
+		attribute.String("method", c.method),
+		attribute.String("code", code.String()),
+		attribute.String("class", class),
+	)
+	return class
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	counter *messageCounter
+}
+
+func (s *serverStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.counter.record(s.Context(), "sent", m)
+	}
+	return err
+}
+
+func (s *serverStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.counter.record(s.Context(), "received", m)
+	}
+	return err
+}
+
+type clientStream struct {
+	grpc.ClientStream
+	counter  *messageCounter
+	start    time.Time
+	finished atomic.Bool
+}
+
+func (s *clientStream) SendMsg(m any) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.counter.record(s.Context(), "sent", m)
+	} else if !errors.Is(err, io.EOF) {
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil {
+		s.counter.record(s.Context(), "received", m)
+	} else {
+		s.finish(err)
+	}
+	return err
+}
+
+// finish records the end of the stream, once.
+func (s *clientStream) finish(err error) {
+	if !s.finished.CompareAndSwap(false, true) {
+		return
+	}
+	s.counter.finish(context.WithoutCancel(s.Context()), time.Since(s.start), err)
+}
+
+// errorCode returns the gRPC status code of err, context errors included.
+func errorCode(err error) grpccodes.Code {
+	if s, ok := status.FromError(err); ok {
+		return s.Code()
+	}
+	return status.FromContextError(err).Code()
+}
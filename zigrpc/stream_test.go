@@ -0,0 +1,144 @@
+package zigrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// countdownDesc describes a server streaming service sending count messages,
+// then failing when the request is negative.
+var countdownDesc = grpc.ServiceDesc{
+	ServiceName: "test.Countdown",
+	HandlerType: (*any)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Count",
+		ServerStreams: true,
+		Handler: func(_ any, stream grpc.ServerStream) error {
+			req := &wrapperspb.Int32Value{}
+			if err := stream.RecvMsg(req); err != nil {
+				return err
+			}
+			if req.Value < 0 {
+				return status.Error(grpccodes.InvalidArgument, "negative count")
+			}
+			for i := req.Value; i > 0; i-- {
+				if err := stream.SendMsg(wrapperspb.String("tick")); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}},
+}
+
+func dialCountdown(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.StreamInterceptor(StreamServerInterceptor()))
+	srv.RegisterService(&countdownDesc, nil)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStreamInterceptor(StreamClientInterceptor()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func countdown(ctx context.Context, conn *grpc.ClientConn, n int32) (int, error) {
+	stream, err := conn.NewStream(ctx, &countdownDesc.Streams[0], "/test.Countdown/Count")
+	if err != nil {
+		return 0, err
+	}
+	if err := stream.SendMsg(wrapperspb.Int32(n)); err != nil {
+		return 0, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return 0, err
+	}
+	for received := 0; ; received++ {
+		if err := stream.RecvMsg(&wrapperspb.StringValue{}); err != nil {
+			if errors.Is(err, io.EOF) {
+				return received, nil
+			}
+			return received, err
+		}
+	}
+}
+
+func TestStreamInterceptors(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	conn := dialCountdown(t)
+	ctx := context.Background()
+
+	if n, err := countdown(ctx, conn, 3); err != nil || n != 3 {
+		t.Fatalf("countdown(3) = %d, %v", n, err)
+	}
+	if _, err := countdown(ctx, conn, -1); status.Code(err) != grpccodes.InvalidArgument {
+		t.Fatalf("countdown(-1) error = %v, want InvalidArgument", err)
+	}
+	conn.Close()
+
+	method := attribute.String("method", "/test.Countdown/Count")
+	for _, side := range []string{"server", "client"} {
+		sent, received := "sent", "received"
+		if side == "client" {
+			sent, received = received, sent
+		}
+		reveliotest.AssertCounterValue(t, s, "grpc_stream_messages_total", 3,
+			method, attribute.String("side", side), attribute.String("direction", sent))
+		reveliotest.AssertCounterValue(t, s, "grpc_stream_messages_total", 2,
+			method, attribute.String("side", side), attribute.String("direction", received))
+
+		for class, want := range map[string]uint64{ClassOK: 1, ClassClient: 1} {
+			h := reveliotest.CollectHistogram(t, s, "grpc_stream_duration_ms", method, attribute.String("side", side), attribute.String("class", class))
+			if h.Count != want {
+				t.Errorf("%s streams with class %s = %d, want %d", side, class, h.Count, want)
+			}
+		}
+	}
+
+	sizes := reveliotest.CollectHistogram(t, s, "grpc_stream_message_size_bytes", method,
+		attribute.String("side", "server"), attribute.String("direction", "sent"))
+	if sizes.Count != 3 || sizes.Sum != 3*float64(6) {
+		t.Errorf("sent sizes: count = %d, sum = %v, want 3 messages of 6 bytes", sizes.Count, sizes.Sum)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{err: nil, want: ClassOK},
+		{err: io.EOF, want: ClassOK},
+		{err: context.Canceled, want: ClassCanceled},
+		{err: status.Error(grpccodes.DeadlineExceeded, ""), want: ClassTimeout},
+		{err: status.Error(grpccodes.NotFound, ""), want: ClassClient},
+		{err: status.Error(grpccodes.Unavailable, ""), want: ClassServer},
+		{err: errors.New("boom"), want: ClassServer},
+	}
+	for _, tt := range tests {
+		if got := ClassifyError(tt.err); got != tt.want {
+			t.Errorf("ClassifyError(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package zigrpcfx
+
+import (
+	"github.com/divikraf/lumos/zigrpc"
+	"go.uber.org/fx"
+	"google.golang.org/grpc"
+)
+
+var Provider = fx.Provide(zigrpc.NewServer)
+
+var Invoker = fx.Invoke(zigrpc.StartServer)
+
+// AddServices adds registrars of services, registered once the server is
+// created
+func AddServices(registrars ...zigrpc.ServiceRegistrar) fx.Option {
+	var opts []fx.Option
+	for _, r := range registrars {
+		opts = append(opts, fx.Supply(fx.Annotate(r, fx.As(new(zigrpc.ServiceRegistrar)), fx.ResultTags(`group:"grpc-service-registrars"`))))
+	}
+	return fx.Options(opts...)
+}
+
+// AsServiceRegistrar annotates a constructor of a zigrpc.ServiceRegistrar,
+// e.g. a service implementation with dependencies, to provide it in the
+// service registrars group
+func AsServiceRegistrar(constructor any) any {
+	return fx.Annotate(constructor, fx.As(new(zigrpc.ServiceRegistrar)), fx.ResultTags(`group:"grpc-service-registrars"`))
+}
+
+// AddUnaryInterceptor annotates a constructor of a
+// grpc.UnaryServerInterceptor, to chain it after the built-in ones
+func AddUnaryInterceptor(constructor any) fx.Option {
+	return fx.Provide(fx.Annotate(constructor, fx.ResultTags(`group:"grpc-unary-interceptors"`)))
+}
+
+// AddStreamInterceptor annotates a constructor of a
+// grpc.StreamServerInterceptor, to chain it after the built-in ones
+func AddStreamInterceptor(constructor any) fx.Option {
+	return fx.Provide(fx.Annotate(constructor, fx.ResultTags(`group:"grpc-stream-interceptors"`)))
+}
+
+// ClientsProvider provides the connections to the gRPC targets of the
+// config, see zigrpc.Clients
+var ClientsProvider = fx.Provide(zigrpc.NewClients)
+
+// ClientConn provides the connection to the target name of the config, as a
+// *grpc.ClientConn named name, e.g. to build a generated client:
+//
+//	fx.Provide(fx.Annotate(orderspb.NewOrdersClient, fx.ParamTags(`name:"orders"`)))
+func ClientConn(name string) fx.Option {
+	return fx.Provide(fx.Annotate(
+		func(clients *zigrpc.Clients) (*grpc.ClientConn, error) { return clients.Conn(name) },
+		fx.ResultTags(`name:"`+name+`"`),
+	))
+}
@@ -0,0 +1,37 @@
+// Package zihealth defines the health checkers of the dependencies, without
+// dependencies itself, so that the packages contributing them don't pull in
+// the HTTP endpoints of zin/health reporting them.
+package zihealth
+
+import "context"
+
+// Group is the fx group health checkers are collected from, e.g.
+//
+//	fx.Annotate(newChecker, fx.ResultTags(zihealth.Group))
+//
+// or in fx.Out structs:
+//
+//	Checker zihealth.Checker `group:"health.checker"`
+const Group = `group:"health.checker"`
+
+// Checker checks the health of a dependency.
+type Checker interface {
+	// Name identifies the checker in the readiness report.
+	Name() string
+	// Check returns an error when the dependency is unhealthy.
+	Check(ctx context.Context) error
+}
+
+type checkerFunc struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func (c checkerFunc) Name() string                    { return c.name }
+func (c checkerFunc) Check(ctx context.Context) error { return c.check(ctx) }
+
+// NewChecker returns a Checker named name calling check, e.g.
+// NewChecker("postgres", connector.PingAll).
+func NewChecker(name string, check func(ctx context.Context) error) Checker {
+	return checkerFunc{name: name, check: check}
+}
@@ -0,0 +1,125 @@
+package zihttpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/divikraf/lumos/internal/breaker"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ErrCircuitOpen is returned for requests to a host whose breaker is open.
+var ErrCircuitOpen = errors.New("zihttpc: circuit breaker open")
+
+// Breaker states
+const (
+	BreakerClosed   = breaker.Closed
+	BreakerOpen     = breaker.Open
+	BreakerHalfOpen = breaker.HalfOpen
+)
+
+// BreakerConfig configures the circuit breakers of the hosts. A breaker opens
+// when, over Window, at least MinRequests requests were made to its host and
+// the rate of failures, transport errors and server errors, reaches
+// ErrorRate. Open breakers fail requests with ErrCircuitOpen for
+// OpenDuration, then let HalfOpenRequests probes through, closing once they
+// all succeed.
+type BreakerConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Window is the duration over which requests are counted (default: 10s).
+	Window time.Duration `json:"window" yaml:"window"`
+	// MinRequests is the minimum number of requests of the window before the
+	// breaker can open (default: 20).
+	MinRequests int `json:"min_requests" yaml:"min_requests"`
+	// ErrorRate is the failure rate opening the breaker (default: 0.5).
+	ErrorRate float64 `json:"error_rate" yaml:"error_rate"`
+	// OpenDuration is how long the breaker stays open (default: 30s).
+	OpenDuration time.Duration `json:"open_duration" yaml:"open_duration"`
+	// HalfOpenRequests is the number of probes of a half-open breaker
+	// (default: 1).
+	HalfOpenRequests int `json:"half_open_requests" yaml:"half_open_requests"`
+}
+
+func (c BreakerConfig) breakerConfig() breaker.Config {
+	return breaker.Config{
+		Window:           c.Window,
+		MinRequests:      c.MinRequests,
+		ErrorRate:        c.ErrorRate,
+		OpenDuration:     c.OpenDuration,
+		HalfOpenRequests: c.HalfOpenRequests,
+	}
+}
+
+// breakerTransport is an http.RoundTripper with a circuit breaker per host.
+type breakerTransport struct {
+	base        http.RoundTripper
+	config      breaker.Config
+	rejected    metric.Int64Counter
+	transitions metric.Int64Counter
+
+	mu       sync.Mutex
+	breakers map[string]*breaker.Breaker
+}
+
+// NewBreakerTransport wraps base with a circuit breaker per host, see
+// BreakerConfig. Rejected requests are counted in
+// http_client_breaker_rejected_total, and transitions in
+// http_client_breaker_transitions_total, by host.
+func NewBreakerTransport(base http.RoundTripper, config BreakerConfig) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &breakerTransport{
+		base:   base,
+		config: config.breakerConfig(),
+		rejected: revelio.MustInt64Counter(
+			"http_client_breaker_rejected_total",
+			"Number of HTTP client requests rejected by an open circuit breaker, by host",
+		),
+		transitions: revelio.MustInt64Counter(
+			"http_client_breaker_transitions_total",
+			"Number of HTTP client circuit breaker state transitions, by host and state",
+		),
+		breakers: map[string]*breaker.Breaker{},
+	}
+}
+
+func (t *breakerTransport) hostBreaker(host string) *breaker.Breaker {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b, ok := t.breakers[host]
+	if !ok {
+		b = breaker.New(t.config, func(ctx context.Context, _, to string) {
+			t.transitions.Add(ctx, 1, metric.WithAttributes(
+				attribute.String("host", host),
+				attribute.String("state", to),
+			))
+		})
+		t.breakers[host] = b
+	}
+	return b
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	b := t.hostBreaker(req.URL.Host)
+	if !b.Allow(ctx) {
+		t.rejected.Add(ctx, 1, metric.WithAttributes(attribute.String("host", req.URL.Host)))
+		return nil, ErrCircuitOpen
+	}
+	start := b.Now()
+	resp, err := t.base.RoundTrip(req)
+	// Requests canceled by the caller say nothing of the host
+	if ctx.Err() == nil {
+		b.Done(ctx, err != nil || resp.StatusCode >= 500, b.Now().Sub(start))
+	} else {
+		b.Release()
+	}
+	return resp, err
+}
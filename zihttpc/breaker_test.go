@@ -0,0 +1,84 @@
+package zihttpc
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestBreakerTransport(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	var failing atomic.Bool
+	failing.Store(true)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+	host := mustHost(t, srv.URL)
+
+	transport := NewBreakerTransport(http.DefaultTransport, BreakerConfig{
+		Enabled:      true,
+		MinRequests:  4,
+		ErrorRate:    0.5,
+		OpenDuration: time.Minute,
+	}).(*breakerTransport)
+	now := time.Now()
+	transport.hostBreaker(host).Now = func() time.Time { return now }
+	client := &http.Client{Transport: transport}
+
+	for range 4 {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	t.Run("open breakers reject requests", func(t *testing.T) {
+		_, err := client.Get(srv.URL)
+		if !errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("err = %v, want %v", err, ErrCircuitOpen)
+		}
+		if calls.Load() != 4 {
+			t.Errorf("calls = %d, want 4", calls.Load())
+		}
+		reveliotest.AssertCounterValue(t, s, "http_client_breaker_rejected_total", 1, attribute.String("host", host))
+		reveliotest.AssertCounterValue(t, s, "http_client_breaker_transitions_total", 1,
+			attribute.String("host", host), attribute.String("state", BreakerOpen))
+	})
+
+	t.Run("a successful probe closes the breaker", func(t *testing.T) {
+		failing.Store(false)
+		now = now.Add(time.Minute)
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Failed to send probe: %v", err)
+		}
+		resp.Body.Close()
+		if state := transport.hostBreaker(host).State(); state != BreakerClosed {
+			t.Errorf("state = %q, want %q", state, BreakerClosed)
+		}
+		reveliotest.AssertCounterValue(t, s, "http_client_breaker_transitions_total", 1,
+			attribute.String("host", host), attribute.String("state", BreakerClosed))
+	})
+}
+
+func mustHost(t *testing.T, rawURL string) string {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("Failed to parse URL: %v", err)
+	}
+	return u.Host
+}
@@ -0,0 +1,152 @@
+package zihttpc
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ClientConfig configures an HTTP client, see New.
+type ClientConfig struct {
+	// Timeout is the maximum duration of a request, retries and hedges
+	// included (default: 30s).
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+	// Retry, if set, retries the failed idempotent requests.
+	Retry *RetryConfig `json:"retry" yaml:"retry"`
+	// Hedge, if set, hedges the slow idempotent requests.
+	Hedge   *HedgeConfig  `json:"hedge" yaml:"hedge"`
+	Breaker BreakerConfig `json:"breaker" yaml:"breaker"`
+	// DNS, if set, caches the DNS lookups of the default transport, see
+	// DNSCache.
+	DNS *DNSConfig `json:"dns" yaml:"dns"`
+}
+
+// Option configures New.
+type Option func(*options)
+
+type options struct {
+	base http.RoundTripper
+}
+
+// WithTransport sets the transport sending the requests (default: a
+// transport of NewTransport, per client).
+func WithTransport(base http.RoundTripper) Option {
+	return func(o *options) {
+		o.base = base
+	}
+}
+
+type routeCtxKey struct{}
+
+// WithRoute sets the route template of the requests made with ctx, e.g.
+// "/orders/{id}", naming their spans and labelling their metrics instead of
+// the unbounded paths.
+func WithRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeCtxKey{}, route)
+}
+
+func routeOf(req *http.Request) string {
+	route, _ := req.Context().Value(routeCtxKey{}).(string)
+	return route
+}
+
+// New returns an *http.Client configured by config, the client-side
+// counterpart of a zin router: requests are traced with otelhttp, propagating
+// the trace, timed in http_client_duration_ms by host, route template, method
+// and status, and logged by the logger of their context when failing. Each
+// attempt then goes through the per-host circuit breaker, the retries and
+// the hedges, when configured, and records the connection pool metrics:
+// the connections acquired, the dials and the TLS handshakes.
+func New(config ClientConfig, opts ...Option) *http.Client {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+
+	transport := o.base
+	if transport == nil {
+		var dns *DNSCache
+		if config.DNS != nil {
+			dns = NewDNSCache(*config.DNS, nil)
+		}
+		transport = NewTransport(dns)
+	}
+	transport = newPoolTransport(transport)
+	if config.Breaker.Enabled {
+		transport = NewBreakerTransport(transport, config.Breaker)
+	}
+	if config.Hedge != nil {
+		transport = NewHedgedTransport(transport, *config.Hedge)
+	}
+	if config.Retry != nil {
+		transport = NewRetryTransport(transport, *config.Retry)
+	}
+	transport = newInstrumentedTransport(transport)
+	transport = otelhttp.NewTransport(transport, otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+		if route := routeOf(req); route != "" {
+			return req.Method + " " + route
+		}
+		return req.Method
+	}))
+
+	return &http.Client{Transport: transport, Timeout: config.Timeout}
+}
+
+// instrumentedTransport records the metrics and logs of the requests.
+type instrumentedTransport struct {
+	base     http.RoundTripper
+	duration revelio.DurationRecorder
+}
+
+func newInstrumentedTransport(base http.RoundTripper) *instrumentedTransport {
+	return &instrumentedTransport{
+		base: base,
+		duration: revelio.MustDuration(
+			"http_client_duration_ms",
+			"Duration of HTTP client requests in milliseconds, by host, route, method and status",
+		),
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	d := time.Since(start)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	route := routeOf(req)
+	t.duration.Record(ctx, d,
+		attribute.String("host", req.URL.Host),
+		attribute.String("http.route", route),
+		attribute.String("http.method", req.Method),
+		attribute.String("http.status", status),
+	)
+
+	if err != nil || resp.StatusCode >= 500 {
+		event := zerolog.Ctx(ctx).Warn().
+			Str("http.host", req.URL.Host).
+			Str("http.route", route).
+			Str("http.method", req.Method).
+			Str("http.status", status).
+			Dur("http.dur", d)
+		if err != nil {
+			event = event.Err(err)
+		}
+		event.Msg("HTTP client request failed")
+	}
+	return resp, err
+}
@@ -0,0 +1,45 @@
+package zihttpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := New(ClientConfig{
+		Retry: &RetryConfig{
+			MaxAttempts: 2,
+			Backoff:     time.Millisecond,
+			BudgetRatio: 0.1,
+		},
+	})
+
+	const requests = 20
+	ctx := WithRoute(context.Background(), "/orders/{id}")
+	for range requests {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/orders/1", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+		}
+	}
+	// The budget allows the first retries, then about one every ten requests
+	if got := calls.Load(); got <= requests || got >= 2*requests {
+		t.Errorf("calls = %d, want retries limited by the budget", got)
+	}
+}
@@ -0,0 +1,407 @@
+package zihttpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DNSConfig configures the DNS cache of a client, see DNSCache.
+type DNSConfig struct {
+	// MinTTL and MaxTTL bound the TTL of the cached records (default: 1s and
+	// 5m).
+	MinTTL time.Duration `json:"min_ttl" yaml:"min_ttl"`
+	MaxTTL time.Duration `json:"max_ttl" yaml:"max_ttl"`
+	// NegativeTTL is the duration a failed lookup is cached (default: 5s).
+	NegativeTTL time.Duration `json:"negative_ttl" yaml:"negative_ttl"`
+}
+
+func (c DNSConfig) withDefaults() DNSConfig {
+	if c.MinTTL <= 0 {
+		c.MinTTL = time.Second
+	}
+	if c.MaxTTL <= 0 {
+		c.MaxTTL = 5 * time.Minute
+	}
+	if c.NegativeTTL <= 0 {
+		c.NegativeTTL = 5 * time.Second
+	}
+	return c
+}
+
+// LookupFunc returns the addresses of host, and their TTL.
+type LookupFunc func(ctx context.Context, host string) ([]netip.Addr, time.Duration, error)
+
+// DNSCache caches the addresses of the hosts for the TTL of their records.
+// Failed lookups are cached for the negative TTL: hosts not found fail
+// without a lookup, and the addresses of the hosts whose lookup failed
+// otherwise, e.g. timing out, are served stale. Concurrent lookups of a host
+// are merged. Lookups are counted in http_client_dns_lookups_total by cache
+// result (hit, miss) and status.
+type DNSCache struct {
+	config DNSConfig
+	lookup LookupFunc
+
+	mu      sync.Mutex
+	entries map[string]*dnsEntry
+
+	lookups metric.Int64Counter
+}
+
+type dnsEntry struct {
+	// ready is closed once the lookup is done
+	ready   chan struct{}
+	addrs   []netip.Addr
+	err     error
+	expires time.Time
+}
+
+// NewDNSCache returns a DNSCache of the lookups of lookup, LookupDNS when
+// nil.
+func NewDNSCache(config DNSConfig, lookup LookupFunc) *DNSCache {
+	if lookup == nil {
+		lookup = LookupDNS
+	}
+	return &DNSCache{
+		config:  config.withDefaults(),
+		lookup:  lookup,
+		entries: map[string]*dnsEntry{},
+		lookups: revelio.MustInt64Counter(
+			"http_client_dns_lookups_total",
+			"Number of DNS lookups of HTTP clients, by cache result (hit, miss) and status",
+		),
+	}
+}
+
+// LookupNetIP returns the addresses of host, from the cache unless expired.
+func (c *DNSCache) LookupNetIP(ctx context.Context, host string) ([]netip.Addr, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{addr}, nil
+	}
+
+	c.mu.Lock()
+	e, ok := c.entries[host]
+	var stale []netip.Addr
+	if ok {
+		select {
+		case <-e.ready:
+			if time.Now().Before(e.expires) {
+				c.mu.Unlock()
+				c.record(ctx, "hit", e.err)
+				return e.addrs, e.err
+			}
+			stale = e.addrs
+			ok = false
+		default:
+			// Lookup in flight
+		}
+	}
+	if !ok {
+		e = &dnsEntry{ready: make(chan struct{})}
+		c.entries[host] = e
+		// The lookup isn't canceled with ctx, it is shared with the
+		// concurrent callers
+		go c.resolve(context.WithoutCancel(ctx), host, e, stale)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-e.ready:
+		c.record(ctx, "miss", e.err)
+		return e.addrs, e.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// resolve looks host up, and fills e.
+func (c *DNSCache) resolve(ctx context.Context, host string, e *dnsEntry, stale []netip.Addr) {
+	defer close(e.ready)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	addrs, ttl, err := c.lookup(ctx, host)
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil && len(addrs) == 0:
+		err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		fallthrough
+	case err != nil:
+		ttl = c.config.NegativeTTL
+		if len(stale) > 0 && !(errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+			addrs, err = stale, nil
+		}
+	default:
+		ttl = min(max(ttl, c.config.MinTTL), c.config.MaxTTL)
+	}
+	e.addrs, e.err, e.expires = addrs, err, time.Now().Add(ttl)
+}
+
+func (c *DNSCache) record(ctx context.Context, cache string, err error) {
+	c.lookups.Add(ctx, 1, metric.WithAttributes(
+		append([]attribute.KeyValue{attribute.String("cache", cache)}, revelio.ResultAttributes(err)...)...,
+	))
+}
+
+// DialContext returns a dial function, e.g. of an http.Transport, resolving
+// the hosts with c, then dialing their addresses with dialer in order until
+// one connects.
+func (c *DNSCache) DialContext(dialer *net.Dialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		addrs, err := c.LookupNetIP(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var firstErr error
+		for _, addr := range addrs {
+			if (network == "tcp4" && !addr.Is4()) || (network == "tcp6" && !addr.Is6()) {
+				continue
+			}
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		if firstErr == nil {
+			firstErr = &net.DNSError{Err: "no suitable address", Name: host}
+		}
+		return nil, firstErr
+	}
+}
+
+var (
+	nameserversOnce sync.Once
+	nameservers     []string
+)
+
+// readNameservers reads the nameservers of /etc/resolv.conf.
+func readNameservers() []string {
+	nameserversOnce.Do(func() {
+		f, err := os.Open("/etc/resolv.conf")
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) >= 2 && fields[0] == "nameserver" {
+				nameservers = append(nameservers, net.JoinHostPort(fields[1], "53"))
+			}
+		}
+	})
+	return nameservers
+}
+
+// LookupDNS looks the A and AAAA records of host up from the nameservers of
+// /etc/resolv.conf, returning the lowest TTL of the records. The Go resolver
+// doesn't expose the TTLs: it is used instead for the names which aren't
+// fully qualified, e.g. resolved with search domains or /etc/hosts, or when
+// the nameservers fail, the addresses being cached for the minimum TTL.
+func LookupDNS(ctx context.Context, host string) ([]netip.Addr, time.Duration, error) {
+	servers := readNameservers()
+	if len(servers) > 0 && strings.Contains(strings.TrimSuffix(host, "."), ".") {
+		if addrs, ttl, err := lookupServers(ctx, servers, host); err == nil && len(addrs) > 0 {
+			return addrs, ttl, nil
+		}
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	return addrs, 0, err
+}
+
+// lookupServers queries the A and AAAA records of host from the first
+// server answering.
+func lookupServers(ctx context.Context, servers []string, host string) ([]netip.Addr, time.Duration, error) {
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, 0, err
+	}
+	var lastErr error
+	for _, server := range servers {
+		addrs, ttl, err := lookupServer(ctx, server, name)
+		if err == nil {
+			return addrs, ttl, nil
+		}
+		lastErr = err
+		var dnsErr *net.DNSError
+		if ctx.Err() != nil || (errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+			break
+		}
+	}
+	return nil, 0, lastErr
+}
+
+// lookupServer queries the A and AAAA records of name from server.
+func lookupServer(ctx context.Context, server string, name dnsmessage.Name) ([]netip.Addr, time.Duration, error) {
+	var addrs []netip.Addr
+	var ttl time.Duration
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		found, t, err := exchange(ctx, server, name, qtype)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(found) > 0 && (len(addrs) == 0 || t < ttl) {
+			ttl = t
+		}
+		addrs = append(addrs, found...)
+	}
+	return addrs, ttl, nil
+}
+
+// exchange queries the records of type qtype of name from server over UDP,
+// then TCP when the answer is truncated, returning their lowest TTL.
+func exchange(ctx context.Context, server string, name dnsmessage.Name, qtype dnsmessage.Type) ([]netip.Addr, time.Duration, error) {
+	id := uint16(rand.Uint32())
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, 0, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: name, Type: qtype, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, 0, err
+	}
+	query, err := b.Finish()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	answer, err := roundTrip(ctx, "udp", server, query, id)
+	if err != nil {
+		return nil, 0, err
+	}
+	var p dnsmessage.Parser
+	h, err := p.Start(answer)
+	if err != nil {
+		return nil, 0, err
+	}
+	if h.Truncated {
+		if answer, err = roundTrip(ctx, "tcp", server, query, id); err != nil {
+			return nil, 0, err
+		}
+		if h, err = p.Start(answer); err != nil {
+			return nil, 0, err
+		}
+	}
+	switch h.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, 0, &net.DNSError{Err: "no such host", Name: name.String(), Server: server, IsNotFound: true}
+	default:
+		return nil, 0, &net.DNSError{Err: "server failure: " + h.RCode.String(), Name: name.String(), Server: server, IsTemporary: true}
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, 0, err
+	}
+
+	var addrs []netip.Addr
+	var ttl time.Duration
+	for {
+		rh, err := p.AnswerHeader()
+		if errors.Is(err, dnsmessage.ErrSectionDone) {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		var addr netip.Addr
+		switch rh.Type {
+		case dnsmessage.TypeA:
+			r, err := p.AResource()
+			if err != nil {
+				return nil, 0, err
+			}
+			addr = netip.AddrFrom4(r.A)
+		case dnsmessage.TypeAAAA:
+			r, err := p.AAAAResource()
+			if err != nil {
+				return nil, 0, err
+			}
+			addr = netip.AddrFrom16(r.AAAA)
+		default:
+			// e.g. the CNAME records of the chain leading to the addresses
+			if err := p.SkipAnswer(); err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+		if t := time.Duration(rh.TTL) * time.Second; len(addrs) == 0 || t < ttl {
+			ttl = t
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, ttl, nil
+}
+
+// roundTrip sends query to server, and returns the answer of ID id.
+func roundTrip(ctx context.Context, network, server string, query []byte, id uint16) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	// A nameserver not answering mustn't use the time of the others
+	deadline := time.Now().Add(2 * time.Second)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	if network == "tcp" {
+		// TCP messages are prefixed with their length
+		msg := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+		if _, err := conn.Write(append(msg, query...)); err != nil {
+			return nil, err
+		}
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return nil, err
+		}
+		answer := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, answer); err != nil {
+			return nil, err
+		}
+		return answer, nil
+	}
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 1232)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		// Answers to other queries are ignored
+		if n >= 2 && binary.BigEndian.Uint16(buf) == id {
+			return buf[:n], nil
+		}
+	}
+}
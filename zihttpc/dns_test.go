@@ -0,0 +1,137 @@
+package zihttpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestDNSCache(t *testing.T) {
+	var (
+		calls  atomic.Int32
+		mu     sync.Mutex
+		result = []netip.Addr{netip.MustParseAddr("10.0.0.1")}
+		ttl    = time.Hour
+		err    error
+	)
+	cache := NewDNSCache(DNSConfig{MaxTTL: 20 * time.Millisecond, NegativeTTL: 20 * time.Millisecond}, func(ctx context.Context, host string) ([]netip.Addr, time.Duration, error) {
+		calls.Add(1)
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		return result, ttl, err
+	})
+	set := func(addrs []netip.Addr, e error) {
+		mu.Lock()
+		defer mu.Unlock()
+		result, err = addrs, e
+	}
+	lookup := func() ([]netip.Addr, error) {
+		return cache.LookupNetIP(context.Background(), "api.example.com")
+	}
+
+	// Concurrent lookups are merged, then cached for the TTL capped by MaxTTL
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if addrs, err := lookup(); err != nil || len(addrs) != 1 {
+				t.Errorf("LookupNetIP() = %v, %v", addrs, err)
+			}
+		}()
+	}
+	wg.Wait()
+	lookup()
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("%d lookups, want 1", n)
+	}
+
+	// Failing lookups serve the stale addresses
+	time.Sleep(25 * time.Millisecond)
+	set(nil, errors.New("i/o timeout"))
+	if addrs, err := lookup(); err != nil || len(addrs) != 1 {
+		t.Errorf("LookupNetIP() after a failure = %v, %v, want the stale addresses", addrs, err)
+	}
+
+	// Hosts not found are cached for the negative TTL
+	time.Sleep(25 * time.Millisecond)
+	set(nil, &net.DNSError{Err: "no such host", IsNotFound: true})
+	for range 2 {
+		if _, err := lookup(); err == nil {
+			t.Error("LookupNetIP() of a host not found succeeded")
+		}
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("%d lookups, want 3", n)
+	}
+
+	if addrs, err := cache.LookupNetIP(context.Background(), "::1"); err != nil || addrs[0] != netip.IPv6Loopback() {
+		t.Errorf("LookupNetIP() of an address = %v, %v", addrs, err)
+	}
+}
+
+// serveDNS answers the A queries of a UDP nameserver with addr, and the AAAA
+// ones with no record.
+func serveDNS(t *testing.T, addr [4]byte, ttl uint32) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var p dnsmessage.Parser
+			h, err := p.Start(buf[:n])
+			if err != nil {
+				continue
+			}
+			q, err := p.Question()
+			if err != nil {
+				continue
+			}
+			b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: h.ID, Response: true, RecursionAvailable: true})
+			b.StartQuestions()
+			b.Question(q)
+			b.StartAnswers()
+			if q.Type == dnsmessage.TypeA {
+				b.CNAMEResource(dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: 3600},
+					dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName("lb.example.com.")})
+				b.AResource(dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName("lb.example.com."), Class: dnsmessage.ClassINET, TTL: ttl},
+					dnsmessage.AResource{A: addr})
+			}
+			msg, _ := b.Finish()
+			conn.WriteTo(msg, from)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestLookupServers(t *testing.T) {
+	server := serveDNS(t, [4]byte{10, 0, 0, 7}, 42)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	addrs, ttl, err := lookupServers(ctx, []string{server}, "api.example.com")
+	if err != nil {
+		t.Fatalf("lookupServers() = %v", err)
+	}
+	if len(addrs) != 1 || addrs[0] != netip.MustParseAddr("10.0.0.7") {
+		t.Errorf("addresses = %v, want [10.0.0.7]", addrs)
+	}
+	if ttl != 42*time.Second {
+		t.Errorf("TTL = %s, want the TTL of the A record", ttl)
+	}
+}
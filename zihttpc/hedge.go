@@ -0,0 +1,322 @@
+package zihttpc
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// HedgeConfig holds configuration for hedged requests.
+type HedgeConfig struct {
+	// Delay is how long to wait for an attempt before sending the next one.
+	// When Percentile is set, Delay is used until enough latencies are
+	// observed.
+	Delay time.Duration `json:"delay" yaml:"delay"`
+
+	// Percentile, if greater than zero, derives the hedge delay from the
+	// observed latency percentile (e.g. 0.95 for p95).
+	Percentile float64 `json:"percentile" yaml:"percentile"`
+
+	// MaxHedges is the maximum number of additional attempts (default: 1).
+	MaxHedges int `json:"max_hedges" yaml:"max_hedges"`
+
+	// BudgetRatio is the maximum ratio of hedges to requests, e.g. 0.1 allows
+	// at most one hedge for every ten requests (default: 0.1).
+	BudgetRatio float64 `json:"budget_ratio" yaml:"budget_ratio"`
+}
+
+// DefaultHedgeConfig returns the default configuration for hedged requests.
+func DefaultHedgeConfig() HedgeConfig {
+	return HedgeConfig{
+		Delay:       50 * time.Millisecond,
+		MaxHedges:   1,
+		BudgetRatio: 0.1,
+	}
+}
+
+type idempotentCtxKey struct{}
+
+// WithIdempotent marks requests made with ctx as safe to be hedged, even when
+// the HTTP method is not idempotent (e.g. a POST carrying an idempotency key).
+func WithIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentCtxKey{}, true)
+}
+
+func isIdempotent(req *http.Request) bool {
+	if v, _ := req.Context().Value(idempotentCtxKey{}).(bool); v {
+		return true
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+const (
+	hedgeEventSent      = "sent"
+	hedgeEventWon       = "won"
+	hedgeEventNoBudget  = "budget_exhausted"
+	latencySampleSize   = 1000
+	latencyRecomputeGap = 100
+	maxBudgetTokens     = 10
+)
+
+// hedgedTransport is an http.RoundTripper sending hedged requests.
+type hedgedTransport struct {
+	base    http.RoundTripper
+	config  HedgeConfig
+	budget  *tokenBudget
+	latency *latencyTracker
+	counter metric.Int64Counter
+}
+
+// NewHedgedTransport wraps base so idempotent requests are re-sent after the
+// configured delay when the previous attempt has not responded yet. The first
+// response wins, and the other attempts are canceled.
+func NewHedgedTransport(base http.RoundTripper, config HedgeConfig) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	defaults := DefaultHedgeConfig()
+	if config.Delay <= 0 {
+		config.Delay = defaults.Delay
+	}
+	if config.MaxHedges <= 0 {
+		config.MaxHedges = defaults.MaxHedges
+	}
+	if config.BudgetRatio <= 0 {
+		config.BudgetRatio = defaults.BudgetRatio
+	}
+
+	counter := revelio.MustInt64Counter(
+		"http_client_hedges_total",
+		"Number of hedged HTTP client requests by event",
+	)
+
+	return &hedgedTransport{
+		base:    base,
+		config:  config,
+		budget:  &tokenBudget{ratio: config.BudgetRatio},
+		latency: newLatencyTracker(config.Percentile),
+		counter: counter,
+	}
+}
+
+type attemptResult struct {
+	idx  int
+	resp *http.Response
+	err  error
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *hedgedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.hedgeable(req) {
+		return t.base.RoundTrip(req)
+	}
+	t.budget.deposit()
+
+	ctx := req.Context()
+	hostAttr := attribute.String("host", req.URL.Host)
+	maxAttempts := 1 + t.config.MaxHedges
+	results := make(chan attemptResult, maxAttempts)
+	cancels := make([]context.CancelFunc, 0, maxAttempts)
+	start := time.Now()
+
+	send := func(idx int) error {
+		actx, cancel := context.WithCancel(ctx)
+		r := req.Clone(actx)
+		if idx > 0 && req.Body != nil && req.Body != http.NoBody {
+			body, err := req.GetBody()
+			if err != nil {
+				cancel()
+				return err
+			}
+			r.Body = body
+		}
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := t.base.RoundTrip(r)
+			results <- attemptResult{idx: idx, resp: resp, err: err}
+		}()
+		return nil
+	}
+
+	if err := send(0); err != nil {
+		return nil, err
+	}
+	inflight := 1
+
+	timer := time.NewTimer(t.latency.delay(t.config.Delay))
+	defer timer.Stop()
+
+	var lastErr error
+	for {
+		select {
+		case res := <-results:
+			inflight--
+			if res.err != nil {
+				lastErr = res.err
+				if inflight > 0 {
+					continue
+				}
+				t.cancelAll(cancels, -1)
+				return nil, lastErr
+			}
+
+			if res.idx == 0 {
+				t.latency.observe(time.Since(start))
+			} else {
+				t.counter.Add(ctx, 1, metric.WithAttributes(hostAttr, attribute.String("event", hedgeEventWon)))
+			}
+			t.cancelAll(cancels, res.idx)
+			go drainResults(results, inflight)
+
+			res.resp.Body = &cancelOnClose{ReadCloser: res.resp.Body, cancel: cancels[res.idx]}
+			return res.resp, nil
+		case <-timer.C:
+			if len(cancels) >= maxAttempts {
+				continue
+			}
+			if !t.budget.withdraw() {
+				t.counter.Add(ctx, 1, metric.WithAttributes(hostAttr, attribute.String("event", hedgeEventNoBudget)))
+				continue
+			}
+			if err := send(len(cancels)); err != nil {
+				continue
+			}
+			inflight++
+			t.counter.Add(ctx, 1, metric.WithAttributes(hostAttr, attribute.String("event", hedgeEventSent)))
+			timer.Reset(t.latency.delay(t.config.Delay))
+		case <-ctx.Done():
+			t.cancelAll(cancels, -1)
+			go drainResults(results, inflight)
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (t *hedgedTransport) hedgeable(req *http.Request) bool {
+	if !isIdempotent(req) {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	return true
+}
+
+// cancelAll cancels every attempt except the one at index keep.
+func (t *hedgedTransport) cancelAll(cancels []context.CancelFunc, keep int) {
+	for i, cancel := range cancels {
+		if i != keep {
+			cancel()
+		}
+	}
+}
+
+// drainResults closes the bodies of the losing attempts.
+func drainResults(results <-chan attemptResult, n int) {
+	for i := 0; i < n; i++ {
+		res := <-results
+		if res.resp != nil {
+			res.resp.Body.Close()
+		}
+	}
+}
+
+// cancelOnClose releases the winning attempt context once its body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// tokenBudget limits the ratio of hedges or retries to requests with a token
+// bucket.
+type tokenBudget struct {
+	mu     sync.Mutex
+	ratio  float64
+	tokens float64
+}
+
+func (b *tokenBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.tokens+b.ratio, maxBudgetTokens)
+}
+
+func (b *tokenBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// latencyTracker keeps a window of observed latencies to derive the hedge
+// delay from a percentile.
+type latencyTracker struct {
+	mu         sync.Mutex
+	percentile float64
+	samples    []time.Duration
+	next       int
+	observed   int
+	current    time.Duration
+}
+
+func newLatencyTracker(percentile float64) *latencyTracker {
+	return &latencyTracker{
+		percentile: percentile,
+		samples:    make([]time.Duration, 0, latencySampleSize),
+	}
+}
+
+func (l *latencyTracker) observe(d time.Duration) {
+	if l.percentile <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.samples) < latencySampleSize {
+		l.samples = append(l.samples, d)
+	} else {
+		l.samples[l.next] = d
+		l.next = (l.next + 1) % latencySampleSize
+	}
+
+	l.observed++
+	if l.observed%latencyRecomputeGap == 0 {
+		sorted := slices.Clone(l.samples)
+		slices.Sort(sorted)
+		idx := int(float64(len(sorted)-1) * l.percentile)
+		l.current = sorted[idx]
+	}
+}
+
+func (l *latencyTracker) delay(fallback time.Duration) time.Duration {
+	if l.percentile <= 0 {
+		return fallback
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.current <= 0 {
+		return fallback
+	}
+	return l.current
+}
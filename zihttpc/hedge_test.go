@@ -0,0 +1,68 @@
+package zihttpc
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedgedTransport(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+				return
+			}
+			w.Write([]byte("slow"))
+			return
+		}
+		w.Write([]byte("fast"))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{
+		Transport: NewHedgedTransport(http.DefaultTransport, HedgeConfig{
+			Delay:       20 * time.Millisecond,
+			BudgetRatio: 1,
+		}),
+	}
+
+	t.Run("hedge wins over slow attempt", func(t *testing.T) {
+		start := time.Now()
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != "fast" {
+			t.Errorf("Body = %q, want %q", body, "fast")
+		}
+		if time.Since(start) > 500*time.Millisecond {
+			t.Error("Hedged request should not wait for the slow attempt")
+		}
+	})
+
+	t.Run("non idempotent requests are not hedged", func(t *testing.T) {
+		calls.Store(0)
+		resp, err := client.Post(srv.URL, "text/plain", nil)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != "slow" {
+			t.Errorf("Body = %q, want %q", body, "slow")
+		}
+		if calls.Load() != 1 {
+			t.Errorf("Calls = %d, want 1", calls.Load())
+		}
+	})
+}
@@ -0,0 +1,199 @@
+package zihttpc
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// NewTransport returns a clone of http.DefaultTransport dialing with dns when
+// not nil. Its connections are reported in the http_client_open_connections
+// gauge, by host and state (in_use, idle). HTTP/2 connections, multiplexing
+// the requests, are reported in use.
+func NewTransport(dns *DNSCache) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	dial := dialer.DialContext
+	if dns != nil {
+		dial = dns.DialContext(dialer)
+	}
+	t.DialContext = openConns.dial(dial)
+	return t
+}
+
+// openConns tracks the connections of the transports of NewTransport.
+var openConns = &connTracker{conns: map[*trackedConn]struct{}{}}
+
+type connTracker struct {
+	mu       sync.Mutex
+	conns    map[*trackedConn]struct{}
+	observed sync.Once
+}
+
+// trackedConn is a connection tracked by a connTracker, by the address
+// dialed.
+type trackedConn struct {
+	net.Conn
+	tracker *connTracker
+	addr    string
+	inUse   atomic.Bool
+	closed  sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.closed.Do(func() {
+		c.tracker.mu.Lock()
+		delete(c.tracker.conns, c)
+		c.tracker.mu.Unlock()
+	})
+	return c.Conn.Close()
+}
+
+// dial wraps dial so that its connections are tracked.
+func (t *connTracker) dial(dial func(ctx context.Context, network, address string) (net.Conn, error)) func(ctx context.Context, network, address string) (net.Conn, error) {
+	t.observed.Do(t.observe)
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		c := &trackedConn{Conn: conn, tracker: t, addr: address}
+		t.mu.Lock()
+		t.conns[c] = struct{}{}
+		t.mu.Unlock()
+		return c, nil
+	}
+}
+
+// observe reports the connections in the http_client_open_connections gauge.
+func (t *connTracker) observe() {
+	gauge, err := revelio.Int64ObservableGauge(
+		"http_client_open_connections",
+		"Number of open HTTP client connections, by host and state (in_use, idle)",
+	)
+	if err != nil {
+		otel.Handle(err)
+		return
+	}
+	_, err = revelio.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for addr, counts := range t.count() {
+			host := attribute.String("host", addr)
+			o.ObserveInt64(gauge, int64(counts[0]), metric.WithAttributes(host, attribute.String("state", "in_use")))
+			o.ObserveInt64(gauge, int64(counts[1]), metric.WithAttributes(host, attribute.String("state", "idle")))
+		}
+		return nil
+	}, gauge)
+	if err != nil {
+		otel.Handle(err)
+	}
+}
+
+// count returns the number of connections in use and idle, by address.
+func (t *connTracker) count() map[string][2]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counts := map[string][2]int{}
+	for c := range t.conns {
+		n := counts[c.addr]
+		if c.inUse.Load() {
+			n[0]++
+		} else {
+			n[1]++
+		}
+		counts[c.addr] = n
+	}
+	return counts
+}
+
+// tracked returns the trackedConn of conn, possibly wrapped in a *tls.Conn.
+func tracked(conn net.Conn) (*trackedConn, bool) {
+	if tc, ok := conn.(*tls.Conn); ok {
+		conn = tc.NetConn()
+	}
+	c, ok := conn.(*trackedConn)
+	return c, ok
+}
+
+// poolTransport records the connection pool metrics of the requests.
+type poolTransport struct {
+	base     http.RoundTripper
+	acquired metric.Int64Counter
+	dials    metric.Int64Counter
+	tls      revelio.DurationRecorder
+}
+
+func newPoolTransport(base http.RoundTripper) *poolTransport {
+	return &poolTransport{
+		base: base,
+		acquired: revelio.MustInt64Counter(
+			"http_client_connections_acquired_total",
+			"Number of connections acquired by HTTP client requests, by host and reuse",
+		),
+		dials: revelio.MustInt64Counter(
+			"http_client_dials_total",
+			"Number of connections dialed by HTTP clients, by host and status",
+		),
+		tls: revelio.MustDuration(
+			"http_client_tls_handshake_duration_ms",
+			"Duration of the TLS handshakes of HTTP clients in milliseconds, by host and status",
+		),
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *poolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	host := attribute.String("host", canonicalAddr(req.URL))
+	var (
+		conn     atomic.Pointer[trackedConn]
+		tlsStart time.Time
+	)
+	trace := &httptrace.ClientTrace{
+		ConnectDone: func(_, _ string, err error) {
+			t.dials.Add(ctx, 1, metric.WithAttributes(append([]attribute.KeyValue{host}, revelio.ResultAttributes(err)...)...))
+		},
+		TLSHandshakeStart: func() {
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			t.tls.Record(ctx, time.Since(tlsStart), append([]attribute.KeyValue{host}, revelio.ResultAttributes(err)...)...)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.acquired.Add(ctx, 1, metric.WithAttributes(host, attribute.Bool("reused", info.Reused)))
+			if c, ok := tracked(info.Conn); ok {
+				c.inUse.Store(true)
+				conn.Store(c)
+			}
+		},
+		PutIdleConn: func(err error) {
+			if c := conn.Load(); c != nil && err == nil {
+				c.inUse.Store(false)
+			}
+		},
+	}
+	return t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(ctx, trace)))
+}
+
+// canonicalAddr returns the host:port of u, the port defaulting to the port
+// of its scheme.
+func canonicalAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
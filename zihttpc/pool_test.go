@@ -0,0 +1,71 @@
+package zihttpc
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestPoolMetrics(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	port := u.Port()
+
+	// The DNS cache resolves the name of the server
+	dns := NewDNSCache(DNSConfig{}, func(ctx context.Context, host string) ([]netip.Addr, time.Duration, error) {
+		return []netip.Addr{netip.MustParseAddr("127.0.0.1")}, time.Minute, nil
+	})
+	transport := NewTransport(dns)
+	client := New(ClientConfig{}, WithTransport(transport))
+	for range 2 {
+		resp, err := client.Get("http://api.test:" + port)
+		if err != nil {
+			t.Fatalf("Get() = %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	host := attribute.String("host", "api.test:"+port)
+	reveliotest.AssertCounterValue(t, s, "http_client_dials_total", 1, host, attribute.String("status", "success"))
+	reveliotest.AssertCounterValue(t, s, "http_client_connections_acquired_total", 1, host, attribute.Bool("reused", false))
+	reveliotest.AssertCounterValue(t, s, "http_client_connections_acquired_total", 1, host, attribute.Bool("reused", true))
+	reveliotest.AssertCounterValue(t, s, "http_client_dns_lookups_total", 1, attribute.String("cache", "miss"))
+	if n := openConnections(t, s, host.Value.AsString(), "idle"); n != 1 {
+		t.Errorf("idle connections = %d, want 1", n)
+	}
+
+	transport.CloseIdleConnections()
+	if n := openConnections(t, s, host.Value.AsString(), "idle"); n != 0 {
+		t.Errorf("idle connections after closing them = %d, want 0", n)
+	}
+}
+
+func openConnections(t *testing.T, s *reveliotest.Scope, host, state string) int64 {
+	t.Helper()
+	m, ok := s.Metric(t, "http_client_open_connections")
+	if !ok {
+		return 0
+	}
+	for _, dp := range m.Data.(metricdata.Gauge[int64]).DataPoints {
+		h, _ := dp.Attributes.Value("host")
+		st, _ := dp.Attributes.Value("state")
+		if h.AsString() == host && st.AsString() == state {
+			return dp.Value
+		}
+	}
+	return 0
+}
@@ -0,0 +1,188 @@
+package zihttpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// RetryConfig holds configuration for retried requests.
+type RetryConfig struct {
+	// MaxAttempts is the maximum number of attempts, including the first one
+	// (default: 3).
+	MaxAttempts int `json:"max_attempts" yaml:"max_attempts"`
+
+	// Backoff is the base delay between attempts, doubled after every attempt
+	// with full jitter (default: 100ms).
+	Backoff time.Duration `json:"backoff" yaml:"backoff"`
+
+	// MaxBackoff caps the delay between attempts, including delays requested
+	// with a Retry-After header (default: 2s).
+	MaxBackoff time.Duration `json:"max_backoff" yaml:"max_backoff"`
+
+	// RetryOnStatus lists the response status codes worth retrying (default:
+	// 429, 502, 503, 504).
+	RetryOnStatus []int `json:"retry_on_status" yaml:"retry_on_status"`
+
+	// BudgetRatio, if greater than zero, is the maximum ratio of retries to
+	// requests, e.g. 0.2 allows at most one retry for every five requests
+	// beyond a burst of 10, so retries don't amplify the load of a failing
+	// upstream. Unlimited when zero.
+	BudgetRatio float64 `json:"budget_ratio" yaml:"budget_ratio"`
+}
+
+// DefaultRetryConfig returns the default configuration for retried requests.
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts: 3,
+		Backoff:     100 * time.Millisecond,
+		MaxBackoff:  2 * time.Second,
+		RetryOnStatus: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// retryTransport is an http.RoundTripper retrying failed requests.
+type retryTransport struct {
+	base    http.RoundTripper
+	config  RetryConfig
+	budget  *tokenBudget
+	counter metric.Int64Counter
+}
+
+// NewRetryTransport wraps base so idempotent requests are retried on transport
+// errors and on the configured status codes. Requests with a body are only
+// retried when the body can be replayed (GetBody is set).
+func NewRetryTransport(base http.RoundTripper, config RetryConfig) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	defaults := DefaultRetryConfig()
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaults.MaxAttempts
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = defaults.Backoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaults.MaxBackoff
+	}
+	if config.RetryOnStatus == nil {
+		config.RetryOnStatus = defaults.RetryOnStatus
+	}
+
+	counter := revelio.MustInt64Counter(
+		"http_client_retries_total",
+		"Number of retried HTTP client requests",
+	)
+
+	t := &retryTransport{
+		base:    base,
+		config:  config,
+		counter: counter,
+	}
+	if config.BudgetRatio > 0 {
+		// The budget starts full, so a cold client can still retry
+		t.budget = &tokenBudget{ratio: config.BudgetRatio, tokens: maxBudgetTokens}
+	}
+	return t
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.retryable(req) {
+		return t.base.RoundTrip(req)
+	}
+
+	if t.budget != nil {
+		t.budget.deposit()
+	}
+
+	ctx := req.Context()
+	hostAttr := metric.WithAttributes(attribute.String("host", req.URL.Host))
+
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(ctx)
+			attemptReq.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if attempt >= t.config.MaxAttempts || !t.shouldRetry(ctx, resp, err) {
+			return resp, err
+		}
+		if t.budget != nil && !t.budget.withdraw() {
+			return resp, err
+		}
+
+		delay := t.backoff(attempt, resp)
+		if resp != nil {
+			// Drain so the connection can be reused
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+
+		t.counter.Add(ctx, 1, hostAttr)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (t *retryTransport) retryable(req *http.Request) bool {
+	if !isIdempotent(req) {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	return true
+}
+
+func (t *retryTransport) shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		// Open breakers fail fast, retrying would only wait for them
+		return !errors.Is(err, ErrCircuitOpen)
+	}
+	return slices.Contains(t.config.RetryOnStatus, resp.StatusCode)
+}
+
+// backoff returns the delay before the next attempt, honoring Retry-After.
+func (t *retryTransport) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			return min(time.Duration(secs)*time.Second, t.config.MaxBackoff)
+		}
+	}
+
+	ceiling := t.config.Backoff << (attempt - 1)
+	if ceiling <= 0 || ceiling > t.config.MaxBackoff {
+		ceiling = t.config.MaxBackoff
+	}
+	return rand.N(ceiling) + 1
+}
@@ -0,0 +1,72 @@
+package zihttpc
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryTransport(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{
+		Transport: NewRetryTransport(http.DefaultTransport, RetryConfig{
+			MaxAttempts: 3,
+			Backoff:     time.Millisecond,
+		}),
+	}
+
+	t.Run("retries until success and replays the body", func(t *testing.T) {
+		calls.Store(0)
+		req, _ := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader("payload"))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK || string(body) != "payload" {
+			t.Errorf("got %d %q, want 200 %q", resp.StatusCode, body, "payload")
+		}
+		if calls.Load() != 3 {
+			t.Errorf("calls = %d, want 3", calls.Load())
+		}
+	})
+
+	t.Run("non idempotent requests are not retried", func(t *testing.T) {
+		calls.Store(0)
+		resp, err := client.Post(srv.URL, "text/plain", strings.NewReader("payload"))
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable || calls.Load() != 1 {
+			t.Errorf("got %d after %d calls, want 503 after 1 call", resp.StatusCode, calls.Load())
+		}
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		calls.Store(-10)
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable || calls.Load() != -7 {
+			t.Errorf("got %d after %d calls, want 503 after 3 calls", resp.StatusCode, calls.Load()+10)
+		}
+	})
+}
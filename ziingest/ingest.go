@@ -0,0 +1,442 @@
+// Package ziingest runs bulk imports of CSV files from object storage, e.g.
+// catalogs uploaded by merchants, without holding them in memory.
+//
+// The rows of a file are streamed from a zistorage.Bucket, parsed and
+// validated with zivalidator, the invalid rows being collected with their
+// errors, and the valid ones written by chunks, e.g. with InsertRows. A
+// checkpoint stored next to the file after every chunk records the progress,
+// so an interrupted import resumes from its last chunk when run again. The
+// errors are then uploaded as a CSV report next to the file, and the
+// Notifier is told about the outcome.
+package ziingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/divikraf/lumos/db/zisqlx"
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zistorage"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/divikraf/lumos/zivalidator"
+	"github.com/divikraf/lumos/ziworker"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const tracerName = "github.com/divikraf/lumos/ziingest"
+
+// Row results
+const (
+	ResultImported = "imported"
+	ResultRejected = "rejected"
+)
+
+var (
+	// ErrMissingColumns is returned for files missing columns of Job.Columns.
+	ErrMissingColumns = errors.New("ziingest: missing columns")
+	// ErrTooManyRejected is returned once more rows than Job.MaxRejected are
+	// rejected.
+	ErrTooManyRejected = errors.New("ziingest: too many rejected rows")
+)
+
+// Job is an import of rows of type T.
+type Job[T any] struct {
+	// Name identifies the kind of import in the telemetry, e.g. "catalog".
+	// It must come from a small bounded set.
+	Name string
+	// Key is the object key of the CSV file, identifying the import: an
+	// import of the same key resumes from its checkpoint.
+	Key string
+	// Columns are the columns the header of the file must have.
+	Columns []string
+	// Parse returns the value of a row. Its errors reject the row, against
+	// the column of a ColumnError.
+	Parse func(Row) (T, error)
+	// Write writes the valid rows of a chunk, e.g. InsertRows. Its errors
+	// fail the import, to be resumed from the chunk.
+	Write func(ctx context.Context, rows []T) error
+	// ChunkRows is the number of rows written and checkpointed at once
+	// (default: 1000).
+	ChunkRows int
+	// MaxRejected, if set, fails the import once more rows are rejected.
+	MaxRejected int64
+	// MaxReportErrors is the number of errors kept in the report (default:
+	// 10000). Result.Rejected counts all of them.
+	MaxReportErrors int
+	// ReportExpires, if set, is the validity of the signed URL of the error
+	// report in the Result.
+	ReportExpires time.Duration
+}
+
+func (j Job[T]) withDefaults() Job[T] {
+	if j.ChunkRows <= 0 {
+		j.ChunkRows = 1000
+	}
+	if j.MaxReportErrors <= 0 {
+		j.MaxReportErrors = 10000
+	}
+	return j
+}
+
+// RowError is an error of a rejected row.
+type RowError struct {
+	Line int `json:"line"`
+	// Field is the column or the field of the row in error, if known.
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// Result is the outcome of an import.
+type Result struct {
+	Name     string `json:"name"`
+	Key      string `json:"key"`
+	Imported int64  `json:"imported"`
+	Rejected int64  `json:"rejected"`
+	// ReportKey is the object key of the error report, when rows were
+	// rejected.
+	ReportKey string `json:"report_key,omitempty"`
+	// ReportURL is the signed URL of the error report, when
+	// Job.ReportExpires is set.
+	ReportURL string `json:"report_url,omitempty"`
+}
+
+// Checkpoint is the progress of an import, stored after every chunk.
+type Checkpoint struct {
+	// Rows is the number of rows of the file done.
+	Rows     int64      `json:"rows"`
+	Imported int64      `json:"imported"`
+	Rejected int64      `json:"rejected"`
+	Errors   []RowError `json:"errors"`
+}
+
+// Importer runs imports from a bucket.
+type Importer struct {
+	bucket   zistorage.Bucket
+	pool     *ziworker.Pool
+	validate zivalidator.Validate
+	notifier Notifier
+
+	rows     metric.Int64Counter
+	jobs     revelio.ResultCounter
+	duration revelio.DurationRecorder
+}
+
+// Option configures an Importer.
+type Option func(*Importer)
+
+// WithValidator sets the validator of the rows, skipping validation if nil.
+func WithValidator(v zivalidator.Validate) Option {
+	return func(i *Importer) {
+		i.validate = v
+	}
+}
+
+// WithNotifier sets the notifier of the outcome of the imports.
+func WithNotifier(n Notifier) Option {
+	return func(i *Importer) {
+		i.notifier = n
+	}
+}
+
+// New returns an Importer reading from bucket, running the imports started
+// in pool. The imports are recorded in the ingest_rows_total metric by
+// import and result (imported, rejected), and the ingest_jobs_total and
+// ingest_duration_ms metrics by import.
+func New(bucket zistorage.Bucket, pool *ziworker.Pool, opts ...Option) *Importer {
+	i := &Importer{
+		bucket:   bucket,
+		pool:     pool,
+		rows:     revelio.MustInt64Counter("ingest_rows_total", "Number of rows imported, by import and result"),
+		jobs:     revelio.MustResultCounter("ingest_jobs_total", "Number of imports, by import and status"),
+		duration: revelio.MustDuration("ingest_duration_ms", "Duration of imports in milliseconds, by import and status"),
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// Start queues job in the pool of imp, blocking while its queue is full until
+// ctx is done.
+func Start[T any](ctx context.Context, imp *Importer, job Job[T]) error {
+	return imp.pool.Submit(ctx, "ingest "+job.Name, func(ctx context.Context) error {
+		_, err := Run(ctx, imp, job)
+		return err
+	})
+}
+
+// Run runs job with imp, resuming it from its checkpoint if any, and notifies
+// its outcome. Once the file is done, the error report is uploaded and the
+// checkpoint deleted.
+func Run[T any](ctx context.Context, imp *Importer, job Job[T]) (result Result, err error) {
+	job = job.withDefaults()
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "ingest "+job.Name)
+	attrs := []attribute.KeyValue{attribute.String("ingest", job.Name)}
+	start := time.Now()
+	result = Result{Name: job.Name, Key: job.Key}
+	defer func() {
+		imp.duration.Record(ctx, time.Since(start), append(attrs, revelio.ResultAttributes(err)...)...)
+		imp.jobs.Record(ctx, err, attrs...)
+		if err != nil {
+			observe.RecordError(span, err)
+		}
+		span.End()
+		if imp.notifier != nil {
+			if nerr := imp.notifier.Notify(ctx, result, err); nerr != nil {
+				zilog.FromContext(ctx).Error().Err(nerr).Str("ingest", job.Name).Msg("Failed to notify import")
+			}
+		}
+	}()
+
+	cp, err := imp.loadCheckpoint(ctx, job.Key)
+	if err != nil {
+		return result, err
+	}
+	if cp.Rows > 0 {
+		zilog.FromContext(ctx).Info().Str("ingest", job.Name).Str("key", job.Key).Int64("rows", cp.Rows).Msg("Resuming import")
+	}
+	result.Imported, result.Rejected = cp.Imported, cp.Rejected
+
+	rc, _, err := imp.bucket.Get(ctx, job.Key)
+	if err != nil {
+		return result, err
+	}
+	defer rc.Close()
+	r, columns, err := openCSV(rc, job.Columns)
+	if err != nil {
+		return result, err
+	}
+	if err := skip(r, cp.Rows); err != nil {
+		return result, err
+	}
+	for done := false; !done; {
+		done, err = runChunk(ctx, imp, job, r, columns, &cp)
+		result.Imported, result.Rejected = cp.Imported, cp.Rejected
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if len(cp.Errors) > 0 {
+		result.ReportKey = reportKey(job.Key)
+		if err := imp.saveReport(ctx, result.ReportKey, cp.Errors); err != nil {
+			return result, err
+		}
+		if job.ReportExpires > 0 {
+			if result.ReportURL, err = imp.bucket.SignedURL(ctx, result.ReportKey, "GET", job.ReportExpires); err != nil {
+				return result, err
+			}
+		}
+	}
+	return result, imp.bucket.Delete(ctx, checkpointKey(job.Key))
+}
+
+// openCSV returns the reader of the rows of a CSV file and the index of its
+// columns, checking it has the required ones.
+func openCSV(rc io.Reader, required []string) (*csv.Reader, map[string]int, error) {
+	r := csv.NewReader(rc)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, nil, fmt.Errorf("%w: empty file", ErrMissingColumns)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, c := range header {
+		if i == 0 {
+			c = strings.TrimPrefix(c, "\ufeff")
+		}
+		columns[strings.TrimSpace(c)] = i
+	}
+	var missing []string
+	for _, c := range required {
+		if _, ok := columns[c]; !ok {
+			missing = append(missing, c)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, nil, fmt.Errorf("%w: %s", ErrMissingColumns, strings.Join(missing, ", "))
+	}
+	return r, columns, nil
+}
+
+// skip skips the n rows of r done before a resume.
+func skip(r *csv.Reader, n int64) error {
+	for range n {
+		if _, err := r.Read(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// runChunk imports the next chunk of rows of job, and checkpoints it. It
+// reports whether the file is exhausted.
+func runChunk[T any](ctx context.Context, imp *Importer, job Job[T], r *csv.Reader, columns map[string]int, cp *Checkpoint) (bool, error) {
+	var (
+		valid    []T
+		rows     int
+		rejected int64
+		done     bool
+	)
+	for rows < job.ChunkRows {
+		values, err := r.Read()
+		if err == io.EOF {
+			done = true
+			break
+		}
+		if err != nil {
+			return false, err
+		}
+		line, _ := r.FieldPos(0)
+		rows++
+		v, errs := parseRow(ctx, imp.validate, job, Row{Line: line, columns: columns, values: values})
+		if len(errs) > 0 {
+			rejected++
+			if room := job.MaxReportErrors - len(cp.Errors); room > 0 {
+				cp.Errors = append(cp.Errors, errs[:min(room, len(errs))]...)
+			}
+			continue
+		}
+		valid = append(valid, v)
+	}
+	if rows == 0 {
+		return true, nil
+	}
+
+	if len(valid) > 0 {
+		if err := job.Write(ctx, valid); err != nil {
+			return false, err
+		}
+	}
+	cp.Rows += int64(rows)
+	cp.Imported += int64(len(valid))
+	cp.Rejected += rejected
+	imp.rows.Add(ctx, int64(len(valid)), metric.WithAttributes(attribute.String("ingest", job.Name), attribute.String("result", ResultImported)))
+	imp.rows.Add(ctx, rejected, metric.WithAttributes(attribute.String("ingest", job.Name), attribute.String("result", ResultRejected)))
+	if err := imp.saveCheckpoint(ctx, job.Key, *cp); err != nil {
+		return false, err
+	}
+	zilog.FromContext(ctx).Debug().Str("ingest", job.Name).Str("key", job.Key).Int64("rows", cp.Rows).Msg("Import chunk written")
+	if job.MaxRejected > 0 && cp.Rejected > job.MaxRejected {
+		return false, fmt.Errorf("%w: %d", ErrTooManyRejected, cp.Rejected)
+	}
+	return done, nil
+}
+
+// parseRow returns the value of row, or its errors.
+func parseRow[T any](ctx context.Context, validate zivalidator.Validate, job Job[T], row Row) (T, []RowError) {
+	if len(row.values) != len(row.columns) {
+		var zero T
+		return zero, []RowError{{Line: row.Line, Message: fmt.Sprintf("%d fields, expected %d", len(row.values), len(row.columns))}}
+	}
+	v, err := job.Parse(row)
+	if err != nil {
+		var ce *ColumnError
+		if errors.As(err, &ce) {
+			return v, []RowError{{Line: row.Line, Field: ce.Column, Message: ce.Err.Error()}}
+		}
+		return v, []RowError{{Line: row.Line, Message: err.Error()}}
+	}
+	if validate == nil {
+		return v, nil
+	}
+	res := validate.ValidateStruct(ctx, v)
+	if res == nil {
+		return v, nil
+	}
+	if len(res.FieldErrors) == 0 {
+		return v, []RowError{{Line: row.Line, Message: res.Message}}
+	}
+	errs := make([]RowError, len(res.FieldErrors))
+	for i, fe := range res.FieldErrors {
+		errs[i] = RowError{Line: row.Line, Field: fe.Key, Message: fe.Msg}
+	}
+	return v, errs
+}
+
+// InsertRows returns a Job.Write inserting the rows of a chunk with insert in
+// a transaction of db, values returning the values of the columns of a row.
+// As a chunk interrupted after its write is written again on resume, insert
+// should be an upsert, see zisqlx.BatchInsert.Suffix.
+func InsertRows[T any](db zisqlx.TxBeginner, operationName string, insert zisqlx.BatchInsert, values func(T) []any) func(context.Context, []T) error {
+	return func(ctx context.Context, rows []T) error {
+		args := make([][]any, len(rows))
+		for i, r := range rows {
+			args[i] = values(r)
+		}
+		tx, err := db.BeginTx(ctx, operationName, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := insert.Exec(ctx, tx, operationName, args); err != nil {
+			return errors.Join(err, tx.Rollback())
+		}
+		return tx.Commit()
+	}
+}
+
+// saveReport uploads errs as a CSV report to key.
+func (i *Importer) saveReport(ctx context.Context, key string, errs []RowError) error {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"line", "field", "message"})
+	for _, e := range errs {
+		w.Write([]string{strconv.Itoa(e.Line), e.Field, e.Message})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	_, err := i.bucket.Put(ctx, key, &buf, zistorage.PutOptions{ContentType: "text/csv", Size: int64(buf.Len())})
+	return err
+}
+
+func (i *Importer) loadCheckpoint(ctx context.Context, key string) (Checkpoint, error) {
+	var cp Checkpoint
+	rc, _, err := i.bucket.Get(ctx, checkpointKey(key))
+	if errors.Is(err, zistorage.ErrObjectNotFound) {
+		return cp, nil
+	}
+	if err != nil {
+		return cp, err
+	}
+	defer rc.Close()
+	if err := json.NewDecoder(rc).Decode(&cp); err != nil {
+		return cp, fmt.Errorf("ziingest: invalid checkpoint of %s: %w", key, err)
+	}
+	return cp, nil
+}
+
+func (i *Importer) saveCheckpoint(ctx context.Context, key string, cp Checkpoint) error {
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	_, err = i.bucket.Put(ctx, checkpointKey(key), bytes.NewReader(b), zistorage.PutOptions{ContentType: "application/json", Size: int64(len(b))})
+	return err
+}
+
+func checkpointKey(key string) string {
+	return key + ".ingest.json"
+}
+
+func reportKey(key string) string {
+	return key + ".errors.csv"
+}
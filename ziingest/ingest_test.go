@@ -0,0 +1,174 @@
+package ziingest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/divikraf/lumos/db/zisqlx"
+	"github.com/divikraf/lumos/zistorage"
+	"github.com/divikraf/lumos/zivalidator"
+	"github.com/jmoiron/sqlx"
+)
+
+type product struct {
+	SKU   string  `validate:"required"`
+	Price float64 `validate:"gt=0"`
+}
+
+func parseProduct(r Row) (product, error) {
+	price, err := r.Float("price")
+	return product{SKU: r.Get("sku"), Price: price}, err
+}
+
+func newTestBucket(t *testing.T) zistorage.Bucket {
+	b, err := zistorage.NewFSBucket(zistorage.FSConfig{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func putObject(t *testing.T, b zistorage.Bucket, key, content string) {
+	t.Helper()
+	if _, err := b.Put(context.Background(), key, strings.NewReader(content), zistorage.PutOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readObject(t *testing.T, b zistorage.Bucket, key string) string {
+	t.Helper()
+	rc, _, err := b.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get(%s) = %v", key, err)
+	}
+	defer rc.Close()
+	content, _ := io.ReadAll(rc)
+	return string(content)
+}
+
+func TestRunResumes(t *testing.T) {
+	bucket := newTestBucket(t)
+	putObject(t, bucket, "uploads/catalog.csv", "sku,price\na,1\n,2\nc,x\nd,4\ne,-1\nf,6\n")
+	var notified []error
+	imp := New(bucket, nil, WithValidator(zivalidator.New()), WithNotifier(NotifierFunc(func(ctx context.Context, result Result, err error) error {
+		notified = append(notified, err)
+		return nil
+	})))
+
+	var written []product
+	fail := true
+	job := Job[product]{
+		Name:    "catalog",
+		Key:     "uploads/catalog.csv",
+		Columns: []string{"sku", "price"},
+		Parse:   parseProduct,
+		Write: func(ctx context.Context, rows []product) error {
+			if len(written) > 0 && fail {
+				fail = false
+				return errors.New("connection reset")
+			}
+			written = append(written, rows...)
+			return nil
+		},
+		ChunkRows: 3,
+	}
+	ctx := context.Background()
+
+	// The first run fails writing its second chunk
+	if _, err := Run(ctx, imp, job); err == nil {
+		t.Fatal("Run() succeeded with a failing write")
+	}
+	if _, err := bucket.Stat(ctx, "uploads/catalog.csv.ingest.json"); err != nil {
+		t.Fatalf("no checkpoint after a failure: %v", err)
+	}
+
+	// The second run resumes from the second chunk
+	result, err := Run(ctx, imp, job)
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if want := []product{{"a", 1}, {"d", 4}, {"f", 6}}; !reflect.DeepEqual(written, want) {
+		t.Errorf("written = %v, want %v", written, want)
+	}
+	if result.Imported != 3 || result.Rejected != 3 || result.ReportKey != "uploads/catalog.csv.errors.csv" {
+		t.Errorf("Result = %+v", result)
+	}
+	report := readObject(t, bucket, result.ReportKey)
+	for _, want := range []string{"line,field,message\n", "3,SKU,", "4,price,\"invalid number \"\"x\"\"\"\n", "6,Price,"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report =\n%s\nmissing %q", report, want)
+		}
+	}
+	if len(notified) != 2 || notified[0] == nil || notified[1] != nil {
+		t.Errorf("notifications = %v, want a failure then a success", notified)
+	}
+	if _, err := bucket.Stat(ctx, "uploads/catalog.csv.ingest.json"); !errors.Is(err, zistorage.ErrObjectNotFound) {
+		t.Errorf("checkpoint left after the import: %v", err)
+	}
+}
+
+func TestRunMissingColumns(t *testing.T) {
+	bucket := newTestBucket(t)
+	putObject(t, bucket, "catalog.csv", "\ufeffsku,name\na,b\n")
+	job := Job[product]{Name: "catalog", Key: "catalog.csv", Columns: []string{"sku", "price"}, Parse: parseProduct}
+	_, err := Run(context.Background(), New(bucket, nil), job)
+	if !errors.Is(err, ErrMissingColumns) || !strings.Contains(err.Error(), "price") {
+		t.Errorf("Run() = %v, want missing price", err)
+	}
+}
+
+func TestRunMaxRejected(t *testing.T) {
+	bucket := newTestBucket(t)
+	putObject(t, bucket, "catalog.csv", "sku,price\n,1\n,2\n")
+	job := Job[product]{
+		Name: "catalog", Key: "catalog.csv", Parse: parseProduct, MaxRejected: 1,
+		Write: func(ctx context.Context, rows []product) error { return nil },
+	}
+	_, err := Run(context.Background(), New(bucket, nil, WithValidator(zivalidator.New())), job)
+	if !errors.Is(err, ErrTooManyRejected) {
+		t.Errorf("Run() = %v, want ErrTooManyRejected", err)
+	}
+}
+
+// txDB records the statements of its transactions.
+type txDB struct {
+	queries   []string
+	committed int
+}
+
+func (d *txDB) BeginTx(ctx context.Context, operationName string, opts *sql.TxOptions) (zisqlx.TxInterface, error) {
+	return &txRecorder{db: d}, nil
+}
+
+type txRecorder struct {
+	zisqlx.TxInterface
+	db *txDB
+}
+
+func (tx *txRecorder) ExecContext(ctx context.Context, operationName string, query string, args ...any) (sql.Result, error) {
+	tx.db.queries = append(tx.db.queries, query)
+	return nil, nil
+}
+
+func (tx *txRecorder) Commit() error {
+	tx.db.committed++
+	return nil
+}
+
+func TestInsertRows(t *testing.T) {
+	db := &txDB{}
+	write := InsertRows(db, "import_products", zisqlx.BatchInsert{Table: "products", Columns: []string{"sku", "price"}, BindType: sqlx.QUESTION},
+		func(p product) []any { return []any{p.SKU, p.Price} })
+	if err := write(context.Background(), []product{{"a", 1}, {"b", 2}}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"INSERT INTO products (sku, price) VALUES (?, ?), (?, ?)"}
+	if !reflect.DeepEqual(db.queries, want) || db.committed != 1 {
+		t.Errorf("queries = %q committed %d times, want %q committed once", db.queries, db.committed, want)
+	}
+}
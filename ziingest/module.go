@@ -0,0 +1,61 @@
+package ziingest
+
+import (
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zistorage"
+	"github.com/divikraf/lumos/zivalidator"
+	"github.com/divikraf/lumos/ziworker"
+	"go.uber.org/fx"
+)
+
+// Config configures the Importer.
+type Config struct {
+	// Bucket is the name of the bucket of the imported files, see
+	// zistorage.Config.
+	Bucket string `json:"bucket" yaml:"bucket" validate:"required"`
+	// WebhookURL, if set, is notified of the outcome of the imports, see
+	// NewWebhookNotifier.
+	WebhookURL string `json:"webhook_url" yaml:"webhook_url"`
+	// WebhookDryRun, if set, logs the notifications of WebhookURL instead
+	// of posting them, see NewDryRunWebhookNotifier.
+	WebhookDryRun bool `json:"webhook_dry_run" yaml:"webhook_dry_run"`
+}
+
+// ingestConfig is implemented by configurations of the imports.
+type ingestConfig interface {
+	GetIngest() Config
+}
+
+type NewConfiguredImporterParams struct {
+	fx.In
+	Config   ziconf.Config
+	Buckets  zistorage.Buckets
+	Pool     *ziworker.Pool
+	Validate zivalidator.Validate `optional:"true"`
+	Notifier Notifier             `optional:"true"`
+}
+
+// NewConfiguredImporter returns the Importer configured by the ingest
+// config, running the imports in the default pool and validating the rows
+// with the provided validator, if any. The provided Notifier, if any, takes
+// precedence over the webhook of the config, dry-run or not.
+func NewConfiguredImporter(params NewConfiguredImporterParams) (*Importer, error) {
+	var config Config
+	if c, ok := params.Config.(ingestConfig); ok {
+		config = c.GetIngest()
+	}
+	bucket, err := params.Buckets.Bucket(config.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	opts := []Option{WithValidator(params.Validate)}
+	switch {
+	case params.Notifier != nil:
+		opts = append(opts, WithNotifier(params.Notifier))
+	case config.WebhookURL != "" && config.WebhookDryRun:
+		opts = append(opts, WithNotifier(NewDryRunWebhookNotifier(config.WebhookURL)))
+	case config.WebhookURL != "":
+		opts = append(opts, WithNotifier(NewWebhookNotifier(config.WebhookURL, nil)))
+	}
+	return New(bucket, params.Pool, opts...), nil
+}
@@ -0,0 +1,36 @@
+package ziingest
+
+import (
+	"net/http"
+
+	"github.com/divikraf/lumos/internal/webhook"
+)
+
+// Notifier is told about the outcome of the imports, e.g. to send the error
+// report to the user uploading the file.
+type Notifier = webhook.Notifier[Result]
+
+// NotifierFunc is a Notifier function.
+type NotifierFunc = webhook.NotifierFunc[Result]
+
+// Notification statuses
+const (
+	StatusCompleted = webhook.StatusCompleted
+	StatusFailed    = webhook.StatusFailed
+)
+
+// NewWebhookNotifier returns a Notifier posting the results in JSON to url
+// with client, or a client of zihttpc.New if nil, e.g.
+//
+//	{"name": "catalog", "key": "uploads/1.csv", "imported": 40, "rejected": 2, "report_key": "uploads/1.csv.errors.csv", "status": "completed"}
+func NewWebhookNotifier(url string, client *http.Client) Notifier {
+	return webhook.NewNotifier[Result](webhook.NewSender("ziingest", client, false), url)
+}
+
+// NewDryRunWebhookNotifier returns a Notifier logging the results it would
+// post to url instead of posting them, e.g. in staging environments. The
+// notifications are measured like those of NewWebhookNotifier, tagged
+// dry_run=true.
+func NewDryRunWebhookNotifier(url string) Notifier {
+	return webhook.NewNotifier[Result](webhook.NewSender("ziingest", nil, true), url)
+}
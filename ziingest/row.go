@@ -0,0 +1,78 @@
+package ziingest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Row is a row of an imported file.
+type Row struct {
+	// Line is the line of the row in the file, the header being line 1.
+	Line    int
+	columns map[string]int
+	values  []string
+}
+
+// Get returns the trimmed value of column, or "" if the row has none.
+func (r Row) Get(column string) string {
+	i, ok := r.columns[column]
+	if !ok || i >= len(r.values) {
+		return ""
+	}
+	return strings.TrimSpace(r.values[i])
+}
+
+// Int returns the value of column as an int64, 0 if empty.
+func (r Row) Int(column string) (int64, error) {
+	v := r.Get(column)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, &ColumnError{Column: column, Err: fmt.Errorf("invalid integer %q", v)}
+	}
+	return n, nil
+}
+
+// Float returns the value of column as a float64, 0 if empty.
+func (r Row) Float(column string) (float64, error) {
+	v := r.Get(column)
+	if v == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, &ColumnError{Column: column, Err: fmt.Errorf("invalid number %q", v)}
+	}
+	return f, nil
+}
+
+// Bool returns the value of column as a bool, false if empty.
+func (r Row) Bool(column string) (bool, error) {
+	v := r.Get(column)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, &ColumnError{Column: column, Err: fmt.Errorf("invalid boolean %q", v)}
+	}
+	return b, nil
+}
+
+// ColumnError is an invalid value of a column, reported against the column
+// in the error report when returned by Job.Parse.
+type ColumnError struct {
+	Column string
+	Err    error
+}
+
+func (e *ColumnError) Error() string {
+	return e.Column + ": " + e.Err.Error()
+}
+
+func (e *ColumnError) Unwrap() error {
+	return e.Err
+}
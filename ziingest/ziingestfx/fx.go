@@ -0,0 +1,11 @@
+package ziingestfx
+
+import (
+	"github.com/divikraf/lumos/ziingest"
+	"go.uber.org/fx"
+)
+
+// Provider provides the *ziingest.Importer configured by the ingest config,
+// given the zistorage.Buckets and the default *ziworker.Pool, see
+// zistoragefx.BucketsProvider and ziworkerfx.Provider.
+var Provider = fx.Provide(ziingest.NewConfiguredImporter)
@@ -0,0 +1,88 @@
+package zijwt
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/credentials"
+)
+
+// JWKSPath is the path of the endpoint serving the public keys of the tokens.
+const JWKSPath = "/.well-known/jwks.json"
+
+// Transport returns a transport setting the bearer token of target on the
+// requests sent by base (default: http.DefaultTransport), e.g. with
+//
+//	zihttpc.New(config, zihttpc.WithTransport(source.Transport("payments", zihttpc.NewTransport(nil))))
+func (s *TokenSource) Transport(target string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{source: s, target: target, base: base}
+}
+
+type transport struct {
+	source *TokenSource
+	target string
+	base   http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.source.Token(req.Context(), t.target)
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	// RoundTrippers must not modify the request
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(req)
+}
+
+// PerRPCCredentials returns the credentials setting the bearer token of
+// target on the calls of a gRPC connection, e.g. with
+//
+//	clients.Conn("orders", grpc.WithPerRPCCredentials(source.PerRPCCredentials("orders")))
+//
+// The connection must use TLS, unless Insecure is configured.
+func (s *TokenSource) PerRPCCredentials(target string) credentials.PerRPCCredentials {
+	return &rpcCredentials{source: s, target: target}
+}
+
+type rpcCredentials struct {
+	source *TokenSource
+	target string
+}
+
+func (c *rpcCredentials) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	token, err := c.source.Token(ctx, c.target)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+func (c *rpcCredentials) RequireTransportSecurity() bool {
+	return !c.source.config.Insecure
+}
+
+// RegisterRoutes mounts the JWKS endpoint on router: GET JWKSPath serves the
+// public keys of the tokens, the current one and the one it rotated, for the
+// target services to verify them with zin/auth.NewJWKS.
+func (s *TokenSource) RegisterRoutes(router gin.IRouter) {
+	router.GET(JWKSPath, func(ctx *gin.Context) {
+		s.mu.Lock()
+		keys := make([]map[string]string, len(s.public))
+		for i, k := range s.public {
+			jwk := k.jwk()
+			jwk["kid"], jwk["alg"], jwk["use"] = k.id, k.alg, "sig"
+			keys[i] = jwk
+		}
+		s.mu.Unlock()
+		ctx.Header("Cache-Control", "public, max-age=300")
+		ctx.JSON(http.StatusOK, gin.H{"keys": keys})
+	})
+}
@@ -0,0 +1,155 @@
+package zijwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/divikraf/lumos/zin/auth"
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"github.com/gin-gonic/gin"
+)
+
+func TestTransport(t *testing.T) {
+	reveliotest.NewDefaultTestScope(t)
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	source, err := NewTokenSource(Config{Issuer: "orders", PrivateKey: pemKey(t, key, false)})
+	if err != nil {
+		t.Fatalf("NewTokenSource() = %v", err)
+	}
+	validator := auth.NewValidator(auth.PublicKey(&key.PublicKey), auth.Config{Issuer: "orders", Audience: []string{"payments"}})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := validator.Validate(r.Context(), strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: source.Transport("payments", srv.Client().Transport)}
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want the token of payments accepted", resp.StatusCode)
+	}
+	if req.Header.Get("Authorization") != "" {
+		t.Error("request of the caller modified")
+	}
+
+	client.Transport = source.Transport("billing", srv.Client().Transport)
+	resp, err = client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want the token of billing rejected", resp.StatusCode)
+	}
+}
+
+func TestPerRPCCredentials(t *testing.T) {
+	reveliotest.NewDefaultTestScope(t)
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	source, err := NewTokenSource(Config{Issuer: "orders", PrivateKey: pemKey(t, key, false)})
+	if err != nil {
+		t.Fatalf("NewTokenSource() = %v", err)
+	}
+	ctx := context.Background()
+	creds := source.PerRPCCredentials("inventory")
+	md, err := creds.GetRequestMetadata(ctx, "dns:///inventory:443")
+	if err != nil {
+		t.Fatalf("GetRequestMetadata() = %v", err)
+	}
+	token, _ := source.Token(ctx, "inventory")
+	if md["authorization"] != "Bearer "+token {
+		t.Errorf("metadata = %v, want the bearer token of inventory", md)
+	}
+	if !creds.RequireTransportSecurity() {
+		t.Error("RequireTransportSecurity() = false, want TLS unless insecure")
+	}
+	source.config.Insecure = true
+	if creds.RequireTransportSecurity() {
+		t.Error("RequireTransportSecurity() = true, want plaintext allowed when insecure")
+	}
+}
+
+func TestJWKSRoutes(t *testing.T) {
+	reveliotest.NewDefaultTestScope(t)
+	gin.SetMode(gin.TestMode)
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	source, err := NewTokenSource(Config{Issuer: "orders", PrivateKey: pemKey(t, oldKey, false)})
+	if err != nil {
+		t.Fatalf("NewTokenSource() = %v", err)
+	}
+	router := gin.New()
+	source.RegisterRoutes(router)
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+	ctx := context.Background()
+
+	old, _ := source.Token(ctx, "payments")
+	if err := source.SetPrivateKey(pemKey(t, newKey, true)); err != nil {
+		t.Fatalf("SetPrivateKey() = %v", err)
+	}
+	token, _ := source.Token(ctx, "payments")
+
+	// The tokens signed by the rotated key and by the previous one verify
+	validator := auth.NewValidator(auth.NewJWKS(srv.URL+JWKSPath), auth.Config{Issuer: "orders", Audience: []string{"payments"}})
+	for name, token := range map[string]string{"previous key": old, "rotated key": token} {
+		if _, err := validator.Validate(ctx, token); err != nil {
+			t.Errorf("Validate(token of the %s) = %v", name, err)
+		}
+	}
+}
+
+func TestJWKSRoutesKeyID(t *testing.T) {
+	reveliotest.NewDefaultTestScope(t)
+	gin.SetMode(gin.TestMode)
+	oldKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	source, err := NewTokenSource(Config{Issuer: "orders", KeyID: "orders", PrivateKey: pemKey(t, oldKey, false)})
+	if err != nil {
+		t.Fatalf("NewTokenSource() = %v", err)
+	}
+	router := gin.New()
+	source.RegisterRoutes(router)
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+	ctx := context.Background()
+
+	old, _ := source.Token(ctx, "payments")
+	// Setting the current key again keeps the tokens
+	if err := source.SetPrivateKey(pemKey(t, oldKey, true)); err != nil {
+		t.Fatalf("SetPrivateKey() = %v", err)
+	}
+	if token, _ := source.Token(ctx, "payments"); token != old {
+		t.Error("token minted again with the same key")
+	}
+	if err := source.SetPrivateKey(pemKey(t, newKey, false)); err != nil {
+		t.Fatalf("SetPrivateKey() = %v", err)
+	}
+	token, _ := source.Token(ctx, "payments")
+
+	if len(source.public) != 2 {
+		t.Fatalf("%d public keys, want the rotated key and the previous one", len(source.public))
+	}
+	if ids := []string{source.public[0].id, source.public[1].id}; ids[0] == ids[1] || !strings.HasPrefix(ids[0], "orders-") || !strings.HasPrefix(ids[1], "orders-") {
+		t.Errorf("key IDs = %q, want distinct IDs prefixed with the configured one", ids)
+	}
+	validator := auth.NewValidator(auth.NewJWKS(srv.URL+JWKSPath), auth.Config{Issuer: "orders", Audience: []string{"payments"}})
+	for name, token := range map[string]string{"previous key": old, "rotated key": token} {
+		if _, err := validator.Validate(ctx, token); err != nil {
+			t.Errorf("Validate(token of the %s) = %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+// Package zijwt mints the short-lived JWTs services authenticate each other
+// with, replacing the static API keys of their configs: the calling service
+// signs a token per target service with its private key, typically a
+// zisecrets reference, and the target verifies it with the public keys the
+// caller serves as a JWKS, see zin/auth.
+package zijwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zisecrets"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const instrumentationName = "github.com/divikraf/lumos/zijwt"
+
+var scope = revelio.Extend(revelio.Library(instrumentationName))
+
+// Config configures a TokenSource.
+type Config struct {
+	// Issuer is the iss and sub claims of the tokens, the name of the
+	// calling service.
+	Issuer string `json:"issuer" yaml:"issuer" validate:"required"`
+	// PrivateKey is the PEM private key signing the tokens, RSA or ECDSA,
+	// typically a zisecrets reference, e.g.
+	// vault://secret/data/orders#jwt_private_key.
+	PrivateKey string `json:"private_key" yaml:"private_key" validate:"required"`
+	// KeyID prefixes the kid header of the tokens, suffixed with the start of
+	// the JWK thumbprint of the key, for the keys it rotates to be told apart
+	// (default: the JWK thumbprint of the key).
+	KeyID string `json:"key_id" yaml:"key_id"`
+	// TTL is the lifetime of the tokens (default: 5m).
+	TTL time.Duration `json:"ttl" yaml:"ttl"`
+	// Audiences are the aud claims of the tokens, by target service
+	// (default: the name of the target).
+	Audiences map[string]string `json:"audiences" yaml:"audiences"`
+	// Insecure allows sending the tokens to gRPC targets over plaintext
+	// connections, e.g. within a mesh encrypting them.
+	Insecure bool `json:"insecure" yaml:"insecure"`
+}
+
+// signingKey is a private key, and the algorithm of its signatures.
+type signingKey struct {
+	id     string
+	alg    string
+	hash   crypto.Hash
+	signer crypto.Signer
+}
+
+type token struct {
+	raw       string
+	expiresAt time.Time
+}
+
+// TokenSource mints the tokens of the calls to the target services, cached
+// by audience and minted again once a fifth of their lifetime is left, so
+// they never expire in flight.
+type TokenSource struct {
+	config Config
+	now    func() time.Time
+
+	mu     sync.Mutex
+	key    *signingKey
+	public []*signingKey
+	tokens map[string]token
+
+	minted revelio.ResultCounter
+}
+
+// NewTokenSource returns the TokenSource of config. The tokens minted are
+// counted in the jwt_tokens_minted_total metric by audience and status.
+func NewTokenSource(config Config) (*TokenSource, error) {
+	if config.Issuer == "" {
+		return nil, errors.New("zijwt: no issuer configured")
+	}
+	if config.TTL <= 0 {
+		config.TTL = 5 * time.Minute
+	}
+	s := &TokenSource{
+		config: config,
+		now:    time.Now,
+		tokens: map[string]token{},
+		minted: revelio.Must(scope.ResultCounter("jwt_tokens_minted_total", "Number of service tokens minted, by audience and status")),
+	}
+	if err := s.SetPrivateKey(config.PrivateKey); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Audience returns the audience of the tokens of target.
+func (s *TokenSource) Audience(target string) string {
+	if aud, ok := s.config.Audiences[target]; ok {
+		return aud
+	}
+	return target
+}
+
+// Token returns a token of the calls to target, minted unless cached.
+func (s *TokenSource) Token(ctx context.Context, target string) (string, error) {
+	aud := s.Audience(target)
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.tokens[aud]; ok && now.Before(t.expiresAt.Add(-s.config.TTL/5)) {
+		return t.raw, nil
+	}
+	raw, err := s.mint(aud, now)
+	s.minted.Record(ctx, err, attribute.String("audience", aud))
+	if err != nil {
+		return "", fmt.Errorf("zijwt: failed to mint a token for %s: %w", aud, err)
+	}
+	s.tokens[aud] = token{raw: raw, expiresAt: now.Add(s.config.TTL)}
+	return raw, nil
+}
+
+// mint signs a token of aud issued at now. s.mu must be held.
+func (s *TokenSource) mint(aud string, now time.Time) (string, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	header, err := json.Marshal(map[string]string{"alg": s.key.alg, "kid": s.key.id, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss": s.config.Issuer,
+		"sub": s.config.Issuer,
+		"aud": aud,
+		"iat": now.Unix(),
+		"exp": now.Add(s.config.TTL).Unix(),
+		"jti": hex.EncodeToString(jti),
+	})
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	sig, err := s.key.sign(signed)
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// SetPrivateKey replaces the key signing the tokens by the PEM key, e.g.
+// once rotated, and drops the tokens minted with the previous one, which is
+// still served in the JWKS for the tokens in flight to verify. Setting the
+// current key again changes nothing.
+func (s *TokenSource) SetPrivateKey(key string) error {
+	k, err := parseKey(key, s.config.KeyID)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.key != nil && s.key.equal(k) {
+		return nil
+	}
+	public := []*signingKey{k}
+	if s.key != nil {
+		public = append(public, s.key)
+	}
+	s.key, s.public = k, public
+	clear(s.tokens)
+	return nil
+}
+
+// OnRotate returns the callback of zisecrets.Resolver.OnRotate setting the
+// private key to the rotated secret of field, the path of the PrivateKey of
+// the config, e.g. "jwt.private_key".
+func (s *TokenSource) OnRotate(field string) func(ctx context.Context, rotation zisecrets.Rotation) {
+	return func(ctx context.Context, rotation zisecrets.Rotation) {
+		if rotation.Field != field {
+			return
+		}
+		if err := s.SetPrivateKey(rotation.Value); err != nil {
+			zilog.FromContext(ctx).Error().Err(err).Str("field", field).Msg("Failed to rotate the service token key")
+		}
+	}
+}
+
+// parseKey parses the PEM private key, PKCS #8, PKCS #1 or SEC 1, identified
+// by its thumbprint, prefixed with kid if set.
+func parseKey(key, kid string) (*signingKey, error) {
+	block, _ := pem.Decode([]byte(key))
+	if block == nil {
+		return nil, errors.New("zijwt: private key is not PEM encoded")
+	}
+	var (
+		parsed any
+		err    error
+	)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("zijwt: invalid private key: %w", err)
+	}
+
+	k := &signingKey{}
+	switch parsed := parsed.(type) {
+	case *rsa.PrivateKey:
+		k.alg, k.hash, k.signer = "RS256", crypto.SHA256, parsed
+	case *ecdsa.PrivateKey:
+		switch parsed.Curve {
+		case elliptic.P256():
+			k.alg, k.hash = "ES256", crypto.SHA256
+		case elliptic.P384():
+			k.alg, k.hash = "ES384", crypto.SHA384
+		case elliptic.P521():
+			k.alg, k.hash = "ES512", crypto.SHA512
+		default:
+			return nil, fmt.Errorf("zijwt: unsupported curve %s", parsed.Curve.Params().Name)
+		}
+		k.signer = parsed
+	default:
+		return nil, fmt.Errorf("zijwt: unsupported private key %T", parsed)
+	}
+	if thumbprint := k.thumbprint(); kid == "" {
+		k.id = thumbprint
+	} else {
+		k.id = kid + "-" + thumbprint[:8]
+	}
+	return k, nil
+}
+
+// equal reports whether k and other are the same key.
+func (k *signingKey) equal(other *signingKey) bool {
+	public, ok := k.signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	return ok && public.Equal(other.signer.Public())
+}
+
+// sign returns the signature of signed.
+func (k *signingKey) sign(signed string) ([]byte, error) {
+	h := k.hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+	switch key := k.signer.(type) {
+	case *rsa.PrivateKey:
+		return rsa.SignPKCS1v15(rand.Reader, key, k.hash, digest)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+		if err != nil {
+			return nil, err
+		}
+		// JWS signatures are the fixed size r and s, see RFC 7518
+		size := (key.Curve.Params().BitSize + 7) / 8
+		sig := make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+		return sig, nil
+	}
+	return nil, fmt.Errorf("zijwt: unsupported private key %T", k.signer)
+}
+
+// jwk returns the public JWK of the key, see RFC 7517.
+func (k *signingKey) jwk() map[string]string {
+	switch key := k.signer.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return map[string]string{
+			"kty": "EC",
+			"crv": key.Curve.Params().Name,
+			"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size))),
+			"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size))),
+		}
+	}
+	return nil
+}
+
+// thumbprint returns the JWK thumbprint of the key, see RFC 7638: the hash of
+// its required members, marshalled with sorted keys.
+func (k *signingKey) thumbprint() string {
+	b, _ := json.Marshal(k.jwk())
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
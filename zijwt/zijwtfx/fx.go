@@ -0,0 +1,56 @@
+package zijwtfx
+
+import (
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zijwt"
+	"github.com/divikraf/lumos/zin"
+	"github.com/divikraf/lumos/zin/zinfx"
+	"github.com/divikraf/lumos/zisecrets"
+	"go.uber.org/fx"
+)
+
+// PrivateKeyField is the path of the private key in the configs, as
+// reported by the rotations of zisecrets.
+const PrivateKeyField = "jwt.private_key"
+
+// jwtConfig is implemented by configs of services calling others with
+// service tokens, their JWT config under the "jwt" key.
+type jwtConfig interface {
+	GetJWT() zijwt.Config
+}
+
+type sourceParams struct {
+	fx.In
+
+	Config   ziconf.Config
+	Resolver *zisecrets.Resolver `optional:"true"`
+}
+
+// Provider provides the *zijwt.TokenSource of the config, rotating its key
+// with the private key of the config when a *zisecrets.Resolver is
+// provided, see zisecretsfx.WithConfig.
+var Provider = fx.Provide(
+	func(params sourceParams) (*zijwt.TokenSource, error) {
+		var config zijwt.Config
+		if jc, ok := params.Config.(jwtConfig); ok {
+			config = jc.GetJWT()
+		}
+		source, err := zijwt.NewTokenSource(config)
+		if err != nil {
+			return nil, err
+		}
+		if params.Resolver != nil {
+			params.Resolver.OnRotate(source.OnRotate(PrivateKeyField))
+		}
+		return source, nil
+	},
+)
+
+// JWKSRoutes mounts the JWKS endpoint of the token source on the additional
+// server name, or on the main router when empty, see zijwt.JWKSPath.
+func JWKSRoutes(server string) fx.Option {
+	return fx.Provide(fx.Annotate(
+		func(s *zijwt.TokenSource) zin.RouteRegistrar { return s },
+		fx.ResultTags(zinfx.RoutesGroup(server)),
+	))
+}
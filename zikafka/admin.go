@@ -0,0 +1,64 @@
+package zikafka
+
+import (
+	"github.com/divikraf/lumos/zin"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminPath is the path of the consumers admin endpoints.
+const AdminPath = "/kafka/consumers"
+
+// ConsumerInfo describes a consumer, as listed by the admin endpoint.
+type ConsumerInfo struct {
+	Name   string   `json:"name"`
+	Group  string   `json:"group"`
+	Topics []string `json:"topics"`
+	Paused bool     `json:"paused"`
+	// Lag is the lag of the partitions, by topic and partition.
+	Lag map[string]map[int32]int64 `json:"lag"`
+}
+
+func consumerInfo(c *Consumer) ConsumerInfo {
+	return ConsumerInfo{
+		Name:   c.name,
+		Group:  c.config.Group,
+		Topics: c.config.Topics,
+		Paused: c.Paused(),
+		Lag:    c.Lag(),
+	}
+}
+
+// RegisterRoutes mounts the admin endpoints on router, best on an internal
+// server:
+//
+//	GET  AdminPath                 lists the consumers, with their lag
+//	POST AdminPath/:name/pause     pauses a consumer, see Consumer.Pause
+//	POST AdminPath/:name/resume    resumes a consumer
+//
+// Consumers are paused on the instance serving the request only.
+func (cs *Consumers) RegisterRoutes(router gin.IRouter) {
+	router.GET(AdminPath, func(ctx *gin.Context) {
+		infos := make([]ConsumerInfo, len(cs.consumers))
+		for i, c := range cs.consumers {
+			infos[i] = consumerInfo(c)
+		}
+		zin.OK(ctx, gin.H{"consumers": infos})
+	})
+	toggle := func(pause bool) gin.HandlerFunc {
+		return func(ctx *gin.Context) {
+			c, ok := cs.Consumer(ctx.Param("name"))
+			if !ok {
+				zin.AbortWithError(ctx, zin.ErrNotFound)
+				return
+			}
+			if pause {
+				c.Pause()
+			} else {
+				c.Resume()
+			}
+			zin.OK(ctx, consumerInfo(c))
+		}
+	}
+	router.POST(AdminPath+"/:name/pause", toggle(true))
+	router.POST(AdminPath+"/:name/resume", toggle(false))
+}
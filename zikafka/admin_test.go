@@ -0,0 +1,77 @@
+package zikafka
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/divikraf/lumos/zin"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+func TestAdminRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := zerolog.Nop()
+	group := &fakeGroup{batches: make(chan []*Record), committed: map[string]map[int32]int64{}}
+	join := func(ConsumerConfig, RevokedFunc) (GroupClient, error) { return group, nil }
+	consumer, err := NewConsumer("orders", ConsumerConfig{Group: "billing", Topics: []string{"orders"}}, join, nil, nil, &logger)
+	if err != nil {
+		t.Fatalf("Failed to create consumer: %v", err)
+	}
+	// The consumer isn't run, which unregisters its lag
+	defer consumer.lag.Unregister()
+	consumer.setLag(&Record{Topic: "orders", Partition: 2, Offset: 4, HighWatermark: 10})
+	cs := &Consumers{consumers: []*Consumer{consumer}}
+
+	router := gin.New()
+	router.Use(zin.ErrorMiddleware())
+	cs.RegisterRoutes(router)
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	if w := do(http.MethodPost, AdminPath+"/orders/pause"); w.Code != http.StatusOK {
+		t.Fatalf("POST pause = %d %s", w.Code, w.Body)
+	}
+	if !consumer.Paused() {
+		t.Error("consumer not paused by the admin endpoint")
+	}
+	if err := cs.Check(context.Background()); err == nil {
+		t.Error("Check() = nil, want the paused consumer to fail the readiness")
+	}
+	if w := do(http.MethodPost, AdminPath+"/unknown/pause"); w.Code != http.StatusNotFound {
+		t.Errorf("POST pause of an unknown consumer = %d, want 404", w.Code)
+	}
+
+	w := do(http.MethodGet, AdminPath)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s = %d %s", AdminPath, w.Code, w.Body)
+	}
+	var body struct {
+		Data struct {
+			Consumers []ConsumerInfo `json:"consumers"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if got := body.Data.Consumers; len(got) != 1 || got[0].Name != "orders" || got[0].Group != "billing" ||
+		!got[0].Paused || got[0].Lag["orders"][2] != 5 {
+		t.Errorf("consumers = %+v, want orders paused with a lag of 5 on partition 2", got)
+	}
+
+	if w := do(http.MethodPost, AdminPath+"/orders/resume"); w.Code != http.StatusOK {
+		t.Fatalf("POST resume = %d %s", w.Code, w.Body)
+	}
+	if consumer.Paused() {
+		t.Error("consumer still paused once resumed by the admin endpoint")
+	}
+	if err := cs.Check(context.Background()); err != nil {
+		t.Errorf("Check() = %v, want nil once resumed", err)
+	}
+}
@@ -0,0 +1,489 @@
+package zikafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/divikraf/lumos/internal/wait"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Headers of the dead-lettered records, locating the original record.
+const (
+	HeaderDeadLetterError     = "x-dead-letter-error"
+	HeaderDeadLetterTopic     = "x-dead-letter-topic"
+	HeaderDeadLetterPartition = "x-dead-letter-partition"
+	HeaderDeadLetterOffset    = "x-dead-letter-offset"
+)
+
+// Handler handles a record.
+type Handler func(ctx context.Context, record *Record) error
+
+// JoinFunc joins a consumer group, calling revoked before partitions are
+// revoked, e.g. a Dialer.Join bound to a config.
+type JoinFunc func(consumer ConsumerConfig, revoked RevokedFunc) (GroupClient, error)
+
+// Consumer consumes the records of a consumer group. Records are processed
+// in order per key and concurrently across keys, and offsets are committed
+// up to the oldest record not processed yet, at least once.
+//
+// Failing records are retried with backoff, then sent to the dead letter
+// topic if configured. Before partitions are revoked, their records in
+// flight are processed and committed, so the next owner resumes after them.
+// On shutdown, polling stops and the records in flight are drained.
+//
+// Every record is processed in a consumer span, child of the producer span
+// propagated in its headers, and recorded with the messaging instruments of
+// revelio. The lag of the partitions is observed in
+// kafka_consumer_lag{messaging.destination.name, messaging.destination.partition.id,
+// messaging.consumer.group.name}.
+//
+// A Consumer is paused and resumed with Pause and Resume, e.g. from the admin
+// endpoints of Consumers.
+type Consumer struct {
+	name     string
+	config   ConsumerConfig
+	client   GroupClient
+	handle   Handler
+	producer *Producer
+	logger   *zerolog.Logger
+
+	instruments *revelio.MessagingInstruments
+	lag         metric.Registration
+
+	// hard is canceled once the records in flight are abandoned.
+	hard    context.Context
+	abandon context.CancelFunc
+
+	// resumed is closed when the paused consumer is resumed, nil when not
+	// paused.
+	pauseMu sync.Mutex
+	resumed chan struct{}
+
+	mu        sync.Mutex
+	trackers  map[string]*OffsetTracker
+	lags      map[string]map[int32]int64
+	commitMu  sync.Mutex
+	committed map[string]map[int32]int64
+}
+
+// NewConsumer returns a Consumer named name, joining its group with join and
+// handling records with handle. producer sends the dead-lettered records,
+// and may be nil without dead letter topic.
+func NewConsumer(name string, config ConsumerConfig, join JoinFunc, handle Handler, producer *Producer, logger *zerolog.Logger) (*Consumer, error) {
+	if config.DeadLetterTopic != "" && producer == nil {
+		return nil, fmt.Errorf("zikafka: consumer %q has a dead letter topic without producer", name)
+	}
+	c := &Consumer{
+		name:        name,
+		config:      config.withDefaults(),
+		handle:      handle,
+		producer:    producer,
+		logger:      logger,
+		instruments: revelio.Must(revelio.NewMessagingInstruments(scope)),
+		trackers:    map[string]*OffsetTracker{},
+		lags:        map[string]map[int32]int64{},
+		committed:   map[string]map[int32]int64{},
+	}
+	lag, err := scope.Int64ObservableGauge(
+		"kafka_consumer_lag",
+		"Number of records between the last consumed offset and the high watermark, by topic, partition and group",
+	)
+	if err != nil {
+		return nil, err
+	}
+	if c.lag, err = scope.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for topic, partitions := range c.Lag() {
+			for p, n := range partitions {
+				o.ObserveInt64(lag, n, metric.WithAttributes(
+					revelio.MessagingDestinationKey.String(topic),
+					revelio.MessagingPartitionKey.String(strconv.Itoa(int(p))),
+					revelio.MessagingConsumerGroupKey.String(c.config.Group),
+				))
+			}
+		}
+		return nil
+	}, lag); err != nil {
+		return nil, err
+	}
+
+	c.hard, c.abandon = context.WithCancel(context.Background())
+	client, err := join(c.config, c.Revoked)
+	if err != nil {
+		c.abandon()
+		c.lag.Unregister()
+		return nil, err
+	}
+	c.client = client
+	return c, nil
+}
+
+// Name returns the name of c.
+func (c *Consumer) Name() string {
+	return c.name
+}
+
+// Config returns the config of c, with the defaults.
+func (c *Consumer) Config() ConsumerConfig {
+	return c.config
+}
+
+// Lag returns the number of records between the last consumed offset and
+// the high watermark of the partitions assigned to c, by topic and
+// partition, once records of the partition are polled.
+func (c *Consumer) Lag() map[string]map[int32]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lags := make(map[string]map[int32]int64, len(c.lags))
+	for topic, partitions := range c.lags {
+		lags[topic] = make(map[int32]int64, len(partitions))
+		for p, n := range partitions {
+			lags[topic][p] = n
+		}
+	}
+	return lags
+}
+
+// setLag records the lag of the partition of record.
+func (c *Consumer) setLag(record *Record) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lags[record.Topic] == nil {
+		c.lags[record.Topic] = map[int32]int64{}
+	}
+	c.lags[record.Topic][record.Partition] = max(record.HighWatermark-record.Offset-1, 0)
+}
+
+// Pauser is implemented by group clients able to stop fetching the records
+// of topics, e.g. with PauseFetchTopics in franz-go. Paused consumers keep
+// polling them, so they still take part in the rebalances of the group.
+type Pauser interface {
+	Pause(topics ...string)
+	Resume(topics ...string)
+}
+
+// Pause stops c from polling records until resumed, the records in flight
+// being still processed and committed. The partitions of c stay assigned.
+func (c *Consumer) Pause() {
+	c.pauseMu.Lock()
+	defer c.pauseMu.Unlock()
+	if c.resumed != nil {
+		return
+	}
+	c.resumed = make(chan struct{})
+	if p, ok := c.client.(Pauser); ok {
+		p.Pause(c.config.Topics...)
+	}
+	c.logger.Warn().Str("consumer", c.name).Msg("kafka consumer paused")
+}
+
+// Resume resumes c, paused with Pause.
+func (c *Consumer) Resume() {
+	c.pauseMu.Lock()
+	defer c.pauseMu.Unlock()
+	if c.resumed == nil {
+		return
+	}
+	if p, ok := c.client.(Pauser); ok {
+		p.Resume(c.config.Topics...)
+	}
+	close(c.resumed)
+	c.resumed = nil
+	c.logger.Info().Str("consumer", c.name).Msg("kafka consumer resumed")
+}
+
+// Paused reports whether c is paused.
+func (c *Consumer) Paused() bool {
+	c.pauseMu.Lock()
+	defer c.pauseMu.Unlock()
+	return c.resumed != nil
+}
+
+// waitResumed blocks while c is paused, unless its client is a Pauser, and
+// reports whether c was resumed before ctx is done.
+func (c *Consumer) waitResumed(ctx context.Context) bool {
+	if _, ok := c.client.(Pauser); ok {
+		return true
+	}
+	c.pauseMu.Lock()
+	resumed := c.resumed
+	c.pauseMu.Unlock()
+	if resumed == nil {
+		return true
+	}
+	select {
+	case <-resumed:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (c *Consumer) tracker(topic string) *OffsetTracker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.trackers[topic]
+	if !ok {
+		t = NewOffsetTracker()
+		c.trackers[topic] = t
+	}
+	return t
+}
+
+// Run consumes the records until ctx is done, then drains the records in
+// flight for at most the drain timeout, commits and leaves the group.
+func (c *Consumer) Run(ctx context.Context) error {
+	defer c.abandon()
+	defer c.lag.Unregister()
+
+	processor := NewOrderedProcessor(
+		func(r *Record) string {
+			return r.Topic + "/" + strconv.Itoa(int(r.Partition)) + "/" + string(r.Key)
+		},
+		c.process,
+		func(r *Record, err error) {
+			// Abandoned records are left uncommitted, to be consumed again
+			if err == nil {
+				c.tracker(r.Topic).Done(r.Partition, r.Offset)
+			}
+		},
+		WithParallelism(c.config.Concurrency),
+	)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.commitLoop(ctx)
+	}()
+
+	backoff := c.config.Backoff
+poll:
+	for c.waitResumed(ctx) {
+		records, err := c.client.Poll(ctx)
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			c.logger.Error().Err(err).Str("consumer", c.name).Dur("backoff", backoff).Msg("kafka poll failed")
+			if !wait.Sleep(ctx, backoff) {
+				break
+			}
+			backoff = min(2*backoff, c.config.MaxBackoff)
+			continue
+		}
+		backoff = c.config.Backoff
+		for _, r := range records {
+			c.tracker(r.Topic).Track(r.Partition, r.Offset)
+			if r.HighWatermark > 0 {
+				c.setLag(r)
+			}
+			if err := processor.Submit(ctx, r); err != nil {
+				break poll
+			}
+		}
+	}
+
+	drainCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.config.DrainTimeout)
+	defer cancel()
+	if err := processor.Close(drainCtx); err != nil {
+		c.logger.Warn().Err(err).Str("consumer", c.name).Msg("kafka records in flight abandoned")
+		c.abandon()
+	}
+	wg.Wait()
+	c.commit(drainCtx)
+	return c.client.Close()
+}
+
+// commitLoop commits every commit interval until ctx is done.
+func (c *Consumer) commitLoop(ctx context.Context) {
+	ticker := time.NewTicker(c.config.CommitInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.commit(ctx)
+		}
+	}
+}
+
+// commit commits the offsets processed since the last commit.
+func (c *Consumer) commit(ctx context.Context) {
+	c.commitMu.Lock()
+	defer c.commitMu.Unlock()
+
+	c.mu.Lock()
+	trackers := make(map[string]*OffsetTracker, len(c.trackers))
+	for topic, t := range c.trackers {
+		trackers[topic] = t
+	}
+	c.mu.Unlock()
+
+	offsets := map[string]map[int32]int64{}
+	for topic, t := range trackers {
+		for _, p := range t.Partitions() {
+			offset, ok := t.Committable(p)
+			if !ok || offset == c.committed[topic][p] {
+				continue
+			}
+			if offsets[topic] == nil {
+				offsets[topic] = map[int32]int64{}
+			}
+			offsets[topic][p] = offset
+		}
+	}
+	if len(offsets) == 0 {
+		return
+	}
+	if err := c.client.Commit(ctx, offsets); err != nil {
+		c.logger.Error().Err(err).Str("consumer", c.name).Msg("kafka commit failed")
+		return
+	}
+	for topic, partitions := range offsets {
+		if c.committed[topic] == nil {
+			c.committed[topic] = map[int32]int64{}
+		}
+		for p, offset := range partitions {
+			c.committed[topic][p] = offset
+		}
+	}
+}
+
+// Revoked processes the records in flight of partitions and commits them,
+// see RevokedFunc.
+func (c *Consumer) Revoked(ctx context.Context, partitions map[string][]int32) {
+	for topic, ps := range partitions {
+		t := c.tracker(topic)
+		for _, p := range ps {
+			for t.Inflight(p) > 0 && c.hard.Err() == nil {
+				if !wait.Sleep(ctx, 10*time.Millisecond) {
+					break
+				}
+			}
+		}
+	}
+	c.commit(ctx)
+
+	c.commitMu.Lock()
+	defer c.commitMu.Unlock()
+	for topic, ps := range partitions {
+		c.tracker(topic).Revoke(ps...)
+		c.mu.Lock()
+		for _, p := range ps {
+			delete(c.committed[topic], p)
+			delete(c.lags[topic], p)
+		}
+		c.mu.Unlock()
+	}
+	c.logger.Info().Str("consumer", c.name).Interface("partitions", partitions).Msg("kafka partitions revoked")
+}
+
+// process handles record, retrying it with backoff, then dead-lettering it.
+func (c *Consumer) process(ctx context.Context, record *Record) error {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, headerCarrier{record: record})
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(c.hard, cancel)()
+
+	attrs := revelio.MessagingAttributes("kafka", record.Topic, c.config.Group)
+	backoff := c.config.Backoff
+	enqueuedAt := record.Timestamp
+	for attempt := 0; ; attempt++ {
+		err := c.handleRecord(ctx, record, enqueuedAt, attrs)
+		if err == nil {
+			return nil
+		}
+		if c.config.DeadLetterTopic != "" && attempt >= c.config.MaxRetries {
+			return c.deadLetter(ctx, record, err, attrs)
+		}
+		c.logger.Warn().Err(err).
+			Str("consumer", c.name).
+			Str("topic", record.Topic).
+			Int32("partition", record.Partition).
+			Int64("offset", record.Offset).
+			Int("attempt", attempt+1).
+			Msg("kafka record failed")
+		c.instruments.RecordRetry(ctx, attrs...)
+		if !wait.Sleep(ctx, backoff) {
+			return ctx.Err()
+		}
+		backoff = min(2*backoff, c.config.MaxBackoff)
+		// The lag is only recorded once per record
+		enqueuedAt = time.Time{}
+	}
+}
+
+func (c *Consumer) handleRecord(ctx context.Context, record *Record, enqueuedAt time.Time, attrs []attribute.KeyValue) (err error) {
+	ctx, span := tracer.Start(ctx, record.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(
+			revelio.MessagingOperationKey.String(revelio.MessagingOperationProcess),
+			revelio.MessagingPartitionKey.String(strconv.Itoa(int(record.Partition))),
+			attribute.Int64("messaging.kafka.offset", record.Offset),
+		),
+	)
+	defer span.End()
+
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			observe.RecordPanic(span, r)
+			err = errors.New("zikafka: handler panicked")
+		}
+		if err != nil {
+			observe.RecordError(span, err)
+		}
+		c.instruments.RecordProcessed(ctx, enqueuedAt, time.Since(start), err, attrs...)
+	}()
+	return c.handle(ctx, record)
+}
+
+// deadLetter sends record to the dead letter topic, retrying until it is
+// sent or ctx is done.
+func (c *Consumer) deadLetter(ctx context.Context, record *Record, cause error, attrs []attribute.KeyValue) error {
+	dead := &Record{
+		Topic:   c.config.DeadLetterTopic,
+		Key:     record.Key,
+		Value:   record.Value,
+		Headers: append([]Header(nil), record.Headers...),
+	}
+	dead.SetHeader(HeaderDeadLetterError, []byte(cause.Error()))
+	dead.SetHeader(HeaderDeadLetterTopic, []byte(record.Topic))
+	dead.SetHeader(HeaderDeadLetterPartition, []byte(strconv.Itoa(int(record.Partition))))
+	dead.SetHeader(HeaderDeadLetterOffset, []byte(strconv.FormatInt(record.Offset, 10)))
+
+	backoff := c.config.Backoff
+	for {
+		err := c.producer.Produce(ctx, dead)
+		if err == nil {
+			break
+		}
+		c.logger.Error().Err(err).Str("consumer", c.name).Str("topic", dead.Topic).Msg("kafka dead letter failed")
+		if !wait.Sleep(ctx, backoff) {
+			return ctx.Err()
+		}
+		backoff = min(2*backoff, c.config.MaxBackoff)
+	}
+	c.instruments.RecordDeadLettered(ctx, attrs...)
+	c.logger.Error().Err(cause).
+		Str("consumer", c.name).
+		Str("topic", record.Topic).
+		Int32("partition", record.Partition).
+		Int64("offset", record.Offset).
+		Str("dead_letter_topic", dead.Topic).
+		Msg("kafka record dead-lettered")
+	return nil
+}
@@ -0,0 +1,319 @@
+package zikafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type fakeProducer struct {
+	mu      sync.Mutex
+	records []*Record
+}
+
+func (p *fakeProducer) Produce(ctx context.Context, record *Record) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	record.Offset = int64(len(p.records))
+	p.records = append(p.records, record)
+	return nil
+}
+
+func (p *fakeProducer) Close() error { return nil }
+
+type fakeGroup struct {
+	batches chan []*Record
+
+	mu        sync.Mutex
+	committed map[string]map[int32]int64
+	closed    bool
+}
+
+func (g *fakeGroup) Poll(ctx context.Context) ([]*Record, error) {
+	select {
+	case batch := <-g.batches:
+		return batch, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (g *fakeGroup) Commit(ctx context.Context, offsets map[string]map[int32]int64) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for topic, partitions := range offsets {
+		if g.committed[topic] == nil {
+			g.committed[topic] = map[int32]int64{}
+		}
+		for p, offset := range partitions {
+			g.committed[topic][p] = offset
+		}
+	}
+	return nil
+}
+
+func (g *fakeGroup) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.closed = true
+	return nil
+}
+
+func (g *fakeGroup) offset(topic string, partition int32) int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.committed[topic][partition]
+}
+
+func TestConsumer(t *testing.T) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	logger := zerolog.Nop()
+	producerClient := &fakeProducer{}
+	producer := NewProducer(producerClient)
+
+	// Records are produced, then fed to the consumer
+	ctx, span := otel.Tracer("test").Start(context.Background(), "test")
+	for _, key := range []string{"a", "b", "bad", "a", "b"} {
+		if err := producer.Produce(ctx, &Record{Topic: "orders", Key: []byte(key), Value: []byte("v")}); err != nil {
+			t.Fatalf("Failed to produce: %v", err)
+		}
+	}
+	span.End()
+
+	group := &fakeGroup{batches: make(chan []*Record, 1), committed: map[string]map[int32]int64{}}
+	var (
+		mu     sync.Mutex
+		traces = map[trace.TraceID]int{}
+		seen   = map[string][]int64{}
+	)
+	handle := func(ctx context.Context, r *Record) error {
+		if string(r.Key) == "bad" {
+			return errors.New("boom")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		traces[trace.SpanContextFromContext(ctx).TraceID()]++
+		seen[string(r.Key)] = append(seen[string(r.Key)], r.Offset)
+		return nil
+	}
+	join := func(ConsumerConfig, RevokedFunc) (GroupClient, error) { return group, nil }
+	consumer, err := NewConsumer("orders", ConsumerConfig{
+		Group:           "billing",
+		Topics:          []string{"orders"},
+		DeadLetterTopic: "orders.dlt",
+		MaxRetries:      1,
+		Backoff:         time.Millisecond,
+		CommitInterval:  5 * time.Millisecond,
+	}, join, handle, producer, &logger)
+	if err != nil {
+		t.Fatalf("Failed to create consumer: %v", err)
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- consumer.Run(runCtx) }()
+	group.batches <- append([]*Record(nil), producerClient.records...)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for group.offset("orders", 0) != 5 {
+		if time.Now().After(deadline) {
+			t.Fatalf("committed offset = %d, want 5", group.offset("orders", 0))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+
+	if !group.closed {
+		t.Error("group not left on shutdown")
+	}
+	if got := seen["a"]; len(got) != 2 || got[0] != 0 || got[1] != 3 {
+		t.Errorf("offsets of a = %v, want [0 3]", got)
+	}
+	if n := traces[span.SpanContext().TraceID()]; n != 4 {
+		t.Errorf("%d records handled in the producer trace, want 4", n)
+	}
+
+	dead := producerClient.records[len(producerClient.records)-1]
+	if dead.Topic != "orders.dlt" || string(dead.Key) != "bad" {
+		t.Fatalf("last produced record = %s/%s, want the dead-lettered one", dead.Topic, dead.Key)
+	}
+	for key, want := range map[string]string{
+		HeaderDeadLetterError:     "boom",
+		HeaderDeadLetterTopic:     "orders",
+		HeaderDeadLetterPartition: "0",
+		HeaderDeadLetterOffset:    "2",
+	} {
+		if v, _ := dead.Header(key); string(v) != want {
+			t.Errorf("header %s = %q, want %q", key, v, want)
+		}
+	}
+}
+
+func TestConsumerRevoked(t *testing.T) {
+	logger := zerolog.Nop()
+	group := &fakeGroup{batches: make(chan []*Record), committed: map[string]map[int32]int64{}}
+	release := make(chan struct{})
+	handle := func(ctx context.Context, r *Record) error {
+		<-release
+		return nil
+	}
+	var revoked RevokedFunc
+	join := func(_ ConsumerConfig, f RevokedFunc) (GroupClient, error) {
+		revoked = f
+		return group, nil
+	}
+	consumer, err := NewConsumer("orders", ConsumerConfig{CommitInterval: time.Hour}, join, handle, nil, &logger)
+	if err != nil {
+		t.Fatalf("Failed to create consumer: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go consumer.Run(ctx)
+	group.batches <- []*Record{{Topic: "orders", Partition: 1, Offset: 7}}
+
+	for consumer.tracker("orders").Inflight(1) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// The revocation waits for the record in flight, then commits it
+	time.AfterFunc(10*time.Millisecond, func() { close(release) })
+	revoked(context.Background(), map[string][]int32{"orders": {1}})
+	if got := group.offset("orders", 1); got != 8 {
+		t.Errorf("committed offset = %d, want 8", got)
+	}
+	if partitions := consumer.tracker("orders").Partitions(); len(partitions) != 0 {
+		t.Errorf("tracked partitions after revocation = %v, want none", partitions)
+	}
+}
+
+func TestConsumerLag(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	logger := zerolog.Nop()
+	group := &fakeGroup{batches: make(chan []*Record, 1), committed: map[string]map[int32]int64{}}
+	var revoked RevokedFunc
+	join := func(_ ConsumerConfig, f RevokedFunc) (GroupClient, error) {
+		revoked = f
+		return group, nil
+	}
+	handle := func(ctx context.Context, r *Record) error { return nil }
+	consumer, err := NewConsumer("orders", ConsumerConfig{Group: "billing", CommitInterval: time.Millisecond}, join, handle, nil, &logger)
+	if err != nil {
+		t.Fatalf("Failed to create consumer: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go consumer.Run(ctx)
+	group.batches <- []*Record{
+		{Topic: "orders", Partition: 0, Offset: 3, HighWatermark: 10},
+		{Topic: "orders", Partition: 1, Offset: 5, HighWatermark: 6},
+	}
+	for group.offset("orders", 1) != 6 {
+		time.Sleep(time.Millisecond)
+	}
+
+	lags := func() map[string]int64 {
+		m, ok := s.Metric(t, "kafka_consumer_lag")
+		if !ok {
+			return nil
+		}
+		lags := map[string]int64{}
+		for _, dp := range m.Data.(metricdata.Gauge[int64]).DataPoints {
+			p, _ := dp.Attributes.Value(revelio.MessagingPartitionKey)
+			group, _ := dp.Attributes.Value(revelio.MessagingConsumerGroupKey)
+			lags[group.AsString()+"/"+p.AsString()] = dp.Value
+		}
+		return lags
+	}
+	if got := lags(); len(got) != 2 || got["billing/0"] != 6 || got["billing/1"] != 0 {
+		t.Errorf("kafka_consumer_lag = %v, want 6 for partition 0 and 0 for partition 1", got)
+	}
+
+	revoked(context.Background(), map[string][]int32{"orders": {0}})
+	if got := lags(); len(got) != 1 || got["billing/1"] != 0 {
+		t.Errorf("kafka_consumer_lag after revocation = %v, want partition 1 only", got)
+	}
+}
+
+func TestConsumerPause(t *testing.T) {
+	logger := zerolog.Nop()
+	group := &fakeGroup{batches: make(chan []*Record, 1), committed: map[string]map[int32]int64{}}
+	join := func(ConsumerConfig, RevokedFunc) (GroupClient, error) { return group, nil }
+	handled := make(chan int64, 1)
+	handle := func(ctx context.Context, r *Record) error {
+		handled <- r.Offset
+		return nil
+	}
+	consumer, err := NewConsumer("orders", ConsumerConfig{}, join, handle, nil, &logger)
+	if err != nil {
+		t.Fatalf("Failed to create consumer: %v", err)
+	}
+
+	consumer.Pause()
+	if !consumer.Paused() {
+		t.Fatal("consumer not paused")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go consumer.Run(ctx)
+	group.batches <- []*Record{{Topic: "orders", Offset: 1}}
+
+	select {
+	case <-handled:
+		t.Fatal("paused consumer handled a record")
+	case <-time.After(20 * time.Millisecond):
+	}
+	consumer.Resume()
+	select {
+	case offset := <-handled:
+		if offset != 1 {
+			t.Errorf("handled offset %d, want 1", offset)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("resumed consumer didn't handle the record")
+	}
+}
+
+type pausableGroup struct {
+	*fakeGroup
+	paused []string
+}
+
+func (g *pausableGroup) Pause(topics ...string)  { g.paused = topics }
+func (g *pausableGroup) Resume(topics ...string) { g.paused = nil }
+
+func TestConsumerPauseClient(t *testing.T) {
+	logger := zerolog.Nop()
+	group := &pausableGroup{fakeGroup: &fakeGroup{batches: make(chan []*Record), committed: map[string]map[int32]int64{}}}
+	join := func(ConsumerConfig, RevokedFunc) (GroupClient, error) { return group, nil }
+	consumer, err := NewConsumer("orders", ConsumerConfig{Topics: []string{"orders"}}, join, nil, nil, &logger)
+	if err != nil {
+		t.Fatalf("Failed to create consumer: %v", err)
+	}
+
+	consumer.Pause()
+	if len(group.paused) != 1 || group.paused[0] != "orders" {
+		t.Errorf("paused topics = %v, want [orders]", group.paused)
+	}
+	consumer.Resume()
+	if group.paused != nil || consumer.Paused() {
+		t.Error("consumer still paused once resumed")
+	}
+}
@@ -0,0 +1,208 @@
+// Package franz adapts the clients of franz-go to zikafka, e.g. to provide
+// the zikafka.Dialer of the zikafkafx module:
+//
+//	fx.Supply(fx.Annotate(franz.Dialer{}, fx.As(new(zikafka.Dialer))))
+package franz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/divikraf/lumos/zikafka"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// Dialer is the zikafka.Dialer of franz-go.
+type Dialer struct {
+	// Options are added to the options of every client, e.g. kgo.SASL or
+	// kgo.DialTLSConfig.
+	Options []kgo.Opt
+}
+
+var _ zikafka.Dialer = Dialer{}
+
+func (d Dialer) options(config zikafka.Config, opts ...kgo.Opt) []kgo.Opt {
+	all := []kgo.Opt{kgo.SeedBrokers(config.Brokers...)}
+	if config.ClientID != "" {
+		all = append(all, kgo.ClientID(config.ClientID))
+	}
+	all = append(all, opts...)
+	return append(all, d.Options...)
+}
+
+// Producer returns a client producing to the brokers of config. Records are
+// partitioned by key like the Java client, see zikafka.Partition.
+func (d Dialer) Producer(config zikafka.Config) (zikafka.ProduceClient, error) {
+	client, err := kgo.NewClient(d.options(config)...)
+	if err != nil {
+		return nil, err
+	}
+	return &produceClient{client: client}, nil
+}
+
+// Join returns a member of the consumer group of consumer. Offsets are only
+// committed by zikafka, and rebalances are blocked while polled records are
+// tracked: revoked is called, and returns, before partitions are revoked or
+// lost, so their records in flight are committed before they are handed
+// back.
+func (d Dialer) Join(config zikafka.Config, consumer zikafka.ConsumerConfig, revoked zikafka.RevokedFunc) (zikafka.GroupClient, error) {
+	onRevoked := func(ctx context.Context, _ *kgo.Client, partitions map[string][]int32) {
+		revoked(ctx, partitions)
+	}
+	client, err := kgo.NewClient(d.options(config,
+		kgo.ConsumerGroup(consumer.Group),
+		kgo.ConsumeTopics(consumer.Topics...),
+		kgo.DisableAutoCommit(),
+		kgo.BlockRebalanceOnPoll(),
+		kgo.OnPartitionsRevoked(onRevoked),
+		kgo.OnPartitionsLost(onRevoked),
+	)...)
+	if err != nil {
+		return nil, err
+	}
+	return &groupClient{client: client}, nil
+}
+
+type produceClient struct {
+	client *kgo.Client
+}
+
+func (c *produceClient) Produce(ctx context.Context, record *zikafka.Record) error {
+	r := toKgo(record)
+	if err := c.client.ProduceSync(ctx, r).FirstErr(); err != nil {
+		return err
+	}
+	record.Partition, record.Offset = r.Partition, r.Offset
+	return nil
+}
+
+func (c *produceClient) Close() error {
+	c.client.Close()
+	return nil
+}
+
+type groupClient struct {
+	client *kgo.Client
+}
+
+var _ zikafka.Pauser = (*groupClient)(nil)
+
+// Poll allows the rebalances blocked since the previous poll, the records
+// polled being tracked by then, and polls the next records. Fetch errors are
+// returned when no record is fetched.
+func (c *groupClient) Poll(ctx context.Context) ([]*zikafka.Record, error) {
+	c.client.AllowRebalance()
+	fetches := c.client.PollFetches(ctx)
+	if fetches.IsClientClosed() {
+		return nil, kgo.ErrClientClosed
+	}
+	var records []*zikafka.Record
+	fetches.EachPartition(func(p kgo.FetchTopicPartition) {
+		for _, r := range p.Records {
+			records = append(records, fromKgo(r, p.HighWatermark))
+		}
+	})
+	if len(records) == 0 {
+		var errs []error
+		fetches.EachError(func(topic string, partition int32, err error) {
+			errs = append(errs, fmt.Errorf("franz: fetch %s/%d: %w", topic, partition, err))
+		})
+		return nil, errors.Join(errs...)
+	}
+	return records, nil
+}
+
+func (c *groupClient) Commit(ctx context.Context, offsets map[string]map[int32]int64) error {
+	var commitErr error
+	c.client.CommitOffsetsSync(ctx, epochOffsets(offsets), func(_ *kgo.Client, _ *kmsg.OffsetCommitRequest, resp *kmsg.OffsetCommitResponse, err error) {
+		if err != nil {
+			commitErr = err
+			return
+		}
+		commitErr = commitError(resp)
+	})
+	return commitErr
+}
+
+// Pause stops fetching the records of topics, see zikafka.Pauser.
+func (c *groupClient) Pause(topics ...string) {
+	c.client.PauseFetchTopics(topics...)
+}
+
+// Resume resumes fetching the records of topics.
+func (c *groupClient) Resume(topics ...string) {
+	c.client.ResumeFetchTopics(topics...)
+}
+
+// Close allows the rebalance of leaving the group, revoking the partitions,
+// and closes the client.
+func (c *groupClient) Close() error {
+	c.client.CloseAllowingRebalance()
+	return nil
+}
+
+// toKgo returns the franz-go record of record.
+func toKgo(record *zikafka.Record) *kgo.Record {
+	r := &kgo.Record{
+		Topic:     record.Topic,
+		Key:       record.Key,
+		Value:     record.Value,
+		Timestamp: record.Timestamp,
+	}
+	if len(record.Headers) > 0 {
+		r.Headers = make([]kgo.RecordHeader, len(record.Headers))
+		for i, h := range record.Headers {
+			r.Headers[i] = kgo.RecordHeader{Key: h.Key, Value: h.Value}
+		}
+	}
+	return r
+}
+
+// fromKgo returns the zikafka record of r, fetched from a partition whose
+// high watermark is hw.
+func fromKgo(r *kgo.Record, hw int64) *zikafka.Record {
+	record := &zikafka.Record{
+		Topic:         r.Topic,
+		Partition:     r.Partition,
+		Offset:        r.Offset,
+		Key:           r.Key,
+		Value:         r.Value,
+		Timestamp:     r.Timestamp,
+		HighWatermark: hw,
+	}
+	if len(r.Headers) > 0 {
+		record.Headers = make([]zikafka.Header, len(r.Headers))
+		for i, h := range r.Headers {
+			record.Headers[i] = zikafka.Header{Key: h.Key, Value: h.Value}
+		}
+	}
+	return record
+}
+
+// epochOffsets returns offsets without leader epoch, by topic and partition.
+func epochOffsets(offsets map[string]map[int32]int64) map[string]map[int32]kgo.EpochOffset {
+	epochs := make(map[string]map[int32]kgo.EpochOffset, len(offsets))
+	for topic, partitions := range offsets {
+		epochs[topic] = make(map[int32]kgo.EpochOffset, len(partitions))
+		for p, offset := range partitions {
+			epochs[topic][p] = kgo.EpochOffset{Epoch: -1, Offset: offset}
+		}
+	}
+	return epochs
+}
+
+// commitError returns the errors of the partitions of resp.
+func commitError(resp *kmsg.OffsetCommitResponse) error {
+	var errs []error
+	for _, t := range resp.Topics {
+		for _, p := range t.Partitions {
+			if err := kerr.ErrorForCode(p.ErrorCode); err != nil {
+				errs = append(errs, fmt.Errorf("franz: commit %s/%d: %w", t.Topic, p.Partition, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,174 @@
+package franz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zikafka"
+	"github.com/rs/zerolog"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestRecords(t *testing.T) {
+	record := &zikafka.Record{
+		Topic:     "orders",
+		Key:       []byte("order-1"),
+		Value:     []byte("created"),
+		Headers:   []zikafka.Header{{Key: "traceparent", Value: []byte("00-trace")}},
+		Timestamp: time.Unix(1700000000, 0),
+	}
+	r := toKgo(record)
+	r.Partition, r.Offset = 3, 42
+
+	got := fromKgo(r, 50)
+	if got.Topic != "orders" || got.Partition != 3 || got.Offset != 42 || got.HighWatermark != 50 ||
+		string(got.Key) != "order-1" || string(got.Value) != "created" || !got.Timestamp.Equal(record.Timestamp) {
+		t.Errorf("record = %+v, want %+v at 3/42", got, record)
+	}
+	if v, ok := got.Header("traceparent"); !ok || string(v) != "00-trace" {
+		t.Errorf("traceparent header = %q, want it propagated", v)
+	}
+}
+
+func TestCommit(t *testing.T) {
+	epochs := epochOffsets(map[string]map[int32]int64{"orders": {0: 5, 1: 8}})
+	if got := epochs["orders"][1]; got != (kgo.EpochOffset{Epoch: -1, Offset: 8}) {
+		t.Errorf("epoch offset = %+v, want offset 8 without epoch", got)
+	}
+
+	resp := kmsg.NewPtrOffsetCommitResponse()
+	topic := kmsg.NewOffsetCommitResponseTopic()
+	topic.Topic = "orders"
+	for p, code := range []int16{0, kerr.RebalanceInProgress.Code} {
+		partition := kmsg.NewOffsetCommitResponseTopicPartition()
+		partition.Partition, partition.ErrorCode = int32(p), code
+		topic.Partitions = append(topic.Partitions, partition)
+	}
+	resp.Topics = append(resp.Topics, topic)
+	if err := commitError(resp); !errors.Is(err, kerr.RebalanceInProgress) {
+		t.Errorf("commitError() = %v, want the error of partition 1", err)
+	}
+}
+
+func TestDialer(t *testing.T) {
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(2, "orders"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+	config := zikafka.Config{Brokers: cluster.ListenAddrs(), ClientID: "test"}
+	logger := zerolog.Nop()
+	dialer := Dialer{}
+
+	client, err := dialer.Producer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := zikafka.NewProducer(client)
+	defer producer.Close()
+	produce := func(n int) {
+		for i := 0; i < n; i++ {
+			r := &zikafka.Record{Topic: "orders", Key: []byte(strconv.Itoa(i)), Value: []byte("v")}
+			r.SetHeader("x-tenant", []byte("acme"))
+			if err := producer.Produce(context.Background(), r); err != nil {
+				t.Fatalf("Produce() = %v", err)
+			}
+		}
+	}
+
+	var (
+		mu      sync.Mutex
+		handled = map[string]int{}
+		revoked = make(chan map[string][]int32, 10)
+	)
+	run := func(ctx context.Context) {
+		handle := func(ctx context.Context, r *zikafka.Record) error {
+			if v, _ := r.Header("x-tenant"); string(v) != "acme" {
+				t.Errorf("x-tenant header = %q, want acme", v)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			handled[fmt.Sprintf("%d/%d", r.Partition, r.Offset)]++
+			return nil
+		}
+		join := func(cc zikafka.ConsumerConfig, f zikafka.RevokedFunc) (zikafka.GroupClient, error) {
+			return dialer.Join(config, cc, func(ctx context.Context, partitions map[string][]int32) {
+				f(ctx, partitions)
+				if len(partitions) > 0 {
+					revoked <- partitions
+				}
+			})
+		}
+		consumer, err := zikafka.NewConsumer("orders", zikafka.ConsumerConfig{
+			Group:          "billing",
+			Topics:         []string{"orders"},
+			CommitInterval: 10 * time.Millisecond,
+		}, join, handle, nil, &logger)
+		if err != nil {
+			t.Errorf("NewConsumer() = %v", err)
+			return
+		}
+		consumer.Run(ctx)
+	}
+	waitHandled := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			mu.Lock()
+			got := len(handled)
+			mu.Unlock()
+			if got >= n {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%d records handled, want %d", got, n)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	first, stopFirst := context.WithCancel(context.Background())
+	firstDone := make(chan struct{})
+	go func() {
+		defer close(firstDone)
+		run(first)
+	}()
+	produce(10)
+	waitHandled(10)
+
+	// The second member takes partitions of the first one, which hands them
+	// back once committed
+	second, stopSecond := context.WithCancel(context.Background())
+	secondDone := make(chan struct{})
+	go func() {
+		defer close(secondDone)
+		run(second)
+	}()
+	select {
+	case <-revoked:
+	case <-time.After(10 * time.Second):
+		t.Fatal("no partition revoked when the second member joined")
+	}
+	produce(10)
+	waitHandled(20)
+	stopFirst()
+	<-firstDone
+	stopSecond()
+	<-secondDone
+
+	mu.Lock()
+	defer mu.Unlock()
+	for record, n := range handled {
+		if n != 1 {
+			t.Errorf("record %s handled %d times, want once", record, n)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package zikafka
+
+import (
+	"context"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+)
+
+const instrumentationName = "github.com/divikraf/lumos/zikafka"
+
+var (
+	tracer = observe.LibraryTracer(instrumentationName)
+	scope  = revelio.Library(instrumentationName)
+)
+
+// Header is a header of a record.
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Record is a Kafka record, adapted from the record type of the client
+// library.
+type Record struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   []Header
+	Timestamp time.Time
+	// HighWatermark is the offset following the last record of the
+	// partition when the record was fetched, zero if unknown.
+	HighWatermark int64
+}
+
+// Header returns the value of the header key.
+func (r *Record) Header(key string) ([]byte, bool) {
+	for _, h := range r.Headers {
+		if h.Key == key {
+			return h.Value, true
+		}
+	}
+	return nil, false
+}
+
+// SetHeader sets the header key to value, replacing the existing one.
+func (r *Record) SetHeader(key string, value []byte) {
+	for i, h := range r.Headers {
+		if h.Key == key {
+			r.Headers[i].Value = value
+			return
+		}
+	}
+	r.Headers = append(r.Headers, Header{Key: key, Value: value})
+}
+
+// headerCarrier adapts the headers of a record to
+// propagation.TextMapCarrier.
+type headerCarrier struct {
+	record *Record
+}
+
+func (c headerCarrier) Get(key string) string {
+	v, _ := c.record.Header(key)
+	return string(v)
+}
+
+func (c headerCarrier) Set(key, value string) {
+	c.record.SetHeader(key, []byte(value))
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, len(c.record.Headers))
+	for i, h := range c.record.Headers {
+		keys[i] = h.Key
+	}
+	return keys
+}
+
+// ProduceClient sends records, e.g. an adapted *kgo.Client or
+// sarama.SyncProducer.
+type ProduceClient interface {
+	// Produce sends record, blocking until it is acknowledged.
+	Produce(ctx context.Context, record *Record) error
+	Close() error
+}
+
+// GroupClient is a member of a consumer group, e.g. an adapted *kgo.Client
+// with a consumer group.
+type GroupClient interface {
+	// Poll blocks until records are fetched, or ctx is done.
+	Poll(ctx context.Context) ([]*Record, error)
+	// Commit commits offsets by topic and partition, the offset of a
+	// partition being the one of the next record to consume.
+	Commit(ctx context.Context, offsets map[string]map[int32]int64) error
+	// Close leaves the group.
+	Close() error
+}
+
+// RevokedFunc must be called by group clients before partitions are revoked
+// or lost, e.g. from the OnPartitionsRevoked and OnPartitionsLost hooks of
+// franz-go, or from the Cleanup of a sarama.ConsumerGroupHandler, and must
+// return before the partitions are reassigned.
+type RevokedFunc func(ctx context.Context, partitions map[string][]int32)
+
+// Dialer creates the clients of the client library, e.g. the franz.Dialer
+// of franz-go.
+type Dialer interface {
+	// Producer returns a producer connected to the brokers of config.
+	Producer(config Config) (ProduceClient, error)
+	// Join joins the consumer group of consumer, calling revoked before
+	// partitions are revoked.
+	Join(config Config, consumer ConsumerConfig, revoked RevokedFunc) (GroupClient, error)
+}
+
+// Config configures the Kafka clients.
+type Config struct {
+	Brokers  []string `json:"brokers" yaml:"brokers"`
+	ClientID string   `json:"client_id" yaml:"client_id"`
+	// Consumers are the consumers by name.
+	Consumers map[string]ConsumerConfig `json:"consumers" yaml:"consumers"`
+}
+
+// kafkaConfig is implemented by configs of services using Kafka.
+type kafkaConfig interface {
+	GetKafka() Config
+}
+
+// ConsumerConfig configures a consumer, see Consumer.
+type ConsumerConfig struct {
+	Group  string   `json:"group" yaml:"group"`
+	Topics []string `json:"topics" yaml:"topics"`
+	// DeadLetterTopic, if set, receives the records still failing after
+	// MaxRetries retries. Without it, failing records are retried until they
+	// succeed.
+	DeadLetterTopic string `json:"dead_letter_topic" yaml:"dead_letter_topic"`
+	// MaxRetries is the number of retries of a failing record before it is
+	// dead-lettered (default: 3).
+	MaxRetries int `json:"max_retries" yaml:"max_retries"`
+	// Backoff and MaxBackoff are the initial and maximum delays between
+	// retries (default: 100ms and 10s).
+	Backoff    time.Duration `json:"backoff" yaml:"backoff"`
+	MaxBackoff time.Duration `json:"max_backoff" yaml:"max_backoff"`
+	// Concurrency is the maximum number of keys processed concurrently,
+	// records of a key being processed in order (default: 16).
+	Concurrency int `json:"concurrency" yaml:"concurrency"`
+	// CommitInterval is the interval between commits (default: 5s).
+	CommitInterval time.Duration `json:"commit_interval" yaml:"commit_interval"`
+	// DrainTimeout is the maximum duration to wait for the records in flight
+	// on shutdown (default: 30s).
+	DrainTimeout time.Duration `json:"drain_timeout" yaml:"drain_timeout"`
+}
+
+func (c ConsumerConfig) withDefaults() ConsumerConfig {
+	if c.MaxRetries <= 0 {
+		c.MaxRetries = 3
+	}
+	if c.Backoff <= 0 {
+		c.Backoff = 100 * time.Millisecond
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = 10 * time.Second
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = 16
+	}
+	if c.CommitInterval <= 0 {
+		c.CommitInterval = 5 * time.Second
+	}
+	if c.DrainTimeout <= 0 {
+		c.DrainTimeout = 30 * time.Second
+	}
+	return c
+}
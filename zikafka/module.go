@@ -0,0 +1,152 @@
+package zikafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/rs/zerolog"
+	"go.uber.org/fx"
+)
+
+// ConsumerHandler is the handler of the consumer Name of the config.
+type ConsumerHandler struct {
+	Name   string
+	Handle Handler
+}
+
+type NewConfiguredProducerParams struct {
+	fx.In
+	Config ziconf.Config
+	Dialer Dialer
+	LC     fx.Lifecycle
+}
+
+// NewConfiguredProducer returns a Producer connected with the dialer to the
+// brokers of the config, closed when the application stops.
+func NewConfiguredProducer(params NewConfiguredProducerParams) (*Producer, error) {
+	c, ok := params.Config.(kafkaConfig)
+	if !ok {
+		return nil, errors.New("zikafka: the config has no Kafka config")
+	}
+	client, err := params.Dialer.Producer(c.GetKafka())
+	if err != nil {
+		return nil, err
+	}
+	p := NewProducer(client)
+	params.LC.Append(fx.StopHook(p.Close))
+	return p, nil
+}
+
+type StartConsumersParams struct {
+	fx.In
+	Config ziconf.Config
+	Dialer Dialer
+	Logger *zerolog.Logger
+	LC     fx.Lifecycle
+	// Producer sends the dead-lettered records.
+	Producer *Producer         `optional:"true"`
+	Handlers []ConsumerHandler `group:"kafka-consumers"`
+}
+
+// Consumers are the consumers run by the application, see NewConsumers.
+type Consumers struct {
+	consumers []*Consumer
+}
+
+// NewConsumers returns the Consumers of the handlers of the params, each
+// configured by the consumer of the same name of the config, run from start
+// until the application stops.
+func NewConsumers(params StartConsumersParams) (*Consumers, error) {
+	cs := &Consumers{}
+	if len(params.Handlers) == 0 {
+		return cs, nil
+	}
+	c, ok := params.Config.(kafkaConfig)
+	if !ok {
+		return nil, errors.New("zikafka: the config has no Kafka config")
+	}
+	config := c.GetKafka()
+
+	for _, h := range params.Handlers {
+		cc, ok := config.Consumers[h.Name]
+		if !ok {
+			return nil, fmt.Errorf("zikafka: consumer %q is not configured", h.Name)
+		}
+		join := func(cc ConsumerConfig, revoked RevokedFunc) (GroupClient, error) {
+			return params.Dialer.Join(config, cc, revoked)
+		}
+		consumer, err := NewConsumer(h.Name, cc, join, h.Handle, params.Producer, params.Logger)
+		if err != nil {
+			return nil, err
+		}
+		cs.consumers = append(cs.consumers, consumer)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		params.LC.Append(fx.StartHook(func() {
+			go func() {
+				defer close(done)
+				if err := consumer.Run(ctx); err != nil {
+					params.Logger.Error().Err(err).Str("consumer", h.Name).Msg("kafka consumer stopped")
+				}
+			}()
+			params.Logger.Info().Str("consumer", h.Name).Strs("topics", cc.Topics).Msg("kafka consumer started")
+		}))
+		params.LC.Append(fx.StopHook(func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-stopCtx.Done():
+				return stopCtx.Err()
+			}
+		}))
+	}
+	slices.SortFunc(cs.consumers, func(a, b *Consumer) int { return strings.Compare(a.name, b.name) })
+	return cs, nil
+}
+
+// StartConsumers runs the Consumers of the params, see NewConsumers.
+func StartConsumers(params StartConsumersParams) error {
+	_, err := NewConsumers(params)
+	return err
+}
+
+// Consumers returns the consumers, sorted by name.
+func (cs *Consumers) Consumers() []*Consumer {
+	return cs.consumers
+}
+
+// Consumer returns the consumer name.
+func (cs *Consumers) Consumer(name string) (*Consumer, bool) {
+	for _, c := range cs.consumers {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// Name names the readiness check of the consumers.
+func (cs *Consumers) Name() string {
+	return "kafka-consumers"
+}
+
+// Check fails while consumers are paused, so that paused consumers show up
+// in the readiness report.
+func (cs *Consumers) Check(ctx context.Context) error {
+	var paused []string
+	for _, c := range cs.consumers {
+		if c.Paused() {
+			paused = append(paused, c.name)
+		}
+	}
+	if len(paused) > 0 {
+		return fmt.Errorf("zikafka: consumers paused: %s", strings.Join(paused, ", "))
+	}
+	return nil
+}
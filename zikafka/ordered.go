@@ -0,0 +1,253 @@
+package zikafka
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrProcessorClosed is returned by Submit once the processor is closed.
+var ErrProcessorClosed = errors.New("zikafka: ordered processor closed")
+
+// OrderedOption configures an OrderedProcessor.
+type OrderedOption func(*orderedConfig)
+
+type orderedConfig struct {
+	parallelism int
+	maxPending  int
+}
+
+// WithParallelism sets the maximum number of keys processed concurrently
+// (default: 16).
+func WithParallelism(n int) OrderedOption {
+	return func(c *orderedConfig) {
+		c.parallelism = n
+	}
+}
+
+// WithMaxPending sets the maximum number of messages submitted and not yet
+// processed, Submit blocking beyond (default: 1024).
+func WithMaxPending(n int) OrderedOption {
+	return func(c *orderedConfig) {
+		c.maxPending = n
+	}
+}
+
+// OrderedProcessor processes messages in order per key, and concurrently
+// across keys: every key has a serial queue, and at most parallelism queues
+// are processed at once. E.g. the events of an order are handled in order,
+// while the events of different orders are handled in parallel.
+//
+// As messages of a partition complete out of order, offsets must only be
+// committed once every previous message is done, see OffsetTracker.
+type OrderedProcessor[T any] struct {
+	keyOf  func(T) string
+	handle func(ctx context.Context, msg T) error
+	done   func(msg T, err error)
+
+	slots   chan struct{}
+	pending chan struct{}
+	wg      sync.WaitGroup
+
+	mu     sync.Mutex
+	queues map[string][]queued[T]
+	closed bool
+}
+
+type queued[T any] struct {
+	ctx context.Context
+	msg T
+}
+
+// NewOrderedProcessor returns an OrderedProcessor handling messages with
+// handle, keyed by keyOf. done, if not nil, is called with the result of
+// every message once handled, e.g. to mark its offset done.
+func NewOrderedProcessor[T any](keyOf func(T) string, handle func(ctx context.Context, msg T) error, done func(msg T, err error), opts ...OrderedOption) *OrderedProcessor[T] {
+	config := orderedConfig{parallelism: 16, maxPending: 1024}
+	for _, o := range opts {
+		o(&config)
+	}
+	if config.parallelism <= 0 {
+		config.parallelism = 1
+	}
+	if config.maxPending < config.parallelism {
+		config.maxPending = config.parallelism
+	}
+	return &OrderedProcessor[T]{
+		keyOf:   keyOf,
+		handle:  handle,
+		done:    done,
+		slots:   make(chan struct{}, config.parallelism),
+		pending: make(chan struct{}, config.maxPending),
+		queues:  map[string][]queued[T]{},
+	}
+}
+
+// Submit queues msg after the previous messages of its key, blocking while
+// the maximum of pending messages is reached. The message is handled with
+// the values of ctx, but isn't canceled with it.
+func (p *OrderedProcessor[T]) Submit(ctx context.Context, msg T) error {
+	select {
+	case p.pending <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	key := p.keyOf(msg)
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		<-p.pending
+		return ErrProcessorClosed
+	}
+	queue, running := p.queues[key]
+	p.queues[key] = append(queue, queued[T]{ctx: context.WithoutCancel(ctx), msg: msg})
+	if !running {
+		p.wg.Add(1)
+		go p.run(key)
+	}
+	p.mu.Unlock()
+	return nil
+}
+
+// run processes the queue of key until empty.
+func (p *OrderedProcessor[T]) run(key string) {
+	defer p.wg.Done()
+	p.slots <- struct{}{}
+	defer func() { <-p.slots }()
+
+	for {
+		p.mu.Lock()
+		queue := p.queues[key]
+		if len(queue) == 0 {
+			delete(p.queues, key)
+			p.mu.Unlock()
+			return
+		}
+		next := queue[0]
+		queue[0] = queued[T]{}
+		p.queues[key] = queue[1:]
+		p.mu.Unlock()
+
+		err := p.handle(next.ctx, next.msg)
+		if p.done != nil {
+			p.done(next.msg, err)
+		}
+		<-p.pending
+	}
+}
+
+// Close stops accepting messages, and waits for the submitted ones to be
+// handled, or for ctx to be done.
+func (p *OrderedProcessor[T]) Close(ctx context.Context) error {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// OffsetTracker tracks the offsets of messages completing out of order, e.g.
+// with an OrderedProcessor, to commit each partition up to its oldest
+// message not done.
+type OffsetTracker struct {
+	mu         sync.Mutex
+	partitions map[int32]*partitionOffsets
+}
+
+// partitionOffsets are the offsets in flight of a partition, in order.
+type partitionOffsets struct {
+	inflight []int64
+	done     map[int64]bool
+	commit   int64
+}
+
+// NewOffsetTracker returns an empty OffsetTracker.
+func NewOffsetTracker() *OffsetTracker {
+	return &OffsetTracker{partitions: map[int32]*partitionOffsets{}}
+}
+
+// Track tracks the offset of a message of partition, before it is
+// processed. Offsets of a partition are tracked in increasing order.
+func (t *OffsetTracker) Track(partition int32, offset int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.partitions[partition]
+	if !ok {
+		p = &partitionOffsets{done: map[int64]bool{}, commit: -1}
+		t.partitions[partition] = p
+	}
+	p.inflight = append(p.inflight, offset)
+}
+
+// Done marks the offset of partition done.
+func (t *OffsetTracker) Done(partition int32, offset int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.partitions[partition]
+	if !ok {
+		return
+	}
+	p.done[offset] = true
+	n := 0
+	for n < len(p.inflight) && p.done[p.inflight[n]] {
+		delete(p.done, p.inflight[n])
+		p.commit = p.inflight[n] + 1
+		n++
+	}
+	p.inflight = p.inflight[n:]
+}
+
+// Committable returns the offset to commit for partition, i.e. the offset
+// following the messages all done, and false when none is done yet.
+func (t *OffsetTracker) Committable(partition int32) (int64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.partitions[partition]
+	if !ok || p.commit < 0 {
+		return 0, false
+	}
+	return p.commit, true
+}
+
+// Inflight returns the number of tracked offsets of partition not done yet.
+func (t *OffsetTracker) Inflight(partition int32) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p, ok := t.partitions[partition]; ok {
+		return len(p.inflight)
+	}
+	return 0
+}
+
+// Revoke forgets the offsets of partitions, e.g. on a rebalance.
+func (t *OffsetTracker) Revoke(partitions ...int32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, p := range partitions {
+		delete(t.partitions, p)
+	}
+}
+
+// Partitions returns the tracked partitions, sorted.
+func (t *OffsetTracker) Partitions() []int32 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	partitions := make([]int32, 0, len(t.partitions))
+	for p := range t.partitions {
+		partitions = append(partitions, p)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+	return partitions
+}
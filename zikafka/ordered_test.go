@@ -0,0 +1,111 @@
+package zikafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type event struct {
+	orderID string
+	seq     int
+	offset  int64
+}
+
+func TestOrderedProcessor(t *testing.T) {
+	ctx := context.Background()
+	var (
+		mu       sync.Mutex
+		seen     = map[string][]int{}
+		inflight atomic.Int32
+		peak     atomic.Int32
+	)
+	tracker := NewOffsetTracker()
+	p := NewOrderedProcessor(
+		func(e event) string { return e.orderID },
+		func(ctx context.Context, e event) error {
+			n := inflight.Add(1)
+			defer inflight.Add(-1)
+			for {
+				old := peak.Load()
+				if n <= old || peak.CompareAndSwap(old, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			seen[e.orderID] = append(seen[e.orderID], e.seq)
+			mu.Unlock()
+			return nil
+		},
+		func(e event, err error) { tracker.Done(0, e.offset) },
+		WithParallelism(3),
+		WithMaxPending(8),
+	)
+
+	orders := []string{"o-1", "o-2", "o-3", "o-4", "o-5"}
+	offset := int64(0)
+	for seq := 0; seq < 10; seq++ {
+		for _, id := range orders {
+			tracker.Track(0, offset)
+			if err := p.Submit(ctx, event{orderID: id, seq: seq, offset: offset}); err != nil {
+				t.Fatal(err)
+			}
+			offset++
+		}
+	}
+	if err := p.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range orders {
+		got := seen[id]
+		if len(got) != 10 {
+			t.Fatalf("%s handled %d events, want 10", id, len(got))
+		}
+		for i, seq := range got {
+			if seq != i {
+				t.Fatalf("%s events handled in order %v", id, got)
+			}
+		}
+	}
+	if peak.Load() > 3 {
+		t.Errorf("%d keys handled concurrently, want at most 3", peak.Load())
+	}
+	if got, ok := tracker.Committable(0); !ok || got != offset {
+		t.Errorf("Committable() = %d, %v, want %d", got, ok, offset)
+	}
+	if err := p.Submit(ctx, event{orderID: "o-1"}); !errors.Is(err, ErrProcessorClosed) {
+		t.Errorf("Submit() after Close = %v, want ErrProcessorClosed", err)
+	}
+}
+
+func TestOffsetTracker(t *testing.T) {
+	tracker := NewOffsetTracker()
+	for _, o := range []int64{10, 11, 12, 13} {
+		tracker.Track(1, o)
+	}
+	if _, ok := tracker.Committable(1); ok {
+		t.Error("Committable() before any message done")
+	}
+	tracker.Done(1, 12)
+	tracker.Done(1, 11)
+	if _, ok := tracker.Committable(1); ok {
+		t.Error("Committable() while the oldest message is in flight")
+	}
+	tracker.Done(1, 10)
+	if got, _ := tracker.Committable(1); got != 13 {
+		t.Errorf("Committable() = %d, want 13", got)
+	}
+	tracker.Done(1, 13)
+	if got, _ := tracker.Committable(1); got != 14 {
+		t.Errorf("Committable() = %d, want 14", got)
+	}
+	tracker.Revoke(1)
+	if got := tracker.Partitions(); len(got) != 0 {
+		t.Errorf("Partitions() = %v after Revoke", got)
+	}
+}
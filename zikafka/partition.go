@@ -0,0 +1,55 @@
+// Package zikafka provides Kafka producers and consumer groups independent
+// of the client library, adapted to the small interfaces of the package, e.g.
+// GroupClient, as well as partition selection compatible with the Java client
+// and ordered processing of the messages of a key.
+package zikafka
+
+import "encoding/binary"
+
+// Murmur2 returns the murmur2 hash of data, as computed by the Java client
+// (org.apache.kafka.common.utils.Utils.murmur2).
+func Murmur2(data []byte) int32 {
+	const (
+		seed uint32 = 0x9747b28c
+		m    uint32 = 0x5bd1e995
+		r           = 24
+	)
+	length := len(data)
+	h := seed ^ uint32(length)
+	for i := 0; i+4 <= length; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+	tail := data[length&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int32(h)
+}
+
+// Partition returns the partition of key among partitions, as chosen by the
+// default partitioner of the Java client, so services producing the events
+// of the same entities with different clients agree on their partition. Use
+// the entity ID as key, e.g. the order ID: messages are only ordered within
+// a partition.
+func Partition(key []byte, partitions int) int {
+	if partitions <= 0 {
+		return 0
+	}
+	return int(Murmur2(key)&0x7fffffff) % partitions
+}
@@ -0,0 +1,31 @@
+package zikafka
+
+import "testing"
+
+func TestMurmur2(t *testing.T) {
+	// Vectors of the Java client UtilsTest
+	for key, want := range map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	} {
+		if got := Murmur2([]byte(key)); got != want {
+			t.Errorf("Murmur2(%q) = %d, want %d", key, got, want)
+		}
+	}
+}
+
+func TestPartition(t *testing.T) {
+	// murmur2("foobar") & 0x7fffffff = 1357151166
+	if got := Partition([]byte("foobar"), 12); got != 1357151166%12 {
+		t.Errorf("Partition() = %d, want %d", got, 1357151166%12)
+	}
+	for _, key := range []string{"o-1", "o-2", "o-3"} {
+		if p := Partition([]byte(key), 3); p < 0 || p >= 3 {
+			t.Errorf("Partition(%q) = %d, out of range", key, p)
+		}
+	}
+}
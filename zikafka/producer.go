@@ -0,0 +1,62 @@
+package zikafka
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Producer sends records through a ProduceClient. Every record is traced in
+// a producer span, whose context is propagated in the headers of the
+// record, and counted in messaging_messages_produced_total by topic and
+// status.
+type Producer struct {
+	client      ProduceClient
+	instruments *revelio.MessagingInstruments
+}
+
+// NewProducer returns a Producer sending records with client.
+func NewProducer(client ProduceClient) *Producer {
+	return &Producer{
+		client:      client,
+		instruments: revelio.Must(revelio.NewMessagingInstruments(scope)),
+	}
+}
+
+// Produce sends record, blocking until it is acknowledged.
+func (p *Producer) Produce(ctx context.Context, record *Record) error {
+	attrs := revelio.MessagingAttributes("kafka", record.Topic, "")
+	ctx, span := tracer.Start(ctx, record.Topic+" send",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(revelio.MessagingOperationKey.String(revelio.MessagingOperationSend)),
+	)
+	defer span.End()
+
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier{record: record})
+	if record.Timestamp.IsZero() {
+		record.Timestamp = time.Now()
+	}
+	err := p.client.Produce(ctx, record)
+	if err != nil {
+		observe.RecordError(span, err)
+	} else {
+		span.SetAttributes(
+			revelio.MessagingPartitionKey.String(strconv.Itoa(int(record.Partition))),
+			attribute.Int64("messaging.kafka.offset", record.Offset),
+		)
+	}
+	p.instruments.Produced.Record(ctx, err, attrs...)
+	return err
+}
+
+// Close closes the client.
+func (p *Producer) Close() error {
+	return p.client.Close()
+}
@@ -0,0 +1,53 @@
+package zikafkafx
+
+import (
+	"github.com/divikraf/lumos/zihealth"
+	"github.com/divikraf/lumos/zikafka"
+	"github.com/divikraf/lumos/zin"
+	"github.com/divikraf/lumos/zin/zinfx"
+	"go.uber.org/fx"
+)
+
+type consumersResult struct {
+	fx.Out
+
+	Consumers *zikafka.Consumers
+	Checker   zihealth.Checker `group:"health.checker"`
+}
+
+// Provider provides the producer, given a zikafka.Dialer adapting the client
+// library, e.g. the franz.Dialer of franz-go
+var Provider = fx.Provide(zikafka.NewConfiguredProducer)
+
+// Invoker runs the consumers, provided as *zikafka.Consumers with a readiness
+// check failing while consumers are paused
+var Invoker = fx.Options(
+	fx.Provide(func(params zikafka.StartConsumersParams) (consumersResult, error) {
+		cs, err := zikafka.NewConsumers(params)
+		if err != nil {
+			return consumersResult{}, err
+		}
+		return consumersResult{Consumers: cs, Checker: cs}, nil
+	}),
+	fx.Invoke(func(*zikafka.Consumers) {}),
+)
+
+// AddConsumer adds the handler of the consumer name of the config
+func AddConsumer(name string, handle zikafka.Handler) fx.Option {
+	return fx.Supply(fx.Annotate(zikafka.ConsumerHandler{Name: name, Handle: handle}, fx.ResultTags(`group:"kafka-consumers"`)))
+}
+
+// AsConsumer annotates a constructor of a zikafka.ConsumerHandler, e.g. a
+// handler with dependencies, to provide it in the consumer handlers group
+func AsConsumer(constructor any) any {
+	return fx.Annotate(constructor, fx.ResultTags(`group:"kafka-consumers"`))
+}
+
+// AdminRoutes mounts the consumers admin endpoints on the additional server
+// name, see zinfx.Server, or on the main router when empty
+func AdminRoutes(server string) fx.Option {
+	return fx.Provide(fx.Annotate(
+		func(cs *zikafka.Consumers) zin.RouteRegistrar { return cs },
+		fx.ResultTags(zinfx.RoutesGroup(server)),
+	))
+}
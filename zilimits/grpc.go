@@ -0,0 +1,51 @@
+package zilimits
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor returns a gRPC interceptor admitting calls with l,
+// rejecting the others with codes.Unavailable.
+func UnaryServerInterceptor(l *Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		token, ok := l.Acquire(ctx)
+		if !ok {
+			return nil, status.Errorf(grpccodes.Unavailable, "concurrency limit of %d reached", l.Limit())
+		}
+		resp, err := handler(ctx, req)
+		release(token, err)
+		return resp, err
+	}
+}
+
+// UnaryClientInterceptor returns a gRPC interceptor limiting the calls in
+// flight to a server with l, failing the others with codes.Unavailable
+// without calling the server.
+func UnaryClientInterceptor(l *Limiter) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		token, ok := l.Acquire(ctx)
+		if !ok {
+			return status.Errorf(grpccodes.Unavailable, "client concurrency limit of %d reached", l.Limit())
+		}
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		release(token, err)
+		return err
+	}
+}
+
+// release releases the token of a call: timeouts and overloads are drops,
+// other failures are ignored.
+func release(token *Token, err error) {
+	switch status.Code(err) {
+	case grpccodes.OK:
+		token.Success()
+	case grpccodes.DeadlineExceeded, grpccodes.ResourceExhausted, grpccodes.Unavailable:
+		token.Dropped()
+	default:
+		token.Ignore()
+	}
+}
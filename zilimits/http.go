@@ -0,0 +1,54 @@
+package zilimits
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// ErrLimitExceeded is returned by the transports of NewTransport when the
+// limit is reached.
+var ErrLimitExceeded = errors.New("zilimits: concurrency limit exceeded")
+
+// limitedTransport is an http.RoundTripper limiting the requests in flight.
+type limitedTransport struct {
+	base    http.RoundTripper
+	limiter *Limiter
+}
+
+// NewTransport wraps base so the requests in flight are limited by l,
+// failing the others with ErrLimitExceeded without sending them. Timeouts
+// and 429, 503 and 504 responses are drops, shrinking the limit. The
+// latency is sampled once the response headers are received. As the base of
+// zihttpc.NewRetryTransport, it limits the attempts rather than the requests.
+func NewTransport(base http.RoundTripper, l *Limiter) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &limitedTransport{base: base, limiter: l}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, ok := t.limiter.Acquire(req.Context())
+	if !ok {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, ErrLimitExceeded
+	}
+	resp, err := t.base.RoundTrip(req)
+	switch {
+	case err != nil && errors.Is(err, context.DeadlineExceeded):
+		token.Dropped()
+	case err != nil:
+		token.Ignore()
+	case resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode == http.StatusServiceUnavailable,
+		resp.StatusCode == http.StatusGatewayTimeout:
+		token.Dropped()
+	default:
+		token.Success()
+	}
+	return resp, err
+}
@@ -0,0 +1,48 @@
+package zilimits
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTransport(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	l := New("client", Config{Algorithm: AlgorithmAIMD, InitialLimit: 4})
+	client := &http.Client{Transport: NewTransport(nil, l)}
+
+	for i := 0; i < 4; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if got := l.Limit(); got != 4 {
+		t.Errorf("limit = %d after sequential successes, want 4", got)
+	}
+
+	status = http.StatusTooManyRequests
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := l.Limit(); got != 3 {
+		t.Errorf("limit = %d after a 429, want 3", got)
+	}
+
+	full := New("full", Config{InitialLimit: 1})
+	full.Acquire(context.Background())
+	_, err = (&http.Client{Transport: NewTransport(nil, full)}).Get(srv.URL)
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("err = %v, want ErrLimitExceeded", err)
+	}
+}
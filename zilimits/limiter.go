@@ -0,0 +1,282 @@
+// Package zilimits provides adaptive concurrency limits: the limit of
+// requests in flight follows the observed latencies, instead of a static
+// limit always wrong for some traffic pattern. Limiters are used as a zin
+// middleware, gRPC interceptors or an HTTP client transport.
+package zilimits
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Algorithms of the limit
+const (
+	// AlgorithmGradient adjusts the limit by the gradient of the short term
+	// latency over the long term one, Vegas style: the limit grows while
+	// latencies are steady, and shrinks as soon as requests queue.
+	AlgorithmGradient = "gradient"
+	// AlgorithmAIMD increases the limit additively on successes, and
+	// decreases it multiplicatively on drops, i.e. timeouts and overloads.
+	AlgorithmAIMD = "aimd"
+)
+
+// Config configures a Limiter.
+type Config struct {
+	// Algorithm is the limit algorithm, AlgorithmGradient or AlgorithmAIMD
+	// (default: AlgorithmGradient).
+	Algorithm string `json:"algorithm" yaml:"algorithm"`
+	// InitialLimit is the limit before any sample (default: 20).
+	InitialLimit int `json:"initial_limit" yaml:"initial_limit"`
+	// MinLimit is the lower bound of the limit (default: 1).
+	MinLimit int `json:"min_limit" yaml:"min_limit"`
+	// MaxLimit is the upper bound of the limit (default: 1000).
+	MaxLimit int `json:"max_limit" yaml:"max_limit"`
+
+	// BackoffRatio multiplies the AIMD limit on drops (default: 0.9).
+	BackoffRatio float64 `json:"backoff_ratio" yaml:"backoff_ratio"`
+	// Timeout is the latency above which AIMD samples are drops (default:
+	// 5s).
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+
+	// Tolerance is the ratio of the short term latency over the long term
+	// one tolerated by the gradient before the limit shrinks (default: 1.5).
+	Tolerance float64 `json:"tolerance" yaml:"tolerance"`
+	// Smoothing is the weight of each gradient update of the limit, in
+	// (0, 1] (default: 0.2).
+	Smoothing float64 `json:"smoothing" yaml:"smoothing"`
+	// LongWindow is the number of samples of the long term latency average
+	// of the gradient (default: 600).
+	LongWindow int `json:"long_window" yaml:"long_window"`
+}
+
+func (c Config) withDefaults() Config {
+	if c.Algorithm == "" {
+		c.Algorithm = AlgorithmGradient
+	}
+	if c.InitialLimit <= 0 {
+		c.InitialLimit = 20
+	}
+	if c.MinLimit <= 0 {
+		c.MinLimit = 1
+	}
+	if c.MaxLimit <= 0 {
+		c.MaxLimit = 1000
+	}
+	if c.BackoffRatio <= 0 || c.BackoffRatio >= 1 {
+		c.BackoffRatio = 0.9
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 5 * time.Second
+	}
+	if c.Tolerance < 1 {
+		c.Tolerance = 1.5
+	}
+	if c.Smoothing <= 0 || c.Smoothing > 1 {
+		c.Smoothing = 0.2
+	}
+	if c.LongWindow <= 0 {
+		c.LongWindow = 600
+	}
+	return c
+}
+
+// algorithm computes the next limit from a sample: the latency of a request,
+// the number of requests in flight when it started, and whether it was
+// dropped.
+type algorithm interface {
+	update(limit float64, rtt time.Duration, inflight int, dropped bool) float64
+}
+
+// Limiter limits the requests in flight to an adaptive limit. Its limit,
+// requests in flight and rejected requests are recorded in
+// concurrency_limit, concurrency_inflight and concurrency_shed_total, by
+// limiter name.
+type Limiter struct {
+	config Config
+	alg    algorithm
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+
+	attrs         metric.MeasurementOption
+	limitGauge    metric.Int64Gauge
+	inflightGauge metric.Int64UpDownCounter
+	shed          metric.Int64Counter
+}
+
+// New returns a Limiter named name in its metrics.
+func New(name string, config Config) *Limiter {
+	config = config.withDefaults()
+	l := &Limiter{
+		config: config,
+		limit:  float64(config.InitialLimit),
+		attrs:  metric.WithAttributes(attribute.String("limiter", name)),
+		limitGauge: revelio.MustInt64Gauge(
+			"concurrency_limit",
+			"Adaptive concurrency limit, by limiter",
+		),
+		inflightGauge: revelio.MustInt64UpDownCounter(
+			"concurrency_inflight",
+			"Number of requests in flight, by limiter",
+		),
+		shed: revelio.MustInt64Counter(
+			"concurrency_shed_total",
+			"Number of requests rejected by an adaptive concurrency limit, by limiter",
+		),
+	}
+	if config.Algorithm == AlgorithmAIMD {
+		l.alg = &aimd{config: config}
+	} else {
+		l.alg = &gradient{config: config}
+	}
+	l.limitGauge.Record(context.Background(), int64(l.limit), l.attrs)
+	return l
+}
+
+// Limit returns the current limit.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// InFlight returns the number of requests in flight.
+func (l *Limiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inflight
+}
+
+// Acquire admits a request, returning its Token, or false when the limit is
+// reached and the request must be rejected. The token must be released
+// with one of its methods once the request is done.
+func (l *Limiter) Acquire(ctx context.Context) (*Token, bool) {
+	l.mu.Lock()
+	if l.inflight >= int(l.limit) {
+		l.mu.Unlock()
+		l.shed.Add(ctx, 1, l.attrs)
+		return nil, false
+	}
+	l.inflight++
+	inflight := l.inflight
+	l.mu.Unlock()
+
+	l.inflightGauge.Add(ctx, 1, l.attrs)
+	return &Token{l: l, ctx: ctx, start: time.Now(), inflight: inflight}, true
+}
+
+// release ends a request, sampling its latency unless ignored.
+func (l *Limiter) release(ctx context.Context, rtt time.Duration, inflight int, dropped, ignored bool) {
+	l.mu.Lock()
+	l.inflight--
+	changed := false
+	if !ignored {
+		changed = l.sample(rtt, inflight, dropped)
+	}
+	limit := int64(l.limit)
+	l.mu.Unlock()
+
+	l.inflightGauge.Add(ctx, -1, l.attrs)
+	if changed {
+		l.limitGauge.Record(ctx, limit, l.attrs)
+	}
+}
+
+// sample updates the limit, reporting whether it changed. l.mu is held.
+func (l *Limiter) sample(rtt time.Duration, inflight int, dropped bool) bool {
+	limit := l.alg.update(l.limit, rtt, inflight, dropped)
+	limit = math.Max(float64(l.config.MinLimit), math.Min(float64(l.config.MaxLimit), limit))
+	changed := int(limit) != int(l.limit)
+	l.limit = limit
+	return changed
+}
+
+// Token is an admitted request.
+type Token struct {
+	l        *Limiter
+	ctx      context.Context
+	start    time.Time
+	inflight int
+	once     sync.Once
+}
+
+// Success releases the token of a request served normally, sampling its
+// latency.
+func (t *Token) Success() {
+	t.once.Do(func() { t.l.release(t.ctx, time.Since(t.start), t.inflight, false, false) })
+}
+
+// Dropped releases the token of a request which timed out or was rejected
+// by an overloaded dependency, shrinking the limit.
+func (t *Token) Dropped() {
+	t.once.Do(func() { t.l.release(t.ctx, time.Since(t.start), t.inflight, true, false) })
+}
+
+// Ignore releases the token without sampling, e.g. for requests failing
+// fast on invalid input, whose latency tells nothing about the load.
+func (t *Token) Ignore() {
+	t.once.Do(func() { t.l.release(t.ctx, 0, t.inflight, false, true) })
+}
+
+// aimd is the additive increase, multiplicative decrease algorithm.
+type aimd struct {
+	config Config
+}
+
+func (a *aimd) update(limit float64, rtt time.Duration, inflight int, dropped bool) float64 {
+	if dropped || rtt > a.config.Timeout {
+		return limit * a.config.BackoffRatio
+	}
+	// Only grow when the limit was actually used
+	if float64(inflight)*2 >= limit {
+		return limit + 1
+	}
+	return limit
+}
+
+// gradient is the gradient algorithm of Netflix concurrency-limits: the
+// limit is scaled by the ratio of the long term latency over the short term
+// one, plus a queue allowance of sqrt(limit) for growth.
+type gradient struct {
+	config  Config
+	longRTT float64
+	samples int
+}
+
+func (g *gradient) update(limit float64, rtt time.Duration, inflight int, dropped bool) float64 {
+	short := float64(rtt)
+	if short <= 0 {
+		return limit
+	}
+	g.samples++
+	if g.samples == 1 {
+		g.longRTT = short
+	} else {
+		// Exponential average, as a plain average over the first samples
+		n := math.Min(float64(g.samples), float64(g.config.LongWindow))
+		g.longRTT += (short - g.longRTT) * 2 / (n + 1)
+	}
+	// Let the long term latency recover quickly from a past steady increase
+	if g.longRTT/short > 2 {
+		g.longRTT *= 0.95
+	}
+
+	// Drops halve the limit, without growth allowance
+	next := limit * 0.5
+	if !dropped {
+		ratio := math.Max(0.5, math.Min(1, g.config.Tolerance*g.longRTT/short))
+		// An application limited, not load limited, limit isn't increased
+		if ratio == 1 && float64(inflight)*2 < limit {
+			return limit
+		}
+		next = limit*ratio + math.Sqrt(limit)
+	}
+	return limit*(1-g.config.Smoothing) + next*g.config.Smoothing
+}
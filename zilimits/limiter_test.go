@@ -0,0 +1,85 @@
+package zilimits
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestLimiterAcquire(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	ctx := context.Background()
+	l := New("orders", Config{InitialLimit: 2})
+
+	first, ok := l.Acquire(ctx)
+	if !ok {
+		t.Fatal("first request rejected")
+	}
+	if _, ok := l.Acquire(ctx); !ok {
+		t.Fatal("second request rejected")
+	}
+	if _, ok := l.Acquire(ctx); ok {
+		t.Fatal("request over the limit admitted")
+	}
+	first.Ignore()
+	first.Ignore()
+	if got := l.InFlight(); got != 1 {
+		t.Errorf("InFlight() = %d after a release, want 1", got)
+	}
+	if _, ok := l.Acquire(ctx); !ok {
+		t.Error("request rejected after a release")
+	}
+	reveliotest.AssertCounterValue(t, s, "concurrency_shed_total", 1, attribute.String("limiter", "orders"))
+}
+
+func TestAIMD(t *testing.T) {
+	l := New("aimd", Config{Algorithm: AlgorithmAIMD, InitialLimit: 10, Timeout: time.Second})
+
+	l.sample(10*time.Millisecond, 2, false)
+	if got := l.Limit(); got != 10 {
+		t.Errorf("limit = %d after an underused success, want 10", got)
+	}
+	l.sample(10*time.Millisecond, 8, false)
+	if got := l.Limit(); got != 11 {
+		t.Errorf("limit = %d after a success, want 11", got)
+	}
+	l.sample(10*time.Millisecond, 8, true)
+	if got := l.Limit(); got != 9 {
+		t.Errorf("limit = %d after a drop, want 9", got)
+	}
+	l.sample(2*time.Second, 8, false)
+	if got := l.Limit(); got != 8 {
+		t.Errorf("limit = %d after a timeout, want 8", got)
+	}
+}
+
+func TestGradient(t *testing.T) {
+	l := New("gradient", Config{InitialLimit: 20, MaxLimit: 200})
+
+	// Steady latencies at full use grow the limit
+	for i := 0; i < 50; i++ {
+		l.sample(10*time.Millisecond, l.Limit(), false)
+	}
+	grown := l.Limit()
+	if grown <= 20 {
+		t.Fatalf("limit = %d with steady latencies, want it grown", grown)
+	}
+
+	// Queueing shrinks it
+	for i := 0; i < 20; i++ {
+		l.sample(50*time.Millisecond, l.Limit(), false)
+	}
+	if got := l.Limit(); got >= grown {
+		t.Errorf("limit = %d with increasing latencies, want below %d", got, grown)
+	}
+
+	for i := 0; i < 200; i++ {
+		l.sample(time.Second, 1, true)
+	}
+	if got := l.Limit(); got != 1 {
+		t.Errorf("limit = %d after drops, want the min limit", got)
+	}
+}
@@ -0,0 +1,53 @@
+package zilimits
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/divikraf/lumos/zin"
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware returns a zin middleware admitting requests with l, rejecting
+// the others with zin.ErrServiceUnavailable. Requests timing out or ending
+// with a 503 or 504 are drops, other server errors are ignored as their
+// latency tells nothing about the load.
+func Middleware(l *Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := l.Acquire(c.Request.Context())
+		if !ok {
+			zin.AbortWithError(c, zin.ErrServiceUnavailable)
+			return
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				token.Ignore()
+				panic(r)
+			}
+			switch status := responseStatus(c); {
+			case errors.Is(c.Request.Context().Err(), context.DeadlineExceeded),
+				status == http.StatusServiceUnavailable, status == http.StatusGatewayTimeout:
+				token.Dropped()
+			case status >= http.StatusInternalServerError:
+				token.Ignore()
+			default:
+				token.Success()
+			}
+		}()
+		c.Next()
+	}
+}
+
+// responseStatus returns the status of the response, or of the error pending
+// for zin.ErrorMiddleware.
+func responseStatus(c *gin.Context) int {
+	if c.Writer.Written() || len(c.Errors) == 0 {
+		return c.Writer.Status()
+	}
+	var apiErr *zin.APIError
+	if errors.As(c.Errors.Last().Err, &apiErr) {
+		return apiErr.Status
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,54 @@
+package zilimits
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/divikraf/lumos/zin"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l := New("http", Config{Algorithm: AlgorithmAIMD, InitialLimit: 1})
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	router := gin.New()
+	router.Use(zin.ErrorMiddleware(), Middleware(l))
+	router.GET("/slow", func(c *gin.Context) {
+		close(started)
+		<-release
+		c.Status(http.StatusOK)
+	})
+	router.GET("/unavailable", zin.Handle(func(c *gin.Context) error {
+		return zin.ErrServiceUnavailable
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("request over the limit = %d, want 503", w.Code)
+	}
+	close(release)
+	<-done
+	if got := l.Limit(); got != 2 {
+		t.Errorf("limit = %d after a success, want 2", got)
+	}
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/unavailable", nil))
+	if got := l.Limit(); got != 1 {
+		t.Errorf("limit = %d after a 503, want it decreased to 1", got)
+	}
+	if got := l.InFlight(); got != 0 {
+		t.Errorf("InFlight() = %d, want 0", got)
+	}
+}
@@ -0,0 +1,326 @@
+// Package zilock provides distributed locks stored in Redis.
+//
+// A lock is a key set if absent to a random value, expiring after a TTL,
+// extended in the background while held, and deleted when released only if
+// it still holds the value. Every acquisition also increments a fencing
+// token, strictly increasing per key, to be passed to the resources written
+// under the lock so they reject the writes of holders which lost it, e.g.
+// after a long pause:
+//
+//	UPDATE stocks SET quantity = ?, fence = ? WHERE sku = ? AND fence < ?
+//
+// The locks live on a single Redis instance or cluster node (the lock and
+// its token share a hash slot), so they are as available as that node.
+package zilock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const tracerName = "github.com/divikraf/lumos/zilock"
+
+var (
+	// ErrNotAcquired is returned by TryLock for locks held by others.
+	ErrNotAcquired = errors.New("zilock: lock not acquired")
+	// ErrLockLost is returned for locks which were taken by others, or about
+	// to expire, while held.
+	ErrLockLost = errors.New("zilock: lock lost")
+)
+
+func init() {
+	revelio.RegisterErrorType(ErrNotAcquired, "not_acquired")
+	revelio.RegisterErrorType(ErrLockLost, "lock_lost")
+}
+
+// acquireScript sets KEYS[1] to ARGV[1] when absent, expiring after ARGV[2]
+// milliseconds, and returns the incremented fencing token KEYS[2], or 0.
+var acquireScript = redis.NewScript(`
+if not redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return 0
+end
+return redis.call('INCR', KEYS[2])
+`)
+
+// extendScript sets the expiration of KEYS[1] to ARGV[2] milliseconds when
+// its value is ARGV[1].
+var extendScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+`)
+
+// releaseScript deletes KEYS[1] when its value is ARGV[1].
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+return redis.call('DEL', KEYS[1])
+`)
+
+// Locker acquires the locks of a kind, e.g. of the stocks of the products.
+type Locker struct {
+	client        redis.UniversalClient
+	name          string
+	prefix        string
+	ttl           time.Duration
+	retryInterval time.Duration
+
+	wait revelio.DurationRecorder
+	hold revelio.DurationRecorder
+}
+
+// Option configures a Locker.
+type Option func(*Locker)
+
+// WithPrefix sets the prefix of the Redis keys of the locks (default:
+// "lock:").
+func WithPrefix(prefix string) Option {
+	return func(l *Locker) {
+		l.prefix = prefix
+	}
+}
+
+// WithTTL sets the expiration of the locks, extended every third of it
+// while held (default: 30s). It bounds how long a lock outlives a crashed
+// holder.
+func WithTTL(ttl time.Duration) Option {
+	return func(l *Locker) {
+		l.ttl = ttl
+	}
+}
+
+// WithRetryInterval sets the interval of the attempts of Lock to acquire a
+// held lock (default: 100ms).
+func WithRetryInterval(d time.Duration) Option {
+	return func(l *Locker) {
+		l.retryInterval = d
+	}
+}
+
+// New returns the Locker of the locks named name, stored in client. name
+// identifies them in the telemetry and must come from a small bounded set.
+// The time waited for the locks is recorded in the lock_wait_duration_ms
+// metric by lock and status, and the time they are held in the
+// lock_hold_duration_ms metric by lock and status, failed if lost.
+func New(client redis.UniversalClient, name string, opts ...Option) *Locker {
+	l := &Locker{
+		client:        client,
+		name:          name,
+		prefix:        "lock:",
+		ttl:           30 * time.Second,
+		retryInterval: 100 * time.Millisecond,
+		wait:          revelio.MustDuration("lock_wait_duration_ms", "Time waited to acquire locks in milliseconds, by lock and status"),
+		hold:          revelio.MustDuration("lock_hold_duration_ms", "Time locks were held in milliseconds, by lock and status"),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// keys returns the Redis keys of the lock key and of its fencing token,
+// hash tagged to the same cluster slot.
+func (l *Locker) keys(key string) []string {
+	k := l.prefix + "{" + l.name + ":" + key + "}"
+	return []string{k, k + ":fence"}
+}
+
+// TryLock acquires the lock key, or returns ErrNotAcquired if it is held.
+func (l *Locker) TryLock(ctx context.Context, key string) (*Lock, error) {
+	start := time.Now()
+	lock, err := l.acquire(ctx, key)
+	if err == nil && lock == nil {
+		err = ErrNotAcquired
+	}
+	l.wait.Record(ctx, time.Since(start), append([]attribute.KeyValue{attribute.String("lock", l.name)}, revelio.ResultAttributes(err)...)...)
+	return lock, err
+}
+
+// Lock acquires the lock key, waiting until it is released or ctx is done.
+func (l *Locker) Lock(ctx context.Context, key string) (lock *Lock, err error) {
+	start := time.Now()
+	defer func() {
+		l.wait.Record(ctx, time.Since(start), append([]attribute.KeyValue{attribute.String("lock", l.name)}, revelio.ResultAttributes(err)...)...)
+	}()
+	ticker := time.NewTicker(l.retryInterval)
+	defer ticker.Stop()
+	for {
+		if lock, err = l.acquire(ctx, key); lock != nil || err != nil {
+			return lock, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// WithLock runs fn holding the lock key, acquired with Lock, in a span. The
+// context of fn is canceled with ErrLockLost as cause if the lock is lost,
+// and ErrLockLost is then returned.
+func (l *Locker) WithLock(ctx context.Context, key string, fn func(ctx context.Context) error) (err error) {
+	lock, err := l.Lock(ctx, key)
+	if err != nil {
+		return err
+	}
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "lock "+l.name)
+	span.SetAttributes(attribute.String("lock.key", key), attribute.Int64("lock.token", lock.Token()))
+	defer func() {
+		if err != nil {
+			observe.RecordError(span, err)
+		}
+		span.End()
+	}()
+
+	fnCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	stop := context.AfterFunc(lock.ctx, func() { cancel(ErrLockLost) })
+	err = fn(fnCtx)
+	stop()
+	return errors.Join(err, lock.Unlock(context.WithoutCancel(ctx)))
+}
+
+// acquire acquires the lock key, or returns nil if it is held.
+func (l *Locker) acquire(ctx context.Context, key string) (*Lock, error) {
+	b := make([]byte, 16)
+	rand.Read(b)
+	value := hex.EncodeToString(b)
+	keys := l.keys(key)
+	// The lock is valid for a TTL from the request, not from its response
+	sent := time.Now()
+	token, err := acquireScript.Run(ctx, l.client, keys, value, l.ttl.Milliseconds()).Int64()
+	if err != nil || token == 0 {
+		return nil, err
+	}
+
+	lctx, cancel := context.WithCancelCause(context.Background())
+	lock := &Lock{
+		locker:   l,
+		key:      key,
+		redisKey: keys[0],
+		value:    value,
+		token:    token,
+		acquired: sent,
+		ctx:      lctx,
+		cancel:   cancel,
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go lock.refresh(zilog.FromContext(ctx))
+	return lock, nil
+}
+
+// Lock is a held lock.
+type Lock struct {
+	locker   *Locker
+	key      string
+	redisKey string
+	value    string
+	token    int64
+	acquired time.Time
+
+	// ctx is canceled once the lock is released or lost
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	stop    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+	err     error
+}
+
+// Key returns the key of the lock.
+func (l *Lock) Key() string {
+	return l.key
+}
+
+// Token returns the fencing token of the lock, greater than the tokens of
+// the previous holders of the key.
+func (l *Lock) Token() int64 {
+	return l.token
+}
+
+// Done returns a channel closed once the lock is released or lost.
+func (l *Lock) Done() <-chan struct{} {
+	return l.ctx.Done()
+}
+
+// Err returns ErrLockLost once the lock is lost, and nil otherwise.
+func (l *Lock) Err() error {
+	if errors.Is(context.Cause(l.ctx), ErrLockLost) {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// refresh extends the lock every third of the TTL until it is released, or
+// lost: taken by others, or with less than half the TTL left after failed
+// extensions, e.g. when Redis is unreachable. The lock is then lost after two
+// missed extensions, a third of the TTL before Redis expires it, rather than
+// once others may already hold it.
+func (l *Lock) refresh(logger *zerolog.Logger) {
+	defer close(l.stopped)
+	ttl := l.locker.ttl
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	valid := l.acquired.Add(ttl)
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+		sent := time.Now()
+		ctx, cancel := context.WithTimeout(l.ctx, ttl/3)
+		ok, err := extendScript.Run(ctx, l.locker.client, []string{l.redisKey}, l.value, ttl.Milliseconds()).Bool()
+		cancel()
+		switch {
+		case err == nil && ok:
+			valid = sent.Add(ttl)
+			continue
+		case err == nil:
+			logger.Warn().Str("lock", l.locker.name).Str("key", l.key).Msg("Lock taken by another holder")
+		case time.Until(valid) >= ttl/2:
+			logger.Warn().Err(err).Str("lock", l.locker.name).Str("key", l.key).Msg("Failed to extend lock")
+			continue
+		default:
+			logger.Error().Err(err).Str("lock", l.locker.name).Str("key", l.key).Msg("Lock about to expire while held")
+		}
+		l.cancel(ErrLockLost)
+		return
+	}
+}
+
+// Unlock releases the lock, or returns ErrLockLost if it was lost. It is
+// safe to call several times.
+func (l *Lock) Unlock(ctx context.Context) error {
+	l.once.Do(func() {
+		close(l.stop)
+		<-l.stopped
+		l.err = l.Err()
+		if l.err == nil {
+			var released bool
+			released, l.err = releaseScript.Run(ctx, l.locker.client, []string{l.redisKey}, l.value).Bool()
+			if l.err == nil && !released {
+				l.err = ErrLockLost
+			}
+		}
+		l.cancel(l.err)
+		l.locker.hold.Record(ctx, time.Since(l.acquired), append([]attribute.KeyValue{attribute.String("lock", l.locker.name)}, revelio.ResultAttributes(l.err)...)...)
+	})
+	return l.err
+}
@@ -0,0 +1,235 @@
+package zilock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// fakeStore answers the scripts of the locks from memory, as a hook never
+// reaching the network. Expirations are ignored, and the extensions fail
+// while unreachable is set.
+type fakeStore struct {
+	mu          sync.Mutex
+	values      map[string]string
+	unreachable bool
+}
+
+func newFakeClient(t *testing.T) (*redis.Client, *fakeStore) {
+	t.Helper()
+	store := &fakeStore{values: map[string]string{}}
+	client := redis.NewClient(&redis.Options{Addr: "fake:6379"})
+	client.AddHook(store)
+	t.Cleanup(func() { client.Close() })
+	return client, store
+}
+
+func (s *fakeStore) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, fmt.Errorf("fake store does not dial")
+	}
+}
+
+func (s *fakeStore) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func (s *fakeStore) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		args := cmd.Args()
+		if len(args) < 4 || args[0] != "evalsha" {
+			cmd.SetErr(fmt.Errorf("fake store: unsupported command %v", args[0]))
+			return cmd.Err()
+		}
+		c := cmd.(*redis.Cmd)
+		key, value := args[3].(string), fmt.Sprint(args[len(args)-2])
+		switch args[1] {
+		case acquireScript.Hash():
+			value = fmt.Sprint(args[5])
+			if _, held := s.values[key]; held {
+				c.SetVal(int64(0))
+				return nil
+			}
+			s.values[key] = value
+			n, _ := strconv.ParseInt(s.values[args[4].(string)], 10, 64)
+			s.values[args[4].(string)] = strconv.FormatInt(n+1, 10)
+			c.SetVal(n + 1)
+		case extendScript.Hash():
+			if s.unreachable {
+				c.SetErr(errors.New("connection refused"))
+				return c.Err()
+			}
+			c.SetVal(boolInt(s.values[key] == value))
+		case releaseScript.Hash():
+			value = fmt.Sprint(args[4])
+			if s.values[key] != value {
+				c.SetVal(int64(0))
+				return nil
+			}
+			delete(s.values, key)
+			c.SetVal(int64(1))
+		}
+		return nil
+	}
+}
+
+func (s *fakeStore) set(key, value string) {
+	s.mu.Lock()
+	s.values[key] = value
+	s.mu.Unlock()
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func TestTryLock(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	ctx := context.Background()
+	client, store := newFakeClient(t)
+	locker := New(client, "stock")
+
+	lock, err := locker.TryLock(ctx, "sku-1")
+	if err != nil {
+		t.Fatalf("TryLock() = %v", err)
+	}
+	if store.values["lock:{stock:sku-1}"] == "" || lock.Token() != 1 {
+		t.Fatalf("values = %v, token = %d", store.values, lock.Token())
+	}
+	if _, err := locker.TryLock(ctx, "sku-1"); !errors.Is(err, ErrNotAcquired) {
+		t.Fatalf("TryLock() of a held lock = %v, want ErrNotAcquired", err)
+	}
+	if err := lock.Unlock(ctx); err != nil {
+		t.Fatalf("Unlock() = %v", err)
+	}
+	if err := lock.Unlock(ctx); err != nil {
+		t.Errorf("second Unlock() = %v", err)
+	}
+	select {
+	case <-lock.Done():
+	default:
+		t.Error("Done() not closed after Unlock")
+	}
+
+	lock, err = locker.TryLock(ctx, "sku-1")
+	if err != nil || lock.Token() != 2 {
+		t.Fatalf("TryLock() after Unlock = %v, token %d, want token 2", err, lock.Token())
+	}
+	lock.Unlock(ctx)
+
+	wait := reveliotest.CollectHistogram(t, s, "lock_wait_duration_ms", attribute.String("lock", "stock"), attribute.String("error.type", "not_acquired"))
+	if wait.Count != 1 {
+		t.Errorf("not acquired waits = %d, want 1", wait.Count)
+	}
+	if hold := reveliotest.CollectHistogram(t, s, "lock_hold_duration_ms", attribute.String("status", "success")); hold.Count != 2 {
+		t.Errorf("holds = %d, want 2", hold.Count)
+	}
+}
+
+func TestLockWaits(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newFakeClient(t)
+	locker := New(client, "stock", WithRetryInterval(5*time.Millisecond))
+
+	held, err := locker.TryLock(ctx, "sku-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(20*time.Millisecond, func() { held.Unlock(ctx) })
+	lock, err := locker.Lock(ctx, "sku-1")
+	if err != nil {
+		t.Fatalf("Lock() = %v", err)
+	}
+	lock.Unlock(ctx)
+
+	held, _ = locker.TryLock(ctx, "sku-1")
+	defer held.Unlock(ctx)
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := locker.Lock(ctx, "sku-1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Lock() of a held lock = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestWithLockLost(t *testing.T) {
+	ctx := context.Background()
+	client, store := newFakeClient(t)
+	locker := New(client, "stock", WithTTL(30*time.Millisecond))
+
+	err := locker.WithLock(ctx, "sku-1", func(ctx context.Context) error {
+		// Another holder takes the lock, e.g. after it expired
+		store.set("lock:{stock:sku-1}", "other")
+		<-ctx.Done()
+		if cause := context.Cause(ctx); !errors.Is(cause, ErrLockLost) {
+			t.Errorf("cause = %v, want ErrLockLost", cause)
+		}
+		return nil
+	})
+	if !errors.Is(err, ErrLockLost) {
+		t.Errorf("WithLock() = %v, want ErrLockLost", err)
+	}
+	if store.values["lock:{stock:sku-1}"] != "other" {
+		t.Error("the lock of the other holder was released")
+	}
+}
+
+func TestLockLostBeforeExpiring(t *testing.T) {
+	ctx := context.Background()
+	client, store := newFakeClient(t)
+	ttl := 90 * time.Millisecond
+	locker := New(client, "stock", WithTTL(ttl))
+
+	lock, err := locker.TryLock(ctx, "sku-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Unlock(ctx)
+	store.mu.Lock()
+	store.unreachable = true
+	store.mu.Unlock()
+
+	select {
+	case <-lock.Done():
+	case <-time.After(ttl):
+		t.Fatal("lock still held once Redis expired it")
+	}
+	if elapsed := time.Since(lock.acquired); elapsed < ttl/2 {
+		t.Errorf("lock lost after %s, want two missed extensions", elapsed)
+	}
+	if !errors.Is(lock.Err(), ErrLockLost) {
+		t.Errorf("Err() = %v, want ErrLockLost", lock.Err())
+	}
+}
+
+func TestWithLock(t *testing.T) {
+	ctx := context.Background()
+	client, store := newFakeClient(t)
+	locker := New(client, "stock", WithTTL(30*time.Millisecond))
+
+	boom := errors.New("boom")
+	err := locker.WithLock(ctx, "sku-1", func(ctx context.Context) error {
+		// Held across several extensions
+		time.Sleep(50 * time.Millisecond)
+		return boom
+	})
+	if !errors.Is(err, boom) || errors.Is(err, ErrLockLost) {
+		t.Errorf("WithLock() = %v, want the error of fn", err)
+	}
+	if _, held := store.values["lock:{stock:sku-1}"]; held {
+		t.Error("lock not released")
+	}
+}
@@ -0,0 +1,53 @@
+package zilog
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AuditChannel is the channel field of the audit events, for the log
+// pipeline to route them to their own sink, retained longer than the logs.
+const AuditChannel = "audit"
+
+// DefaultAuditLogger is the logger of the audit channel. It writes to stdout
+// synchronously, whatever the global level: unlike the logs going through
+// DefaultDiode, audit events are never dropped.
+var DefaultAuditLogger = NewAuditLogger(os.Stdout)
+
+type auditKey struct{}
+
+// NewAuditLogger returns a logger of the audit channel writing to w.
+func NewAuditLogger(w io.Writer) *zerolog.Logger {
+	logger := zerolog.New(zerolog.SyncWriter(w)).With().
+		Timestamp().
+		Str("channel", AuditChannel).
+		Logger()
+	return &logger
+}
+
+// WithAuditLogger returns ctx with the audit logger, e.g. one writing to a
+// buffer in tests.
+func WithAuditLogger(ctx context.Context, logger *zerolog.Logger) context.Context {
+	return context.WithValue(ctx, auditKey{}, logger)
+}
+
+// Audit returns an event of the audit logger of ctx (default:
+// DefaultAuditLogger), carrying the W3C Trace Context IDs of the span of ctx.
+// The event has no level, so it's written whatever the global level:
+//
+//	zilog.Audit(ctx).Str("actor", userID).Str("action", "refund").Msg("refund issued")
+func Audit(ctx context.Context) *zerolog.Event {
+	logger, ok := ctx.Value(auditKey{}).(*zerolog.Logger)
+	if !ok {
+		logger = DefaultAuditLogger
+	}
+	e := logger.Log()
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		e = e.Str("trace_id", sc.TraceID().String()).Str("span_id", sc.SpanID().String())
+	}
+	return e
+}
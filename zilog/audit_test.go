@@ -0,0 +1,48 @@
+package zilog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestAudit(t *testing.T) {
+	var logs bytes.Buffer
+	ctx := WithAuditLogger(context.Background(), NewAuditLogger(&logs))
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx = trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+
+	// Audit events are written whatever the global level
+	level := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+	defer zerolog.SetGlobalLevel(level)
+	Audit(ctx).Str("actor", "user-1").Msg("refund issued")
+
+	var entry map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("audit event %q: %v", logs.String(), err)
+	}
+	want := map[string]any{
+		"channel":  AuditChannel,
+		"actor":    "user-1",
+		"message":  "refund issued",
+		"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736",
+		"span_id":  "00f067aa0ba902b7",
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("%s = %v, want %v", k, entry[k], v)
+		}
+	}
+	if _, ok := entry["level"]; ok {
+		t.Errorf("audit event with a level: %v", entry)
+	}
+}
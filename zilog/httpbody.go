@@ -0,0 +1,173 @@
+package zilog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+// DefaultMaxLoggedBodySize is the default BodyFields.MaxBodySize.
+const DefaultMaxLoggedBodySize = 64 << 10
+
+// BodyFields declares the JSON body fields logged by a route. Fields are
+// dotted paths, e.g. "customer.id"; arrays are traversed, so "items.sku" logs
+// the sku of every item. A path ending on an object logs the whole object.
+type BodyFields struct {
+	// Request are the logged fields of the request body.
+	Request []string
+	// Response are the logged fields of the response body.
+	Response []string
+	// MaxBodySize is the size above which bodies aren't logged (default:
+	// DefaultMaxLoggedBodySize).
+	MaxBodySize int
+}
+
+// LogBodyFields returns a route handler adding the allowlisted fields of the
+// JSON request and response bodies to the HTTPLogMiddleware log line, as
+// request.fields and response.fields. Other fields, and bodies which aren't
+// JSON or are too large, aren't logged.
+func LogBodyFields(fields BodyFields) gin.HandlerFunc {
+	if fields.MaxBodySize <= 0 {
+		fields.MaxBodySize = DefaultMaxLoggedBodySize
+	}
+	request := splitPaths(fields.Request)
+	response := splitPaths(fields.Response)
+
+	return func(c *gin.Context) {
+		logger := zerolog.Ctx(c.Request.Context())
+		if logger == zerolog.DefaultContextLogger || logger.GetLevel() == zerolog.Disabled {
+			// Not behind HTTPLogMiddleware, don't update the shared logger
+			c.Next()
+			return
+		}
+
+		if len(request) > 0 && isJSON(c.ContentType()) && c.Request.Body != nil {
+			body, err := io.ReadAll(io.LimitReader(c.Request.Body, int64(fields.MaxBodySize)+1))
+			c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(body), c.Request.Body), c.Request.Body}
+			if err == nil && len(body) <= fields.MaxBodySize {
+				addFields(logger, "request.fields", body, request)
+			}
+		}
+
+		var capture *captureWriter
+		if len(response) > 0 {
+			capture = &captureWriter{ResponseWriter: c.Writer, limit: fields.MaxBodySize}
+			c.Writer = capture
+		}
+
+		c.Next()
+
+		if capture != nil && !capture.overflow && isJSON(capture.Header().Get("Content-Type")) {
+			addFields(logger, "response.fields", capture.body.Bytes(), response)
+		}
+	}
+}
+
+// addFields adds the allowlisted fields of the JSON body to logger as key.
+func addFields(logger *zerolog.Logger, key string, body []byte, paths [][]string) {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return
+	}
+	picked, ok := pickFields(v, paths)
+	if !ok {
+		return
+	}
+	logger.UpdateContext(func(c zerolog.Context) zerolog.Context {
+		return c.Interface(key, picked)
+	})
+}
+
+// pickFields returns the parts of v at paths, keeping its structure.
+func pickFields(v any, paths [][]string) (any, bool) {
+	switch v := v.(type) {
+	case map[string]any:
+		children := map[string][][]string{}
+		whole := map[string]bool{}
+		for _, p := range paths {
+			if len(p) == 1 {
+				whole[p[0]] = true
+			} else {
+				children[p[0]] = append(children[p[0]], p[1:])
+			}
+		}
+		out := map[string]any{}
+		for name, child := range v {
+			if whole[name] {
+				out[name] = child
+			} else if sub, ok := children[name]; ok {
+				if picked, ok := pickFields(child, sub); ok {
+					out[name] = picked
+				}
+			}
+		}
+		return out, len(out) > 0
+	case []any:
+		out := make([]any, 0, len(v))
+		for _, item := range v {
+			if picked, ok := pickFields(item, paths); ok {
+				out = append(out, picked)
+			}
+		}
+		return out, len(out) > 0
+	}
+	// Paths continue past a scalar
+	return nil, false
+}
+
+func splitPaths(fields []string) [][]string {
+	paths := make([][]string, 0, len(fields))
+	for _, f := range fields {
+		if f != "" {
+			paths = append(paths, strings.Split(f, "."))
+		}
+	}
+	return paths
+}
+
+func isJSON(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (t == "application/json" || strings.HasSuffix(t, "+json"))
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// captureWriter keeps the response body, up to limit bytes.
+type captureWriter struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	w.capture(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *captureWriter) capture(b []byte) {
+	if w.overflow {
+		return
+	}
+	if w.body.Len()+len(b) > w.limit {
+		w.overflow = true
+		w.body.Reset()
+		return
+	}
+	w.body.Write(b)
+}
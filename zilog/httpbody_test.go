@@ -0,0 +1,96 @@
+package zilog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+func TestLogBodyFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var logs bytes.Buffer
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		logger := zerolog.New(&logs)
+		c.Request = c.Request.WithContext(logger.WithContext(c.Request.Context()))
+	}, HTTPLogMiddleware())
+
+	router.POST("/orders", LogBodyFields(BodyFields{
+		Request:  []string{"customer.id", "items.sku", "missing.field"},
+		Response: []string{"id", "status"},
+	}), func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil || !strings.Contains(string(body), "4111111111111111") {
+			t.Errorf("handler body = %q, %v, want the whole body", body, err)
+		}
+		c.JSON(http.StatusCreated, gin.H{"id": "o-1", "status": "pending", "token": "secret"})
+	})
+
+	body := `{"customer":{"id":"c-1","email":"jane@example.com"},"card":"4111111111111111","items":[{"sku":"A","qty":1},{"sku":"B","qty":2}]}`
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+		t.Fatalf("invalid log line %q: %v", logs.String(), err)
+	}
+	wantRequest := map[string]any{
+		"customer": map[string]any{"id": "c-1"},
+		"items":    []any{map[string]any{"sku": "A"}, map[string]any{"sku": "B"}},
+	}
+	if !reflect.DeepEqual(line["request.fields"], wantRequest) {
+		t.Errorf("request.fields = %v, want %v", line["request.fields"], wantRequest)
+	}
+	wantResponse := map[string]any{"id": "o-1", "status": "pending"}
+	if !reflect.DeepEqual(line["response.fields"], wantResponse) {
+		t.Errorf("response.fields = %v, want %v", line["response.fields"], wantResponse)
+	}
+	for _, secret := range []string{"4111111111111111", "jane@example.com", "secret"} {
+		if strings.Contains(logs.String(), secret) {
+			t.Errorf("log line contains %q: %s", secret, logs.String())
+		}
+	}
+}
+
+func TestLogBodyFieldsSkipsLargeAndNonJSONBodies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var logs bytes.Buffer
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		logger := zerolog.New(&logs)
+		c.Request = c.Request.WithContext(logger.WithContext(c.Request.Context()))
+	}, HTTPLogMiddleware())
+	router.POST("/", LogBodyFields(BodyFields{
+		Request:     []string{"id"},
+		Response:    []string{"id"},
+		MaxBodySize: 16,
+	}), func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.Data(http.StatusOK, "application/json", body)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id":"0123456789abcdef"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if got := rec.Body.String(); got != `{"id":"0123456789abcdef"}` {
+		t.Errorf("response body = %q", got)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`id=1`))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if strings.Contains(logs.String(), ".fields") {
+		t.Errorf("logged fields of skipped bodies: %s", logs.String())
+	}
+}
@@ -46,7 +46,8 @@ func init() {
 	DefaultDiode = diode.NewWriter(NewLevelWriter(os.Stdout), 1000, 1*time.Millisecond, func(missed int) {
 		slog.Error(fmt.Sprintf("zLog: Dropped %d logs!!!\n", missed))
 	})
-	DefaultLogger = New(DefaultDiode, WithLoggerCallerSkipFrameCount(zerolog.CallerSkipFrameCount+2))
+	DefaultLogger = New(zerolog.MultiLevelWriter(DefaultDiode, DefaultRing), WithLoggerCallerSkipFrameCount(zerolog.CallerSkipFrameCount+2))
+	DefaultLogger.Logger = DefaultLogger.Hook(crashReportHook{})
 	zerolog.DefaultContextLogger = &DefaultLogger.Logger
 	zerolog.ErrorHandler = func(err error) {
 		slog.Error(err.Error())
@@ -0,0 +1,161 @@
+package zilog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultRingSize is the number of events per level kept by DefaultRing.
+const DefaultRingSize = 100
+
+// DefaultRing keeps the recent events of DefaultLogger.
+var DefaultRing = NewRingBuffer(DefaultRingSize)
+
+// RingBuffer is a zerolog.LevelWriter keeping the last events of every
+// level in memory, e.g. to attach the context of a crash to its report even
+// when the log pipeline lags. Events are kept per level so a burst of debug
+// logs doesn't evict the last errors.
+type RingBuffer struct {
+	size int
+
+	mu     sync.Mutex
+	seq    uint64
+	levels map[zerolog.Level]*ring
+}
+
+type ring struct {
+	events []ringEvent
+	next   int
+}
+
+type ringEvent struct {
+	seq  uint64
+	data []byte
+}
+
+// NewRingBuffer returns a RingBuffer keeping size events per level.
+func NewRingBuffer(size int) *RingBuffer {
+	if size <= 0 {
+		size = DefaultRingSize
+	}
+	return &RingBuffer{size: size, levels: map[zerolog.Level]*ring{}}
+}
+
+// Write keeps p as an event without level.
+func (b *RingBuffer) Write(p []byte) (int, error) {
+	return b.WriteLevel(zerolog.NoLevel, p)
+}
+
+// WriteLevel keeps p as an event of level, evicting the oldest one of level
+// when full.
+func (b *RingBuffer) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	// zerolog reuses its buffers, p must be copied
+	data := append([]byte(nil), p...)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r, ok := b.levels[level]
+	if !ok {
+		r = &ring{events: make([]ringEvent, 0, b.size)}
+		b.levels[level] = r
+	}
+	b.seq++
+	e := ringEvent{seq: b.seq, data: data}
+	if len(r.events) < b.size {
+		r.events = append(r.events, e)
+	} else {
+		r.events[r.next] = e
+		r.next = (r.next + 1) % b.size
+	}
+	return len(p), nil
+}
+
+// Events returns the kept events of every level, oldest first.
+func (b *RingBuffer) Events() [][]byte {
+	b.mu.Lock()
+	var events []ringEvent
+	for _, r := range b.levels {
+		events = append(events, r.events...)
+	}
+	b.mu.Unlock()
+
+	sort.Slice(events, func(i, j int) bool { return events[i].seq < events[j].seq })
+	out := make([][]byte, len(events))
+	for i, e := range events {
+		out[i] = e.data
+	}
+	return out
+}
+
+// WriteTo writes the kept events to w, oldest first, one per line.
+func (b *RingBuffer) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	for _, e := range b.Events() {
+		m, err := w.Write(e)
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+		if len(e) > 0 && e[len(e)-1] != '\n' {
+			m, err = w.Write([]byte{'\n'})
+			n += int64(m)
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// crashReport is the JSON document written by WriteCrashReport.
+type crashReport struct {
+	Timestamp  int64             `json:"timestamp"`
+	Reason     string            `json:"reason"`
+	Stack      string            `json:"stack,omitempty"`
+	RecentLogs []json.RawMessage `json:"recent_logs"`
+}
+
+// WriteCrashReport writes a crash report of reason to w: the stack of the
+// calling goroutine and the recent events of DefaultRing, as JSON. Events
+// which aren't valid JSON are skipped.
+func WriteCrashReport(w io.Writer, reason any) error {
+	report := crashReport{
+		Timestamp:  time.Now().UnixMilli(),
+		Reason:     fmt.Sprint(reason),
+		Stack:      string(debug.Stack()),
+		RecentLogs: []json.RawMessage{},
+	}
+	for _, e := range DefaultRing.Events() {
+		if json.Valid(e) {
+			report.RecentLogs = append(report.RecentLogs, json.RawMessage(e))
+		}
+	}
+	return json.NewEncoder(w).Encode(report)
+}
+
+// ReportCrash, deferred at the start of main or of a goroutine, writes a
+// crash report of a panic to stderr before resuming it.
+func ReportCrash() {
+	if r := recover(); r != nil {
+		_ = WriteCrashReport(os.Stderr, r)
+		panic(r)
+	}
+}
+
+// crashReportHook writes a crash report to stderr before fatal events exit
+// the program.
+type crashReportHook struct{}
+
+func (crashReportHook) Run(e *zerolog.Event, level zerolog.Level, msg string) {
+	if level == zerolog.FatalLevel {
+		_ = WriteCrashReport(os.Stderr, msg)
+	}
+}
@@ -0,0 +1,64 @@
+package zilog
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestRingBuffer(t *testing.T) {
+	ring := NewRingBuffer(2)
+	logger := zerolog.New(ring)
+
+	logger.Error().Msg("error 1")
+	for i := 0; i < 5; i++ {
+		logger.Debug().Msgf("debug %d", i)
+	}
+	logger.Error().Msg("error 2")
+
+	var messages []string
+	for _, e := range ring.Events() {
+		var event struct {
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(e, &event); err != nil {
+			t.Fatalf("Failed to decode event %q: %v", e, err)
+		}
+		messages = append(messages, event.Message)
+	}
+	// The debug burst doesn't evict the errors, and events stay in order
+	want := "error 1,debug 3,debug 4,error 2"
+	if got := strings.Join(messages, ","); got != want {
+		t.Errorf("events = %s, want %s", got, want)
+	}
+
+	var buf bytes.Buffer
+	if _, err := ring.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 4 {
+		t.Errorf("WriteTo wrote %d lines, want 4", lines)
+	}
+}
+
+func TestWriteCrashReport(t *testing.T) {
+	DefaultLogger.Warn().Msg("before the crash")
+
+	var buf bytes.Buffer
+	if err := WriteCrashReport(&buf, "boom"); err != nil {
+		t.Fatalf("WriteCrashReport: %v", err)
+	}
+	var report crashReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	if report.Reason != "boom" || !strings.Contains(report.Stack, "TestWriteCrashReport") {
+		t.Errorf("report = %q with stack %q, want the reason and the stack", report.Reason, report.Stack)
+	}
+	if n := len(report.RecentLogs); n == 0 || !bytes.Contains(report.RecentLogs[n-1], []byte("before the crash")) {
+		t.Errorf("recent logs = %s, want the last event", report.RecentLogs)
+	}
+}
@@ -0,0 +1,24 @@
+package zilog
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ForTask returns a child of the logger of ctx for the asynchronous task
+// taskName, e.g. a ziworker job or a ziqueue handler spawned from a request,
+// and ctx with it. The logs carry the task name and the W3C Trace Context
+// IDs of the span of ctx, so they stay correlated with the originating trace
+// even once the request has ended.
+func ForTask(ctx context.Context, taskName string) (context.Context, *zerolog.Logger) {
+	c := zerolog.Ctx(ctx).With().Str("task", taskName)
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		c = c.Str("trace_id", sc.TraceID().String()).
+			Str("span_id", sc.SpanID().String()).
+			Str("trace_flags", sc.TraceFlags().String())
+	}
+	logger := c.Logger()
+	return logger.WithContext(ctx), &logger
+}
@@ -0,0 +1,70 @@
+package zilog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestForTask(t *testing.T) {
+	var logs bytes.Buffer
+	logger := zerolog.New(&logs)
+	ctx := logger.WithContext(context.Background())
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx = trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	// The request ends before the task runs
+	taskCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	taskCtx, taskLogger := ForTask(context.WithoutCancel(taskCtx), "send_receipt")
+	taskLogger.Info().Msg("sending")
+	FromContext(taskCtx).Info().Msg("sent")
+
+	dec := json.NewDecoder(&logs)
+	for _, msg := range []string{"sending", "sent"} {
+		var entry map[string]any
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]any{
+			"level":       "info",
+			"message":     msg,
+			"task":        "send_receipt",
+			"trace_id":    "4bf92f3577b34da6a3ce929d0e0e4736",
+			"span_id":     "00f067aa0ba902b7",
+			"trace_flags": "01",
+		}
+		for k, v := range want {
+			if entry[k] != v {
+				t.Errorf("%s: %s = %v, want %v", msg, k, entry[k], v)
+			}
+		}
+	}
+}
+
+func TestForTaskWithoutTrace(t *testing.T) {
+	var logs bytes.Buffer
+	logger := zerolog.New(&logs)
+	_, taskLogger := ForTask(logger.WithContext(context.Background()), "cleanup")
+	taskLogger.Info().Msg("done")
+
+	var entry map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["task"] != "cleanup" {
+		t.Errorf("task = %v, want cleanup", entry["task"])
+	}
+	if _, ok := entry["trace_id"]; ok {
+		t.Errorf("trace_id logged without trace: %v", entry)
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"log/slog"
 	"os"
 
+	"github.com/divikraf/lumos/ziconf"
 	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zitelemetry/observe/observefx"
 	"github.com/rs/zerolog"
 	"go.uber.org/fx"
 	"go.uber.org/fx/fxevent"
@@ -25,6 +27,8 @@ type fxLogParams struct {
 
 	DisableSlog useConsoleLogger `optional:"true"`
 	L           *slog.Logger
+	Log         *zerolog.Logger `optional:"true"`
+	Config      ziconf.Config   `optional:"true"`
 }
 
 // UseConsoleLogger sets Uber Fx framework logger to a simple console logger
@@ -36,16 +40,19 @@ var UseConsoleLogger = fx.Provide(
 	},
 )
 
-// FxLogger is a Logger that may be used for fx.App
+// FxLogger is a Logger that may be used for fx.App. The startup is traced as
+// well, see observefx.BootTracer, and summarized once done, see
+// StartupSummary.
 var FxLogger = fx.WithLogger(func(params fxLogParams) fxevent.Logger {
+	var logger fxevent.Logger = &fxevent.ConsoleLogger{W: os.Stdout}
 	if !params.DisableSlog {
-		return &SlogLogger{
-			Logger: params.L,
-		}
-	}
-	return &fxevent.ConsoleLogger{
-		W: os.Stdout,
+		logger = &SlogLogger{Logger: params.L}
 	}
+	return &observefx.BootTracer{Logger: &StartupSummary{
+		Logger: logger,
+		Log:    params.Log,
+		Config: params.Config,
+	}}
 })
 
 // ContextDecorator decorates a context.Context with a Logger from the provided
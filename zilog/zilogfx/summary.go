@@ -0,0 +1,142 @@
+package zilogfx
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zin"
+	"github.com/rs/zerolog"
+	"go.uber.org/fx/fxevent"
+)
+
+// StartupSummary is an fxevent.Logger logging a single "service started"
+// line once the app started, with the service name, version, environment,
+// listen addresses, enabled modules, config checksum and startup duration.
+//
+// Enabled modules are the packages of the constructors run and of the
+// functions invoked, so modules provided but never used aren't listed.
+type StartupSummary struct {
+	// Logger is the wrapped logger, events are forwarded to it when not nil.
+	Logger fxevent.Logger
+	// Log is the logger of the summary (default: zilog.DefaultLogger).
+	Log *zerolog.Logger
+	// Config is the config of the app, its fields are left out when nil.
+	Config ziconf.Config
+
+	mu      sync.Mutex
+	started time.Time
+	modules map[string]struct{}
+}
+
+var _ fxevent.Logger = (*StartupSummary)(nil)
+
+// listenersConfig is implemented by configs with additional HTTP servers,
+// see zin.ListenerConfig.
+type listenersConfig interface {
+	GetHttpListeners() map[string]zin.ListenerConfig
+}
+
+func (s *StartupSummary) LogEvent(event fxevent.Event) {
+	if s.Logger != nil {
+		s.Logger.LogEvent(event)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started.IsZero() {
+		s.started = time.Now()
+		s.modules = map[string]struct{}{}
+	}
+
+	switch e := event.(type) {
+	case *fxevent.Run:
+		s.addModule(e.ModuleName, e.Name)
+	case *fxevent.Invoked:
+		s.addModule(e.ModuleName, e.FunctionName)
+	case *fxevent.Started:
+		if e.Err == nil {
+			s.log(time.Since(s.started))
+		}
+	}
+}
+
+// addModule records the fx module, or the package of the function name
+// otherwise.
+func (s *StartupSummary) addModule(module, function string) {
+	if module == "" {
+		module = functionPackage(function)
+	}
+	if module == "" || module == "reflect" || strings.HasPrefix(module, "go.uber.org/fx") {
+		return
+	}
+	s.modules[module] = struct{}{}
+}
+
+// functionPackage returns the package path of a function name like
+// "github.com/divikraf/lumos/zin.StartHttpServer()".
+func functionPackage(name string) string {
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return ""
+	}
+	return name[:slash+1+dot]
+}
+
+func (s *StartupSummary) log(duration time.Duration) {
+	logger := s.Log
+	if logger == nil {
+		logger = &zilog.DefaultLogger.Logger
+	}
+	modules := make([]string, 0, len(s.modules))
+	for m := range s.modules {
+		modules = append(modules, m)
+	}
+	sort.Strings(modules)
+
+	event := logger.Info()
+	if info, ok := debug.ReadBuildInfo(); ok {
+		event = event.Str("version", info.Main.Version)
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				event = event.Str("revision", setting.Value)
+			}
+		}
+	}
+	if s.Config != nil {
+		listen := zerolog.Dict().Str("http", s.Config.GetHttpPort())
+		if c, ok := s.Config.(listenersConfig); ok {
+			for name, l := range c.GetHttpListeners() {
+				listen = listen.Str(name, l.Addr)
+			}
+		}
+		event = event.
+			Str("service", s.Config.GetService().Name).
+			Str("environment", s.Config.GetEnvironment()).
+			Dict("listen", listen).
+			Str("config_checksum", configChecksum(s.Config))
+	}
+	event.
+		Strs("modules", modules).
+		Dur("startup_duration", duration).
+		Msg("service started")
+}
+
+// configChecksum returns a checksum of the JSON encoded config, telling
+// whether instances run the same config without logging it.
+func configChecksum(config ziconf.Config) string {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,88 @@
+package zilogfx
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zin"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/rs/zerolog"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
+	"go.uber.org/fx/fxtest"
+)
+
+type testConfig struct {
+	Secret string `json:"secret"`
+}
+
+func (testConfig) GetService() ziconf.ServiceConfig { return ziconf.ServiceConfig{Name: "orders"} }
+func (testConfig) GetEnvironment() string           { return "staging" }
+func (testConfig) GetLog() ziconf.LogConfig         { return ziconf.LogConfig{} }
+func (testConfig) GetHttpPort() string              { return ":8080" }
+func (testConfig) GetTelemetry() observe.Config     { return observe.Config{} }
+func (testConfig) GetHttpListeners() map[string]zin.ListenerConfig {
+	return map[string]zin.ListenerConfig{"admin": {Addr: ":9090"}}
+}
+
+type store struct{}
+
+func newStore() *store { return &store{} }
+
+func TestStartupSummary(t *testing.T) {
+	var buf bytes.Buffer
+	log := zerolog.New(&buf)
+	config := testConfig{Secret: "s3cr3t"}
+	app := fxtest.New(t,
+		fx.WithLogger(func() fxevent.Logger {
+			return &StartupSummary{Log: &log, Config: config}
+		}),
+		fx.Provide(newStore),
+		fx.Provide(func() string { return "unused" }),
+		fx.Invoke(func(*store) {}),
+	)
+	app.RequireStart().RequireStop()
+
+	var got struct {
+		Message     string            `json:"message"`
+		Service     string            `json:"service"`
+		Environment string            `json:"environment"`
+		Listen      map[string]string `json:"listen"`
+		Modules     []string          `json:"modules"`
+		Checksum    string            `json:"config_checksum"`
+		Duration    *float64          `json:"startup_duration"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("summary %q: %v", buf.String(), err)
+	}
+	if got.Message != "service started" || got.Service != "orders" || got.Environment != "staging" || got.Duration == nil {
+		t.Errorf("summary = %s", buf.String())
+	}
+	if got.Listen["http"] != ":8080" || got.Listen["admin"] != ":9090" {
+		t.Errorf("listen = %v", got.Listen)
+	}
+	if want := "github.com/divikraf/lumos/zilog/zilogfx"; len(got.Modules) != 1 || got.Modules[0] != want {
+		t.Errorf("modules = %v, want [%s]", got.Modules, want)
+	}
+	if got.Checksum == "" || got.Checksum != configChecksum(config) || got.Checksum == configChecksum(testConfig{}) {
+		t.Errorf("config checksum = %q, want a checksum of the config", got.Checksum)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("s3cr3t")) {
+		t.Error("summary logs the config")
+	}
+}
+
+func TestFunctionPackage(t *testing.T) {
+	for name, want := range map[string]string{
+		"github.com/divikraf/lumos/zin.StartHttpServer()":          "github.com/divikraf/lumos/zin",
+		"github.com/divikraf/lumos/zin/zinfx.Server.func1()":       "github.com/divikraf/lumos/zin/zinfx",
+		"go.uber.org/fx.(*App).constructCustomLogger.Provide(...)": "go.uber.org/fx",
+		"main.main.func1()": "main",
+	} {
+		if got := functionPackage(name); got != want {
+			t.Errorf("functionPackage(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
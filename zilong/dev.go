@@ -0,0 +1,41 @@
+package zilong
+
+import (
+	"slices"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"go.uber.org/fx"
+)
+
+// DevEnvironments are the environments DevOverrides applies to.
+var DevEnvironments = []string{"local", "development"}
+
+// IsDev reports whether env is one of DevEnvironments.
+func IsDev(env string) bool {
+	return slices.Contains(DevEnvironments, env)
+}
+
+// DevOverrides swaps the dependencies needing infrastructure for local ones
+// when the config environment is one of DevEnvironments, so that `go run .`
+// works on a laptop with zero infra. Elsewhere, it changes nothing:
+//
+//   - OTLP and Jaeger trace and metric exporters are replaced by console ones.
+func DevOverrides() fx.Option {
+	return fx.Options(
+		fx.Decorate(devTelemetry),
+	)
+}
+
+// devTelemetry exports telemetry to the console in dev environments.
+func devTelemetry(config ziconf.Config, telemetry observe.Config) observe.Config {
+	if !IsDev(config.GetEnvironment()) {
+		return telemetry
+	}
+	for _, exporter := range []*observe.ExporterConfig{&telemetry.Tracing.Exporter, &telemetry.Metrics.Exporter} {
+		if exporter.Type != "none" {
+			exporter.Type = "console"
+		}
+	}
+	return telemetry
+}
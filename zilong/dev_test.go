@@ -0,0 +1,52 @@
+package zilong
+
+import (
+	"testing"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+type testConfig struct {
+	env string
+}
+
+func (c testConfig) GetService() ziconf.ServiceConfig { return ziconf.ServiceConfig{} }
+func (c testConfig) GetEnvironment() string           { return c.env }
+func (c testConfig) GetLog() ziconf.LogConfig         { return ziconf.LogConfig{} }
+func (c testConfig) GetHttpPort() string              { return "" }
+func (c testConfig) GetTelemetry() observe.Config     { return observe.Config{} }
+
+func TestDevOverrides(t *testing.T) {
+	tests := []struct {
+		env          string
+		wantExporter string
+	}{
+		{env: "local", wantExporter: "console"},
+		{env: "production", wantExporter: "otlp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			var got observe.Config
+			app := fxtest.New(t,
+				fx.Supply(fx.Annotate(testConfig{env: tt.env}, fx.As(new(ziconf.Config)))),
+				fx.Supply(observe.Config{
+					Tracing: observe.TracingConfig{Exporter: observe.ExporterConfig{Type: "otlp"}},
+					Metrics: observe.MetricsConfig{Exporter: observe.ExporterConfig{Type: "none"}},
+				}),
+				DevOverrides(),
+				fx.Populate(&got),
+			)
+			app.RequireStart().RequireStop()
+
+			if got.Tracing.Exporter.Type != tt.wantExporter {
+				t.Errorf("tracing exporter = %q, want %q", got.Tracing.Exporter.Type, tt.wantExporter)
+			}
+			if got.Metrics.Exporter.Type != "none" {
+				t.Errorf("metrics exporter = %q, want none to be kept", got.Metrics.Exporter.Type)
+			}
+		})
+	}
+}
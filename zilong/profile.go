@@ -0,0 +1,168 @@
+package zilong
+
+import (
+	"os"
+	"time"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zin"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"go.uber.org/fx"
+)
+
+// Profile holds the defaults of an environment. Fields explicitly set in the
+// config take precedence over the profile ones.
+type Profile struct {
+	Name string
+	// LogLevel is the global log level, see zilog.LevelDebug.
+	LogLevel string
+	// GinMode is the gin mode, unless set with the GIN_MODE variable.
+	GinMode string
+	// Exporter is the type of the trace and metric exporters.
+	Exporter string
+	Sampler  observe.SamplerConfig
+	Batch    observe.BatchConfig
+	// MetricsInterval is the metrics export interval.
+	MetricsInterval time.Duration
+	// ErrorDetails attaches stack traces to recorded errors.
+	ErrorDetails bool
+	// DebugRoutes mounts the pprof and expvar endpoints on the router,
+	// without token.
+	DebugRoutes bool
+}
+
+// Built-in profiles
+var (
+	DevelopmentProfile = Profile{
+		Name:            "development",
+		LogLevel:        "debug",
+		GinMode:         gin.DebugMode,
+		Exporter:        "console",
+		Sampler:         observe.SamplerConfig{Type: "always_on"},
+		Batch:           observe.BatchConfig{MaxExportBatchSize: 64, ExportTimeout: 5 * time.Second, MaxQueueSize: 512},
+		MetricsInterval: 10 * time.Second,
+		ErrorDetails:    true,
+		DebugRoutes:     true,
+	}
+	StagingProfile = Profile{
+		Name:            "staging",
+		LogLevel:        "debug",
+		GinMode:         gin.ReleaseMode,
+		Exporter:        "otlp",
+		Sampler:         observe.SamplerConfig{Type: "parentbased", Fraction: 1},
+		Batch:           observe.BatchConfig{MaxExportBatchSize: 512, ExportTimeout: 30 * time.Second, MaxQueueSize: 2048},
+		MetricsInterval: 30 * time.Second,
+		ErrorDetails:    true,
+	}
+	ProductionProfile = Profile{
+		Name:            "production",
+		LogLevel:        "info",
+		GinMode:         gin.ReleaseMode,
+		Exporter:        "otlp",
+		Sampler:         observe.SamplerConfig{Type: "parentbased", Fraction: 0.1},
+		Batch:           observe.BatchConfig{MaxExportBatchSize: 512, ExportTimeout: 30 * time.Second, MaxQueueSize: 8192},
+		MetricsInterval: 60 * time.Second,
+	}
+)
+
+// EnvironmentProfiles maps the config environments to their profile.
+var EnvironmentProfiles = map[string]Profile{
+	"local":       DevelopmentProfile,
+	"development": DevelopmentProfile,
+	"staging":     StagingProfile,
+	"production":  ProductionProfile,
+}
+
+// ProfileFor returns the profile of the environment env.
+func ProfileFor(env string) (Profile, bool) {
+	p, ok := EnvironmentProfiles[env]
+	return p, ok
+}
+
+// Telemetry returns telemetry with its unset fields set from p.
+func (p Profile) Telemetry(telemetry observe.Config) observe.Config {
+	for _, exporter := range []*observe.ExporterConfig{&telemetry.Tracing.Exporter, &telemetry.Metrics.Exporter} {
+		if exporter.Type == "" {
+			exporter.Type = p.Exporter
+		}
+	}
+	if telemetry.Tracing.Sampler.Type == "" {
+		telemetry.Tracing.Sampler = p.Sampler
+	}
+	batch := &telemetry.Tracing.Batch
+	if batch.MaxExportBatchSize == 0 {
+		batch.MaxExportBatchSize = p.Batch.MaxExportBatchSize
+	}
+	if batch.ExportTimeout == 0 {
+		batch.ExportTimeout = p.Batch.ExportTimeout
+	}
+	if batch.MaxQueueSize == 0 {
+		batch.MaxQueueSize = p.Batch.MaxQueueSize
+	}
+	if telemetry.Metrics.Reader.Interval == 0 {
+		telemetry.Metrics.Reader.Interval = p.MetricsInterval
+	}
+	telemetry.Tracing.ErrorDetails.Enabled = telemetry.Tracing.ErrorDetails.Enabled || p.ErrorDetails
+	return telemetry
+}
+
+// Profiles applies the profile of the config environment, see
+// EnvironmentProfiles, filling the telemetry fields left unset, and setting
+// the log level, the gin mode and the debug routes. Environments without
+// profile are left as is.
+func Profiles() fx.Option {
+	return fx.Options(
+		fx.Decorate(profileTelemetry),
+		fx.Invoke(applyProfile),
+		fx.Invoke(profileDebugRoutes),
+	)
+}
+
+func profileTelemetry(config ziconf.Config, telemetry observe.Config) observe.Config {
+	p, ok := ProfileFor(config.GetEnvironment())
+	if !ok {
+		return telemetry
+	}
+	return p.Telemetry(telemetry)
+}
+
+// applyProfile sets the log level and the gin mode.
+func applyProfile(config ziconf.Config) error {
+	p, ok := ProfileFor(config.GetEnvironment())
+	level := config.GetLog().Level
+	if level == "" && ok {
+		level = p.LogLevel
+	}
+	if level != "" {
+		l, err := zerolog.ParseLevel(level)
+		if err != nil {
+			return err
+		}
+		zerolog.SetGlobalLevel(l)
+	}
+	if ok && os.Getenv(gin.EnvGinMode) == "" {
+		gin.SetMode(p.GinMode)
+	}
+	return nil
+}
+
+// debugRoutesConfig is implemented by configs setting the debug routes, see
+// zin.RegisterDebugRoutes.
+type debugRoutesConfig interface {
+	GetDebugRoutes() zin.DebugRoutesConfig
+}
+
+// profileDebugRoutes mounts the debug routes when the profile exposes them,
+// unless they are configured explicitly.
+func profileDebugRoutes(config ziconf.Config, router *gin.Engine) {
+	p, ok := ProfileFor(config.GetEnvironment())
+	if !ok || !p.DebugRoutes {
+		return
+	}
+	if c, ok := config.(debugRoutesConfig); ok && c.GetDebugRoutes().Enabled {
+		return
+	}
+	zin.DebugRoutes(router)
+}
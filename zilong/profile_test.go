@@ -0,0 +1,76 @@
+package zilong
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+func TestProfileTelemetry(t *testing.T) {
+	got := ProductionProfile.Telemetry(observe.Config{
+		Tracing: observe.TracingConfig{
+			Exporter: observe.ExporterConfig{Type: "jaeger"},
+			Batch:    observe.BatchConfig{MaxQueueSize: 100},
+		},
+	})
+	if got.Tracing.Exporter.Type != "jaeger" || got.Metrics.Exporter.Type != "otlp" {
+		t.Errorf("exporters = %q, %q", got.Tracing.Exporter.Type, got.Metrics.Exporter.Type)
+	}
+	if got.Tracing.Batch.MaxQueueSize != 100 || got.Tracing.Batch.MaxExportBatchSize != 512 {
+		t.Errorf("batch = %+v", got.Tracing.Batch)
+	}
+	if got.Tracing.Sampler != ProductionProfile.Sampler || got.Metrics.Reader.Interval != ProductionProfile.MetricsInterval {
+		t.Errorf("sampler = %+v, interval = %v", got.Tracing.Sampler, got.Metrics.Reader.Interval)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	defer zerolog.SetGlobalLevel(zerolog.GlobalLevel())
+	defer gin.SetMode(gin.Mode())
+
+	tests := []struct {
+		env         string
+		wantLevel   zerolog.Level
+		wantMode    string
+		wantDebug   int
+		wantSampler string
+	}{
+		{env: "local", wantLevel: zerolog.DebugLevel, wantMode: gin.DebugMode, wantDebug: http.StatusOK, wantSampler: "always_on"},
+		{env: "production", wantLevel: zerolog.InfoLevel, wantMode: gin.ReleaseMode, wantDebug: http.StatusNotFound, wantSampler: "parentbased"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			var telemetry observe.Config
+			router := gin.New()
+			app := fxtest.New(t,
+				fx.Supply(fx.Annotate(testConfig{env: tt.env}, fx.As(new(ziconf.Config)))),
+				fx.Supply(observe.Config{}, router),
+				Profiles(),
+				fx.Populate(&telemetry),
+			)
+			app.RequireStart().RequireStop()
+
+			if got := zerolog.GlobalLevel(); got != tt.wantLevel {
+				t.Errorf("log level = %v, want %v", got, tt.wantLevel)
+			}
+			if got := gin.Mode(); got != tt.wantMode {
+				t.Errorf("gin mode = %q, want %q", got, tt.wantMode)
+			}
+			if telemetry.Tracing.Sampler.Type != tt.wantSampler {
+				t.Errorf("sampler = %q, want %q", telemetry.Tracing.Sampler.Type, tt.wantSampler)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+			if w.Code != tt.wantDebug {
+				t.Errorf("debug routes status = %d, want %d", w.Code, tt.wantDebug)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package zilong
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zin/health"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/fx"
+)
+
+// DefaultRestartExitCode is the exit code of the restarts, EX_TEMPFAIL, for
+// the orchestrator to tell them from failures.
+const DefaultRestartExitCode = 75
+
+// Restart reasons
+const (
+	RestartReasonConfig    = "config"
+	RestartReasonSecret    = "secret"
+	RestartReasonRequested = "requested"
+)
+
+// RestartConfig configures the restarts of the app on the changes of its
+// configuration or secrets which cannot be applied without restarting.
+type RestartConfig struct {
+	// ConfigFiles are the watched configuration, a base file and its
+	// overlays, see ziconf.LoadSettings (default: config.yaml).
+	ConfigFiles []string `json:"configFiles" yaml:"configFiles"`
+	// Keys are the keys, or parents of keys, whose changes restart the app,
+	// e.g. "database" (default: every key).
+	Keys []string `json:"keys" yaml:"keys"`
+	// SecretFiles are the files, e.g. mounted secrets, whose changes restart
+	// the app.
+	SecretFiles []string `json:"secretFiles" yaml:"secretFiles"`
+	// PollInterval is the interval of the checks of the files (default: 10s).
+	PollInterval time.Duration `json:"pollInterval" yaml:"pollInterval"`
+	// Jitter is the maximum random delay of a restart, spreading the
+	// restarts of the replicas seeing the change together (default: 30s).
+	Jitter time.Duration `json:"jitter" yaml:"jitter"`
+	// DrainDelay is how long readiness fails before the app stops, so load
+	// balancers stop routing traffic to it first (default: 5s).
+	DrainDelay time.Duration `json:"drainDelay" yaml:"drainDelay"`
+	// ExitCode is the exit code of the restarts (default:
+	// DefaultRestartExitCode).
+	ExitCode int `json:"exitCode" yaml:"exitCode"`
+}
+
+func (c *RestartConfig) setDefaults() {
+	if len(c.ConfigFiles) == 0 {
+		c.ConfigFiles = []string{"config.yaml"}
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = 10 * time.Second
+	}
+	if c.Jitter <= 0 {
+		c.Jitter = 30 * time.Second
+	}
+	if c.DrainDelay <= 0 {
+		c.DrainDelay = 5 * time.Second
+	}
+	if c.ExitCode == 0 {
+		c.ExitCode = DefaultRestartExitCode
+	}
+}
+
+// Restarter restarts the app when its configuration or secrets change: it
+// waits a random delay up to the jitter, makes readiness fail, waits for the
+// drain delay, then stops the app with the restart exit code, for the
+// orchestrator to start it again with the new configuration. Restarts are
+// recorded in the app_restarts_total metric by reason.
+type Restarter struct {
+	config     RestartConfig
+	logger     *zerolog.Logger
+	drainer    drainer
+	shutdowner fx.Shutdowner
+	restarts   metric.Int64Counter
+
+	settings map[string]any
+	secrets  map[string][sha256.Size]byte
+
+	requests chan string
+	once     sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// drainer is implemented by *health.Health.
+type drainer interface {
+	Drain()
+}
+
+// NewRestarter returns a Restarter watching the files of config from their
+// current content. d, if not nil, is drained before stopping the app.
+func NewRestarter(config RestartConfig, logger *zerolog.Logger, d drainer, shutdowner fx.Shutdowner) (*Restarter, error) {
+	config.setDefaults()
+	r := &Restarter{
+		config:     config,
+		logger:     logger,
+		drainer:    d,
+		shutdowner: shutdowner,
+		restarts:   revelio.MustInt64Counter("app_restarts_total", "Number of restarts of the app on configuration changes, by reason"),
+		requests:   make(chan string, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	var err error
+	if r.settings, err = ziconf.LoadSettings(config.ConfigFiles[0], config.ConfigFiles[1:]...); err != nil {
+		return nil, err
+	}
+	if r.secrets, err = hashFiles(config.SecretFiles); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Start starts watching the files.
+func (r *Restarter) Start() {
+	go r.run()
+}
+
+// Stop stops watching the files, and cancels a pending restart.
+func (r *Restarter) Stop(ctx context.Context) error {
+	r.once.Do(func() { close(r.stop) })
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RequestRestart restarts the app for a change detected elsewhere, e.g. a
+// credential rotated in a secret manager.
+func (r *Restarter) RequestRestart(reason string) {
+	select {
+	case r.requests <- reason:
+	default:
+	}
+}
+
+func (r *Restarter) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		var reason string
+		select {
+		case <-ticker.C:
+			reason = r.check()
+		case requested := <-r.requests:
+			r.logger.Warn().Str("reason", requested).Msg("Restart requested")
+			reason = RestartReasonRequested
+		case <-r.stop:
+			return
+		}
+		if reason != "" {
+			r.restart(reason)
+			return
+		}
+	}
+}
+
+// check returns the reason of a restart when the files changed, or "".
+func (r *Restarter) check() string {
+	secrets, err := hashFiles(r.config.SecretFiles)
+	if err != nil {
+		r.logger.Warn().Err(err).Msg("Failed to read the secret files")
+	}
+	for path, sum := range secrets {
+		if r.secrets[path] != sum {
+			r.logger.Warn().Str("path", path).Msg("Secret changed, restarting")
+			return RestartReasonSecret
+		}
+	}
+
+	settings, err := ziconf.LoadSettings(r.config.ConfigFiles[0], r.config.ConfigFiles[1:]...)
+	if err != nil {
+		// The file may be written, check it again at the next tick
+		r.logger.Warn().Err(err).Msg("Failed to read the configuration")
+		return ""
+	}
+	var changes []ziconf.Change
+	for _, c := range ziconf.Diff(r.settings, settings) {
+		if r.critical(c.Key) {
+			changes = append(changes, c)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	ziconf.WriteDiff(&b, changes)
+	r.logger.Warn().Str("changes", b.String()).Msg("Configuration changed, restarting")
+	return RestartReasonConfig
+}
+
+// critical reports whether changes of key restart the app.
+func (r *Restarter) critical(key string) bool {
+	if len(r.config.Keys) == 0 {
+		return true
+	}
+	for _, k := range r.config.Keys {
+		if key == k || strings.HasPrefix(key, k+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// restart drains then stops the app after the jitter, unless stopped first.
+func (r *Restarter) restart(reason string) {
+	delay := rand.N(r.config.Jitter)
+	r.logger.Info().Dur("delay", delay).Str("reason", reason).Msg("Restart scheduled")
+	if !r.sleep(delay) {
+		return
+	}
+	r.restarts.Add(context.Background(), 1, metric.WithAttributes(attribute.String("reason", reason)))
+	if r.drainer != nil {
+		r.drainer.Drain()
+		if !r.sleep(r.config.DrainDelay) {
+			return
+		}
+	}
+	if err := r.shutdowner.Shutdown(fx.ExitCode(r.config.ExitCode)); err != nil {
+		r.logger.Error().Err(err).Msg("Failed to stop the app for a restart")
+	}
+}
+
+// sleep waits for d, returning false when stopped first.
+func (r *Restarter) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-r.stop:
+		return false
+	}
+}
+
+// hashFiles returns the hashes of the content of paths, missing files being
+// hashed as empty.
+func hashFiles(paths []string) (map[string][sha256.Size]byte, error) {
+	sums := make(map[string][sha256.Size]byte, len(paths))
+	var errs []error
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+			continue
+		}
+		sums[path] = sha256.Sum256(b)
+	}
+	return sums, errors.Join(errs...)
+}
+
+// restartConfig is implemented by configurations of the restarts.
+type restartConfig interface {
+	GetRestart() RestartConfig
+}
+
+type restartParams struct {
+	fx.In
+	Config     ziconf.Config
+	Logger     *zerolog.Logger
+	Health     *health.Health `optional:"true"`
+	Shutdowner fx.Shutdowner
+	LC         fx.Lifecycle
+}
+
+// RestartOnChange provides a *Restarter, configured by the restart config
+// when the config has one, watching the files while the app runs.
+var RestartOnChange = fx.Options(
+	fx.Provide(func(params restartParams) (*Restarter, error) {
+		var config RestartConfig
+		if c, ok := params.Config.(restartConfig); ok {
+			config = c.GetRestart()
+		}
+		var d drainer
+		if params.Health != nil {
+			d = params.Health
+		}
+		r, err := NewRestarter(config, params.Logger, d, params.Shutdowner)
+		if err != nil {
+			return nil, err
+		}
+		params.LC.Append(fx.StartStopHook(r.Start, r.Stop))
+		return r, nil
+	}),
+	fx.Invoke(func(*Restarter) {}),
+)
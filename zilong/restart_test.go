@@ -0,0 +1,126 @@
+package zilong
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.uber.org/fx"
+)
+
+type fakeShutdowner struct {
+	code chan int
+}
+
+func (s *fakeShutdowner) Shutdown(opts ...fx.ShutdownOption) error {
+	s.code <- exitCode(opts...)
+	return nil
+}
+
+// exitCode returns the exit code of opts, applied to a throwaway app.
+func exitCode(opts ...fx.ShutdownOption) int {
+	var shutdowner fx.Shutdowner
+	app := fx.New(fx.NopLogger, fx.Populate(&shutdowner))
+	app.Start(context.Background())
+	shutdowner.Shutdown(opts...)
+	sig := <-app.Wait()
+	app.Stop(context.Background())
+	return sig.ExitCode
+}
+
+type fakeDrainer struct {
+	drained atomic.Bool
+}
+
+func (d *fakeDrainer) Drain() { d.drained.Store(true) }
+
+func TestRestarter(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.yaml")
+	secret := filepath.Join(dir, "password")
+	write := func(path, content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(config, "database:\n  host: db-1\nlog:\n  level: info\n")
+	write(secret, "s3cret")
+
+	tests := []struct {
+		name   string
+		change func()
+	}{
+		{name: "critical key", change: func() { write(config, "database:\n  host: db-2\nlog:\n  level: info\n") }},
+		{name: "secret", change: func() { write(secret, "rotated") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := zerolog.Nop()
+			d := &fakeDrainer{}
+			s := &fakeShutdowner{code: make(chan int, 1)}
+			r, err := NewRestarter(RestartConfig{
+				ConfigFiles:  []string{config},
+				Keys:         []string{"database"},
+				SecretFiles:  []string{secret},
+				PollInterval: 5 * time.Millisecond,
+				Jitter:       time.Millisecond,
+				DrainDelay:   time.Millisecond,
+			}, &logger, d, s)
+			if err != nil {
+				t.Fatalf("NewRestarter() = %v", err)
+			}
+			r.Start()
+			defer r.Stop(context.Background())
+
+			// Changes of other keys are applied without restarting
+			write(config, "database:\n  host: db-1\nlog:\n  level: debug\n")
+			time.Sleep(30 * time.Millisecond)
+			if d.drained.Load() {
+				t.Fatal("drained on a change of a non critical key")
+			}
+
+			tt.change()
+			select {
+			case code := <-s.code:
+				if code != DefaultRestartExitCode {
+					t.Errorf("exit code = %d, want %d", code, DefaultRestartExitCode)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("app not stopped after the change")
+			}
+			if !d.drained.Load() {
+				t.Error("app stopped without draining")
+			}
+			write(config, "database:\n  host: db-1\nlog:\n  level: info\n")
+			write(secret, "s3cret")
+		})
+	}
+}
+
+func TestRestarterStopCancelsRestart(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.yaml")
+	os.WriteFile(config, []byte("a: 1\n"), 0o600)
+
+	logger := zerolog.Nop()
+	s := &fakeShutdowner{code: make(chan int, 1)}
+	r, err := NewRestarter(RestartConfig{ConfigFiles: []string{config}, Jitter: time.Hour}, &logger, nil, s)
+	if err != nil {
+		t.Fatalf("NewRestarter() = %v", err)
+	}
+	r.Start()
+	r.RequestRestart("credentials rotated")
+	time.Sleep(10 * time.Millisecond)
+	if err := r.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() = %v", err)
+	}
+	select {
+	case <-s.code:
+		t.Error("app stopped after the restarter")
+	default:
+	}
+}
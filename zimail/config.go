@@ -0,0 +1,90 @@
+package zimail
+
+import (
+	"fmt"
+	"io/fs"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zihttpc"
+	"github.com/divikraf/lumos/ziqueue"
+	"go.uber.org/fx"
+)
+
+// Config configures the Sender.
+type Config struct {
+	// Driver is the driver of the emails: DriverSMTP, DriverSES,
+	// DriverSendGrid or DriverLog (default: DriverLog).
+	Driver string `json:"driver" yaml:"driver"`
+	// From is the From address of the emails without one.
+	From     string         `json:"from" yaml:"from"`
+	SMTP     SMTPConfig     `json:"smtp" yaml:"smtp"`
+	SES      SESConfig      `json:"ses" yaml:"ses"`
+	SendGrid SendGridConfig `json:"sendgrid" yaml:"sendgrid"`
+	// HTTP configures the HTTP client of the SES and SendGrid drivers, see
+	// zihttpc.New.
+	HTTP zihttpc.ClientConfig `json:"http" yaml:"http"`
+	// Queue, if set, is the queue of the emails sent asynchronously, see
+	// Sender.Enqueue.
+	Queue string `json:"queue" yaml:"queue"`
+	// DryRun, if enabled, logs or writes the emails instead of sending them,
+	// see WithDryRun.
+	DryRun DryRunConfig `json:"dry_run" yaml:"dry_run"`
+}
+
+// mailConfig is implemented by configurations of services sending emails.
+type mailConfig interface {
+	GetMail() Config
+}
+
+// NewDriver returns the Driver configured by config.
+func NewDriver(config Config) (Driver, error) {
+	switch config.Driver {
+	case DriverSMTP:
+		return NewSMTPDriver(config.SMTP), nil
+	case DriverSES:
+		return NewSESDriver(config.SES, zihttpc.New(config.HTTP)), nil
+	case DriverSendGrid:
+		return NewSendGridDriver(config.SendGrid, zihttpc.New(config.HTTP)), nil
+	case DriverLog, "":
+		return LogDriver(), nil
+	default:
+		return nil, fmt.Errorf("zimail: unknown driver %q", config.Driver)
+	}
+}
+
+type NewConfiguredSenderParams struct {
+	fx.In
+	Config    ziconf.Config
+	Templates fs.FS           `name:"zimail-templates" optional:"true"`
+	Broker    *ziqueue.Broker `optional:"true"`
+}
+
+// NewConfiguredSender returns the Sender configured by the mail config,
+// rendering the templates of the provided "zimail-templates" file system, if
+// any, and enqueuing the emails to the queue of the config with the
+// provided broker, if any. In dry-run mode, the emails are logged or written
+// to the directory of the config instead of being sent.
+func NewConfiguredSender(params NewConfiguredSenderParams) (*Sender, error) {
+	var config Config
+	if c, ok := params.Config.(mailConfig); ok {
+		config = c.GetMail()
+	}
+	driver, err := NewDriver(config)
+	if err != nil {
+		return nil, err
+	}
+	if config.Driver == "" {
+		config.Driver = DriverLog
+	}
+	opts := []Option{WithFrom(config.From)}
+	if params.Templates != nil {
+		opts = append(opts, WithTemplates(NewTemplates(params.Templates)))
+	}
+	if params.Broker != nil && config.Queue != "" {
+		opts = append(opts, WithQueue(params.Broker, config.Queue))
+	}
+	if config.DryRun.Enabled {
+		opts = append(opts, WithDryRun(config.DryRun.Driver()))
+	}
+	return New(config.Driver, driver, opts...), nil
+}
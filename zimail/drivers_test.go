@@ -0,0 +1,182 @@
+package zimail
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testMessage = &Message{
+	From:    "Acme <no-reply@acme.com>",
+	To:      []string{"Jane <jane@example.com>"},
+	Bcc:     []string{"audit@acme.com"},
+	Subject: "Your receipt",
+	Text:    "Thanks",
+	HTML:    "<p>Thanks</p>",
+}
+
+func TestRaw(t *testing.T) {
+	raw, err := Raw(testMessage)
+	if err != nil {
+		t.Fatalf("Raw = %v", err)
+	}
+	m, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatalf("invalid MIME message: %v", err)
+	}
+	if m.Header.Get("Bcc") != "" || m.Header.Get("Subject") != "Your receipt" {
+		t.Errorf("headers = %v, want the subject and no Bcc", m.Header)
+	}
+	mediaType, params, _ := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %s, want multipart/alternative", mediaType)
+	}
+	r := multipart.NewReader(m.Body, params["boundary"])
+	var bodies []string
+	for {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(part)
+		bodies = append(bodies, part.Header.Get("Content-Type")+" "+string(b))
+	}
+	if strings.Join(bodies, "|") != "text/plain; charset=utf-8 Thanks|text/html; charset=utf-8 <p>Thanks</p>" {
+		t.Errorf("parts = %q", bodies)
+	}
+}
+
+func TestSESDriver(t *testing.T) {
+	var input struct {
+		Destination struct{ ToAddresses []string }
+		Content     struct{ Raw struct{ Data []byte } }
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/email/outbound-emails" || !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			w.Header().Set("X-Amzn-ErrorType", "AccessDeniedException:http://internal.amazon.com/")
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `{"message":"denied"}`)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&input)
+		io.WriteString(w, `{"MessageId":"1"}`)
+	}))
+	defer srv.Close()
+
+	driver := NewSESDriver(SESConfig{Region: "us-east-1", Endpoint: srv.URL, AccessKeyID: "AKID", SecretAccessKey: "secret"}, srv.Client())
+	if err := driver.Send(context.Background(), testMessage); err != nil {
+		t.Fatalf("Send = %v", err)
+	}
+	if len(input.Destination.ToAddresses) != 2 || !strings.Contains(string(input.Content.Raw.Data), "Subject: Your receipt") {
+		t.Errorf("input = %+v, want the recipients and the raw email", input)
+	}
+
+	driver = NewSESDriver(SESConfig{Region: "us-east-1", Endpoint: srv.URL, AccessKeyID: "other"}, srv.Client())
+	var e *Error
+	if err := driver.Send(context.Background(), testMessage); !errors.As(err, &e) || e.Code != "AccessDeniedException" || e.Temporary() {
+		t.Errorf("Send = %v, want AccessDeniedException", err)
+	}
+}
+
+func TestSendGridDriver(t *testing.T) {
+	var input sendGridMail
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, `{"errors":[{"message":"invalid key"}]}`)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&input)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	if err := NewSendGridDriver(SendGridConfig{APIKey: "key", Endpoint: srv.URL}, srv.Client()).Send(context.Background(), testMessage); err != nil {
+		t.Fatalf("Send = %v", err)
+	}
+	p := input.Personalizations[0]
+	if input.From.Email != "no-reply@acme.com" || p.To[0].Name != "Jane" || p.Bcc[0].Email != "audit@acme.com" ||
+		len(input.Content) != 2 || input.Content[0].Type != "text/plain" {
+		t.Errorf("input = %+v", input)
+	}
+
+	var e *Error
+	err := NewSendGridDriver(SendGridConfig{APIKey: "bad", Endpoint: srv.URL}, srv.Client()).Send(context.Background(), testMessage)
+	if !errors.As(err, &e) || e.Status != http.StatusUnauthorized || e.Message != "invalid key" {
+		t.Errorf("Send = %v, want the 401 error", err)
+	}
+}
+
+func TestSMTPDriver(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+		reply("220 localhost ESMTP")
+		var commands []string
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			commands = append(commands, line)
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				reply("250 localhost")
+			case line == "DATA":
+				reply("354 go ahead")
+				for {
+					l, _ := r.ReadString('\n')
+					if l == ".\r\n" {
+						break
+					}
+				}
+				reply("250 queued")
+			case line == "QUIT":
+				reply("221 bye")
+				received <- commands
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	p, _ := strconv.Atoi(port)
+	driver := NewSMTPDriver(SMTPConfig{Host: "127.0.0.1", Port: p, Timeout: 5 * time.Second})
+	if err := driver.Send(context.Background(), testMessage); err != nil {
+		t.Fatalf("Send = %v", err)
+	}
+	commands := <-received
+	want := "MAIL FROM:<no-reply@acme.com>|RCPT TO:<jane@example.com>|RCPT TO:<audit@acme.com>|DATA"
+	if got := strings.Join(commands[1:5], "|"); got != want {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,68 @@
+package zimail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/divikraf/lumos/zilog"
+)
+
+// DryRunConfig configures the dry-run mode of the Sender, e.g. in staging
+// environments, where the emails are rendered but not sent.
+type DryRunConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Dir, if set, is the directory the emails are written to as .eml
+	// files, instead of being logged.
+	Dir string `json:"dir" yaml:"dir"`
+}
+
+// Driver returns the Driver of the dry-run mode: the DirDriver of Dir, or
+// the LogDriver.
+func (c DryRunConfig) Driver() Driver {
+	if c.Dir != "" {
+		return DirDriver(c.Dir)
+	}
+	return LogDriver()
+}
+
+// WithDryRun sends the emails with driver, e.g. the LogDriver or a
+// DirDriver, instead of the driver of the Sender. The deliveries are still
+// traced and measured, tagged dry_run=true.
+func WithDryRun(driver Driver) Option {
+	return func(s *Sender) {
+		s.dryRun = driver
+	}
+}
+
+// DirDriver returns the Driver writing the emails to dir, created if
+// missing, as .eml files named after the time they are sent, e.g. to open
+// them with a mail client. The Bcc addresses are logged, as they aren't
+// part of the files.
+func DirDriver(dir string) Driver {
+	return DriverFunc(func(ctx context.Context, msg *Message) error {
+		raw, err := Raw(msg)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("zimail: failed to create %s: %w", dir, err)
+		}
+		b := make([]byte, 4)
+		rand.Read(b)
+		name := filepath.Join(dir, time.Now().UTC().Format("20060102T150405.000000000")+"-"+hex.EncodeToString(b)+".eml")
+		if err := os.WriteFile(name, raw, 0o644); err != nil {
+			return fmt.Errorf("zimail: failed to write %s: %w", name, err)
+		}
+		zilog.FromContext(ctx).Info().
+			Str("file", name).
+			Strs("bcc", msg.Bcc).
+			Str("subject", msg.Subject).
+			Msg("Email not sent, written by the dir driver")
+		return nil
+	})
+}
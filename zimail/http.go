@@ -0,0 +1,48 @@
+package zimail
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/divikraf/lumos/i18n"
+	"github.com/divikraf/lumos/zin"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+)
+
+// PreviewPath is the path of the template preview admin endpoint.
+const PreviewPath = "/mail/templates/:name/preview"
+
+// RegisterRoutes mounts the template preview admin endpoint on router, best
+// on an internal server: POST PreviewPath?lang=en renders the template name
+// with the JSON body as data, in the language lang (default: the language
+// of the request context), and responds the rendered email, which isn't
+// sent.
+func (s *Sender) RegisterRoutes(router gin.IRouter) {
+	router.POST(PreviewPath, func(ctx *gin.Context) {
+		var data any
+		if err := json.NewDecoder(ctx.Request.Body).Decode(&data); err != nil && !errors.Is(err, io.EOF) {
+			zin.AbortWithError(ctx, zin.ErrBadRequest)
+			return
+		}
+		c := ctx.Request.Context()
+		if lang := ctx.Query("lang"); lang != "" {
+			tag, err := language.Parse(lang)
+			if err != nil {
+				zin.AbortWithError(ctx, zin.ErrBadRequest)
+				return
+			}
+			c = i18n.WithContext(c, tag)
+		}
+		msg, err := s.Preview(c, ctx.Param("name"), data)
+		switch {
+		case errors.Is(err, ErrTemplateNotFound), errors.Is(err, ErrNoTemplates):
+			zin.AbortWithError(ctx, zin.ErrNotFound)
+		case err != nil:
+			zin.AbortWithError(ctx, err)
+		default:
+			zin.OK(ctx, msg)
+		}
+	})
+}
@@ -0,0 +1,128 @@
+// Package zimail sends transactional emails, e.g. sign-up confirmations or
+// receipts, with a Driver: SMTP, Amazon SES or SendGrid, or the log for local
+// development. The emails are rendered from templates in the language of
+// their context, see Templates, and sent right away by a Sender, or enqueued
+// to be sent by a ziqueue consumer, redelivered on failures.
+//
+// In dry-run mode, e.g. in staging environments, the emails are rendered and
+// logged, or written to a directory, instead of being sent, see WithDryRun,
+// and the templates can be previewed on an admin endpoint, see
+// Sender.RegisterRoutes.
+package zimail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+)
+
+var (
+	// ErrNoSender is returned for messages without From address.
+	ErrNoSender = errors.New("zimail: no sender")
+	// ErrNoRecipients is returned for messages without recipients.
+	ErrNoRecipients = errors.New("zimail: no recipients")
+)
+
+// Drivers, the mail.driver of the telemetry.
+const (
+	DriverSMTP     = "smtp"
+	DriverSES      = "ses"
+	DriverSendGrid = "sendgrid"
+	DriverLog      = "log"
+)
+
+func init() {
+	revelio.RegisterErrorType(ErrNoSender, "no_sender")
+	revelio.RegisterErrorType(ErrNoRecipients, "no_recipients")
+}
+
+// Message is an email. The addresses are RFC 5322 addresses, e.g.
+// "Acme <no-reply@acme.com>".
+type Message struct {
+	From    string   `json:"from"`
+	To      []string `json:"to"`
+	Cc      []string `json:"cc,omitempty"`
+	Bcc     []string `json:"bcc,omitempty"`
+	ReplyTo string   `json:"reply_to,omitempty"`
+	Subject string   `json:"subject"`
+	// Text and HTML are the bodies of the email, at least one is set.
+	Text    string            `json:"text,omitempty"`
+	HTML    string            `json:"html,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Recipients returns the To, Cc and Bcc addresses of m.
+func (m *Message) Recipients() []string {
+	recipients := make([]string, 0, len(m.To)+len(m.Cc)+len(m.Bcc))
+	recipients = append(recipients, m.To...)
+	recipients = append(recipients, m.Cc...)
+	return append(recipients, m.Bcc...)
+}
+
+func (m *Message) validate() error {
+	if m.From == "" {
+		return ErrNoSender
+	}
+	if len(m.To)+len(m.Cc)+len(m.Bcc) == 0 {
+		return ErrNoRecipients
+	}
+	return nil
+}
+
+// Driver sends emails, e.g. with an SMTP server or the API of a provider.
+type Driver interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// DriverFunc is a Driver function.
+type DriverFunc func(ctx context.Context, msg *Message) error
+
+func (f DriverFunc) Send(ctx context.Context, msg *Message) error {
+	return f(ctx, msg)
+}
+
+// Error is an error response of a provider.
+type Error struct {
+	Driver string
+	Status int
+	// Code is the error code of the response, if any.
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("zimail: %s responded %d %s: %s", e.Driver, e.Status, e.Code, e.Message)
+}
+
+// ErrorType returns the code of the error, as the normalized error type of
+// metrics.
+func (e *Error) ErrorType() string {
+	if e.Code == "" {
+		return "http_" + strconv.Itoa(e.Status)
+	}
+	return e.Code
+}
+
+// Temporary reports whether sending the email again may succeed: on
+// throttling and server errors.
+func (e *Error) Temporary() bool {
+	return e.Status == 429 || e.Status >= 500
+}
+
+// LogDriver returns the Driver logging the emails with the logger of their
+// context instead of sending them, e.g. for local development.
+func LogDriver() Driver {
+	return DriverFunc(func(ctx context.Context, msg *Message) error {
+		zilog.FromContext(ctx).Info().
+			Str("from", msg.From).
+			Strs("to", msg.Recipients()).
+			Str("subject", msg.Subject).
+			Str("text", msg.Text).
+			Msg("Email not sent, logged by the log driver")
+		return nil
+	})
+}
@@ -0,0 +1,124 @@
+package zimail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Raw returns the MIME encoding of msg, as sent by SMTP, with a
+// multipart/alternative body when it has both a text and an HTML body. The
+// Bcc addresses aren't part of it.
+func Raw(msg *Message) ([]byte, error) {
+	var buf bytes.Buffer
+	header := textproto.MIMEHeader{}
+	header.Set("From", msg.From)
+	header.Set("To", strings.Join(msg.To, ", "))
+	if len(msg.Cc) > 0 {
+		header.Set("Cc", strings.Join(msg.Cc, ", "))
+	}
+	if msg.ReplyTo != "" {
+		header.Set("Reply-To", msg.ReplyTo)
+	}
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header.Set("Date", time.Now().Format(time.RFC1123Z))
+	header.Set("Message-Id", messageID(msg.From))
+	header.Set("Mime-Version", "1.0")
+	for key, value := range msg.Headers {
+		header.Set(key, value)
+	}
+
+	if msg.Text == "" || msg.HTML == "" {
+		contentType, body := "text/plain", msg.Text
+		if msg.HTML != "" {
+			contentType, body = "text/html", msg.HTML
+		}
+		header.Set("Content-Type", contentType+"; charset=utf-8")
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		writeHeader(&buf, header)
+		if err := writeQuotedPrintable(&buf, body); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	header.Set("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	var head bytes.Buffer
+	writeHeader(&head, header)
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain", msg.Text},
+		{"text/html", msg.HTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return append(head.Bytes(), buf.Bytes()...), nil
+}
+
+// writeHeader writes header, sorted, and the blank line ending it.
+func writeHeader(buf *bytes.Buffer, header textproto.MIMEHeader) {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range header[key] {
+			fmt.Fprintf(buf, "%s: %s\r\n", key, value)
+		}
+	}
+	buf.WriteString("\r\n")
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// messageID returns a unique Message-ID in the domain of from.
+func messageID(from string) string {
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if _, d, ok := strings.Cut(addr.Address, "@"); ok {
+			domain = d
+		}
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
+
+// address returns the address of the RFC 5322 address addr, e.g.
+// "no-reply@acme.com" of "Acme <no-reply@acme.com>".
+func address(addr string) (string, error) {
+	a, err := mail.ParseAddress(addr)
+	if err != nil {
+		return "", fmt.Errorf("zimail: invalid address %q: %w", addr, err)
+	}
+	return a.Address, nil
+}
@@ -0,0 +1,221 @@
+package zimail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"time"
+
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/ziqueue"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/divikraf/lumos/zimail"
+
+var (
+	tracer = observe.LibraryTracer(instrumentationName)
+	scope  = revelio.Extend(revelio.Library(instrumentationName))
+)
+
+var (
+	// ErrNoTemplates is returned when rendering templates without Templates.
+	ErrNoTemplates = errors.New("zimail: no templates")
+	// ErrNoQueue is returned when enqueuing emails without queue.
+	ErrNoQueue = errors.New("zimail: no queue")
+)
+
+// Sender sends emails with a Driver, right away or through a queue.
+type Sender struct {
+	system    string
+	driver    Driver
+	from      string
+	templates *Templates
+	broker    *ziqueue.Broker
+	queue     string
+	// dryRun, if set, sends the emails instead of driver
+	dryRun Driver
+
+	duration revelio.DurationRecorder
+	results  revelio.ResultCounter
+}
+
+// Option configures a Sender.
+type Option func(*Sender)
+
+// WithFrom sets the From address of the messages without one.
+func WithFrom(from string) Option {
+	return func(s *Sender) {
+		s.from = from
+	}
+}
+
+// WithTemplates sets the templates of SendTemplate and EnqueueTemplate.
+func WithTemplates(t *Templates) Option {
+	return func(s *Sender) {
+		s.templates = t
+	}
+}
+
+// WithQueue sets the queue of broker the emails are enqueued to by Enqueue,
+// to be sent by the Handler subscribed to it.
+func WithQueue(broker *ziqueue.Broker, queue string) Option {
+	return func(s *Sender) {
+		s.broker = broker
+		s.queue = queue
+	}
+}
+
+// New returns a Sender sending the emails with driver, e.g. DriverSMTP. The
+// deliveries are traced, timed in the mail_send_duration_ms metric, and
+// counted in mail_sent_total, by driver, dry_run and status.
+func New(system string, driver Driver, opts ...Option) *Sender {
+	s := &Sender{
+		system:   system,
+		driver:   driver,
+		duration: revelio.Must(scope.Duration("mail_send_duration_ms", "Duration of email deliveries in milliseconds")),
+		results:  revelio.Must(scope.ResultCounter("mail_sent_total", "Number of email deliveries, by driver, dry_run and status")),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Send sends msg right away.
+func (s *Sender) Send(ctx context.Context, msg *Message) error {
+	if msg.From == "" {
+		msg.From = s.from
+	}
+	if err := msg.validate(); err != nil {
+		return err
+	}
+
+	driver := s.driver
+	if s.dryRun != nil {
+		driver = s.dryRun
+	}
+	ctx, span := tracer.Start(ctx, "mail send",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("mail.driver", s.system),
+			attribute.Bool("mail.dry_run", s.dryRun != nil),
+			attribute.Int("mail.recipients", len(msg.To)+len(msg.Cc)+len(msg.Bcc)),
+		),
+	)
+	defer span.End()
+
+	start := time.Now()
+	err := driver.Send(ctx, msg)
+	attrs := []attribute.KeyValue{attribute.String("driver", s.system), attribute.Bool("dry_run", s.dryRun != nil)}
+	s.duration.Record(ctx, time.Since(start), append(attrs, revelio.ResultAttributes(err)...)...)
+	s.results.Record(ctx, err, attrs...)
+	if err != nil {
+		observe.RecordError(span, err)
+		zilog.FromContext(ctx).Error().Err(err).Str("driver", s.system).Str("subject", msg.Subject).Msg("Failed to send email")
+	}
+	return err
+}
+
+// render renders the template name into msg, in the language of ctx.
+func (s *Sender) render(ctx context.Context, msg *Message, name string, data any) error {
+	if s.templates == nil {
+		return ErrNoTemplates
+	}
+	return s.templates.Render(ctx, name, data, msg)
+}
+
+// Preview returns the email rendered from the template name with data, in
+// the language of ctx, from the default From address, without sending it.
+func (s *Sender) Preview(ctx context.Context, name string, data any) (*Message, error) {
+	msg := &Message{From: s.from}
+	if err := s.render(ctx, msg, name, data); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// SendTemplate renders the template name with data into msg, in the
+// language of ctx, and sends it right away.
+func (s *Sender) SendTemplate(ctx context.Context, msg *Message, name string, data any) error {
+	if err := s.render(ctx, msg, name, data); err != nil {
+		return err
+	}
+	return s.Send(ctx, msg)
+}
+
+// Enqueue enqueues msg to be sent by the Handler subscribed to the queue,
+// redelivered according to the retry policy of the subscription when
+// sending fails.
+func (s *Sender) Enqueue(ctx context.Context, msg *Message) error {
+	if s.broker == nil {
+		return ErrNoQueue
+	}
+	if msg.From == "" {
+		msg.From = s.from
+	}
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return s.broker.Publish(ctx, s.queue, body)
+}
+
+// EnqueueTemplate renders the template name with data into msg, in the
+// language of ctx, and enqueues it, see Enqueue. The template is rendered
+// right away, so data doesn't need to be serializable.
+func (s *Sender) EnqueueTemplate(ctx context.Context, msg *Message, name string, data any) error {
+	if err := s.render(ctx, msg, name, data); err != nil {
+		return err
+	}
+	return s.Enqueue(ctx, msg)
+}
+
+// Queue returns the queue of the emails enqueued by Enqueue, if any.
+func (s *Sender) Queue() string {
+	return s.queue
+}
+
+// Handler returns the ziqueue.Handler sending the enqueued emails. The
+// emails which can't be sent, e.g. rejected by the provider, fail with a
+// permanent error, so they are dead-lettered instead of retried.
+func (s *Sender) Handler() ziqueue.Handler {
+	return func(ctx context.Context, m *ziqueue.Message) error {
+		var msg Message
+		if err := json.Unmarshal(m.Body, &msg); err != nil {
+			return ziqueue.Permanent(fmt.Errorf("zimail: invalid enqueued email: %w", err))
+		}
+		err := s.Send(ctx, &msg)
+		if permanent(err) {
+			return ziqueue.Permanent(err)
+		}
+		return err
+	}
+}
+
+// permanent reports whether sending an email failing with err again can't
+// succeed.
+func permanent(err error) bool {
+	if errors.Is(err, ErrNoSender) || errors.Is(err, ErrNoRecipients) {
+		return true
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return !e.Temporary()
+	}
+	// SMTP replies 5xx to the permanent failures, and 4xx to the transient
+	// ones
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) {
+		return smtpErr.Code >= 500
+	}
+	return false
+}
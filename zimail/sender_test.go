@@ -0,0 +1,189 @@
+package zimail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/divikraf/lumos/zin"
+	"github.com/divikraf/lumos/ziqueue"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// recorder is a Driver recording the sent emails, failing with errs first.
+type recorder struct {
+	sent chan *Message
+	errs []error
+}
+
+func (r *recorder) Send(ctx context.Context, msg *Message) error {
+	if len(r.errs) > 0 {
+		err := r.errs[0]
+		r.errs = r.errs[1:]
+		return err
+	}
+	r.sent <- msg
+	return nil
+}
+
+func TestSend(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	driver := &recorder{sent: make(chan *Message, 1), errs: []error{&Error{Driver: "test", Status: 400, Code: "invalid"}}}
+	sender := New("test", driver, WithFrom("Acme <no-reply@acme.com>"))
+	logger := zerolog.Nop()
+	ctx := logger.WithContext(context.Background())
+
+	if err := sender.Send(ctx, &Message{Subject: "Hi"}); !errors.Is(err, ErrNoRecipients) {
+		t.Errorf("Send without recipients = %v, want ErrNoRecipients", err)
+	}
+	msg := &Message{To: []string{"jane@example.com"}, Subject: "Hi", Text: "Hello"}
+	var mailErr *Error
+	if err := sender.Send(ctx, msg); !errors.As(err, &mailErr) {
+		t.Fatalf("Send = %v, want the driver error", err)
+	}
+	if err := sender.Send(ctx, msg); err != nil {
+		t.Fatalf("Send = %v", err)
+	}
+	if sent := <-driver.sent; sent.From != "Acme <no-reply@acme.com>" {
+		t.Errorf("From = %q, want the default", sent.From)
+	}
+
+	reveliotest.AssertCounterValue(t, s, "mail_sent_total", 1,
+		attribute.String("driver", "test"), revelio.StatusKey.String(revelio.StatusSuccess))
+	reveliotest.AssertCounterValue(t, s, "mail_sent_total", 1,
+		attribute.String("driver", "test"), revelio.ErrorTypeKey.String("invalid"))
+	if h := reveliotest.CollectHistogram(t, s, "mail_send_duration_ms",
+		attribute.String("driver", "test"), revelio.StatusKey.String(revelio.StatusSuccess)); h.Count != 1 {
+		t.Errorf("mail_send_duration_ms count = %d, want 1 success", h.Count)
+	}
+}
+
+func TestSendDryRun(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	driver := &recorder{sent: make(chan *Message, 1)}
+	dir := t.TempDir()
+	sender := New("ses", driver, WithFrom("no-reply@acme.com"), WithDryRun(DirDriver(dir)))
+	logger := zerolog.Nop()
+	ctx := logger.WithContext(context.Background())
+
+	msg := &Message{To: []string{"jane@example.com"}, Bcc: []string{"audit@acme.com"}, Subject: "Receipt", Text: "Thanks"}
+	if err := sender.Send(ctx, msg); err != nil {
+		t.Fatalf("Send = %v", err)
+	}
+	if len(driver.sent) != 0 {
+		t.Error("email sent in dry-run mode")
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("files = %v, want 1 email", files)
+	}
+	if raw, _ := os.ReadFile(files[0]); !strings.Contains(string(raw), "Subject: Receipt") || strings.Contains(string(raw), "audit@acme.com") {
+		t.Errorf("written email = %q", raw)
+	}
+	reveliotest.AssertCounterValue(t, s, "mail_sent_total", 1,
+		attribute.String("driver", "ses"), attribute.Bool("dry_run", true), revelio.StatusKey.String(revelio.StatusSuccess))
+}
+
+func TestPreview(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	templates := NewTemplates(fstest.MapFS{
+		"receipt/subject.txt": {Data: []byte(`Receipt {{ .Order }}`)},
+		"receipt/body.txt":    {Data: []byte(`Total: {{ .Total }}`)},
+	})
+	driver := &recorder{sent: make(chan *Message, 1)}
+	sender := New("test", driver, WithFrom("no-reply@acme.com"), WithTemplates(templates))
+
+	router := gin.New()
+	router.Use(zin.ErrorMiddleware())
+	sender.RegisterRoutes(router)
+	do := func(name, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/mail/templates/"+name+"/preview?lang=en", strings.NewReader(body)))
+		return w
+	}
+
+	w := do("receipt", `{"Order": "o-1", "Total": "$42"}`)
+	var body struct {
+		Data Message `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); w.Code != http.StatusOK || err != nil {
+		t.Fatalf("preview = %d %s", w.Code, w.Body)
+	}
+	if got := body.Data; got.From != "no-reply@acme.com" || got.Subject != "Receipt o-1" || got.Text != "Total: $42" {
+		t.Errorf("previewed %+v", got)
+	}
+	if len(driver.sent) != 0 {
+		t.Error("previewed email sent")
+	}
+	if w := do("missing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("preview of a missing template = %d, want 404", w.Code)
+	}
+	if w := do("receipt", "{"); w.Code != http.StatusBadRequest {
+		t.Errorf("preview of invalid data = %d, want 400", w.Code)
+	}
+}
+
+func TestEnqueue(t *testing.T) {
+	logger := zerolog.Nop()
+	ctx, cancel := context.WithCancel(logger.WithContext(context.Background()))
+	defer cancel()
+	broker := ziqueue.NewBroker(ziqueue.SystemMemory, ziqueue.NewMemoryDriver(), nil)
+	driver := &recorder{sent: make(chan *Message, 1), errs: []error{&Error{Driver: "test", Status: 503}}}
+	sender := New("test", driver, WithFrom("no-reply@acme.com"), WithQueue(broker, "emails"))
+
+	subscribed := make(chan error)
+	go func() {
+		subscribed <- broker.Subscribe(ctx, sender.Queue(), sender.Handler(), ziqueue.SubscriptionConfig{
+			Retry: ziqueue.RetryPolicy{Backoff: time.Millisecond},
+		})
+	}()
+	if err := sender.Enqueue(ctx, &Message{To: []string{"jane@example.com"}, Subject: "Receipt", HTML: "<p>Thanks</p>"}); err != nil {
+		t.Fatalf("Enqueue = %v", err)
+	}
+	select {
+	case msg := <-driver.sent:
+		if msg.Subject != "Receipt" || msg.HTML != "<p>Thanks</p>" || msg.From != "no-reply@acme.com" {
+			t.Errorf("sent %+v, want the enqueued email", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("enqueued email not sent")
+	}
+	cancel()
+	<-subscribed
+
+	if err := New("test", driver).Enqueue(ctx, &Message{}); !errors.Is(err, ErrNoQueue) {
+		t.Errorf("Enqueue without queue = %v, want ErrNoQueue", err)
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{ErrNoRecipients, true},
+		{&Error{Status: 400}, true},
+		{&Error{Status: 429}, false},
+		{&Error{Status: 502}, false},
+		{&textproto.Error{Code: 550, Msg: "mailbox unavailable"}, true},
+		{&textproto.Error{Code: 421, Msg: "try later"}, false},
+		{errors.New("connection reset"), false},
+	} {
+		if got := permanent(tt.err); got != tt.want {
+			t.Errorf("permanent(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
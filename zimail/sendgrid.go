@@ -0,0 +1,144 @@
+package zimail
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/mail"
+	"strings"
+)
+
+// SendGridConfig configures the SendGrid driver.
+type SendGridConfig struct {
+	APIKey string `json:"api_key" yaml:"api_key"`
+	// Endpoint is the URL of the API (default: https://api.sendgrid.com).
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+}
+
+type sendGridDriver struct {
+	config SendGridConfig
+	client *http.Client
+}
+
+// NewSendGridDriver returns the Driver sending the emails with the v3 Mail
+// Send API of SendGrid, with client, or http.DefaultClient if nil.
+func NewSendGridDriver(config SendGridConfig, client *http.Client) Driver {
+	if config.Endpoint == "" {
+		config.Endpoint = "https://api.sendgrid.com"
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &sendGridDriver{config: config, client: client}
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+func sendGridAddresses(addrs []string) ([]sendGridAddress, error) {
+	if len(addrs) == 0 {
+		return nil, nil
+	}
+	list := make([]sendGridAddress, len(addrs))
+	for i, addr := range addrs {
+		a, err := mail.ParseAddress(addr)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = sendGridAddress{Email: a.Address, Name: a.Name}
+	}
+	return list, nil
+}
+
+type sendGridPersonalization struct {
+	To  []sendGridAddress `json:"to,omitempty"`
+	Cc  []sendGridAddress `json:"cc,omitempty"`
+	Bcc []sendGridAddress `json:"bcc,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridMail struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	ReplyTo          *sendGridAddress          `json:"reply_to,omitempty"`
+	Subject          string                    `json:"subject"`
+	Headers          map[string]string         `json:"headers,omitempty"`
+	Content          []sendGridContent         `json:"content"`
+}
+
+func (d *sendGridDriver) Send(ctx context.Context, msg *Message) error {
+	var (
+		p   sendGridPersonalization
+		err error
+	)
+	if p.To, err = sendGridAddresses(msg.To); err != nil {
+		return err
+	}
+	if p.Cc, err = sendGridAddresses(msg.Cc); err != nil {
+		return err
+	}
+	if p.Bcc, err = sendGridAddresses(msg.Bcc); err != nil {
+		return err
+	}
+	from, err := sendGridAddresses([]string{msg.From})
+	if err != nil {
+		return err
+	}
+	input := sendGridMail{
+		Personalizations: []sendGridPersonalization{p},
+		From:             from[0],
+		Subject:          msg.Subject,
+		Headers:          msg.Headers,
+	}
+	if msg.ReplyTo != "" {
+		replyTo, err := sendGridAddresses([]string{msg.ReplyTo})
+		if err != nil {
+			return err
+		}
+		input.ReplyTo = &replyTo[0]
+	}
+	// The text content must come first
+	if msg.Text != "" {
+		input.Content = append(input.Content, sendGridContent{Type: "text/plain", Value: msg.Text})
+	}
+	if msg.HTML != "" {
+		input.Content = append(input.Content, sendGridContent{Type: "text/html", Value: msg.HTML})
+	}
+
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(d.config.Endpoint, "/")+"/v3/mail/send", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+d.config.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Errors []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		messages := make([]string, len(e.Errors))
+		for i, err := range e.Errors {
+			messages[i] = err.Message
+		}
+		return &Error{Driver: DriverSendGrid, Status: resp.StatusCode, Message: strings.Join(messages, "; ")}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package zimail
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/divikraf/lumos/internal/awsv4"
+)
+
+// SESConfig configures the Amazon SES driver.
+type SESConfig struct {
+	// Region is the region of SES (default: the AWS_REGION or
+	// AWS_DEFAULT_REGION variable).
+	Region string `json:"region" yaml:"region"`
+	// Endpoint is the URL of the service (default:
+	// https://email.<region>.amazonaws.com).
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+	// ConfigurationSet, if set, is the configuration set of the emails,
+	// e.g. to publish their events.
+	ConfigurationSet string `json:"configuration_set" yaml:"configuration_set"`
+	// AccessKeyID, SecretAccessKey and SessionToken are the credentials
+	// (default: the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+	// AWS_SESSION_TOKEN variables).
+	AccessKeyID     string `json:"access_key_id" yaml:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key" yaml:"secret_access_key"`
+	SessionToken    string `json:"session_token" yaml:"session_token"`
+}
+
+type sesDriver struct {
+	config SESConfig
+	client *http.Client
+	creds  awsv4.Credentials
+	now    func() time.Time
+}
+
+// NewSESDriver returns the Driver sending the emails with the SendEmail
+// action of the Amazon SES v2 API, as raw MIME emails, with client, or
+// http.DefaultClient if nil.
+func NewSESDriver(config SESConfig, client *http.Client) Driver {
+	if config.Region == "" {
+		config.Region = os.Getenv("AWS_REGION")
+	}
+	if config.Region == "" {
+		config.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = "https://email." + config.Region + ".amazonaws.com"
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &sesDriver{config: config, client: client, now: time.Now, creds: awsv4.CredentialsFromEnv(awsv4.Credentials{
+		AccessKeyID:     config.AccessKeyID,
+		SecretAccessKey: config.SecretAccessKey,
+		SessionToken:    config.SessionToken,
+	})}
+}
+
+func (d *sesDriver) Send(ctx context.Context, msg *Message) error {
+	raw, err := Raw(msg)
+	if err != nil {
+		return err
+	}
+	input := map[string]any{
+		// The recipients are set so the Bcc addresses, absent from the
+		// headers, receive the email too
+		"Destination": map[string][]string{"ToAddresses": msg.Recipients()},
+		"Content":     map[string]any{"Raw": map[string][]byte{"Data": raw}},
+	}
+	if d.config.ConfigurationSet != "" {
+		input["ConfigurationSetName"] = d.config.ConfigurationSet
+	}
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(d.config.Endpoint, "/")+"/v2/email/outbound-emails", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	d.sign(req, body)
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		code, _, _ := strings.Cut(resp.Header.Get("X-Amzn-ErrorType"), ":")
+		return &Error{Driver: DriverSES, Status: resp.StatusCode, Code: code, Message: e.Message}
+	}
+	return nil
+}
+
+// sign signs req, of payload body, with AWS Signature Version 4.
+func (d *sesDriver) sign(req *http.Request, body []byte) {
+	awsv4.Sign(req, body, d.creds, d.config.Region, "ses", d.now())
+}
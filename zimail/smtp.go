@@ -0,0 +1,111 @@
+package zimail
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTPConfig configures the SMTP driver.
+type SMTPConfig struct {
+	Host string `json:"host" yaml:"host"`
+	// Port is the port of the server (default: 587).
+	Port int `json:"port" yaml:"port"`
+	// Username and Password, if set, authenticate with PLAIN auth.
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+	// ImplicitTLS connects with TLS, e.g. on port 465, instead of upgrading
+	// the connection with STARTTLS when the server supports it.
+	ImplicitTLS bool `json:"implicit_tls" yaml:"implicit_tls"`
+	// Timeout is the maximum duration of a delivery (default: 30s).
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+}
+
+type smtpDriver struct {
+	config SMTPConfig
+}
+
+// NewSMTPDriver returns the Driver sending the emails with the SMTP server of
+// config, one connection per email.
+func NewSMTPDriver(config SMTPConfig) Driver {
+	if config.Port == 0 {
+		config.Port = 587
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+	return &smtpDriver{config: config}
+}
+
+func (d *smtpDriver) Send(ctx context.Context, msg *Message) error {
+	raw, err := Raw(msg)
+	if err != nil {
+		return err
+	}
+	from, err := address(msg.From)
+	if err != nil {
+		return err
+	}
+	recipients := msg.Recipients()
+	for i, r := range recipients {
+		if recipients[i], err = address(r); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.config.Timeout)
+	defer cancel()
+	addr := net.JoinHostPort(d.config.Host, strconv.Itoa(d.config.Port))
+	tlsConfig := &tls.Config{ServerName: d.config.Host}
+	var conn net.Conn
+	if d.config.ImplicitTLS {
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, d.config.Host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok && !d.config.ImplicitTLS {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if d.config.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", d.config.Username, d.config.Password, d.config.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, r := range recipients {
+		if err := c.Rcpt(r); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(raw); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,177 @@
+package zimail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"sync"
+	texttemplate "text/template"
+
+	"github.com/divikraf/lumos/i18n"
+	"golang.org/x/text/language"
+)
+
+// ErrTemplateNotFound is returned for templates without subject or body.
+var ErrTemplateNotFound = errors.New("zimail: template not found")
+
+// Template files of a template directory.
+const (
+	SubjectFile = "subject.txt"
+	TextFile    = "body.txt"
+	HTMLFile    = "body.html"
+)
+
+// Templates renders emails from the templates of a file system, e.g. an
+// embed.FS, by name: the directory of their subject.txt, body.txt and
+// body.html files, the subject and at least one body being required, e.g.
+//
+//	welcome/subject.txt
+//	welcome/body.html
+//	welcome/id/subject.txt
+//	welcome/id/body.html
+//
+// The files of the subdirectory of the language of the context, e.g. "id",
+// take precedence, then those of i18n.FallbackLanguage, then those of the
+// template directory. The subject and the text body are text/template
+// templates, the HTML body an html/template template. Messages registered
+// with i18n.AddMessages are translated in the language of the context with
+// the t function, e.g. {{ t "welcome.greeting" .Name }}.
+type Templates struct {
+	fsys fs.FS
+
+	mu    sync.Mutex
+	texts map[string]*texttemplate.Template
+	htmls map[string]*htmltemplate.Template
+}
+
+// NewTemplates returns the Templates of fsys.
+func NewTemplates(fsys fs.FS) *Templates {
+	return &Templates{
+		fsys:  fsys,
+		texts: map[string]*texttemplate.Template{},
+		htmls: map[string]*htmltemplate.Template{},
+	}
+}
+
+// placeholderFuncs declares the functions of the templates, bound to the
+// context of the rendering by Render.
+var placeholderFuncs = map[string]any{
+	"t": func(key string, args ...any) string { return key },
+}
+
+// Render renders the subject and bodies of the template name into msg, in
+// the language of ctx.
+func (t *Templates) Render(ctx context.Context, name string, data any, msg *Message) error {
+	funcs := map[string]any{
+		"t": func(key string, args ...any) string { return i18n.Translate(ctx, key, args...) },
+	}
+	dirs := t.dirs(ctx, name)
+
+	subject, err := t.renderText(dirs, SubjectFile, funcs, data)
+	if err != nil {
+		return err
+	}
+	text, err := t.renderText(dirs, TextFile, funcs, data)
+	if err != nil {
+		return err
+	}
+	html, err := t.renderHTML(dirs, funcs, data)
+	if err != nil {
+		return err
+	}
+	if subject == nil || (text == nil && html == nil) {
+		return fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+
+	msg.Subject = *subject
+	if text != nil {
+		msg.Text = *text
+	}
+	if html != nil {
+		msg.HTML = *html
+	}
+	return nil
+}
+
+// dirs returns the directories of the files of the template name, in order
+// of precedence.
+func (t *Templates) dirs(ctx context.Context, name string) []string {
+	var dirs []string
+	for _, lang := range []language.Tag{i18n.FromContext(ctx), i18n.FallbackLanguage} {
+		base, _ := lang.Base()
+		dirs = append(dirs, path.Join(name, base.String()))
+	}
+	return append(dirs, name)
+}
+
+// find returns the path of the first file of dirs, or "".
+func (t *Templates) find(dirs []string, file string) string {
+	for _, dir := range dirs {
+		if _, err := fs.Stat(t.fsys, path.Join(dir, file)); err == nil {
+			return path.Join(dir, file)
+		}
+	}
+	return ""
+}
+
+func (t *Templates) renderText(dirs []string, file string, funcs map[string]any, data any) (*string, error) {
+	name := t.find(dirs, file)
+	if name == "" {
+		return nil, nil
+	}
+	t.mu.Lock()
+	tmpl, ok := t.texts[name]
+	if !ok {
+		var err error
+		if tmpl, err = texttemplate.New(path.Base(name)).Funcs(placeholderFuncs).ParseFS(t.fsys, name); err != nil {
+			t.mu.Unlock()
+			return nil, fmt.Errorf("zimail: failed to parse %s: %w", name, err)
+		}
+		t.texts[name] = tmpl
+	}
+	t.mu.Unlock()
+
+	tmpl, err := tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Funcs(funcs).Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("zimail: failed to render %s: %w", name, err)
+	}
+	s := buf.String()
+	return &s, nil
+}
+
+func (t *Templates) renderHTML(dirs []string, funcs map[string]any, data any) (*string, error) {
+	name := t.find(dirs, HTMLFile)
+	if name == "" {
+		return nil, nil
+	}
+	t.mu.Lock()
+	tmpl, ok := t.htmls[name]
+	if !ok {
+		var err error
+		if tmpl, err = htmltemplate.New(path.Base(name)).Funcs(placeholderFuncs).ParseFS(t.fsys, name); err != nil {
+			t.mu.Unlock()
+			return nil, fmt.Errorf("zimail: failed to parse %s: %w", name, err)
+		}
+		t.htmls[name] = tmpl
+	}
+	t.mu.Unlock()
+
+	tmpl, err := tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Funcs(funcs).Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("zimail: failed to render %s: %w", name, err)
+	}
+	s := buf.String()
+	return &s, nil
+}
@@ -0,0 +1,44 @@
+package zimail
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/divikraf/lumos/i18n"
+	"golang.org/x/text/language"
+)
+
+func TestTemplates(t *testing.T) {
+	i18n.AddMessages(language.English, map[string]string{"welcome.greeting": "Hello %s"})
+	i18n.AddMessages(language.Indonesian, map[string]string{"welcome.greeting": "Halo %s"})
+	templates := NewTemplates(fstest.MapFS{
+		"welcome/subject.txt":    {Data: []byte(`{{ t "welcome.greeting" .Name }}!`)},
+		"welcome/body.html":      {Data: []byte(`<p>{{ t "welcome.greeting" .Name }}</p>`)},
+		"welcome/en/subject.txt": {Data: []byte(`Welcome {{ .Name }}`)},
+		"welcome/en/body.txt":    {Data: []byte(`{{ t "welcome.greeting" .Name }}`)},
+	})
+
+	tests := []struct {
+		lang language.Tag
+		want Message
+	}{
+		{language.BritishEnglish, Message{Subject: "Welcome <Jane>", Text: "Hello <Jane>", HTML: "<p>Hello &lt;Jane&gt;</p>"}},
+		{language.Indonesian, Message{Subject: "Halo <Jane>!", HTML: "<p>Halo &lt;Jane&gt;</p>"}},
+	}
+	for _, tt := range tests {
+		var msg Message
+		ctx := i18n.WithContext(context.Background(), tt.lang)
+		if err := templates.Render(ctx, "welcome", map[string]string{"Name": "<Jane>"}, &msg); err != nil {
+			t.Fatalf("Render(%s) = %v", tt.lang, err)
+		}
+		if msg.Subject != tt.want.Subject || msg.Text != tt.want.Text || msg.HTML != tt.want.HTML {
+			t.Errorf("Render(%s) = %+v, want %+v", tt.lang, msg, tt.want)
+		}
+	}
+
+	if err := templates.Render(context.Background(), "missing", nil, &Message{}); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("Render(missing) = %v, want ErrTemplateNotFound", err)
+	}
+}
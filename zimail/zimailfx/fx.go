@@ -0,0 +1,46 @@
+package zimailfx
+
+import (
+	"errors"
+	"io/fs"
+
+	"github.com/divikraf/lumos/zimail"
+	"github.com/divikraf/lumos/zin"
+	"github.com/divikraf/lumos/zin/zinfx"
+	"github.com/divikraf/lumos/ziqueue/ziqueuefx"
+	"go.uber.org/fx"
+)
+
+// Provider provides the *zimail.Sender configured by the mail config. The
+// emails are enqueued with the *ziqueue.Broker, if provided, see
+// ziqueuefx.Provider.
+var Provider = fx.Provide(zimail.NewConfiguredSender)
+
+// WithTemplates provides the templates of the emails, e.g. an embed.FS, see
+// zimail.Templates.
+func WithTemplates(fsys fs.FS) fx.Option {
+	return fx.Provide(fx.Annotate(
+		func() fs.FS { return fsys },
+		fx.ResultTags(`name:"zimail-templates"`),
+	))
+}
+
+// Subscription subscribes the handler of the Sender to the queue of the
+// mail config, to send the enqueued emails, see ziqueuefx.Invoker.
+var Subscription = fx.Provide(ziqueuefx.AsSubscription(
+	func(s *zimail.Sender) (ziqueuefx.Subscription, error) {
+		if s.Queue() == "" {
+			return ziqueuefx.Subscription{}, errors.New("zimailfx: no mail queue configured")
+		}
+		return ziqueuefx.Subscription{Queue: s.Queue(), Handler: s.Handler()}, nil
+	},
+))
+
+// AdminRoutes mounts the template preview admin endpoint on the additional
+// server name, see zinfx.Server, or on the main router when empty.
+func AdminRoutes(server string) fx.Option {
+	return fx.Provide(fx.Annotate(
+		func(s *zimail.Sender) zin.RouteRegistrar { return s },
+		fx.ResultTags(zinfx.RoutesGroup(server)),
+	))
+}
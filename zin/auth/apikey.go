@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"crypto/subtle"
+
+	"github.com/divikraf/lumos/zin"
+	"github.com/gin-gonic/gin"
+)
+
+// Headers of the API key and request signature authentications
+const (
+	ClientIDHeader  = "X-Client-ID"
+	APIKeyHeader    = "X-API-Key"
+	TimestampHeader = "X-Timestamp"
+	SignatureHeader = "X-Signature"
+)
+
+// APIKey authenticates requests with the API key of the client identified by
+// the X-Client-ID header, sent in the X-API-Key header, and looked up in
+// secrets. The client ID is the subject of the request claims.
+func APIKey(secrets SecretStore) gin.HandlerFunc {
+	failures := newFailureCounter("api_key")
+	return func(c *gin.Context) {
+		clientID, key := c.GetHeader(ClientIDHeader), c.GetHeader(APIKeyHeader)
+		if clientID == "" || key == "" {
+			failures.reject(c, ErrMissingToken)
+			return
+		}
+		secret, err := secrets.Secret(c.Request.Context(), clientID)
+		if err != nil {
+			failures.reject(c, err)
+			return
+		}
+		if subtle.ConstantTimeCompare(secret, []byte(key)) != 1 {
+			failures.reject(c, ErrInvalidAPIKey)
+			return
+		}
+		authenticated(c, &zin.Claims{Subject: clientID})
+	}
+}
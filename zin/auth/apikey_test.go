@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/divikraf/lumos/zin"
+	"github.com/gin-gonic/gin"
+)
+
+func subjectHandler(c *gin.Context) {
+	claims, _ := zin.ClaimsFromContext(c.Request.Context())
+	c.String(http.StatusOK, claims.Subject)
+}
+
+func TestAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	failing := SecretStoreFunc(func(context.Context, string) ([]byte, error) { return nil, errors.New("redis down") })
+
+	r := gin.New()
+	r.GET("/", APIKey(StaticSecrets(map[string]string{"billing": "k3y"})), subjectHandler)
+	r.GET("/failing", APIKey(failing), subjectHandler)
+
+	tests := []struct {
+		path     string
+		clientID string
+		key      string
+		status   int
+	}{
+		{path: "/", clientID: "billing", key: "k3y", status: http.StatusOK},
+		{path: "/", clientID: "billing", key: "wrong", status: http.StatusUnauthorized},
+		{path: "/", clientID: "orders", key: "k3y", status: http.StatusUnauthorized},
+		{path: "/", clientID: "billing", status: http.StatusUnauthorized},
+		{path: "/failing", clientID: "billing", key: "k3y", status: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set(ClientIDHeader, tt.clientID)
+		req.Header.Set(APIKeyHeader, tt.key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%s %s/%s: status = %d, want %d", tt.path, tt.clientID, tt.key, w.Code, tt.status)
+		}
+		if w.Code == http.StatusOK && w.Body.String() != tt.clientID {
+			t.Errorf("subject = %q, want %q", w.Body.String(), tt.clientID)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// JWKS is a KeySource fetching the keys from a JWKS endpoint, e.g. the
+// jwks_uri of an OIDC provider. Keys are cached, and refreshed when they
+// expire or when a token is signed by an unknown key, at most once per
+// minimum refresh interval. A single fetch runs at a time, in the background:
+// expired keys are served while they are refreshed, and only the tokens
+// signed by unknown keys wait for the fetch.
+type JWKS struct {
+	url             string
+	client          *http.Client
+	ttl             time.Duration
+	minRefreshDelay time.Duration
+
+	mu        sync.Mutex
+	keys      map[string]any
+	fetchedAt time.Time
+	fetching  *jwksFetch
+}
+
+// jwksFetch is a fetch of the keys, done once they are swapped in.
+type jwksFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// jwksFetchTimeout bounds the fetches, which outlive the requests waiting for
+// them.
+const jwksFetchTimeout = 30 * time.Second
+
+// JWKSOption configures a JWKS.
+type JWKSOption func(*JWKS)
+
+// WithHTTPClient sets the client fetching the keys (default: a client with a
+// 10s timeout).
+func WithHTTPClient(client *http.Client) JWKSOption {
+	return func(j *JWKS) {
+		j.client = client
+	}
+}
+
+// WithCacheTTL sets how long keys are cached (default: 1h).
+func WithCacheTTL(ttl time.Duration) JWKSOption {
+	return func(j *JWKS) {
+		j.ttl = ttl
+	}
+}
+
+// WithMinRefreshInterval sets the minimum delay between two fetches, which
+// protects the endpoint from tokens signed by unknown keys (default: 1m).
+func WithMinRefreshInterval(d time.Duration) JWKSOption {
+	return func(j *JWKS) {
+		j.minRefreshDelay = d
+	}
+}
+
+// NewJWKS returns a JWKS fetching the keys from url, lazily.
+func NewJWKS(url string, opts ...JWKSOption) *JWKS {
+	j := &JWKS{
+		url:             url,
+		client:          &http.Client{Timeout: 10 * time.Second},
+		ttl:             time.Hour,
+		minRefreshDelay: time.Minute,
+	}
+	for _, o := range opts {
+		o(j)
+	}
+	return j
+}
+
+// Key returns the key identified by kid, or the only key of the set when kid
+// is empty.
+func (j *JWKS) Key(ctx context.Context, kid, _ string) (any, error) {
+	j.mu.Lock()
+	key, ok := j.lookup(kid)
+	age := time.Since(j.fetchedAt)
+	var fetch *jwksFetch
+	if (!ok && age >= j.minRefreshDelay) || age >= j.ttl {
+		fetch = j.fetch(ctx)
+	} else if !ok {
+		// The running fetch may add the key
+		fetch = j.fetching
+	}
+	j.mu.Unlock()
+
+	if ok {
+		// Expired keys are served while they are refreshed, and while the
+		// endpoint is failing
+		return key, nil
+	}
+	if fetch != nil {
+		select {
+		case <-fetch.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if fetch.err != nil {
+			return nil, fetch.err
+		}
+		j.mu.Lock()
+		key, ok = j.lookup(kid)
+		j.mu.Unlock()
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+	}
+	return key, nil
+}
+
+// fetch returns the running fetch of the keys, or starts one, detached from
+// the cancellation of ctx. j.mu must be held.
+func (j *JWKS) fetch(ctx context.Context) *jwksFetch {
+	if j.fetching != nil {
+		return j.fetching
+	}
+	// Failures count as fetches, so a failing endpoint isn't hammered
+	j.fetchedAt = time.Now()
+	f := &jwksFetch{done: make(chan struct{})}
+	j.fetching = f
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jwksFetchTimeout)
+		defer cancel()
+		keys, err := j.refresh(ctx)
+
+		j.mu.Lock()
+		if err == nil {
+			j.keys = keys
+		}
+		f.err = err
+		j.fetching = nil
+		j.mu.Unlock()
+		close(f.done)
+	}()
+	return f
+}
+
+func (j *JWKS) lookup(kid string) (any, bool) {
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, true
+		}
+	}
+	key, ok := j.keys[kid]
+	return key, ok
+}
+
+// jwk is a JSON Web Key, see RFC 7517.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// refresh fetches the keys.
+func (j *JWKS) refresh(ctx context.Context) (map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("auth: failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("auth: failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("auth: failed to decode JWKS: %w", err)
+	}
+	keys := make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// Unsupported keys are skipped, tokens signed by them fail with
+		// ErrUnknownKey
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+var curves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+func (k jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, fmt.Errorf("auth: invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("auth: unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("auth: invalid EC point")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("auth: unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
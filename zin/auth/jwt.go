@@ -0,0 +1,296 @@
+// Package auth authenticates zin requests with JWTs, signed with HMAC, RSA or
+// ECDSA keys, which can be fetched from a JWKS endpoint, e.g. of an OIDC
+// provider. Services without OIDC can authenticate each other with API keys
+// or request signatures.
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/divikraf/lumos/zin"
+)
+
+// Error is an authentication failure. Its reason is the normalized error type
+// of metrics.
+type Error struct {
+	reason string
+}
+
+func (e *Error) Error() string {
+	return "auth: " + strings.ReplaceAll(e.reason, "_", " ")
+}
+
+// ErrorType returns the reason of the failure.
+func (e *Error) ErrorType() string {
+	return e.reason
+}
+
+// Authentication failures
+var (
+	ErrMissingToken         = &Error{reason: "missing_token"}
+	ErrMalformedToken       = &Error{reason: "malformed_token"}
+	ErrUnsupportedAlgorithm = &Error{reason: "unsupported_algorithm"}
+	ErrUnknownKey           = &Error{reason: "unknown_key"}
+	ErrInvalidSignature     = &Error{reason: "invalid_signature"}
+	ErrExpired              = &Error{reason: "expired"}
+	ErrNotYetValid          = &Error{reason: "not_yet_valid"}
+	ErrInvalidIssuer        = &Error{reason: "invalid_issuer"}
+	ErrInvalidAudience      = &Error{reason: "invalid_audience"}
+	ErrInvalidAPIKey        = &Error{reason: "invalid_api_key"}
+	ErrInvalidTimestamp     = &Error{reason: "invalid_timestamp"}
+	ErrReplayed             = &Error{reason: "replayed"}
+)
+
+// Config holds the checks of validated tokens.
+type Config struct {
+	// Issuer is the expected iss claim, not checked when empty.
+	Issuer string `json:"issuer" yaml:"issuer"`
+
+	// Audience are the accepted aud claims, tokens must have one of them. Not
+	// checked when empty.
+	Audience []string `json:"audience" yaml:"audience"`
+
+	// ClockSkew is the tolerance of the exp, nbf and iat checks (default:
+	// 1m).
+	ClockSkew time.Duration `json:"clock_skew" yaml:"clock_skew"`
+
+	// Algorithms are the accepted signing algorithms (default: every
+	// supported one, the key type deciding which ones can succeed).
+	Algorithms []string `json:"algorithms" yaml:"algorithms"`
+}
+
+// KeySource returns the key verifying tokens signed with alg by the key
+// identified by kid, which can be empty. HMAC keys are []byte, others are
+// *rsa.PublicKey or *ecdsa.PublicKey.
+type KeySource interface {
+	Key(ctx context.Context, kid, alg string) (any, error)
+}
+
+// KeySourceFunc adapts a function to a KeySource.
+type KeySourceFunc func(ctx context.Context, kid, alg string) (any, error)
+
+func (f KeySourceFunc) Key(ctx context.Context, kid, alg string) (any, error) {
+	return f(ctx, kid, alg)
+}
+
+// HMACKey returns a KeySource verifying tokens with the shared secret.
+func HMACKey(secret []byte) KeySource {
+	return KeySourceFunc(func(context.Context, string, string) (any, error) {
+		return secret, nil
+	})
+}
+
+// PublicKey returns a KeySource verifying tokens with key, an
+// *rsa.PublicKey or an *ecdsa.PublicKey.
+func PublicKey(key crypto.PublicKey) KeySource {
+	return KeySourceFunc(func(context.Context, string, string) (any, error) {
+		return key, nil
+	})
+}
+
+// hashes are the hash functions of the supported algorithms.
+var hashes = map[string]crypto.Hash{
+	"HS256": crypto.SHA256, "HS384": crypto.SHA384, "HS512": crypto.SHA512,
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"PS256": crypto.SHA256, "PS384": crypto.SHA384, "PS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+// Validator validates JWTs.
+type Validator struct {
+	keys   KeySource
+	config Config
+	now    func() time.Time
+}
+
+// NewValidator returns a Validator verifying signatures with the keys of
+// keys.
+func NewValidator(keys KeySource, config Config) *Validator {
+	if config.ClockSkew == 0 {
+		config.ClockSkew = time.Minute
+	}
+	return &Validator{keys: keys, config: config, now: time.Now}
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Validate verifies the signature and the claims of token. Failures are
+// *Error values, possibly wrapped.
+func (v *Validator) Validate(ctx context.Context, token string) (*zin.Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrMalformedToken, err)
+	}
+	hash, ok := hashes[h.Alg]
+	if !ok || (len(v.config.Algorithms) > 0 && !slices.Contains(v.config.Algorithms, h.Alg)) {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, h.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrMalformedToken, err)
+	}
+
+	key, err := v.keys.Key(ctx, h.Kid, h.Alg)
+	if err != nil {
+		return nil, err
+	}
+	if err := verify(h.Alg, hash, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	raw := map[string]any{}
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrMalformedToken, err)
+	}
+	claims, err := parseClaims(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.check(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// verify verifies the signature sig of signed with key.
+func verify(alg string, hash crypto.Hash, key any, signed string, sig []byte) error {
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	var ok bool
+	switch key := key.(type) {
+	case []byte:
+		if alg[:2] != "HS" {
+			return fmt.Errorf("%w: %s with an HMAC key", ErrUnsupportedAlgorithm, alg)
+		}
+		mac := hmac.New(hash.New, key)
+		mac.Write([]byte(signed))
+		ok = hmac.Equal(sig, mac.Sum(nil))
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			ok = rsa.VerifyPKCS1v15(key, hash, digest, sig) == nil
+		case "PS":
+			ok = rsa.VerifyPSS(key, hash, digest, sig, nil) == nil
+		default:
+			return fmt.Errorf("%w: %s with an RSA key", ErrUnsupportedAlgorithm, alg)
+		}
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if alg[:2] != "ES" || len(sig) != 2*size {
+			return fmt.Errorf("%w: %s with an ECDSA key", ErrUnsupportedAlgorithm, alg)
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		ok = ecdsa.Verify(key, digest, r, s)
+	default:
+		return fmt.Errorf("%w: key of type %T", ErrUnknownKey, key)
+	}
+	if !ok {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// check checks the time, issuer and audience claims.
+func (v *Validator) check(c *zin.Claims) error {
+	now := v.now()
+	if !c.ExpiresAt.IsZero() && now.After(c.ExpiresAt.Add(v.config.ClockSkew)) {
+		return ErrExpired
+	}
+	if !c.NotBefore.IsZero() && now.Add(v.config.ClockSkew).Before(c.NotBefore) {
+		return ErrNotYetValid
+	}
+	if !c.IssuedAt.IsZero() && now.Add(v.config.ClockSkew).Before(c.IssuedAt) {
+		return ErrNotYetValid
+	}
+	if v.config.Issuer != "" && c.Issuer != v.config.Issuer {
+		return ErrInvalidIssuer
+	}
+	if len(v.config.Audience) > 0 && !slices.ContainsFunc(v.config.Audience, c.HasAudience) {
+		return ErrInvalidAudience
+	}
+	return nil
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// parseClaims returns the claims of raw, checking the types of the
+// registered ones.
+func parseClaims(raw map[string]any) (*zin.Claims, error) {
+	c := &zin.Claims{Raw: raw}
+	var err error
+	for name, dst := range map[string]*string{"iss": &c.Issuer, "sub": &c.Subject, "jti": &c.ID} {
+		if v, ok := raw[name]; ok {
+			if *dst, ok = v.(string); !ok {
+				return nil, fmt.Errorf("%w: %s is not a string", ErrMalformedToken, name)
+			}
+		}
+	}
+	for name, dst := range map[string]*time.Time{"exp": &c.ExpiresAt, "nbf": &c.NotBefore, "iat": &c.IssuedAt} {
+		if v, ok := raw[name]; ok {
+			if *dst, err = numericDate(v); err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrMalformedToken, name, err)
+			}
+		}
+	}
+	switch aud := raw["aud"].(type) {
+	case nil:
+	case string:
+		c.Audience = []string{aud}
+	case []any:
+		for _, a := range aud {
+			s, ok := a.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: aud is not a string array", ErrMalformedToken)
+			}
+			c.Audience = append(c.Audience, s)
+		}
+	default:
+		return nil, fmt.Errorf("%w: aud is not a string or an array", ErrMalformedToken)
+	}
+	return c, nil
+}
+
+// numericDate converts a JWT NumericDate, seconds since the epoch, possibly
+// fractional.
+func numericDate(v any) (time.Time, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, errors.New("not a number")
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, int64(f*float64(time.Second))), nil
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// sign returns a token of claims signed with key using alg.
+func sign(t *testing.T, alg, kid string, key any, claims map[string]any) string {
+	t.Helper()
+	h, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	c, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	var err error
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key, digest[:])
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestValidator(t *testing.T) {
+	secret := []byte("s3cret")
+	now := time.Now()
+	valid := map[string]any{
+		"iss":  "https://issuer.example",
+		"sub":  "user-1",
+		"aud":  []string{"orders", "billing"},
+		"exp":  now.Add(time.Hour).Unix(),
+		"iat":  now.Unix(),
+		"tier": "gold",
+	}
+	with := func(name string, value any) map[string]any {
+		c := map[string]any{}
+		for k, v := range valid {
+			c[k] = v
+		}
+		c[name] = value
+		return c
+	}
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	v := NewValidator(HMACKey(secret), Config{Issuer: "https://issuer.example", Audience: []string{"orders"}, ClockSkew: 30 * time.Second})
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{name: "valid", token: sign(t, "HS256", "", secret, valid)},
+		{name: "single audience", token: sign(t, "HS256", "", secret, with("aud", "orders"))},
+		{name: "expired within skew", token: sign(t, "HS256", "", secret, with("exp", now.Add(-10*time.Second).Unix()))},
+		{name: "expired", token: sign(t, "HS256", "", secret, with("exp", now.Add(-time.Minute).Unix())), err: ErrExpired},
+		{name: "not yet valid", token: sign(t, "HS256", "", secret, with("nbf", now.Add(time.Minute).Unix())), err: ErrNotYetValid},
+		{name: "wrong issuer", token: sign(t, "HS256", "", secret, with("iss", "https://evil.example")), err: ErrInvalidIssuer},
+		{name: "wrong audience", token: sign(t, "HS256", "", secret, with("aud", "billing")), err: ErrInvalidAudience},
+		{name: "wrong secret", token: sign(t, "HS256", "", []byte("other"), valid), err: ErrInvalidSignature},
+		{name: "RSA token with an HMAC key", token: sign(t, "RS256", "", rsaKey, valid), err: ErrUnsupportedAlgorithm},
+		{name: "none algorithm", token: sign(t, "none", "", secret, valid), err: ErrUnsupportedAlgorithm},
+		{name: "malformed", token: "not.a-token", err: ErrMalformedToken},
+		{name: "malformed claim", token: sign(t, "HS256", "", secret, with("sub", 42)), err: ErrMalformedToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.Validate(context.Background(), tt.token)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Validate() error = %v, want %v", err, tt.err)
+			}
+			if err == nil && (claims.Subject != "user-1" || claims.String("tier") != "gold") {
+				t.Errorf("claims = %+v", claims)
+			}
+			if err != nil && claims != nil {
+				t.Errorf("claims = %+v along with error %v", claims, err)
+			}
+		})
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecKey.X.Bytes()), "y": b64(ecKey.Y.Bytes())},
+			{"kty": "RSA", "kid": "enc", "use": "enc", "n": b64(rsaKey.N.Bytes()), "e": "AQAB"},
+		}})
+	}))
+	defer srv.Close()
+
+	v := NewValidator(NewJWKS(srv.URL), Config{})
+	claims := map[string]any{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()}
+	for _, token := range []string{sign(t, "RS256", "rsa", rsaKey, claims), sign(t, "ES256", "ec", ecKey, claims)} {
+		if _, err := v.Validate(context.Background(), token); err != nil {
+			t.Errorf("Validate() error = %v", err)
+		}
+	}
+	for _, kid := range []string{"enc", "unknown"} {
+		if _, err := v.Validate(context.Background(), sign(t, "RS256", kid, rsaKey, claims)); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("Validate() with key %q error = %v, want ErrUnknownKey", kid, err)
+		}
+	}
+	// Unknown keys don't refetch within the minimum refresh interval
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", got)
+	}
+}
+
+func TestJWKSRefresh(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecKey.X.Bytes()), "y": b64(ecKey.Y.Bytes())},
+		}})
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	jwks := NewJWKS(srv.URL, WithCacheTTL(time.Millisecond), WithMinRefreshInterval(time.Millisecond))
+	ctx := context.Background()
+	if _, err := jwks.Key(ctx, "ec", "ES256"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// The expired key is served while the slow refresh runs
+	start := time.Now()
+	for range 3 {
+		if _, err := jwks.Key(ctx, "ec", "ES256"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Key() waited %s for the refresh", elapsed)
+	}
+
+	// Unknown keys wait for the running refresh, until their context is done
+	timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := jwks.Key(timeoutCtx, "rotated", "ES256"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Key() of an unknown key = %v, want context.DeadlineExceeded", err)
+	}
+	for deadline := time.Now().Add(time.Second); fetches.Load() < 2 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", got)
+	}
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/divikraf/lumos/zin"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/divikraf/lumos/zin/auth"
+
+var scope = revelio.Library(instrumentationName)
+
+// middlewareConfig holds the options of Middleware.
+type middlewareConfig struct {
+	optional bool
+	extract  func(c *gin.Context) string
+}
+
+// MiddlewareOption configures Middleware.
+type MiddlewareOption func(*middlewareConfig)
+
+// Optional lets requests without a token through, unauthenticated. Requests
+// with an invalid token are still rejected.
+func Optional() MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.optional = true
+	}
+}
+
+// WithTokenExtractor sets how tokens are read from requests (default: the
+// bearer token of the Authorization header).
+func WithTokenExtractor(extract func(c *gin.Context) string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.extract = extract
+	}
+}
+
+// BearerToken returns the bearer token of the Authorization header.
+func BearerToken(c *gin.Context) string {
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// Middleware authenticates requests with v, rejecting them with
+// zin.ErrUnauthorized when the token is missing or invalid. The claims of
+// authenticated requests are available with zin.ClaimsFromContext, and their
+// subject is set as the enduser.id span attribute. Failures are counted by
+// reason in http_auth_failures_total.
+func Middleware(v *Validator, opts ...MiddlewareOption) gin.HandlerFunc {
+	config := middlewareConfig{extract: BearerToken}
+	for _, o := range opts {
+		o(&config)
+	}
+	failures := newFailureCounter("jwt")
+
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		token := config.extract(c)
+		if token == "" && config.optional {
+			c.Next()
+			return
+		}
+
+		var err error
+		var claims *zin.Claims
+		if token == "" {
+			err = ErrMissingToken
+		} else {
+			claims, err = v.Validate(ctx, token)
+		}
+		if err != nil {
+			failures.reject(c, err)
+			return
+		}
+		authenticated(c, claims)
+	}
+}
+
+// failureCounter counts the authentication failures of a scheme.
+type failureCounter struct {
+	counter metric.Int64Counter
+	scheme  attribute.KeyValue
+}
+
+func newFailureCounter(scheme string) failureCounter {
+	return failureCounter{
+		counter: revelio.Must(scope.Int64Counter(
+			"http_auth_failures_total",
+			"Number of rejected HTTP authentications, by scheme and reason",
+		)),
+		scheme: attribute.String("scheme", scheme),
+	}
+}
+
+// reject aborts the request with zin.ErrUnauthorized when err is an *Error,
+// and as an internal error otherwise, e.g. when keys can't be fetched.
+func (f failureCounter) reject(c *gin.Context, err error) {
+	reason := "unknown"
+	var authErr *Error
+	if errors.As(err, &authErr) {
+		reason = authErr.reason
+	}
+	f.counter.Add(c.Request.Context(), 1, metric.WithAttributes(f.scheme, attribute.String("reason", reason)))
+	trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("auth.failure_reason", reason))
+	if authErr == nil {
+		zin.AbortWithError(c, err)
+		return
+	}
+	zin.AbortWithError(c, zin.ErrUnauthorized.Wrap(err))
+}
+
+// authenticated adds claims to the request context and continues.
+func authenticated(c *gin.Context, claims *zin.Claims) {
+	if claims.Subject != "" {
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("enduser.id", claims.Subject))
+	}
+	c.Request = c.Request.WithContext(zin.WithClaims(c.Request.Context(), claims))
+	c.Next()
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zin"
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := reveliotest.NewDefaultTestScope(t)
+	secret := []byte("s3cret")
+	v := NewValidator(HMACKey(secret), Config{})
+
+	handler := func(c *gin.Context) {
+		claims, ok := zin.ClaimsFromContext(c.Request.Context())
+		if !ok {
+			c.String(http.StatusOK, "anonymous")
+			return
+		}
+		c.String(http.StatusOK, claims.Subject)
+	}
+	r := gin.New()
+	r.GET("/required", Middleware(v), handler)
+	r.GET("/optional", Middleware(v, Optional()), handler)
+
+	valid := sign(t, "HS256", "", secret, map[string]any{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()})
+	expired := sign(t, "HS256", "", secret, map[string]any{"sub": "user-1", "exp": time.Now().Add(-time.Hour).Unix()})
+	tests := []struct {
+		path   string
+		auth   string
+		status int
+		body   string
+	}{
+		{path: "/required", auth: "Bearer " + valid, status: http.StatusOK, body: "user-1"},
+		{path: "/required", auth: "bearer " + valid, status: http.StatusOK, body: "user-1"},
+		{path: "/required", status: http.StatusUnauthorized},
+		{path: "/required", auth: "Basic dXNlcjpwYXNz", status: http.StatusUnauthorized},
+		{path: "/required", auth: "Bearer " + expired, status: http.StatusUnauthorized},
+		{path: "/optional", status: http.StatusOK, body: "anonymous"},
+		{path: "/optional", auth: "Bearer " + expired, status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.status || (tt.body != "" && w.Body.String() != tt.body) {
+			t.Errorf("%s %q: got %d %q, want %d %q", tt.path, tt.auth, w.Code, w.Body.String(), tt.status, tt.body)
+		}
+	}
+
+	reveliotest.AssertCounterValue(t, s, "http_auth_failures_total", 2, attribute.String("reason", "missing_token"))
+	reveliotest.AssertCounterValue(t, s, "http_auth_failures_total", 2, attribute.String("reason", "expired"))
+}
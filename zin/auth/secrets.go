@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SecretStore returns the secrets of the clients authenticated with API keys
+// and request signatures.
+type SecretStore interface {
+	// Secret returns the secret of the client clientID, or an error wrapping
+	// ErrUnknownKey.
+	Secret(ctx context.Context, clientID string) ([]byte, error)
+}
+
+// SecretStoreFunc adapts a function to a SecretStore.
+type SecretStoreFunc func(ctx context.Context, clientID string) ([]byte, error)
+
+func (f SecretStoreFunc) Secret(ctx context.Context, clientID string) ([]byte, error) {
+	return f(ctx, clientID)
+}
+
+// StaticSecrets returns a SecretStore of the secrets by client ID, typically
+// from the config.
+func StaticSecrets(secrets map[string]string) SecretStore {
+	return SecretStoreFunc(func(_ context.Context, clientID string) ([]byte, error) {
+		secret, ok := secrets[clientID]
+		if !ok || secret == "" {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownKey, clientID)
+		}
+		return []byte(secret), nil
+	})
+}
+
+// RedisSecrets returns a SecretStore reading the secret of a client from the
+// Redis string at prefix + client ID.
+func RedisSecrets(client redis.Cmdable, prefix string) SecretStore {
+	return SecretStoreFunc(func(ctx context.Context, clientID string) ([]byte, error) {
+		secret, err := client.Get(ctx, prefix+clientID).Bytes()
+		if errors.Is(err, redis.Nil) {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownKey, clientID)
+		}
+		return secret, err
+	})
+}
+
+// ReplayCache remembers the signatures already used.
+type ReplayCache interface {
+	// Seen records key for ttl, and reports whether it was already recorded.
+	Seen(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// memoryReplayCache is a ReplayCache of a single instance.
+type memoryReplayCache struct {
+	mu      sync.Mutex
+	keys    map[string]time.Time
+	evicted time.Time
+}
+
+// NewMemoryReplayCache returns a ReplayCache kept in memory, which only
+// protects from replays against the same instance.
+func NewMemoryReplayCache() ReplayCache {
+	return &memoryReplayCache{keys: map[string]time.Time{}}
+}
+
+func (c *memoryReplayCache) Seen(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.evicted) > ttl {
+		for k, expires := range c.keys {
+			if now.After(expires) {
+				delete(c.keys, k)
+			}
+		}
+		c.evicted = now
+	}
+	if expires, ok := c.keys[key]; ok && now.Before(expires) {
+		return true, nil
+	}
+	c.keys[key] = now.Add(ttl)
+	return false, nil
+}
+
+// redisReplayCache is a ReplayCache shared by every instance.
+type redisReplayCache struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewRedisReplayCache returns a ReplayCache storing the signatures in Redis,
+// at prefix + signature, shared by every instance.
+func NewRedisReplayCache(client redis.Cmdable, prefix string) ReplayCache {
+	return &redisReplayCache{client: client, prefix: prefix}
+}
+
+func (c *redisReplayCache) Seen(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	set, err := c.client.SetNX(ctx, c.prefix+key, 1, ttl).Result()
+	if err != nil {
+		return false, err
+	}
+	return !set, nil
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSignature(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secret := []byte("s3cret")
+	r := gin.New()
+	r.POST("/orders", Signature(StaticSecrets(map[string]string{"billing": string(secret)}), NewMemoryReplayCache(), SignatureConfig{}), func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+
+	newRequest := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/orders?dry_run=1", strings.NewReader(body))
+		if err := SignRequest(req, "billing", secret); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		w := serve(newRequest(`{"id":1}`))
+		if w.Code != http.StatusOK || w.Body.String() != `{"id":1}` {
+			t.Errorf("got %d %q", w.Code, w.Body.String())
+		}
+	})
+	t.Run("replayed", func(t *testing.T) {
+		req := newRequest(`{"id":2}`)
+		replay := req.Clone(req.Context())
+		replay.Body = io.NopCloser(strings.NewReader(`{"id":2}`))
+		serve(req)
+		if w := serve(replay); w.Code != http.StatusUnauthorized {
+			t.Errorf("replay status = %d, want 401", w.Code)
+		}
+	})
+	t.Run("replayed with a re-cased signature", func(t *testing.T) {
+		req := newRequest(`{"id":6}`)
+		replay := req.Clone(req.Context())
+		replay.Body = io.NopCloser(strings.NewReader(`{"id":6}`))
+		replay.Header.Set(SignatureHeader, strings.ToUpper(req.Header.Get(SignatureHeader)))
+		if w := serve(req); w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", w.Code)
+		}
+		if w := serve(replay); w.Code != http.StatusUnauthorized {
+			t.Errorf("replay status = %d, want 401", w.Code)
+		}
+	})
+	t.Run("tampered body", func(t *testing.T) {
+		req := newRequest(`{"id":3}`)
+		req.Body = io.NopCloser(strings.NewReader(`{"id":4}`))
+		if w := serve(req); w.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401", w.Code)
+		}
+	})
+	t.Run("tampered query", func(t *testing.T) {
+		req := newRequest(`{"id":5}`)
+		req.URL.RawQuery = "dry_run=0"
+		req.RequestURI = req.URL.RequestURI()
+		if w := serve(req); w.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401", w.Code)
+		}
+	})
+	t.Run("stale timestamp", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+		ts := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+		req.Header.Set(ClientIDHeader, "billing")
+		req.Header.Set(TimestampHeader, ts)
+		req.Header.Set(SignatureHeader, "00")
+		if w := serve(req); w.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401", w.Code)
+		}
+	})
+}
+
+func TestMemoryReplayCache(t *testing.T) {
+	c := NewMemoryReplayCache()
+	for i, want := range []bool{false, true} {
+		if seen, _ := c.Seen(context.Background(), "sig", time.Minute); seen != want {
+			t.Errorf("Seen() #%d = %v, want %v", i, seen, want)
+		}
+	}
+	if seen, _ := c.Seen(context.Background(), "expiring", time.Nanosecond); seen {
+		t.Error("Seen() of a new key = true")
+	}
+	time.Sleep(time.Millisecond)
+	if seen, _ := c.Seen(context.Background(), "expiring", time.Minute); seen {
+		t.Error("Seen() of an expired key = true")
+	}
+}
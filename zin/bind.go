@@ -0,0 +1,52 @@
+package zin
+
+import (
+	"net/http"
+
+	"github.com/divikraf/lumos/zivalidator"
+	"github.com/gin-gonic/gin"
+)
+
+// BindAndValidate binds the uri params, the query and the JSON body of the
+// request into a T, using the uri, form and json tags, then validates it with
+// v in the request language, see i18n.LanguageMiddleware. Fields are
+// validated with the validate tags only, binding tags are not supported.
+//
+// On failure, the request is aborted with an error envelope and false is
+// returned: ErrBadRequest when the request is malformed, and
+// ErrUnprocessableEntity detailing the zivalidator.FieldErrors when T is
+// invalid, with the language of their messages as Content-Language.
+func BindAndValidate[T any](c *gin.Context, v zivalidator.Validate) (T, bool) {
+	var t T
+	if err := bind(c, &t); err != nil {
+		AbortWithError(c, ErrBadRequest.Wrap(err))
+		return t, false
+	}
+	if result := v.ValidateStruct(c.Request.Context(), &t); result != nil {
+		if result.Locale != "" {
+			c.Header("Content-Language", result.Locale)
+		}
+		AbortWithError(c, ErrUnprocessableEntity.WithDetails(result.FieldErrors))
+		return t, false
+	}
+	return t, true
+}
+
+func bind(c *gin.Context, obj any) error {
+	if len(c.Params) > 0 {
+		if err := c.ShouldBindUri(obj); err != nil {
+			return err
+		}
+	}
+	if len(c.Request.URL.RawQuery) > 0 {
+		if err := c.ShouldBindQuery(obj); err != nil {
+			return err
+		}
+	}
+	if c.Request.Body != nil && c.Request.Body != http.NoBody && c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package zin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/divikraf/lumos/i18n"
+	"github.com/divikraf/lumos/zivalidator"
+	"github.com/gin-gonic/gin"
+)
+
+type createItemRequest struct {
+	ListID string `uri:"list_id" validate:"required"`
+	DryRun bool   `form:"dry_run"`
+	Name   string `json:"name" validate:"required"`
+	Count  int    `json:"count" validate:"gte=1"`
+}
+
+func TestBindAndValidate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	v := zivalidator.New()
+
+	var got createItemRequest
+	router := gin.New()
+	router.Use(i18n.LanguageMiddleware())
+	router.POST("/lists/:list_id/items", func(c *gin.Context) {
+		req, ok := BindAndValidate[createItemRequest](c, v)
+		if !ok {
+			return
+		}
+		got = req
+		c.Status(http.StatusCreated)
+	})
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		fields []string
+	}{
+		{name: "valid", body: `{"name":"milk","count":2}`, status: http.StatusCreated},
+		{name: "malformed", body: `{"name":`, status: http.StatusBadRequest},
+		{name: "invalid", body: `{"count":0}`, status: http.StatusUnprocessableEntity, fields: []string{"Name", "Count"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/lists/groceries/items?dry_run=true", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept-Language", "en-US")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status == http.StatusCreated {
+				want := createItemRequest{ListID: "groceries", DryRun: true, Name: "milk", Count: 2}
+				if got != want {
+					t.Errorf("bound %+v, want %+v", got, want)
+				}
+				return
+			}
+
+			var body struct {
+				Error struct {
+					Details []zivalidator.FieldError `json:"details"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if tt.status == http.StatusUnprocessableEntity && w.Header().Get("Content-Language") != "en" {
+				t.Errorf("Content-Language = %q, want en", w.Header().Get("Content-Language"))
+			}
+			if len(body.Error.Details) != len(tt.fields) {
+				t.Fatalf("field errors = %+v, want %v", body.Error.Details, tt.fields)
+			}
+			for i, field := range tt.fields {
+				if fe := body.Error.Details[i]; fe.Key != field || !strings.Contains(fe.Msg, field) {
+					t.Errorf("field error %d = %+v, want an English message for %s", i, fe, field)
+				}
+			}
+		})
+	}
+}
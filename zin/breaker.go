@@ -0,0 +1,254 @@
+package zin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/divikraf/lumos/internal/breaker"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Breaker states
+const (
+	BreakerClosed   = breaker.Closed
+	BreakerOpen     = breaker.Open
+	BreakerHalfOpen = breaker.HalfOpen
+)
+
+// BreakerConfig configures a circuit breaker. The breaker opens when, over
+// Window, at least MinRequests requests were made and the rate of failures,
+// server errors and panics, or of slow requests reaches its threshold. Open
+// breakers reject requests with ErrServiceUnavailable for OpenDuration, then
+// let HalfOpenRequests probes through, closing once they all succeed.
+type BreakerConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Window is the duration over which requests are counted (default: 10s).
+	Window time.Duration `json:"window" yaml:"window"`
+	// MinRequests is the minimum number of requests of the window before the
+	// breaker can open (default: 20).
+	MinRequests int `json:"min_requests" yaml:"min_requests"`
+	// ErrorRate is the failure rate opening the breaker (default: 0.5).
+	ErrorRate float64 `json:"error_rate" yaml:"error_rate"`
+	// SlowDuration is the duration above which requests are slow, not
+	// checked when zero.
+	SlowDuration time.Duration `json:"slow_duration" yaml:"slow_duration"`
+	// SlowRate is the slow request rate opening the breaker (default: 0.5).
+	SlowRate float64 `json:"slow_rate" yaml:"slow_rate"`
+	// OpenDuration is how long the breaker stays open (default: 30s).
+	OpenDuration time.Duration `json:"open_duration" yaml:"open_duration"`
+	// HalfOpenRequests is the number of probes of a half-open breaker
+	// (default: 1).
+	HalfOpenRequests int `json:"half_open_requests" yaml:"half_open_requests"`
+}
+
+func (c BreakerConfig) breakerConfig() breaker.Config {
+	return breaker.Config{
+		Window:           c.Window,
+		MinRequests:      c.MinRequests,
+		ErrorRate:        c.ErrorRate,
+		SlowDuration:     c.SlowDuration,
+		SlowRate:         c.SlowRate,
+		OpenDuration:     c.OpenDuration,
+		HalfOpenRequests: c.HalfOpenRequests,
+	}
+}
+
+// RouteResilienceConfig configures the protection of a route.
+type RouteResilienceConfig struct {
+	// MaxConcurrent is the maximum number of requests in flight, further
+	// ones are rejected with ErrServiceUnavailable. Unlimited when zero.
+	MaxConcurrent int           `json:"max_concurrent" yaml:"max_concurrent"`
+	Breaker       BreakerConfig `json:"breaker" yaml:"breaker"`
+}
+
+// ResilienceConfig configures the concurrency limits and circuit breakers of
+// the router routes.
+type ResilienceConfig struct {
+	// Default applies to the routes without their own configuration.
+	Default RouteResilienceConfig `json:"default" yaml:"default"`
+	// Routes are configurations by route, keyed by method and route path,
+	// e.g. "GET /orders/:id". They replace Default.
+	Routes map[string]RouteResilienceConfig `json:"routes" yaml:"routes"`
+}
+
+// resilienceConfig is implemented by configs protecting the router routes,
+// see ResilienceMiddleware.
+type resilienceConfig interface {
+	GetResilience() ResilienceConfig
+}
+
+// shedMetrics counts the requests rejected by the limiters and breakers,
+// and the breaker transitions.
+type shedMetrics struct {
+	rejected    metric.Int64Counter
+	transitions metric.Int64Counter
+}
+
+func newShedMetrics() *shedMetrics {
+	return &shedMetrics{
+		rejected: revelio.Must(scope.Int64Counter(
+			"http_requests_shed_total",
+			"Number of HTTP requests rejected by a concurrency limit or an open circuit breaker, by route and reason",
+		)),
+		transitions: revelio.Must(scope.Int64Counter(
+			"http_breaker_transitions_total",
+			"Number of HTTP circuit breaker state transitions, by route and state",
+		)),
+	}
+}
+
+func (m *shedMetrics) reject(ctx context.Context, route, reason string) {
+	m.rejected.Add(ctx, 1, metric.WithAttributes(attribute.String("http.route", route), attribute.String("reason", reason)))
+}
+
+// ConcurrencyLimit returns a route middleware rejecting requests with
+// ErrServiceUnavailable while max requests are in flight.
+func ConcurrencyLimit(max int) gin.HandlerFunc {
+	l := newLimiter(max, newShedMetrics())
+	return func(c *gin.Context) {
+		l.serve(c, c.FullPath(), c.Next)
+	}
+}
+
+// CircuitBreaker returns a route middleware rejecting requests with
+// ErrServiceUnavailable while its breaker is open. Transitions are counted in
+// http_breaker_transitions_total.
+func CircuitBreaker(config BreakerConfig) gin.HandlerFunc {
+	b := newBreaker(config, newShedMetrics())
+	return func(c *gin.Context) {
+		b.serve(c, c.FullPath(), c.Next)
+	}
+}
+
+// ResilienceMiddleware applies the concurrency limits and circuit breakers
+// of config to every route, each route having its own. Rejected requests are
+// counted by reason in http_requests_shed_total.
+func ResilienceMiddleware(config ResilienceConfig) gin.HandlerFunc {
+	metrics := newShedMetrics()
+	var mu sync.Mutex
+	routes := map[string]*routeGuard{}
+
+	guard := func(key string) *routeGuard {
+		mu.Lock()
+		defer mu.Unlock()
+		g, ok := routes[key]
+		if !ok {
+			rc, ok := config.Routes[key]
+			if !ok {
+				rc = config.Default
+			}
+			g = &routeGuard{}
+			if rc.MaxConcurrent > 0 {
+				g.limiter = newLimiter(rc.MaxConcurrent, metrics)
+			}
+			if rc.Breaker.Enabled {
+				g.breaker = newBreaker(rc.Breaker, metrics)
+			}
+			routes[key] = g
+		}
+		return g
+	}
+
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			// Unmatched requests aren't protected
+			c.Next()
+			return
+		}
+		g := guard(c.Request.Method + " " + route)
+		next := c.Next
+		if g.breaker != nil {
+			inner := next
+			next = func() { g.breaker.serve(c, route, inner) }
+		}
+		if g.limiter != nil {
+			g.limiter.serve(c, route, next)
+			return
+		}
+		next()
+	}
+}
+
+// routeGuard holds the limiter and breaker of a route, either may be nil.
+type routeGuard struct {
+	limiter *limiter
+	breaker *routeBreaker
+}
+
+// limiter limits the number of requests in flight.
+type limiter struct {
+	slots   chan struct{}
+	metrics *shedMetrics
+}
+
+func newLimiter(max int, metrics *shedMetrics) *limiter {
+	return &limiter{slots: make(chan struct{}, max), metrics: metrics}
+}
+
+func (l *limiter) serve(c *gin.Context, route string, next func()) {
+	select {
+	case l.slots <- struct{}{}:
+	default:
+		l.metrics.reject(c.Request.Context(), route, "concurrency_limit")
+		AbortWithError(c, ErrServiceUnavailable)
+		return
+	}
+	defer func() { <-l.slots }()
+	next()
+}
+
+// routeBreaker is a circuit breaker of routes, counting its rejections and
+// transitions by route.
+type routeBreaker struct {
+	*breaker.Breaker
+	metrics *shedMetrics
+}
+
+func newBreaker(config BreakerConfig, metrics *shedMetrics) *routeBreaker {
+	return &routeBreaker{
+		Breaker: breaker.New(config.breakerConfig(), func(ctx context.Context, from, to string) {
+			metrics.transitions.Add(ctx, 1, metric.WithAttributes(
+				attribute.String("http.route", observe.RouteFromContext(ctx)),
+				attribute.String("from", from),
+				attribute.String("to", to),
+			))
+		}),
+		metrics: metrics,
+	}
+}
+
+func (b *routeBreaker) serve(c *gin.Context, route string, next func()) {
+	ctx := observe.WithRoute(c.Request.Context(), route)
+	if !b.Allow(ctx) {
+		b.metrics.reject(ctx, route, "breaker_open")
+		AbortWithError(c, ErrServiceUnavailable)
+		return
+	}
+
+	start := b.Now()
+	failed := true
+	defer func() {
+		// Panics count as failures
+		b.Done(ctx, failed, b.Now().Sub(start))
+	}()
+	next()
+	failed = requestFailed(c)
+}
+
+// requestFailed reports whether the request ended with a server error,
+// written or pending in the context errors.
+func requestFailed(c *gin.Context) bool {
+	if c.Writer.Written() || len(c.Errors) == 0 {
+		return c.Writer.Status() >= http.StatusInternalServerError
+	}
+	var apiErr *APIError
+	return !errors.As(c.Errors.Last().Err, &apiErr) || apiErr.Status >= http.StatusInternalServerError
+}
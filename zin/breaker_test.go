@@ -0,0 +1,119 @@
+package zin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestResilienceMiddlewareBreaker(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := reveliotest.NewDefaultTestScope(t)
+
+	failing := true
+	r := gin.New()
+	r.Use(ErrorMiddleware(), ResilienceMiddleware(ResilienceConfig{
+		Routes: map[string]RouteResilienceConfig{
+			"GET /orders/:id": {Breaker: BreakerConfig{Enabled: true, MinRequests: 4, OpenDuration: 20 * time.Millisecond}},
+		},
+	}))
+	r.GET("/orders/:id", Handle(func(c *gin.Context) error {
+		if failing {
+			return ErrInternal
+		}
+		c.Status(http.StatusNoContent)
+		return nil
+	}))
+	r.GET("/users/:id", Handle(func(c *gin.Context) error { return ErrInternal }))
+
+	do := func(path string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	for i := 0; i < 4; i++ {
+		if code := do("/orders/1"); code != http.StatusInternalServerError {
+			t.Fatalf("request %d = %d, want 500", i, code)
+		}
+	}
+	if code := do("/orders/2"); code != http.StatusServiceUnavailable {
+		t.Fatalf("open breaker = %d, want 503", code)
+	}
+	// Other routes have their own breaker, here none
+	for i := 0; i < 5; i++ {
+		if code := do("/users/1"); code != http.StatusInternalServerError {
+			t.Fatalf("unprotected route = %d, want 500", code)
+		}
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	failing = false
+	if code := do("/orders/1"); code != http.StatusNoContent {
+		t.Fatalf("half-open probe = %d, want 204", code)
+	}
+	if code := do("/orders/1"); code != http.StatusNoContent {
+		t.Fatalf("closed breaker = %d, want 204", code)
+	}
+
+	route := attribute.String("http.route", "/orders/:id")
+	reveliotest.AssertCounterValue(t, s, "http_requests_shed_total", 1, route, attribute.String("reason", "breaker_open"))
+	reveliotest.AssertCounterValue(t, s, "http_breaker_transitions_total", 1, route, attribute.String("to", BreakerOpen))
+	reveliotest.AssertCounterValue(t, s, "http_breaker_transitions_total", 1, route, attribute.String("to", BreakerHalfOpen))
+	reveliotest.AssertCounterValue(t, s, "http_breaker_transitions_total", 1, route, attribute.String("to", BreakerClosed))
+}
+
+func TestBreakerSlowRequests(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newBreaker(BreakerConfig{Enabled: true, MinRequests: 2, SlowDuration: time.Second}, newShedMetrics())
+	b.Now = func() time.Time { return now }
+
+	ctx := context.Background()
+	b.Done(ctx, false, 2*time.Second)
+	if state := b.State(); state != BreakerClosed {
+		t.Fatalf("state = %s before MinRequests", state)
+	}
+	b.Done(ctx, false, 2*time.Second)
+	if state := b.State(); state != BreakerOpen {
+		t.Fatalf("state = %s, want open", state)
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := reveliotest.NewDefaultTestScope(t)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	r := gin.New()
+	r.GET("/slow", ConcurrencyLimit(1), func(c *gin.Context) {
+		close(started)
+		<-release
+		c.Status(http.StatusOK)
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("second request = %d, want 503", w.Code)
+	}
+	close(release)
+	wg.Wait()
+
+	reveliotest.AssertCounterValue(t, s, "http_requests_shed_total", 1, attribute.String("reason", "concurrency_limit"))
+}
@@ -0,0 +1,75 @@
+package zin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Causes of the failed requests, the error_cause label of the HTTP metrics.
+// The client caused ones (validation, auth, not_found, rate_limited and
+// client_error) can be excluded from the error budget of an SLO.
+const (
+	CauseNone            = "none"
+	CauseValidation      = "validation"
+	CauseAuth            = "auth"
+	CauseNotFound        = "not_found"
+	CauseRateLimited     = "rate_limited"
+	CauseClientError     = "client_error"
+	CauseUpstreamTimeout = "upstream_timeout"
+	CauseHandlerError    = "handler_error"
+	CausePanic           = "panic"
+)
+
+// errorCauseKey is the context key of the cause of the error response.
+const errorCauseKey = "zin.error.cause"
+
+// ErrorCause returns the cause of err: the Cause of its APIError, else the
+// cause of its status. Errors other than APIError are handler errors, or
+// upstream timeouts when they wrap context.DeadlineExceeded.
+func ErrorCause(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.Cause != "" {
+			return apiErr.Cause
+		}
+		return statusCause(apiErr.Status)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return CauseUpstreamTimeout
+	}
+	return CauseHandlerError
+}
+
+// statusCause returns the cause of a response of status.
+func statusCause(status int) string {
+	switch {
+	case status < http.StatusBadRequest:
+		return CauseNone
+	case status == http.StatusBadRequest, status == http.StatusUnprocessableEntity:
+		return CauseValidation
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return CauseAuth
+	case status == http.StatusNotFound:
+		return CauseNotFound
+	case status == http.StatusTooManyRequests:
+		return CauseRateLimited
+	case status == http.StatusGatewayTimeout:
+		return CauseUpstreamTimeout
+	case status < http.StatusInternalServerError:
+		return CauseClientError
+	default:
+		return CauseHandlerError
+	}
+}
+
+// responseCause returns the cause of the response of c: the cause of the
+// error rendered, else the cause of its status.
+func responseCause(c *gin.Context) string {
+	if cause := c.GetString(errorCauseKey); cause != "" {
+		return cause
+	}
+	return statusCause(c.Writer.Status())
+}
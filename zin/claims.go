@@ -0,0 +1,47 @@
+package zin
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// Claims are the claims of the token authenticating a request, see the
+// zin/auth package.
+type Claims struct {
+	Issuer    string
+	Subject   string
+	Audience  []string
+	ExpiresAt time.Time
+	NotBefore time.Time
+	IssuedAt  time.Time
+	ID        string
+
+	// Raw holds every claim of the token, custom ones included. Numbers are
+	// json.Number values.
+	Raw map[string]any
+}
+
+// String returns the custom claim named name when it is a string.
+func (c *Claims) String(name string) string {
+	s, _ := c.Raw[name].(string)
+	return s
+}
+
+// HasAudience reports whether aud is one of the audiences of the claims.
+func (c *Claims) HasAudience(aud string) bool {
+	return slices.Contains(c.Audience, aud)
+}
+
+type claimsKey struct{}
+
+// WithClaims returns a copy of ctx carrying claims.
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims of the authenticated request of ctx.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok
+}
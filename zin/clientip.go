@@ -0,0 +1,80 @@
+package zin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zilog"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ClientIPConfig configures the resolution of the client IP.
+type ClientIPConfig struct {
+	// TrustedProxies are the IPs and CIDRs of the proxies in front of the
+	// service, e.g. "10.0.0.0/8". Headers are only read from them, so
+	// clients can't spoof their IP. No proxy is trusted by default.
+	TrustedProxies []string `json:"trusted_proxies" yaml:"trusted_proxies"`
+	// Headers carry the client IP set by the proxies, in order of
+	// preference (default: X-Forwarded-For, X-Real-IP).
+	Headers []string `json:"headers" yaml:"headers"`
+}
+
+// clientIPConfig is implemented by configs customizing the client IP
+// resolution.
+type clientIPConfig interface {
+	GetClientIP() ClientIPConfig
+}
+
+// ConfigureClientIP configures the client IP resolution of router, used by
+// gin.Context.ClientIP and ClientIPMiddleware: X-Forwarded-For is walked
+// from the right, skipping the trusted proxies, so the first untrusted hop
+// is the client.
+func ConfigureClientIP(router *gin.Engine, config ClientIPConfig) error {
+	if err := router.SetTrustedProxies(config.TrustedProxies); err != nil {
+		return fmt.Errorf("zin: invalid trusted proxies: %w", err)
+	}
+	router.ForwardedByClientIP = true
+	router.RemoteIPHeaders = config.Headers
+	if len(router.RemoteIPHeaders) == 0 {
+		router.RemoteIPHeaders = []string{"X-Forwarded-For", "X-Real-IP"}
+	}
+	return nil
+}
+
+// configureClientIP configures router with the client IP config of config,
+// if any.
+func configureClientIP(router *gin.Engine, config ziconf.Config) error {
+	var c ClientIPConfig
+	if cc, ok := config.(clientIPConfig); ok {
+		c = cc.GetClientIP()
+	}
+	return ConfigureClientIP(router, c)
+}
+
+type clientIPKey struct{}
+
+// ClientIP returns the client IP of the request of ctx, resolved by
+// ClientIPMiddleware, e.g. as a rate-limiter key. It is empty outside of a
+// request.
+func ClientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+// ClientIPMiddleware resolves the client IP, see ConfigureClientIP. It is
+// available with ClientIP, and added to the request logger and span.
+//
+// It must run after the tracing middleware and before the logging one.
+func ClientIPMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		ctx := context.WithValue(c.Request.Context(), clientIPKey{}, ip)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("client.address", ip))
+		logger := zilog.FromContext(ctx).With().Str("client_ip", ip).Logger()
+		c.Request = c.Request.WithContext(logger.WithContext(ctx))
+		c.Next()
+	}
+}
@@ -0,0 +1,73 @@
+package zin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestClientIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range []struct {
+		name    string
+		config  ClientIPConfig
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{
+			name:    "no trusted proxy",
+			remote:  "10.0.0.1:1234",
+			headers: map[string]string{"X-Forwarded-For": "1.2.3.4"},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "trusted proxy",
+			config:  ClientIPConfig{TrustedProxies: []string{"10.0.0.0/8"}},
+			remote:  "10.0.0.1:1234",
+			headers: map[string]string{"X-Forwarded-For": "6.6.6.6, 1.2.3.4, 10.0.0.2"},
+			want:    "1.2.3.4",
+		},
+		{
+			name:    "untrusted peer spoofing",
+			config:  ClientIPConfig{TrustedProxies: []string{"10.0.0.0/8"}},
+			remote:  "5.5.5.5:1234",
+			headers: map[string]string{"X-Forwarded-For": "1.2.3.4"},
+			want:    "5.5.5.5",
+		},
+		{
+			name:    "real IP header",
+			config:  ClientIPConfig{TrustedProxies: []string{"10.0.0.1"}},
+			remote:  "10.0.0.1:1234",
+			headers: map[string]string{"X-Real-IP": "1.2.3.4"},
+			want:    "1.2.3.4",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.New()
+			if err := ConfigureClientIP(router, tc.config); err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			router.Use(ClientIPMiddleware())
+			router.GET("/", func(c *gin.Context) { got = ClientIP(c.Request.Context()) })
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remote
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			router.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tc.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	if err := ConfigureClientIP(gin.New(), ClientIPConfig{TrustedProxies: []string{"10.0.0.0/33"}}); err == nil {
+		t.Error("ConfigureClientIP() of an invalid CIDR succeeded")
+	}
+}
@@ -0,0 +1,304 @@
+package zin
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// CompressionConfig holds configuration for the compression middleware.
+type CompressionConfig struct {
+	// Level is the compression level, from gzip.BestSpeed to
+	// gzip.BestCompression (default: gzip.DefaultCompression).
+	Level int
+
+	// MinSize is the minimum response size worth compressing, in bytes
+	// (default: 1024).
+	MinSize int
+
+	// ExcludedContentTypes are content type prefixes never compressed, e.g.
+	// already compressed formats (default: DefaultExcludedContentTypes).
+	ExcludedContentTypes []string
+
+	// ExcludedPaths are request paths never compressed.
+	ExcludedPaths []string
+}
+
+// DefaultExcludedContentTypes are the content types not compressed by
+// default: already compressed formats and event streams.
+var DefaultExcludedContentTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"font/woff",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/zstd",
+	"text/event-stream",
+}
+
+// DefaultCompressionConfig returns the default configuration for response
+// compression.
+func DefaultCompressionConfig() CompressionConfig {
+	return CompressionConfig{
+		Level:                gzip.DefaultCompression,
+		MinSize:              1024,
+		ExcludedContentTypes: DefaultExcludedContentTypes,
+	}
+}
+
+// compressor creates the encoders of a compression level, reusing them.
+type compressor struct {
+	config CompressionConfig
+	gzip   sync.Pool
+	zlib   sync.Pool
+	saved  metric.Int64Counter
+	total  metric.Int64Counter
+}
+
+// CompressionMiddleware compresses responses with gzip or deflate, as accepted
+// by the client. Responses smaller than MinSize, with an excluded content type
+// or an already set Content-Encoding are left untouched. Compressed responses
+// are counted in http_compressed_responses_total, and the bytes saved in
+// http_compression_saved_bytes_total.
+func CompressionMiddleware(config CompressionConfig) gin.HandlerFunc {
+	defaults := DefaultCompressionConfig()
+	if config.Level == 0 || config.Level < gzip.HuffmanOnly || config.Level > gzip.BestCompression {
+		config.Level = defaults.Level
+	}
+	if config.MinSize <= 0 {
+		config.MinSize = defaults.MinSize
+	}
+	if config.ExcludedContentTypes == nil {
+		config.ExcludedContentTypes = defaults.ExcludedContentTypes
+	}
+
+	c := &compressor{
+		config: config,
+		saved: revelio.Must(scope.Int64Counter(
+			"http_compression_saved_bytes_total",
+			"Number of response bytes saved by compression",
+			metric.WithUnit("By"),
+		)),
+		total: revelio.Must(scope.Int64Counter(
+			"http_compressed_responses_total",
+			"Number of compressed HTTP responses",
+		)),
+	}
+	c.gzip.New = func() any {
+		w, _ := gzip.NewWriterLevel(io.Discard, config.Level)
+		return w
+	}
+	c.zlib.New = func() any {
+		w, _ := zlib.NewWriterLevel(io.Discard, config.Level)
+		return w
+	}
+
+	excludedPaths := make(map[string]bool, len(config.ExcludedPaths))
+	for _, path := range config.ExcludedPaths {
+		excludedPaths[path] = true
+	}
+
+	return func(ctx *gin.Context) {
+		r := ctx.Request
+		if excludedPaths[r.URL.Path] || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			ctx.Next()
+			return
+		}
+		encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			ctx.Next()
+			return
+		}
+
+		ctx.Header("Vary", "Accept-Encoding")
+		w := &compressWriter{ResponseWriter: ctx.Writer, compressor: c, encoding: encoding}
+		ctx.Writer = w
+		defer func() {
+			w.finish()
+			ctx.Writer = w.ResponseWriter
+			if w.compressed {
+				attrs := metric.WithAttributes(attribute.String("encoding", encoding))
+				c.total.Add(r.Context(), 1, attrs)
+				if saved := int64(w.raw) - int64(w.ResponseWriter.Size()); saved > 0 {
+					c.saved.Add(r.Context(), saved, attrs)
+				}
+			}
+		}()
+		ctx.Next()
+	}
+}
+
+// acceptedEncoding returns the preferred encoding of an Accept-Encoding
+// header, gzip or deflate, or an empty string when neither is accepted.
+func acceptedEncoding(header string) string {
+	var deflate bool
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				continue
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "gzip", "*":
+			return "gzip"
+		case "deflate":
+			deflate = true
+		}
+	}
+	if deflate {
+		return "deflate"
+	}
+	return ""
+}
+
+// encoder is implemented by *gzip.Writer and *zlib.Writer.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressWriter buffers the beginning of the response until it knows whether
+// it is worth compressing.
+type compressWriter struct {
+	gin.ResponseWriter
+	compressor *compressor
+	encoding   string
+
+	buf        []byte
+	decided    bool
+	compressed bool
+	encoder    encoder
+	raw        int
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	w.raw += len(p)
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < w.compressor.config.MinSize {
+			return len(p), nil
+		}
+		return len(p), w.decide()
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow delays writing the headers until the compression is decided.
+func (w *compressWriter) WriteHeaderNow() {
+	if w.decided {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		_ = w.decide()
+	}
+	if w.encoder != nil {
+		_ = w.encoder.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.decided = true
+	return w.ResponseWriter.Hijack()
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// decide compresses the response from now on when it is worth it, and writes
+// the buffered beginning of the response.
+func (w *compressWriter) decide() error {
+	w.decided = true
+	buf := w.buf
+	w.buf = nil
+
+	if w.shouldCompress(buf) {
+		h := w.Header()
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		w.compressed = true
+		if w.encoding == "gzip" {
+			w.encoder = w.compressor.gzip.Get().(*gzip.Writer)
+		} else {
+			w.encoder = w.compressor.zlib.Get().(*zlib.Writer)
+		}
+		w.encoder.Reset(w.ResponseWriter)
+		_, err := w.encoder.Write(buf)
+		return err
+	}
+
+	if len(buf) == 0 {
+		w.ResponseWriter.WriteHeaderNow()
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+func (w *compressWriter) shouldCompress(buf []byte) bool {
+	if len(buf) < w.compressor.config.MinSize {
+		return false
+	}
+	if status := w.Status(); status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		return false
+	}
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	contentType := h.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(buf)
+	}
+	for _, excluded := range w.compressor.config.ExcludedContentTypes {
+		if strings.HasPrefix(contentType, excluded) {
+			return false
+		}
+	}
+	return true
+}
+
+// finish writes the remaining of the response.
+func (w *compressWriter) finish() {
+	if !w.decided {
+		_ = w.decide()
+	}
+	if w.encoder == nil {
+		return
+	}
+	_ = w.encoder.Close()
+	w.encoder.Reset(io.Discard)
+	if w.encoding == "gzip" {
+		w.compressor.gzip.Put(w.encoder)
+	} else {
+		w.compressor.zlib.Put(w.encoder)
+	}
+	w.encoder = nil
+}
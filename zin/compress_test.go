@@ -0,0 +1,87 @@
+package zin
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCompressionMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := reveliotest.NewDefaultTestScope(t)
+
+	large := strings.Repeat("lumos ", 1000)
+	r := gin.New()
+	r.Use(CompressionMiddleware(CompressionConfig{ExcludedPaths: []string{"/excluded"}}))
+	r.GET("/large", func(c *gin.Context) { c.String(http.StatusOK, large) })
+	r.GET("/small", func(c *gin.Context) { c.String(http.StatusOK, "small") })
+	r.GET("/excluded", func(c *gin.Context) { c.String(http.StatusOK, large) })
+	r.GET("/image", func(c *gin.Context) { c.Data(http.StatusOK, "image/png", []byte(large)) })
+
+	do := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("gzip", func(t *testing.T) {
+		w := do("/large", "br;q=1.0, gzip;q=0.8")
+		if w.Header().Get("Content-Encoding") != "gzip" {
+			t.Fatalf("Content-Encoding = %q, want gzip", w.Header().Get("Content-Encoding"))
+		}
+		zr, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, _ := io.ReadAll(zr); string(body) != large {
+			t.Error("decompressed body does not match")
+		}
+	})
+
+	t.Run("deflate", func(t *testing.T) {
+		w := do("/large", "deflate, gzip;q=0")
+		if w.Header().Get("Content-Encoding") != "deflate" {
+			t.Fatalf("Content-Encoding = %q, want deflate", w.Header().Get("Content-Encoding"))
+		}
+		zr, err := zlib.NewReader(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, _ := io.ReadAll(zr); string(body) != large {
+			t.Error("decompressed body does not match")
+		}
+	})
+
+	for _, tt := range []struct{ name, path, acceptEncoding string }{
+		{name: "not accepted", path: "/large"},
+		{name: "too small", path: "/small", acceptEncoding: "gzip"},
+		{name: "excluded path", path: "/excluded", acceptEncoding: "gzip"},
+		{name: "excluded content type", path: "/image", acceptEncoding: "gzip"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(tt.path, tt.acceptEncoding)
+			if w.Header().Get("Content-Encoding") != "" || w.Code != http.StatusOK {
+				t.Errorf("got %d with Content-Encoding %q, want an uncompressed response", w.Code, w.Header().Get("Content-Encoding"))
+			}
+			if want := map[bool]int{true: 5, false: len(large)}[tt.path == "/small"]; w.Body.Len() != want {
+				t.Errorf("body length = %d, want %d", w.Body.Len(), want)
+			}
+		})
+	}
+
+	reveliotest.AssertCounterValue(t, s, "http_compressed_responses_total", 2)
+	if m, ok := s.Metric(t, "http_compression_saved_bytes_total"); !ok {
+		t.Errorf("http_compression_saved_bytes_total was not recorded: %+v", m)
+	}
+}
@@ -0,0 +1,132 @@
+package zin
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Names of the connection states
+var connStateNames = map[http.ConnState]string{
+	http.StateNew:      "new",
+	http.StateActive:   "active",
+	http.StateIdle:     "idle",
+	http.StateHijacked: "hijacked",
+	http.StateClosed:   "closed",
+}
+
+// connTracker tracks the state of the server connections, and records their
+// lifecycle metrics.
+type connTracker struct {
+	mu       sync.Mutex
+	conns    map[net.Conn]trackedConn
+	states   metric.Int64Counter
+	duration metric.Int64Histogram
+	// server is the attribute of the named servers, see StartServer.
+	server []attribute.KeyValue
+	// attrs are the attributes of the connection states, allocated once.
+	attrs map[http.ConnState]metric.MeasurementOption
+}
+
+type trackedConn struct {
+	state  http.ConnState
+	opened time.Time
+}
+
+// newConnTracker returns a connTracker of the server name, empty for the main
+// server.
+func newConnTracker(name string) *connTracker {
+	t := &connTracker{
+		conns: map[net.Conn]trackedConn{},
+		attrs: make(map[http.ConnState]metric.MeasurementOption, len(connStateNames)),
+		states: revelio.Must(scope.Int64Counter(
+			"http_server_connection_states_total",
+			"Number of HTTP server connection state transitions, by state (new, active, idle, hijacked, closed)",
+		)),
+		duration: revelio.Must(scope.Int64Histogram(
+			"http_server_connection_duration_ms",
+			"Lifetime of HTTP server connections in milliseconds, from accept to close or hijack",
+			metric.WithUnit("ms"),
+		)),
+	}
+	if name != "" {
+		t.server = []attribute.KeyValue{attribute.String("server", name)}
+	}
+	for state, stateName := range connStateNames {
+		t.attrs[state] = t.withState(stateName)
+	}
+	return t
+}
+
+func (t *connTracker) withState(state string) metric.MeasurementOption {
+	return metric.WithAttributes(append([]attribute.KeyValue{attribute.String("state", state)}, t.server...)...)
+}
+
+// track is used as http.Server.ConnState.
+func (t *connTracker) track(conn net.Conn, state http.ConnState) {
+	ctx := context.Background()
+	if attrs, ok := t.attrs[state]; ok {
+		t.states.Add(ctx, 1, attrs)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch state {
+	case http.StateHijacked, http.StateClosed:
+		if c, ok := t.conns[conn]; ok {
+			t.duration.Record(ctx, time.Since(c.opened).Milliseconds(), t.attrs[state])
+			delete(t.conns, conn)
+		}
+	case http.StateNew:
+		t.conns[conn] = trackedConn{state: state, opened: time.Now()}
+	default:
+		c := t.conns[conn]
+		if c.opened.IsZero() {
+			c.opened = time.Now()
+		}
+		c.state = state
+		t.conns[conn] = c
+	}
+}
+
+// count returns the number of active and idle connections.
+func (t *connTracker) count() (active, idle int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, c := range t.conns {
+		if c.state == http.StateIdle {
+			idle++
+		} else {
+			active++
+		}
+	}
+	return active, idle
+}
+
+// observe reports the open connections of t in the http_server_open_connections
+// gauge, until the registration is unregistered.
+func (t *connTracker) observe() (metric.Registration, error) {
+	gauge, err := scope.Int64ObservableGauge(
+		"http_server_open_connections",
+		"Number of open HTTP server connections, by state (active, idle)",
+	)
+	if err != nil {
+		return nil, err
+	}
+	active := t.withState("active")
+	idle := t.withState("idle")
+	return scope.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		a, i := t.count()
+		o.ObserveInt64(gauge, int64(a), active)
+		o.ObserveInt64(gauge, int64(i), idle)
+		return nil
+	}, gauge)
+}
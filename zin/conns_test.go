@@ -0,0 +1,81 @@
+package zin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestConnTrackerMetrics(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	conns := newConnTracker("")
+	reg, err := conns.observe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reg.Unregister()
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Config.ConnState = conns.track
+	srv.Start()
+	defer srv.Close()
+
+	client := srv.Client()
+	for range 2 {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	// The keep-alive connection is reused, and idle once the response is read
+	waitFor(t, func() bool { _, idle := conns.count(); return idle == 1 })
+	reveliotest.AssertCounterValue(t, s, "http_server_connection_states_total", 1, attribute.String("state", "new"))
+	reveliotest.AssertCounterValue(t, s, "http_server_connection_states_total", 2, attribute.String("state", "active"))
+	if got := openConnections(t, s, "idle"); got != 1 {
+		t.Errorf("idle connections = %d, want 1", got)
+	}
+
+	client.CloseIdleConnections()
+	waitFor(t, func() bool { active, idle := conns.count(); return active+idle == 0 })
+	reveliotest.AssertCounterValue(t, s, "http_server_connection_states_total", 1, attribute.String("state", "closed"))
+	if h := reveliotest.CollectHistogram(t, s, "http_server_connection_duration_ms"); h.Count != 1 {
+		t.Errorf("connection durations = %d, want 1", h.Count)
+	}
+	if got := openConnections(t, s, "idle"); got != 0 {
+		t.Errorf("idle connections = %d, want 0", got)
+	}
+}
+
+func openConnections(t *testing.T, s *reveliotest.Scope, state string) int64 {
+	t.Helper()
+	m, ok := s.Metric(t, "http_server_open_connections")
+	if !ok {
+		t.Fatal("http_server_open_connections was not recorded")
+	}
+	for _, dp := range m.Data.(metricdata.Gauge[int64]).DataPoints {
+		if v, _ := dp.Attributes.Value("state"); v.AsString() == state {
+			return dp.Value
+		}
+	}
+	return 0
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,45 @@
+package zin
+
+import (
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// DebugMiddleware handles debug requests, carrying a valid debug token either
+// in the X-Lumos-Debug header or in the baggage propagated by an upstream
+// service: the token is added to the request baggage, so the request is
+// sampled and the token propagated downstream, and the request logger is
+// made verbose.
+//
+// It must run before the tracing middleware, which starts the request span.
+// Invalid tokens are ignored.
+func DebugMiddleware() gin.HandlerFunc {
+	var baggage propagation.Baggage
+	return func(c *gin.Context) {
+		carrier := propagation.HeaderCarrier(c.Request.Header)
+		ctx := baggage.Extract(c.Request.Context(), carrier)
+
+		token := c.GetHeader(observe.DebugHeader)
+		if token == "" || !observe.VerifyDebugToken(token) {
+			if !observe.IsDebug(ctx) {
+				c.Next()
+				return
+			}
+		} else {
+			var err error
+			if ctx, err = observe.ContextWithDebugToken(ctx, token); err != nil {
+				c.Next()
+				return
+			}
+			// The tracing middleware extracts the baggage from the headers
+			baggage.Inject(ctx, carrier)
+		}
+
+		logger := zilog.FromContext(ctx).Level(zerolog.TraceLevel).With().Bool("debug", true).Logger()
+		c.Request = c.Request.WithContext(logger.WithContext(ctx))
+		c.Next()
+	}
+}
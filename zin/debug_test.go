@@ -0,0 +1,65 @@
+package zin
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+func TestDebugMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	observe.SetDebug(observe.DebugConfig{Enabled: true, Secret: "s3cr3t"})
+	defer observe.SetDebug(observe.DebugConfig{})
+
+	var debug bool
+	var header string
+	var logs bytes.Buffer
+	r := gin.New()
+	r.Use(DebugMiddleware())
+	r.GET("/", func(c *gin.Context) {
+		debug = observe.IsDebug(c.Request.Context())
+		header = c.GetHeader("Baggage")
+		zilog.FromContext(c.Request.Context()).Trace().Msg("verbose")
+	})
+
+	do := func(headers map[string]string) {
+		logs.Reset()
+		logger := zerolog.New(&logs).Level(zerolog.InfoLevel)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req = req.WithContext(logger.WithContext(req.Context()))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	token := observe.IssueDebugToken("s3cr3t", time.Minute)
+
+	do(map[string]string{observe.DebugHeader: token, "Baggage": "tenant=acme"})
+	if !debug || !strings.Contains(logs.String(), `"debug":true`) {
+		t.Errorf("debug = %v, logs = %q, want a verbose debug request", debug, logs.String())
+	}
+	b, err := baggage.Parse(header)
+	if err != nil || b.Member("tenant").Value() != "acme" || b.Member(observe.DebugBaggageKey).Value() != token {
+		t.Errorf("baggage header = %q, want the token added to the existing members", header)
+	}
+
+	// Downstream services receive the token through baggage
+	do(map[string]string{"Baggage": observe.DebugBaggageKey + "=" + token})
+	if !debug || !strings.Contains(logs.String(), `"debug":true`) {
+		t.Errorf("debug = %v, logs = %q, want a verbose debug request", debug, logs.String())
+	}
+
+	do(map[string]string{observe.DebugHeader: observe.IssueDebugToken("guess", time.Minute)})
+	if debug || logs.Len() > 0 {
+		t.Error("invalid tokens should be ignored")
+	}
+}
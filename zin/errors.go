@@ -0,0 +1,166 @@
+package zin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/divikraf/lumos/i18n"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// APIError is an error returned to API clients. Its message is localized from
+// MessageKey, see i18n.AddMessages, falling back to Message.
+type APIError struct {
+	// Code is a stable, machine readable error code, e.g. "order_not_found".
+	Code string
+	// Status is the HTTP status of the response.
+	Status int
+	// MessageKey is the i18n key of the message.
+	MessageKey string
+	// Message is used when MessageKey has no message in the request language.
+	Message string
+	// Details are optional data helping clients handling the error.
+	Details any
+	// Cause is the cause recorded in the HTTP metrics, derived from Status
+	// when empty, see ErrorCause.
+	Cause string
+
+	err error
+}
+
+// Common API errors, to be customized with WithDetails and Wrap.
+var (
+	ErrBadRequest          = NewAPIError(http.StatusBadRequest, "bad_request", "error.bad_request", "Bad request")
+	ErrUnauthorized        = NewAPIError(http.StatusUnauthorized, "unauthorized", "error.unauthorized", "Unauthorized")
+	ErrForbidden           = NewAPIError(http.StatusForbidden, "forbidden", "error.forbidden", "Forbidden")
+	ErrNotFound            = NewAPIError(http.StatusNotFound, "not_found", "error.not_found", "Not found")
+	ErrConflict            = NewAPIError(http.StatusConflict, "conflict", "error.conflict", "Conflict")
+	ErrUnprocessableEntity = NewAPIError(http.StatusUnprocessableEntity, "unprocessable_entity", "error.unprocessable_entity", "Unprocessable entity")
+	ErrTooManyRequests     = NewAPIError(http.StatusTooManyRequests, "too_many_requests", "error.too_many_requests", "Too many requests")
+	ErrInternal            = NewAPIError(http.StatusInternalServerError, "internal_error", "error.internal", "Internal server error")
+	ErrServiceUnavailable  = NewAPIError(http.StatusServiceUnavailable, "service_unavailable", "error.service_unavailable", "Service unavailable")
+	ErrGatewayTimeout      = NewAPIError(http.StatusGatewayTimeout, "gateway_timeout", "error.gateway_timeout", "Gateway timeout")
+)
+
+// NewAPIError returns an APIError.
+func NewAPIError(status int, code, messageKey, message string) *APIError {
+	return &APIError{Code: code, Status: status, MessageKey: messageKey, Message: message}
+}
+
+func (e *APIError) Error() string {
+	if e.err != nil {
+		return e.Code + ": " + e.err.Error()
+	}
+	return e.Code
+}
+
+// Unwrap returns the error wrapped with Wrap.
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether target is an APIError with the same code, so that
+// errors.Is(err, zin.ErrNotFound) holds for customized copies.
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	return ok && t.Code == e.Code
+}
+
+// ErrorType returns the code, as the normalized error type of metrics.
+func (e *APIError) ErrorType() string {
+	return e.Code
+}
+
+// WithDetails returns a copy of e with details.
+func (e *APIError) WithDetails(details any) *APIError {
+	c := *e
+	c.Details = details
+	return &c
+}
+
+// WithCause returns a copy of e recorded with cause in the HTTP metrics, e.g.
+// CauseUpstreamTimeout for a 502 of a timed out dependency.
+func (e *APIError) WithCause(cause string) *APIError {
+	c := *e
+	c.Cause = cause
+	return &c
+}
+
+// Wrap returns a copy of e wrapping the internal error err, which is recorded
+// on the span but never exposed to clients.
+func (e *APIError) Wrap(err error) *APIError {
+	c := *e
+	c.err = err
+	return &c
+}
+
+// ErrorBody is the error of a response envelope.
+type ErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+}
+
+// Body returns the localized error body of e in the language of c.
+func (e *APIError) Body(c *gin.Context) ErrorBody {
+	message, ok := i18n.Lookup(c.Request.Context(), e.MessageKey)
+	if !ok {
+		message = e.Message
+	}
+	if message == "" {
+		message = http.StatusText(e.Status)
+	}
+	return ErrorBody{Code: e.Code, Message: message, Details: e.Details}
+}
+
+// Handle adapts a handler returning an error to gin, errors are added to the
+// context and rendered by ErrorMiddleware.
+func Handle(h func(c *gin.Context) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := h(c); err != nil {
+			_ = c.Error(err)
+		}
+	}
+}
+
+// AbortWithError aborts the request and renders err as an error envelope.
+func AbortWithError(c *gin.Context, err error) {
+	c.Abort()
+	writeError(c, err)
+}
+
+// ErrorMiddleware renders the last error added to the context with c.Error,
+// unless the response is already written. Errors other than APIError are
+// rendered as ErrInternal. Server errors are recorded on the span.
+func ErrorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		writeError(c, c.Errors.Last().Err)
+	}
+}
+
+// writeError writes the error envelope of err, and keeps its cause for the
+// HTTP metrics.
+func writeError(c *gin.Context, err error) {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		apiErr = ErrInternal.Wrap(err)
+	}
+	c.Set(errorCauseKey, ErrorCause(err))
+
+	span := trace.SpanFromContext(c.Request.Context())
+	span.SetAttributes(attribute.String("error.type", apiErr.Code))
+	if apiErr.Status >= http.StatusInternalServerError {
+		observe.RecordError(span, err)
+	}
+
+	body := apiErr.Body(c)
+	c.JSON(apiErr.Status, Envelope{Meta: Meta{RequestID: RequestIDFromContext(c.Request.Context())}, Error: &body})
+}
@@ -0,0 +1,82 @@
+package zin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/divikraf/lumos/i18n"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+)
+
+func TestErrorMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	i18n.AddMessages(language.English, map[string]string{"error.order_not_found": "Order not found"})
+	errOrderNotFound := NewAPIError(http.StatusNotFound, "order_not_found", "error.order_not_found", "")
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(i18n.WithContext(c.Request.Context(), language.AmericanEnglish))
+	}, ErrorMiddleware())
+	router.GET("/order", Handle(func(c *gin.Context) error {
+		return errOrderNotFound.WithDetails(map[string]string{"id": "42"})
+	}))
+	router.GET("/internal", Handle(func(c *gin.Context) error {
+		return errors.New("connection refused")
+	}))
+	router.GET("/written", func(c *gin.Context) {
+		c.String(http.StatusAccepted, "ok")
+		_ = c.Error(ErrConflict)
+	})
+
+	tests := []struct {
+		path   string
+		status int
+		want   ErrorBody
+	}{
+		{path: "/order", status: http.StatusNotFound, want: ErrorBody{Code: "order_not_found", Message: "Order not found", Details: map[string]any{"id": "42"}}},
+		{path: "/internal", status: http.StatusInternalServerError, want: ErrorBody{Code: "internal_error", Message: "Internal server error"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+
+			var body struct {
+				Error ErrorBody `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			got, _ := json.Marshal(body.Error)
+			want, _ := json.Marshal(tt.want)
+			if string(got) != string(want) {
+				t.Errorf("error = %s, want %s", got, want)
+			}
+		})
+	}
+
+	t.Run("written response", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/written", nil))
+		if w.Code != http.StatusAccepted || w.Body.String() != "ok" {
+			t.Errorf("got %d %q, want the handler response", w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestAPIErrorIs(t *testing.T) {
+	err := ErrNotFound.WithDetails("order").Wrap(errors.New("no rows"))
+	if !errors.Is(err, ErrNotFound) || errors.Is(err, ErrConflict) {
+		t.Errorf("errors.Is mismatch for %v", err)
+	}
+	if err.Error() != "not_found: no rows" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+}
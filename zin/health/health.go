@@ -0,0 +1,198 @@
+// Package health provides liveness and readiness endpoints aggregating named
+// health checkers.
+package health
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/divikraf/lumos/zihealth"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	LivePath  = "/health/live"
+	ReadyPath = "/health/ready"
+
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusDraining = "draining"
+)
+
+// Checker checks the health of a dependency, see zihealth.Checker.
+type Checker = zihealth.Checker
+
+// NewChecker returns a Checker named name calling check, see
+// zihealth.NewChecker.
+func NewChecker(name string, check func(ctx context.Context) error) Checker {
+	return zihealth.NewChecker(name, check)
+}
+
+// Config configures the health checks.
+type Config struct {
+	// Timeout is the maximum duration of a single check, 2s by default.
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+	// CacheTTL is how long check results are reused, so frequent probes do
+	// not hammer the dependencies, 1s by default. Zero uses the default,
+	// negative disables caching.
+	CacheTTL time.Duration `json:"cache_ttl" yaml:"cache_ttl"`
+}
+
+// DefaultConfig returns the default health check configuration.
+func DefaultConfig() Config {
+	return Config{
+		Timeout:  2 * time.Second,
+		CacheTTL: time.Second,
+	}
+}
+
+// CheckResult is the result of a single check.
+type CheckResult struct {
+	Status   string        `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Report is the aggregated result of every check.
+type Report struct {
+	Status    string                 `json:"status"`
+	Checks    map[string]CheckResult `json:"checks,omitempty"`
+	CheckedAt time.Time              `json:"checked_at"`
+}
+
+// Health aggregates health checkers.
+type Health struct {
+	config   Config
+	checkers []Checker
+
+	mu     sync.Mutex
+	report *Report
+
+	draining atomic.Bool
+}
+
+// New returns a Health running checkers.
+func New(config Config, checkers ...Checker) *Health {
+	defaults := DefaultConfig()
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.CacheTTL == 0 {
+		config.CacheTTL = defaults.CacheTTL
+	}
+
+	checkers = append([]Checker(nil), checkers...)
+	sort.Slice(checkers, func(i, j int) bool { return checkers[i].Name() < checkers[j].Name() })
+	return &Health{
+		config:   config,
+		checkers: checkers,
+	}
+}
+
+// Check runs every checker concurrently, or returns the cached report when it
+// is still fresh.
+func (h *Health) Check(ctx context.Context) Report {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.report != nil && h.config.CacheTTL > 0 && time.Since(h.report.CheckedAt) < h.config.CacheTTL {
+		return *h.report
+	}
+
+	report := h.run(ctx)
+	h.report = &report
+	return report
+}
+
+func (h *Health) run(ctx context.Context) Report {
+	report := Report{
+		Status:    StatusUp,
+		Checks:    make(map[string]CheckResult, len(h.checkers)),
+		CheckedAt: time.Now(),
+	}
+
+	results := make([]CheckResult, len(h.checkers))
+	var wg sync.WaitGroup
+	for i, c := range h.checkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = h.runOne(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	for i, c := range h.checkers {
+		report.Checks[c.Name()] = results[i]
+		if results[i].Status == StatusDown {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+func (h *Health) runOne(ctx context.Context, c Checker) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, h.config.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// Don't wait for checkers ignoring the context
+		err = ctx.Err()
+	}
+
+	res := CheckResult{Status: StatusUp, Duration: time.Since(start)}
+	if err != nil {
+		res.Status = StatusDown
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// Drain makes the readiness endpoint fail from now on, so load balancers stop
+// routing traffic to the process before it shuts down. Liveness is unaffected.
+func (h *Health) Drain() {
+	h.draining.Store(true)
+}
+
+// Draining reports whether Drain was called.
+func (h *Health) Draining() bool {
+	return h.draining.Load()
+}
+
+// RegisterRoutes registers the liveness and readiness endpoints on r.
+func (h *Health) RegisterRoutes(r gin.IRouter) {
+	r.GET(LivePath, h.live)
+	r.GET(ReadyPath, h.ready)
+}
+
+// live only reports the process is serving requests, dependencies are not
+// checked so an unhealthy dependency does not get the process restarted.
+func (h *Health) live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": StatusUp})
+}
+
+func (h *Health) ready(c *gin.Context) {
+	if h.Draining() {
+		c.JSON(http.StatusServiceUnavailable, Report{Status: StatusDraining, CheckedAt: time.Now()})
+		return
+	}
+	report := h.Check(c.Request.Context())
+	status := http.StatusOK
+	if report.Status != StatusUp {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
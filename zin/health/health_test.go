@@ -0,0 +1,107 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHealthCheck(t *testing.T) {
+	var calls atomic.Int32
+	h := New(Config{Timeout: 50 * time.Millisecond, CacheTTL: time.Hour},
+		NewChecker("db", func(context.Context) error {
+			calls.Add(1)
+			return nil
+		}),
+		NewChecker("cache", func(context.Context) error { return errors.New("connection refused") }),
+		NewChecker("slow", func(context.Context) error {
+			// Ignores the context on purpose
+			time.Sleep(time.Second)
+			return nil
+		}),
+	)
+
+	start := time.Now()
+	report := h.Check(context.Background())
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Check() took %v, the slow checker should have timed out", elapsed)
+	}
+
+	if report.Status != StatusDown {
+		t.Errorf("Status = %q, want %q", report.Status, StatusDown)
+	}
+	if r := report.Checks["db"]; r.Status != StatusUp {
+		t.Errorf("db = %+v", r)
+	}
+	if r := report.Checks["cache"]; r.Status != StatusDown || r.Error != "connection refused" {
+		t.Errorf("cache = %+v", r)
+	}
+	if r := report.Checks["slow"]; r.Status != StatusDown || r.Error != context.DeadlineExceeded.Error() {
+		t.Errorf("slow = %+v", r)
+	}
+
+	h.Check(context.Background())
+	if calls.Load() != 1 {
+		t.Errorf("checker called %d times, want the cached result to be reused", calls.Load())
+	}
+}
+
+func TestRegisterRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	healthy := true
+	h := New(Config{CacheTTL: -1}, NewChecker("db", func(context.Context) error {
+		if !healthy {
+			return errors.New("down")
+		}
+		return nil
+	}))
+
+	r := gin.New()
+	h.RegisterRoutes(r)
+
+	do := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	if w := do(ReadyPath); w.Code != http.StatusOK {
+		t.Errorf("ready = %d %s", w.Code, w.Body)
+	}
+
+	healthy = false
+	w := do(ReadyPath)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("ready = %d, want 503", w.Code)
+	}
+	var report Report
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil || report.Checks["db"].Error != "down" {
+		t.Errorf("ready body = %s", w.Body)
+	}
+
+	// Liveness doesn't depend on the checkers
+	if w := do(LivePath); w.Code != http.StatusOK {
+		t.Errorf("live = %d, want 200", w.Code)
+	}
+
+	healthy = true
+	h.Drain()
+	w = do(ReadyPath)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("ready while draining = %d, want 503", w.Code)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil || report.Status != StatusDraining {
+		t.Errorf("ready body while draining = %s", w.Body)
+	}
+	if w := do(LivePath); w.Code != http.StatusOK {
+		t.Errorf("live while draining = %d, want 200", w.Code)
+	}
+}
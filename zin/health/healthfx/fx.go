@@ -0,0 +1,67 @@
+package healthfx
+
+import (
+	"context"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zihealth"
+	"github.com/divikraf/lumos/zin/health"
+	"github.com/divikraf/lumos/zin/zinfx"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/fx"
+)
+
+// Group is the fx group health checkers are collected from, see
+// zihealth.Group.
+const Group = zihealth.Group
+
+// healthConfig is implemented by configs customizing the health checks.
+type healthConfig interface {
+	GetHealth() health.Config
+}
+
+type healthParams struct {
+	fx.In
+
+	Config   ziconf.Config
+	Checkers []health.Checker `group:"health.checker"`
+}
+
+// Provider provides *health.Health aggregating every checker of the
+// "health.checker" group.
+var Provider = fx.Provide(
+	func(params healthParams) *health.Health {
+		config := health.DefaultConfig()
+		if c, ok := params.Config.(healthConfig); ok {
+			config = c.GetHealth()
+		}
+		return health.New(config, params.Checkers...)
+	},
+)
+
+// Invoker registers the health endpoints on the router.
+var Invoker = fx.Invoke(
+	func(h *health.Health, router *gin.Engine) {
+		h.RegisterRoutes(router)
+	},
+)
+
+// Module registers /health/live and /health/ready, excluded from HTTP
+// metrics.
+var Module = fx.Options(
+	Provider,
+	Invoker,
+	zinfx.AddSkipPaths(health.LivePath, health.ReadyPath),
+)
+
+// AddChecker contributes a checker named name to the readiness endpoint.
+func AddChecker(name string, check func(ctx context.Context) error) fx.Option {
+	return fx.Provide(
+		fx.Annotate(
+			func() health.Checker {
+				return health.NewChecker(name, check)
+			},
+			fx.ResultTags(Group),
+		),
+	)
+}
@@ -0,0 +1,65 @@
+package zin
+
+import (
+	"fmt"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.uber.org/fx"
+)
+
+// ListenerConfig configures an additional HTTP server, e.g. an internal admin
+// server next to the public API.
+type ListenerConfig struct {
+	// Addr is the address the server listens on, e.g. ":9090".
+	Addr string `json:"addr" yaml:"addr"`
+	// Server configures the server like the main one, with the defaults of
+	// DefaultServerConfig for zero timeouts.
+	Server ServerConfig `json:"server" yaml:"server"`
+}
+
+// listenersConfig is implemented by configs with additional HTTP servers, by
+// name.
+type listenersConfig interface {
+	GetHttpListeners() map[string]ListenerConfig
+}
+
+// NewRouter returns a router for an additional server, with the tracing,
+// request ID, client IP, recovery and error middlewares, followed by
+// middlewares. Logging and metrics are left to the caller, internal servers
+// usually don't need them.
+func NewRouter(config ziconf.Config, middlewares ...gin.HandlerFunc) (*gin.Engine, error) {
+	router := gin.New()
+	if err := configureClientIP(router, config); err != nil {
+		return nil, err
+	}
+	router.Use(otelgin.Middleware(config.GetService().Name))
+	router.Use(RequestIDMiddleware())
+	router.Use(ClientIPMiddleware())
+	router.Use(RecoveryMiddleware())
+	router.Use(ErrorMiddleware())
+	router.Use(middlewares...)
+	return router, nil
+}
+
+// StartServer starts the additional server name, configured by the
+// listeners of the config, serving router with the lifecycle. It shuts down
+// with the shutdown config of the main server, without failing readiness.
+// Its connection metrics have a server attribute.
+func StartServer(lc fx.Lifecycle, logger *zerolog.Logger, config ziconf.Config, name string, router *gin.Engine) error {
+	var listener ListenerConfig
+	var ok bool
+	if c, isListeners := config.(listenersConfig); isListeners {
+		listener, ok = c.GetHttpListeners()[name]
+	}
+	if !ok || listener.Addr == "" {
+		return fmt.Errorf("zin: no listener configured for the %q server", name)
+	}
+	shutdown := DefaultShutdownConfig()
+	if c, ok := config.(shutdownConfig); ok {
+		shutdown = c.GetShutdown()
+	}
+	return startServer(lc, logger, name, listener.Addr, router, listener.Server, shutdown, nil)
+}
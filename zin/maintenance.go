@@ -0,0 +1,163 @@
+package zin
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zin/health"
+	"github.com/gin-gonic/gin"
+)
+
+// MaintenancePath is the path of the maintenance admin endpoint.
+const MaintenancePath = "/maintenance"
+
+// ErrMaintenance is the error of the requests rejected in maintenance mode.
+var ErrMaintenance = NewAPIError(http.StatusServiceUnavailable, "maintenance", "error.maintenance", "Service under maintenance")
+
+// MaintenanceConfig configures the maintenance mode.
+type MaintenanceConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// RetryAfter is sent in the Retry-After header of rejected requests
+	// (default: 5m).
+	RetryAfter time.Duration `json:"retry_after" yaml:"retry_after"`
+	// AllowedPaths are the request paths still served in maintenance mode,
+	// paths ending with "*" being prefixes, e.g. "/internal/*". The health
+	// endpoints are always allowed.
+	AllowedPaths []string `json:"allowed_paths" yaml:"allowed_paths"`
+	// Token, if set, must be sent as a bearer token to reach the admin
+	// endpoint.
+	Token string `json:"token" yaml:"token"`
+}
+
+// maintenanceConfig is implemented by configs with a maintenance mode.
+type maintenanceConfig interface {
+	GetMaintenance() MaintenanceConfig
+}
+
+// Maintenance is a toggleable maintenance mode: while enabled, its
+// middleware rejects the requests of the paths not allowed with
+// ErrMaintenance and a Retry-After header. It is toggled with Update, e.g.
+// on a config reload, or through its admin endpoint, see RegisterRoutes.
+type Maintenance struct {
+	config atomic.Pointer[MaintenanceConfig]
+}
+
+// NewMaintenance returns a Maintenance in the state of config.
+func NewMaintenance(config MaintenanceConfig) *Maintenance {
+	m := &Maintenance{}
+	m.Update(config)
+	return m
+}
+
+// NewMaintenanceFromConfig returns a Maintenance configured by config,
+// disabled when it has no maintenance config.
+func NewMaintenanceFromConfig(config ziconf.Config) *Maintenance {
+	var mc MaintenanceConfig
+	if c, ok := config.(maintenanceConfig); ok {
+		mc = c.GetMaintenance()
+	}
+	return NewMaintenance(mc)
+}
+
+// Update replaces the config of the maintenance mode.
+func (m *Maintenance) Update(config MaintenanceConfig) {
+	if config.RetryAfter <= 0 {
+		config.RetryAfter = 5 * time.Minute
+	}
+	m.config.Store(&config)
+}
+
+// Enabled reports whether the maintenance mode is enabled.
+func (m *Maintenance) Enabled() bool {
+	return m.config.Load().Enabled
+}
+
+// SetEnabled enables or disables the maintenance mode, with retryAfter when
+// positive.
+func (m *Maintenance) SetEnabled(enabled bool, retryAfter time.Duration) {
+	config := *m.config.Load()
+	config.Enabled = enabled
+	if retryAfter > 0 {
+		config.RetryAfter = retryAfter
+	}
+	m.Update(config)
+}
+
+// Middleware returns the middleware rejecting requests while enabled.
+func (m *Maintenance) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		config := m.config.Load()
+		if !config.Enabled || maintenanceAllowed(config.AllowedPaths, c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		c.Header("Retry-After", strconv.Itoa(int(config.RetryAfter.Round(time.Second)/time.Second)))
+		AbortWithError(c, ErrMaintenance)
+	}
+}
+
+func maintenanceAllowed(allowed []string, path string) bool {
+	if path == health.LivePath || path == health.ReadyPath || path == MaintenancePath {
+		return true
+	}
+	for _, p := range allowed {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok && strings.HasPrefix(path, prefix) || p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// maintenanceState is the body of the admin endpoint.
+type maintenanceState struct {
+	Enabled    bool   `json:"enabled"`
+	RetryAfter string `json:"retry_after,omitempty"`
+}
+
+// RegisterRoutes mounts the admin endpoint of the maintenance mode on
+// router, best on an internal server: GET MaintenancePath returns the state,
+// PUT enables the mode, with an optional {"retry_after": "10m"} body, and
+// DELETE disables it.
+func (m *Maintenance) RegisterRoutes(router gin.IRouter) {
+	group := router.Group("")
+	if token := m.config.Load().Token; token != "" {
+		group.Use(RequireBearerToken(token))
+	}
+	group.GET(MaintenancePath, m.state)
+	group.PUT(MaintenancePath, func(c *gin.Context) {
+		var body maintenanceState
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&body); err != nil {
+				AbortWithError(c, ErrBadRequest.Wrap(err))
+				return
+			}
+		}
+		var retryAfter time.Duration
+		if body.RetryAfter != "" {
+			var err error
+			if retryAfter, err = time.ParseDuration(body.RetryAfter); err != nil {
+				AbortWithError(c, ErrBadRequest.Wrap(err))
+				return
+			}
+		}
+		m.SetEnabled(true, retryAfter)
+		m.state(c)
+	})
+	group.DELETE(MaintenancePath, func(c *gin.Context) {
+		m.SetEnabled(false, 0)
+		m.state(c)
+	})
+}
+
+func (m *Maintenance) state(c *gin.Context) {
+	config := m.config.Load()
+	state := maintenanceState{Enabled: config.Enabled}
+	if config.Enabled {
+		state.RetryAfter = config.RetryAfter.String()
+	}
+	OK(c, state)
+}
@@ -0,0 +1,69 @@
+package zin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/divikraf/lumos/zin/health"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMaintenance(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := NewMaintenance(MaintenanceConfig{AllowedPaths: []string{"/internal/*", "/status"}, Token: "secret"})
+
+	router := gin.New()
+	router.Use(ErrorMiddleware(), m.Middleware())
+	for _, path := range []string{"/orders", "/internal/jobs", "/status", health.LivePath} {
+		router.GET(path, func(c *gin.Context) { c.Status(http.StatusOK) })
+	}
+	m.RegisterRoutes(router)
+
+	do := func(method, path, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodGet, "/orders", "", ""); w.Code != http.StatusOK {
+		t.Fatalf("GET /orders = %d while disabled", w.Code)
+	}
+	if w := do(http.MethodPut, MaintenancePath, "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("PUT %s without a token = %d, want 401", MaintenancePath, w.Code)
+	}
+	if w := do(http.MethodPut, MaintenancePath, `{"retry_after":"2m"}`, "secret"); w.Code != http.StatusOK || !m.Enabled() {
+		t.Fatalf("PUT %s = %d %s, want maintenance enabled", MaintenancePath, w.Code, w.Body)
+	}
+
+	w := do(http.MethodGet, "/orders", "", "")
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "120" {
+		t.Errorf("GET /orders = %d, Retry-After %q, want 503 after 120s", w.Code, w.Header().Get("Retry-After"))
+	}
+	if !strings.Contains(w.Body.String(), `"code":"maintenance"`) {
+		t.Errorf("body = %s, want the maintenance error", w.Body)
+	}
+	for _, path := range []string{"/internal/jobs", "/status", health.LivePath} {
+		if w := do(http.MethodGet, path, "", ""); w.Code != http.StatusOK {
+			t.Errorf("GET %s = %d, want it allowed", path, w.Code)
+		}
+	}
+
+	if w := do(http.MethodDelete, MaintenancePath, "", "secret"); w.Code != http.StatusOK || m.Enabled() {
+		t.Fatalf("DELETE %s = %d, want maintenance disabled", MaintenancePath, w.Code)
+	}
+	if w := do(http.MethodGet, "/orders", "", ""); w.Code != http.StatusOK {
+		t.Errorf("GET /orders = %d after disabling", w.Code)
+	}
+
+	m.Update(MaintenanceConfig{Enabled: true})
+	w = do(http.MethodGet, "/internal/jobs", "", "")
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "300" {
+		t.Errorf("GET /internal/jobs = %d, Retry-After %q after a config update", w.Code, w.Header().Get("Retry-After"))
+	}
+}
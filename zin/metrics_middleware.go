@@ -1,6 +1,7 @@
 package zin
 
 import (
+	"fmt"
 	"regexp"
 	"strconv"
 	"sync"
@@ -12,10 +13,16 @@ import (
 	"go.opentelemetry.io/otel/metric"
 )
 
-// Global single histogram for HTTP metrics
+const instrumentationName = "github.com/divikraf/lumos/zin"
+
+// scope only has the metrics of the router, the spans of the requests are
+// created by otelgin.
+var scope = revelio.Library(instrumentationName)
+
+// HTTP histograms, by metric name
 var (
-	httpHistogram metric.Int64Histogram
-	histogramOnce sync.Once
+	httpHistograms   = map[string]metric.Int64Histogram{}
+	httpHistogramsMu sync.Mutex
 )
 
 // HTTPMetricsConfig holds configuration for HTTP metrics middleware
@@ -26,11 +33,18 @@ type HTTPMetricsConfig struct {
 	// MetricDescription is the description of the histogram metric
 	MetricDescription string
 
-	// MetricUnit is the unit of the histogram metric (default: "ms")
+	// MetricUnit is the unit of the histogram metric, one of "ms", "us" or
+	// "ns" (default: "ms")
 	MetricUnit string
 
 	// Labels to include in the histogram
-	// Available labels: method, path, status_code, route, user_agent
+	// Available labels: method, path, status_code, route, user_agent,
+	// error_cause (default: method, route, status_code, error_cause). The
+	// path label is normalized with NormalizePathFunc, while the route of
+	// requests matching no route is UnmatchedRoute. The error_cause label is
+	// the cause of the failed requests, see ErrorCause, and CauseNone for the
+	// others. Beware of path and user_agent, they can have a high
+	// cardinality.
 	Labels []string
 
 	// SkipPaths is a list of paths to skip metrics collection
@@ -39,8 +53,117 @@ type HTTPMetricsConfig struct {
 	// NormalizePath if true, normalizes paths like /users/123 to /users/:id
 	NormalizePath bool
 
-	// NormalizePathFunc is a custom function to normalize paths
+	// NormalizePathFunc is a custom function to normalize paths (default:
+	// AdvancedNormalizePath)
 	NormalizePathFunc func(string) string
+
+	// Enrichers append extra attributes to the histogram, see MetricsEnricher
+	Enrichers []MetricsEnricher
+
+	// MaxExtraAttributes caps the number of attributes added by Enrichers
+	// (default: DefaultMaxExtraAttributes)
+	MaxExtraAttributes int
+
+	// RecordSizes if true, records the request and response body sizes in the
+	// http_request_size_bytes and http_response_size_bytes histograms, with
+	// the same labels as the duration. Requests of unknown size are skipped.
+	RecordSizes bool
+
+	// RecordInFlight if true, counts the requests being served in the
+	// http_requests_in_flight up-down counter, by method.
+	RecordInFlight bool
+}
+
+// Names of the optional HTTP metrics
+const (
+	HTTPRequestSizeMetric      = "http_request_size_bytes"
+	HTTPResponseSizeMetric     = "http_response_size_bytes"
+	HTTPRequestsInFlightMetric = "http_requests_in_flight"
+)
+
+// DefaultMaxExtraAttributes is the default cap on the number of attributes
+// added by metrics enrichers.
+const DefaultMaxExtraAttributes = 4
+
+// MetricsEnricher computes extra attributes recorded with the HTTP request
+// duration, e.g. a tenant tier or an API version. It runs after the handlers,
+// so it can read values they stored in the context. Every attribute value
+// must come from a small bounded set: each distinct value creates a new time
+// series.
+type MetricsEnricher func(c *gin.Context) []attribute.KeyValue
+
+// Context keys of the route metrics metadata
+const (
+	skipMetricsKey  = "zin.metrics.skip"
+	metricLabelsKey = "zin.metrics.labels"
+)
+
+// SkipMetrics returns a handler excluding the routes it is attached to from
+// the HTTP metrics, e.g. noisy internal endpoints:
+//
+//	router.GET("/internal/poll", zin.SkipMetrics(), poll)
+func SkipMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(skipMetricsKey, true)
+	}
+}
+
+// WithMetricLabels returns a handler adding labels to the HTTP metrics of the
+// routes it is attached to, e.g. a handler label:
+//
+//	router.GET("/users", zin.WithMetricLabels(attribute.String("handler", "list_users")), listUsers)
+//
+// They take precedence over the labels added by enrichers, and count toward
+// MaxExtraAttributes. Labels of HTTPMetricsConfig can't be overridden.
+func WithMetricLabels(labels ...attribute.KeyValue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		existing, _ := c.Get(metricLabelsKey)
+		current, _ := existing.([]attribute.KeyValue)
+		c.Set(metricLabelsKey, append(current[:len(current):len(current)], labels...))
+	}
+}
+
+// routeMetricLabels is the enricher returning the labels added with
+// WithMetricLabels.
+func routeMetricLabels(c *gin.Context) []attribute.KeyValue {
+	labels, _ := c.Get(metricLabelsKey)
+	kvs, _ := labels.([]attribute.KeyValue)
+	return kvs
+}
+
+// reservedMetricsAttributes are the available labels, they can't be
+// overridden by enrichers.
+var reservedMetricsAttributes = map[attribute.Key]bool{
+	"method":      true,
+	"path":        true,
+	"route":       true,
+	"status_code": true,
+	"user_agent":  true,
+	"error_cause": true,
+}
+
+// enrichAttributes appends to attrs the attributes returned by enrichers, up
+// to max of them. Reserved and duplicate keys are dropped.
+func enrichAttributes(c *gin.Context, attrs []attribute.KeyValue, enrichers []MetricsEnricher, max int) []attribute.KeyValue {
+	if max <= 0 {
+		max = DefaultMaxExtraAttributes
+	}
+	added := 0
+	seen := make(map[attribute.Key]bool, max)
+	for _, enrich := range enrichers {
+		for _, kv := range enrich(c) {
+			if added == max {
+				return attrs
+			}
+			if reservedMetricsAttributes[kv.Key] || seen[kv.Key] || !kv.Valid() {
+				continue
+			}
+			seen[kv.Key] = true
+			attrs = append(attrs, kv)
+			added++
+		}
+	}
+	return attrs
 }
 
 // DefaultHTTPMetricsConfig returns the default configuration for HTTP metrics
@@ -49,25 +172,80 @@ func DefaultHTTPMetricsConfig() HTTPMetricsConfig {
 		MetricName:        "http_request_duration_ms",
 		MetricDescription: "HTTP request duration in milliseconds",
 		MetricUnit:        "ms",
-		Labels:            []string{"method", "path", "status_code"},
+		Labels:            []string{"method", "route", "status_code", "error_cause"},
 		SkipPaths:         []string{"/health", "/metrics", "/ready"},
 		NormalizePath:     true,
-		NormalizePathFunc: defaultNormalizePath,
+		NormalizePathFunc: AdvancedNormalizePath,
 	}
 }
 
-// getHTTPHistogram gets or creates the single HTTP histogram
-func getHTTPHistogram() metric.Int64Histogram {
-	histogramOnce.Do(func() {
-		httpHistogram = revelio.MustInt64Histogram("http_request_duration_ms", "HTTP request duration in milliseconds", metric.WithUnit("ms"))
-	})
-	return httpHistogram
+// UnmatchedRoute is the route label of the requests matching no route, so
+// that the paths of 404s don't make the cardinality of the metrics unbounded.
+const UnmatchedRoute = "unmatched"
+
+// getHTTPHistogram gets or creates the HTTP histogram named name
+func getHTTPHistogram(name, description, unit string) metric.Int64Histogram {
+	httpHistogramsMu.Lock()
+	defer httpHistogramsMu.Unlock()
+	h, ok := httpHistograms[name]
+	if !ok {
+		h = revelio.Must(scope.Int64Histogram(name, description, metric.WithUnit(unit)))
+		httpHistograms[name] = h
+	}
+	return h
 }
 
-// HTTPMetricsMiddleware creates a Gin middleware that records HTTP request metrics
+// durationUnits converts durations to the supported metric units
+var durationUnits = map[string]func(time.Duration) int64{
+	"ms": time.Duration.Milliseconds,
+	"us": time.Duration.Microseconds,
+	"ns": time.Duration.Nanoseconds,
+}
+
+// HTTPMetricsMiddleware creates a Gin middleware that records HTTP request metrics.
+// It panics when config has an unknown label or unit.
 func HTTPMetricsMiddleware(config HTTPMetricsConfig) gin.HandlerFunc {
-	// Get the single HTTP histogram
-	histogram := getHTTPHistogram()
+	defaults := DefaultHTTPMetricsConfig()
+	if config.MetricName == "" {
+		config.MetricName = defaults.MetricName
+	}
+	if config.MetricDescription == "" {
+		config.MetricDescription = defaults.MetricDescription
+	}
+	if config.MetricUnit == "" {
+		config.MetricUnit = defaults.MetricUnit
+	}
+	if config.Labels == nil {
+		config.Labels = defaults.Labels
+	}
+	for _, label := range config.Labels {
+		if !reservedMetricsAttributes[attribute.Key(label)] {
+			panic(fmt.Sprintf("zin: unknown HTTP metrics label %q", label))
+		}
+	}
+	toUnit, ok := durationUnits[config.MetricUnit]
+	if !ok {
+		panic(fmt.Sprintf("zin: unsupported HTTP metrics unit %q", config.MetricUnit))
+	}
+	normalize := func(path string) string { return path }
+	if config.NormalizePath {
+		normalize = config.NormalizePathFunc
+		if normalize == nil {
+			normalize = AdvancedNormalizePath
+		}
+	}
+
+	histogram := getHTTPHistogram(config.MetricName, config.MetricDescription, config.MetricUnit)
+	var requestSize, responseSize metric.Int64Histogram
+	if config.RecordSizes {
+		requestSize = getHTTPHistogram(HTTPRequestSizeMetric, "HTTP request body size in bytes", "By")
+		responseSize = getHTTPHistogram(HTTPResponseSizeMetric, "HTTP response body size in bytes", "By")
+	}
+	var inFlight metric.Int64UpDownCounter
+	if config.RecordInFlight {
+		inFlight = revelio.Must(scope.Int64UpDownCounter(HTTPRequestsInFlightMetric, "Number of HTTP requests being served", metric.WithUnit("{request}")))
+	}
+	enrichers := append([]MetricsEnricher{routeMetricLabels}, config.Enrichers...)
 
 	// Create skip paths map for O(1) lookup
 	skipPaths := make(map[string]bool)
@@ -83,58 +261,90 @@ func HTTPMetricsMiddleware(config HTTPMetricsConfig) gin.HandlerFunc {
 		}
 
 		start := time.Now()
+		if inFlight != nil {
+			method := metric.WithAttributes(attribute.String("method", c.Request.Method))
+			inFlight.Add(c.Request.Context(), 1, method)
+			defer inFlight.Add(c.Request.Context(), -1, method)
+		}
 
 		// Process request
 		c.Next()
 
-		// Calculate duration
-		duration := time.Since(start).Milliseconds()
-
-		// Get route pattern (e.g., /users/:id instead of /users/123)
-		route := c.FullPath()
-		if route == "" {
-			// If no route pattern found, use the actual path
-			route = c.Request.URL.Path
+		if c.GetBool(skipMetricsKey) {
+			return
+		}
+		duration := toUnit(time.Since(start))
+
+		// Record histogram with the configured labels, followed by the
+		// enriched ones
+		attrs := make([]attribute.KeyValue, 0, len(config.Labels))
+		for _, label := range config.Labels {
+			var value string
+			switch label {
+			case "method":
+				value = c.Request.Method
+			case "path":
+				value = normalize(c.Request.URL.Path)
+			case "route":
+				// Route pattern (e.g., /users/:id instead of /users/123)
+				if value = c.FullPath(); value == "" {
+					value = UnmatchedRoute
+				}
+			case "status_code":
+				value = strconv.Itoa(c.Writer.Status())
+			case "user_agent":
+				value = c.Request.UserAgent()
+			case "error_cause":
+				value = responseCause(c)
+			}
+			attrs = append(attrs, attribute.String(label, value))
+		}
+		attrs = enrichAttributes(c, attrs, enrichers, config.MaxExtraAttributes)
+		set := metric.WithAttributeSet(attribute.NewSet(attrs...))
+		histogram.Record(c.Request.Context(), duration, set)
+		if config.RecordSizes {
+			if c.Request.ContentLength >= 0 {
+				requestSize.Record(c.Request.Context(), c.Request.ContentLength, set)
+			}
+			responseSize.Record(c.Request.Context(), int64(max(c.Writer.Size(), 0)), set)
 		}
-
-		// Record histogram with fixed labels: method, route, status_code
-		histogram.Record(c.Request.Context(), duration,
-			metric.WithAttributes(
-				attribute.String("method", c.Request.Method),
-				attribute.String("route", route),
-				attribute.String("status_code", strconv.Itoa(c.Writer.Status())),
-			),
-		)
 	}
 }
 
-// defaultNormalizePath provides a simple path normalization
-func defaultNormalizePath(path string) string {
-	// This is a simple implementation - in production you might want more sophisticated normalization
-	// For example, converting /users/123 to /users/:id
-
-	// For now, just return the path as-is
-	// You can enhance this based on your needs
-	return path
-}
+// Patterns replaced by AdvancedNormalizePath
+var (
+	numericIDRegex = regexp.MustCompile(`/\d+(/|$)`)
+	uuidRegex      = regexp.MustCompile(`/[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}(/|$)`)
+	slugRegex      = regexp.MustCompile(`/[a-zA-Z0-9_-]{20,}(/|$)`)
+)
 
 // AdvancedNormalizePath provides advanced path normalization function that converts numeric IDs to placeholders
 func AdvancedNormalizePath(path string) string {
-	// Replace numeric IDs with regex
-	numericIDRegex := regexp.MustCompile(`/\d+`)
-	path = numericIDRegex.ReplaceAllString(path, "/:id")
+	// Replace UUIDs first, they may start with digits
+	path = replaceSegments(uuidRegex, path, "/:uuid")
 
-	// Replace UUIDs (basic pattern)
-	uuidRegex := regexp.MustCompile(`/[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
-	path = uuidRegex.ReplaceAllString(path, "/:uuid")
+	// Replace numeric IDs
+	path = replaceSegments(numericIDRegex, path, "/:id")
 
 	// Replace long alphanumeric strings (like slugs)
-	slugRegex := regexp.MustCompile(`/[a-zA-Z0-9_-]{20,}`)
-	path = slugRegex.ReplaceAllString(path, "/:slug")
+	path = replaceSegments(slugRegex, path, "/:slug")
 
 	return path
 }
 
+// replaceSegments replaces the whole path segments matched by re with repl.
+// Matches include the following slash, so it is replaced repeatedly to handle
+// consecutive segments.
+func replaceSegments(re *regexp.Regexp, path, repl string) string {
+	for {
+		next := re.ReplaceAllString(path, repl+"$1")
+		if next == path {
+			return path
+		}
+		path = next
+	}
+}
+
 // Convenience functions for common configurations
 
 // HTTPMetricsMiddlewareDefault creates middleware with default configuration
@@ -142,57 +352,35 @@ func HTTPMetricsMiddlewareDefault() gin.HandlerFunc {
 	return HTTPMetricsMiddleware(DefaultHTTPMetricsConfig())
 }
 
-// httpMetricsMiddlewareWithSkipPaths creates middleware with provided skip paths
-func httpMetricsMiddlewareWithSkipPaths(skipPathsList []string) gin.HandlerFunc {
-	// Get the single HTTP histogram
-	histogram := getHTTPHistogram()
-
-	// Create skip paths map for O(1) lookup
-	skipPaths := make(map[string]bool)
-	for _, path := range skipPathsList {
-		skipPaths[path] = true
-	}
-
-	return func(c *gin.Context) {
-		// Check if we should skip this path
-		if skipPaths[c.Request.URL.Path] {
-			c.Next()
-			return
-		}
-
-		start := time.Now()
-
-		// Process request
-		c.Next()
-
-		// Calculate duration
-		duration := time.Since(start).Milliseconds()
-
-		// Get route pattern (e.g., /users/:id instead of /users/123)
-		route := c.FullPath()
-		if route == "" {
-			// If no route pattern found, use the actual path
-			route = c.Request.URL.Path
-		}
+// HTTPMetricsOptions holds the configurable HTTP metrics of the router.
+type HTTPMetricsOptions struct {
+	RecordSizes    bool `json:"record_sizes" yaml:"record_sizes"`
+	RecordInFlight bool `json:"record_in_flight" yaml:"record_in_flight"`
+}
 
-		// Record histogram with fixed labels: method, route, status_code
-		histogram.Record(c.Request.Context(), duration,
-			metric.WithAttributes(
-				attribute.String("method", c.Request.Method),
-				attribute.String("route", route),
-				attribute.String("status_code", strconv.Itoa(c.Writer.Status())),
-			),
-		)
-	}
+// httpMetricsConfig is implemented by configurations enabling optional HTTP
+// metrics.
+type httpMetricsConfig interface {
+	GetHttpMetrics() HTTPMetricsOptions
 }
 
-// HTTPMetricsMiddlewareWithNormalization creates middleware with path normalization
-func HTTPMetricsMiddlewareWithNormalization() gin.HandlerFunc {
+// httpMetricsMiddlewareWithSkipPaths creates middleware with provided skip paths
+// and enrichers
+func httpMetricsMiddlewareWithSkipPaths(skipPathsList []string, enrichers []MetricsEnricher, opts HTTPMetricsOptions) gin.HandlerFunc {
 	config := DefaultHTTPMetricsConfig()
-	config.NormalizePathFunc = AdvancedNormalizePath
+	config.SkipPaths = skipPathsList
+	config.Enrichers = enrichers
+	config.RecordSizes = opts.RecordSizes
+	config.RecordInFlight = opts.RecordInFlight
 	return HTTPMetricsMiddleware(config)
 }
 
+// HTTPMetricsMiddlewareWithNormalization creates middleware with path
+// normalization, now the default of DefaultHTTPMetricsConfig.
+func HTTPMetricsMiddlewareWithNormalization() gin.HandlerFunc {
+	return HTTPMetricsMiddleware(DefaultHTTPMetricsConfig())
+}
+
 // HTTPMetricsMiddlewareMinimal creates middleware with minimal labels
 func HTTPMetricsMiddlewareMinimal() gin.HandlerFunc {
 	config := DefaultHTTPMetricsConfig()
@@ -203,12 +391,13 @@ func HTTPMetricsMiddlewareMinimal() gin.HandlerFunc {
 // HTTPMetricsMiddlewareDetailed creates middleware with all available labels
 func HTTPMetricsMiddlewareDetailed() gin.HandlerFunc {
 	config := DefaultHTTPMetricsConfig()
-	config.Labels = []string{"method", "path", "route", "status_code", "user_agent"}
+	config.Labels = []string{"method", "path", "route", "status_code", "user_agent", "error_cause"}
 	return HTTPMetricsMiddleware(config)
 }
 
-// ClearHTTPHistogram resets the HTTP histogram (useful for testing)
+// ClearHTTPHistogram resets the HTTP histograms (useful for testing)
 func ClearHTTPHistogram() {
-	histogramOnce = sync.Once{}
-	httpHistogram = nil
+	httpHistogramsMu.Lock()
+	defer httpHistogramsMu.Unlock()
+	httpHistograms = map[string]metric.Int64Histogram{}
 }
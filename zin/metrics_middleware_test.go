@@ -0,0 +1,246 @@
+package zin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestHTTPMetricsMiddlewareEnrichers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := reveliotest.NewDefaultTestScope(t)
+
+	config := DefaultHTTPMetricsConfig()
+	config.MaxExtraAttributes = 2
+	config.Enrichers = []MetricsEnricher{
+		func(c *gin.Context) []attribute.KeyValue {
+			return []attribute.KeyValue{
+				attribute.String("route", "/overridden"),
+				attribute.String("tenant_tier", c.GetString("tier")),
+			}
+		},
+		func(c *gin.Context) []attribute.KeyValue {
+			return []attribute.KeyValue{
+				attribute.String("tenant_tier", "duplicate"),
+				attribute.String("api_version", "v2"),
+				attribute.String("client_app", "over the cap"),
+			}
+		},
+	}
+
+	r := gin.New()
+	r.Use(HTTPMetricsMiddleware(config))
+	r.GET("/users/:id", func(c *gin.Context) {
+		c.Set("tier", "gold")
+		c.Status(http.StatusOK)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+
+	h := reveliotest.CollectHistogram(t, s, "http_request_duration_ms",
+		attribute.String("route", "/users/:id"),
+		attribute.String("tenant_tier", "gold"),
+		attribute.String("api_version", "v2"),
+	)
+	if h.Count != 1 {
+		t.Fatalf("count = %d, want 1 request with the enriched attributes", h.Count)
+	}
+	if got := reveliotest.CollectHistogram(t, s, "http_request_duration_ms", attribute.String("client_app", "over the cap")); got.Count != 0 {
+		t.Errorf("attributes past the cap should be dropped, got %d data points", got.Count)
+	}
+}
+
+func TestHTTPMetricsMiddlewareConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := reveliotest.NewDefaultTestScope(t)
+
+	config := HTTPMetricsConfig{
+		MetricName:        "api_request_duration_us",
+		MetricUnit:        "us",
+		Labels:            []string{"method", "path", "status_code"},
+		SkipPaths:         []string{"/skipped"},
+		NormalizePath:     true,
+		NormalizePathFunc: AdvancedNormalizePath,
+	}
+	r := gin.New()
+	r.Use(HTTPMetricsMiddleware(config))
+	r.GET("/users/:id/orders/:order", func(c *gin.Context) { c.Status(http.StatusOK) })
+	for _, path := range []string{"/users/1/orders/2", "/users/42/orders/43", "/skipped", "/files/0b7e4c1e-1d3a-4d6e-9a1b-7c2d3e4f5a6b"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	h := reveliotest.CollectHistogram(t, s, "api_request_duration_us",
+		attribute.String("method", "GET"),
+		attribute.String("path", "/users/:id/orders/:id"),
+		attribute.String("status_code", "200"),
+	)
+	if h.Count != 2 {
+		t.Errorf("count = %d, want 2 requests with the normalized path", h.Count)
+	}
+	if got := reveliotest.CollectHistogram(t, s, "api_request_duration_us", attribute.String("path", "/files/:uuid")); got.Count != 1 {
+		t.Errorf("unmatched count = %d, want 1", got.Count)
+	}
+	if m, _ := s.Metric(t, "api_request_duration_us"); m.Unit != "us" {
+		t.Errorf("unit = %q, want us", m.Unit)
+	}
+	if got := reveliotest.CollectHistogram(t, s, "api_request_duration_us", attribute.String("route", "/users/:id/orders/:order")); got.Count != 0 {
+		t.Errorf("route label is not configured, got %d data points", got.Count)
+	}
+}
+
+func TestHTTPMetricsMiddlewareUnknownLabel(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("HTTPMetricsMiddleware should panic on unknown labels")
+		}
+	}()
+	HTTPMetricsMiddleware(HTTPMetricsConfig{Labels: []string{"tenant"}})
+}
+
+func TestAdvancedNormalizePath(t *testing.T) {
+	tests := map[string]string{
+		"/users/123":    "/users/:id",
+		"/users/1/2":    "/users/:id/:id",
+		"/v2/users":     "/v2/users",
+		"/users/123abc": "/users/123abc",
+		"/a/0b7e4c1e-1d3a-4d6e-9a1b-7c2d3e4f5a6b/b": "/a/:uuid/b",
+		"/posts/a-very-long-article-slug-here":      "/posts/:slug",
+	}
+	for path, want := range tests {
+		if got := AdvancedNormalizePath(path); got != want {
+			t.Errorf("AdvancedNormalizePath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestHTTPMetricsMiddlewareRouteMetadata(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := reveliotest.NewDefaultTestScope(t)
+
+	config := DefaultHTTPMetricsConfig()
+	config.Enrichers = []MetricsEnricher{
+		func(c *gin.Context) []attribute.KeyValue {
+			return []attribute.KeyValue{attribute.String("handler", "enriched")}
+		},
+	}
+	r := gin.New()
+	r.Use(HTTPMetricsMiddleware(config))
+	r.GET("/poll", SkipMetrics(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	api := r.Group("/api", WithMetricLabels(attribute.String("api", "v1")))
+	api.GET("/users", WithMetricLabels(attribute.String("handler", "list_users"), attribute.String("status_code", "999")), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	for _, path := range []string{"/poll", "/api/users"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := reveliotest.CollectHistogram(t, s, "http_request_duration_ms", attribute.String("route", "/poll")); got.Count != 0 {
+		t.Errorf("skipped route recorded %d times", got.Count)
+	}
+	h := reveliotest.CollectHistogram(t, s, "http_request_duration_ms",
+		attribute.String("route", "/api/users"),
+		attribute.String("status_code", "200"),
+		attribute.String("api", "v1"),
+		attribute.String("handler", "list_users"),
+	)
+	if h.Count != 1 {
+		t.Errorf("count = %d, want 1 request with the route labels", h.Count)
+	}
+}
+
+func TestHTTPMetricsMiddlewareSizesAndInFlight(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := reveliotest.NewDefaultTestScope(t)
+
+	config := DefaultHTTPMetricsConfig()
+	config.RecordSizes = true
+	config.RecordInFlight = true
+	r := gin.New()
+	r.Use(HTTPMetricsMiddleware(config))
+	r.POST("/echo", func(c *gin.Context) {
+		reveliotest.AssertCounterValue(t, s, HTTPRequestsInFlightMetric, 1, attribute.String("method", http.MethodPost))
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, "%s%s", body, body)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader("hello")))
+
+	reveliotest.AssertCounterValue(t, s, HTTPRequestsInFlightMetric, 0, attribute.String("method", http.MethodPost))
+	req := reveliotest.CollectHistogram(t, s, HTTPRequestSizeMetric, attribute.String("route", "/echo"))
+	if req.Count != 1 || req.Sum != 5 {
+		t.Errorf("request size count = %d, sum = %v", req.Count, req.Sum)
+	}
+	resp := reveliotest.CollectHistogram(t, s, HTTPResponseSizeMetric, attribute.String("route", "/echo"), attribute.String("status_code", "200"))
+	if resp.Count != 1 || resp.Sum != 10 {
+		t.Errorf("response size count = %d, sum = %v", resp.Count, resp.Sum)
+	}
+}
+
+func TestHTTPMetricsMiddlewareErrorCause(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := reveliotest.NewDefaultTestScope(t)
+
+	r := gin.New()
+	r.Use(HTTPMetricsMiddleware(DefaultHTTPMetricsConfig()), RecoveryMiddleware(), ErrorMiddleware())
+	r.GET("/ok", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/invalid", Handle(func(c *gin.Context) error { return ErrBadRequest }))
+	r.GET("/forbidden", Handle(func(c *gin.Context) error { return ErrForbidden }))
+	r.GET("/limited", Handle(func(c *gin.Context) error { return ErrTooManyRequests }))
+	r.GET("/timeout", Handle(func(c *gin.Context) error { return context.DeadlineExceeded }))
+	r.GET("/upstream", Handle(func(c *gin.Context) error {
+		return ErrServiceUnavailable.WithCause(CauseUpstreamTimeout)
+	}))
+	r.GET("/failed", Handle(func(c *gin.Context) error { return errors.New("boom") }))
+	r.GET("/panic", func(c *gin.Context) { panic("boom") })
+
+	tests := map[string]string{
+		"/ok":        CauseNone,
+		"/invalid":   CauseValidation,
+		"/forbidden": CauseAuth,
+		"/missing":   CauseNotFound,
+		"/limited":   CauseRateLimited,
+		"/timeout":   CauseUpstreamTimeout,
+		"/upstream":  CauseUpstreamTimeout,
+		"/failed":    CauseHandlerError,
+		"/panic":     CausePanic,
+	}
+	for path, cause := range tests {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		route := path
+		if path == "/missing" {
+			route = UnmatchedRoute
+		}
+		h := reveliotest.CollectHistogram(t, s, "http_request_duration_ms",
+			attribute.String("route", route),
+			attribute.String("error_cause", cause),
+		)
+		if h.Count != 1 {
+			t.Errorf("%s: count = %d, want 1 request with cause %s", path, h.Count, cause)
+		}
+	}
+}
+
+func TestHTTPMetricsMiddlewareUnmatchedRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := reveliotest.NewDefaultTestScope(t)
+
+	r := gin.New()
+	r.Use(HTTPMetricsMiddleware(DefaultHTTPMetricsConfig()))
+	for _, path := range []string{"/wp-admin", "/.env", "/users/42"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	h := reveliotest.CollectHistogram(t, s, "http_request_duration_ms",
+		attribute.String("route", UnmatchedRoute),
+		attribute.String("status_code", "404"),
+	)
+	if h.Count != 3 {
+		t.Errorf("count = %d, want the 3 unmatched requests under %s", h.Count, UnmatchedRoute)
+	}
+}
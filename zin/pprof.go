@@ -0,0 +1,160 @@
+package zin
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rpprof "runtime/pprof"
+	"strconv"
+	"strings"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zilog"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"go.uber.org/fx"
+)
+
+const (
+	DebugPprofPath      = "/debug/pprof"
+	DebugVarsPath       = "/debug/vars"
+	DebugGoroutinesPath = "/debug/goroutines"
+	DebugLogsPath       = "/debug/logs"
+)
+
+// DebugRoutesConfig configures the pprof and expvar debug endpoints.
+type DebugRoutesConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Token, if set, must be sent as a bearer token to reach the endpoints.
+	Token string `json:"token" yaml:"token"`
+	// Addr, if set, serves the endpoints on a separate listener, e.g.
+	// "127.0.0.1:6060", instead of the main router.
+	Addr string `json:"addr" yaml:"addr"`
+}
+
+// debugRoutesConfig is implemented by configs enabling the debug endpoints.
+type debugRoutesConfig interface {
+	GetDebugRoutes() DebugRoutesConfig
+}
+
+// DebugRoutesOption is a functional option to configure the debug endpoints.
+type DebugRoutesOption func(cfg *DebugRoutesConfig)
+
+// WithDebugToken requires token to be sent as a bearer token.
+func WithDebugToken(token string) DebugRoutesOption {
+	return func(cfg *DebugRoutesConfig) {
+		cfg.Token = token
+	}
+}
+
+// DebugRoutes mounts the debug endpoints on router:
+//
+//   - /debug/pprof/* serves the net/http/pprof profiles,
+//   - /debug/vars serves the expvar variables,
+//   - /debug/goroutines dumps the stack of every goroutine,
+//   - /debug/logs dumps the recent logs kept by zilog.DefaultRing.
+func DebugRoutes(router gin.IRouter, opts ...DebugRoutesOption) {
+	var cfg DebugRoutesConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	group := router.Group("")
+	if cfg.Token != "" {
+		group.Use(RequireBearerToken(cfg.Token))
+	}
+	group.GET(DebugPprofPath+"/*name", debugPprof)
+	group.POST(DebugPprofPath+"/symbol", gin.WrapF(pprof.Symbol))
+	group.GET(DebugVarsPath, gin.WrapH(expvar.Handler()))
+	group.GET(DebugGoroutinesPath, debugGoroutines)
+	group.GET(DebugLogsPath, debugLogs)
+}
+
+// RequireBearerToken returns a middleware rejecting the requests without
+// token as bearer token with 401, e.g. to protect admin endpoints.
+func RequireBearerToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		got, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
+
+func debugPprof(c *gin.Context) {
+	switch strings.TrimPrefix(c.Param("name"), "/") {
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		// Serves the index and the named profiles, e.g. heap
+		pprof.Index(c.Writer, c.Request)
+	}
+}
+
+func debugGoroutines(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Header("X-Goroutine-Count", strconv.Itoa(runtime.NumGoroutine()))
+	_ = rpprof.Lookup("goroutine").WriteTo(c.Writer, 2)
+}
+
+func debugLogs(c *gin.Context) {
+	c.Header("Content-Type", "application/x-ndjson")
+	_, _ = zilog.DefaultRing.WriteTo(c.Writer)
+}
+
+// DebugServerParams holds the dependencies of RegisterDebugRoutes.
+type DebugServerParams struct {
+	fx.In
+
+	LC     fx.Lifecycle
+	Logger *zerolog.Logger
+	Config ziconf.Config
+	Router *gin.Engine
+}
+
+// RegisterDebugRoutes mounts the debug endpoints when enabled by the config,
+// either on the main router or on a separate listener.
+func RegisterDebugRoutes(params DebugServerParams) {
+	c, ok := params.Config.(debugRoutesConfig)
+	if !ok || !c.GetDebugRoutes().Enabled {
+		return
+	}
+	cfg := c.GetDebugRoutes()
+
+	if cfg.Addr == "" {
+		DebugRoutes(params.Router, WithDebugToken(cfg.Token))
+		return
+	}
+
+	router := gin.New()
+	router.Use(RecoveryMiddleware())
+	DebugRoutes(router, WithDebugToken(cfg.Token))
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           router,
+		ReadHeaderTimeout: DefaultServerConfig().ReadHeaderTimeout,
+	}
+
+	params.LC.Append(fx.StartHook(func() {
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				params.Logger.Error().Err(err).Msgf("debug server failed on %s", srv.Addr)
+			}
+		}()
+	}))
+	params.LC.Append(fx.StopHook(func(ctx context.Context) error {
+		return srv.Shutdown(ctx)
+	}))
+}
@@ -0,0 +1,52 @@
+package zin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/divikraf/lumos/zilog"
+	"github.com/gin-gonic/gin"
+)
+
+func TestDebugRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	zilog.DefaultLogger.Warn().Msg("kept for the debug routes")
+	DebugRoutes(router, WithDebugToken("s3cret"))
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+		body   string
+	}{
+		{name: "missing token", path: "/debug/vars", status: http.StatusUnauthorized},
+		{name: "wrong token", path: "/debug/vars", token: "nope", status: http.StatusUnauthorized},
+		{name: "expvar", path: "/debug/vars", token: "s3cret", status: http.StatusOK, body: "memstats"},
+		{name: "pprof index", path: "/debug/pprof/", token: "s3cret", status: http.StatusOK, body: "goroutine"},
+		{name: "named profile", path: "/debug/pprof/heap?debug=1", token: "s3cret", status: http.StatusOK, body: "heap profile"},
+		{name: "cmdline", path: "/debug/pprof/cmdline", token: "s3cret", status: http.StatusOK},
+		{name: "goroutines", path: "/debug/goroutines", token: "s3cret", status: http.StatusOK, body: "TestDebugRoutes"},
+		{name: "recent logs", path: "/debug/logs", token: "s3cret", status: http.StatusOK, body: "kept for the debug routes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if !strings.Contains(w.Body.String(), tt.body) {
+				t.Errorf("body does not contain %q", tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package zin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/divikraf/lumos/zihttpc"
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// ProxyConfig holds configuration for a reverse proxy.
+type ProxyConfig struct {
+	// Timeout is the maximum duration to wait for the upstream response
+	// headers, the body is streamed without deadline (default: 30s).
+	Timeout time.Duration
+
+	// StripPrefix is removed from the request path before it is appended to
+	// the target path.
+	StripPrefix string
+
+	// RewritePath, if set, rewrites the request path (after StripPrefix).
+	RewritePath func(path string) string
+
+	// SetRequestHeaders are set on the upstream request.
+	SetRequestHeaders map[string]string
+
+	// RemoveRequestHeaders are removed from the upstream request.
+	RemoveRequestHeaders []string
+
+	// SetResponseHeaders are set on the response.
+	SetResponseHeaders map[string]string
+
+	// RemoveResponseHeaders are removed from the response.
+	RemoveResponseHeaders []string
+
+	// ModifyResponse, if set, is called with every upstream response.
+	ModifyResponse func(*http.Response) error
+
+	// Retry, if set, retries idempotent requests without a body on transport
+	// errors and retryable status codes.
+	Retry *zihttpc.RetryConfig
+
+	// Transport is the transport used to reach the upstream (default:
+	// http.DefaultTransport).
+	Transport http.RoundTripper
+
+	// FlushInterval is the flush interval used while copying the response
+	// body, a negative value flushes after every write. Streaming responses
+	// (e.g. text/event-stream) are always flushed immediately.
+	FlushInterval time.Duration
+}
+
+// ProxyOption is a functional option to configure a reverse proxy.
+type ProxyOption func(cfg *ProxyConfig)
+
+// WithProxyTimeout sets the maximum duration to wait for the upstream response
+// headers.
+func WithProxyTimeout(d time.Duration) ProxyOption {
+	return func(cfg *ProxyConfig) {
+		cfg.Timeout = d
+	}
+}
+
+// WithStripPrefix removes prefix from the request path.
+func WithStripPrefix(prefix string) ProxyOption {
+	return func(cfg *ProxyConfig) {
+		cfg.StripPrefix = prefix
+	}
+}
+
+// WithRewritePath rewrites the request path.
+func WithRewritePath(f func(path string) string) ProxyOption {
+	return func(cfg *ProxyConfig) {
+		cfg.RewritePath = f
+	}
+}
+
+// WithRequestHeader sets a header on the upstream request.
+func WithRequestHeader(key, value string) ProxyOption {
+	return func(cfg *ProxyConfig) {
+		if cfg.SetRequestHeaders == nil {
+			cfg.SetRequestHeaders = map[string]string{}
+		}
+		cfg.SetRequestHeaders[key] = value
+	}
+}
+
+// WithoutRequestHeaders removes headers from the upstream request.
+func WithoutRequestHeaders(keys ...string) ProxyOption {
+	return func(cfg *ProxyConfig) {
+		cfg.RemoveRequestHeaders = append(cfg.RemoveRequestHeaders, keys...)
+	}
+}
+
+// WithResponseHeader sets a header on the response.
+func WithResponseHeader(key, value string) ProxyOption {
+	return func(cfg *ProxyConfig) {
+		if cfg.SetResponseHeaders == nil {
+			cfg.SetResponseHeaders = map[string]string{}
+		}
+		cfg.SetResponseHeaders[key] = value
+	}
+}
+
+// WithoutResponseHeaders removes headers from the response.
+func WithoutResponseHeaders(keys ...string) ProxyOption {
+	return func(cfg *ProxyConfig) {
+		cfg.RemoveResponseHeaders = append(cfg.RemoveResponseHeaders, keys...)
+	}
+}
+
+// WithModifyResponse sets a function called with every upstream response.
+func WithModifyResponse(f func(*http.Response) error) ProxyOption {
+	return func(cfg *ProxyConfig) {
+		cfg.ModifyResponse = f
+	}
+}
+
+// WithProxyRetry retries idempotent requests without a body.
+func WithProxyRetry(config zihttpc.RetryConfig) ProxyOption {
+	return func(cfg *ProxyConfig) {
+		cfg.Retry = &config
+	}
+}
+
+// WithProxyTransport sets the transport used to reach the upstream.
+func WithProxyTransport(rt http.RoundTripper) ProxyOption {
+	return func(cfg *ProxyConfig) {
+		cfg.Transport = rt
+	}
+}
+
+// WithFlushInterval sets the flush interval used while copying the response
+// body.
+func WithFlushInterval(d time.Duration) ProxyOption {
+	return func(cfg *ProxyConfig) {
+		cfg.FlushInterval = d
+	}
+}
+
+// errUpstreamTimeout is returned when the upstream does not respond in time.
+var errUpstreamTimeout = errors.New("zin: upstream response timeout")
+
+// Proxy returns a handler forwarding requests to target, e.g.
+//
+//	router.Any("/users/*path", zin.Proxy("http://users.internal", zin.WithStripPrefix("/users")))
+//
+// The upstream request carries the trace context and X-Forwarded headers, and
+// every proxied request is recorded in the http_proxy_duration_ms histogram. Upstream failures are answered with 502,
+// or 504 on timeout.
+func Proxy(target string, opts ...ProxyOption) gin.HandlerFunc {
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		panic("zin: invalid proxy target: " + err.Error())
+	}
+
+	cfg := ProxyConfig{Timeout: 30 * time.Second}
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	transport := cfg.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if cfg.Retry != nil {
+		transport = zihttpc.NewRetryTransport(transport, *cfg.Retry)
+	}
+	if cfg.Timeout > 0 {
+		transport = &headerTimeoutTransport{base: transport, timeout: cfg.Timeout}
+	}
+
+	duration := revelio.Must(scope.Duration("http_proxy_duration_ms", "Duration of proxied HTTP requests"))
+	targetAttr := attribute.String("target", targetURL.Host)
+
+	proxy := &httputil.ReverseProxy{
+		Transport:     transport,
+		FlushInterval: cfg.FlushInterval,
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			path := strings.TrimPrefix(pr.In.URL.Path, cfg.StripPrefix)
+			if cfg.RewritePath != nil {
+				path = cfg.RewritePath(path)
+			}
+			if path != "" && !strings.HasPrefix(path, "/") {
+				path = "/" + path
+			}
+			pr.Out.URL.Path = path
+			pr.Out.URL.RawPath = ""
+
+			pr.SetURL(targetURL)
+			pr.SetXForwarded()
+			pr.Out.Host = targetURL.Host
+
+			for _, key := range cfg.RemoveRequestHeaders {
+				pr.Out.Header.Del(key)
+			}
+			for key, value := range cfg.SetRequestHeaders {
+				pr.Out.Header.Set(key, value)
+			}
+			otel.GetTextMapPropagator().Inject(pr.Out.Context(), propagation.HeaderCarrier(pr.Out.Header))
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			for _, key := range cfg.RemoveResponseHeaders {
+				resp.Header.Del(key)
+			}
+			for key, value := range cfg.SetResponseHeaders {
+				resp.Header.Set(key, value)
+			}
+			if cfg.ModifyResponse != nil {
+				return cfg.ModifyResponse(resp)
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			status := http.StatusBadGateway
+			if errors.Is(err, errUpstreamTimeout) {
+				status = http.StatusGatewayTimeout
+			}
+			if !errors.Is(err, context.Canceled) {
+				zilog.FromContext(r.Context()).Error().Err(err).
+					Msgf("failed to proxy request to %s", targetURL.Host)
+			}
+			w.WriteHeader(status)
+		},
+	}
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		proxy.ServeHTTP(c.Writer, c.Request)
+		duration.Record(c.Request.Context(), time.Since(start),
+			targetAttr,
+			attribute.String("status_code", strconv.Itoa(c.Writer.Status())),
+		)
+	}
+}
+
+// headerTimeoutTransport fails requests whose response headers are not
+// received in time, without limiting how long the body takes to stream.
+type headerTimeoutTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *headerTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	timer := time.AfterFunc(t.timeout, cancel)
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if !timer.Stop() && req.Context().Err() == nil {
+		// The timer fired before the headers were received
+		if resp != nil {
+			resp.Body.Close()
+		}
+		cancel()
+		return nil, errUpstreamTimeout
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		// The upgraded body must stay an io.ReadWriteCloser, the connection
+		// is released along with the request context
+		return resp, nil
+	}
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
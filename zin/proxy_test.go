@@ -0,0 +1,97 @@
+package zin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestProxy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Header().Set("X-Internal", "secret")
+		w.Header().Set("X-Path", r.URL.Path)
+		w.Header().Set("X-Tenant", r.Header.Get("X-Tenant"))
+		w.Header().Set("X-Cookie", r.Header.Get("Cookie"))
+		w.Header().Set("X-Forwarded", r.Header.Get("X-Forwarded-Host"))
+		w.Write([]byte("hello"))
+	}))
+	defer upstream.Close()
+
+	r := gin.New()
+	r.Any("/api/*path", Proxy(upstream.URL+"/v1",
+		WithStripPrefix("/api"),
+		WithRequestHeader("X-Tenant", "acme"),
+		WithoutRequestHeaders("Cookie"),
+		WithoutResponseHeaders("X-Internal"),
+		WithProxyTimeout(50*time.Millisecond),
+	))
+
+	// ReverseProxy needs a CloseNotifier, which the response recorder is not
+	bff := httptest.NewServer(r)
+	defer bff.Close()
+
+	t.Run("rewrites the request and response", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, bff.URL+"/api/users/1", nil)
+		req.Host = "bff.local"
+		req.Header.Set("Cookie", "session=1")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK || string(body) != "hello" {
+			t.Fatalf("got %d %q", resp.StatusCode, body)
+		}
+		h := resp.Header
+		if h.Get("X-Path") != "/v1/users/1" {
+			t.Errorf("upstream path = %q, want /v1/users/1", h.Get("X-Path"))
+		}
+		if h.Get("X-Tenant") != "acme" || h.Get("X-Cookie") != "" {
+			t.Errorf("upstream headers tenant=%q cookie=%q", h.Get("X-Tenant"), h.Get("X-Cookie"))
+		}
+		if h.Get("X-Forwarded") != "bff.local" {
+			t.Errorf("X-Forwarded-Host = %q, want bff.local", h.Get("X-Forwarded"))
+		}
+		if h.Get("X-Internal") != "" {
+			t.Error("X-Internal response header should have been removed")
+		}
+	})
+
+	t.Run("times out slow upstreams", func(t *testing.T) {
+		resp, err := http.Get(bff.URL + "/api/slow")
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusGatewayTimeout {
+			t.Errorf("got %d, want 504", resp.StatusCode)
+		}
+	})
+
+	t.Run("unreachable upstream", func(t *testing.T) {
+		r := gin.New()
+		r.Any("/*path", Proxy("http://127.0.0.1:1"))
+		srv := httptest.NewServer(r)
+		defer srv.Close()
+
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadGateway {
+			t.Errorf("got %d, want 502", resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package zin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"syscall"
+
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RecoveryMiddleware recovers the panics of the handlers: the panic is
+// logged with its stack by the request logger, carrying the request ID,
+// recorded on the request span, counted in http_server_panics_total by
+// route, and answered with ErrInternal unless the response is already
+// written, with CausePanic in the HTTP metrics. Panics on a broken
+// connection are logged without response, and http.ErrAbortHandler is let
+// through to net/http.
+func RecoveryMiddleware() gin.HandlerFunc {
+	panics := revelio.Must(scope.Int64Counter(
+		"http_server_panics_total",
+		"Number of panics recovered while serving HTTP requests, by route",
+	))
+	return func(c *gin.Context) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			if r == http.ErrAbortHandler {
+				panic(r)
+			}
+
+			ctx := c.Request.Context()
+			route := c.FullPath()
+			observe.RecordPanic(trace.SpanFromContext(ctx), r)
+			panics.Add(ctx, 1, metric.WithAttributes(attribute.String("http.route", route)))
+			zilog.FromContext(ctx).Error().
+				Str("panic", fmt.Sprint(r)).
+				Str("http.route", route).
+				Str("stack", string(debug.Stack())).
+				Msg("recovered from panic")
+
+			defer c.Set(errorCauseKey, CausePanic)
+			if brokenConnection(r) || c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			AbortWithError(c, ErrInternal)
+		}()
+		c.Next()
+	}
+}
+
+// brokenConnection reports whether the panic r comes from writing to a
+// connection closed by the client.
+func brokenConnection(r any) bool {
+	err, ok := r.(error)
+	return ok && (errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET))
+}
@@ -0,0 +1,70 @@
+package zin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := reveliotest.NewDefaultTestScope(t)
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	var logs bytes.Buffer
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		ctx, span := tracer.Start(c.Request.Context(), "request")
+		defer span.End()
+		logger := zerolog.New(&logs).With().Str("request_id", "req-1").Logger()
+		c.Request = c.Request.WithContext(logger.WithContext(ctx))
+		c.Next()
+	})
+	router.Use(RecoveryMiddleware())
+	router.GET("/orders/:id", func(c *gin.Context) { panic("nil order") })
+	router.GET("/partial", func(c *gin.Context) {
+		c.String(http.StatusOK, "partial")
+		panic("late")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/1", nil))
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), `"code":"internal_error"`) {
+		t.Errorf("response = %d %s, want the internal error envelope", w.Code, w.Body)
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(bytes.SplitN(logs.Bytes(), []byte("\n"), 2)[0], &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["panic"] != "nil order" || entry["http.route"] != "/orders/:id" || entry["request_id"] != "req-1" ||
+		!strings.Contains(entry["stack"].(string), "recovery_test.go") {
+		t.Errorf("log = %v", entry)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Status().Code != codes.Error || len(spans[0].Events()) == 0 {
+		t.Errorf("span = %+v, want the panic recorded", spans)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/partial", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "partial" {
+		t.Errorf("response = %d %q, want the written response kept", w.Code, w.Body)
+	}
+
+	reveliotest.AssertCounterValue(t, s, "http_server_panics_total", 1, attribute.String("http.route", "/orders/:id"))
+	reveliotest.AssertCounterValue(t, s, "http_server_panics_total", 1, attribute.String("http.route", "/partial"))
+}
@@ -0,0 +1,73 @@
+package zin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/divikraf/lumos/zilog"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader is the header carrying the request ID.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength caps the length of request IDs sent by clients.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID of ctx, or an empty string.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestIDMiddleware identifies each request with the X-Request-ID header
+// sent by the client, or a random ID when absent or invalid. The ID is echoed
+// in the response, added to the request logger and span, and available with
+// RequestIDFromContext.
+//
+// It must run after the tracing middleware and before the logging one.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Header(RequestIDHeader, id)
+
+		ctx := WithRequestID(c.Request.Context(), id)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("http.request.id", id))
+		logger := zilog.FromContext(ctx).With().Str("request_id", id).Logger()
+		c.Request = c.Request.WithContext(logger.WithContext(ctx))
+		c.Next()
+	}
+}
+
+// validRequestID reports whether id is a non-empty, bounded string of
+// printable ASCII characters, safe to log and echo.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
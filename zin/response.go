@@ -0,0 +1,91 @@
+package zin
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Envelope is the shape of every response body: the data of successful
+// responses, or the error of failed ones, and metadata.
+type Envelope struct {
+	Data  any        `json:"data"`
+	Meta  Meta       `json:"meta"`
+	Error *ErrorBody `json:"error,omitempty"`
+}
+
+// Meta holds the metadata of a response.
+type Meta struct {
+	RequestID  string      `json:"request_id,omitempty"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
+
+// Pagination describes the page of a paginated response, either offset or
+// cursor based.
+type Pagination struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset,omitempty"`
+	// Total is the total number of items, when known.
+	Total      *int64 `json:"total,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// WithTotal returns a copy of p with the total number of items.
+func (p Pagination) WithTotal(total int64) Pagination {
+	p.Total = &total
+	return p
+}
+
+// PageParams are the pagination parameters of a request.
+type PageParams struct {
+	Limit  int
+	Offset int
+	Cursor string
+}
+
+// ParsePageParams parses the limit, offset and cursor query parameters. The
+// limit defaults to defaultLimit and is capped to maxLimit. On invalid
+// parameters, the request is aborted with ErrBadRequest and false is
+// returned.
+func ParsePageParams(c *gin.Context, defaultLimit, maxLimit int) (PageParams, bool) {
+	p := PageParams{Limit: defaultLimit, Cursor: c.Query("cursor")}
+	for name, dst := range map[string]*int{"limit": &p.Limit, "offset": &p.Offset} {
+		v, ok := c.GetQuery(name)
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || (name == "limit" && n == 0) {
+			AbortWithError(c, ErrBadRequest.WithDetails(map[string]string{"param": name}))
+			return p, false
+		}
+		*dst = n
+	}
+	if maxLimit > 0 && p.Limit > maxLimit {
+		p.Limit = maxLimit
+	}
+	return p, true
+}
+
+// OK writes data in a 200 response envelope.
+func OK(c *gin.Context, data any) {
+	Respond(c, http.StatusOK, data)
+}
+
+// Created writes data in a 201 response envelope.
+func Created(c *gin.Context, data any) {
+	Respond(c, http.StatusCreated, data)
+}
+
+// Paginated writes a page of items in a 200 response envelope.
+func Paginated(c *gin.Context, items any, page Pagination) {
+	c.JSON(http.StatusOK, Envelope{Data: items, Meta: Meta{RequestID: RequestIDFromContext(c.Request.Context()), Pagination: &page}})
+}
+
+// Respond writes data in a response envelope with status.
+func Respond(c *gin.Context, status int, data any) {
+	c.JSON(status, Envelope{Data: data, Meta: Meta{RequestID: RequestIDFromContext(c.Request.Context())}})
+}
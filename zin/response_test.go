@@ -0,0 +1,95 @@
+package zin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestResponseEnvelope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestIDMiddleware())
+	router.GET("/items", func(c *gin.Context) {
+		p, ok := ParsePageParams(c, 20, 100)
+		if !ok {
+			return
+		}
+		Paginated(c, []string{}, Pagination{Limit: p.Limit, Offset: p.Offset, HasMore: true}.WithTotal(250))
+	})
+	router.POST("/items", func(c *gin.Context) {
+		Created(c, map[string]int{"id": 1})
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		want   string
+	}{
+		{
+			name:   "paginated",
+			method: http.MethodGet,
+			path:   "/items?limit=500&offset=100",
+			status: http.StatusOK,
+			want:   `{"data":[],"meta":{"request_id":"req-1","pagination":{"limit":100,"offset":100,"total":250,"has_more":true}}}`,
+		},
+		{
+			name:   "invalid page",
+			method: http.MethodGet,
+			path:   "/items?limit=-1",
+			status: http.StatusBadRequest,
+			want:   `{"data":null,"meta":{"request_id":"req-1"},"error":{"code":"bad_request","message":"Bad request","details":{"param":"limit"}}}`,
+		},
+		{
+			name:   "created",
+			method: http.MethodPost,
+			path:   "/items",
+			status: http.StatusCreated,
+			want:   `{"data":{"id":1},"meta":{"request_id":"req-1"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set(RequestIDHeader, "req-1")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := strings.TrimSpace(w.Body.String()); got != tt.want {
+				t.Errorf("body = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var got string
+	router := gin.New()
+	router.Use(RequestIDMiddleware())
+	router.GET("/", func(c *gin.Context) {
+		got = RequestIDFromContext(c.Request.Context())
+	})
+
+	for _, sent := range []string{"", "has spaces", strings.Repeat("x", 200)} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(RequestIDHeader, sent)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if len(got) != 32 || got == sent {
+			t.Errorf("request ID for %q = %q, want a generated one", sent, got)
+		}
+		if w.Header().Get(RequestIDHeader) != got {
+			t.Errorf("response header = %q, want %q", w.Header().Get(RequestIDHeader), got)
+		}
+	}
+}
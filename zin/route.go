@@ -0,0 +1,19 @@
+package zin
+
+import (
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/gin-gonic/gin"
+)
+
+// RouteMiddleware sets the matched route template of the requests, e.g.
+// "/users/:id", in their context, see observe.WithRoute, for the work they
+// start to be attributed to it, e.g. the SQL comments of zisqlx. Unmatched
+// requests have no route.
+func RouteMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if route := c.FullPath(); route != "" {
+			c.Request = c.Request.WithContext(observe.WithRoute(c.Request.Context(), route))
+		}
+		c.Next()
+	}
+}
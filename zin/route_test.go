@@ -0,0 +1,25 @@
+package zin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRouteMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	var route string
+	router.Use(RouteMiddleware())
+	router.GET("/users/:id", func(c *gin.Context) {
+		route = observe.RouteFromContext(c.Request.Context())
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	if route != "/users/:id" {
+		t.Errorf("route = %q, want the route template", route)
+	}
+}
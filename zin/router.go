@@ -7,27 +7,76 @@ import (
 
 	"github.com/divikraf/lumos/ziconf"
 	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zin/health"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/metric"
 	"go.uber.org/fx"
 )
 
 type InitRouterParams struct {
 	fx.In
 	Config    ziconf.Config
-	SkipPaths []string `group:"http-metrics-skip-paths"`
+	SkipPaths []string          `group:"http-metrics-skip-paths"`
+	Enrichers []MetricsEnricher `group:"http-metrics-enrichers"`
+	Routes    []RouteRegistrar  `group:"http-route-registrars"`
+	// Maintenance is the maintenance mode of the router, if provided.
+	Maintenance *Maintenance `optional:"true"`
+	// Middlewares are applied after the built-in ones, in no particular
+	// order, see zinfx.AddMiddleware.
+	Middlewares []gin.HandlerFunc `group:"http-middlewares"`
 }
 
+// RegiterRouter returns the router of the main server, see NewMainRouter,
+// panicking if its configuration is invalid.
+//
+// Deprecated: use NewMainRouter, returning the error.
 func RegiterRouter(params InitRouterParams) *gin.Engine {
+	router, err := NewMainRouter(params)
+	if err != nil {
+		panic(err)
+	}
+	return router
+}
+
+// NewMainRouter returns the router of the main server, with the built-in
+// middlewares, followed by the middlewares and the routes of params. It
+// fails if the client IP configuration is invalid.
+func NewMainRouter(params InitRouterParams) (*gin.Engine, error) {
 	router := gin.New()
+	if err := configureClientIP(router, params.Config); err != nil {
+		return nil, err
+	}
+	if params.Config.GetTelemetry().Tracing.Debug.Enabled {
+		router.Use(DebugMiddleware())
+	}
 	router.Use(otelgin.Middleware(params.Config.GetService().Name))
+	router.Use(RequestIDMiddleware())
+	router.Use(RouteMiddleware())
+	router.Use(ClientIPMiddleware())
 	router.Use(zilog.HTTPLogMiddleware(zilog.WithLogHTTPRequest(), zilog.WithLogHTTPResponse()))
-	// Use skip paths from FX groups
-	router.Use(httpMetricsMiddlewareWithSkipPaths(params.SkipPaths))
-	router.Use(gin.Recovery())
+	// Use skip paths and enrichers from FX groups
+	var metrics HTTPMetricsOptions
+	if c, ok := params.Config.(httpMetricsConfig); ok {
+		metrics = c.GetHttpMetrics()
+	}
+	router.Use(httpMetricsMiddlewareWithSkipPaths(params.SkipPaths, params.Enrichers, metrics))
+	router.Use(RecoveryMiddleware())
+	router.Use(ErrorMiddleware())
+	if params.Maintenance != nil {
+		router.Use(params.Maintenance.Middleware())
+	}
+	if c, ok := params.Config.(resilienceConfig); ok {
+		router.Use(ResilienceMiddleware(c.GetResilience()))
+	}
+	router.Use(params.Middlewares...)
 
-	return router
+	for _, r := range params.Routes {
+		r.RegisterRoutes(router)
+	}
+
+	return router, nil
 }
 
 type HttpServerParams struct {
@@ -37,24 +86,70 @@ type HttpServerParams struct {
 	Logger *zerolog.Logger
 	Config ziconf.Config
 	Router *gin.Engine
+	Health *health.Health `optional:"true"`
 }
 
+// StartHttpServer starts the main server, see RunHTTPServer, panicking if its
+// configuration is invalid.
+//
+// Deprecated: use RunHTTPServer, returning the error.
 func StartHttpServer(params HttpServerParams) {
+	if err := RunHTTPServer(params); err != nil {
+		panic(err)
+	}
+}
+
+// RunHTTPServer serves the router on the HTTP port with the lifecycle, and
+// shuts it down gracefully, draining the health checks if provided, on stop.
+// It fails if the server configuration is invalid.
+func RunHTTPServer(params HttpServerParams) error {
+	server := DefaultServerConfig()
+	if c, ok := params.Config.(serverConfig); ok {
+		server = c.GetHttpServer()
+	}
+	shutdown := DefaultShutdownConfig()
+	if c, ok := params.Config.(shutdownConfig); ok {
+		shutdown = c.GetShutdown()
+	}
+	var d drainer
+	if params.Health != nil {
+		d = params.Health
+	}
+	return startServer(params.LC, params.Logger, "", params.Config.GetHttpPort(), params.Router, server, shutdown, d)
+}
+
+// startServer starts serving router on addr with the lifecycle, and shuts it
+// down gracefully on stop. name is the name of the server in its metrics,
+// empty for the main server.
+func startServer(lc fx.Lifecycle, logger *zerolog.Logger, name, addr string, router *gin.Engine, server ServerConfig, shutdown ShutdownConfig, d drainer) error {
+	conns := newConnTracker(name)
 	srv := &http.Server{
-		Addr:    params.Config.GetHttpPort(),
-		Handler: params.Router.Handler(),
+		Addr:      addr,
+		Handler:   router.Handler(),
+		ConnState: conns.track,
+	}
+	if err := configureServer(srv, server); err != nil {
+		return err
 	}
 
-	params.LC.Append(fx.StartHook(func() error {
+	var gauge metric.Registration
+	lc.Append(fx.StartHook(func() error {
+		var err error
+		if gauge, err = conns.observe(); err != nil {
+			return err
+		}
 		go func() {
-			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			if err := serve(srv, server.TLS.Enabled); err != nil && err != http.ErrServerClosed {
 				log.Fatalf("Could not listen on %s: %v\n", srv.Addr, err)
 			}
 		}()
 		return nil
 	}))
 
-	params.LC.Append(fx.StopHook(func(ctx context.Context) {
-		srv.Shutdown(ctx)
+	lc.Append(fx.StopHook(func(ctx context.Context) error {
+		defer gauge.Unregister()
+		return gracefulShutdown(ctx, srv, shutdown, conns, d, logger)
 	}))
+
+	return nil
 }
@@ -0,0 +1,18 @@
+package zin
+
+import "github.com/gin-gonic/gin"
+
+// RouteRegistrar registers routes on the router. Registrars provided in the
+// "http-route-registrars" fx group, see zinfx.AddRoutes, are invoked by
+// NewMainRouter once the middlewares are set up.
+type RouteRegistrar interface {
+	RegisterRoutes(router gin.IRouter)
+}
+
+// RouteRegistrarFunc is a function implementing RouteRegistrar.
+type RouteRegistrarFunc func(router gin.IRouter)
+
+// RegisterRoutes calls f(router).
+func (f RouteRegistrarFunc) RegisterRoutes(router gin.IRouter) {
+	f(router)
+}
@@ -0,0 +1,237 @@
+package zin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// ServerConfig configures the HTTP server started by RunHTTPServer.
+//
+// Zero timeouts use the defaults of DefaultServerConfig, negative ones
+// disable the timeout.
+type ServerConfig struct {
+	// ReadTimeout is the maximum duration to read a request, body included.
+	ReadTimeout time.Duration `json:"read_timeout" yaml:"read_timeout"`
+	// ReadHeaderTimeout is the maximum duration to read request headers.
+	ReadHeaderTimeout time.Duration `json:"read_header_timeout" yaml:"read_header_timeout"`
+	// WriteTimeout is the maximum duration from the end of the request
+	// headers to the end of the response. It also cuts streamed responses,
+	// so it is disabled by default.
+	WriteTimeout time.Duration `json:"write_timeout" yaml:"write_timeout"`
+	// IdleTimeout is the maximum duration a keep-alive connection waits for
+	// the next request.
+	IdleTimeout time.Duration `json:"idle_timeout" yaml:"idle_timeout"`
+	// MaxHeaderBytes caps the size of the request headers.
+	MaxHeaderBytes int `json:"max_header_bytes" yaml:"max_header_bytes"`
+
+	TLS   TLSConfig   `json:"tls" yaml:"tls"`
+	HTTP2 HTTP2Config `json:"http2" yaml:"http2"`
+}
+
+// DefaultServerConfig returns the default HTTP server configuration.
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		ReadTimeout:       60 * time.Second,
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      -1,
+		IdleTimeout:       120 * time.Second,
+		MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+	}
+}
+
+// TLSConfig configures TLS, with either a certificate and key pair or
+// certificates obtained from Let's Encrypt.
+type TLSConfig struct {
+	Enabled  bool   `json:"enabled" yaml:"enabled"`
+	CertFile string `json:"cert_file" yaml:"cert_file"`
+	KeyFile  string `json:"key_file" yaml:"key_file"`
+
+	// ClientCAFile holds the PEM encoded CAs used to verify client
+	// certificates, for mTLS.
+	ClientCAFile string `json:"client_ca_file" yaml:"client_ca_file"`
+	// ClientAuth is the client certificate policy: "none", "request",
+	// "require", "verify_if_given" or "require_and_verify" (default:
+	// "require_and_verify" when ClientCAFile is set, "none" otherwise).
+	ClientAuth string `json:"client_auth" yaml:"client_auth"`
+
+	// MinVersion is the minimum TLS version, "1.2" or "1.3" (default: "1.2").
+	MinVersion string `json:"min_version" yaml:"min_version"`
+
+	Autocert AutocertConfig `json:"autocert" yaml:"autocert"`
+}
+
+// AutocertConfig configures certificates obtained from Let's Encrypt, using
+// the TLS-ALPN-01 challenge so no plain HTTP listener is needed.
+type AutocertConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Domains are the host names certificates are requested for.
+	Domains []string `json:"domains" yaml:"domains"`
+	// CacheDir stores the certificates across restarts.
+	CacheDir string `json:"cache_dir" yaml:"cache_dir"`
+	// Email is the contact address of the ACME account.
+	Email string `json:"email" yaml:"email"`
+}
+
+// HTTP2Config configures HTTP/2. HTTP/2 is negotiated over TLS unless
+// disabled, H2C serves it over plain text connections as well.
+type HTTP2Config struct {
+	Disabled             bool   `json:"disabled" yaml:"disabled"`
+	H2C                  bool   `json:"h2c" yaml:"h2c"`
+	MaxConcurrentStreams uint32 `json:"max_concurrent_streams" yaml:"max_concurrent_streams"`
+}
+
+// serverConfig is implemented by configs customizing the HTTP server.
+type serverConfig interface {
+	GetHttpServer() ServerConfig
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":               tls.NoClientCert,
+	"request":            tls.RequestClientCert,
+	"require":            tls.RequireAnyClientCert,
+	"verify_if_given":    tls.VerifyClientCertIfGiven,
+	"require_and_verify": tls.RequireAndVerifyClientCert,
+}
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Build returns the *tls.Config described by c.
+func (c TLSConfig) Build() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.MinVersion != "" {
+		version, ok := tlsVersions[c.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("zin: unsupported TLS min version %q", c.MinVersion)
+		}
+		config.MinVersion = version
+	}
+
+	switch {
+	case c.Autocert.Enabled:
+		if len(c.Autocert.Domains) == 0 {
+			return nil, errors.New("zin: autocert requires at least one domain")
+		}
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(c.Autocert.Domains...),
+			Email:      c.Autocert.Email,
+		}
+		if c.Autocert.CacheDir != "" {
+			m.Cache = autocert.DirCache(c.Autocert.CacheDir)
+		}
+		config.GetCertificate = m.GetCertificate
+		// The challenge protocol is negotiated along with the regular ones
+		config.NextProtos = []string{"h2", "http/1.1", acme.ALPNProto}
+	case c.CertFile != "" && c.KeyFile != "":
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("zin: failed to load TLS certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	default:
+		return nil, errors.New("zin: TLS requires a cert and key file, or autocert")
+	}
+
+	clientAuth := c.ClientAuth
+	if c.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("zin: failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("zin: no certificate found in %s", c.ClientCAFile)
+		}
+		config.ClientCAs = pool
+		if clientAuth == "" {
+			clientAuth = "require_and_verify"
+		}
+	}
+	if clientAuth != "" {
+		authType, ok := clientAuthTypes[clientAuth]
+		if !ok {
+			return nil, fmt.Errorf("zin: unsupported TLS client auth %q", clientAuth)
+		}
+		config.ClientAuth = authType
+	}
+
+	return config, nil
+}
+
+// configureServer applies the timeouts, TLS and HTTP/2 configuration to srv.
+func configureServer(srv *http.Server, config ServerConfig) error {
+	defaults := DefaultServerConfig()
+	srv.ReadTimeout = serverTimeout(config.ReadTimeout, defaults.ReadTimeout)
+	srv.ReadHeaderTimeout = serverTimeout(config.ReadHeaderTimeout, defaults.ReadHeaderTimeout)
+	srv.WriteTimeout = serverTimeout(config.WriteTimeout, defaults.WriteTimeout)
+	srv.IdleTimeout = serverTimeout(config.IdleTimeout, defaults.IdleTimeout)
+	srv.MaxHeaderBytes = config.MaxHeaderBytes
+	if srv.MaxHeaderBytes <= 0 {
+		srv.MaxHeaderBytes = defaults.MaxHeaderBytes
+	}
+
+	if config.TLS.Enabled {
+		tlsConfig, err := config.TLS.Build()
+		if err != nil {
+			return err
+		}
+		srv.TLSConfig = tlsConfig
+	}
+
+	if config.HTTP2.Disabled {
+		// A non-nil empty map disables HTTP/2 over TLS
+		srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		if srv.TLSConfig != nil {
+			srv.TLSConfig.NextProtos = slices.DeleteFunc(srv.TLSConfig.NextProtos, func(p string) bool { return p == "h2" })
+		}
+		return nil
+	}
+
+	h2 := &http2.Server{MaxConcurrentStreams: config.HTTP2.MaxConcurrentStreams}
+	if !config.TLS.Enabled {
+		// ConfigureServer would set a TLS config on plain HTTP servers
+		if config.HTTP2.H2C {
+			srv.Handler = h2c.NewHandler(srv.Handler, h2)
+		}
+		return nil
+	}
+	if err := http2.ConfigureServer(srv, h2); err != nil {
+		return fmt.Errorf("zin: failed to configure HTTP/2: %w", err)
+	}
+	return nil
+}
+
+// serverTimeout returns d, def when d is zero, or no timeout when the result
+// is negative.
+func serverTimeout(d, def time.Duration) time.Duration {
+	if d == 0 {
+		d = def
+	}
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// serve runs srv with TLS when enabled, until it is shut down.
+func serve(srv *http.Server, tlsEnabled bool) error {
+	if tlsEnabled {
+		// Certificates are already part of the TLS config
+		return srv.ListenAndServeTLS("", "")
+	}
+	return srv.ListenAndServe()
+}
@@ -0,0 +1,214 @@
+package zin
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1 and returns
+// the cert and key file paths.
+func writeTestCert(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "lumos test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return certFile, keyFile
+}
+
+func TestTLSConfigBuild(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+
+	tests := []struct {
+		name    string
+		config  TLSConfig
+		wantErr bool
+	}{
+		{name: "cert and key", config: TLSConfig{CertFile: certFile, KeyFile: keyFile}},
+		{name: "no certificate", config: TLSConfig{}, wantErr: true},
+		{name: "missing file", config: TLSConfig{CertFile: "missing.pem", KeyFile: keyFile}, wantErr: true},
+		{name: "unknown version", config: TLSConfig{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.0"}, wantErr: true},
+		{name: "unknown client auth", config: TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientAuth: "maybe"}, wantErr: true},
+		{name: "autocert without domains", config: TLSConfig{Autocert: AutocertConfig{Enabled: true}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.config.Build()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Build() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("client CA defaults to mTLS", func(t *testing.T) {
+		config, err := TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile, MinVersion: "1.3"}.Build()
+		if err != nil {
+			t.Fatalf("Build() error = %v", err)
+		}
+		if config.ClientAuth != tls.RequireAndVerifyClientCert || config.MinVersion != tls.VersionTLS13 {
+			t.Errorf("ClientAuth = %v, MinVersion = %x", config.ClientAuth, config.MinVersion)
+		}
+	})
+}
+
+func TestConfigureServerHTTP2(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+
+	for _, disabled := range []bool{false, true} {
+		srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.Proto))
+		})}
+		err := configureServer(srv, ServerConfig{
+			TLS:   TLSConfig{Enabled: true, CertFile: certFile, KeyFile: keyFile},
+			HTTP2: HTTP2Config{Disabled: disabled},
+		})
+		if err != nil {
+			t.Fatalf("configureServer() error = %v", err)
+		}
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go srv.ServeTLS(ln, "", "")
+
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			ForceAttemptHTTP2: true,
+		}}
+		resp, err := client.Get("https://" + ln.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		resp.Body.Close()
+		srv.Close()
+
+		if want := map[bool]int{false: 2, true: 1}[disabled]; resp.ProtoMajor != want {
+			t.Errorf("HTTP2 disabled=%v: got HTTP/%d, want HTTP/%d", disabled, resp.ProtoMajor, want)
+		}
+	}
+}
+
+func TestConfigureServerAutocertProtocols(t *testing.T) {
+	for _, tt := range []struct {
+		disabled bool
+		want     []string
+	}{
+		{disabled: false, want: []string{"h2", "http/1.1", "acme-tls/1"}},
+		{disabled: true, want: []string{"http/1.1", "acme-tls/1"}},
+	} {
+		srv := &http.Server{}
+		err := configureServer(srv, ServerConfig{
+			TLS:   TLSConfig{Enabled: true, Autocert: AutocertConfig{Enabled: true, Domains: []string{"example.com"}}},
+			HTTP2: HTTP2Config{Disabled: tt.disabled},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(srv.TLSConfig.NextProtos, tt.want) {
+			t.Errorf("HTTP/2 disabled: %v, NextProtos = %q, want %q", tt.disabled, srv.TLSConfig.NextProtos, tt.want)
+		}
+	}
+}
+
+func TestServeDefaultConfig(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	srv := &http.Server{Addr: addr, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})}
+	if err := configureServer(srv, DefaultServerConfig()); err != nil {
+		t.Fatalf("configureServer() error = %v", err)
+	}
+	if srv.TLSConfig != nil {
+		t.Error("TLS config set with TLS disabled")
+	}
+	errs := make(chan error, 1)
+	go func() { errs <- serve(srv, false) }()
+	defer srv.Close()
+
+	var resp *http.Response
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		select {
+		case err := <-errs:
+			t.Fatalf("serve() error = %v", err)
+		default:
+		}
+		if resp, err = http.Get("http://" + addr); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 1 {
+		t.Errorf("got %d over HTTP/%d, want 200 over HTTP/1", resp.StatusCode, resp.ProtoMajor)
+	}
+}
+
+func TestConfigureServerTimeouts(t *testing.T) {
+	srv := &http.Server{}
+	err := configureServer(srv, ServerConfig{
+		ReadHeaderTimeout: 2 * time.Second,
+		IdleTimeout:       -1,
+	})
+	if err != nil {
+		t.Fatalf("configureServer() error = %v", err)
+	}
+
+	defaults := DefaultServerConfig()
+	if srv.ReadHeaderTimeout != 2*time.Second {
+		t.Errorf("ReadHeaderTimeout = %v, want 2s", srv.ReadHeaderTimeout)
+	}
+	if srv.ReadTimeout != defaults.ReadTimeout {
+		t.Errorf("ReadTimeout = %v, want the default %v", srv.ReadTimeout, defaults.ReadTimeout)
+	}
+	if srv.IdleTimeout != 0 || srv.WriteTimeout != 0 {
+		t.Errorf("IdleTimeout = %v, WriteTimeout = %v, want both disabled", srv.IdleTimeout, srv.WriteTimeout)
+	}
+	if srv.MaxHeaderBytes != http.DefaultMaxHeaderBytes {
+		t.Errorf("MaxHeaderBytes = %d, want %d", srv.MaxHeaderBytes, http.DefaultMaxHeaderBytes)
+	}
+}
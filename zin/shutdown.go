@@ -0,0 +1,81 @@
+package zin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// ShutdownConfig configures how the HTTP server drains on shutdown.
+type ShutdownConfig struct {
+	// Timeout is the maximum duration to wait for in-flight requests once the
+	// listener is closed, remaining connections are then closed forcibly
+	// (default: 10s). It is also bounded by the fx stop timeout.
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+
+	// FailReadiness makes the readiness endpoint fail as soon as shutdown
+	// starts, when health checks are registered.
+	FailReadiness bool `json:"fail_readiness" yaml:"fail_readiness"`
+
+	// DrainDelay is how long the server keeps accepting requests after
+	// readiness started failing, so load balancers can stop routing traffic
+	// to it first.
+	DrainDelay time.Duration `json:"drain_delay" yaml:"drain_delay"`
+}
+
+// DefaultShutdownConfig returns the default shutdown configuration.
+func DefaultShutdownConfig() ShutdownConfig {
+	return ShutdownConfig{
+		Timeout: 10 * time.Second,
+	}
+}
+
+// shutdownConfig is implemented by configs customizing the server shutdown.
+type shutdownConfig interface {
+	GetShutdown() ShutdownConfig
+}
+
+// drainer is implemented by *health.Health.
+type drainer interface {
+	Drain()
+}
+
+// gracefulShutdown drains srv: readiness fails first when configured, then
+// the listener is closed and in-flight requests are waited for up to the
+// configured timeout. Connections still open past the deadline are logged and
+// closed.
+func gracefulShutdown(ctx context.Context, srv *http.Server, config ShutdownConfig, conns *connTracker, d drainer, logger *zerolog.Logger) error {
+	if config.FailReadiness && d != nil {
+		d.Drain()
+		if config.DrainDelay > 0 {
+			logger.Info().Dur("delay", config.DrainDelay).Msg("readiness failing, draining HTTP server")
+			timer := time.NewTimer(config.DrainDelay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+			}
+		}
+	}
+
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
+
+	err := srv.Shutdown(ctx)
+	if err == nil {
+		return nil
+	}
+
+	active, idle := conns.count()
+	logger.Warn().Err(err).
+		Int("active_connections", active).
+		Int("idle_connections", idle).
+		Msg("HTTP server shutdown deadline exceeded, closing remaining connections")
+	return errors.Join(err, srv.Close())
+}
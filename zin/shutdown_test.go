@@ -0,0 +1,87 @@
+package zin
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+type drainerFunc func()
+
+func (f drainerFunc) Drain() { f() }
+
+func TestGracefulShutdown(t *testing.T) {
+	start := func(t *testing.T, handler http.HandlerFunc) (*http.Server, *connTracker, string) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		conns := newConnTracker("")
+		srv := &http.Server{Handler: handler, ConnState: conns.track}
+		go srv.Serve(ln)
+		return srv, conns, "http://" + ln.Addr().String()
+	}
+	logger := zerolog.Nop()
+
+	t.Run("waits for in-flight requests", func(t *testing.T) {
+		srv, conns, url := start(t, func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(100 * time.Millisecond)
+		})
+
+		done := make(chan int, 1)
+		go func() {
+			resp, err := http.Get(url)
+			if err != nil {
+				done <- 0
+				return
+			}
+			resp.Body.Close()
+			done <- resp.StatusCode
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		drained := false
+		config := ShutdownConfig{Timeout: time.Second, FailReadiness: true, DrainDelay: 10 * time.Millisecond}
+		if err := gracefulShutdown(context.Background(), srv, config, conns, drainerFunc(func() { drained = true }), &logger); err != nil {
+			t.Errorf("gracefulShutdown() = %v", err)
+		}
+		if !drained {
+			t.Error("readiness should fail before shutting down")
+		}
+		if status := <-done; status != http.StatusOK {
+			t.Errorf("in-flight request got %d, want 200", status)
+		}
+	})
+
+	t.Run("closes connections past the deadline", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		srv, conns, url := start(t, func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		})
+
+		go func() {
+			if resp, err := http.Get(url); err == nil {
+				resp.Body.Close()
+			}
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		if active, _ := conns.count(); active != 1 {
+			t.Errorf("active connections = %d, want 1", active)
+		}
+
+		start := time.Now()
+		err := gracefulShutdown(context.Background(), srv, ShutdownConfig{Timeout: 50 * time.Millisecond}, conns, nil, &logger)
+		if err == nil {
+			t.Error("gracefulShutdown() should report the exceeded deadline")
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("gracefulShutdown() took %v", elapsed)
+		}
+	})
+}
@@ -0,0 +1,205 @@
+package zin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StaticOptions configures ServeStatic and ServeSPA.
+type StaticOptions struct {
+	// Prefix is the path the files are served at (default: "/"). Serving at
+	// "/" needs the *gin.Engine, files being served for unmatched routes.
+	Prefix string
+
+	// Index is the file served for directories, and for the client side
+	// routes of ServeSPA (default: "index.html"). It is revalidated on every
+	// request.
+	Index string
+
+	// MaxAge is how long clients cache the other files (default: 1h).
+	MaxAge time.Duration
+
+	// ImmutablePrefixes are path prefixes of fingerprinted files, e.g.
+	// "assets/", cached for a year without revalidation.
+	ImmutablePrefixes []string
+
+	// ExcludedPrefixes are path prefixes never falling back to the index
+	// with ServeSPA, e.g. "api/".
+	ExcludedPrefixes []string
+}
+
+// ServeStatic serves the files of fsys, e.g. an embed.FS, with Cache-Control
+// and ETag headers. Files with a gzip pre-compressed variant, e.g.
+// "app.js.gz", are served compressed to clients accepting it. Missing files
+// are answered with ErrNotFound.
+func ServeStatic(router gin.IRouter, fsys fs.FS, opts StaticOptions) {
+	s := newStaticServer(fsys, opts, false)
+	mountStatic(router, s.opts.Prefix, s.serve)
+}
+
+// ServeSPA serves a single page application like ServeStatic, answering the
+// missing paths without extension, which are client side routes, with the
+// index.
+func ServeSPA(router gin.IRouter, fsys fs.FS, opts StaticOptions) {
+	s := newStaticServer(fsys, opts, true)
+	mountStatic(router, s.opts.Prefix, s.serve)
+}
+
+func mountStatic(router gin.IRouter, prefix string, h gin.HandlerFunc) {
+	if prefix == "/" {
+		engine, ok := router.(*gin.Engine)
+		if !ok {
+			panic("zin: serving files at / needs the *gin.Engine")
+		}
+		engine.NoRoute(h)
+		return
+	}
+	router.GET(prefix+"/*filepath", h)
+	router.HEAD(prefix+"/*filepath", h)
+}
+
+// staticFile is a file loaded in memory, with its ETag.
+type staticFile struct {
+	data    []byte
+	etag    string
+	modTime time.Time
+	size    int64
+}
+
+type staticServer struct {
+	fsys fs.FS
+	opts StaticOptions
+	spa  bool
+
+	mu    sync.RWMutex
+	files map[string]*staticFile
+}
+
+func newStaticServer(fsys fs.FS, opts StaticOptions, spa bool) *staticServer {
+	opts.Prefix = "/" + strings.Trim(opts.Prefix, "/")
+	if opts.Index == "" {
+		opts.Index = "index.html"
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = time.Hour
+	}
+	return &staticServer{fsys: fsys, opts: opts, spa: spa, files: map[string]*staticFile{}}
+}
+
+func (s *staticServer) serve(c *gin.Context) {
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		AbortWithError(c, ErrNotFound)
+		return
+	}
+	p := c.Param("filepath")
+	if p == "" {
+		p = strings.TrimPrefix(c.Request.URL.Path, s.opts.Prefix)
+	}
+	name := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if name == "" {
+		name = s.opts.Index
+	}
+
+	if info, err := fs.Stat(s.fsys, name); err == nil && info.IsDir() {
+		name = path.Join(name, s.opts.Index)
+	}
+	if _, err := fs.Stat(s.fsys, name); err != nil {
+		if !s.spa || path.Ext(name) != "" || s.excluded(name) {
+			AbortWithError(c, ErrNotFound)
+			return
+		}
+		name = s.opts.Index
+	}
+
+	served, encoding := name, ""
+	if acceptedEncoding(c.GetHeader("Accept-Encoding")) == "gzip" {
+		if _, err := fs.Stat(s.fsys, name+".gz"); err == nil {
+			served, encoding = name+".gz", "gzip"
+		}
+	}
+	f, err := s.load(served)
+	if err != nil {
+		AbortWithError(c, err)
+		return
+	}
+
+	h := c.Writer.Header()
+	h.Set("Cache-Control", s.cacheControl(name))
+	h.Set("ETag", f.etag)
+	h.Add("Vary", "Accept-Encoding")
+	if encoding != "" {
+		h.Set("Content-Encoding", encoding)
+	}
+	// ServeContent handles the conditional and range requests, and sets
+	// Content-Type from the uncompressed name
+	http.ServeContent(c.Writer, c.Request, name, f.modTime, bytes.NewReader(f.data))
+}
+
+func (s *staticServer) excluded(name string) bool {
+	for _, prefix := range s.opts.ExcludedPrefixes {
+		if strings.HasPrefix(name, strings.TrimPrefix(prefix, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *staticServer) cacheControl(name string) string {
+	if path.Base(name) == s.opts.Index {
+		return "no-cache"
+	}
+	for _, prefix := range s.opts.ImmutablePrefixes {
+		if strings.HasPrefix(name, strings.TrimPrefix(prefix, "/")) {
+			return "public, max-age=31536000, immutable"
+		}
+	}
+	return "public, max-age=" + strconv.Itoa(int(s.opts.MaxAge.Seconds()))
+}
+
+// load returns the file name, loading it when it isn't cached or changed
+// since, e.g. on an os.DirFS.
+func (s *staticServer) load(name string) (*staticFile, error) {
+	info, err := fs.Stat(s.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	f, ok := s.files[name]
+	s.mu.RUnlock()
+	if ok && f.size == info.Size() && f.modTime.Equal(info.ModTime()) {
+		return f, nil
+	}
+
+	file, err := s.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	f = &staticFile{
+		data:    data,
+		etag:    `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+	}
+
+	s.mu.Lock()
+	s.files[name] = f
+	s.mu.Unlock()
+	return f, nil
+}
@@ -0,0 +1,123 @@
+package zin
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+)
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestServeSPA(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	fsys := fstest.MapFS{
+		"index.html":           {Data: []byte("<html>app</html>")},
+		"favicon.ico":          {Data: []byte("icon")},
+		"assets/app.123.js":    {Data: []byte("console.log('app')")},
+		"assets/app.123.js.gz": {Data: gzipped(t, "console.log('app')")},
+	}
+	r := gin.New()
+	r.Use(ErrorMiddleware())
+	r.GET("/api/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	ServeSPA(r, fsys, StaticOptions{ImmutablePrefixes: []string{"assets/"}, ExcludedPrefixes: []string{"/api/"}})
+
+	do := func(path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		path         string
+		status       int
+		body         string
+		cacheControl string
+	}{
+		{path: "/", status: http.StatusOK, body: "<html>app</html>", cacheControl: "no-cache"},
+		{path: "/orders/42", status: http.StatusOK, body: "<html>app</html>", cacheControl: "no-cache"},
+		{path: "/favicon.ico", status: http.StatusOK, body: "icon", cacheControl: "public, max-age=3600"},
+		{path: "/assets/app.123.js", status: http.StatusOK, body: "console.log('app')", cacheControl: "public, max-age=31536000, immutable"},
+		{path: "/assets/missing.js", status: http.StatusNotFound},
+		{path: "/api/unknown", status: http.StatusNotFound},
+		{path: "/api/ping", status: http.StatusOK, body: "pong"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := do(tt.path)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+			if got := w.Header().Get("Cache-Control"); got != tt.cacheControl {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.cacheControl)
+			}
+		})
+	}
+
+	t.Run("precompressed", func(t *testing.T) {
+		w := do("/assets/app.123.js", "Accept-Encoding", "gzip")
+		if w.Header().Get("Content-Encoding") != "gzip" {
+			t.Fatalf("Content-Encoding = %q, want gzip", w.Header().Get("Content-Encoding"))
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "text/javascript; charset=utf-8" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if !bytes.Equal(w.Body.Bytes(), fsys["assets/app.123.js.gz"].Data) {
+			t.Error("body is not the pre-compressed file")
+		}
+	})
+
+	t.Run("etag", func(t *testing.T) {
+		etag := do("/favicon.ico").Header().Get("ETag")
+		if etag == "" {
+			t.Fatal("missing ETag")
+		}
+		if w := do("/favicon.ico", "If-None-Match", etag); w.Code != http.StatusNotModified {
+			t.Errorf("conditional request = %d, want 304", w.Code)
+		}
+	})
+}
+
+func TestServeStatic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ErrorMiddleware())
+	ServeStatic(r, fstest.MapFS{
+		"docs/index.html": {Data: []byte("docs")},
+	}, StaticOptions{Prefix: "/static"})
+
+	for path, want := range map[string]int{
+		"/static/docs/":            http.StatusOK,
+		"/static/docs/index.html":  http.StatusOK,
+		"/static/missing":          http.StatusNotFound,
+		"/static/../../etc/passwd": http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != want {
+			t.Errorf("GET %s = %d, want %d", path, w.Code, want)
+		}
+	}
+}
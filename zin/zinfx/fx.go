@@ -1,13 +1,16 @@
 package zinfx
 
 import (
+	"github.com/divikraf/lumos/ziconf"
 	"github.com/divikraf/lumos/zin"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
 	"go.uber.org/fx"
 )
 
-var Provider = fx.Provide(zin.RegiterRouter)
+var Provider = fx.Provide(zin.NewMainRouter)
 
-var Invoker = fx.Invoke(zin.StartHttpServer)
+var Invoker = fx.Invoke(zin.RunHTTPServer)
 
 // SkipPathProvider provides skip paths for HTTP metrics
 type SkipPathProvider struct {
@@ -23,3 +26,114 @@ func AddSkipPaths(paths ...string) fx.Option {
 		}
 	})
 }
+
+// MetricsEnricherProvider provides an enricher for HTTP metrics
+type MetricsEnricherProvider struct {
+	fx.Out
+	Enricher zin.MetricsEnricher `group:"http-metrics-enrichers"`
+}
+
+// AddMetricsEnricher adds an enricher appending extra attributes to HTTP
+// metrics, at most zin.DefaultMaxExtraAttributes of them are recorded
+func AddMetricsEnricher(enricher zin.MetricsEnricher) fx.Option {
+	return fx.Provide(func() MetricsEnricherProvider {
+		return MetricsEnricherProvider{
+			Enricher: enricher,
+		}
+	})
+}
+
+// DebugRoutesInvoker mounts the pprof and expvar debug endpoints when enabled
+// by the config, see zin.DebugRoutesConfig
+var DebugRoutesInvoker = fx.Invoke(zin.RegisterDebugRoutes)
+
+// AddMiddleware annotates a constructor of a gin.HandlerFunc, to provide it
+// in the middlewares of the main router, applied after the built-in ones
+func AddMiddleware(constructor any) fx.Option {
+	return fx.Provide(fx.Annotate(constructor, fx.ResultTags(`group:"http-middlewares"`)))
+}
+
+// MaintenanceProvider provides the maintenance mode of the router, configured
+// by the config, see zin.MaintenanceConfig
+var MaintenanceProvider = fx.Provide(zin.NewMaintenanceFromConfig)
+
+// MaintenanceRoutes mounts the maintenance admin endpoint on the additional
+// server name, or on the main router when empty
+func MaintenanceRoutes(server string) fx.Option {
+	return fx.Provide(fx.Annotate(
+		func(m *zin.Maintenance) zin.RouteRegistrar { return m },
+		fx.ResultTags(RoutesGroup(server)),
+	))
+}
+
+// AddRoutes adds registrars of routes, registered once the router middlewares
+// are set up
+func AddRoutes(registrars ...zin.RouteRegistrar) fx.Option {
+	var opts []fx.Option
+	for _, r := range registrars {
+		opts = append(opts, fx.Supply(fx.Annotate(r, fx.As(new(zin.RouteRegistrar)), fx.ResultTags(`group:"http-route-registrars"`))))
+	}
+	return fx.Options(opts...)
+}
+
+// AsRouteRegistrar annotates a constructor of a zin.RouteRegistrar, e.g. a
+// handler with dependencies, to provide it in the route registrars group
+func AsRouteRegistrar(constructor any) any {
+	return fx.Annotate(constructor, fx.As(new(zin.RouteRegistrar)), fx.ResultTags(`group:"http-route-registrars"`))
+}
+
+// Server provides an additional HTTP server, configured by the listener name
+// of the config (see zin.ListenerConfig), e.g. an internal admin server. Its
+// router is a *gin.Engine named name, with middlewares after the base ones of
+// zin.NewRouter, and the routes added by AddServerRoutes.
+func Server(name string, middlewares ...gin.HandlerFunc) fx.Option {
+	return fx.Module("zin.server."+name,
+		fx.Provide(fx.Annotate(
+			func(config ziconf.Config, routes []zin.RouteRegistrar) (*gin.Engine, error) {
+				router, err := zin.NewRouter(config, middlewares...)
+				if err != nil {
+					return nil, err
+				}
+				for _, r := range routes {
+					r.RegisterRoutes(router)
+				}
+				return router, nil
+			},
+			fx.ParamTags(``, serverRoutesGroup(name)),
+			fx.ResultTags(serverName(name)),
+		)),
+		fx.Invoke(fx.Annotate(
+			func(lc fx.Lifecycle, logger *zerolog.Logger, config ziconf.Config, router *gin.Engine) error {
+				return zin.StartServer(lc, logger, config, name, router)
+			},
+			fx.ParamTags(``, ``, ``, serverName(name)),
+		)),
+	)
+}
+
+// AddServerRoutes adds registrars of routes to the additional server name,
+// see Server
+func AddServerRoutes(name string, registrars ...zin.RouteRegistrar) fx.Option {
+	var opts []fx.Option
+	for _, r := range registrars {
+		opts = append(opts, fx.Supply(fx.Annotate(r, fx.As(new(zin.RouteRegistrar)), fx.ResultTags(serverRoutesGroup(name)))))
+	}
+	return fx.Options(opts...)
+}
+
+// RoutesGroup returns the fx group tag of the route registrars of the
+// additional server name, or of the main router when empty
+func RoutesGroup(server string) string {
+	if server == "" {
+		return `group:"http-route-registrars"`
+	}
+	return serverRoutesGroup(server)
+}
+
+func serverName(name string) string {
+	return `name:"` + name + `"`
+}
+
+func serverRoutesGroup(name string) string {
+	return `group:"http-route-registrars-` + name + `"`
+}
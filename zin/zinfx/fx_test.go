@@ -0,0 +1,117 @@
+package zinfx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zin"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+type testConfig struct{}
+
+func (testConfig) GetService() ziconf.ServiceConfig { return ziconf.ServiceConfig{Name: "test"} }
+func (testConfig) GetEnvironment() string           { return "test" }
+func (testConfig) GetLog() ziconf.LogConfig         { return ziconf.LogConfig{} }
+func (testConfig) GetHttpPort() string              { return ":0" }
+func (testConfig) GetTelemetry() observe.Config     { return observe.Config{} }
+
+type pingHandler struct {
+	reply string
+}
+
+func (h pingHandler) RegisterRoutes(router gin.IRouter) {
+	router.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, h.reply) })
+}
+
+func TestAddRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var router *gin.Engine
+	app := fxtest.New(t,
+		fx.Supply(fx.Annotate(testConfig{}, fx.As(new(ziconf.Config)))),
+		fx.Supply("pong"),
+		Provider,
+		fx.Provide(AsRouteRegistrar(func(reply string) pingHandler { return pingHandler{reply: reply} })),
+		AddRoutes(zin.RouteRegistrarFunc(func(router gin.IRouter) {
+			router.GET("/health", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+		})),
+		fx.Populate(&router),
+	)
+	app.RequireStart().RequireStop()
+
+	for path, want := range map[string]int{"/ping": http.StatusOK, "/health": http.StatusNoContent} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != want {
+			t.Errorf("GET %s = %d, want %d", path, w.Code, want)
+		}
+	}
+}
+
+type listenersConfig struct {
+	testConfig
+}
+
+func (listenersConfig) GetHttpListeners() map[string]zin.ListenerConfig {
+	return map[string]zin.ListenerConfig{"admin": {Addr: "127.0.0.1:0"}}
+}
+
+func TestServer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := zerolog.Nop()
+	var main, admin *gin.Engine
+	app := fxtest.New(t,
+		fx.Supply(fx.Annotate(listenersConfig{}, fx.As(new(ziconf.Config)))),
+		fx.Supply(&logger),
+		Provider,
+		AddRoutes(zin.RouteRegistrarFunc(func(router gin.IRouter) {
+			router.GET("/orders", func(c *gin.Context) { c.Status(http.StatusOK) })
+		})),
+		Server("admin"),
+		AddServerRoutes("admin", zin.RouteRegistrarFunc(func(router gin.IRouter) {
+			router.GET("/admin/stats", func(c *gin.Context) { c.Status(http.StatusOK) })
+		})),
+		fx.Populate(&main),
+		fx.Invoke(fx.Annotate(func(r *gin.Engine) { admin = r }, fx.ParamTags(`name:"admin"`))),
+	)
+	app.RequireStart().RequireStop()
+
+	if admin == main {
+		t.Fatal("admin server shares the main router")
+	}
+	for _, tc := range []struct {
+		router *gin.Engine
+		path   string
+		want   int
+	}{
+		{admin, "/admin/stats", http.StatusOK},
+		{admin, "/orders", http.StatusNotFound},
+		{main, "/orders", http.StatusOK},
+		{main, "/admin/stats", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		tc.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if w.Code != tc.want {
+			t.Errorf("GET %s = %d, want %d", tc.path, w.Code, tc.want)
+		}
+	}
+}
+
+func TestServerNotConfigured(t *testing.T) {
+	logger := zerolog.Nop()
+	app := fx.New(
+		fx.NopLogger,
+		fx.Supply(fx.Annotate(testConfig{}, fx.As(new(ziconf.Config)))),
+		fx.Supply(&logger),
+		Server("admin"),
+	)
+	if app.Err() == nil {
+		t.Error("Server() without a listener config succeeded")
+	}
+}
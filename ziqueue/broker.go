@@ -0,0 +1,341 @@
+package ziqueue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/divikraf/lumos/internal/wait"
+	"github.com/divikraf/lumos/zidlq"
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/divikraf/lumos/ziqueue"
+
+// Queue systems, the messaging.system of the telemetry.
+const (
+	SystemSQS      = "aws_sqs"
+	SystemRabbitMQ = "rabbitmq"
+	SystemPubSub   = "gcp_pubsub"
+	SystemMemory   = "memory"
+)
+
+// Delivery is a message received from a queue, acked or nacked once handled.
+type Delivery interface {
+	Message() *Message
+	// Ack removes the message from the queue.
+	Ack(ctx context.Context) error
+	// Nack returns the message to the queue, to be redelivered after delay,
+	// e.g. by changing the visibility timeout of an SQS message.
+	Nack(ctx context.Context, delay time.Duration) error
+}
+
+// Driver is the client of a queue system, e.g. an adapted SQS, RabbitMQ or
+// Pub/Sub client.
+type Driver interface {
+	// Publish publishes msg to queue, delivered after delay.
+	Publish(ctx context.Context, queue string, msg *Message, delay time.Duration) error
+	// Receive blocks until at most max messages of queue are received, or
+	// ctx is done.
+	Receive(ctx context.Context, queue string, max int) ([]Delivery, error)
+	Close() error
+}
+
+// RetryPolicy is the redelivery policy of the failing messages.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts after which a failing message is
+	// poison (default: DefaultMaxAttempts).
+	MaxAttempts int `json:"max_attempts" yaml:"max_attempts"`
+	// Backoff and MaxBackoff are the initial and maximum delays before a
+	// failed message is redelivered, doubling every attempt (default: 1s and
+	// 5m).
+	Backoff    time.Duration `json:"backoff" yaml:"backoff"`
+	MaxBackoff time.Duration `json:"max_backoff" yaml:"max_backoff"`
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultMaxAttempts
+	}
+	if p.Backoff <= 0 {
+		p.Backoff = time.Second
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 5 * time.Minute
+	}
+	return p
+}
+
+// Delay returns the delay before redelivering a message failing on attempt.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxBackoff)
+}
+
+// SubscriptionConfig configures a subscription, see Broker.Subscribe.
+type SubscriptionConfig struct {
+	// Concurrency is the number of messages handled concurrently (default:
+	// 1).
+	Concurrency int `json:"concurrency" yaml:"concurrency"`
+	// BatchSize is the maximum number of messages received at once
+	// (default: 10).
+	BatchSize int         `json:"batch_size" yaml:"batch_size"`
+	Retry     RetryPolicy `json:"retry" yaml:"retry"`
+}
+
+func (c SubscriptionConfig) withDefaults() SubscriptionConfig {
+	if c.Concurrency <= 0 {
+		c.Concurrency = 1
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 10
+	}
+	c.Retry = c.Retry.withDefaults()
+	return c
+}
+
+// PublishOption configures Broker.Publish.
+type PublishOption func(*publishConfig)
+
+type publishConfig struct {
+	id      string
+	headers map[string]string
+	delay   time.Duration
+}
+
+// WithMessageID sets the ID of the message, e.g. to deduplicate it.
+func WithMessageID(id string) PublishOption {
+	return func(c *publishConfig) {
+		c.id = id
+	}
+}
+
+// WithHeader sets the header key of the message.
+func WithHeader(key, value string) PublishOption {
+	return func(c *publishConfig) {
+		c.headers[key] = value
+	}
+}
+
+// WithDelay delays the delivery of the message.
+func WithDelay(d time.Duration) PublishOption {
+	return func(c *publishConfig) {
+		c.delay = d
+	}
+}
+
+// Broker publishes and consumes the messages of the queues of a Driver, the
+// same way whatever the queue system: the trace context is propagated in
+// the headers of the messages, every message is traced, logged with the
+// logger of its context, and recorded with the messaging instruments of
+// revelio, and failing messages are redelivered according to the retry
+// policy of their subscription.
+type Broker struct {
+	system     string
+	driver     Driver
+	deadLetter *zidlq.Manager
+
+	instruments *revelio.MessagingInstruments
+	poison      metric.Int64Counter
+}
+
+// NewBroker returns a Broker of the queue system, e.g. SystemSQS, using
+// driver. Poison messages are dead-lettered in deadLetter, or left to the
+// redrive policy of the queue when nil.
+func NewBroker(system string, driver Driver, deadLetter *zidlq.Manager) *Broker {
+	return &Broker{
+		system:      system,
+		driver:      driver,
+		deadLetter:  deadLetter,
+		instruments: revelio.MustMessagingInstruments(),
+		poison: revelio.MustInt64Counter(
+			"messaging_poison_messages_total",
+			"Number of poison messages, failing permanently or on their last attempt, by queue",
+		),
+	}
+}
+
+// Publish publishes body to queue.
+func (b *Broker) Publish(ctx context.Context, queue string, body []byte, opts ...PublishOption) error {
+	config := publishConfig{headers: map[string]string{}}
+	for _, o := range opts {
+		o(&config)
+	}
+	attrs := revelio.MessagingAttributes(b.system, queue, "")
+	ctx, span := otel.Tracer(tracerName).Start(ctx, queue+" send",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(revelio.MessagingOperationKey.String(revelio.MessagingOperationSend)),
+	)
+	defer span.End()
+
+	msg := &Message{
+		ID:          config.id,
+		Queue:       queue,
+		Body:        body,
+		Headers:     config.headers,
+		PublishedAt: time.Now(),
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(msg.Headers))
+	err := b.driver.Publish(ctx, queue, msg, config.delay)
+	if err != nil {
+		observe.RecordError(span, err)
+		zilog.FromContext(ctx).Error().Err(err).Str("queue", queue).Msg("queue publish failed")
+	}
+	b.instruments.Produced.Record(ctx, err, attrs...)
+	return err
+}
+
+// Subscribe handles the messages of queue with handle until ctx is done,
+// then waits for the messages being handled. Messages are acked when handle
+// returns nil, and nacked to be redelivered after the delay of the retry
+// policy otherwise. Messages failing with a Permanent error, or on their
+// last attempt, are poison: they are dead-lettered and acked with a
+// dead-letter manager, and nacked to the queue redrive policy otherwise.
+func (b *Broker) Subscribe(ctx context.Context, queue string, handle Handler, config SubscriptionConfig) error {
+	config = config.withDefaults()
+	deliveries := make(chan Delivery)
+	var wg sync.WaitGroup
+	for range config.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range deliveries {
+				// Messages received are handled even once ctx is done
+				b.handle(context.WithoutCancel(ctx), queue, d, handle, config.Retry)
+			}
+		}()
+	}
+	defer func() {
+		close(deliveries)
+		wg.Wait()
+	}()
+
+	backoff := config.Retry.Backoff
+	for {
+		batch, err := b.driver.Receive(ctx, queue, config.BatchSize)
+		for _, d := range batch {
+			deliveries <- d
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			zilog.FromContext(ctx).Error().Err(err).Str("queue", queue).Dur("backoff", backoff).Msg("queue receive failed")
+			if !wait.Sleep(ctx, backoff) {
+				return nil
+			}
+			backoff = min(2*backoff, config.Retry.MaxBackoff)
+			continue
+		}
+		backoff = config.Retry.Backoff
+	}
+}
+
+// handle handles a delivery, and acks or nacks it.
+func (b *Broker) handle(ctx context.Context, queue string, d Delivery, handle Handler, policy RetryPolicy) {
+	msg := d.Message()
+	if msg.Queue == "" {
+		msg.Queue = queue
+	}
+	if msg.Headers != nil {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(msg.Headers))
+	}
+	attrs := revelio.MessagingAttributes(b.system, queue, "")
+	ctx, span := otel.Tracer(tracerName).Start(ctx, queue+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(
+			revelio.MessagingOperationKey.String(revelio.MessagingOperationProcess),
+			attribute.String("messaging.message.id", msg.ID),
+			attribute.Int("messaging.delivery.attempt", msg.Attempt),
+		),
+	)
+	defer span.End()
+	logger := zilog.FromContext(ctx).With().
+		Str("queue", queue).
+		Str("message_id", msg.ID).
+		Int("attempt", msg.Attempt).
+		Logger()
+	ctx = logger.WithContext(ctx)
+
+	start := time.Now()
+	err := safeHandle(ctx, handle, msg)
+	enqueuedAt := msg.PublishedAt
+	if msg.Attempt > 1 {
+		// The lag is only recorded on the first delivery
+		enqueuedAt = time.Time{}
+	}
+	b.instruments.RecordProcessed(ctx, enqueuedAt, time.Since(start), err, attrs...)
+
+	if err == nil {
+		if err := d.Ack(ctx); err != nil {
+			logger.Error().Err(err).Msg("queue ack failed")
+		}
+		return
+	}
+	observe.RecordError(span, err)
+
+	if !IsPermanent(err) && msg.Attempt < policy.MaxAttempts {
+		delay := policy.Delay(msg.Attempt)
+		logger.Warn().Err(err).Dur("delay", delay).Msg("queue message failed, retrying")
+		b.instruments.RecordRetry(ctx, attrs...)
+		if err := d.Nack(ctx, delay); err != nil {
+			logger.Error().Err(err).Msg("queue nack failed")
+		}
+		return
+	}
+
+	b.poison.Add(ctx, 1, metric.WithAttributes(revelio.MessagingDestinationKey.String(queue)))
+	if b.deadLetter == nil {
+		logger.Error().Err(err).Msg("poison message, left to the queue redrive policy")
+		if err := d.Nack(ctx, 0); err != nil {
+			logger.Error().Err(err).Msg("queue nack failed")
+		}
+		return
+	}
+	if dlqErr := b.deadLetter.Dead(ctx, zidlq.Message{
+		Queue:    queue,
+		Payload:  msg.Body,
+		Headers:  msg.Headers,
+		Attempts: msg.Attempt,
+	}, err); dlqErr != nil {
+		logger.Error().Err(dlqErr).Msg("queue dead letter failed")
+		if err := d.Nack(ctx, policy.Delay(msg.Attempt)); err != nil {
+			logger.Error().Err(err).Msg("queue nack failed")
+		}
+		return
+	}
+	b.instruments.RecordDeadLettered(ctx, attrs...)
+	logger.Warn().Err(err).Msg("poison message dead-lettered")
+	if err := d.Ack(ctx); err != nil {
+		logger.Error().Err(err).Msg("queue ack failed")
+	}
+}
+
+// safeHandle calls handle, turning its panics into permanent errors.
+func safeHandle(ctx context.Context, handle Handler, msg *Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			observe.RecordPanic(trace.SpanFromContext(ctx), r)
+			err = Permanent(fmt.Errorf("ziqueue: handler panicked: %v", r))
+		}
+	}()
+	return handle(ctx, msg)
+}
+
+// Close closes the driver.
+func (b *Broker) Close() error {
+	return b.driver.Close()
+}
@@ -0,0 +1,99 @@
+package ziqueue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zidlq"
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestBroker(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	logger := zerolog.Nop()
+	dlq, err := zidlq.New(zidlq.NewMemoryStore(), &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	driver := NewMemoryDriver()
+	broker := NewBroker(SystemMemory, driver, dlq)
+	ctx, cancel := context.WithCancel(logger.WithContext(context.Background()))
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		attempts = map[string]int{}
+		done     = make(chan struct{}, 3)
+	)
+	handle := func(ctx context.Context, msg *Message) error {
+		mu.Lock()
+		attempts[string(msg.Body)] = msg.Attempt
+		mu.Unlock()
+		done <- struct{}{}
+		switch string(msg.Body) {
+		case "flaky":
+			if msg.Attempt < 2 {
+				return errors.New("try again")
+			}
+		case "poison":
+			return Permanent(errors.New("undecodable"))
+		}
+		return nil
+	}
+	subscribed := make(chan error)
+	go func() {
+		subscribed <- broker.Subscribe(ctx, "orders", handle, SubscriptionConfig{
+			Retry: RetryPolicy{Backoff: time.Millisecond},
+		})
+	}()
+
+	for _, body := range []string{"flaky", "poison"} {
+		if err := broker.Publish(ctx, "orders", []byte(body), WithHeader("source", "test")); err != nil {
+			t.Fatalf("Failed to publish: %v", err)
+		}
+	}
+	for range 3 {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("messages not handled")
+		}
+	}
+	cancel()
+	if err := <-subscribed; err != nil {
+		t.Fatalf("Subscribe = %v", err)
+	}
+
+	if attempts["flaky"] != 2 || attempts["poison"] != 1 {
+		t.Errorf("attempts = %v, want flaky on 2 and poison on 1", attempts)
+	}
+	if n := driver.Len("orders"); n != 0 {
+		t.Errorf("%d messages left in the queue, want 0", n)
+	}
+	dead, err := dlq.List(context.Background(), "orders", zidlq.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to list dead messages: %v", err)
+	}
+	if len(dead) != 1 || string(dead[0].Payload) != "poison" || dead[0].Headers["source"] != "test" {
+		t.Errorf("dead messages = %+v, want the poison message", dead)
+	}
+	reveliotest.AssertCounterValue(t, s, "messaging_retries_total", 1,
+		attribute.String("messaging.system", SystemMemory),
+		attribute.String("messaging.destination.name", "orders"))
+	reveliotest.AssertCounterValue(t, s, "messaging_poison_messages_total", 1,
+		attribute.String("messaging.destination.name", "orders"))
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second} {
+		if got := p.Delay(attempt); got != want {
+			t.Errorf("Delay(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package ziqueue
+
+import (
+	"fmt"
+
+	"github.com/divikraf/lumos/zihttpc"
+)
+
+// DriverConfig configures the drivers of the queue systems.
+type DriverConfig struct {
+	SQS      SQSConfig      `json:"sqs" yaml:"sqs"`
+	RabbitMQ RabbitMQConfig `json:"rabbitmq" yaml:"rabbitmq"`
+	PubSub   PubSubConfig   `json:"pubsub" yaml:"pubsub"`
+	// HTTP configures the HTTP client of the SQS and Pub/Sub drivers, see
+	// zihttpc.New. Its timeout must exceed the SQS wait time.
+	HTTP zihttpc.ClientConfig `json:"http" yaml:"http"`
+}
+
+// OpenDriver returns the Driver of the queue system, SystemSQS,
+// SystemRabbitMQ, SystemPubSub or SystemMemory, configured by config and
+// instrumented, see Instrument.
+func OpenDriver(system string, config DriverConfig) (Driver, error) {
+	var d Driver
+	switch system {
+	case SystemSQS:
+		d = NewSQSDriver(config.SQS, zihttpc.New(config.HTTP))
+	case SystemRabbitMQ:
+		d = NewRabbitMQDriver(config.RabbitMQ)
+	case SystemPubSub:
+		d = NewPubSubDriver(config.PubSub, zihttpc.New(config.HTTP))
+	case SystemMemory:
+		d = NewMemoryDriver()
+	default:
+		return nil, fmt.Errorf("ziqueue: unknown queue system %q", system)
+	}
+	return Instrument(system, d), nil
+}
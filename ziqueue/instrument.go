@@ -0,0 +1,110 @@
+package ziqueue
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Driver operations, the operation attribute of the driver telemetry.
+const (
+	OperationPublish = "publish"
+	OperationReceive = "receive"
+	OperationAck     = "ack"
+	OperationNack    = "nack"
+)
+
+type instrumentedDriver struct {
+	system   string
+	driver   Driver
+	duration revelio.DurationRecorder
+}
+
+// Instrument returns driver, of the queue system, with its calls traced,
+// recorded in the queue_driver_duration_ms metric by system, queue,
+// operation and status, and their failures logged with the logger of their
+// context. Receive calls interrupted by their context are not failures.
+func Instrument(system string, driver Driver) Driver {
+	return &instrumentedDriver{
+		system: system,
+		driver: driver,
+		duration: revelio.MustDuration(
+			"queue_driver_duration_ms",
+			"Duration of the calls of the queue drivers in milliseconds, by system, queue, operation and status",
+		),
+	}
+}
+
+// start starts the span of operation on queue, and returns the function
+// ending it with the error of the operation.
+func (d *instrumentedDriver) start(ctx context.Context, operation, queue string, attrs ...attribute.KeyValue) (context.Context, func(error)) {
+	metricAttrs := append(revelio.MessagingAttributes(d.system, queue, ""), attribute.String("operation", operation))
+	ctx, span := otel.Tracer(tracerName).Start(ctx, queue+" "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(metricAttrs...),
+		trace.WithAttributes(attrs...),
+	)
+	start := time.Now()
+	return ctx, func(err error) {
+		d.duration.Record(ctx, time.Since(start), append(metricAttrs, revelio.ResultAttributes(err)...)...)
+		if err != nil {
+			observe.RecordError(span, err)
+			zilog.FromContext(ctx).Debug().Err(err).
+				Str("queue_system", d.system).
+				Str("queue", queue).
+				Str("operation", operation).
+				Msg("queue driver call failed")
+		}
+		span.End()
+	}
+}
+
+func (d *instrumentedDriver) Publish(ctx context.Context, queue string, msg *Message, delay time.Duration) (err error) {
+	ctx, end := d.start(ctx, OperationPublish, queue, attribute.String("messaging.message.id", msg.ID))
+	defer func() { end(err) }()
+	return d.driver.Publish(ctx, queue, msg, delay)
+}
+
+func (d *instrumentedDriver) Receive(ctx context.Context, queue string, max int) ([]Delivery, error) {
+	spanCtx, end := d.start(ctx, OperationReceive, queue)
+	deliveries, err := d.driver.Receive(spanCtx, queue, max)
+	trace.SpanFromContext(spanCtx).SetAttributes(attribute.Int("messaging.batch.message_count", len(deliveries)))
+	if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		end(nil)
+	} else {
+		end(err)
+	}
+	for i, delivery := range deliveries {
+		deliveries[i] = &instrumentedDelivery{Delivery: delivery, driver: d, queue: queue}
+	}
+	return deliveries, err
+}
+
+func (d *instrumentedDriver) Close() error {
+	return d.driver.Close()
+}
+
+type instrumentedDelivery struct {
+	Delivery
+	driver *instrumentedDriver
+	queue  string
+}
+
+func (d *instrumentedDelivery) Ack(ctx context.Context) (err error) {
+	ctx, end := d.driver.start(ctx, OperationAck, d.queue, attribute.String("messaging.message.id", d.Message().ID))
+	defer func() { end(err) }()
+	return d.Delivery.Ack(ctx)
+}
+
+func (d *instrumentedDelivery) Nack(ctx context.Context, delay time.Duration) (err error) {
+	ctx, end := d.driver.start(ctx, OperationNack, d.queue, attribute.String("messaging.message.id", d.Message().ID))
+	defer func() { end(err) }()
+	return d.Delivery.Nack(ctx, delay)
+}
@@ -0,0 +1,79 @@
+package ziqueue
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInstrument(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	recorder := tracetest.NewSpanRecorder()
+	provider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(provider)
+
+	var logs bytes.Buffer
+	logger := zerolog.New(&logs).Level(zerolog.DebugLevel)
+	ctx := logger.WithContext(context.Background())
+	driver := Instrument(SystemMemory, NewMemoryDriver())
+
+	if err := driver.Publish(ctx, "orders", &Message{ID: "order-1", Body: []byte("created")}, 0); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	deliveries, err := driver.Receive(ctx, "orders", 10)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("Receive() = %d deliveries, %v, want 1", len(deliveries), err)
+	}
+	if err := deliveries[0].Ack(ctx); err != nil {
+		t.Fatalf("Ack() = %v", err)
+	}
+	// Receiving until the context is done isn't a failure
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := driver.Receive(timeout, "orders", 10); err == nil {
+		t.Fatal("Receive() = nil, want the error of the context")
+	}
+	driver.Close()
+	if err := driver.Publish(ctx, "orders", &Message{}, 0); err != ErrDriverClosed {
+		t.Fatalf("Publish() = %v, want ErrDriverClosed", err)
+	}
+
+	var names, failed []string
+	for _, span := range recorder.Ended() {
+		names = append(names, span.Name())
+		if span.SpanKind() != trace.SpanKindClient {
+			t.Errorf("span %s of kind %s, want client", span.Name(), span.SpanKind())
+		}
+		if span.Status().Code == codes.Error {
+			failed = append(failed, span.Name())
+		}
+	}
+	if got := strings.Join(names, ", "); got != "orders publish, orders receive, orders ack, orders receive, orders publish" {
+		t.Errorf("spans = %s", got)
+	}
+	if len(failed) != 1 || failed[0] != "orders publish" {
+		t.Errorf("failed spans = %v, want the last publish", failed)
+	}
+	system := attribute.String("messaging.system", SystemMemory)
+	if h := reveliotest.CollectHistogram(t, s, "queue_driver_duration_ms", system, attribute.String("operation", OperationReceive), attribute.String("status", "success")); h.Count != 2 {
+		t.Errorf("%d receives recorded, want 2", h.Count)
+	}
+	if h := reveliotest.CollectHistogram(t, s, "queue_driver_duration_ms", system, attribute.String("operation", OperationPublish), attribute.String("status", "failure")); h.Count != 1 {
+		t.Errorf("%d failed publishes recorded, want 1", h.Count)
+	}
+	if !strings.Contains(logs.String(), `"operation":"publish","message":"queue driver call failed"`) {
+		t.Errorf("logs = %s, want the failed publish", logs.String())
+	}
+}
@@ -0,0 +1,136 @@
+package ziqueue
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrDriverClosed is returned by the drivers once closed.
+var ErrDriverClosed = errors.New("ziqueue: driver closed")
+
+// MemoryDriver is a Driver keeping the queues in memory, e.g. for tests and
+// local development. Messages are lost when the process stops.
+type MemoryDriver struct {
+	mu     sync.Mutex
+	queues map[string]*memoryQueue
+	seq    int
+	closed chan struct{}
+}
+
+type memoryQueue struct {
+	messages []*Message
+	// ready is closed, then replaced, whenever a message is added.
+	ready chan struct{}
+}
+
+// NewMemoryDriver returns an empty MemoryDriver.
+func NewMemoryDriver() *MemoryDriver {
+	return &MemoryDriver{queues: map[string]*memoryQueue{}, closed: make(chan struct{})}
+}
+
+func (d *MemoryDriver) queue(name string) *memoryQueue {
+	q, ok := d.queues[name]
+	if !ok {
+		q = &memoryQueue{ready: make(chan struct{})}
+		d.queues[name] = q
+	}
+	return q
+}
+
+// enqueue adds msg to queue after delay.
+func (d *MemoryDriver) enqueue(queue string, msg *Message, delay time.Duration) {
+	if delay > 0 {
+		time.AfterFunc(delay, func() { d.enqueue(queue, msg, 0) })
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	q := d.queue(queue)
+	q.messages = append(q.messages, msg)
+	close(q.ready)
+	q.ready = make(chan struct{})
+}
+
+// Publish implements Driver.
+func (d *MemoryDriver) Publish(ctx context.Context, queue string, msg *Message, delay time.Duration) error {
+	select {
+	case <-d.closed:
+		return ErrDriverClosed
+	default:
+	}
+	copied := *msg
+	if copied.ID == "" {
+		d.mu.Lock()
+		d.seq++
+		copied.ID = strconv.Itoa(d.seq)
+		d.mu.Unlock()
+	}
+	copied.Queue = queue
+	d.enqueue(queue, &copied, delay)
+	return nil
+}
+
+// Receive implements Driver.
+func (d *MemoryDriver) Receive(ctx context.Context, queue string, max int) ([]Delivery, error) {
+	for {
+		d.mu.Lock()
+		q := d.queue(queue)
+		n := min(max, len(q.messages))
+		if n > 0 {
+			deliveries := make([]Delivery, n)
+			for i, msg := range q.messages[:n] {
+				msg.Attempt++
+				deliveries[i] = &memoryDelivery{driver: d, msg: msg}
+			}
+			q.messages = q.messages[n:]
+			d.mu.Unlock()
+			return deliveries, nil
+		}
+		ready := q.ready
+		d.mu.Unlock()
+
+		select {
+		case <-ready:
+		case <-d.closed:
+			return nil, ErrDriverClosed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Len returns the number of messages of queue waiting to be received.
+func (d *MemoryDriver) Len(queue string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.queue(queue).messages)
+}
+
+// Close implements Driver.
+func (d *MemoryDriver) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	select {
+	case <-d.closed:
+	default:
+		close(d.closed)
+	}
+	return nil
+}
+
+type memoryDelivery struct {
+	driver *MemoryDriver
+	msg    *Message
+}
+
+func (m *memoryDelivery) Message() *Message { return m.msg }
+
+func (m *memoryDelivery) Ack(ctx context.Context) error { return nil }
+
+func (m *memoryDelivery) Nack(ctx context.Context, delay time.Duration) error {
+	m.driver.enqueue(m.msg.Queue, m.msg, delay)
+	return nil
+}
@@ -0,0 +1,219 @@
+package ziqueue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/divikraf/lumos/internal/gcpauth"
+)
+
+// pubsubIDAttribute is the attribute of the ID of the messages published
+// with one.
+const pubsubIDAttribute = "ziqueue-id"
+
+// pubsubMaxAckDeadline is the maximum ack deadline of Pub/Sub.
+const pubsubMaxAckDeadline = 10 * time.Minute
+
+// PubSubConfig configures the GCP Pub/Sub driver.
+type PubSubConfig struct {
+	// Project is the project of the topics and subscriptions.
+	Project string `json:"project" yaml:"project" validate:"required"`
+	// Endpoint is the URL of the service (default:
+	// https://pubsub.googleapis.com).
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+	// Subscriptions are the subscriptions received from, by queue (default:
+	// the subscription named after the queue).
+	Subscriptions map[string]string `json:"subscriptions" yaml:"subscriptions"`
+	// AccessToken authenticates the requests (default: the
+	// GOOGLE_OAUTH_ACCESS_TOKEN variable, or the tokens of the metadata
+	// server).
+	AccessToken string `json:"access_token" yaml:"access_token"`
+}
+
+type pubsubDriver struct {
+	config PubSubConfig
+	client *http.Client
+	tokens *gcpauth.TokenSource
+}
+
+// NewPubSubDriver returns the Driver of GCP Pub/Sub, called with the REST
+// API with client, or http.DefaultClient if nil. Queues are topics,
+// published to, and received from their subscription. The headers of the
+// messages are their attributes. Pub/Sub doesn't delay deliveries, and only
+// counts the attempts of the subscriptions with a dead letter policy: the
+// messages of the others are always on their first attempt, and retried
+// until acked.
+func NewPubSubDriver(config PubSubConfig, client *http.Client) Driver {
+	if config.Endpoint == "" {
+		config.Endpoint = "https://pubsub.googleapis.com"
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &pubsubDriver{config: config, client: client, tokens: gcpauth.NewTokenSource(config.AccessToken, client)}
+}
+
+// subscription returns the resource name of the subscription of queue.
+func (d *pubsubDriver) subscription(queue string) string {
+	name := queue
+	if s, ok := d.config.Subscriptions[queue]; ok {
+		name = s
+	}
+	if strings.HasPrefix(name, "projects/") {
+		return name
+	}
+	return "projects/" + d.config.Project + "/subscriptions/" + name
+}
+
+// topic returns the resource name of the topic of queue.
+func (d *pubsubDriver) topic(queue string) string {
+	if strings.HasPrefix(queue, "projects/") {
+		return queue
+	}
+	return "projects/" + d.config.Project + "/topics/" + queue
+}
+
+type pubsubMessage struct {
+	Data        []byte            `json:"data"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	MessageID   string            `json:"messageId,omitempty"`
+	PublishTime string            `json:"publishTime,omitempty"`
+}
+
+func (d *pubsubDriver) Publish(ctx context.Context, queue string, msg *Message, delay time.Duration) error {
+	if delay > 0 {
+		return fmt.Errorf("%w: Pub/Sub delivers messages on publish", ErrDelayUnsupported)
+	}
+	m := pubsubMessage{Data: msg.Body, Attributes: make(map[string]string, len(msg.Headers)+1)}
+	for k, v := range msg.Headers {
+		m.Attributes[k] = v
+	}
+	if msg.ID != "" {
+		m.Attributes[pubsubIDAttribute] = msg.ID
+	}
+	return d.call(ctx, d.topic(queue)+":publish", map[string]any{"messages": []pubsubMessage{m}}, nil)
+}
+
+func (d *pubsubDriver) Receive(ctx context.Context, queue string, max int) ([]Delivery, error) {
+	subscription := d.subscription(queue)
+	var output struct {
+		ReceivedMessages []struct {
+			AckID           string        `json:"ackId"`
+			Message         pubsubMessage `json:"message"`
+			DeliveryAttempt int           `json:"deliveryAttempt"`
+		} `json:"receivedMessages"`
+	}
+	if err := d.call(ctx, subscription+":pull", map[string]int{"maxMessages": max}, &output); err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]Delivery, 0, len(output.ReceivedMessages))
+	for _, r := range output.ReceivedMessages {
+		msg := &Message{
+			ID:      r.Message.MessageID,
+			Queue:   queue,
+			Body:    r.Message.Data,
+			Headers: r.Message.Attributes,
+			Attempt: r.DeliveryAttempt,
+		}
+		if msg.Headers == nil {
+			msg.Headers = map[string]string{}
+		}
+		if msg.Attempt < 1 {
+			msg.Attempt = 1
+		}
+		msg.PublishedAt, _ = time.Parse(time.RFC3339Nano, r.Message.PublishTime)
+		if id, ok := msg.Headers[pubsubIDAttribute]; ok {
+			msg.ID = id
+			delete(msg.Headers, pubsubIDAttribute)
+		}
+		deliveries = append(deliveries, &pubsubDelivery{driver: d, subscription: subscription, ackID: r.AckID, msg: msg})
+	}
+	return deliveries, nil
+}
+
+func (d *pubsubDriver) Close() error {
+	return nil
+}
+
+// call calls the method of the resource, e.g. "projects/p/topics/t:publish",
+// with the JSON input, and decodes its output in out, if not nil. Error
+// responses are returned as *Error.
+func (d *pubsubDriver) call(ctx context.Context, method string, input, out any) error {
+	token, err := d.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(d.config.Endpoint, "/")+"/v1/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Error struct {
+				Status  string `json:"status"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return &Error{System: SystemPubSub, Status: resp.StatusCode, Code: e.Error.Status, Message: e.Error.Message}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("ziqueue: invalid Pub/Sub response of %s: %w", method, err)
+	}
+	return nil
+}
+
+// accessToken returns the token of the requests, the error responses of the
+// metadata server as *Error.
+func (d *pubsubDriver) accessToken(ctx context.Context) (string, error) {
+	token, err := d.tokens.Token(ctx)
+	var statusErr *gcpauth.StatusError
+	if errors.As(err, &statusErr) {
+		return "", &Error{System: SystemPubSub, Status: statusErr.Status, Message: "metadata server token"}
+	}
+	return token, err
+}
+
+type pubsubDelivery struct {
+	driver       *pubsubDriver
+	subscription string
+	ackID        string
+	msg          *Message
+}
+
+func (p *pubsubDelivery) Message() *Message { return p.msg }
+
+// Ack acknowledges the message.
+func (p *pubsubDelivery) Ack(ctx context.Context) error {
+	return p.driver.call(ctx, p.subscription+":acknowledge", map[string][]string{"ackIds": {p.ackID}}, nil)
+}
+
+// Nack sets the ack deadline of the message to delay, at most 10m, for it to
+// be redelivered then.
+func (p *pubsubDelivery) Nack(ctx context.Context, delay time.Duration) error {
+	return p.driver.call(ctx, p.subscription+":modifyAckDeadline", map[string]any{
+		"ackIds":             []string{p.ackID},
+		"ackDeadlineSeconds": int(min(delay, pubsubMaxAckDeadline).Seconds()),
+	}, nil)
+}
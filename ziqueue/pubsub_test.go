@@ -0,0 +1,155 @@
+package ziqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePubSub serves the methods of the Pub/Sub REST API used by the driver,
+// with one subscription per topic.
+type fakePubSub struct {
+	mu            sync.Mutex
+	subscriptions map[string]string
+	messages      map[string][]*fakePubSubMessage
+	tokens        int
+}
+
+type fakePubSubMessage struct {
+	message   pubsubMessage
+	ackID     string
+	attempts  int
+	visibleAt time.Time
+}
+
+func (f *fakePubSub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path == "/token" {
+		f.tokens++
+		io.WriteString(w, `{"access_token":"ya29","expires_in":3600}`)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer ya29" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	resource, method, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/"), ":")
+	if topic, ok := f.subscriptions[resource]; ok {
+		resource = topic
+	}
+	messages, ok := f.messages[resource]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"error":{"code":404,"status":"NOT_FOUND","message":"Resource not found"}}`)
+		return
+	}
+	var in struct {
+		Messages           []pubsubMessage `json:"messages"`
+		MaxMessages        int             `json:"maxMessages"`
+		AckIDs             []string        `json:"ackIds"`
+		AckDeadlineSeconds int             `json:"ackDeadlineSeconds"`
+	}
+	json.NewDecoder(r.Body).Decode(&in)
+
+	var out any = map[string]any{}
+	switch method {
+	case "publish":
+		var ids []string
+		for _, m := range in.Messages {
+			m.MessageID = strconv.Itoa(len(messages) + 1)
+			m.PublishTime = "2023-11-14T22:13:20.123Z"
+			messages = append(messages, &fakePubSubMessage{message: m})
+			ids = append(ids, m.MessageID)
+		}
+		f.messages[resource] = messages
+		out = map[string][]string{"messageIds": ids}
+	case "pull":
+		var received []map[string]any
+		for _, m := range messages {
+			if len(received) == in.MaxMessages || time.Now().Before(m.visibleAt) {
+				continue
+			}
+			m.attempts++
+			m.ackID = "ack-" + m.message.MessageID + "-" + strconv.Itoa(m.attempts)
+			m.visibleAt = time.Now().Add(10 * time.Second)
+			received = append(received, map[string]any{"ackId": m.ackID, "message": m.message, "deliveryAttempt": m.attempts})
+		}
+		out = map[string]any{"receivedMessages": received}
+	case "acknowledge", "modifyAckDeadline":
+		for i, m := range messages {
+			if len(in.AckIDs) != 1 || m.ackID != in.AckIDs[0] {
+				continue
+			}
+			if method == "acknowledge" {
+				f.messages[resource] = append(messages[:i:i], messages[i+1:]...)
+			} else {
+				m.visibleAt = time.Now().Add(time.Duration(in.AckDeadlineSeconds) * time.Second)
+			}
+		}
+	}
+	json.NewEncoder(w).Encode(out)
+}
+
+func TestPubSubDriver(t *testing.T) {
+	fake := &fakePubSub{
+		subscriptions: map[string]string{"projects/acme/subscriptions/orders-billing": "projects/acme/topics/orders"},
+		messages:      map[string][]*fakePubSubMessage{"projects/acme/topics/orders": nil},
+	}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	driver := NewPubSubDriver(PubSubConfig{
+		Project:       "acme",
+		Endpoint:      srv.URL,
+		Subscriptions: map[string]string{"orders": "orders-billing"},
+	}, srv.Client()).(*pubsubDriver)
+	driver.tokens.AccessToken = ""
+	driver.tokens.URL = srv.URL + "/token"
+	ctx := context.Background()
+
+	if err := driver.Publish(ctx, "orders", &Message{ID: "order-1", Body: []byte{0xff, 0x00}, Headers: map[string]string{"traceparent": "00-trace"}}, 0); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	if err := driver.Publish(ctx, "orders", &Message{}, time.Second); !errors.Is(err, ErrDelayUnsupported) {
+		t.Errorf("Publish() = %v, want ErrDelayUnsupported", err)
+	}
+
+	deliveries, err := driver.Receive(ctx, "orders", 10)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("Receive() = %d deliveries, %v, want 1", len(deliveries), err)
+	}
+	msg := deliveries[0].Message()
+	if msg.ID != "order-1" || string(msg.Body) != "\xff\x00" || msg.Attempt != 1 || msg.Headers["traceparent"] != "00-trace" || len(msg.Headers) != 1 ||
+		!msg.PublishedAt.Equal(time.Date(2023, 11, 14, 22, 13, 20, 123e6, time.UTC)) {
+		t.Errorf("message = %+v", msg)
+	}
+	if err := deliveries[0].Nack(ctx, 0); err != nil {
+		t.Fatalf("Nack() = %v", err)
+	}
+	deliveries, err = driver.Receive(ctx, "orders", 10)
+	if err != nil || len(deliveries) != 1 || deliveries[0].Message().Attempt != 2 {
+		t.Fatalf("Receive() = %d deliveries, %v, want the message nacked on attempt 2", len(deliveries), err)
+	}
+	if err := deliveries[0].Ack(ctx); err != nil {
+		t.Fatalf("Ack() = %v", err)
+	}
+	if n := len(fake.messages["projects/acme/topics/orders"]); n != 0 {
+		t.Errorf("%d messages left, want 0", n)
+	}
+	if fake.tokens != 1 {
+		t.Errorf("%d access tokens requested, want 1 cached", fake.tokens)
+	}
+
+	var e *Error
+	if _, err := driver.Receive(ctx, "payments", 10); !errors.As(err, &e) || e.Status != http.StatusNotFound || e.Code != "NOT_FOUND" {
+		t.Errorf("Receive() = %v, want NOT_FOUND", err)
+	}
+}
@@ -0,0 +1,80 @@
+// Package ziqueue provides a message queue abstraction over SQS, RabbitMQ or
+// Pub/Sub drivers: the Broker publishing and consuming the messages of a
+// Driver, the message and handler types shared by the drivers, and handler
+// wrappers making the common consumer patterns safe by default. The drivers
+// of the queue systems are returned by NewSQSDriver, NewRabbitMQDriver and
+// NewPubSubDriver, or instrumented by OpenDriver.
+package ziqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Message is a message delivered by a queue.
+type Message struct {
+	ID      string
+	Queue   string
+	Body    []byte
+	Headers map[string]string
+	// Attempt is the delivery attempt of the message, from 1.
+	Attempt int
+	// PublishedAt is when the message was published, if known.
+	PublishedAt time.Time
+}
+
+// Handler handles a message. The message is acked when it returns nil, and
+// nacked to be redelivered otherwise.
+type Handler func(ctx context.Context, msg *Message) error
+
+// permanentError is an error retrying can't fix.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as permanent, e.g. an undecodable message: the message
+// is poison, and is dead-lettered without being retried.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// ErrDelayUnsupported is returned by the drivers of the queue systems unable
+// to delay the delivery of a message.
+var ErrDelayUnsupported = errors.New("ziqueue: delayed delivery not supported")
+
+// Error is an error response of a queue system.
+type Error struct {
+	System string
+	Status int
+	// Code is the error code of the response, if any.
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("ziqueue: %s responded %d %s: %s", e.System, e.Status, e.Code, e.Message)
+}
+
+// ErrorType returns the code of the error, as the normalized error type of
+// metrics.
+func (e *Error) ErrorType() string {
+	if e.Code == "" {
+		return "http_" + strconv.Itoa(e.Status)
+	}
+	return e.Code
+}
@@ -0,0 +1,221 @@
+package ziqueue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// fakeRabbit is a broker of quorum queues, routing the messages published
+// to any exchange to the queue of their routing key.
+type fakeRabbit struct {
+	mu        sync.Mutex
+	dials     int
+	conn      *fakeRabbitConnection
+	queues    map[string]chan amqp.Delivery
+	exchanges []string
+	tag       uint64
+}
+
+func (f *fakeRabbit) dial(url string) (rabbitConnection, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.dials++
+	f.conn = &fakeRabbitConnection{broker: f}
+	return f.conn, nil
+}
+
+// publish enqueues a delivery of msg, previously delivered count times.
+func (f *fakeRabbit) publish(key string, msg amqp.Publishing, count int64) {
+	f.tag++
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	if count > 0 {
+		headers[rabbitDeliveryCount] = count
+	}
+	delivery := amqp.Delivery{
+		Acknowledger: &fakeAcknowledger{broker: f, key: key, msg: msg, count: count},
+		Headers:      headers,
+		MessageId:    msg.MessageId,
+		Timestamp:    msg.Timestamp,
+		DeliveryTag:  f.tag,
+		Body:         msg.Body,
+	}
+	f.queues[key] <- delivery
+}
+
+// cancel cancels the consumers of queue, e.g. as the queue is deleted.
+func (f *fakeRabbit) cancel(queue string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	close(f.queues[queue])
+	f.queues[queue] = make(chan amqp.Delivery, 10)
+}
+
+// drop closes the connection.
+func (f *fakeRabbit) drop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.conn.closed = true
+}
+
+type fakeRabbitConnection struct {
+	broker *fakeRabbit
+	closed bool
+}
+
+func (c *fakeRabbitConnection) Channel() (rabbitChannel, error) {
+	return &fakeRabbitChannel{broker: c.broker}, nil
+}
+
+func (c *fakeRabbitConnection) IsClosed() bool {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+	return c.closed
+}
+
+func (c *fakeRabbitConnection) Close() error {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+type fakeRabbitChannel struct {
+	broker *fakeRabbit
+}
+
+func (c *fakeRabbitChannel) Qos(prefetchCount, prefetchSize int, global bool) error { return nil }
+
+func (c *fakeRabbitChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+	q, ok := c.broker.queues[queue]
+	if !ok {
+		return nil, &amqp.Error{Code: amqp.NotFound, Reason: "NOT_FOUND - no queue '" + queue + "'"}
+	}
+	return q, nil
+}
+
+func (c *fakeRabbitChannel) PublishConfirmed(ctx context.Context, exchange, key string, msg amqp.Publishing) error {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+	c.broker.exchanges = append(c.broker.exchanges, exchange)
+	c.broker.publish(key, msg, 0)
+	return nil
+}
+
+func (c *fakeRabbitChannel) Close() error { return nil }
+
+type fakeAcknowledger struct {
+	broker *fakeRabbit
+	key    string
+	msg    amqp.Publishing
+	count  int64
+	acked  bool
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.broker.mu.Lock()
+	defer a.broker.mu.Unlock()
+	a.acked = true
+	return nil
+}
+
+func (a *fakeAcknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.broker.mu.Lock()
+	defer a.broker.mu.Unlock()
+	if requeue {
+		a.broker.publish(a.key, a.msg, a.count+1)
+	}
+	return nil
+}
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+func TestRabbitMQDriver(t *testing.T) {
+	fake := &fakeRabbit{queues: map[string]chan amqp.Delivery{"orders": make(chan amqp.Delivery, 10)}}
+	driver := NewRabbitMQDriver(RabbitMQConfig{URL: "amqp://rabbitmq", DelayExchange: "delayed"}).(*rabbitDriver)
+	driver.dial = fake.dial
+	ctx := context.Background()
+
+	publishedAt := time.Unix(1700000000, 0)
+	if err := driver.Publish(ctx, "orders", &Message{ID: "order-1", Body: []byte("created"), Headers: map[string]string{"traceparent": "00-trace"}, PublishedAt: publishedAt}, 0); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	deliveries, err := driver.Receive(ctx, "orders", 10)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("Receive() = %d deliveries, %v, want 1", len(deliveries), err)
+	}
+	msg := deliveries[0].Message()
+	if msg.ID != "order-1" || string(msg.Body) != "created" || msg.Attempt != 1 || msg.Headers["traceparent"] != "00-trace" || !msg.PublishedAt.Equal(publishedAt) {
+		t.Errorf("message = %+v", msg)
+	}
+
+	// Nacked messages are held for their delay, then requeued
+	start := time.Now()
+	if err := deliveries[0].Nack(ctx, 20*time.Millisecond); err != nil {
+		t.Fatalf("Nack() = %v", err)
+	}
+	deliveries, err = driver.Receive(ctx, "orders", 10)
+	if err != nil || len(deliveries) != 1 || deliveries[0].Message().Attempt != 2 {
+		t.Fatalf("Receive() = %d deliveries, %v, want the message nacked on attempt 2", len(deliveries), err)
+	}
+	if waited := time.Since(start); waited < 20*time.Millisecond {
+		t.Errorf("message redelivered after %s, want the delay of the nack", waited)
+	}
+	if err := deliveries[0].Ack(ctx); err != nil {
+		t.Fatalf("Ack() = %v", err)
+	}
+	if !deliveries[0].(*rabbitDelivery).delivery.Acknowledger.(*fakeAcknowledger).acked {
+		t.Error("message not acked")
+	}
+
+	if err := driver.Publish(ctx, "orders", &Message{}, time.Minute); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	delayed := <-fake.queues["orders"]
+	if fake.exchanges[len(fake.exchanges)-1] != "delayed" || delayed.Headers["x-delay"] != int64(60000) {
+		t.Errorf("delayed message published to %v with headers %v, want x-delay on the delay exchange", fake.exchanges, delayed.Headers)
+	}
+	driver.config.DelayExchange = ""
+	if err := driver.Publish(ctx, "orders", &Message{}, time.Minute); !errors.Is(err, ErrDelayUnsupported) {
+		t.Errorf("Publish() = %v, want ErrDelayUnsupported without delay exchange", err)
+	}
+
+	// A cancelled consumer fails, and the queue is consumed again
+	fake.cancel("orders")
+	if _, err := driver.Receive(ctx, "orders", 10); err == nil {
+		t.Fatal("Receive() = nil, want the error of the cancelled consumer")
+	}
+	// The connection lost, the driver reconnects
+	fake.drop()
+	if err := driver.Publish(ctx, "orders", &Message{Body: []byte("again")}, 0); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	deliveries, err = driver.Receive(ctx, "orders", 10)
+	if err != nil || len(deliveries) != 1 || string(deliveries[0].Message().Body) != "again" {
+		t.Fatalf("Receive() = %d deliveries, %v, want the message published once reconnected", len(deliveries), err)
+	}
+	if fake.dials != 2 {
+		t.Errorf("%d connections, want 2", fake.dials)
+	}
+
+	if _, err := driver.Receive(ctx, "payments", 10); err == nil {
+		t.Error("Receive() = nil, want the error of the unknown queue")
+	}
+	if err := driver.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if err := driver.Publish(ctx, "orders", &Message{}, 0); !errors.Is(err, ErrDriverClosed) {
+		t.Errorf("Publish() = %v, want ErrDriverClosed", err)
+	}
+}
@@ -0,0 +1,279 @@
+package ziqueue
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/divikraf/lumos/internal/awsv4"
+)
+
+// SQS message attributes carrying what SQS messages can't.
+const (
+	// sqsIDAttribute is the ID of the messages published with one.
+	sqsIDAttribute = "ziqueue-id"
+	// sqsEncodingAttribute is the encoding of the binary bodies.
+	sqsEncodingAttribute = "ziqueue-encoding"
+)
+
+// SQS limits
+const (
+	sqsMaxBatch      = 10
+	sqsMaxDelay      = 15 * time.Minute
+	sqsMaxVisibility = 12 * time.Hour
+)
+
+// SQSConfig configures the Amazon SQS driver.
+type SQSConfig struct {
+	// Region is the region of the queues (default: the AWS_REGION or
+	// AWS_DEFAULT_REGION variable).
+	Region string `json:"region" yaml:"region"`
+	// Endpoint is the URL of the service (default:
+	// https://sqs.<region>.amazonaws.com).
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+	// WaitTime is the long polling duration of the receive calls (default:
+	// 20s, the maximum).
+	WaitTime time.Duration `json:"wait_time" yaml:"wait_time"`
+	// AccessKeyID, SecretAccessKey and SessionToken are the credentials
+	// (default: the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+	// AWS_SESSION_TOKEN variables).
+	AccessKeyID     string `json:"access_key_id" yaml:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key" yaml:"secret_access_key"`
+	SessionToken    string `json:"session_token" yaml:"session_token"`
+}
+
+type sqsDriver struct {
+	config SQSConfig
+	client *http.Client
+	creds  awsv4.Credentials
+	now    func() time.Time
+
+	mu   sync.Mutex
+	urls map[string]string
+}
+
+// NewSQSDriver returns the Driver of the Amazon SQS standard queues, called
+// with the JSON protocol with client, or http.DefaultClient if nil. Queues
+// are named by name, their URL being looked up once, or by URL. The headers
+// of the messages are their string attributes, SQS keeping at most 10, and
+// the messages are redelivered by changing their visibility timeout, SQS
+// counting their attempts.
+func NewSQSDriver(config SQSConfig, client *http.Client) Driver {
+	if config.Region == "" {
+		config.Region = os.Getenv("AWS_REGION")
+	}
+	if config.Region == "" {
+		config.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = "https://sqs." + config.Region + ".amazonaws.com"
+	}
+	if config.WaitTime <= 0 {
+		config.WaitTime = 20 * time.Second
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &sqsDriver{config: config, client: client, now: time.Now, urls: map[string]string{}, creds: awsv4.CredentialsFromEnv(awsv4.Credentials{
+		AccessKeyID:     config.AccessKeyID,
+		SecretAccessKey: config.SecretAccessKey,
+		SessionToken:    config.SessionToken,
+	})}
+}
+
+type sqsAttribute struct {
+	DataType    string `json:"DataType"`
+	StringValue string `json:"StringValue"`
+}
+
+func (d *sqsDriver) Publish(ctx context.Context, queue string, msg *Message, delay time.Duration) error {
+	if delay > sqsMaxDelay {
+		return fmt.Errorf("%w: SQS delays messages at most %s, not %s", ErrDelayUnsupported, sqsMaxDelay, delay)
+	}
+	url, err := d.queueURL(ctx, queue)
+	if err != nil {
+		return err
+	}
+	attributes := make(map[string]sqsAttribute, len(msg.Headers)+2)
+	for k, v := range msg.Headers {
+		attributes[k] = sqsAttribute{DataType: "String", StringValue: v}
+	}
+	if msg.ID != "" {
+		attributes[sqsIDAttribute] = sqsAttribute{DataType: "String", StringValue: msg.ID}
+	}
+	body := string(msg.Body)
+	if !sqsText(msg.Body) {
+		body = base64.StdEncoding.EncodeToString(msg.Body)
+		attributes[sqsEncodingAttribute] = sqsAttribute{DataType: "String", StringValue: "base64"}
+	}
+	input := map[string]any{
+		"QueueUrl":     url,
+		"MessageBody":  body,
+		"DelaySeconds": int(delay.Seconds()),
+	}
+	if len(attributes) > 0 {
+		input["MessageAttributes"] = attributes
+	}
+	return d.call(ctx, "SendMessage", input, nil)
+}
+
+func (d *sqsDriver) Receive(ctx context.Context, queue string, max int) ([]Delivery, error) {
+	url, err := d.queueURL(ctx, queue)
+	if err != nil {
+		return nil, err
+	}
+	var output struct {
+		Messages []struct {
+			MessageID         string                  `json:"MessageId"`
+			ReceiptHandle     string                  `json:"ReceiptHandle"`
+			Body              string                  `json:"Body"`
+			Attributes        map[string]string       `json:"Attributes"`
+			MessageAttributes map[string]sqsAttribute `json:"MessageAttributes"`
+		} `json:"Messages"`
+	}
+	if err := d.call(ctx, "ReceiveMessage", map[string]any{
+		"QueueUrl":                    url,
+		"MaxNumberOfMessages":         min(max, sqsMaxBatch),
+		"WaitTimeSeconds":             int(d.config.WaitTime.Seconds()),
+		"MessageAttributeNames":       []string{"All"},
+		"MessageSystemAttributeNames": []string{"ApproximateReceiveCount", "SentTimestamp"},
+	}, &output); err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]Delivery, 0, len(output.Messages))
+	for _, m := range output.Messages {
+		msg := &Message{ID: m.MessageID, Queue: queue, Body: []byte(m.Body), Headers: map[string]string{}}
+		for k, a := range m.MessageAttributes {
+			msg.Headers[k] = a.StringValue
+		}
+		if id, ok := msg.Headers[sqsIDAttribute]; ok {
+			msg.ID = id
+			delete(msg.Headers, sqsIDAttribute)
+		}
+		if msg.Headers[sqsEncodingAttribute] == "base64" {
+			if msg.Body, err = base64.StdEncoding.DecodeString(m.Body); err != nil {
+				return nil, fmt.Errorf("ziqueue: invalid SQS message %s: %w", m.MessageID, err)
+			}
+			delete(msg.Headers, sqsEncodingAttribute)
+		}
+		if msg.Attempt, _ = strconv.Atoi(m.Attributes["ApproximateReceiveCount"]); msg.Attempt < 1 {
+			msg.Attempt = 1
+		}
+		if ms, err := strconv.ParseInt(m.Attributes["SentTimestamp"], 10, 64); err == nil {
+			msg.PublishedAt = time.UnixMilli(ms)
+		}
+		deliveries = append(deliveries, &sqsDelivery{driver: d, url: url, receipt: m.ReceiptHandle, msg: msg})
+	}
+	return deliveries, nil
+}
+
+func (d *sqsDriver) Close() error {
+	return nil
+}
+
+// queueURL returns the URL of queue, looked up by name unless it is one.
+func (d *sqsDriver) queueURL(ctx context.Context, queue string) (string, error) {
+	if strings.Contains(queue, "://") {
+		return queue, nil
+	}
+	d.mu.Lock()
+	url, ok := d.urls[queue]
+	d.mu.Unlock()
+	if ok {
+		return url, nil
+	}
+	var output struct {
+		QueueURL string `json:"QueueUrl"`
+	}
+	if err := d.call(ctx, "GetQueueUrl", map[string]string{"QueueName": queue}, &output); err != nil {
+		return "", err
+	}
+	d.mu.Lock()
+	d.urls[queue] = output.QueueURL
+	d.mu.Unlock()
+	return output.QueueURL, nil
+}
+
+// call calls action with the JSON input, and decodes its output in out, if
+// not nil. Error responses are returned as *Error.
+func (d *sqsDriver) call(ctx context.Context, action string, input, out any) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(d.config.Endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", "AmazonSQS."+action)
+	awsv4.Sign(req, body, d.creds, d.config.Region, "sqs", d.now())
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		_, code, _ := strings.Cut(e.Type, "#")
+		return &Error{System: SystemSQS, Status: resp.StatusCode, Code: code, Message: e.Message}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("ziqueue: invalid SQS %s response: %w", action, err)
+	}
+	return nil
+}
+
+// sqsText reports whether body only has the characters allowed in the body
+// of SQS messages.
+func sqsText(body []byte) bool {
+	if !utf8.Valid(body) {
+		return false
+	}
+	for _, r := range string(body) {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0xFFFE || r == 0xFFFF {
+			return false
+		}
+	}
+	return true
+}
+
+type sqsDelivery struct {
+	driver  *sqsDriver
+	url     string
+	receipt string
+	msg     *Message
+}
+
+func (s *sqsDelivery) Message() *Message { return s.msg }
+
+// Ack deletes the message.
+func (s *sqsDelivery) Ack(ctx context.Context) error {
+	return s.driver.call(ctx, "DeleteMessage", map[string]string{"QueueUrl": s.url, "ReceiptHandle": s.receipt}, nil)
+}
+
+// Nack makes the message visible again after delay, at most 12h.
+func (s *sqsDelivery) Nack(ctx context.Context, delay time.Duration) error {
+	return s.driver.call(ctx, "ChangeMessageVisibility", map[string]any{
+		"QueueUrl":          s.url,
+		"ReceiptHandle":     s.receipt,
+		"VisibilityTimeout": int(min(delay, sqsMaxVisibility).Seconds()),
+	}, nil)
+}
@@ -0,0 +1,183 @@
+package ziqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSQS serves the actions of the SQS JSON protocol used by the driver,
+// on queues kept in memory.
+type fakeSQS struct {
+	mu       sync.Mutex
+	url      string
+	queues   map[string][]*fakeSQSMessage
+	lookups  int
+	receipts int
+}
+
+type fakeSQSMessage struct {
+	id, receipt, body string
+	attributes        map[string]sqsAttribute
+	receives          int
+	visibleAt         time.Time
+}
+
+func (f *fakeSQS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	var in struct {
+		QueueName           string
+		QueueURL            string `json:"QueueUrl"`
+		MessageBody         string
+		MessageAttributes   map[string]sqsAttribute
+		DelaySeconds        int
+		MaxNumberOfMessages int
+		ReceiptHandle       string
+		VisibilityTimeout   int
+	}
+	json.NewDecoder(r.Body).Decode(&in)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	action := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "AmazonSQS.")
+	queue := strings.TrimPrefix(in.QueueURL, f.url+"/123456789012/")
+	if action == "GetQueueUrl" {
+		queue = in.QueueName
+	}
+	messages, ok := f.queues[queue]
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"__type": "com.amazonaws.sqs#QueueDoesNotExist", "message": "The specified queue does not exist."})
+		return
+	}
+
+	var out any = map[string]any{}
+	switch action {
+	case "GetQueueUrl":
+		f.lookups++
+		out = map[string]string{"QueueUrl": f.url + "/123456789012/" + in.QueueName}
+	case "SendMessage":
+		f.queues[queue] = append(messages, &fakeSQSMessage{
+			id:         strconv.Itoa(len(messages) + 1),
+			body:       in.MessageBody,
+			attributes: in.MessageAttributes,
+			visibleAt:  time.Now().Add(time.Duration(in.DelaySeconds) * time.Second),
+		})
+	case "ReceiveMessage":
+		var received []map[string]any
+		for _, m := range messages {
+			if len(received) == in.MaxNumberOfMessages || time.Now().Before(m.visibleAt) {
+				continue
+			}
+			f.receipts++
+			m.receives++
+			m.receipt = "receipt-" + strconv.Itoa(f.receipts)
+			m.visibleAt = time.Now().Add(30 * time.Second)
+			received = append(received, map[string]any{
+				"MessageId":     m.id,
+				"ReceiptHandle": m.receipt,
+				"Body":          m.body,
+				"Attributes": map[string]string{
+					"ApproximateReceiveCount": strconv.Itoa(m.receives),
+					"SentTimestamp":           "1700000000000",
+				},
+				"MessageAttributes": m.attributes,
+			})
+		}
+		out = map[string]any{"Messages": received}
+	case "DeleteMessage", "ChangeMessageVisibility":
+		for i, m := range messages {
+			if m.receipt != in.ReceiptHandle {
+				continue
+			}
+			if action == "DeleteMessage" {
+				f.queues[queue] = append(messages[:i:i], messages[i+1:]...)
+			} else {
+				m.visibleAt = time.Now().Add(time.Duration(in.VisibilityTimeout) * time.Second)
+			}
+		}
+	}
+	json.NewEncoder(w).Encode(out)
+}
+
+func TestSQSDriver(t *testing.T) {
+	fake := &fakeSQS{queues: map[string][]*fakeSQSMessage{"orders": nil}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	fake.url = srv.URL
+	driver := NewSQSDriver(SQSConfig{
+		Region:          "eu-west-1",
+		Endpoint:        srv.URL,
+		WaitTime:        time.Second,
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+	}, srv.Client())
+	ctx := context.Background()
+
+	binary := []byte{0xff, 0x00, 'o', 'k'}
+	if err := driver.Publish(ctx, "orders", &Message{ID: "order-1", Body: binary, Headers: map[string]string{"traceparent": "00-trace"}}, 0); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	if err := driver.Publish(ctx, "orders", &Message{Body: []byte("later")}, time.Minute); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	if err := driver.Publish(ctx, "orders", &Message{}, time.Hour); !errors.Is(err, ErrDelayUnsupported) {
+		t.Errorf("Publish() = %v, want ErrDelayUnsupported beyond 15m", err)
+	}
+
+	deliveries, err := driver.Receive(ctx, "orders", 10)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("Receive() = %d deliveries, %v, want the message not delayed", len(deliveries), err)
+	}
+	msg := deliveries[0].Message()
+	if msg.ID != "order-1" || string(msg.Body) != string(binary) || msg.Attempt != 1 || msg.Headers["traceparent"] != "00-trace" || len(msg.Headers) != 1 ||
+		!msg.PublishedAt.Equal(time.UnixMilli(1700000000000)) {
+		t.Errorf("message = %+v", msg)
+	}
+	if err := deliveries[0].Nack(ctx, 0); err != nil {
+		t.Fatalf("Nack() = %v", err)
+	}
+	deliveries, err = driver.Receive(ctx, "orders", 10)
+	if err != nil || len(deliveries) != 1 || deliveries[0].Message().Attempt != 2 {
+		t.Fatalf("Receive() = %d deliveries, %v, want the message nacked on attempt 2", len(deliveries), err)
+	}
+	if err := deliveries[0].Ack(ctx); err != nil {
+		t.Fatalf("Ack() = %v", err)
+	}
+	if n := len(fake.queues["orders"]); n != 1 {
+		t.Errorf("%d messages left, want the delayed one", n)
+	}
+	if fake.lookups != 1 {
+		t.Errorf("queue URL looked up %d times, want once", fake.lookups)
+	}
+
+	var e *Error
+	if err := driver.Publish(ctx, "payments", &Message{}, 0); !errors.As(err, &e) || e.Code != "QueueDoesNotExist" || e.ErrorType() != "QueueDoesNotExist" {
+		t.Errorf("Publish() = %v, want QueueDoesNotExist", err)
+	}
+}
+
+func TestSQSText(t *testing.T) {
+	for body, want := range map[string]bool{
+		"{\"id\": 1}\n": true,
+		"café \t":       true,
+		"\x00":          false,
+		"\xff":          false,
+		"bell \a":       false,
+		"\uffff":        false,
+		"\U0001F600 ok": true,
+	} {
+		if got := sqsText([]byte(body)); got != want {
+			t.Errorf("sqsText(%q) = %t, want %t", body, got, want)
+		}
+	}
+}
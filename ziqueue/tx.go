@@ -0,0 +1,120 @@
+package ziqueue
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/divikraf/lumos/db/zisqlx"
+	"github.com/divikraf/lumos/zidlq"
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// DefaultMaxAttempts is the default number of attempts of a message before
+// it is considered poison.
+const DefaultMaxAttempts = 5
+
+// TxHandler handles a message within the transaction tx.
+type TxHandler func(ctx context.Context, tx zisqlx.TxInterface, msg *Message) error
+
+// TxOption configures Transactional.
+type TxOption func(*txConfig)
+
+type txConfig struct {
+	maxAttempts int
+	deadLetter  *zidlq.Manager
+	txOptions   *sql.TxOptions
+}
+
+// WithMaxAttempts sets the number of attempts after which a failing message
+// is poison (default: DefaultMaxAttempts).
+func WithMaxAttempts(n int) TxOption {
+	return func(c *txConfig) {
+		c.maxAttempts = n
+	}
+}
+
+// WithDeadLetter dead-letters poison messages in m, under their queue name.
+func WithDeadLetter(m *zidlq.Manager) TxOption {
+	return func(c *txConfig) {
+		c.deadLetter = m
+	}
+}
+
+// WithTxOptions sets the options of the transactions, e.g. their isolation
+// level.
+func WithTxOptions(opts *sql.TxOptions) TxOption {
+	return func(c *txConfig) {
+		c.txOptions = opts
+	}
+}
+
+// Transactional returns a Handler running handle in a transaction of db,
+// named operationName: the message is acked once the transaction commits,
+// and nacked to be retried when it rolls back, so no message is acked
+// without its writes. A message whose ack is lost after the commit is
+// redelivered, so writes should still be idempotent, e.g. keyed by the
+// message ID.
+//
+// Messages failing with a Permanent error, or on their last attempt, are
+// poison: they are dead-lettered and acked when WithDeadLetter is set, and
+// left to the queue redrive policy otherwise. Poison messages are counted in
+// messaging_poison_messages_total.
+func Transactional(db zisqlx.TxBeginner, operationName string, handle TxHandler, opts ...TxOption) Handler {
+	config := txConfig{maxAttempts: DefaultMaxAttempts}
+	for _, o := range opts {
+		o(&config)
+	}
+	poison := revelio.MustInt64Counter(
+		"messaging_poison_messages_total",
+		"Number of poison messages, failing permanently or on their last attempt, by queue",
+	)
+
+	return func(ctx context.Context, msg *Message) error {
+		tx, err := db.BeginTx(ctx, operationName, config.txOptions)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				_ = tx.Rollback()
+				panic(r)
+			}
+		}()
+
+		if err := handle(ctx, tx, msg); err != nil {
+			_ = tx.Rollback()
+			if !IsPermanent(err) && msg.Attempt < config.maxAttempts {
+				return err
+			}
+			poison.Add(ctx, 1, metric.WithAttributes(revelio.MessagingDestinationKey.String(msg.Queue)))
+			return deadLetter(ctx, config.deadLetter, msg, err)
+		}
+		return tx.Commit()
+	}
+}
+
+// deadLetter dead-letters the poison message msg failing with cause, or
+// returns cause without dead-letter manager.
+func deadLetter(ctx context.Context, m *zidlq.Manager, msg *Message, cause error) error {
+	logger := zilog.FromContext(ctx).With().
+		Str("queue", msg.Queue).
+		Str("message_id", msg.ID).
+		Int("attempt", msg.Attempt).
+		Logger()
+	if m == nil {
+		logger.Error().Err(cause).Msg("poison message, left to the queue redrive policy")
+		return cause
+	}
+	if err := m.Dead(ctx, zidlq.Message{
+		Queue:    msg.Queue,
+		Payload:  msg.Body,
+		Headers:  msg.Headers,
+		Attempts: msg.Attempt,
+	}, cause); err != nil {
+		return err
+	}
+	logger.Warn().Err(cause).Msg("poison message dead-lettered")
+	return nil
+}
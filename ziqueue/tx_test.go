@@ -0,0 +1,92 @@
+package ziqueue
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/divikraf/lumos/db/zisqlx"
+	"github.com/divikraf/lumos/zidlq"
+	"github.com/rs/zerolog"
+)
+
+// fakeTx records how its transaction ended.
+type fakeTx struct {
+	zisqlx.TxInterface
+	committed, rolledBack bool
+}
+
+func (t *fakeTx) Commit() error   { t.committed = true; return nil }
+func (t *fakeTx) Rollback() error { t.rolledBack = true; return nil }
+
+type fakeDB struct {
+	txs []*fakeTx
+}
+
+func (d *fakeDB) BeginTx(ctx context.Context, operationName string, opts *sql.TxOptions) (zisqlx.TxInterface, error) {
+	tx := &fakeTx{}
+	d.txs = append(d.txs, tx)
+	return tx, nil
+}
+
+func (d *fakeDB) last() *fakeTx { return d.txs[len(d.txs)-1] }
+
+func TestTransactional(t *testing.T) {
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	dlq, err := zidlq.New(zidlq.NewMemoryStore(), &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dlq.Close()
+
+	db := &fakeDB{}
+	failure := errors.New("deadlock")
+	var fail error
+	handler := Transactional(db, "save_order", func(ctx context.Context, tx zisqlx.TxInterface, msg *Message) error {
+		return fail
+	}, WithMaxAttempts(3), WithDeadLetter(dlq))
+
+	if err := handler(ctx, &Message{ID: "m-1", Queue: "orders", Attempt: 1}); err != nil {
+		t.Fatalf("handler() = %v", err)
+	}
+	if tx := db.last(); !tx.committed || tx.rolledBack {
+		t.Errorf("tx = %+v, want committed", tx)
+	}
+
+	fail = failure
+	if err := handler(ctx, &Message{ID: "m-2", Queue: "orders", Attempt: 1}); !errors.Is(err, failure) {
+		t.Errorf("handler() = %v, want the failure so the message is nacked", err)
+	}
+	if tx := db.last(); tx.committed || !tx.rolledBack {
+		t.Errorf("tx = %+v, want rolled back", tx)
+	}
+
+	// Last attempt
+	if err := handler(ctx, &Message{ID: "m-2", Queue: "orders", Body: []byte(`{}`), Attempt: 3}); err != nil {
+		t.Errorf("handler() of a poison message = %v, want it acked", err)
+	}
+	// Permanent failure on the first attempt
+	fail = Permanent(errors.New("invalid payload"))
+	if err := handler(ctx, &Message{ID: "m-3", Queue: "orders", Attempt: 1}); err != nil {
+		t.Errorf("handler() of a permanent failure = %v, want it acked", err)
+	}
+
+	dead, err := dlq.List(ctx, "orders", zidlq.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 2 || dead[0].Error != "deadlock" || dead[0].Attempts != 3 || dead[1].Error != "invalid payload" {
+		t.Errorf("dead messages = %+v", dead)
+	}
+
+	t.Run("without dead letter", func(t *testing.T) {
+		handler := Transactional(db, "save_order", func(context.Context, zisqlx.TxInterface, *Message) error {
+			return Permanent(failure)
+		})
+		if err := handler(ctx, &Message{ID: "m-4", Queue: "orders", Attempt: 1}); !errors.Is(err, failure) || !IsPermanent(err) {
+			t.Errorf("handler() = %v, want the permanent failure left to the queue", err)
+		}
+	})
+}
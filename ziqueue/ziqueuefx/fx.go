@@ -0,0 +1,156 @@
+package ziqueuefx
+
+import (
+	"context"
+	"sync"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zidlq"
+	"github.com/divikraf/lumos/zihttpc"
+	"github.com/divikraf/lumos/ziqueue"
+	"github.com/rs/zerolog"
+	"go.uber.org/fx"
+)
+
+// Config configures the broker.
+type Config struct {
+	// System is the queue system of the driver, e.g. ziqueue.SystemSQS
+	// (default: ziqueue.SystemMemory).
+	System string `json:"system" yaml:"system"`
+	// SQS, RabbitMQ, PubSub and HTTP configure the driver of the system,
+	// see ziqueue.DriverConfig.
+	SQS      ziqueue.SQSConfig      `json:"sqs" yaml:"sqs"`
+	RabbitMQ ziqueue.RabbitMQConfig `json:"rabbitmq" yaml:"rabbitmq"`
+	PubSub   ziqueue.PubSubConfig   `json:"pubsub" yaml:"pubsub"`
+	HTTP     zihttpc.ClientConfig   `json:"http" yaml:"http"`
+	// Subscriptions are the subscriptions by queue.
+	Subscriptions map[string]ziqueue.SubscriptionConfig `json:"subscriptions" yaml:"subscriptions"`
+}
+
+// queueConfig is implemented by configs of services using queues.
+type queueConfig interface {
+	GetQueue() Config
+}
+
+func configOf(c ziconf.Config) Config {
+	var config Config
+	if qc, ok := c.(queueConfig); ok {
+		config = qc.GetQueue()
+	}
+	if config.System == "" {
+		config.System = ziqueue.SystemMemory
+	}
+	return config
+}
+
+// Subscription is the handler of a queue, provide it in the
+// "ziqueue-subscriptions" group.
+type Subscription struct {
+	Queue   string
+	Handler ziqueue.Handler
+}
+
+type brokerParams struct {
+	fx.In
+
+	LC         fx.Lifecycle
+	Config     ziconf.Config
+	Driver     ziqueue.Driver `optional:"true"`
+	DeadLetter *zidlq.Manager `optional:"true"`
+}
+
+// Provider provides a *ziqueue.Broker, using the ziqueue.Driver provided or
+// the driver of the configured system, see ziqueue.OpenDriver. Poison
+// messages are dead-lettered when a *zidlq.Manager is provided.
+var Provider = fx.Provide(
+	func(params brokerParams) (*ziqueue.Broker, error) {
+		config := configOf(params.Config)
+		driver := params.Driver
+		if driver == nil {
+			var err error
+			driver, err = ziqueue.OpenDriver(config.System, ziqueue.DriverConfig{
+				SQS:      config.SQS,
+				RabbitMQ: config.RabbitMQ,
+				PubSub:   config.PubSub,
+				HTTP:     config.HTTP,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		b := ziqueue.NewBroker(config.System, driver, params.DeadLetter)
+		params.LC.Append(fx.StopHook(b.Close))
+		return b, nil
+	},
+)
+
+type subscribeParams struct {
+	fx.In
+
+	LC            fx.Lifecycle
+	Config        ziconf.Config
+	Broker        *ziqueue.Broker
+	Logger        *zerolog.Logger
+	Subscriptions []Subscription `group:"ziqueue-subscriptions"`
+}
+
+// Invoker subscribes the handlers of the subscriptions group on start, with
+// the subscription config of their queue, and waits for the messages being
+// handled on stop.
+var Invoker = fx.Invoke(
+	func(params subscribeParams) {
+		if len(params.Subscriptions) == 0 {
+			return
+		}
+		config := configOf(params.Config)
+		ctx, cancel := context.WithCancel(params.Logger.WithContext(context.Background()))
+		var wg sync.WaitGroup
+
+		params.LC.Append(fx.StartHook(func() {
+			for _, s := range params.Subscriptions {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := params.Broker.Subscribe(ctx, s.Queue, s.Handler, config.Subscriptions[s.Queue]); err != nil {
+						params.Logger.Error().Err(err).Str("queue", s.Queue).Msg("queue subscription stopped")
+					}
+				}()
+			}
+		}))
+		params.LC.Append(fx.StopHook(func(stopCtx context.Context) error {
+			cancel()
+			done := make(chan struct{})
+			go func() {
+				wg.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+				return nil
+			case <-stopCtx.Done():
+				return stopCtx.Err()
+			}
+		}))
+	},
+)
+
+type subscriptionResult struct {
+	fx.Out
+
+	Subscription Subscription `group:"ziqueue-subscriptions"`
+}
+
+// WithSubscription subscribes handler to queue.
+func WithSubscription(queue string, handler ziqueue.Handler) fx.Option {
+	return fx.Provide(func() subscriptionResult {
+		return subscriptionResult{
+			Subscription: Subscription{Queue: queue, Handler: handler},
+		}
+	})
+}
+
+// AsSubscription annotates a constructor of a Subscription, e.g. a handler
+// with dependencies, to provide it in the subscriptions group.
+func AsSubscription(constructor any) any {
+	return fx.Annotate(constructor, fx.ResultTags(`group:"ziqueue-subscriptions"`))
+}
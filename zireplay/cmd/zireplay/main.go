@@ -0,0 +1,128 @@
+// Command zireplay replays recorded requests against a deployment and reports
+// the responses differing from the recorded ones:
+//
+//	zireplay -target https://orders.staging.internal -rate 20 -ignore-fields id,created_at records.jsonl
+//
+// It exits with status 1 when -fail-on-diff is set and a response differs.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/divikraf/lumos/zihttpc"
+	"github.com/divikraf/lumos/zireplay"
+)
+
+func main() {
+	target := flag.String("target", "", "base URL of the deployment receiving the requests")
+	rate := flag.Float64("rate", 10, "maximum requests per second, 0 for no limit")
+	concurrency := flag.Int("concurrency", 4, "number of requests in flight")
+	ignoreFields := flag.String("ignore-fields", "", "comma separated JSON response fields not compared")
+	header := flag.String("header", "", "comma separated Name:Value headers added to every request")
+	retries := flag.Int("retries", 1, "maximum attempts of failed requests")
+	timeout := flag.Duration("timeout", 10*time.Second, "timeout of each request")
+	failOnDiff := flag.Bool("fail-on-diff", false, "exit with status 1 when a response differs")
+	flag.Parse()
+
+	if *target == "" || flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: zireplay -target URL [flags] FILE...")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	config := zireplay.ReplayConfig{
+		Target:      *target,
+		Rate:        *rate,
+		Concurrency: *concurrency,
+		Header:      http.Header{},
+	}
+	if *ignoreFields != "" {
+		config.IgnoreFields = strings.Split(*ignoreFields, ",")
+	}
+	if *header != "" {
+		for _, h := range strings.Split(*header, ",") {
+			name, value, ok := strings.Cut(h, ":")
+			if !ok {
+				fatal(fmt.Errorf("invalid header %q", h))
+			}
+			config.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+	}
+
+	retry := zihttpc.DefaultRetryConfig()
+	retry.MaxAttempts = *retries
+	client := &http.Client{
+		Transport: zihttpc.NewRetryTransport(http.DefaultTransport, retry),
+		Timeout:   *timeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	records := make(chan zireplay.Record)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(records)
+		for _, path := range flag.Args() {
+			if err := readFile(ctx, path, records); err != nil {
+				readErr <- err
+				return
+			}
+		}
+		readErr <- nil
+	}()
+
+	var replayed, failed, differing int
+	zireplay.NewReplayer(client, config).Replay(ctx, records, func(r zireplay.Result) {
+		replayed++
+		switch {
+		case r.Err != nil:
+			failed++
+			fmt.Printf("FAIL %s %s: %v\n", r.Record.Method, r.Record.URL, r.Err)
+		case len(r.Diffs) > 0:
+			differing++
+			fmt.Printf("DIFF %s %s\n", r.Record.Method, r.Record.URL)
+			for _, d := range r.Diffs {
+				fmt.Printf("\t%s\n", d)
+			}
+		}
+	})
+	stop()
+	if err := <-readErr; err != nil {
+		fatal(err)
+	}
+
+	fmt.Printf("replayed %d requests: %d differing, %d failed\n", replayed, differing, failed)
+	if *failOnDiff && (differing > 0 || failed > 0) {
+		os.Exit(1)
+	}
+}
+
+// readFile sends the records of the file at path to records.
+func readFile(ctx context.Context, path string, records chan<- zireplay.Record) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return zireplay.ReadRecords(f, func(r zireplay.Record) bool {
+		select {
+		case records <- r:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,87 @@
+// Package zireplay captures sampled, sanitized inbound HTTP requests and
+// replays them against another deployment, typically staging, diffing the
+// responses to catch regressions before a release.
+package zireplay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Record is a captured request and the response it got.
+type Record struct {
+	Time   time.Time   `json:"time"`
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+	// Truncated is true when the body was larger than the capture limit,
+	// such records are not replayed.
+	Truncated bool `json:"truncated,omitempty"`
+
+	Status       int    `json:"status"`
+	ResponseBody []byte `json:"response_body,omitempty"`
+	// ResponseTruncated is true when the response body was larger than the
+	// capture limit, only the status of such records is compared.
+	ResponseTruncated bool `json:"response_truncated,omitempty"`
+}
+
+// Sink stores records.
+type Sink interface {
+	Write(ctx context.Context, r Record) error
+}
+
+// FileSink appends records to a file, as JSON lines.
+type FileSink struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// NewFileSink returns a FileSink appending to the file at path, created when
+// missing.
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (s *FileSink) Write(_ context.Context, r Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(r)
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+// ReadRecords decodes the JSON lines records of r, calling fn for each of
+// them until fn returns false.
+func ReadRecords(r io.Reader, fn func(Record) bool) error {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var rec Record
+		if err := dec.Decode(&rec); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if !fn(rec) {
+			return nil
+		}
+	}
+}
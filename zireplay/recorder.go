@@ -0,0 +1,266 @@
+package zireplay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Redacted replaces sanitized values.
+const Redacted = "[REDACTED]"
+
+// RecorderConfig holds configuration for the traffic recorder.
+type RecorderConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+
+	// SampleRate is the fraction of requests captured, from 0 to 1.
+	SampleRate float64 `json:"sample_rate" yaml:"sample_rate"`
+
+	// MaxBodySize caps the captured request and response bodies, in bytes
+	// (default: 64KiB).
+	MaxBodySize int `json:"max_body_size" yaml:"max_body_size"`
+
+	// QueueSize is the number of records buffered before being dropped when
+	// the sink is slow (default: 1024).
+	QueueSize int `json:"queue_size" yaml:"queue_size"`
+
+	// RedactHeaders are the headers whose values are redacted (default:
+	// DefaultRedactHeaders).
+	RedactHeaders []string `json:"redact_headers" yaml:"redact_headers"`
+
+	// RedactFields are the fragments of query parameters and JSON body field
+	// names whose values are redacted (default: DefaultRedactFields).
+	RedactFields []string `json:"redact_fields" yaml:"redact_fields"`
+
+	// ExcludedPaths are request paths never captured.
+	ExcludedPaths []string `json:"excluded_paths" yaml:"excluded_paths"`
+}
+
+// DefaultRedactHeaders are the headers redacted by default.
+var DefaultRedactHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization", "X-Api-Key"}
+
+// DefaultRedactFields are the field name fragments redacted by default.
+var DefaultRedactFields = []string{"password", "secret", "token", "api_key", "apikey", "credit_card", "card_number", "cvv", "ssn"}
+
+// DefaultRecorderConfig returns the default configuration of the recorder,
+// capturing 1% of the requests once enabled.
+func DefaultRecorderConfig() RecorderConfig {
+	return RecorderConfig{
+		SampleRate:    0.01,
+		MaxBodySize:   64 << 10,
+		QueueSize:     1024,
+		RedactHeaders: DefaultRedactHeaders,
+		RedactFields:  DefaultRedactFields,
+	}
+}
+
+// Recorder captures requests to a Sink, in the background.
+type Recorder struct {
+	sink     Sink
+	config   RecorderConfig
+	logger   *zerolog.Logger
+	queue    chan Record
+	done     chan struct{}
+	excluded map[string]bool
+	counter  metric.Int64Counter
+}
+
+// NewRecorder returns a Recorder writing to sink. Close must be called to
+// flush the buffered records.
+func NewRecorder(sink Sink, config RecorderConfig, logger *zerolog.Logger) *Recorder {
+	defaults := DefaultRecorderConfig()
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = defaults.MaxBodySize
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaults.QueueSize
+	}
+	if config.RedactHeaders == nil {
+		config.RedactHeaders = defaults.RedactHeaders
+	}
+	if config.RedactFields == nil {
+		config.RedactFields = defaults.RedactFields
+	}
+
+	r := &Recorder{
+		sink:     sink,
+		config:   config,
+		logger:   logger,
+		queue:    make(chan Record, config.QueueSize),
+		done:     make(chan struct{}),
+		excluded: make(map[string]bool, len(config.ExcludedPaths)),
+		counter: revelio.MustInt64Counter(
+			"replay_recorded_requests_total",
+			"Number of requests captured for replay, by status",
+		),
+	}
+	for _, path := range config.ExcludedPaths {
+		r.excluded[path] = true
+	}
+	go r.run()
+	return r
+}
+
+func (r *Recorder) run() {
+	defer close(r.done)
+	for rec := range r.queue {
+		status := "recorded"
+		if err := r.sink.Write(context.Background(), rec); err != nil {
+			status = "failed"
+			r.logger.Warn().Err(err).Msg("failed to write replay record")
+		}
+		r.counter.Add(context.Background(), 1, metric.WithAttributes(attribute.String("status", status)))
+	}
+}
+
+// Close flushes the buffered records, the middleware must not be used
+// anymore.
+func (r *Recorder) Close(ctx context.Context) error {
+	close(r.queue)
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Middleware captures a sample of the requests, unless disabled. Captured
+// requests are sanitized: redacted headers, query parameters and JSON body
+// fields have their values replaced with Redacted.
+func (r *Recorder) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !r.config.Enabled || r.excluded[c.Request.URL.Path] || rand.Float64() >= r.config.SampleRate {
+			c.Next()
+			return
+		}
+
+		rec := Record{
+			Time:   time.Now(),
+			Method: c.Request.Method,
+			URL:    r.sanitizeURL(c.Request.URL),
+			Header: r.sanitizeHeader(c.Request.Header),
+		}
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			body, err := io.ReadAll(io.LimitReader(c.Request.Body, int64(r.config.MaxBodySize)+1))
+			c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(body), c.Request.Body), c.Request.Body}
+			if err == nil {
+				rec.Truncated = len(body) > r.config.MaxBodySize
+				rec.Body = r.sanitizeBody(body[:min(len(body), r.config.MaxBodySize)])
+			}
+		}
+
+		w := &captureWriter{ResponseWriter: c.Writer, max: r.config.MaxBodySize}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		rec.Status = c.Writer.Status()
+		rec.ResponseBody = r.sanitizeBody(w.body.Bytes())
+		rec.ResponseTruncated = w.truncated
+		select {
+		case r.queue <- rec:
+		default:
+			r.counter.Add(c.Request.Context(), 1, metric.WithAttributes(attribute.String("status", "dropped")))
+		}
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// captureWriter keeps the beginning of the response body.
+type captureWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	room := w.max - w.body.Len()
+	w.body.Write(p[:max(0, min(len(p), room))])
+	w.truncated = w.truncated || len(p) > room
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (r *Recorder) sensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, f := range r.config.RedactFields {
+		if strings.Contains(name, f) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Recorder) sanitizeHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range r.config.RedactHeaders {
+		if _, ok := out[http.CanonicalHeaderKey(name)]; ok {
+			out.Set(name, Redacted)
+		}
+	}
+	return out
+}
+
+func (r *Recorder) sanitizeURL(u *url.URL) string {
+	q := u.Query()
+	for name := range q {
+		if r.sensitive(name) {
+			q.Set(name, Redacted)
+		}
+	}
+	out := url.URL{Path: u.Path, RawQuery: q.Encode()}
+	return out.String()
+}
+
+// sanitizeBody redacts the sensitive fields of JSON bodies, other bodies are
+// kept as is.
+func (r *Recorder) sanitizeBody(body []byte) []byte {
+	var v any
+	if len(body) == 0 || json.Unmarshal(body, &v) != nil {
+		return body
+	}
+	sanitized, err := json.Marshal(r.sanitizeValue(v))
+	if err != nil {
+		return body
+	}
+	return sanitized
+}
+
+func (r *Recorder) sanitizeValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, val := range v {
+			if r.sensitive(k) {
+				v[k] = Redacted
+			} else {
+				v[k] = r.sanitizeValue(val)
+			}
+		}
+	case []any:
+		for i, val := range v {
+			v[i] = r.sanitizeValue(val)
+		}
+	}
+	return v
+}
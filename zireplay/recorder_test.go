@@ -0,0 +1,114 @@
+package zireplay
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type memorySink struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+func (s *memorySink) Write(_ context.Context, r Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, r)
+	return nil
+}
+
+func TestRecorder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := reveliotest.NewDefaultTestScope(t)
+	logger := zerolog.Nop()
+	sink := &memorySink{}
+	recorder := NewRecorder(sink, RecorderConfig{
+		Enabled:       true,
+		SampleRate:    1,
+		MaxBodySize:   64,
+		ExcludedPaths: []string{"/health"},
+	}, &logger)
+
+	var handlerBody string
+	r := gin.New()
+	r.Use(recorder.Middleware())
+	r.POST("/login", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		handlerBody = string(body)
+		c.JSON(http.StatusOK, gin.H{"user": "ada", "access_token": "t0k3n"})
+	})
+	r.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/large", func(c *gin.Context) { c.String(http.StatusOK, strings.Repeat("x", 100)) })
+
+	req := httptest.NewRequest(http.MethodPost, "/login?api_key=abc&page=2", strings.NewReader(`{"user":"ada","password":"hunter2"}`))
+	req.Header.Set("Authorization", "Bearer abc")
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/large", nil))
+
+	if err := recorder.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if handlerBody != `{"user":"ada","password":"hunter2"}` {
+		t.Errorf("handler body = %q", handlerBody)
+	}
+	if len(sink.records) != 2 {
+		t.Fatalf("got %d records, want 2", len(sink.records))
+	}
+
+	rec := sink.records[0]
+	if rec.URL != "/login?api_key=%5BREDACTED%5D&page=2" {
+		t.Errorf("URL = %q", rec.URL)
+	}
+	if got := rec.Header.Get("Authorization"); got != Redacted {
+		t.Errorf("Authorization = %q", got)
+	}
+	if got := rec.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if string(rec.Body) != `{"password":"[REDACTED]","user":"ada"}` {
+		t.Errorf("body = %s", rec.Body)
+	}
+	if string(rec.ResponseBody) != `{"access_token":"[REDACTED]","user":"ada"}` {
+		t.Errorf("response body = %s", rec.ResponseBody)
+	}
+	if rec.Status != http.StatusOK || rec.ResponseTruncated {
+		t.Errorf("status = %d, truncated = %v", rec.Status, rec.ResponseTruncated)
+	}
+
+	large := sink.records[1]
+	if !large.ResponseTruncated || len(large.ResponseBody) != 64 {
+		t.Errorf("truncated = %v, len = %d", large.ResponseTruncated, len(large.ResponseBody))
+	}
+	reveliotest.AssertCounterValue(t, s, "replay_recorded_requests_total", 2, attribute.String("status", "recorded"))
+}
+
+func TestRecorderDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := zerolog.Nop()
+	sink := &memorySink{}
+	recorder := NewRecorder(sink, RecorderConfig{SampleRate: 1}, &logger)
+
+	r := gin.New()
+	r.Use(recorder.Middleware())
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if err := recorder.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.records) != 0 {
+		t.Errorf("got %d records, want 0", len(sink.records))
+	}
+}
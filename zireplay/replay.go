@@ -0,0 +1,222 @@
+package zireplay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxResponseSize caps the size of replayed responses read.
+const maxResponseSize = 16 << 20
+
+// ReplayConfig holds configuration for replaying records.
+type ReplayConfig struct {
+	// Target is the base URL of the deployment receiving the requests, e.g.
+	// "https://orders.staging.internal".
+	Target string
+
+	// Rate is the maximum number of requests per second, 0 for no limit.
+	Rate float64
+
+	// Concurrency is the number of requests in flight (default: 1).
+	Concurrency int
+
+	// IgnoreFields are the JSON response fields not compared, at any depth,
+	// e.g. generated IDs and timestamps.
+	IgnoreFields []string
+
+	// Header is added to every replayed request, e.g. to mark them as
+	// replays or authenticate them, redacted headers having been lost.
+	Header http.Header
+}
+
+// Result is the outcome of a replayed record.
+type Result struct {
+	Record Record
+	Status int
+	Body   []byte
+	// Err is set when the request failed.
+	Err error
+	// Diffs describe the differences between the recorded and the replayed
+	// responses, empty when they match.
+	Diffs []string
+}
+
+// Replayer re-issues recorded requests.
+type Replayer struct {
+	client *http.Client
+	config ReplayConfig
+}
+
+// NewReplayer returns a Replayer sending requests with client, typically
+// built with zihttpc transports.
+func NewReplayer(client *http.Client, config ReplayConfig) *Replayer {
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+	return &Replayer{client: client, config: config}
+}
+
+// Replay replays the records received from records, until it is closed or
+// ctx is done, calling report with the result of each of them. Truncated
+// records are skipped. Report calls are serialized.
+func (r *Replayer) Replay(ctx context.Context, records <-chan Record, report func(Result)) {
+	var tick <-chan time.Time
+	if r.config.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / r.config.Rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var (
+		wg       sync.WaitGroup
+		reportMu sync.Mutex
+		sem      = make(chan struct{}, r.config.Concurrency)
+	)
+	defer wg.Wait()
+	for {
+		var rec Record
+		var ok bool
+		select {
+		case rec, ok = <-records:
+			if !ok {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+		if rec.Truncated {
+			continue
+		}
+		if tick != nil {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			result := r.replay(ctx, rec)
+			reportMu.Lock()
+			report(result)
+			reportMu.Unlock()
+		}()
+	}
+}
+
+func (r *Replayer) replay(ctx context.Context, rec Record) Result {
+	result := Result{Record: rec}
+	req, err := http.NewRequestWithContext(ctx, rec.Method, strings.TrimSuffix(r.config.Target, "/")+rec.URL, bytes.NewReader(rec.Body))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	for name, values := range rec.Header {
+		if values[0] == Redacted {
+			continue
+		}
+		req.Header[name] = values
+	}
+	for name, values := range r.config.Header {
+		req.Header[http.CanonicalHeaderKey(name)] = values
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer resp.Body.Close()
+	result.Status = resp.StatusCode
+	result.Body, result.Err = io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if rec.ResponseTruncated {
+		result.Diffs = Compare(rec.Status, nil, result.Status, nil)
+	} else {
+		result.Diffs = Compare(rec.Status, rec.ResponseBody, result.Status, result.Body, r.config.IgnoreFields...)
+	}
+	return result
+}
+
+// Compare returns the differences between two responses. JSON bodies are
+// compared field by field, ignoring ignoreFields at any depth, other bodies
+// byte for byte.
+func Compare(wantStatus int, wantBody []byte, gotStatus int, gotBody []byte, ignoreFields ...string) []string {
+	var diffs []string
+	if wantStatus != gotStatus {
+		diffs = append(diffs, fmt.Sprintf("status: %d != %d", wantStatus, gotStatus))
+	}
+
+	var want, got any
+	if json.Unmarshal(wantBody, &want) != nil || json.Unmarshal(gotBody, &got) != nil {
+		if !bytes.Equal(wantBody, gotBody) {
+			diffs = append(diffs, "body differs")
+		}
+		return diffs
+	}
+	ignored := make(map[string]bool, len(ignoreFields))
+	for _, f := range ignoreFields {
+		ignored[f] = true
+	}
+	return compareJSON(diffs, "body", want, got, ignored)
+}
+
+func compareJSON(diffs []string, path string, want, got any, ignored map[string]bool) []string {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			break
+		}
+		for k, wv := range w {
+			if ignored[k] {
+				continue
+			}
+			gv, ok := g[k]
+			if !ok {
+				diffs = append(diffs, fmt.Sprintf("%s.%s: missing", path, k))
+				continue
+			}
+			diffs = compareJSON(diffs, path+"."+k, wv, gv, ignored)
+		}
+		for k := range g {
+			if _, ok := w[k]; !ok && !ignored[k] {
+				diffs = append(diffs, fmt.Sprintf("%s.%s: unexpected", path, k))
+			}
+		}
+		return diffs
+	case []any:
+		g, ok := got.([]any)
+		if !ok {
+			break
+		}
+		if len(w) != len(g) {
+			return append(diffs, fmt.Sprintf("%s: %d items != %d items", path, len(w), len(g)))
+		}
+		for i := range w {
+			diffs = compareJSON(diffs, fmt.Sprintf("%s[%d]", path, i), w[i], g[i], ignored)
+		}
+		return diffs
+	}
+	if !reflect.DeepEqual(want, got) {
+		w, _ := json.Marshal(want)
+		g, _ := json.Marshal(got)
+		diffs = append(diffs, fmt.Sprintf("%s: %s != %s", path, w, g))
+	}
+	return diffs
+}
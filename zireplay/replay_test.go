@@ -0,0 +1,115 @@
+package zireplay
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReplayer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("redacted header replayed")
+		}
+		if r.Header.Get("X-Replay") != "1" {
+			t.Errorf("missing replay header")
+		}
+		body, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/echo":
+			w.Write(body)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	records := make(chan Record, 4)
+	records <- Record{Method: http.MethodPost, URL: "/echo", Body: []byte(`{"id":1,"name":"ada"}`),
+		Header: http.Header{"Authorization": {Redacted}}, Status: http.StatusOK, ResponseBody: []byte(`{"id":2,"name":"ada"}`)}
+	records <- Record{Method: http.MethodPost, URL: "/echo", Body: []byte(`{"name":"grace"}`), Status: http.StatusOK, ResponseBody: []byte(`{"name":"ada"}`)}
+	records <- Record{Method: http.MethodGet, URL: "/missing", Status: http.StatusOK, ResponseTruncated: true}
+	records <- Record{Method: http.MethodPost, URL: "/echo", Truncated: true}
+	close(records)
+
+	replayer := NewReplayer(srv.Client(), ReplayConfig{
+		Target:       srv.URL + "/",
+		Rate:         1000,
+		Concurrency:  2,
+		IgnoreFields: []string{"id"},
+		Header:       http.Header{"x-replay": {"1"}},
+	})
+	results := map[string][]string{}
+	replayer.Replay(context.Background(), records, func(r Result) {
+		if r.Err != nil {
+			t.Errorf("%s: %v", r.Record.URL, r.Err)
+		}
+		results[r.Record.URL+" "+string(r.Record.Body)] = r.Diffs
+	})
+
+	want := map[string][]string{
+		`/echo {"id":1,"name":"ada"}`: nil,
+		`/echo {"name":"grace"}`:      {`body.name: "ada" != "grace"`},
+		"/missing ":                   {"status: 200 != 404"},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for k, diffs := range want {
+		if !slices.Equal(results[k], diffs) {
+			t.Errorf("%s: diffs = %q, want %q", k, results[k], diffs)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name     string
+		want     string
+		got      string
+		wantDiff []string
+	}{
+		{name: "equal", want: `{"a":[1,{"b":true}]}`, got: `{"a":[1,{"b":true}]}`},
+		{name: "ignored nested", want: `{"a":{"ts":1}}`, got: `{"a":{"ts":2}}`},
+		{name: "missing", want: `{"a":1,"b":2}`, got: `{"a":1}`, wantDiff: []string{"body.b: missing"}},
+		{name: "unexpected", want: `{"a":1}`, got: `{"a":1,"c":3}`, wantDiff: []string{"body.c: unexpected"}},
+		{name: "length", want: `[1,2]`, got: `[1]`, wantDiff: []string{"body: 2 items != 1 items"}},
+		{name: "type", want: `{"a":"1"}`, got: `{"a":1}`, wantDiff: []string{`body.a: "1" != 1`}},
+		{name: "text", want: "hello", got: "world", wantDiff: []string{"body differs"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs := Compare(http.StatusOK, []byte(tt.want), http.StatusOK, []byte(tt.got), "ts")
+			if !slices.Equal(diffs, tt.wantDiff) {
+				t.Errorf("diffs = %q, want %q", diffs, tt.wantDiff)
+			}
+		})
+	}
+}
+
+func TestReadRecords(t *testing.T) {
+	var buf bytes.Buffer
+	for _, url := range []string{"/a", "/b", "/c"} {
+		buf.WriteString(`{"method":"GET","url":"` + url + `","status":200}` + "\n")
+	}
+	var urls []string
+	err := ReadRecords(&buf, func(r Record) bool {
+		urls = append(urls, r.URL)
+		return len(urls) < 2
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(urls, ",") != "/a,/b" {
+		t.Errorf("urls = %v", urls)
+	}
+
+	if err := ReadRecords(strings.NewReader("{"), func(Record) bool { return true }); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,105 @@
+package zisecrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/divikraf/lumos/internal/awsv4"
+)
+
+// AWSConfig configures the AWS Secrets Manager provider.
+type AWSConfig struct {
+	// Region is the region of the secrets (default: the AWS_REGION or
+	// AWS_DEFAULT_REGION variable).
+	Region string `json:"region" yaml:"region"`
+	// Endpoint is the URL of the service (default:
+	// https://secretsmanager.<region>.amazonaws.com).
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+	// AccessKeyID, SecretAccessKey and SessionToken are the credentials
+	// (default: the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+	// AWS_SESSION_TOKEN variables).
+	AccessKeyID     string `json:"access_key_id" yaml:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key" yaml:"secret_access_key"`
+	SessionToken    string `json:"session_token" yaml:"session_token"`
+}
+
+type awsProvider struct {
+	config AWSConfig
+	client *http.Client
+	creds  awsv4.Credentials
+	now    func() time.Time
+}
+
+// NewAWSProvider returns the Provider of the awssm:// references, to the
+// secrets of AWS Secrets Manager by name or ARN, read with client, or
+// http.DefaultClient if nil, e.g. awssm://payments/stripe#api_key. A key
+// reads a field of a JSON secret.
+func NewAWSProvider(config AWSConfig, client *http.Client) Provider {
+	if config.Region == "" {
+		config.Region = os.Getenv("AWS_REGION")
+	}
+	if config.Region == "" {
+		config.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = "https://secretsmanager." + config.Region + ".amazonaws.com"
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &awsProvider{config: config, client: client, now: time.Now, creds: awsv4.CredentialsFromEnv(awsv4.Credentials{
+		AccessKeyID:     config.AccessKeyID,
+		SecretAccessKey: config.SecretAccessKey,
+		SessionToken:    config.SessionToken,
+	})}
+}
+
+func (p *awsProvider) Resolve(ctx context.Context, ref Ref) (string, error) {
+	body, _ := json.Marshal(map[string]string{"SecretId": ref.Path})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(p.config.Endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	p.sign(req, body)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		if strings.HasSuffix(e.Type, "ResourceNotFoundException") {
+			return "", fmt.Errorf("%w: %s", ErrSecretNotFound, ref)
+		}
+		return "", &Error{Provider: SchemeAWS, Status: resp.StatusCode, Code: e.Type, Message: e.Message}
+	}
+
+	var secret struct {
+		SecretString string `json:"SecretString"`
+		SecretBinary []byte `json:"SecretBinary"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", fmt.Errorf("zisecrets: invalid AWS response for %s: %w", ref, err)
+	}
+	if secret.SecretString == "" {
+		secret.SecretString = string(secret.SecretBinary)
+	}
+	return field(ref, secret.SecretString)
+}
+
+// sign signs req, of payload body, with AWS Signature Version 4.
+func (p *awsProvider) sign(req *http.Request, body []byte) {
+	awsv4.Sign(req, body, p.creds, p.config.Region, "secretsmanager", p.now())
+}
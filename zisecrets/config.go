@@ -0,0 +1,55 @@
+package zisecrets
+
+import (
+	"context"
+	"time"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zihttpc"
+	"github.com/rs/zerolog"
+)
+
+// Config configures the built-in providers of a Resolver.
+type Config struct {
+	Vault VaultConfig `json:"vault" yaml:"vault"`
+	AWS   AWSConfig   `json:"aws" yaml:"aws"`
+	GCP   GCPConfig   `json:"gcp" yaml:"gcp"`
+	// HTTP configures the HTTP client of the providers, see zihttpc.New.
+	HTTP zihttpc.ClientConfig `json:"http" yaml:"http"`
+	// RotationInterval, if set, is the interval of the polls of the secrets
+	// for rotations.
+	RotationInterval time.Duration `json:"rotation_interval" yaml:"rotation_interval"`
+}
+
+// secretsConfig is implemented by configurations of the secret providers.
+type secretsConfig interface {
+	GetSecrets() Config
+}
+
+// NewResolverFromConfig returns a Resolver with the built-in providers
+// configured by config, and the providers of opts.
+func NewResolverFromConfig(config Config, logger *zerolog.Logger, opts ...Option) *Resolver {
+	client := zihttpc.New(config.HTTP)
+	return NewResolver(logger, append([]Option{
+		WithProvider(SchemeVault, NewVaultProvider(config.Vault, client)),
+		WithProvider(SchemeAWS, NewAWSProvider(config.AWS, client)),
+		WithProvider(SchemeGCP, NewGCPProvider(config.GCP, client)),
+		WithRotationInterval(config.RotationInterval),
+	}, opts...)...)
+}
+
+// ReadConfig reads the config like ziconf.ReadConfig, and resolves its
+// references with the Resolver configured by its secrets config, which it
+// returns to be started for rotations.
+func ReadConfig[T ziconf.Config](ctx context.Context, logger *zerolog.Logger, opts ...Option) (*T, *Resolver, error) {
+	cfg := ziconf.ReadConfig[T]()
+	var config Config
+	if c, ok := any(*cfg).(secretsConfig); ok {
+		config = c.GetSecrets()
+	}
+	r := NewResolverFromConfig(config, logger, opts...)
+	if err := r.Resolve(ctx, cfg); err != nil {
+		return nil, nil, err
+	}
+	return cfg, r, nil
+}
@@ -0,0 +1,19 @@
+package zisecrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// EnvProvider returns the Provider of the env:// references, to environment
+// variables, e.g. env://SENTRY_DSN. A key reads a field of a JSON variable.
+func EnvProvider() Provider {
+	return ProviderFunc(func(ctx context.Context, ref Ref) (string, error) {
+		v, ok := os.LookupEnv(ref.Path)
+		if !ok {
+			return "", fmt.Errorf("%w: %s", ErrSecretNotFound, ref)
+		}
+		return field(ref, v)
+	})
+}
@@ -0,0 +1,110 @@
+package zisecrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/divikraf/lumos/internal/gcpauth"
+)
+
+// GCPConfig configures the GCP Secret Manager provider.
+type GCPConfig struct {
+	// Project is the project of the secrets referenced by name only.
+	Project string `json:"project" yaml:"project"`
+	// Endpoint is the URL of the service (default:
+	// https://secretmanager.googleapis.com).
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+	// AccessToken authenticates the requests (default: the
+	// GOOGLE_OAUTH_ACCESS_TOKEN variable, or the tokens of the metadata
+	// server).
+	AccessToken string `json:"access_token" yaml:"access_token"`
+}
+
+type gcpProvider struct {
+	config GCPConfig
+	client *http.Client
+	tokens *gcpauth.TokenSource
+}
+
+// NewGCPProvider returns the Provider of the gcpsm:// references, to the
+// secrets of GCP Secret Manager, read with client, or http.DefaultClient if
+// nil, e.g. gcpsm://projects/acme/secrets/stripe#api_key, or
+// gcpsm://stripe with the Project of config. The latest version is read
+// unless the path has one, e.g. .../secrets/stripe/versions/3. A key reads a
+// field of a JSON secret.
+func NewGCPProvider(config GCPConfig, client *http.Client) Provider {
+	if config.Endpoint == "" {
+		config.Endpoint = "https://secretmanager.googleapis.com"
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &gcpProvider{config: config, client: client, tokens: gcpauth.NewTokenSource(config.AccessToken, client)}
+}
+
+// name returns the resource name of the secret version of ref.
+func (p *gcpProvider) name(ref Ref) string {
+	name := strings.Trim(ref.Path, "/")
+	if !strings.HasPrefix(name, "projects/") {
+		name = "projects/" + p.config.Project + "/secrets/" + name
+	}
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+	return name
+}
+
+func (p *gcpProvider) Resolve(ctx context.Context, ref Ref) (string, error) {
+	token, err := p.accessToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(p.config.Endpoint, "/")+"/v1/"+p.name(ref)+":access", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, ref)
+	}
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Error struct {
+				Status  string `json:"status"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return "", &Error{Provider: SchemeGCP, Status: resp.StatusCode, Code: e.Error.Status, Message: e.Error.Message}
+	}
+
+	var body struct {
+		Payload struct {
+			Data []byte `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("zisecrets: invalid GCP response for %s: %w", ref, err)
+	}
+	return field(ref, string(body.Payload.Data))
+}
+
+// accessToken returns the token of the requests, the error responses of the
+// metadata server as *Error.
+func (p *gcpProvider) accessToken(ctx context.Context) (string, error) {
+	token, err := p.tokens.Token(ctx)
+	var statusErr *gcpauth.StatusError
+	if errors.As(err, &statusErr) {
+		return "", &Error{Provider: SchemeGCP, Status: statusErr.Status, Message: "metadata server token"}
+	}
+	return token, err
+}
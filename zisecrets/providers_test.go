@@ -0,0 +1,134 @@
+package zisecrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVaultProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `{"errors":["permission denied"]}`)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/app":
+			io.WriteString(w, `{"data":{"data":{"password":"s3cret","port":5432},"metadata":{"version":3}}}`)
+		case "/v1/kv/app":
+			io.WriteString(w, `{"data":{"token":"t-1"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	p := NewVaultProvider(VaultConfig{Address: srv.URL, Token: "root"}, nil)
+	ctx := context.Background()
+
+	for ref, want := range map[string]string{
+		"vault://secret/data/app#password": "s3cret",
+		"vault://secret/data/app#port":     "5432",
+		"vault://kv/app":                   "t-1",
+	} {
+		r, _ := ParseRef(ref)
+		if got, err := p.Resolve(ctx, r); err != nil || got != want {
+			t.Errorf("Resolve(%s) = %q, %v, want %q", ref, got, err, want)
+		}
+	}
+	if _, err := p.Resolve(ctx, Ref{Scheme: SchemeVault, Path: "secret/data/missing", Key: "x"}); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Resolve() of a missing secret = %v", err)
+	}
+	if _, err := p.Resolve(ctx, Ref{Scheme: SchemeVault, Path: "secret/data/app"}); err == nil {
+		t.Error("Resolve() without key of a secret with 2 fields succeeded")
+	}
+	var e *Error
+	_, err := NewVaultProvider(VaultConfig{Address: srv.URL, Token: "x"}, nil).Resolve(ctx, Ref{Scheme: SchemeVault, Path: "kv/app"})
+	if !errors.As(err, &e) || e.ErrorType() != "http_403" {
+		t.Errorf("Resolve() with a bad token = %v", err)
+	}
+}
+
+func TestAWSProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/20240102/eu-west-1/secretsmanager/aws4_request, SignedHeaders=content-type;host;x-amz-date;x-amz-target, Signature=") {
+			t.Errorf("Authorization = %s", auth)
+		}
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" {
+			t.Errorf("X-Amz-Target = %s", r.Header.Get("X-Amz-Target"))
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["SecretId"] != "payments/stripe" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"__type":"ResourceNotFoundException","message":"not found"}`)
+			return
+		}
+		io.WriteString(w, `{"Name":"payments/stripe","SecretString":"{\"api_key\":\"sk_1\"}"}`)
+	}))
+	defer srv.Close()
+	p := NewAWSProvider(AWSConfig{Region: "eu-west-1", Endpoint: srv.URL, AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil).(*awsProvider)
+	p.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+	ctx := context.Background()
+
+	if got, err := p.Resolve(ctx, Ref{Scheme: SchemeAWS, Path: "payments/stripe", Key: "api_key"}); err != nil || got != "sk_1" {
+		t.Errorf("Resolve() = %q, %v", got, err)
+	}
+	if got, _ := p.Resolve(ctx, Ref{Scheme: SchemeAWS, Path: "payments/stripe"}); got != `{"api_key":"sk_1"}` {
+		t.Errorf("Resolve() without key = %q, want the whole secret", got)
+	}
+	if _, err := p.Resolve(ctx, Ref{Scheme: SchemeAWS, Path: "missing"}); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Resolve() of a missing secret = %v", err)
+	}
+}
+
+func TestGCPProvider(t *testing.T) {
+	tokens := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			tokens++
+			if r.Header.Get("Metadata-Flavor") != "Google" {
+				t.Error("metadata request without Metadata-Flavor")
+			}
+			io.WriteString(w, `{"access_token":"ya29","expires_in":3600}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer ya29" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/v1/projects/acme/secrets/stripe/versions/latest:access" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// base64 of {"api_key":"sk_1"}
+		io.WriteString(w, `{"payload":{"data":"eyJhcGlfa2V5Ijoic2tfMSJ9"}}`)
+	}))
+	defer srv.Close()
+	p := NewGCPProvider(GCPConfig{Project: "acme", Endpoint: srv.URL}, nil).(*gcpProvider)
+	p.tokens.AccessToken = ""
+	p.tokens.URL = srv.URL + "/token"
+	ctx := context.Background()
+
+	for _, ref := range []Ref{
+		{Scheme: SchemeGCP, Path: "stripe", Key: "api_key"},
+		{Scheme: SchemeGCP, Path: "projects/acme/secrets/stripe", Key: "api_key"},
+	} {
+		if got, err := p.Resolve(ctx, ref); err != nil || got != "sk_1" {
+			t.Errorf("Resolve(%s) = %q, %v", ref, got, err)
+		}
+	}
+	if tokens != 1 {
+		t.Errorf("metadata tokens = %d, want 1 cached", tokens)
+	}
+	if _, err := p.Resolve(ctx, Ref{Scheme: SchemeGCP, Path: "missing"}); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Resolve() of a missing secret = %v", err)
+	}
+}
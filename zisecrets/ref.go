@@ -0,0 +1,72 @@
+package zisecrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Ref is a reference to a secret, written scheme://path#key in the config,
+// e.g. vault://secret/data/payments#db_password.
+type Ref struct {
+	// Scheme identifies the provider, e.g. "vault".
+	Scheme string
+	// Path identifies the secret in the provider.
+	Path string
+	// Key, if set, is the field of the secret, e.g. of a JSON secret.
+	Key string
+}
+
+// ParseRef parses s as a Ref, and reports whether it is one.
+func ParseRef(s string) (Ref, bool) {
+	scheme, rest, ok := strings.Cut(s, "://")
+	if !ok || scheme == "" || rest == "" || strings.ContainsAny(scheme, " /:") {
+		return Ref{}, false
+	}
+	path, key, _ := strings.Cut(rest, "#")
+	return Ref{Scheme: scheme, Path: path, Key: key}, path != ""
+}
+
+func (r Ref) String() string {
+	s := r.Scheme + "://" + r.Path
+	if r.Key != "" {
+		s += "#" + r.Key
+	}
+	return s
+}
+
+// field returns the field key of the JSON object secret, or secret itself
+// when key is empty.
+func field(ref Ref, secret string) (string, error) {
+	if ref.Key == "" {
+		return secret, nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", fmt.Errorf("zisecrets: %s is not a JSON object", ref)
+	}
+	return lookup(ref, fields)
+}
+
+// lookup returns the field ref.Key of fields, or its only field when
+// ref.Key is empty.
+func lookup(ref Ref, fields map[string]any) (string, error) {
+	key := ref.Key
+	if key == "" {
+		if len(fields) != 1 {
+			return "", fmt.Errorf("zisecrets: %s has %d fields, a key is required", ref, len(fields))
+		}
+		for k := range fields {
+			key = k
+		}
+	}
+	v, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, ref)
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
@@ -0,0 +1,357 @@
+// Package zisecrets resolves references to secrets in configs, e.g.
+//
+//	database:
+//	  password: vault://secret/data/payments#db_password
+//	stripe:
+//	  api_key: awssm://payments/stripe#api_key
+//	sentry:
+//	  dsn: env://SENTRY_DSN
+//
+// The string fields of a config whose value is a reference, scheme://path
+// with an optional #key, to a registered provider are replaced by the
+// secrets at startup. The providers of Vault (vault://), AWS Secrets Manager
+// (awssm://), GCP Secret Manager (gcpsm://) and the environment (env://) are
+// built in. The references are then polled for rotations, which callbacks
+// registered with OnRotate are told about, e.g. to reconnect.
+package zisecrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	// ErrSecretNotFound is returned by providers for missing secrets.
+	ErrSecretNotFound = errors.New("zisecrets: secret not found")
+)
+
+// Provider schemes
+const (
+	SchemeEnv   = "env"
+	SchemeVault = "vault"
+	SchemeAWS   = "awssm"
+	SchemeGCP   = "gcpsm"
+)
+
+func init() {
+	revelio.RegisterErrorType(ErrSecretNotFound, "not_found")
+}
+
+// Error is an error response of the API of a provider.
+type Error struct {
+	Provider string
+	Status   int
+	// Code is the error code of the response, if any.
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("zisecrets: %s responded %d %s: %s", e.Provider, e.Status, e.Code, e.Message)
+}
+
+// ErrorType returns the code of the error, as the normalized error type of
+// metrics.
+func (e *Error) ErrorType() string {
+	if e.Code == "" {
+		return "http_" + strconv.Itoa(e.Status)
+	}
+	return e.Code
+}
+
+// Provider resolves the references of a scheme.
+type Provider interface {
+	// Resolve returns the secret of ref, or ErrSecretNotFound.
+	Resolve(ctx context.Context, ref Ref) (string, error)
+}
+
+// ProviderFunc is a Provider function.
+type ProviderFunc func(ctx context.Context, ref Ref) (string, error)
+
+func (f ProviderFunc) Resolve(ctx context.Context, ref Ref) (string, error) {
+	return f(ctx, ref)
+}
+
+// Rotation is a change of the secret of a field of a resolved config.
+type Rotation struct {
+	// Field is the path of the field in the config, e.g. "database.password",
+	// named after the json tags.
+	Field string
+	Ref   Ref
+	Value string
+}
+
+// Resolver resolves the references of configs with providers by scheme.
+type Resolver struct {
+	providers map[string]Provider
+	logger    *zerolog.Logger
+
+	mu        sync.Mutex
+	fields    map[string]Ref
+	values    map[string]string
+	callbacks []func(ctx context.Context, r Rotation)
+	interval  time.Duration
+	cancel    context.CancelFunc
+	done      chan struct{}
+
+	resolutions revelio.ResultCounter
+	rotations   metric.Int64Counter
+}
+
+// Option configures a Resolver.
+type Option func(*Resolver)
+
+// WithProvider registers the provider of the references of scheme.
+func WithProvider(scheme string, p Provider) Option {
+	return func(r *Resolver) {
+		r.providers[scheme] = p
+	}
+}
+
+// WithRotationInterval sets the interval of the polls of the secrets for
+// rotations started by Start (default: 0, not polled).
+func WithRotationInterval(d time.Duration) Option {
+	return func(r *Resolver) {
+		r.interval = d
+	}
+}
+
+// NewResolver returns a Resolver with the env provider, and the providers of
+// opts. The resolutions are counted in the secrets_resolutions_total metric
+// by provider and status, and the rotations in secrets_rotations_total by
+// provider.
+func NewResolver(logger *zerolog.Logger, opts ...Option) *Resolver {
+	r := &Resolver{
+		providers:   map[string]Provider{SchemeEnv: EnvProvider()},
+		logger:      logger,
+		fields:      map[string]Ref{},
+		values:      map[string]string{},
+		resolutions: revelio.MustResultCounter("secrets_resolutions_total", "Number of secret resolutions, by provider and status"),
+		rotations:   revelio.MustInt64Counter("secrets_rotations_total", "Number of secret rotations, by provider"),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// OnRotate registers fn to be called with the rotated secrets of the fields
+// resolved by Resolve. The configs themselves are left unchanged.
+func (r *Resolver) OnRotate(fn func(ctx context.Context, rotation Rotation)) {
+	r.mu.Lock()
+	r.callbacks = append(r.callbacks, fn)
+	r.mu.Unlock()
+}
+
+// ref returns the reference of s, if it is one to a registered provider.
+func (r *Resolver) ref(s string) (Ref, bool) {
+	ref, ok := ParseRef(s)
+	if !ok {
+		return ref, false
+	}
+	_, ok = r.providers[ref.Scheme]
+	return ref, ok
+}
+
+// resolve returns the secret of ref.
+func (r *Resolver) resolve(ctx context.Context, ref Ref) (string, error) {
+	v, err := r.providers[ref.Scheme].Resolve(ctx, ref)
+	r.resolutions.Record(ctx, err, attribute.String("provider", ref.Scheme))
+	if err != nil {
+		return "", fmt.Errorf("zisecrets: failed to resolve %s: %w", ref, err)
+	}
+	return v, nil
+}
+
+// Resolve replaces the references of the strings of the config cfg, a
+// pointer, by their secrets: in its fields, slices, maps and pointers. Every
+// reference is resolved once, failing on the first error.
+func (r *Resolver) Resolve(ctx context.Context, cfg any) error {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("zisecrets: Resolve of a non-pointer %T", cfg)
+	}
+	secrets := map[Ref]string{}
+	return r.walk(v.Elem(), "", func(path, s string) (string, error) {
+		ref, ok := r.ref(s)
+		if !ok {
+			return s, nil
+		}
+		secret, ok := secrets[ref]
+		if !ok {
+			var err error
+			if secret, err = r.resolve(ctx, ref); err != nil {
+				return "", fmt.Errorf("%w (%s)", err, path)
+			}
+			secrets[ref] = secret
+		}
+		r.mu.Lock()
+		r.fields[path], r.values[path] = ref, secret
+		r.mu.Unlock()
+		return secret, nil
+	})
+}
+
+// walk calls replace with the path and the value of the strings of v,
+// setting them to its results.
+func (r *Resolver) walk(v reflect.Value, path string, replace func(path, s string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.String:
+		s, err := replace(path, v.String())
+		if err != nil || s == v.String() {
+			return err
+		}
+		if !v.CanSet() {
+			return fmt.Errorf("zisecrets: %s is not settable", path)
+		}
+		v.SetString(s)
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Interface && v.Elem().Kind() == reflect.String {
+			s, err := replace(path, v.Elem().String())
+			if err == nil && s != v.Elem().String() && v.CanSet() {
+				v.Set(reflect.ValueOf(s))
+			}
+			return err
+		}
+		return r.walk(v.Elem(), path, replace)
+	case reflect.Struct:
+		t := v.Type()
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if err := r.walk(v.Field(i), join(path, fieldName(f)), replace); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			if err := r.walk(v.Index(i), fmt.Sprintf("%s[%d]", path, i), replace); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			// Map values aren't addressable, so walk a copy and set it back
+			elem := reflect.New(iter.Value().Type()).Elem()
+			elem.Set(iter.Value())
+			if err := r.walk(elem, join(path, fmt.Sprint(iter.Key())), replace); err != nil {
+				return err
+			}
+			v.SetMapIndex(iter.Key(), elem)
+		}
+	}
+	return nil
+}
+
+func fieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return f.Name
+	}
+	return name
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// Rotate resolves again the references of the fields resolved by Resolve,
+// and calls the OnRotate callbacks with the changed secrets. The references
+// failing to resolve keep their secret.
+func (r *Resolver) Rotate(ctx context.Context) error {
+	r.mu.Lock()
+	fields := make(map[string]Ref, len(r.fields))
+	for path, ref := range r.fields {
+		fields[path] = ref
+	}
+	r.mu.Unlock()
+
+	var errs []error
+	secrets := map[Ref]string{}
+	for path, ref := range fields {
+		secret, ok := secrets[ref]
+		if !ok {
+			var err error
+			if secret, err = r.resolve(ctx, ref); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			secrets[ref] = secret
+		}
+
+		r.mu.Lock()
+		changed := r.values[path] != secret
+		r.values[path] = secret
+		callbacks := r.callbacks
+		r.mu.Unlock()
+		if !changed {
+			continue
+		}
+		r.rotations.Add(ctx, 1, metric.WithAttributes(attribute.String("provider", ref.Scheme)))
+		r.logger.Info().Str("field", path).Str("ref", ref.String()).Msg("Secret rotated")
+		for _, fn := range callbacks {
+			fn(ctx, Rotation{Field: path, Ref: ref, Value: secret})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Start polls the secrets for rotations every rotation interval, if set,
+// until Stop.
+func (r *Resolver) Start() {
+	if r.interval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel, r.done = cancel, make(chan struct{})
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := r.Rotate(ctx); err != nil && ctx.Err() == nil {
+				r.logger.Warn().Err(err).Msg("Failed to poll secrets for rotations")
+			}
+		}
+	}()
+}
+
+// Stop stops polling the secrets, waiting for the current poll until ctx is
+// done.
+func (r *Resolver) Stop(ctx context.Context) error {
+	if r.cancel == nil {
+		return nil
+	}
+	r.cancel()
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,116 @@
+package zisecrets
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+type databaseConfig struct {
+	Password string `json:"password"`
+}
+
+type appConfig struct {
+	Database  databaseConfig    `json:"database"`
+	Webhook   string            `json:"webhook"`
+	Tokens    []string          `json:"tokens"`
+	Headers   map[string]string `json:"headers"`
+	Extra     map[string]any    `json:"extra"`
+	Replica   *databaseConfig   `json:"replica"`
+	unrelated string
+}
+
+// memory is a Provider of the secrets of a map.
+type memory map[string]string
+
+func (m memory) Resolve(ctx context.Context, ref Ref) (string, error) {
+	v, ok := m[ref.Path]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return field(ref, v)
+}
+
+func TestResolve(t *testing.T) {
+	t.Setenv("APP_TOKEN", "t-1")
+	secrets := memory{"db": `{"password":"s3cret"}`, "api": "key-1"}
+	logger := zerolog.Nop()
+	r := NewResolver(&logger, WithProvider("mem", secrets))
+
+	cfg := appConfig{
+		Database: databaseConfig{Password: "mem://db#password"},
+		Webhook:  "https://example.com/hook",
+		Tokens:   []string{"env://APP_TOKEN", "plain"},
+		Headers:  map[string]string{"X-API-Key": "mem://api"},
+		Extra:    map[string]any{"key": "mem://api", "n": 1},
+		Replica:  &databaseConfig{Password: "mem://db#password"},
+	}
+	if err := r.Resolve(context.Background(), &cfg); err != nil {
+		t.Fatalf("Resolve() = %v", err)
+	}
+	want := appConfig{
+		Database: databaseConfig{Password: "s3cret"},
+		Webhook:  "https://example.com/hook",
+		Tokens:   []string{"t-1", "plain"},
+		Headers:  map[string]string{"X-API-Key": "key-1"},
+		Extra:    map[string]any{"key": "key-1", "n": 1},
+		Replica:  &databaseConfig{Password: "s3cret"},
+	}
+	if cfg.Database != want.Database || cfg.Webhook != want.Webhook || cfg.Tokens[0] != "t-1" || cfg.Tokens[1] != "plain" ||
+		cfg.Headers["X-API-Key"] != "key-1" || cfg.Extra["key"] != "key-1" || *cfg.Replica != *want.Replica {
+		t.Errorf("config = %+v, want %+v", cfg, want)
+	}
+
+	var rotations []Rotation
+	r.OnRotate(func(ctx context.Context, rotation Rotation) {
+		rotations = append(rotations, rotation)
+	})
+	secrets["api"] = "key-2"
+	if err := r.Rotate(context.Background()); err != nil {
+		t.Fatalf("Rotate() = %v", err)
+	}
+	if len(rotations) != 2 || rotations[0].Value != "key-2" {
+		t.Fatalf("rotations = %+v, want the 2 fields of api", rotations)
+	}
+	for _, rot := range rotations {
+		if rot.Field != "headers.X-API-Key" && rot.Field != "extra.key" {
+			t.Errorf("rotated field %s", rot.Field)
+		}
+	}
+	if err := r.Rotate(context.Background()); err != nil || len(rotations) != 2 {
+		t.Errorf("Rotate() without changes = %v, %d rotations", err, len(rotations))
+	}
+}
+
+func TestResolveMissing(t *testing.T) {
+	logger := zerolog.Nop()
+	r := NewResolver(&logger)
+	cfg := appConfig{Database: databaseConfig{Password: "env://ZISECRETS_MISSING"}}
+	err := r.Resolve(context.Background(), &cfg)
+	if !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Resolve() = %v, want ErrSecretNotFound", err)
+	}
+	if err := r.Resolve(context.Background(), cfg); err == nil {
+		t.Error("Resolve() of a non-pointer succeeded")
+	}
+}
+
+func TestParseRef(t *testing.T) {
+	tests := map[string]Ref{
+		"vault://secret/data/app#password": {Scheme: "vault", Path: "secret/data/app", Key: "password"},
+		"awssm://payments/stripe":          {Scheme: "awssm", Path: "payments/stripe"},
+		"env://VAR":                        {Scheme: "env", Path: "VAR"},
+	}
+	for s, want := range tests {
+		if got, ok := ParseRef(s); !ok || got != want || got.String() != s {
+			t.Errorf("ParseRef(%s) = %+v, %v, want %+v", s, got, ok, want)
+		}
+	}
+	for _, s := range []string{"plain", "env://", "://x", "a b://x"} {
+		if _, ok := ParseRef(s); ok {
+			t.Errorf("ParseRef(%s) is a reference", s)
+		}
+	}
+}
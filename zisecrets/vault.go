@@ -0,0 +1,92 @@
+package zisecrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// VaultConfig configures the Vault provider.
+type VaultConfig struct {
+	// Address is the URL of Vault (default: the VAULT_ADDR variable).
+	Address string `json:"address" yaml:"address"`
+	// Token authenticates the requests (default: the VAULT_TOKEN variable).
+	Token string `json:"token" yaml:"token"`
+	// Namespace is the Vault Enterprise namespace (default: the
+	// VAULT_NAMESPACE variable).
+	Namespace string `json:"namespace" yaml:"namespace"`
+}
+
+type vaultProvider struct {
+	config VaultConfig
+	client *http.Client
+}
+
+// NewVaultProvider returns the Provider of the vault:// references, to the
+// fields of the secrets of Vault at their path, read with client, or
+// http.DefaultClient if nil, e.g. vault://secret/data/payments#db_password
+// for the KV version 2 engine mounted at secret/. The key can be omitted for
+// secrets with a single field.
+func NewVaultProvider(config VaultConfig, client *http.Client) Provider {
+	if config.Address == "" {
+		config.Address = os.Getenv("VAULT_ADDR")
+	}
+	if config.Token == "" {
+		config.Token = os.Getenv("VAULT_TOKEN")
+	}
+	if config.Namespace == "" {
+		config.Namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+	config.Address = strings.TrimRight(config.Address, "/")
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &vaultProvider{config: config, client: client}
+}
+
+func (p *vaultProvider) Resolve(ctx context.Context, ref Ref) (string, error) {
+	if p.config.Address == "" {
+		return "", fmt.Errorf("zisecrets: no Vault address to resolve %s", ref)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.Address+"/v1/"+strings.TrimLeft(ref.Path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", p.config.Token)
+	if p.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.config.Namespace)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, ref)
+	}
+	if resp.StatusCode >= 300 {
+		var body struct {
+			Errors []string `json:"errors"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return "", &Error{Provider: SchemeVault, Status: resp.StatusCode, Message: strings.Join(body.Errors, "; ")}
+	}
+
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("zisecrets: invalid Vault response for %s: %w", ref, err)
+	}
+	// The KV version 2 engine nests the fields in data, next to metadata
+	fields := body.Data
+	if nested, ok := fields["data"].(map[string]any); ok {
+		if _, ok := fields["metadata"]; ok {
+			fields = nested
+		}
+	}
+	return lookup(ref, fields)
+}
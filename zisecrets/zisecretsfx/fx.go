@@ -0,0 +1,35 @@
+package zisecretsfx
+
+import (
+	"context"
+	"time"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zisecrets"
+	"github.com/rs/zerolog"
+	"go.uber.org/fx"
+)
+
+// resolveTimeout bounds the resolution of the references of the config.
+const resolveTimeout = time.Minute
+
+// WithConfig replaces ziconffx.WithConfig, providing the config with its
+// references resolved, see zisecrets.ReadConfig, and the *zisecrets.Resolver
+// polling them for rotations while the app runs.
+func WithConfig[T ziconf.Config](opts ...zisecrets.Option) fx.Option {
+	return fx.Provide(
+		func(lc fx.Lifecycle, logger *zerolog.Logger) (*T, *zisecrets.Resolver, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+			defer cancel()
+			cfg, r, err := zisecrets.ReadConfig[T](ctx, logger, opts...)
+			if err != nil {
+				return nil, nil, err
+			}
+			lc.Append(fx.StartStopHook(r.Start, r.Stop))
+			return cfg, r, nil
+		},
+		func(x *T) ziconf.Config {
+			return *x
+		},
+	)
+}
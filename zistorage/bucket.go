@@ -0,0 +1,187 @@
+package zistorage
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/divikraf/lumos/zistorage"
+
+// PutOptions describes an object written with Bucket.Put.
+type PutOptions struct {
+	ContentType string
+	// Size is the size of the object, if known. Drivers needing it buffer
+	// the objects of unknown size in memory.
+	Size int64
+}
+
+// Bucket stores objects by key, e.g. an S3 or GCS bucket, or a local
+// directory.
+type Bucket interface {
+	// Put writes the object key with the content of r.
+	Put(ctx context.Context, key string, r io.Reader, opts PutOptions) (ObjectInfo, error)
+	// Get returns the content of the object key, to be closed, or
+	// ErrObjectNotFound.
+	Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error)
+	// Stat returns the info of the object key, or ErrObjectNotFound.
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// SignedURL returns a URL calling method, e.g. http.MethodGet, on the
+	// object key without credentials until it expires.
+	SignedURL(ctx context.Context, key, method string, expires time.Duration) (string, error)
+	// List calls fn with the objects whose key starts with prefix, in key
+	// order, until fn returns false.
+	List(ctx context.Context, prefix string, fn func(ObjectInfo) bool) error
+	// Delete deletes the object key, missing objects included.
+	Delete(ctx context.Context, key string) error
+}
+
+// Byte directions
+const (
+	DirectionUpload   = "upload"
+	DirectionDownload = "download"
+)
+
+// instrumentedBucket traces and times the operations of a Bucket, and counts
+// the bytes transferred.
+type instrumentedBucket struct {
+	bucket   Bucket
+	name     attribute.KeyValue
+	duration revelio.DurationRecorder
+	bytes    metric.Int64Counter
+}
+
+// Instrument returns b with its operations traced, recorded in the
+// storage_operation_duration_ms metric by bucket, operation and status, and
+// the bytes transferred in the storage_bytes_total metric by bucket and
+// direction (upload, download). name identifies the bucket in both.
+func Instrument(name string, b Bucket) Bucket {
+	return &instrumentedBucket{
+		bucket: b,
+		name:   attribute.String("bucket", name),
+		duration: revelio.MustDuration(
+			"storage_operation_duration_ms",
+			"Duration of object storage operations in milliseconds, by bucket, operation and status",
+		),
+		bytes: revelio.MustInt64Counter(
+			"storage_bytes_total",
+			"Number of bytes transferred to and from object storage, by bucket and direction",
+		),
+	}
+}
+
+// start starts the span of operation on key, and returns the function ending
+// it with the error of the operation.
+func (b *instrumentedBucket) start(ctx context.Context, operation, key string) (context.Context, func(error)) {
+	attrs := []attribute.KeyValue{b.name, attribute.String("operation", operation)}
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "storage "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(attribute.String("object.key", key)),
+	)
+	start := time.Now()
+	return ctx, func(err error) {
+		b.duration.Record(ctx, time.Since(start), append(attrs, revelio.ResultAttributes(err)...)...)
+		if err != nil {
+			observe.RecordError(span, err)
+		}
+		span.End()
+	}
+}
+
+func (b *instrumentedBucket) count(ctx context.Context, direction string, n int64) {
+	if n > 0 {
+		b.bytes.Add(ctx, n, metric.WithAttributes(b.name, attribute.String("direction", direction)))
+	}
+}
+
+func (b *instrumentedBucket) Put(ctx context.Context, key string, r io.Reader, opts PutOptions) (info ObjectInfo, err error) {
+	ctx, end := b.start(ctx, "put", key)
+	defer func() { end(err) }()
+	cr := &countingReader{r: r}
+	info, err = b.bucket.Put(ctx, key, cr, opts)
+	b.count(ctx, DirectionUpload, cr.n)
+	return info, err
+}
+
+func (b *instrumentedBucket) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	ctx, end := b.start(ctx, "get", key)
+	rc, info, err := b.bucket.Get(ctx, key)
+	if err != nil {
+		end(err)
+		return nil, info, err
+	}
+	// The download ends when the content is closed
+	return &countingReadCloser{countingReader: countingReader{r: rc}, close: func(n int64, err error) {
+		b.count(ctx, DirectionDownload, n)
+		end(err)
+	}, c: rc}, info, nil
+}
+
+func (b *instrumentedBucket) Stat(ctx context.Context, key string) (info ObjectInfo, err error) {
+	ctx, end := b.start(ctx, "stat", key)
+	defer func() { end(err) }()
+	return b.bucket.Stat(ctx, key)
+}
+
+func (b *instrumentedBucket) SignedURL(ctx context.Context, key, method string, expires time.Duration) (url string, err error) {
+	ctx, end := b.start(ctx, "sign", key)
+	defer func() { end(err) }()
+	return b.bucket.SignedURL(ctx, key, method, expires)
+}
+
+func (b *instrumentedBucket) List(ctx context.Context, prefix string, fn func(ObjectInfo) bool) (err error) {
+	ctx, end := b.start(ctx, "list", prefix)
+	defer func() { end(err) }()
+	return b.bucket.List(ctx, prefix, fn)
+}
+
+func (b *instrumentedBucket) Delete(ctx context.Context, key string) (err error) {
+	ctx, end := b.start(ctx, "delete", key)
+	defer func() { end(err) }()
+	return b.bucket.Delete(ctx, key)
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// countingReadCloser calls close with the bytes read and the error of the
+// reads, other than io.EOF, once closed.
+type countingReadCloser struct {
+	countingReader
+	c       io.Closer
+	close   func(n int64, err error)
+	readErr error
+}
+
+func (r *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.countingReader.Read(p)
+	if err != nil && err != io.EOF {
+		r.readErr = err
+	}
+	return n, err
+}
+
+func (r *countingReadCloser) Close() error {
+	err := r.c.Close()
+	if r.close != nil {
+		r.close(r.n, r.readErr)
+		r.close = nil
+	}
+	return err
+}
@@ -0,0 +1,42 @@
+package zistorage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestInstrument(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	fsb, err := NewFSBucket(FSConfig{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := Instrument("media", fsb)
+	ctx := context.Background()
+
+	if _, err := b.Put(ctx, "a.txt", strings.NewReader("hello"), PutOptions{}); err != nil {
+		t.Fatalf("Put() = %v", err)
+	}
+	rc, _, err := b.Get(ctx, "a.txt")
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	io.ReadAll(rc)
+	rc.Close()
+	b.Stat(ctx, "missing")
+
+	bucket := attribute.String("bucket", "media")
+	reveliotest.AssertCounterValue(t, s, "storage_bytes_total", 5, bucket, attribute.String("direction", DirectionUpload))
+	reveliotest.AssertCounterValue(t, s, "storage_bytes_total", 5, bucket, attribute.String("direction", DirectionDownload))
+	if h := reveliotest.CollectHistogram(t, s, "storage_operation_duration_ms", bucket, attribute.String("operation", "get")); h.Count != 1 {
+		t.Errorf("get operations = %d, want 1", h.Count)
+	}
+	if h := reveliotest.CollectHistogram(t, s, "storage_operation_duration_ms", attribute.String("operation", "stat"), attribute.String("status", "failure")); h.Count != 1 {
+		t.Errorf("failed stat operations = %d, want 1", h.Count)
+	}
+}
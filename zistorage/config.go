@@ -0,0 +1,94 @@
+package zistorage
+
+import (
+	"fmt"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zihttpc"
+	"go.uber.org/fx"
+)
+
+// Bucket drivers
+const (
+	DriverS3  = "s3"
+	DriverGCS = "gcs"
+	DriverFS  = "fs"
+)
+
+// BucketConfig configures a Bucket, with the config of its driver.
+type BucketConfig struct {
+	// Driver is DriverS3, DriverGCS or DriverFS.
+	Driver string   `json:"driver" yaml:"driver" validate:"required,oneof=s3 gcs fs"`
+	S3     S3Config `json:"s3" yaml:"s3"`
+	FS     FSConfig `json:"fs" yaml:"fs"`
+	// HTTP configures the HTTP client of the S3 and GCS drivers, see
+	// zihttpc.New.
+	HTTP zihttpc.ClientConfig `json:"http" yaml:"http"`
+}
+
+// Config configures the buckets, by name.
+type Config struct {
+	Buckets map[string]BucketConfig `json:"buckets" yaml:"buckets"`
+}
+
+// OpenBucket returns the Bucket of config, instrumented as name.
+func OpenBucket(name string, config BucketConfig) (Bucket, error) {
+	var (
+		b   Bucket
+		err error
+	)
+	switch config.Driver {
+	case DriverS3:
+		b, err = NewS3Bucket(config.S3, zihttpc.New(config.HTTP))
+	case DriverGCS:
+		b, err = NewGCSBucket(config.S3, zihttpc.New(config.HTTP))
+	case DriverFS:
+		b, err = NewFSBucket(config.FS)
+	default:
+		err = fmt.Errorf("zistorage: unknown driver %q", config.Driver)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("zistorage: failed to open bucket %s: %w", name, err)
+	}
+	return Instrument(name, b), nil
+}
+
+// Buckets are the buckets of the storage config, by name.
+type Buckets map[string]Bucket
+
+// Bucket returns the bucket name, or an error if not configured.
+func (b Buckets) Bucket(name string) (Bucket, error) {
+	bucket, ok := b[name]
+	if !ok {
+		return nil, fmt.Errorf("zistorage: bucket %s not configured", name)
+	}
+	return bucket, nil
+}
+
+// storageConfig is implemented by configurations of the buckets.
+type storageConfig interface {
+	GetStorage() Config
+}
+
+type NewConfiguredBucketsParams struct {
+	fx.In
+	Config ziconf.Config
+}
+
+// NewConfiguredBuckets opens the buckets of the storage config, when the
+// config has one.
+func NewConfiguredBuckets(params NewConfiguredBucketsParams) (Buckets, error) {
+	buckets := Buckets{}
+	c, ok := params.Config.(storageConfig)
+	if !ok {
+		return buckets, nil
+	}
+	for name, config := range c.GetStorage().Buckets {
+		b, err := OpenBucket(name, config)
+		if err != nil {
+			return nil, err
+		}
+		buckets[name] = b
+	}
+	return buckets, nil
+}
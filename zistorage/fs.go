@@ -0,0 +1,241 @@
+package zistorage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidKey is returned for keys escaping the directory of an FSBucket.
+var ErrInvalidKey = errors.New("zistorage: invalid key")
+
+// fsTempPrefix prefixes the files being written, skipped by List.
+const fsTempPrefix = ".zistorage-"
+
+// FSConfig configures an FSBucket.
+type FSConfig struct {
+	// Dir is the directory of the objects.
+	Dir string `json:"dir" yaml:"dir" validate:"required"`
+	// BaseURL is the URL Handler is served at, prefixing the signed URLs.
+	BaseURL string `json:"base_url" yaml:"base_url"`
+	// SigningKey signs the URLs.
+	SigningKey string `json:"signing_key" yaml:"signing_key"`
+}
+
+// FSBucket is the Bucket of a local directory, for development and tests.
+// Content types are derived from the extensions of the keys, and the signed
+// URLs are served by Handler.
+type FSBucket struct {
+	config FSConfig
+	now    func() time.Time
+}
+
+// NewFSBucket returns the FSBucket of config, creating its directory.
+func NewFSBucket(config FSConfig) (*FSBucket, error) {
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+	return &FSBucket{config: config, now: time.Now}, nil
+}
+
+// path returns the path of the object key.
+func (b *FSBucket) path(key string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(key)) {
+		return "", fmt.Errorf("%w: %s", ErrInvalidKey, key)
+	}
+	return filepath.Join(b.config.Dir, filepath.FromSlash(key)), nil
+}
+
+func (b *FSBucket) Put(ctx context.Context, key string, r io.Reader, opts PutOptions) (ObjectInfo, error) {
+	p, err := b.path(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return ObjectInfo{}, err
+	}
+	// Write then rename, so readers never see partial objects
+	f, err := os.CreateTemp(filepath.Dir(p), fsTempPrefix+"*")
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	defer os.Remove(f.Name())
+	_, err = io.Copy(f, r)
+	if err = errors.Join(err, f.Close()); err != nil {
+		return ObjectInfo{}, err
+	}
+	if err := os.Rename(f.Name(), p); err != nil {
+		return ObjectInfo{}, err
+	}
+	return b.Stat(ctx, key)
+}
+
+func (b *FSBucket) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	p, err := b.path(key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ObjectInfo{}, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	}
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, ObjectInfo{}, err
+	}
+	return f, fileInfo(key, fi), nil
+}
+
+func (b *FSBucket) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	p, err := b.path(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	fi, err := os.Stat(p)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && fi.IsDir()) {
+		return ObjectInfo{}, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	}
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return fileInfo(key, fi), nil
+}
+
+func (b *FSBucket) Delete(ctx context.Context, key string) error {
+	p, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (b *FSBucket) List(ctx context.Context, prefix string, fn func(ObjectInfo) bool) error {
+	var objects []ObjectInfo
+	err := filepath.WalkDir(b.config.Dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasPrefix(d.Name(), fsTempPrefix) {
+			return err
+		}
+		rel, err := filepath.Rel(b.config.Dir, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, fileInfo(key, fi))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	for _, o := range objects {
+		if !fn(o) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (b *FSBucket) SignedURL(ctx context.Context, key, method string, expires time.Duration) (string, error) {
+	if _, err := b.path(key); err != nil {
+		return "", err
+	}
+	exp := strconv.FormatInt(b.now().Add(expires).Unix(), 10)
+	q := url.Values{"expires": {exp}, "signature": {b.sign(method, key, exp)}}
+	return b.config.BaseURL + "/" + key + "?" + q.Encode(), nil
+}
+
+func (b *FSBucket) sign(method, key, expires string) string {
+	h := hmac.New(sha256.New, []byte(b.config.SigningKey))
+	h.Write([]byte(method + "\n" + key + "\n" + expires))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Handler serves the GET and PUT signed URLs, to be mounted at the path of
+// BaseURL with http.StripPrefix.
+func (b *FSBucket) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+		expires := r.URL.Query().Get("expires")
+		sig, err := hex.DecodeString(r.URL.Query().Get("signature"))
+		want, _ := hex.DecodeString(b.sign(r.Method, key, expires))
+		exp, _ := strconv.ParseInt(expires, 10, 64)
+		if err != nil || !hmac.Equal(sig, want) || b.now().Unix() > exp {
+			http.Error(w, "invalid signature", http.StatusForbidden)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			rc, info, err := b.Get(r.Context(), key)
+			if err != nil {
+				fsError(w, err)
+				return
+			}
+			defer rc.Close()
+			if info.ContentType != "" {
+				w.Header().Set("Content-Type", info.ContentType)
+			}
+			w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+			io.Copy(w, rc)
+		case http.MethodPut:
+			if _, err := b.Put(r.Context(), key, r.Body, PutOptions{Size: r.ContentLength}); err != nil {
+				fsError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func fsError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrObjectNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, ErrInvalidKey):
+		http.Error(w, "invalid key", http.StatusBadRequest)
+	default:
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
+
+func fileInfo(key string, fi fs.FileInfo) ObjectInfo {
+	return ObjectInfo{
+		Key:          key,
+		Size:         fi.Size(),
+		ContentType:  mime.TypeByExtension(path.Ext(key)),
+		ETag:         fmt.Sprintf("%x-%x", fi.ModTime().UnixNano(), fi.Size()),
+		LastModified: fi.ModTime(),
+	}
+}
@@ -0,0 +1,90 @@
+package zistorage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFSBucket(t *testing.T) {
+	b, err := NewFSBucket(FSConfig{Dir: t.TempDir(), SigningKey: "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, key := range []string{"docs/b.txt", "docs/a.txt", "img/c.png"} {
+		if _, err := b.Put(ctx, key, strings.NewReader(key), PutOptions{}); err != nil {
+			t.Fatalf("Put(%s) = %v", key, err)
+		}
+	}
+	if _, err := b.Put(ctx, "../escape", strings.NewReader(""), PutOptions{}); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Put() outside of the directory = %v, want ErrInvalidKey", err)
+	}
+
+	rc, info, err := b.Get(ctx, "img/c.png")
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	content, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(content) != "img/c.png" || info.ContentType != "image/png" || info.Size != 9 {
+		t.Errorf("Get() = %q, %+v", content, info)
+	}
+
+	var keys []string
+	b.List(ctx, "docs/", func(info ObjectInfo) bool {
+		keys = append(keys, info.Key)
+		return true
+	})
+	if strings.Join(keys, ",") != "docs/a.txt,docs/b.txt" {
+		t.Errorf("List() = %v", keys)
+	}
+
+	if err := b.Delete(ctx, "docs/a.txt"); err != nil {
+		t.Errorf("Delete() = %v", err)
+	}
+	if _, err := b.Stat(ctx, "docs/a.txt"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Stat() of a deleted object = %v, want ErrObjectNotFound", err)
+	}
+}
+
+func TestFSBucketSignedURL(t *testing.T) {
+	b, err := NewFSBucket(FSConfig{Dir: t.TempDir(), SigningKey: "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.StripPrefix("/files", b.Handler()))
+	defer srv.Close()
+	b.config.BaseURL = srv.URL + "/files"
+	ctx := context.Background()
+
+	put, _ := b.SignedURL(ctx, "a.txt", http.MethodPut, time.Minute)
+	req, _ := http.NewRequest(http.MethodPut, put, strings.NewReader("hello"))
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT = %v, %v", resp.Status, err)
+	}
+	get, _ := b.SignedURL(ctx, "a.txt", http.MethodGet, time.Minute)
+	resp, err := http.Get(get)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(content) != "hello" {
+		t.Errorf("GET = %q, want hello", content)
+	}
+
+	// The signature is bound to the method and the expiration
+	if resp, _ := http.Post(get, "text/plain", nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("POST = %s, want 403", resp.Status)
+	}
+	expired, _ := b.SignedURL(ctx, "a.txt", http.MethodGet, -time.Minute)
+	if resp, _ := http.Get(expired); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("GET of an expired URL = %s, want 403", resp.Status)
+	}
+}
@@ -0,0 +1,20 @@
+package zistorage
+
+import "net/http"
+
+// GCSEndpoint is the endpoint of the XML API of Google Cloud Storage.
+const GCSEndpoint = "https://storage.googleapis.com"
+
+// NewGCSBucket returns the Bucket of a Google Cloud Storage bucket, over its
+// XML API in interoperability mode: the requests are signed like the S3
+// ones, with the HMAC key of a service account as AccessKeyID and
+// SecretAccessKey, see https://cloud.google.com/storage/docs/authentication/hmackeys.
+func NewGCSBucket(config S3Config, client *http.Client) (*S3Bucket, error) {
+	if config.Endpoint == "" {
+		config.Endpoint = GCSEndpoint
+	}
+	if config.Region == "" {
+		config.Region = "auto"
+	}
+	return NewS3Bucket(config, client)
+}
@@ -0,0 +1,293 @@
+package zistorage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/divikraf/lumos/internal/awsv4"
+)
+
+// S3Config configures an S3Bucket.
+type S3Config struct {
+	Bucket string `json:"bucket" yaml:"bucket" validate:"required"`
+	Region string `json:"region" yaml:"region"`
+	// Endpoint is the URL of the service, e.g. of a MinIO server (default:
+	// https://s3.<region>.amazonaws.com).
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+	// PathStyle addresses the bucket in the path of the URLs rather than in
+	// their host.
+	PathStyle bool `json:"path_style" yaml:"path_style"`
+	// AccessKeyID, SecretAccessKey and SessionToken are the credentials
+	// (default: the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+	// AWS_SESSION_TOKEN variables).
+	AccessKeyID     string `json:"access_key_id" yaml:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key" yaml:"secret_access_key"`
+	SessionToken    string `json:"session_token" yaml:"session_token"`
+}
+
+// S3Error is an error response of the S3 API.
+type S3Error struct {
+	Status  int
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (e *S3Error) Error() string {
+	return fmt.Sprintf("zistorage: %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// ErrorType returns the code of the error, as the normalized error type of
+// metrics.
+func (e *S3Error) ErrorType() string {
+	if e.Code == "" {
+		return "http_" + strconv.Itoa(e.Status)
+	}
+	return e.Code
+}
+
+// S3Bucket is the Bucket of an S3 bucket, or of a service compatible with
+// the S3 API, over its REST API with requests signed with AWS Signature
+// Version 4. It also presigns the PUT uploads of Uploads.
+type S3Bucket struct {
+	config   S3Config
+	endpoint *url.URL
+	client   *http.Client
+	creds    awsv4.Credentials
+	service  string
+	now      func() time.Time
+}
+
+// NewS3Bucket returns the S3Bucket of config, calling the API with client, or
+// http.DefaultClient if nil.
+func NewS3Bucket(config S3Config, client *http.Client) (*S3Bucket, error) {
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = "https://s3." + config.Region + ".amazonaws.com"
+	}
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("zistorage: invalid endpoint %s: %w", config.Endpoint, err)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &S3Bucket{
+		config:   config,
+		endpoint: endpoint,
+		client:   client,
+		creds: awsv4.CredentialsFromEnv(awsv4.Credentials{
+			AccessKeyID:     config.AccessKeyID,
+			SecretAccessKey: config.SecretAccessKey,
+			SessionToken:    config.SessionToken,
+		}),
+		service: "s3",
+		now:     time.Now,
+	}, nil
+}
+
+// objectURL returns the URL of the object key, the bucket itself when empty.
+func (b *S3Bucket) objectURL(key string) *url.URL {
+	u := *b.endpoint
+	path := strings.TrimRight(u.Path, "/")
+	if b.config.PathStyle {
+		path += "/" + b.config.Bucket
+	} else {
+		u.Host = b.config.Bucket + "." + u.Host
+	}
+	path += "/" + key
+	u.Path = path
+	u.RawPath = awsv4.URIEncode(path, false)
+	return &u
+}
+
+func (b *S3Bucket) Put(ctx context.Context, key string, r io.Reader, opts PutOptions) (ObjectInfo, error) {
+	size := opts.Size
+	if size <= 0 {
+		// S3 requires the length of the objects
+		buf, err := io.ReadAll(r)
+		if err != nil {
+			return ObjectInfo{}, err
+		}
+		r, size = bytes.NewReader(buf), int64(len(buf))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, b.objectURL(key).String(), r)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	if opts.ContentType != "" {
+		req.Header.Set("Content-Type", opts.ContentType)
+	}
+	resp, err := b.do(req)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	resp.Body.Close()
+	return ObjectInfo{
+		Key:          key,
+		Size:         size,
+		ContentType:  opts.ContentType,
+		ETag:         strings.Trim(resp.Header.Get("ETag"), `"`),
+		LastModified: b.now().UTC(),
+	}, nil
+}
+
+func (b *S3Bucket) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	resp, err := b.do(req)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	return resp.Body, headerInfo(key, resp), nil
+}
+
+func (b *S3Bucket) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, b.objectURL(key).String(), nil)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	resp, err := b.do(req)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	resp.Body.Close()
+	return headerInfo(key, resp), nil
+}
+
+func (b *S3Bucket) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, b.objectURL(key).String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := b.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		ETag         string    `xml:"ETag"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (b *S3Bucket) List(ctx context.Context, prefix string, fn func(ObjectInfo) bool) error {
+	var token string
+	for {
+		u := b.objectURL("")
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		u.RawQuery = q.Encode()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return err
+		}
+		resp, err := b.do(req)
+		if err != nil {
+			return err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		for _, c := range result.Contents {
+			info := ObjectInfo{Key: c.Key, Size: c.Size, ETag: strings.Trim(c.ETag, `"`), LastModified: c.LastModified}
+			if !fn(info) {
+				return nil
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (b *S3Bucket) SignedURL(ctx context.Context, key, method string, expires time.Duration) (string, error) {
+	return b.presign(method, key, expires, nil), nil
+}
+
+// Presign implements ObjectStore, for PUT uploads. POST form uploads are not
+// supported.
+func (b *S3Bucket) Presign(ctx context.Context, input PresignInput) (PresignedRequest, error) {
+	if input.Method != http.MethodPut {
+		return PresignedRequest{}, fmt.Errorf("zistorage: %s uploads are not supported", input.Method)
+	}
+	headers := map[string]string{"Content-Type": input.ContentType}
+	return PresignedRequest{
+		Method:  input.Method,
+		URL:     b.presign(input.Method, input.Key, input.Expires, headers),
+		Headers: headers,
+	}, nil
+}
+
+// do signs and sends req, returning the error responses as *S3Error, and
+// the 404 of objects as ErrObjectNotFound.
+func (b *S3Bucket) do(req *http.Request) (*http.Response, error) {
+	b.sign(req, awsv4.UnsignedPayload)
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	e := &S3Error{Status: resp.StatusCode}
+	xml.NewDecoder(resp.Body).Decode(e)
+	if e.Code == "NoSuchKey" || (req.Method == http.MethodHead && resp.StatusCode == http.StatusNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, req.URL.Path)
+	}
+	return nil, e
+}
+
+// headerInfo returns the info of the object key from the headers of resp.
+func headerInfo(key string, resp *http.Response) ObjectInfo {
+	modified, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return ObjectInfo{
+		Key:          key,
+		Size:         resp.ContentLength,
+		ContentType:  resp.Header.Get("Content-Type"),
+		ETag:         strings.Trim(resp.Header.Get("ETag"), `"`),
+		LastModified: modified,
+	}
+}
+
+// sign signs req in its Authorization header, with the hash of its payload.
+func (b *S3Bucket) sign(req *http.Request, payloadHash string) {
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	awsv4.SignPayload(req, payloadHash, b.creds, b.config.Region, b.service, b.now())
+}
+
+// presign returns the URL of method on the object key, signed in its query
+// until it expires, with the headers to send.
+func (b *S3Bucket) presign(method, key string, expires time.Duration, headers map[string]string) string {
+	return awsv4.Presign(method, b.objectURL(key), headers, expires, b.creds, b.config.Region, b.service, b.now())
+}
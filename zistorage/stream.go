@@ -0,0 +1,208 @@
+package zistorage
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+)
+
+// ErrTooLarge is returned when a stream exceeds its configured maximum size.
+var ErrTooLarge = errors.New("zistorage: stream exceeds maximum size")
+
+const sniffLen = 512
+
+// Checksums holds hex encoded checksums of a stream.
+type Checksums struct {
+	MD5    string `json:"md5,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// StreamConfig configures checksum computation and size enforcement.
+type StreamConfig struct {
+	// MaxSize is the maximum number of bytes allowed, 0 means unlimited.
+	MaxSize int64
+	// DisableMD5 skips MD5 computation.
+	DisableMD5 bool
+	// DisableSHA256 skips SHA256 computation.
+	DisableSHA256 bool
+}
+
+// StreamOption is a functional option to configure streams.
+type StreamOption func(cfg *StreamConfig)
+
+// WithMaxSize limits the stream to n bytes, reads beyond it return
+// ErrTooLarge.
+func WithMaxSize(n int64) StreamOption {
+	return func(cfg *StreamConfig) {
+		cfg.MaxSize = n
+	}
+}
+
+// WithoutMD5 disables MD5 computation.
+func WithoutMD5() StreamOption {
+	return func(cfg *StreamConfig) {
+		cfg.DisableMD5 = true
+	}
+}
+
+// WithoutSHA256 disables SHA256 computation.
+func WithoutSHA256() StreamOption {
+	return func(cfg *StreamConfig) {
+		cfg.DisableSHA256 = true
+	}
+}
+
+// checksummer computes the configured checksums and counts bytes.
+type checksummer struct {
+	config StreamConfig
+	md5    hash.Hash
+	sha256 hash.Hash
+	size   int64
+}
+
+func newChecksummer(opts []StreamOption) *checksummer {
+	c := &checksummer{}
+	for _, o := range opts {
+		o(&c.config)
+	}
+	if !c.config.DisableMD5 {
+		c.md5 = md5.New()
+	}
+	if !c.config.DisableSHA256 {
+		c.sha256 = sha256.New()
+	}
+	return c
+}
+
+// write feeds p into the checksums, it returns ErrTooLarge when the maximum
+// size is exceeded.
+func (c *checksummer) write(p []byte) error {
+	c.size += int64(len(p))
+	if c.config.MaxSize > 0 && c.size > c.config.MaxSize {
+		return ErrTooLarge
+	}
+	if c.md5 != nil {
+		c.md5.Write(p)
+	}
+	if c.sha256 != nil {
+		c.sha256.Write(p)
+	}
+	return nil
+}
+
+func (c *checksummer) checksums() Checksums {
+	var out Checksums
+	if c.md5 != nil {
+		out.MD5 = hex.EncodeToString(c.md5.Sum(nil))
+	}
+	if c.sha256 != nil {
+		out.SHA256 = hex.EncodeToString(c.sha256.Sum(nil))
+	}
+	return out
+}
+
+// Reader computes checksums on the fly while the underlying stream is read.
+// It also enforces the maximum size and detects the content type.
+type Reader struct {
+	src *bufio.Reader
+	sum *checksummer
+	err error
+}
+
+// NewReader wraps r to compute checksums while it's being read.
+func NewReader(r io.Reader, opts ...StreamOption) *Reader {
+	return &Reader{
+		src: bufio.NewReaderSize(r, sniffLen),
+		sum: newChecksummer(opts),
+	}
+}
+
+// Read implements io.Reader.
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.src.Read(p)
+	if errSum := r.sum.write(p[:n]); errSum != nil {
+		r.err = errSum
+		return 0, errSum
+	}
+	return n, err
+}
+
+// ContentType detects the content type from the first bytes of the stream
+// without consuming them. It should be called before the stream is read.
+func (r *Reader) ContentType() string {
+	head, _ := r.src.Peek(sniffLen)
+	return http.DetectContentType(head)
+}
+
+// Size returns the number of bytes read so far.
+func (r *Reader) Size() int64 {
+	return r.sum.size
+}
+
+// Checksums returns the checksums of the bytes read so far.
+func (r *Reader) Checksums() Checksums {
+	return r.sum.checksums()
+}
+
+// Writer computes checksums on the fly while data is written to the
+// underlying writer. It also enforces the maximum size.
+type Writer struct {
+	dst io.Writer
+	sum *checksummer
+}
+
+// NewWriter wraps w to compute checksums while it's being written.
+func NewWriter(w io.Writer, opts ...StreamOption) *Writer {
+	return &Writer{
+		dst: w,
+		sum: newChecksummer(opts),
+	}
+}
+
+// Write implements io.Writer.
+func (w *Writer) Write(p []byte) (int, error) {
+	if err := w.sum.write(p); err != nil {
+		return 0, err
+	}
+	return w.dst.Write(p)
+}
+
+// Size returns the number of bytes written so far.
+func (w *Writer) Size() int64 {
+	return w.sum.size
+}
+
+// Checksums returns the checksums of the bytes written so far.
+func (w *Writer) Checksums() Checksums {
+	return w.sum.checksums()
+}
+
+// StreamResult describes a fully consumed stream.
+type StreamResult struct {
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	Checksums   Checksums `json:"checksums"`
+}
+
+// Copy copies src into dst while computing checksums, enforcing the maximum
+// size, and detecting the content type.
+func Copy(dst io.Writer, src io.Reader, opts ...StreamOption) (StreamResult, error) {
+	r := NewReader(src, opts...)
+	contentType := r.ContentType()
+	if _, err := io.Copy(dst, r); err != nil {
+		return StreamResult{}, err
+	}
+	return StreamResult{
+		Size:        r.Size(),
+		ContentType: contentType,
+		Checksums:   r.Checksums(),
+	}, nil
+}
@@ -0,0 +1,66 @@
+package zistorage
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestCopy(t *testing.T) {
+	var dst bytes.Buffer
+	res, err := Copy(&dst, strings.NewReader("hello world"))
+	if err != nil {
+		t.Fatalf("Failed to copy: %v", err)
+	}
+
+	if dst.String() != "hello world" {
+		t.Errorf("Copied = %q, want %q", dst.String(), "hello world")
+	}
+	if res.Size != 11 {
+		t.Errorf("Size = %d, want 11", res.Size)
+	}
+	if res.ContentType != "text/plain; charset=utf-8" {
+		t.Errorf("ContentType = %q", res.ContentType)
+	}
+	if res.Checksums.MD5 != "5eb63bbbe01eeed093cb22bb8f5acdc3" {
+		t.Errorf("MD5 = %q", res.Checksums.MD5)
+	}
+	if res.Checksums.SHA256 != "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9" {
+		t.Errorf("SHA256 = %q", res.Checksums.SHA256)
+	}
+}
+
+func TestCopyTooLarge(t *testing.T) {
+	var dst bytes.Buffer
+	_, err := Copy(&dst, strings.NewReader("hello world"), WithMaxSize(5))
+	if !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("Error = %v, want %v", err, ErrTooLarge)
+	}
+}
+
+func TestTempFiles(t *testing.T) {
+	logger := zerolog.Nop()
+	tf := NewTempFiles(t.TempDir(), &logger)
+
+	f, res, err := tf.Spool("upload-*", strings.NewReader("hello world"))
+	if err != nil {
+		t.Fatalf("Failed to spool: %v", err)
+	}
+	if res.Size != 11 {
+		t.Errorf("Size = %d, want 11", res.Size)
+	}
+
+	if err := tf.Cleanup(); err != nil {
+		t.Fatalf("Failed to cleanup: %v", err)
+	}
+	if _, err := os.Stat(f.Name()); !os.IsNotExist(err) {
+		t.Error("Temporary file should be removed on cleanup")
+	}
+	if err := f.Release(); err != nil {
+		t.Errorf("Release after cleanup should be a no-op, got %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package zistorage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// TempFiles keeps track of temporary files so they can be removed once they
+// are no longer needed, or at the latest when the app shuts down.
+type TempFiles struct {
+	dir    string
+	logger *zerolog.Logger
+	mu     sync.Mutex
+	files  map[string]*TempFile
+}
+
+// NewTempFiles returns a temporary file registry creating files in dir. The
+// default directory for temporary files is used when dir is empty.
+func NewTempFiles(dir string, logger *zerolog.Logger) *TempFiles {
+	return &TempFiles{
+		dir:    dir,
+		logger: logger,
+		files:  map[string]*TempFile{},
+	}
+}
+
+// TempFile is a temporary file tracked by TempFiles.
+type TempFile struct {
+	*os.File
+	owner *TempFiles
+	once  sync.Once
+}
+
+// Release closes and removes the temporary file. It is safe to call Release
+// multiple times.
+func (f *TempFile) Release() error {
+	var err error
+	f.once.Do(func() {
+		err = f.owner.remove(f)
+	})
+	return err
+}
+
+// Create creates a new tracked temporary file, see [os.CreateTemp] for the
+// pattern semantics.
+func (t *TempFiles) Create(pattern string) (*TempFile, error) {
+	f, err := os.CreateTemp(t.dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	tf := &TempFile{File: f, owner: t}
+	t.mu.Lock()
+	t.files[f.Name()] = tf
+	t.mu.Unlock()
+	return tf, nil
+}
+
+// Spool streams src into a new tracked temporary file while computing its
+// checksums, enforcing the maximum size, and detecting the content type. The
+// returned file is rewound and ready to be read. The file is released when
+// spooling fails.
+func (t *TempFiles) Spool(pattern string, src io.Reader, opts ...StreamOption) (*TempFile, StreamResult, error) {
+	f, err := t.Create(pattern)
+	if err != nil {
+		return nil, StreamResult{}, err
+	}
+
+	res, err := Copy(f, src, opts...)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = f.Release()
+		return nil, StreamResult{}, err
+	}
+	return f, res, nil
+}
+
+func (t *TempFiles) remove(f *TempFile) error {
+	t.mu.Lock()
+	delete(t.files, f.Name())
+	t.mu.Unlock()
+
+	errClose := f.File.Close()
+	if errClose != nil && errors.Is(errClose, os.ErrClosed) {
+		errClose = nil
+	}
+	errRemove := os.Remove(f.Name())
+	if errRemove != nil && errors.Is(errRemove, os.ErrNotExist) {
+		errRemove = nil
+	}
+	return errors.Join(errClose, errRemove)
+}
+
+// Cleanup releases every temporary file that has not been released yet.
+func (t *TempFiles) Cleanup() error {
+	t.mu.Lock()
+	files := make([]*TempFile, 0, len(t.files))
+	for _, f := range t.files {
+		files = append(files, f)
+	}
+	t.mu.Unlock()
+
+	var returnErr error
+	for _, f := range files {
+		if err := f.Release(); err != nil {
+			t.logger.Error().Err(err).
+				Msgf("failed to remove temporary file: %s", f.Name())
+			returnErr = err
+		}
+	}
+	return returnErr
+}
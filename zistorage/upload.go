@@ -0,0 +1,318 @@
+package zistorage
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"slices"
+	"time"
+
+	"github.com/divikraf/lumos/db/zisqlx"
+	"github.com/jmoiron/sqlx"
+)
+
+// DefaultUploadsTable is the default table of the pending uploads.
+const DefaultUploadsTable = "storage_uploads"
+
+// Upload statuses
+const (
+	UploadPending   = "pending"
+	UploadCompleted = "completed"
+)
+
+var (
+	// ErrObjectNotFound is returned for missing objects.
+	ErrObjectNotFound = errors.New("zistorage: object not found")
+	// ErrUploadNotFound is returned for unknown uploads, or uploads of
+	// another tenant.
+	ErrUploadNotFound = errors.New("zistorage: upload not found")
+	// ErrUploadExpired is returned when completing an upload past its
+	// expiration.
+	ErrUploadExpired = errors.New("zistorage: upload expired")
+	// ErrUploadRejected is returned when an upload breaks its policy, e.g.
+	// with a content type not allowed or a size above the maximum.
+	ErrUploadRejected = errors.New("zistorage: upload rejected")
+)
+
+// PresignInput describes the upload of an object with a presigned request.
+type PresignInput struct {
+	// Method is http.MethodPut, or http.MethodPost for a form upload whose
+	// policy enforces the size.
+	Method      string
+	Key         string
+	ContentType string
+	MaxSize     int64
+	Expires     time.Duration
+}
+
+// PresignedRequest is a request uploading an object without credentials.
+type PresignedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// Headers must be sent with the request.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Fields are the form fields of POST uploads.
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+}
+
+// ObjectStore is the object storage backend of Uploads, e.g. S3 or GCS.
+type ObjectStore interface {
+	// Presign returns a request uploading an object as described by input.
+	Presign(ctx context.Context, input PresignInput) (PresignedRequest, error)
+	// Stat returns the info of the object key, or ErrObjectNotFound.
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// Delete deletes the object key.
+	Delete(ctx context.Context, key string) error
+}
+
+// UploadPolicy constrains the uploads.
+type UploadPolicy struct {
+	// Method is the method of the presigned requests (default: PUT).
+	Method string `json:"method" yaml:"method"`
+	// AllowedContentTypes are the media types of the uploads, any when
+	// empty.
+	AllowedContentTypes []string `json:"allowed_content_types" yaml:"allowed_content_types"`
+	// MaxSize is the maximum size of an upload in bytes (default: 10MiB).
+	MaxSize int64 `json:"max_size" yaml:"max_size"`
+	// Expires is the validity of the presigned requests, and the delay to
+	// complete an upload (default: 15m).
+	Expires time.Duration `json:"expires" yaml:"expires"`
+	// KeyPrefix prefixes the object keys, followed by the tenant, e.g.
+	// "uploads/" gives "uploads/<tenant>/<id>".
+	KeyPrefix string `json:"key_prefix" yaml:"key_prefix"`
+}
+
+func (p UploadPolicy) withDefaults() UploadPolicy {
+	if p.Method == "" {
+		p.Method = "PUT"
+	}
+	if p.MaxSize <= 0 {
+		p.MaxSize = 10 << 20
+	}
+	if p.Expires <= 0 {
+		p.Expires = 15 * time.Minute
+	}
+	return p
+}
+
+// Upload is the state of an upload.
+type Upload struct {
+	ID          string     `db:"id" json:"id"`
+	Tenant      string     `db:"tenant" json:"-"`
+	Key         string     `db:"object_key" json:"key"`
+	ContentType string     `db:"content_type" json:"content_type"`
+	MaxSize     int64      `db:"max_size" json:"max_size"`
+	Status      string     `db:"status" json:"status"`
+	Size        int64      `db:"size" json:"size,omitempty"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	ExpiresAt   time.Time  `db:"expires_at" json:"expires_at"`
+	CompletedAt *time.Time `db:"completed_at" json:"completed_at,omitempty"`
+}
+
+// Uploads orchestrates uploads made by clients directly to the object
+// storage, with presigned requests, so large files don't go through the API:
+// Start records a pending upload and presigns its request, and Complete
+// verifies the uploaded object against the policy once the client reports
+// it done. The uploads are recorded in a table expected to be:
+//
+//	CREATE TABLE storage_uploads (
+//		id           VARCHAR(64) PRIMARY KEY,
+//		tenant       VARCHAR(255) NOT NULL,
+//		object_key   TEXT NOT NULL,
+//		content_type VARCHAR(255) NOT NULL,
+//		max_size     BIGINT NOT NULL,
+//		status       VARCHAR(16) NOT NULL,
+//		size         BIGINT NOT NULL DEFAULT 0,
+//		created_at   TIMESTAMP NOT NULL,
+//		expires_at   TIMESTAMP NOT NULL,
+//		completed_at TIMESTAMP NULL
+//	);
+type Uploads struct {
+	store    ObjectStore
+	db       zisqlx.BasicQueryerExecuter
+	bindType int
+	table    string
+	policy   UploadPolicy
+}
+
+// UploadsOption configures Uploads.
+type UploadsOption func(*Uploads)
+
+// WithUploadsTable sets the table of the uploads (default:
+// DefaultUploadsTable).
+func WithUploadsTable(table string) UploadsOption {
+	return func(u *Uploads) {
+		u.table = table
+	}
+}
+
+// NewUploads returns Uploads presigning with store and recording the uploads
+// in db. bindType is the placeholder style of the database, e.g. sqlx.DOLLAR
+// for PostgreSQL and sqlx.QUESTION for MySQL.
+func NewUploads(store ObjectStore, db zisqlx.BasicQueryerExecuter, bindType int, policy UploadPolicy, opts ...UploadsOption) *Uploads {
+	u := &Uploads{
+		store:    store,
+		db:       db,
+		bindType: bindType,
+		table:    DefaultUploadsTable,
+		policy:   policy.withDefaults(),
+	}
+	for _, o := range opts {
+		o(u)
+	}
+	return u
+}
+
+func (u *Uploads) query(q string) string {
+	return sqlx.Rebind(u.bindType, fmt.Sprintf(q, u.table))
+}
+
+// Start starts an upload of tenant, of size bytes of contentType, returning
+// the request the client uploads the object with.
+func (u *Uploads) Start(ctx context.Context, tenant, contentType string, size int64) (Upload, PresignedRequest, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return Upload{}, PresignedRequest{}, fmt.Errorf("%w: invalid content type %q", ErrUploadRejected, contentType)
+	}
+	if len(u.policy.AllowedContentTypes) > 0 && !slices.Contains(u.policy.AllowedContentTypes, mediaType) {
+		return Upload{}, PresignedRequest{}, fmt.Errorf("%w: content type %s not allowed", ErrUploadRejected, mediaType)
+	}
+	if size > u.policy.MaxSize {
+		return Upload{}, PresignedRequest{}, fmt.Errorf("%w: size %d above the maximum of %d", ErrUploadRejected, size, u.policy.MaxSize)
+	}
+
+	id, err := newUploadID()
+	if err != nil {
+		return Upload{}, PresignedRequest{}, err
+	}
+	now := time.Now().UTC()
+	upload := Upload{
+		ID:          id,
+		Tenant:      tenant,
+		Key:         u.policy.KeyPrefix + tenant + "/" + id,
+		ContentType: contentType,
+		MaxSize:     u.policy.MaxSize,
+		Status:      UploadPending,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(u.policy.Expires),
+	}
+	req, err := u.store.Presign(ctx, PresignInput{
+		Method:      u.policy.Method,
+		Key:         upload.Key,
+		ContentType: contentType,
+		MaxSize:     u.policy.MaxSize,
+		Expires:     u.policy.Expires,
+	})
+	if err != nil {
+		return Upload{}, PresignedRequest{}, err
+	}
+	_, err = u.db.ExecContext(ctx, "zistorage.start_upload",
+		u.query("INSERT INTO %s (id, tenant, object_key, content_type, max_size, status, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"),
+		upload.ID, upload.Tenant, upload.Key, upload.ContentType, upload.MaxSize, upload.Status, upload.CreatedAt, upload.ExpiresAt)
+	if err != nil {
+		return Upload{}, PresignedRequest{}, err
+	}
+	return upload, req, nil
+}
+
+// Get returns the upload id of tenant.
+func (u *Uploads) Get(ctx context.Context, tenant, id string) (Upload, error) {
+	var upload Upload
+	err := u.db.GetContext(ctx, "zistorage.get_upload", &upload,
+		u.query("SELECT id, tenant, object_key, content_type, max_size, status, size, created_at, expires_at, completed_at FROM %s WHERE id = ? AND tenant = ?"),
+		id, tenant)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Upload{}, ErrUploadNotFound
+	}
+	return upload, err
+}
+
+// Complete verifies the uploaded object of the upload id of tenant, and
+// marks the upload completed. Objects breaking the policy are deleted, and
+// ErrUploadRejected returned. Completing a completed upload returns it.
+func (u *Uploads) Complete(ctx context.Context, tenant, id string) (Upload, error) {
+	upload, err := u.Get(ctx, tenant, id)
+	if err != nil || upload.Status == UploadCompleted {
+		return upload, err
+	}
+	if time.Now().After(upload.ExpiresAt) {
+		return Upload{}, ErrUploadExpired
+	}
+
+	info, err := u.store.Stat(ctx, upload.Key)
+	if errors.Is(err, ErrObjectNotFound) {
+		return Upload{}, fmt.Errorf("%w: object not uploaded", ErrUploadRejected)
+	}
+	if err != nil {
+		return Upload{}, err
+	}
+	if reason := u.verify(upload, info); reason != "" {
+		if err := u.store.Delete(ctx, upload.Key); err != nil {
+			return Upload{}, err
+		}
+		return Upload{}, fmt.Errorf("%w: %s", ErrUploadRejected, reason)
+	}
+
+	now := time.Now().UTC()
+	_, err = u.db.ExecContext(ctx, "zistorage.complete_upload",
+		u.query("UPDATE %s SET status = ?, size = ?, completed_at = ? WHERE id = ? AND status = ?"),
+		UploadCompleted, info.Size, now, upload.ID, UploadPending)
+	if err != nil {
+		return Upload{}, err
+	}
+	upload.Status, upload.Size, upload.CompletedAt = UploadCompleted, info.Size, &now
+	return upload, nil
+}
+
+// verify returns why the object info breaks the upload, or an empty string.
+func (u *Uploads) verify(upload Upload, info ObjectInfo) string {
+	if info.Size > upload.MaxSize {
+		return fmt.Sprintf("size %d above the maximum of %d", info.Size, upload.MaxSize)
+	}
+	if info.ContentType != "" && info.ContentType != upload.ContentType {
+		return fmt.Sprintf("content type %s instead of %s", info.ContentType, upload.ContentType)
+	}
+	return ""
+}
+
+// Purge deletes the pending uploads expired for more than olderThan, with
+// their objects, if any.
+func (u *Uploads) Purge(ctx context.Context, olderThan time.Duration) (int, error) {
+	var expired []Upload
+	err := u.db.SelectContext(ctx, "zistorage.list_expired_uploads", &expired,
+		u.query("SELECT id, tenant, object_key, content_type, max_size, status, size, created_at, expires_at, completed_at FROM %s WHERE status = ? AND expires_at < ?"),
+		UploadPending, time.Now().UTC().Add(-olderThan))
+	if err != nil {
+		return 0, err
+	}
+	for i, upload := range expired {
+		if err := u.store.Delete(ctx, upload.Key); err != nil && !errors.Is(err, ErrObjectNotFound) {
+			return i, err
+		}
+		if _, err := u.db.ExecContext(ctx, "zistorage.delete_upload", u.query("DELETE FROM %s WHERE id = ?"), upload.ID); err != nil {
+			return i, err
+		}
+	}
+	return len(expired), nil
+}
+
+func newUploadID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
@@ -0,0 +1,104 @@
+package zistorage
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/divikraf/lumos/zin"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrUploadGone is the API error of expired uploads.
+var ErrUploadGone = zin.NewAPIError(http.StatusGone, "upload_expired", "error.upload_expired", "Upload expired")
+
+// TenantFunc returns the tenant of a request, empty when unauthenticated.
+type TenantFunc func(c *gin.Context) string
+
+// ClaimsTenant returns a TenantFunc reading the tenant from the claim of the
+// authenticated request, or its subject when claim is empty.
+func ClaimsTenant(claim string) TenantFunc {
+	return func(c *gin.Context) string {
+		claims, ok := zin.ClaimsFromContext(c.Request.Context())
+		if !ok {
+			return ""
+		}
+		if claim == "" {
+			return claims.Subject
+		}
+		return claims.String(claim)
+	}
+}
+
+type startUploadRequest struct {
+	ContentType string `json:"content_type" binding:"required"`
+	Size        int64  `json:"size" binding:"required,gt=0"`
+}
+
+type startUploadResponse struct {
+	Upload  Upload           `json:"upload"`
+	Request PresignedRequest `json:"request"`
+}
+
+// RegisterRoutes registers the upload endpoints on router, uploads being
+// scoped to the tenant of tenant:
+//
+//   - POST /uploads {"content_type", "size"} starts an upload, returning it
+//     with the presigned request to upload the object with.
+//   - POST /uploads/:id/complete completes it once the object is uploaded.
+//   - GET /uploads/:id returns it.
+func (u *Uploads) RegisterRoutes(router gin.IRouter, tenant TenantFunc) {
+	router.POST("/uploads", zin.Handle(func(c *gin.Context) error {
+		t := tenant(c)
+		if t == "" {
+			return zin.ErrUnauthorized
+		}
+		var req startUploadRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			return zin.ErrBadRequest.Wrap(err)
+		}
+		upload, presigned, err := u.Start(c.Request.Context(), t, req.ContentType, req.Size)
+		if err != nil {
+			return uploadError(err)
+		}
+		zin.Created(c, startUploadResponse{Upload: upload, Request: presigned})
+		return nil
+	}))
+	router.POST("/uploads/:id/complete", zin.Handle(func(c *gin.Context) error {
+		t := tenant(c)
+		if t == "" {
+			return zin.ErrUnauthorized
+		}
+		upload, err := u.Complete(c.Request.Context(), t, c.Param("id"))
+		if err != nil {
+			return uploadError(err)
+		}
+		zin.OK(c, upload)
+		return nil
+	}))
+	router.GET("/uploads/:id", zin.Handle(func(c *gin.Context) error {
+		t := tenant(c)
+		if t == "" {
+			return zin.ErrUnauthorized
+		}
+		upload, err := u.Get(c.Request.Context(), t, c.Param("id"))
+		if err != nil {
+			return uploadError(err)
+		}
+		zin.OK(c, upload)
+		return nil
+	}))
+}
+
+// uploadError maps the errors of Uploads to API errors.
+func uploadError(err error) error {
+	switch {
+	case errors.Is(err, ErrUploadNotFound):
+		return zin.ErrNotFound.Wrap(err)
+	case errors.Is(err, ErrUploadExpired):
+		return ErrUploadGone.Wrap(err)
+	case errors.Is(err, ErrUploadRejected):
+		return zin.ErrUnprocessableEntity.WithDetails(err.Error()).Wrap(err)
+	default:
+		return zin.ErrInternal.Wrap(err)
+	}
+}
@@ -0,0 +1,139 @@
+package zistorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/db/zisqlx"
+	"github.com/jmoiron/sqlx"
+)
+
+// uploadsDB is an in-memory uploads table, dispatching on the operation
+// names of Uploads.
+type uploadsDB struct {
+	zisqlx.BasicQueryerExecuter
+	rows map[string]Upload
+}
+
+func (db *uploadsDB) ExecContext(ctx context.Context, operationName string, query string, args ...any) (sql.Result, error) {
+	switch operationName {
+	case "zistorage.start_upload":
+		db.rows[args[0].(string)] = Upload{
+			ID: args[0].(string), Tenant: args[1].(string), Key: args[2].(string),
+			ContentType: args[3].(string), MaxSize: args[4].(int64), Status: args[5].(string),
+			CreatedAt: args[6].(time.Time), ExpiresAt: args[7].(time.Time),
+		}
+	case "zistorage.complete_upload":
+		row := db.rows[args[3].(string)]
+		completedAt := args[2].(time.Time)
+		row.Status, row.Size, row.CompletedAt = args[0].(string), args[1].(int64), &completedAt
+		db.rows[row.ID] = row
+	}
+	return nil, nil
+}
+
+func (db *uploadsDB) GetContext(ctx context.Context, operationName string, dest any, query string, args ...any) error {
+	row, ok := db.rows[args[0].(string)]
+	if !ok || row.Tenant != args[1].(string) {
+		return sql.ErrNoRows
+	}
+	*dest.(*Upload) = row
+	return nil
+}
+
+type fakeObjectStore struct {
+	objects map[string]ObjectInfo
+	deleted []string
+}
+
+func (s *fakeObjectStore) Presign(ctx context.Context, input PresignInput) (PresignedRequest, error) {
+	return PresignedRequest{
+		Method:  input.Method,
+		URL:     "https://bucket.example.com/" + input.Key,
+		Headers: map[string]string{"Content-Type": input.ContentType},
+	}, nil
+}
+
+func (s *fakeObjectStore) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	info, ok := s.objects[key]
+	if !ok {
+		return ObjectInfo{}, ErrObjectNotFound
+	}
+	return info, nil
+}
+
+func (s *fakeObjectStore) Delete(ctx context.Context, key string) error {
+	s.deleted = append(s.deleted, key)
+	delete(s.objects, key)
+	return nil
+}
+
+func newTestUploads() (*Uploads, *fakeObjectStore) {
+	store := &fakeObjectStore{objects: map[string]ObjectInfo{}}
+	db := &uploadsDB{rows: map[string]Upload{}}
+	return NewUploads(store, db, sqlx.QUESTION, UploadPolicy{
+		AllowedContentTypes: []string{"image/png"},
+		MaxSize:             100,
+		KeyPrefix:           "uploads/",
+	}), store
+}
+
+func TestUploads(t *testing.T) {
+	ctx := context.Background()
+	uploads, store := newTestUploads()
+
+	upload, req, err := uploads.Start(ctx, "acme", "image/png", 50)
+	if err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+	if upload.Key != "uploads/acme/"+upload.ID || upload.Status != UploadPending {
+		t.Errorf("Upload = %+v", upload)
+	}
+	if req.Method != http.MethodPut || req.URL != "https://bucket.example.com/"+upload.Key {
+		t.Errorf("Request = %+v", req)
+	}
+
+	if _, err := uploads.Complete(ctx, "acme", upload.ID); !errors.Is(err, ErrUploadRejected) {
+		t.Errorf("Complete before upload = %v, want ErrUploadRejected", err)
+	}
+	if _, err := uploads.Complete(ctx, "other", upload.ID); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("Complete of another tenant = %v, want ErrUploadNotFound", err)
+	}
+
+	store.objects[upload.Key] = ObjectInfo{Size: 42, ContentType: "image/png"}
+	completed, err := uploads.Complete(ctx, "acme", upload.ID)
+	if err != nil {
+		t.Fatalf("Failed to complete: %v", err)
+	}
+	if completed.Status != UploadCompleted || completed.Size != 42 || completed.CompletedAt == nil {
+		t.Errorf("Completed = %+v", completed)
+	}
+}
+
+func TestUploadsRejected(t *testing.T) {
+	ctx := context.Background()
+	uploads, store := newTestUploads()
+
+	if _, _, err := uploads.Start(ctx, "acme", "text/plain", 10); !errors.Is(err, ErrUploadRejected) {
+		t.Errorf("Start with a content type not allowed = %v, want ErrUploadRejected", err)
+	}
+	if _, _, err := uploads.Start(ctx, "acme", "image/png", 101); !errors.Is(err, ErrUploadRejected) {
+		t.Errorf("Start above the maximum size = %v, want ErrUploadRejected", err)
+	}
+
+	upload, _, err := uploads.Start(ctx, "acme", "image/png", 10)
+	if err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+	store.objects[upload.Key] = ObjectInfo{Size: 1000, ContentType: "image/png"}
+	if _, err := uploads.Complete(ctx, "acme", upload.ID); !errors.Is(err, ErrUploadRejected) {
+		t.Errorf("Complete above the maximum size = %v, want ErrUploadRejected", err)
+	}
+	if len(store.deleted) != 1 || store.deleted[0] != upload.Key {
+		t.Errorf("Deleted = %v, want the rejected object", store.deleted)
+	}
+}
@@ -0,0 +1,29 @@
+package zistoragefx
+
+import (
+	"github.com/divikraf/lumos/zistorage"
+	"github.com/rs/zerolog"
+	"go.uber.org/fx"
+)
+
+type tempFilesParams struct {
+	fx.In
+
+	LC     fx.Lifecycle
+	Logger *zerolog.Logger
+}
+
+// TempFilesProvider provides *zistorage.TempFiles creating files in the
+// default temporary directory. Files that are still around when the app stops
+// are removed.
+var TempFilesProvider = fx.Provide(
+	func(params tempFilesParams) *zistorage.TempFiles {
+		tf := zistorage.NewTempFiles("", params.Logger)
+		params.LC.Append(fx.StopHook(tf.Cleanup))
+		return tf
+	},
+)
+
+// BucketsProvider provides zistorage.Buckets, the buckets of the storage
+// config.
+var BucketsProvider = fx.Provide(zistorage.NewConfiguredBuckets)
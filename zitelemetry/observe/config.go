@@ -10,6 +10,8 @@ type Config struct {
 	Environment string        `json:"environment" yaml:"environment"`
 	Tracing     TracingConfig `json:"tracing" yaml:"tracing"`
 	Metrics     MetricsConfig `json:"metrics" yaml:"metrics"`
+	// SelfProbe probes the service from inside the process, see SelfProbe
+	SelfProbe SelfProbeConfig `json:"self_probe" yaml:"self_probe"`
 }
 
 type ServiceConfig struct {
@@ -22,6 +24,31 @@ type TracingConfig struct {
 	Exporter ExporterConfig `json:"exporter" yaml:"exporter"`
 	Sampler  SamplerConfig  `json:"sampler" yaml:"sampler"`
 	Batch    BatchConfig    `json:"batch" yaml:"batch"`
+	// ErrorDetails attaches stack traces and error chains to recorded errors
+	ErrorDetails ErrorDetailsConfig `json:"error_details" yaml:"error_details"`
+	// Debug enables on-demand sampling of requests carrying a debug token
+	Debug DebugConfig `json:"debug" yaml:"debug"`
+}
+
+// DebugConfig configures the debug tokens forcing a request to be sampled
+// and logged verbosely, see IssueDebugToken.
+type DebugConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Secret signs the debug tokens, it must be shared by every service
+	Secret string `json:"secret" yaml:"secret"`
+	// MaxTTL rejects tokens valid for longer than this (default: 1h)
+	MaxTTL time.Duration `json:"max_ttl" yaml:"max_ttl"`
+}
+
+// ErrorDetailsConfig configures the details attached to errors recorded with
+// RecordError, RecordPanic and TelemetrySpan.RecordError. Capturing stack
+// traces has a cost, it is typically enabled outside of production only.
+type ErrorDetailsConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// MaxStackFrames caps the stack trace length (default: 32)
+	MaxStackFrames int `json:"max_stack_frames" yaml:"max_stack_frames"`
+	// MaxErrorChain caps the number of wrapped errors listed (default: 10)
+	MaxErrorChain int `json:"max_error_chain" yaml:"max_error_chain"`
 }
 
 // MetricsConfig holds metrics configuration
@@ -29,6 +56,12 @@ type MetricsConfig struct {
 	Enabled  bool           `json:"enabled" yaml:"enabled"`
 	Exporter ExporterConfig `json:"exporter" yaml:"exporter"`
 	Reader   ReaderConfig   `json:"reader" yaml:"reader"`
+	// ExemplarFilter selects which measurements are offered as exemplars:
+	// "trace_based" keeps measurements recorded within a sampled span,
+	// "always_on" keeps all of them, and "always_off" disables exemplars.
+	// When empty, the SDK default is used (trace based, unless overridden by
+	// OTEL_METRICS_EXEMPLAR_FILTER).
+	ExemplarFilter string `json:"exemplar_filter" yaml:"exemplar_filter"`
 }
 
 // ExporterConfig holds exporter configuration
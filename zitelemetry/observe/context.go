@@ -31,3 +31,18 @@ func SpanFromContext(ctx context.Context) Span {
 	span := inst.SpanFromContext(ctx)
 	return NewTelemetrySpan(span)
 }
+
+type routeKey struct{}
+
+// WithRoute returns a copy of ctx with the route served, e.g. the HTTP route
+// template or the RPC method, for the work it starts to be attributed to it,
+// e.g. in the SQL comments of zisqlx.
+func WithRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeKey{}, route)
+}
+
+// RouteFromContext returns the route of ctx, see WithRoute.
+func RouteFromContext(ctx context.Context) string {
+	route, _ := ctx.Value(routeKey{}).(string)
+	return route
+}
@@ -0,0 +1,125 @@
+package observe
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	// DebugHeader carries a debug token issued with IssueDebugToken.
+	DebugHeader = "X-Lumos-Debug"
+	// DebugBaggageKey is the baggage member propagating the debug token to
+	// downstream services.
+	DebugBaggageKey = "lumos.debug"
+	// DefaultDebugMaxTTL is the default maximum lifetime of debug tokens.
+	DefaultDebugMaxTTL = time.Hour
+)
+
+// DebugAttributeKey marks the spans sampled because of a debug token.
+const DebugAttributeKey = attribute.Key("lumos.debug")
+
+var debugConfig atomic.Pointer[DebugConfig]
+
+// SetDebug configures debug token verification. It is called by New with the
+// tracing configuration.
+func SetDebug(config DebugConfig) {
+	if config.MaxTTL <= 0 {
+		config.MaxTTL = DefaultDebugMaxTTL
+	}
+	debugConfig.Store(&config)
+}
+
+// IssueDebugToken returns a debug token valid for ttl, signed with secret. The
+// token is sent in the X-Lumos-Debug header to get a single request sampled
+// and logged verbosely end-to-end.
+func IssueDebugToken(secret string, ttl time.Duration) string {
+	expiry := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	return expiry + "." + signDebugToken(secret, expiry)
+}
+
+// VerifyDebugToken reports whether token was signed with the configured secret
+// and is neither expired nor valid for longer than the configured max TTL. It
+// always returns false when debug tokens are disabled.
+func VerifyDebugToken(token string) bool {
+	config := debugConfig.Load()
+	if config == nil || !config.Enabled || config.Secret == "" || token == "" {
+		return false
+	}
+
+	expiry, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return false
+	}
+	now := time.Now()
+	if exp := time.Unix(unix, 0); now.After(exp) || exp.Sub(now) > config.MaxTTL {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(signDebugToken(config.Secret, expiry)))
+}
+
+func signDebugToken(secret, expiry string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(expiry))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ContextWithDebugToken returns a copy of ctx carrying token in its baggage,
+// so it is propagated to downstream services.
+func ContextWithDebugToken(ctx context.Context, token string) (context.Context, error) {
+	member, err := baggage.NewMember(DebugBaggageKey, token)
+	if err != nil {
+		return ctx, fmt.Errorf("observe: invalid debug token: %w", err)
+	}
+	b, err := baggage.FromContext(ctx).SetMember(member)
+	if err != nil {
+		return ctx, fmt.Errorf("observe: invalid debug token: %w", err)
+	}
+	return baggage.ContextWithBaggage(ctx, b), nil
+}
+
+// IsDebug reports whether ctx carries a valid debug token.
+func IsDebug(ctx context.Context) bool {
+	return VerifyDebugToken(baggage.FromContext(ctx).Member(DebugBaggageKey).Value())
+}
+
+// debugSampler samples every span of debug requests, and defers to its base
+// sampler otherwise.
+type debugSampler struct {
+	base trace.Sampler
+}
+
+// NewDebugSampler returns a sampler sampling every span started with a context
+// carrying a valid debug token, see IsDebug, and deferring to base otherwise.
+func NewDebugSampler(base trace.Sampler) trace.Sampler {
+	return debugSampler{base: base}
+}
+
+// ShouldSample implements trace.Sampler.
+func (s debugSampler) ShouldSample(p trace.SamplingParameters) trace.SamplingResult {
+	if IsDebug(p.ParentContext) {
+		res := trace.AlwaysSample().ShouldSample(p)
+		res.Attributes = append(res.Attributes, DebugAttributeKey.Bool(true))
+		return res
+	}
+	return s.base.ShouldSample(p)
+}
+
+// Description implements trace.Sampler.
+func (s debugSampler) Description() string {
+	return "DebugSampler{" + s.base.Description() + "}"
+}
@@ -0,0 +1,78 @@
+package observe
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestDebugToken(t *testing.T) {
+	SetDebug(DebugConfig{Enabled: true, Secret: "s3cr3t", MaxTTL: time.Hour})
+	defer SetDebug(DebugConfig{})
+
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{name: "valid", token: IssueDebugToken("s3cr3t", time.Minute), want: true},
+		{name: "wrong secret", token: IssueDebugToken("other", time.Minute)},
+		{name: "expired", token: IssueDebugToken("s3cr3t", -time.Minute)},
+		{name: "longer than max TTL", token: IssueDebugToken("s3cr3t", 2*time.Hour)},
+		{name: "malformed", token: "not-a-token"},
+		{name: "empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyDebugToken(tt.token); got != tt.want {
+				t.Errorf("VerifyDebugToken(%q) = %v, want %v", tt.token, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		SetDebug(DebugConfig{Secret: "s3cr3t"})
+		defer SetDebug(DebugConfig{Enabled: true, Secret: "s3cr3t"})
+		if VerifyDebugToken(IssueDebugToken("s3cr3t", time.Minute)) {
+			t.Error("tokens should be rejected when debug is disabled")
+		}
+	})
+}
+
+func TestDebugSampler(t *testing.T) {
+	SetDebug(DebugConfig{Enabled: true, Secret: "s3cr3t"})
+	defer SetDebug(DebugConfig{})
+
+	tracer := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(NewDebugSampler(sdktrace.NeverSample())),
+	).Tracer("test")
+
+	_, span := tracer.Start(context.Background(), "regular")
+	if span.SpanContext().IsSampled() {
+		t.Error("regular requests should defer to the base sampler")
+	}
+
+	ctx, err := ContextWithDebugToken(context.Background(), IssueDebugToken("s3cr3t", time.Minute))
+	if err != nil {
+		t.Fatalf("ContextWithDebugToken() error = %v", err)
+	}
+	if !IsDebug(ctx) {
+		t.Fatal("IsDebug() = false, want true")
+	}
+	ctx, span = tracer.Start(ctx, "debug")
+	if !span.SpanContext().IsSampled() {
+		t.Error("debug requests should be sampled")
+	}
+	// Children are sampled as well, even with a parent based sampler downstream
+	if _, child := tracer.Start(ctx, "child"); !trace.SpanFromContext(ctx).SpanContext().IsSampled() || !child.SpanContext().IsSampled() {
+		t.Error("spans of debug requests should be sampled")
+	}
+
+	forged, _ := ContextWithDebugToken(context.Background(), IssueDebugToken("guess", time.Minute))
+	if _, span := tracer.Start(forged, "forged"); span.SpanContext().IsSampled() {
+		t.Error("forged tokens should be ignored")
+	}
+}
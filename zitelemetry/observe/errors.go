@@ -0,0 +1,123 @@
+package observe
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DefaultMaxStackFrames is the default number of frames kept in error
+	// stack traces.
+	DefaultMaxStackFrames = 32
+	// DefaultMaxErrorChain is the default number of errors kept in error
+	// chains.
+	DefaultMaxErrorChain = 10
+)
+
+// Attribute keys of the error details recorded on exception events.
+const (
+	ExceptionStacktraceKey = attribute.Key("exception.stacktrace")
+	ExceptionChainKey      = attribute.Key("exception.chain")
+)
+
+var errorDetails atomic.Pointer[ErrorDetailsConfig]
+
+// SetErrorDetails configures the details attached by RecordError. It is called
+// by New with the tracing configuration.
+func SetErrorDetails(config ErrorDetailsConfig) {
+	if config.MaxStackFrames <= 0 {
+		config.MaxStackFrames = DefaultMaxStackFrames
+	}
+	if config.MaxErrorChain <= 0 {
+		config.MaxErrorChain = DefaultMaxErrorChain
+	}
+	errorDetails.Store(&config)
+}
+
+// RecordError records err on span as an exception event, and sets the span
+// status to error. When error details are enabled, the event also carries a
+// trimmed stack trace of the caller and the chain of wrapped errors.
+func RecordError(span trace.Span, err error, options ...trace.EventOption) {
+	recordError(span, err, 2, true, options...)
+}
+
+// RecordPanic records a recovered panic value on span, see RecordError. It
+// must be called from the deferred function recovering the panic, so the
+// stack trace points at the panicking code.
+func RecordPanic(span trace.Span, recovered any, options ...trace.EventOption) {
+	err, ok := recovered.(error)
+	if !ok {
+		err = fmt.Errorf("%v", recovered)
+	}
+	recordError(span, fmt.Errorf("panic: %w", err), 2, true, options...)
+}
+
+// recordError records err on span, skip is the number of frames to skip in the
+// stack trace, recordError included.
+func recordError(span trace.Span, err error, skip int, setStatus bool, options ...trace.EventOption) {
+	if err == nil || !span.IsRecording() {
+		return
+	}
+	if config := errorDetails.Load(); config != nil && config.Enabled {
+		options = append(options, trace.WithAttributes(
+			ExceptionStacktraceKey.String(stackTrace(skip+1, config.MaxStackFrames)),
+			ExceptionChainKey.StringSlice(errorChain(err, config.MaxErrorChain)),
+		))
+	}
+	span.RecordError(err, options...)
+	if setStatus {
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// stackTrace formats up to max frames of the calling goroutine, skipping the
+// Go runtime frames.
+func stackTrace(skip, max int) string {
+	pcs := make([]uintptr, max+16)
+	n := runtime.Callers(skip+1, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var b strings.Builder
+	kept := 0
+	for kept < max {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+			kept++
+		}
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+// errorChain lists err and the errors it wraps, depth first, as "type:
+// message" entries.
+func errorChain(err error, max int) []string {
+	var chain []string
+	var walk func(err error)
+	walk = func(err error) {
+		if err == nil || len(chain) == max {
+			return
+		}
+		chain = append(chain, fmt.Sprintf("%T: %s", err, err.Error()))
+		switch e := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, inner := range e.Unwrap() {
+				walk(inner)
+			}
+		default:
+			walk(errors.Unwrap(err))
+		}
+	}
+	walk(err)
+	return chain
+}
@@ -0,0 +1,90 @@
+package observe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRecordError(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	record := func(f func(span *TelemetrySpan)) sdktrace.ReadOnlySpan {
+		_, span := tracer.Start(context.Background(), "op")
+		f(NewTelemetrySpan(span))
+		span.End()
+		spans := recorder.Ended()
+		return spans[len(spans)-1]
+	}
+	eventAttr := func(s sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+		for _, kv := range s.Events()[0].Attributes {
+			if kv.Key == key {
+				return kv.Value, true
+			}
+		}
+		return attribute.Value{}, false
+	}
+
+	err := fmt.Errorf("load user: %w", errors.Join(errors.New("not found"), errors.New("timeout")))
+
+	t.Run("disabled", func(t *testing.T) {
+		SetErrorDetails(ErrorDetailsConfig{})
+		s := record(func(span *TelemetrySpan) { span.RecordError(err) })
+		if _, ok := eventAttr(s, ExceptionStacktraceKey); ok {
+			t.Error("stack trace should not be recorded when disabled")
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		SetErrorDetails(ErrorDetailsConfig{Enabled: true, MaxStackFrames: 2})
+		defer SetErrorDetails(ErrorDetailsConfig{})
+
+		s := record(func(span *TelemetrySpan) { RecordError(span.span, err) })
+		if s.Status().Code != codes.Error {
+			t.Errorf("status = %v, want error", s.Status().Code)
+		}
+
+		stack, _ := eventAttr(s, ExceptionStacktraceKey)
+		lines := strings.Split(strings.TrimSpace(stack.AsString()), "\n")
+		if len(lines) != 4 || !strings.Contains(lines[0], "TestRecordError") {
+			t.Errorf("stack trace should start at the caller and hold 2 frames, got:\n%s", stack.AsString())
+		}
+
+		chain, _ := eventAttr(s, ExceptionChainKey)
+		want := []string{
+			"*fmt.wrapError: load user: not found\ntimeout",
+			"*errors.joinError: not found\ntimeout",
+			"*errors.errorString: not found",
+			"*errors.errorString: timeout",
+		}
+		if got := chain.AsStringSlice(); strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Errorf("chain = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		SetErrorDetails(ErrorDetailsConfig{Enabled: true})
+		defer SetErrorDetails(ErrorDetailsConfig{})
+
+		s := record(func(span *TelemetrySpan) {
+			defer func() {
+				RecordPanic(span.span, recover())
+			}()
+			panic("boom")
+		})
+		if s.Status().Description != "panic: boom" {
+			t.Errorf("status = %q, want %q", s.Status().Description, "panic: boom")
+		}
+		if stack, _ := eventAttr(s, ExceptionStacktraceKey); !strings.Contains(stack.AsString(), "TestRecordError") {
+			t.Errorf("stack trace should include the panicking function, got:\n%s", stack.AsString())
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package observefx
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/fx/fxevent"
+)
+
+// BootTracer is an fxevent.Logger tracing the application startup: an
+// "app.start" span, with a child span per invoke, per constructor or
+// decorator run by it, and per OnStart hook. Spans are created once the app
+// started, with the tracer provider set up by then, and exported on its next
+// flush.
+//
+// fx doesn't time constructors, their spans go from the previous event of
+// the invoke to the end of their run, i.e. excluding their dependencies.
+type BootTracer struct {
+	// Logger is the wrapped logger, events are forwarded to it when not nil.
+	Logger fxevent.Logger
+
+	mu      sync.Mutex
+	started time.Time
+	last    time.Time
+	spans   []bootSpan
+	invokes []int
+	done    bool
+}
+
+var _ fxevent.Logger = (*BootTracer)(nil)
+
+// bootSpan is a span recorded until the app starts. parent is the index of
+// the parent span, or -1 for the root span.
+type bootSpan struct {
+	name       string
+	parent     int
+	start, end time.Time
+	attrs      []attribute.KeyValue
+	err        error
+}
+
+func (b *BootTracer) LogEvent(event fxevent.Event) {
+	if b.Logger != nil {
+		b.Logger.LogEvent(event)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.done {
+		return
+	}
+	now := time.Now()
+	if b.started.IsZero() {
+		b.started = now
+	}
+	if b.last.IsZero() {
+		b.last = now
+	}
+
+	switch e := event.(type) {
+	case *fxevent.Invoking:
+		b.spans = append(b.spans, bootSpan{
+			name:   e.FunctionName,
+			parent: b.parent(),
+			start:  now,
+			attrs:  []attribute.KeyValue{attribute.String("fx.kind", "invoke"), attribute.String("fx.module", e.ModuleName)},
+		})
+		b.invokes = append(b.invokes, len(b.spans)-1)
+	case *fxevent.Run:
+		b.spans = append(b.spans, bootSpan{
+			name:   e.Name,
+			parent: b.parent(),
+			start:  b.last,
+			end:    now,
+			attrs:  []attribute.KeyValue{attribute.String("fx.kind", e.Kind), attribute.String("fx.module", e.ModuleName)},
+			err:    e.Err,
+		})
+	case *fxevent.Invoked:
+		if n := len(b.invokes); n > 0 {
+			s := &b.spans[b.invokes[n-1]]
+			s.end, s.err = now, e.Err
+			b.invokes = b.invokes[:n-1]
+		}
+	case *fxevent.OnStartExecuted:
+		b.spans = append(b.spans, bootSpan{
+			name:   e.FunctionName,
+			parent: -1,
+			start:  now.Add(-e.Runtime),
+			end:    now,
+			attrs:  []attribute.KeyValue{attribute.String("fx.kind", "on_start"), attribute.String("fx.caller", e.CallerName)},
+			err:    e.Err,
+		})
+	case *fxevent.Started:
+		b.done = true
+		b.export(now, e.Err)
+		b.spans = nil
+	}
+	b.last = now
+}
+
+// parent returns the index of the invoke running, or -1.
+func (b *BootTracer) parent() int {
+	if n := len(b.invokes); n > 0 {
+		return b.invokes[n-1]
+	}
+	return -1
+}
+
+// export creates the recorded spans, with their recorded timestamps.
+func (b *BootTracer) export(end time.Time, err error) {
+	tracer := otel.Tracer("lumos/boot")
+	rootCtx, root := tracer.Start(context.Background(), "app.start", trace.WithNewRoot(), trace.WithTimestamp(b.started))
+	contexts := make([]context.Context, len(b.spans))
+	for i, s := range b.spans {
+		ctx := rootCtx
+		if s.parent >= 0 {
+			ctx = contexts[s.parent]
+		}
+		var span trace.Span
+		contexts[i], span = tracer.Start(ctx, s.name, trace.WithTimestamp(s.start), trace.WithAttributes(s.attrs...))
+		if s.err != nil {
+			observe.RecordError(span, s.err)
+		}
+		if s.end.IsZero() {
+			// Invokes interrupted by a failure
+			s.end = end
+		}
+		span.End(trace.WithTimestamp(s.end))
+	}
+	if err != nil {
+		observe.RecordError(root, err)
+	}
+	root.End(trace.WithTimestamp(end))
+}
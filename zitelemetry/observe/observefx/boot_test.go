@@ -0,0 +1,84 @@
+package observefx
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
+	"go.uber.org/fx/fxtest"
+)
+
+type slowDependency struct{}
+
+func newSlowDependency() slowDependency {
+	time.Sleep(5 * time.Millisecond)
+	return slowDependency{}
+}
+
+func startServer(lc fx.Lifecycle, _ slowDependency) {
+	lc.Append(fx.StartHook(func(context.Context) error {
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	}))
+}
+
+func TestBootTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	app := fxtest.New(t,
+		fx.WithLogger(func() fxevent.Logger { return &BootTracer{} }),
+		fx.Provide(newSlowDependency),
+		fx.Invoke(startServer),
+	)
+	app.RequireStart().RequireStop()
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		name := s.Name()
+		if i := strings.LastIndex(name, "."); i >= 0 && s.Name() != "app.start" {
+			name = name[i+1:]
+		}
+		spans[name] = s
+	}
+	root, ok := spans["app.start"]
+	if !ok {
+		t.Fatalf("no app.start span in %v", spans)
+	}
+	invoke, ok := spans["startServer()"]
+	if !ok {
+		t.Fatalf("no invoke span in %v", spans)
+	}
+	constructor, ok := spans["newSlowDependency()"]
+	if !ok {
+		t.Fatalf("no constructor span in %v", spans)
+	}
+	hook, ok := spans["func1()"]
+	if !ok {
+		t.Fatalf("no OnStart hook span in %v", spans)
+	}
+
+	if invoke.Parent().SpanID() != root.SpanContext().SpanID() || hook.Parent().SpanID() != root.SpanContext().SpanID() {
+		t.Error("invokes and hooks are not children of app.start")
+	}
+	if constructor.Parent().SpanID() != invoke.SpanContext().SpanID() {
+		t.Error("constructor is not a child of the invoke requiring it")
+	}
+	if d := constructor.EndTime().Sub(constructor.StartTime()); d < 5*time.Millisecond {
+		t.Errorf("constructor span lasted %s, want at least 5ms", d)
+	}
+	if d := hook.EndTime().Sub(hook.StartTime()); d < 5*time.Millisecond {
+		t.Errorf("hook span lasted %s, want at least 5ms", d)
+	}
+	if root.StartTime().After(constructor.StartTime()) || root.EndTime().Before(hook.EndTime()) {
+		t.Error("app.start does not cover the startup")
+	}
+}
@@ -13,17 +13,23 @@ import (
 // Module provides OpenTelemetry observability components
 var Module = fx.Module("observe",
 	fx.Provide(
+		provideConfig,
 		provideTelemetry,
 		provideTracer,
 	),
 	fx.Invoke(registerShutdown),
 )
 
+// provideConfig provides the telemetry config, so that it can be decorated
+func provideConfig(config ziconf.Config) observe.Config {
+	return config.GetTelemetry()
+}
+
 // provideTelemetry creates a Telemetry instance
-func provideTelemetry(lc fx.Lifecycle, config ziconf.Config) *observe.Telemetry {
+func provideTelemetry(lc fx.Lifecycle, config observe.Config) *observe.Telemetry {
 	ctx := context.Background()
 
-	tel, err := observe.New(ctx, config.GetTelemetry())
+	tel, err := observe.New(ctx, config)
 	if err != nil {
 		panic(err)
 	}
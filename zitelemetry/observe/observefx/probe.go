@@ -0,0 +1,53 @@
+package observefx
+
+import (
+	"context"
+	"strings"
+
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/divikraf/lumos/zin/health"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/rs/zerolog"
+	"go.uber.org/fx"
+)
+
+// SelfProbe runs the self-probe when enabled by the telemetry config, see
+// observe.SelfProbe. The internal health is the one of the health checks,
+// when provided.
+var SelfProbe = fx.Invoke(startSelfProbe)
+
+type selfProbeParams struct {
+	fx.In
+	LC        fx.Lifecycle
+	Config    observe.Config
+	AppConfig ziconf.Config
+	Logger    *zerolog.Logger
+	Health    *health.Health `optional:"true"`
+}
+
+func startSelfProbe(params selfProbeParams) {
+	config := params.Config.SelfProbe
+	if !config.Enabled {
+		return
+	}
+	if config.URL == "" {
+		port := params.AppConfig.GetHttpPort()
+		if !strings.Contains(port, ":") {
+			port = ":" + port
+		}
+		config.URL = "http://localhost" + port + health.LivePath
+	}
+	var healthy func(ctx context.Context) bool
+	if params.Health != nil {
+		healthy = func(ctx context.Context) bool {
+			return !params.Health.Draining() && params.Health.Check(ctx).Status == health.StatusUp
+		}
+	}
+
+	probe := observe.NewSelfProbe(config, nil, healthy, params.Logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	params.LC.Append(fx.StartStopHook(
+		func() { go probe.Run(ctx) },
+		cancel,
+	))
+}
@@ -0,0 +1,193 @@
+package observe
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SelfProbeConfig configures the self-probe.
+type SelfProbeConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// URL is the endpoint probed, e.g. "https://localhost:8443/health/live"
+	// (default: the liveness endpoint of the HTTP port).
+	URL string `json:"url" yaml:"url"`
+	// Interval is the interval between probes (default: 30s)
+	Interval time.Duration `json:"interval" yaml:"interval"`
+	// Timeout is the maximum duration of a probe (default: 5s)
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+}
+
+// Reasons of failed probes, see SelfProbe.
+const (
+	ProbeReasonTLS            = "tls"
+	ProbeReasonRefused        = "connection_refused"
+	ProbeReasonPortExhaustion = "port_exhaustion"
+	ProbeReasonTimeout        = "timeout"
+	ProbeReasonStatus         = "status"
+	ProbeReasonOther          = "other"
+)
+
+// SelfProbe periodically calls an endpoint of the service from inside the
+// process, through the network stack like an external client, to catch what
+// internal health checks can't see: a misconfigured TLS certificate, an
+// exhausted port range, a listener down.
+//
+// Every probe is traced in a new root span, propagated to the service, and
+// timed in self_probe_duration_ms by result and reason. self_probe_up is 1
+// while the service is reachable. A probe failing while the service reports
+// itself healthy is a discrepancy: it is logged, recorded on the span, and
+// counted in self_probe_discrepancies_total by reason.
+type SelfProbe struct {
+	config  SelfProbeConfig
+	client  *http.Client
+	healthy func(ctx context.Context) bool
+	logger  *zerolog.Logger
+
+	duration      revelio.DurationRecorder
+	up            metric.Int64Gauge
+	discrepancies metric.Int64Counter
+}
+
+// NewSelfProbe returns a SelfProbe of config. healthy reports the internal
+// health of the service, the service is assumed healthy when nil. client is
+// the client of the probes, http.DefaultClient when nil.
+func NewSelfProbe(config SelfProbeConfig, client *http.Client, healthy func(ctx context.Context) bool, logger *zerolog.Logger) *SelfProbe {
+	if config.Interval <= 0 {
+		config.Interval = 30 * time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if healthy == nil {
+		healthy = func(context.Context) bool { return true }
+	}
+	return &SelfProbe{
+		config:   config,
+		client:   client,
+		healthy:  healthy,
+		logger:   logger,
+		duration: revelio.MustDuration("self_probe_duration_ms", "Duration of the self-probes in milliseconds, by result and reason"),
+		up: revelio.MustInt64Gauge(
+			"self_probe_up",
+			"Whether the last self-probe reached the service, 1 if so",
+		),
+		discrepancies: revelio.MustInt64Counter(
+			"self_probe_discrepancies_total",
+			"Number of self-probes failing while the service reports itself healthy, by reason",
+		),
+	}
+}
+
+// Probe probes the endpoint once, returning the reason of the failure, empty
+// on success.
+func (p *SelfProbe) Probe(ctx context.Context) string {
+	ctx, cancel := context.WithTimeout(ctx, p.config.Timeout)
+	defer cancel()
+	ctx, span := otel.Tracer("lumos/selfprobe").Start(ctx, "self_probe",
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("url.full", p.config.URL)),
+	)
+	defer span.End()
+
+	start := time.Now()
+	reason, err := p.call(ctx)
+	d := time.Since(start)
+
+	result := "success"
+	if reason != "" {
+		result = "failure"
+		RecordError(span, err)
+		span.SetAttributes(attribute.String("self_probe.reason", reason))
+	}
+	p.duration.Record(ctx, d, attribute.String("result", result), attribute.String("reason", reason))
+	if reason == "" {
+		p.up.Record(ctx, 1)
+		return ""
+	}
+	p.up.Record(ctx, 0)
+
+	if p.healthy(ctx) {
+		span.AddEvent("discrepancy")
+		p.discrepancies.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", reason)))
+		p.logger.Error().Err(err).
+			Str("url", p.config.URL).
+			Str("reason", reason).
+			Dur("duration", d).
+			Msg("service unreachable while reporting itself healthy")
+	}
+	return reason
+}
+
+func (p *SelfProbe) call(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.URL, nil)
+	if err != nil {
+		return ProbeReasonOther, err
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return probeReason(err), err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return ProbeReasonStatus, fmt.Errorf("observe: self-probe status %d", resp.StatusCode)
+	}
+	return "", nil
+}
+
+// probeReason classifies the error of a probe request.
+func probeReason(err error) string {
+	var (
+		certErr      *tls.CertificateVerificationError
+		unknownAuth  x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidCert  x509.CertificateInvalidError
+		recordHeader tls.RecordHeaderError
+		netErr       net.Error
+	)
+	switch {
+	case errors.As(err, &certErr), errors.As(err, &unknownAuth), errors.As(err, &hostnameErr),
+		errors.As(err, &invalidCert), errors.As(err, &recordHeader):
+		return ProbeReasonTLS
+	case errors.Is(err, syscall.EADDRNOTAVAIL):
+		return ProbeReasonPortExhaustion
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ProbeReasonRefused
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ProbeReasonTimeout
+	}
+	return ProbeReasonOther
+}
+
+// Run probes every interval until ctx is done.
+func (p *SelfProbe) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.Probe(ctx)
+		}
+	}
+}
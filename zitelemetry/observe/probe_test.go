@@ -0,0 +1,55 @@
+package observe
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestSelfProbe(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	logger := zerolog.Nop()
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	probe := NewSelfProbe(SelfProbeConfig{URL: srv.URL}, nil, nil, &logger)
+	if reason := probe.Probe(ctx); reason != "" {
+		t.Errorf("Probe = %q, want success", reason)
+	}
+
+	status = http.StatusServiceUnavailable
+	if reason := probe.Probe(ctx); reason != ProbeReasonStatus {
+		t.Errorf("Probe = %q, want %q", reason, ProbeReasonStatus)
+	}
+	reveliotest.AssertCounterValue(t, s, "self_probe_discrepancies_total", 1, attribute.String("reason", ProbeReasonStatus))
+
+	unhealthy := NewSelfProbe(SelfProbeConfig{URL: srv.URL}, nil, func(context.Context) bool { return false }, &logger)
+	unhealthy.Probe(ctx)
+	reveliotest.AssertCounterValue(t, s, "self_probe_discrepancies_total", 1, attribute.String("reason", ProbeReasonStatus))
+}
+
+func TestSelfProbeReasons(t *testing.T) {
+	logger := zerolog.Nop()
+	ctx := context.Background()
+
+	tlsSrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsSrv.Close()
+	if reason := NewSelfProbe(SelfProbeConfig{URL: tlsSrv.URL}, nil, nil, &logger).Probe(ctx); reason != ProbeReasonTLS {
+		t.Errorf("Probe of an untrusted certificate = %q, want %q", reason, ProbeReasonTLS)
+	}
+
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closed.Close()
+	if reason := NewSelfProbe(SelfProbeConfig{URL: closed.URL}, nil, nil, &logger).Probe(ctx); reason != ProbeReasonRefused {
+		t.Errorf("Probe of a closed listener = %q, want %q", reason, ProbeReasonRefused)
+	}
+}
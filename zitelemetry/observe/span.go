@@ -61,9 +61,10 @@ func (s *TelemetrySpan) IsRecording() bool {
 	return s.span.IsRecording()
 }
 
-// RecordError records an error in the span
+// RecordError records an error in the span, with a stack trace and the error
+// chain when error details are enabled
 func (s *TelemetrySpan) RecordError(err error, options ...trace.EventOption) {
-	s.span.RecordError(err, options...)
+	recordError(s.span, err, 2, false, options...)
 }
 
 // SpanContext returns the span context
@@ -18,6 +18,7 @@ import (
 	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
 	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
@@ -60,6 +61,10 @@ func (t *Telemetry) init(ctx context.Context) error {
 	// Set up propagator
 	t.setupPropagator()
 
+	// Configure the recorded error details and debug tokens
+	SetErrorDetails(t.config.Tracing.ErrorDetails)
+	SetDebug(t.config.Tracing.Debug)
+
 	// Set up tracing if enabled
 	if t.config.Tracing.Enabled {
 		if err := t.setupTracing(ctx, res); err != nil {
@@ -115,8 +120,11 @@ func (t *Telemetry) setupTracing(ctx context.Context, res *resource.Resource) er
 		return fmt.Errorf("failed to create trace exporter: %w", err)
 	}
 
-	// Create sampler
+	// Create sampler, debug requests are always sampled
 	sampler := t.createSampler()
+	if t.config.Tracing.Debug.Enabled {
+		sampler = NewDebugSampler(sampler)
+	}
 
 	// Create tracer provider options
 	opts := []trace.TracerProviderOption{
@@ -156,10 +164,17 @@ func (t *Telemetry) setupMetrics(ctx context.Context, res *resource.Resource) er
 	}
 
 	// Create meter provider
-	mp := metric.NewMeterProvider(
+	mpOpts := []metric.Option{
 		metric.WithResource(res),
 		metric.WithReader(metric.NewPeriodicReader(exporter, readerOpts...)),
-	)
+	}
+	// Keep the SDK default (OTEL_METRICS_EXEMPLAR_FILTER, or trace based)
+	// unless a filter is configured
+	if filter := t.createExemplarFilter(); filter != nil {
+		mpOpts = append(mpOpts, metric.WithExemplarFilter(filter))
+	}
+
+	mp := metric.NewMeterProvider(mpOpts...)
 	t.shutdownFuncs = append(t.shutdownFuncs, mp.Shutdown)
 	otel.SetMeterProvider(mp)
 
@@ -288,6 +303,20 @@ func (t *Telemetry) createSampler() trace.Sampler {
 	}
 }
 
+// createExemplarFilter creates the exemplar filter based on configuration
+func (t *Telemetry) createExemplarFilter() exemplar.Filter {
+	switch t.config.Metrics.ExemplarFilter {
+	case "always_on":
+		return exemplar.AlwaysOnFilter
+	case "always_off":
+		return exemplar.AlwaysOffFilter
+	case "trace_based":
+		return exemplar.TraceBasedFilter
+	default:
+		return nil
+	}
+}
+
 // startInfraMetrics starts infrastructure metrics collection
 func (t *Telemetry) startInfraMetrics() error {
 	if err := host.Start(); err != nil {
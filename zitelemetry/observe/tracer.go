@@ -3,9 +3,18 @@ package observe
 import (
 	"context"
 
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/trace"
 )
 
+// LibraryTracer returns the tracer of the instrumentation library name, e.g.
+// "github.com/divikraf/lumos/zin", with revelio.LibraryVersion as version,
+// from the global tracer provider. Its metrics counterpart is revelio.Library.
+func LibraryTracer(name string) trace.Tracer {
+	return otel.Tracer(name, trace.WithInstrumentationVersion(revelio.LibraryVersion))
+}
+
 // Tracer interface defines the contract for tracing operations
 type Tracer interface {
 	// Start starts a new span
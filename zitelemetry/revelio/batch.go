@@ -0,0 +1,137 @@
+package revelio
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Batch buffers measurements sharing the same attributes and records them on
+// Flush. Counter increments are summed locally so every counter is only
+// updated once per flush, and the attribute set is computed once for all
+// measurements. Use it in tight loops, e.g. per-row processing, where the cost
+// of recording every measurement directly adds up.
+//
+// A Batch is not safe for concurrent use. It can be reused after Flush.
+type Batch struct {
+	ctx   context.Context
+	attrs []attribute.KeyValue
+
+	int64Sums         []batchEntry[metric.Int64Counter, int64]
+	float64Sums       []batchEntry[metric.Float64Counter, float64]
+	int64UpDownSums   []batchEntry[metric.Int64UpDownCounter, int64]
+	float64UpDownSums []batchEntry[metric.Float64UpDownCounter, float64]
+	int64Values       []batchEntry[metric.Int64Histogram, int64]
+	float64Values     []batchEntry[metric.Float64Histogram, float64]
+}
+
+type batchEntry[I comparable, N int64 | float64] struct {
+	instr I
+	value N
+}
+
+// NewBatch returns a Batch recording with ctx and attrs once flushed.
+func NewBatch(ctx context.Context, attrs ...attribute.KeyValue) *Batch {
+	return &Batch{
+		ctx:   ctx,
+		attrs: attrs,
+	}
+}
+
+// addSum adds n to the entry of instr, creating it when needed
+func addSum[I comparable, N int64 | float64](entries []batchEntry[I, N], instr I, n N) []batchEntry[I, N] {
+	for i := range entries {
+		if entries[i].instr == instr {
+			entries[i].value += n
+			return entries
+		}
+	}
+	return append(entries, batchEntry[I, N]{instr: instr, value: n})
+}
+
+// AddInt64 buffers an increment of counter
+func (b *Batch) AddInt64(counter metric.Int64Counter, incr int64) {
+	b.int64Sums = addSum(b.int64Sums, counter, incr)
+}
+
+// AddFloat64 buffers an increment of counter
+func (b *Batch) AddFloat64(counter metric.Float64Counter, incr float64) {
+	b.float64Sums = addSum(b.float64Sums, counter, incr)
+}
+
+// AddInt64UpDown buffers an increment of counter
+func (b *Batch) AddInt64UpDown(counter metric.Int64UpDownCounter, incr int64) {
+	b.int64UpDownSums = addSum(b.int64UpDownSums, counter, incr)
+}
+
+// AddFloat64UpDown buffers an increment of counter
+func (b *Batch) AddFloat64UpDown(counter metric.Float64UpDownCounter, incr float64) {
+	b.float64UpDownSums = addSum(b.float64UpDownSums, counter, incr)
+}
+
+// RecordInt64 buffers a measurement of histogram
+func (b *Batch) RecordInt64(histogram metric.Int64Histogram, value int64) {
+	b.int64Values = append(b.int64Values, batchEntry[metric.Int64Histogram, int64]{instr: histogram, value: value})
+}
+
+// RecordFloat64 buffers a measurement of histogram
+func (b *Batch) RecordFloat64(histogram metric.Float64Histogram, value float64) {
+	b.float64Values = append(b.float64Values, batchEntry[metric.Float64Histogram, float64]{instr: histogram, value: value})
+}
+
+// RecordDuration buffers a measurement of a duration recorder
+func (b *Batch) RecordDuration(recorder DurationRecorder, duration time.Duration) {
+	dr, ok := recorder.(*durationRecorder)
+	if !ok {
+		// Foreign implementation, the histogram isn't reachable
+		recorder.Record(b.ctx, duration, b.attrs...)
+		return
+	}
+	b.RecordFloat64(dr.histogram, float64(duration.Milliseconds()))
+}
+
+// Len returns the number of buffered entries
+func (b *Batch) Len() int {
+	return len(b.int64Sums) + len(b.float64Sums) +
+		len(b.int64UpDownSums) + len(b.float64UpDownSums) +
+		len(b.int64Values) + len(b.float64Values)
+}
+
+// Flush records every buffered measurement and resets the batch
+func (b *Batch) Flush() {
+	if b.Len() == 0 {
+		return
+	}
+
+	opt := metric.WithAttributeSet(attribute.NewSet(b.attrs...))
+	addOpts := []metric.AddOption{opt}
+	recordOpts := []metric.RecordOption{opt}
+
+	for _, e := range b.int64Sums {
+		e.instr.Add(b.ctx, e.value, addOpts...)
+	}
+	for _, e := range b.float64Sums {
+		e.instr.Add(b.ctx, e.value, addOpts...)
+	}
+	for _, e := range b.int64UpDownSums {
+		e.instr.Add(b.ctx, e.value, addOpts...)
+	}
+	for _, e := range b.float64UpDownSums {
+		e.instr.Add(b.ctx, e.value, addOpts...)
+	}
+	for _, e := range b.int64Values {
+		e.instr.Record(b.ctx, e.value, recordOpts...)
+	}
+	for _, e := range b.float64Values {
+		e.instr.Record(b.ctx, e.value, recordOpts...)
+	}
+
+	b.int64Sums = b.int64Sums[:0]
+	b.float64Sums = b.float64Sums[:0]
+	b.int64UpDownSums = b.int64UpDownSums[:0]
+	b.float64UpDownSums = b.float64UpDownSums[:0]
+	b.int64Values = b.int64Values[:0]
+	b.float64Values = b.float64Values[:0]
+}
@@ -0,0 +1,57 @@
+package revelio_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestBatch(t *testing.T) {
+	s := reveliotest.NewTestScope()
+	rows, err := s.Int64Counter("batch_rows", "Processed rows")
+	if err != nil {
+		t.Fatalf("Failed to create counter: %v", err)
+	}
+	bytes, err := s.Float64Counter("batch_bytes", "Processed bytes")
+	if err != nil {
+		t.Fatalf("Failed to create counter: %v", err)
+	}
+	latency, err := s.Duration("batch_latency", "Row latency")
+	if err != nil {
+		t.Fatalf("Failed to create duration: %v", err)
+	}
+
+	attr := attribute.String("table", "orders")
+	b := s.Batch(context.Background(), attr)
+	for i := 0; i < 10; i++ {
+		b.AddInt64(rows, 1)
+		b.AddFloat64(bytes, 1.5)
+		b.RecordDuration(latency, time.Duration(i)*time.Millisecond)
+	}
+	if b.Len() != 12 {
+		t.Errorf("Len() = %d, want 12", b.Len())
+	}
+
+	if _, ok := s.Metric(t, "batch_rows"); ok {
+		t.Fatal("expected nothing to be recorded before Flush")
+	}
+
+	b.Flush()
+	if b.Len() != 0 {
+		t.Errorf("Len() after Flush = %d, want 0", b.Len())
+	}
+	reveliotest.AssertCounterValue(t, s, "batch_rows", 10, attr)
+
+	h := reveliotest.CollectHistogram(t, s, "batch_latency", attr)
+	if h.Count != 10 || h.Sum != 45 {
+		t.Errorf("histogram count/sum = %d/%v, want 10/45", h.Count, h.Sum)
+	}
+
+	// The batch is reusable after Flush
+	b.AddInt64(rows, 5)
+	b.Flush()
+	reveliotest.AssertCounterValue(t, s, "batch_rows", 15, attr)
+}
@@ -0,0 +1,276 @@
+package revelio
+
+import (
+	"context"
+	"math/rand/v2"
+	"runtime"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	defaultBatchedInterval = time.Second
+	// maxBatchedValues is the number of values a histogram shard buffers per
+	// attribute set before recording them inline
+	maxBatchedValues = 4096
+)
+
+// batchedConfig holds configurable values of the batched instruments
+type batchedConfig struct {
+	interval time.Duration
+}
+
+// BatchedOption is a functional option for [NewBatchedCounter] and
+// [NewBatchedHistogram].
+type BatchedOption func(cfg *batchedConfig)
+
+// WithFlushInterval sets the interval at which the buffered measurements are
+// recorded on the underlying instrument (default: 1s).
+func WithFlushInterval(interval time.Duration) BatchedOption {
+	return func(cfg *batchedConfig) {
+		if interval > 0 {
+			cfg.interval = interval
+		}
+	}
+}
+
+// shardCount returns one shard per P, rounded up to a power of two
+func shardCount() int {
+	n := 1
+	for n < runtime.GOMAXPROCS(0) {
+		n <<= 1
+	}
+	return n
+}
+
+// flusher runs flush every interval until closed.
+type flusher struct {
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+func startFlusher(interval time.Duration, flush func()) *flusher {
+	f := &flusher{stop: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(f.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-f.stop:
+				flush()
+				return
+			case <-ticker.C:
+				flush()
+			}
+		}
+	}()
+	return f
+}
+
+func (f *flusher) close() {
+	f.once.Do(func() { close(f.stop) })
+	<-f.done
+}
+
+type counterEntry struct {
+	set   attribute.Set
+	value int64
+}
+
+// counterShard is padded to its own cache line so shards don't contend
+type counterShard struct {
+	mu      sync.Mutex
+	entries map[attribute.Distinct]*counterEntry
+	_       [48]byte
+}
+
+// BatchedCounter sums increments of an Int64Counter in sharded local buffers
+// and adds the sums to the counter every flush interval, taking the
+// instrument off hot paths such as per-message counters where recording
+// directly shows up in CPU profiles. Measurements are recorded with a
+// background context, so context attributes of the Scope don't apply.
+//
+// A BatchedCounter is safe for concurrent use. Close it to record the
+// remaining increments.
+type BatchedCounter struct {
+	counter metric.Int64Counter
+	shards  []counterShard
+	mask    uint32
+	flusher *flusher
+}
+
+// NewBatchedCounter returns a BatchedCounter of counter.
+func NewBatchedCounter(counter metric.Int64Counter, opts ...BatchedOption) *BatchedCounter {
+	cfg := batchedConfig{interval: defaultBatchedInterval}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	n := shardCount()
+	c := &BatchedCounter{
+		counter: counter,
+		shards:  make([]counterShard, n),
+		mask:    uint32(n - 1),
+	}
+	for i := range c.shards {
+		c.shards[i].entries = map[attribute.Distinct]*counterEntry{}
+	}
+	c.flusher = startFlusher(cfg.interval, c.Flush)
+	return c
+}
+
+// Add buffers an increment of the counter with set. Build set once, e.g. with
+// attribute.NewSet, rather than per call: computing it costs more than the
+// increment.
+func (c *BatchedCounter) Add(incr int64, set attribute.Set) {
+	// rand.Uint32 reads the state of the current M, spreading the callers
+	// over the shards without a shared counter
+	s := &c.shards[rand.Uint32()&c.mask]
+	key := set.Equivalent()
+	s.mu.Lock()
+	if e, ok := s.entries[key]; ok {
+		e.value += incr
+	} else {
+		s.entries[key] = &counterEntry{set: set, value: incr}
+	}
+	s.mu.Unlock()
+}
+
+// Flush adds the buffered sums to the counter.
+func (c *BatchedCounter) Flush() {
+	ctx := context.Background()
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		entries := s.entries
+		if len(entries) > 0 {
+			s.entries = make(map[attribute.Distinct]*counterEntry, len(entries))
+		}
+		s.mu.Unlock()
+		for _, e := range entries {
+			c.counter.Add(ctx, e.value, metric.WithAttributeSet(e.set))
+		}
+	}
+}
+
+// Close stops the periodic flushes and flushes the remaining increments.
+func (c *BatchedCounter) Close() {
+	c.flusher.close()
+}
+
+type histogramEntry struct {
+	set    attribute.Set
+	values []float64
+}
+
+// histogramShard is padded to its own cache line so shards don't contend
+type histogramShard struct {
+	mu      sync.Mutex
+	entries map[attribute.Distinct]*histogramEntry
+	_       [48]byte
+}
+
+// BatchedHistogram buffers measurements of a Float64Histogram in sharded
+// local buffers and records them every flush interval, see BatchedCounter.
+// Values are recorded one by one on flush, so a shard buffering more than
+// 4096 values of an attribute set records them inline.
+//
+// A BatchedHistogram is safe for concurrent use. Close it to record the
+// remaining values.
+type BatchedHistogram struct {
+	histogram metric.Float64Histogram
+	shards    []histogramShard
+	mask      uint32
+	flusher   *flusher
+}
+
+// NewBatchedHistogram returns a BatchedHistogram of histogram.
+func NewBatchedHistogram(histogram metric.Float64Histogram, opts ...BatchedOption) *BatchedHistogram {
+	cfg := batchedConfig{interval: defaultBatchedInterval}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	n := shardCount()
+	h := &BatchedHistogram{
+		histogram: histogram,
+		shards:    make([]histogramShard, n),
+		mask:      uint32(n - 1),
+	}
+	for i := range h.shards {
+		h.shards[i].entries = map[attribute.Distinct]*histogramEntry{}
+	}
+	h.flusher = startFlusher(cfg.interval, h.Flush)
+	return h
+}
+
+// NewBatchedDuration returns a BatchedHistogram of the histogram of recorder,
+// recording milliseconds with RecordDuration. It returns nil for recorders
+// not created by revelio.
+func NewBatchedDuration(recorder DurationRecorder, opts ...BatchedOption) *BatchedHistogram {
+	dr, ok := recorder.(*durationRecorder)
+	if !ok {
+		return nil
+	}
+	return NewBatchedHistogram(dr.histogram, opts...)
+}
+
+// Record buffers a measurement of the histogram with set, see
+// BatchedCounter.Add.
+func (h *BatchedHistogram) Record(value float64, set attribute.Set) {
+	s := &h.shards[rand.Uint32()&h.mask]
+	key := set.Equivalent()
+	s.mu.Lock()
+	e, ok := s.entries[key]
+	if !ok {
+		e = &histogramEntry{set: set}
+		s.entries[key] = e
+	}
+	e.values = append(e.values, value)
+	var full []float64
+	if len(e.values) >= maxBatchedValues {
+		full, e.values = e.values, nil
+	}
+	s.mu.Unlock()
+
+	if full != nil {
+		h.record(context.Background(), full, set)
+	}
+}
+
+// RecordDuration buffers a duration in milliseconds, see NewBatchedDuration.
+func (h *BatchedHistogram) RecordDuration(d time.Duration, set attribute.Set) {
+	h.Record(float64(d.Milliseconds()), set)
+}
+
+func (h *BatchedHistogram) record(ctx context.Context, values []float64, set attribute.Set) {
+	opt := metric.WithAttributeSet(set)
+	for _, v := range values {
+		h.histogram.Record(ctx, v, opt)
+	}
+}
+
+// Flush records the buffered values.
+func (h *BatchedHistogram) Flush() {
+	ctx := context.Background()
+	for i := range h.shards {
+		s := &h.shards[i]
+		s.mu.Lock()
+		entries := s.entries
+		if len(entries) > 0 {
+			s.entries = make(map[attribute.Distinct]*histogramEntry, len(entries))
+		}
+		s.mu.Unlock()
+		for _, e := range entries {
+			h.record(ctx, e.values, e.set)
+		}
+	}
+}
+
+// Close stops the periodic flushes and flushes the remaining values.
+func (h *BatchedHistogram) Close() {
+	h.flusher.close()
+}
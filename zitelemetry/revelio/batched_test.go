@@ -0,0 +1,61 @@
+package revelio_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestBatchedCounter(t *testing.T) {
+	s := reveliotest.NewTestScope()
+	counter, err := s.Int64Counter("batched_messages", "Processed messages")
+	if err != nil {
+		t.Fatalf("Failed to create counter: %v", err)
+	}
+	latency, err := s.Duration("batched_latency", "Message latency")
+	if err != nil {
+		t.Fatalf("Failed to create duration: %v", err)
+	}
+
+	c := revelio.NewBatchedCounter(counter, revelio.WithFlushInterval(time.Hour))
+	h := revelio.NewBatchedDuration(latency, revelio.WithFlushInterval(time.Hour))
+	if h == nil {
+		t.Fatal("NewBatchedDuration returned nil")
+	}
+	attr := attribute.String("topic", "orders")
+	set := attribute.NewSet(attr)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				c.Add(1, set)
+				h.RecordDuration(2*time.Millisecond, set)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, ok := s.Metric(t, "batched_messages"); ok {
+		t.Fatal("expected nothing to be recorded before Flush")
+	}
+
+	c.Flush()
+	reveliotest.AssertCounterValue(t, s, "batched_messages", 8000, attr)
+
+	// Close flushes the remaining measurements
+	c.Add(5, set)
+	c.Close()
+	h.Close()
+	reveliotest.AssertCounterValue(t, s, "batched_messages", 8005, attr)
+	hist := reveliotest.CollectHistogram(t, s, "batched_latency", attr)
+	if hist.Count != 8000 || hist.Sum != 16000 {
+		t.Errorf("histogram count/sum = %d/%v, want 8000/16000", hist.Count, hist.Sum)
+	}
+}
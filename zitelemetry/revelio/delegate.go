@@ -0,0 +1,623 @@
+package revelio
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/embedded"
+)
+
+// defaultGeneration is bumped every time the global default Scope is replaced.
+var defaultGeneration atomic.Uint64
+
+// globalScope follows the global default Scope, see [Global].
+var globalScope = &delegatingScope{
+	observables:   map[rebinder]struct{}{},
+	registrations: map[*delegatingRegistration]struct{}{},
+}
+
+// Global returns a Scope that always delegates to the current global default
+// Scope. Instruments created through it are re-created against the new
+// default whenever [SetDefault] or [SetMeterProvider] is called, so creating
+// instruments before telemetry is initialized does not silently discard
+// measurements.
+//
+// Synchronous instruments are re-created lazily on their next use, while
+// observable instruments and callbacks are re-registered right away.
+func Global() Scope {
+	return globalScope
+}
+
+// SetMeterProvider replaces the global default Scope with one created from
+// the meter provider.
+func SetMeterProvider(mp metric.MeterProvider, opts ...ScopeOption) {
+	SetDefault(newProviderScope(mp, "", opts))
+}
+
+// lazy holds a synchronous instrument that is re-created once the global
+// default Scope changes.
+type lazy[T any] struct {
+	mu      sync.Mutex
+	cur     atomic.Pointer[lazyValue[T]]
+	current func() Scope
+	create  func(Scope) (T, error)
+}
+
+type lazyValue[T any] struct {
+	gen   uint64
+	instr T
+}
+
+func newLazy[T any](current func() Scope, create func(Scope) (T, error)) (*lazy[T], error) {
+	gen := defaultGeneration.Load()
+	instr, err := create(current())
+	if err != nil {
+		return nil, err
+	}
+	l := &lazy[T]{current: current, create: create}
+	l.cur.Store(&lazyValue[T]{gen: gen, instr: instr})
+	return l, nil
+}
+
+func (l *lazy[T]) get() T {
+	gen := defaultGeneration.Load()
+	v := l.cur.Load()
+	if v.gen == gen {
+		return v.instr
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	v = l.cur.Load()
+	if v.gen == gen {
+		return v.instr
+	}
+
+	instr, err := l.create(l.current())
+	if err != nil {
+		otel.Handle(err)
+		instr = v.instr
+	}
+	l.cur.Store(&lazyValue[T]{gen: gen, instr: instr})
+	return instr
+}
+
+// observable holds an observable instrument that is re-created once the
+// global default Scope changes.
+type observable[T metric.Observable] struct {
+	mu     sync.RWMutex
+	cur    T
+	create func(Scope) (T, error)
+}
+
+func newObservable[T metric.Observable](s Scope, create func(Scope) (T, error)) (*observable[T], error) {
+	instr, err := create(s)
+	if err != nil {
+		return nil, err
+	}
+	return &observable[T]{cur: instr, create: create}, nil
+}
+
+func (o *observable[T]) get() T {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.cur
+}
+
+func (o *observable[T]) rebind(s Scope) {
+	instr, err := o.create(s)
+	if err != nil {
+		otel.Handle(err)
+		return
+	}
+	o.mu.Lock()
+	o.cur = instr
+	o.mu.Unlock()
+}
+
+type rebinder interface {
+	rebind(Scope)
+}
+
+// delegatingObservable is implemented by observable instruments created via
+// the delegating scope.
+type delegatingObservable interface {
+	current() metric.Observable
+	observable() rebinder
+}
+
+// delegatingScope is the implementation of Scope returned by Global and
+// Library
+type delegatingScope struct {
+	// library derives the Scope of a library from the default, nil for Global
+	library *library
+
+	mu sync.Mutex
+	// observables are rebound until the last callback registration observing
+	// them is unregistered, so that the instruments of unregistered gauges
+	// don't pile up
+	observables   map[rebinder]struct{}
+	registrations map[*delegatingRegistration]struct{}
+}
+
+// current returns the Scope the instruments are created against.
+func (d *delegatingScope) current() Scope {
+	if d.library == nil {
+		return GetDefault()
+	}
+	return d.library.scope()
+}
+
+// rebind re-creates observable instruments and re-registers callbacks against
+// the current default Scope.
+func (d *delegatingScope) rebind() {
+	s := d.current()
+
+	d.mu.Lock()
+	observables := make([]rebinder, 0, len(d.observables))
+	for o := range d.observables {
+		observables = append(observables, o)
+	}
+	registrations := make([]*delegatingRegistration, 0, len(d.registrations))
+	for r := range d.registrations {
+		registrations = append(registrations, r)
+	}
+	d.mu.Unlock()
+
+	for _, o := range observables {
+		o.rebind(s)
+	}
+	for _, r := range registrations {
+		r.rebind(s)
+	}
+}
+
+func (d *delegatingScope) addObservable(o rebinder) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.observables[o] = struct{}{}
+}
+
+// unregister removes the registration r, and the observables no other
+// registration observes. d.mu must be held.
+func (d *delegatingScope) unregister(r *delegatingRegistration) {
+	delete(d.registrations, r)
+	for _, o := range r.observables() {
+		observed := false
+		for other := range d.registrations {
+			if slices.Contains(other.observables(), o) {
+				observed = true
+				break
+			}
+		}
+		if !observed {
+			delete(d.observables, o)
+		}
+	}
+}
+
+// GetMeter returns the meter of the current default Scope
+func (d *delegatingScope) GetMeter() metric.Meter {
+	return d.current().GetMeter()
+}
+
+// Duration creates a duration recorder (Float64Histogram with ms unit)
+func (d *delegatingScope) Duration(name string, description string, options ...DurationOption) (DurationRecorder, error) {
+	return newDuration(d, name, description, options...)
+}
+
+// ResultCounter creates a counter of operation results
+func (d *delegatingScope) ResultCounter(name string, description string, options ...metric.Int64CounterOption) (ResultCounter, error) {
+	return newResultCounter(d, name, description, options...)
+}
+
+// Batch returns a Batch recording with ctx and attrs once flushed
+func (d *delegatingScope) Batch(ctx context.Context, attrs ...attribute.KeyValue) *Batch {
+	return NewBatch(ctx, attrs...)
+}
+
+// GaugeFunc creates an Int64ObservableGauge reporting the value returned by f
+func (d *delegatingScope) GaugeFunc(name string, description string, f func() int64, options ...metric.Int64ObservableGaugeOption) (metric.Registration, error) {
+	return gaugeFunc(d, name, description, f, options...)
+}
+
+// Float64GaugeFunc creates a Float64ObservableGauge reporting the value
+// returned by f
+func (d *delegatingScope) Float64GaugeFunc(name string, description string, f func() float64, options ...metric.Float64ObservableGaugeOption) (metric.Registration, error) {
+	return float64GaugeFunc(d, name, description, f, options...)
+}
+
+func (d *delegatingScope) Int64Counter(name string, description string, options ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	l, err := newLazy(d.current, func(s Scope) (metric.Int64Counter, error) {
+		return s.Int64Counter(name, description, options...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &delegatingInt64Counter{l: l}, nil
+}
+
+func (d *delegatingScope) Int64UpDownCounter(name string, description string, options ...metric.Int64UpDownCounterOption) (metric.Int64UpDownCounter, error) {
+	l, err := newLazy(d.current, func(s Scope) (metric.Int64UpDownCounter, error) {
+		return s.Int64UpDownCounter(name, description, options...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &delegatingInt64UpDownCounter{l: l}, nil
+}
+
+func (d *delegatingScope) Int64Histogram(name string, description string, options ...metric.Int64HistogramOption) (metric.Int64Histogram, error) {
+	l, err := newLazy(d.current, func(s Scope) (metric.Int64Histogram, error) {
+		return s.Int64Histogram(name, description, options...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &delegatingInt64Histogram{l: l}, nil
+}
+
+func (d *delegatingScope) Int64Gauge(name string, description string, options ...metric.Int64GaugeOption) (metric.Int64Gauge, error) {
+	l, err := newLazy(d.current, func(s Scope) (metric.Int64Gauge, error) {
+		return s.Int64Gauge(name, description, options...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &delegatingInt64Gauge{l: l}, nil
+}
+
+func (d *delegatingScope) Float64Counter(name string, description string, options ...metric.Float64CounterOption) (metric.Float64Counter, error) {
+	l, err := newLazy(d.current, func(s Scope) (metric.Float64Counter, error) {
+		return s.Float64Counter(name, description, options...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &delegatingFloat64Counter{l: l}, nil
+}
+
+func (d *delegatingScope) Float64UpDownCounter(name string, description string, options ...metric.Float64UpDownCounterOption) (metric.Float64UpDownCounter, error) {
+	l, err := newLazy(d.current, func(s Scope) (metric.Float64UpDownCounter, error) {
+		return s.Float64UpDownCounter(name, description, options...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &delegatingFloat64UpDownCounter{l: l}, nil
+}
+
+func (d *delegatingScope) Float64Histogram(name string, description string, options ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
+	l, err := newLazy(d.current, func(s Scope) (metric.Float64Histogram, error) {
+		return s.Float64Histogram(name, description, options...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &delegatingFloat64Histogram{l: l}, nil
+}
+
+func (d *delegatingScope) Float64Gauge(name string, description string, options ...metric.Float64GaugeOption) (metric.Float64Gauge, error) {
+	l, err := newLazy(d.current, func(s Scope) (metric.Float64Gauge, error) {
+		return s.Float64Gauge(name, description, options...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &delegatingFloat64Gauge{l: l}, nil
+}
+
+func (d *delegatingScope) Int64ObservableCounter(name string, description string, options ...metric.Int64ObservableCounterOption) (metric.Int64ObservableCounter, error) {
+	o, err := newObservable(d.current(), func(s Scope) (metric.Int64ObservableCounter, error) {
+		return s.Int64ObservableCounter(name, description, options...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	d.addObservable(o)
+	return &delegatingInt64ObservableCounter{Int64ObservableCounter: o.get(), o: o}, nil
+}
+
+func (d *delegatingScope) Int64ObservableUpDownCounter(name string, description string, options ...metric.Int64ObservableUpDownCounterOption) (metric.Int64ObservableUpDownCounter, error) {
+	o, err := newObservable(d.current(), func(s Scope) (metric.Int64ObservableUpDownCounter, error) {
+		return s.Int64ObservableUpDownCounter(name, description, options...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	d.addObservable(o)
+	return &delegatingInt64ObservableUpDownCounter{Int64ObservableUpDownCounter: o.get(), o: o}, nil
+}
+
+func (d *delegatingScope) Int64ObservableGauge(name string, description string, options ...metric.Int64ObservableGaugeOption) (metric.Int64ObservableGauge, error) {
+	o, err := newObservable(d.current(), func(s Scope) (metric.Int64ObservableGauge, error) {
+		return s.Int64ObservableGauge(name, description, options...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	d.addObservable(o)
+	return &delegatingInt64ObservableGauge{Int64ObservableGauge: o.get(), o: o}, nil
+}
+
+func (d *delegatingScope) Float64ObservableCounter(name string, description string, options ...metric.Float64ObservableCounterOption) (metric.Float64ObservableCounter, error) {
+	o, err := newObservable(d.current(), func(s Scope) (metric.Float64ObservableCounter, error) {
+		return s.Float64ObservableCounter(name, description, options...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	d.addObservable(o)
+	return &delegatingFloat64ObservableCounter{Float64ObservableCounter: o.get(), o: o}, nil
+}
+
+func (d *delegatingScope) Float64ObservableUpDownCounter(name string, description string, options ...metric.Float64ObservableUpDownCounterOption) (metric.Float64ObservableUpDownCounter, error) {
+	o, err := newObservable(d.current(), func(s Scope) (metric.Float64ObservableUpDownCounter, error) {
+		return s.Float64ObservableUpDownCounter(name, description, options...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	d.addObservable(o)
+	return &delegatingFloat64ObservableUpDownCounter{Float64ObservableUpDownCounter: o.get(), o: o}, nil
+}
+
+func (d *delegatingScope) Float64ObservableGauge(name string, description string, options ...metric.Float64ObservableGaugeOption) (metric.Float64ObservableGauge, error) {
+	o, err := newObservable(d.current(), func(s Scope) (metric.Float64ObservableGauge, error) {
+		return s.Float64ObservableGauge(name, description, options...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	d.addObservable(o)
+	return &delegatingFloat64ObservableGauge{Float64ObservableGauge: o.get(), o: o}, nil
+}
+
+func (d *delegatingScope) RegisterCallback(f metric.Callback, instruments ...metric.Observable) (metric.Registration, error) {
+	r := &delegatingRegistration{
+		owner:       d,
+		f:           f,
+		instruments: instruments,
+	}
+	if err := r.register(d.current()); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	d.registrations[r] = struct{}{}
+	// The observables of a previously unregistered callback are rebound again
+	for _, o := range r.observables() {
+		d.observables[o] = struct{}{}
+	}
+	d.mu.Unlock()
+	return r, nil
+}
+
+// delegatingRegistration is a callback registration that is re-registered
+// once the global default Scope changes.
+type delegatingRegistration struct {
+	embedded.Registration
+
+	owner       *delegatingScope
+	f           metric.Callback
+	instruments []metric.Observable
+
+	mu  sync.Mutex
+	reg metric.Registration
+}
+
+// observables returns the delegating observable instruments observed by r.
+func (r *delegatingRegistration) observables() []rebinder {
+	var observables []rebinder
+	for _, instr := range r.instruments {
+		if d, ok := instr.(delegatingObservable); ok {
+			observables = append(observables, d.observable())
+		}
+	}
+	return observables
+}
+
+func (r *delegatingRegistration) register(s Scope) error {
+	instruments := make([]metric.Observable, len(r.instruments))
+	for i, instr := range r.instruments {
+		if d, ok := instr.(delegatingObservable); ok {
+			instr = d.current()
+		}
+		instruments[i] = instr
+	}
+
+	reg, err := s.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		return r.f(ctx, delegatingObserver{Observer: o})
+	}, instruments...)
+	if err != nil {
+		return err
+	}
+	r.reg = reg
+	return nil
+}
+
+func (r *delegatingRegistration) rebind(s Scope) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.reg != nil {
+		if err := r.reg.Unregister(); err != nil {
+			otel.Handle(err)
+		}
+	}
+	if err := r.register(s); err != nil {
+		otel.Handle(err)
+	}
+}
+
+// Unregister removes the callback registration
+func (r *delegatingRegistration) Unregister() error {
+	r.owner.mu.Lock()
+	r.owner.unregister(r)
+	r.owner.mu.Unlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.reg == nil {
+		return nil
+	}
+	err := r.reg.Unregister()
+	r.reg = nil
+	return err
+}
+
+// delegatingObserver translates delegating observable instruments into the
+// instruments of the current default Scope.
+type delegatingObserver struct {
+	metric.Observer
+}
+
+func (o delegatingObserver) ObserveInt64(obsrv metric.Int64Observable, value int64, options ...metric.ObserveOption) {
+	if d, ok := obsrv.(delegatingObservable); ok {
+		obsrv = d.current().(metric.Int64Observable)
+	}
+	o.Observer.ObserveInt64(obsrv, value, options...)
+}
+
+func (o delegatingObserver) ObserveFloat64(obsrv metric.Float64Observable, value float64, options ...metric.ObserveOption) {
+	if d, ok := obsrv.(delegatingObservable); ok {
+		obsrv = d.current().(metric.Float64Observable)
+	}
+	o.Observer.ObserveFloat64(obsrv, value, options...)
+}
+
+// The types below are synchronous instruments delegating to the instrument
+// of the current default Scope.
+
+type delegatingInt64Counter struct {
+	embedded.Int64Counter
+	l *lazy[metric.Int64Counter]
+}
+
+func (c *delegatingInt64Counter) Add(ctx context.Context, incr int64, options ...metric.AddOption) {
+	c.l.get().Add(ctx, incr, options...)
+}
+
+type delegatingInt64UpDownCounter struct {
+	embedded.Int64UpDownCounter
+	l *lazy[metric.Int64UpDownCounter]
+}
+
+func (c *delegatingInt64UpDownCounter) Add(ctx context.Context, incr int64, options ...metric.AddOption) {
+	c.l.get().Add(ctx, incr, options...)
+}
+
+type delegatingInt64Histogram struct {
+	embedded.Int64Histogram
+	l *lazy[metric.Int64Histogram]
+}
+
+func (h *delegatingInt64Histogram) Record(ctx context.Context, incr int64, options ...metric.RecordOption) {
+	h.l.get().Record(ctx, incr, options...)
+}
+
+type delegatingInt64Gauge struct {
+	embedded.Int64Gauge
+	l *lazy[metric.Int64Gauge]
+}
+
+func (g *delegatingInt64Gauge) Record(ctx context.Context, value int64, options ...metric.RecordOption) {
+	g.l.get().Record(ctx, value, options...)
+}
+
+type delegatingFloat64Counter struct {
+	embedded.Float64Counter
+	l *lazy[metric.Float64Counter]
+}
+
+func (c *delegatingFloat64Counter) Add(ctx context.Context, incr float64, options ...metric.AddOption) {
+	c.l.get().Add(ctx, incr, options...)
+}
+
+type delegatingFloat64UpDownCounter struct {
+	embedded.Float64UpDownCounter
+	l *lazy[metric.Float64UpDownCounter]
+}
+
+func (c *delegatingFloat64UpDownCounter) Add(ctx context.Context, incr float64, options ...metric.AddOption) {
+	c.l.get().Add(ctx, incr, options...)
+}
+
+type delegatingFloat64Histogram struct {
+	embedded.Float64Histogram
+	l *lazy[metric.Float64Histogram]
+}
+
+func (h *delegatingFloat64Histogram) Record(ctx context.Context, incr float64, options ...metric.RecordOption) {
+	h.l.get().Record(ctx, incr, options...)
+}
+
+type delegatingFloat64Gauge struct {
+	embedded.Float64Gauge
+	l *lazy[metric.Float64Gauge]
+}
+
+func (g *delegatingFloat64Gauge) Record(ctx context.Context, value float64, options ...metric.RecordOption) {
+	g.l.get().Record(ctx, value, options...)
+}
+
+// The types below are observable instruments delegating to the instrument of
+// the current default Scope. The embedded instrument is the one created
+// first, it only serves to satisfy the instrument interfaces.
+
+type delegatingInt64ObservableCounter struct {
+	metric.Int64ObservableCounter
+	o *observable[metric.Int64ObservableCounter]
+}
+
+func (d *delegatingInt64ObservableCounter) current() metric.Observable { return d.o.get() }
+func (d *delegatingInt64ObservableCounter) observable() rebinder       { return d.o }
+
+type delegatingInt64ObservableUpDownCounter struct {
+	metric.Int64ObservableUpDownCounter
+	o *observable[metric.Int64ObservableUpDownCounter]
+}
+
+func (d *delegatingInt64ObservableUpDownCounter) current() metric.Observable { return d.o.get() }
+func (d *delegatingInt64ObservableUpDownCounter) observable() rebinder       { return d.o }
+
+type delegatingInt64ObservableGauge struct {
+	metric.Int64ObservableGauge
+	o *observable[metric.Int64ObservableGauge]
+}
+
+func (d *delegatingInt64ObservableGauge) current() metric.Observable { return d.o.get() }
+func (d *delegatingInt64ObservableGauge) observable() rebinder       { return d.o }
+
+type delegatingFloat64ObservableCounter struct {
+	metric.Float64ObservableCounter
+	o *observable[metric.Float64ObservableCounter]
+}
+
+func (d *delegatingFloat64ObservableCounter) current() metric.Observable { return d.o.get() }
+func (d *delegatingFloat64ObservableCounter) observable() rebinder       { return d.o }
+
+type delegatingFloat64ObservableUpDownCounter struct {
+	metric.Float64ObservableUpDownCounter
+	o *observable[metric.Float64ObservableUpDownCounter]
+}
+
+func (d *delegatingFloat64ObservableUpDownCounter) current() metric.Observable { return d.o.get() }
+func (d *delegatingFloat64ObservableUpDownCounter) observable() rebinder       { return d.o }
+
+type delegatingFloat64ObservableGauge struct {
+	metric.Float64ObservableGauge
+	o *observable[metric.Float64ObservableGauge]
+}
+
+func (d *delegatingFloat64ObservableGauge) current() metric.Observable { return d.o.get() }
+func (d *delegatingFloat64ObservableGauge) observable() rebinder       { return d.o }
+
+// Compile-time interface compliance check
+var _ Scope = (*delegatingScope)(nil)
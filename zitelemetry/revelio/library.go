@@ -0,0 +1,132 @@
+package revelio
+
+import (
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+// modulePath is the path of the lumos module, whose version is reported as
+// the version of its instrumentation libraries.
+const modulePath = "github.com/divikraf/lumos"
+
+// LibraryVersion is the version of the lumos module the application is built
+// with, e.g. "v1.4.0", or "(devel)" when it is the main module. It is empty
+// when the build info is unavailable.
+var LibraryVersion = readLibraryVersion()
+
+func readLibraryVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	if info.Main.Path == modulePath {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			return dep.Version
+		}
+	}
+	return ""
+}
+
+// NewFromMeterProvider returns a Scope of the meter name of mp. The Scopes
+// returned by [Library] derive their meters from mp while it is the global
+// default Scope.
+func NewFromMeterProvider(mp metric.MeterProvider, name string, opts ...ScopeOption) Scope {
+	return newProviderScope(mp, name, opts)
+}
+
+func newProviderScope(mp metric.MeterProvider, name string, opts []ScopeOption) *scope {
+	cfg := newScopeConfig(opts)
+	s := NewFromMeter(mp.Meter(name, cfg.meterOptions...), opts...).(*scope)
+	s.provider = mp
+	return s
+}
+
+// LibraryScoper is implemented by the Scopes deriving the Scopes of the
+// instrumentation libraries of [Library] while they are the global default.
+type LibraryScoper interface {
+	// LibraryScope returns the Scope of the library name.
+	LibraryScope(name string) Scope
+}
+
+// LibraryScope returns a Scope of the meter name of the meter provider of s,
+// with [LibraryVersion] as version and the options of s. Scopes created from
+// a meter return themselves.
+func (s *scope) LibraryScope(name string) Scope {
+	if s.provider == nil {
+		return s
+	}
+	return NewFromMeter(s.provider.Meter(name, metric.WithInstrumentationVersion(LibraryVersion)), s.opts...)
+}
+
+var (
+	librariesMu sync.Mutex
+	libraries   []*delegatingScope
+)
+
+// Library returns a Scope of the instrumentation library name, e.g.
+// "github.com/divikraf/lumos/zin", following the global default Scope like
+// [Global]. Its instruments are created from a meter of the meter provider of
+// the default, named after the library with [LibraryVersion] as version and
+// with the default attributes of the default, so backends can attribute
+// measurements to the library, see [LibraryScoper]. Defaults created from a
+// meter, e.g. with [NewFromMeter], are used as is.
+func Library(name string) Scope {
+	d := &delegatingScope{
+		library:       &library{name: name},
+		observables:   map[rebinder]struct{}{},
+		registrations: map[*delegatingRegistration]struct{}{},
+	}
+	librariesMu.Lock()
+	libraries = append(libraries, d)
+	librariesMu.Unlock()
+	return d
+}
+
+// rebindLibraries rebinds the Scopes returned by Library to the default.
+func rebindLibraries() {
+	librariesMu.Lock()
+	scopes := append([]*delegatingScope(nil), libraries...)
+	librariesMu.Unlock()
+	for _, d := range scopes {
+		d.rebind()
+	}
+}
+
+// library derives the Scope of an instrumentation library from the global
+// default Scope.
+type library struct {
+	name string
+	mu   sync.Mutex
+	cur  atomic.Pointer[libraryScope]
+}
+
+type libraryScope struct {
+	gen   uint64
+	scope Scope
+}
+
+func (l *library) scope() Scope {
+	gen := defaultGeneration.Load()
+	if v := l.cur.Load(); v != nil && v.gen == gen {
+		return v.scope
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if v := l.cur.Load(); v != nil && v.gen == gen {
+		return v.scope
+	}
+
+	s := GetDefault()
+	if d, ok := s.(LibraryScoper); ok {
+		s = d.LibraryScope(l.name)
+	}
+	l.cur.Store(&libraryScope{gen: gen, scope: s})
+	return s
+}
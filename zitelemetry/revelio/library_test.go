@@ -0,0 +1,96 @@
+package revelio_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+const libraryName = "github.com/divikraf/lumos/revelio-test"
+
+// scopeOf returns the instrumentation scope of the metric name.
+func scopeOf(t *testing.T, rm metricdata.ResourceMetrics, name string) (string, string) {
+	t.Helper()
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return sm.Scope.Name, sm.Scope.Version
+			}
+		}
+	}
+	t.Fatalf("metric %s not recorded", name)
+	return "", ""
+}
+
+func TestLibrary(t *testing.T) {
+	lib := revelio.Extend(revelio.Library(libraryName))
+	counter, err := lib.Int64Counter("library_counter", "counter created before the default is set")
+	if err != nil {
+		t.Fatalf("Failed to create counter: %v", err)
+	}
+	gauge, err := lib.GaugeFunc("library_gauge", "gauge created before the default is set", func() int64 { return 7 })
+	if err != nil {
+		t.Fatalf("Failed to create gauge func: %v", err)
+	}
+	defer gauge.Unregister()
+
+	s := reveliotest.NewDefaultTestScope(t)
+	counter.Add(context.Background(), 2)
+
+	reveliotest.AssertCounterValue(t, s, "library_counter", 2)
+	rm := s.Collect(t)
+	for _, name := range []string{"library_counter", "library_gauge"} {
+		scope, version := scopeOf(t, rm, name)
+		if scope != libraryName || version != revelio.LibraryVersion {
+			t.Errorf("%s scope = %s@%s, want %s@%s", name, scope, version, libraryName, revelio.LibraryVersion)
+		}
+	}
+
+	// A second swap moves everything again
+	next := reveliotest.NewDefaultTestScope(t)
+	counter.Add(context.Background(), 1)
+	reveliotest.AssertCounterValue(t, next, "library_counter", 1)
+	if _, ok := next.Metric(t, "library_gauge"); !ok {
+		t.Fatal("expected gauge to be re-registered on the new default")
+	}
+}
+
+func TestLibraryDefaultAttributes(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	prev := revelio.GetDefault()
+	revelio.SetDefault(revelio.NewFromMeterProvider(provider, "", revelio.WithDefaultAttributes(attribute.String("service", "billing"))))
+	defer revelio.SetDefault(prev)
+
+	counter, err := revelio.Library(libraryName).Int64Counter("library_attributes_counter", "counter")
+	if err != nil {
+		t.Fatalf("Failed to create counter: %v", err)
+	}
+	counter.Add(context.Background(), 1)
+
+	s := &reveliotest.Scope{Reader: reader, Provider: provider}
+	reveliotest.AssertCounterValue(t, s, "library_attributes_counter", 1, attribute.String("service", "billing"))
+}
+
+func TestLibraryMeterDefault(t *testing.T) {
+	base := reveliotest.NewTestScope()
+	prev := revelio.GetDefault()
+	revelio.SetDefault(revelio.NewFromMeter(base.GetMeter()))
+	defer revelio.SetDefault(prev)
+
+	counter, err := revelio.Library(libraryName).Int64Counter("library_meter_counter", "counter")
+	if err != nil {
+		t.Fatalf("Failed to create counter: %v", err)
+	}
+	counter.Add(context.Background(), 1)
+
+	reveliotest.AssertCounterValue(t, base, "library_meter_counter", 1)
+	if scope, _ := scopeOf(t, base.Collect(t), "library_meter_counter"); scope != "reveliotest" {
+		t.Errorf("scope = %s, want the meter of the default", scope)
+	}
+}
@@ -0,0 +1,124 @@
+package revelio
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Messaging attribute keys, from the OpenTelemetry messaging semantic
+// conventions.
+const (
+	MessagingSystemKey        = attribute.Key("messaging.system")
+	MessagingDestinationKey   = attribute.Key("messaging.destination.name")
+	MessagingOperationKey     = attribute.Key("messaging.operation.type")
+	MessagingConsumerGroupKey = attribute.Key("messaging.consumer.group.name")
+	MessagingPartitionKey     = attribute.Key("messaging.destination.partition.id")
+)
+
+// Messaging operation types
+const (
+	MessagingOperationSend    = "send"
+	MessagingOperationReceive = "receive"
+	MessagingOperationProcess = "process"
+)
+
+// MessagingAttributes returns the attributes identifying a messaging system,
+// e.g. "kafka", and a destination, e.g. a topic or a queue name. The consumer
+// group is omitted when empty.
+func MessagingAttributes(system, destination, consumerGroup string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		MessagingSystemKey.String(system),
+		MessagingDestinationKey.String(destination),
+	}
+	if consumerGroup != "" {
+		attrs = append(attrs, MessagingConsumerGroupKey.String(consumerGroup))
+	}
+	return attrs
+}
+
+// MessagingInstruments are the standard instruments of asynchronous
+// processing, shared by the queue, stream and worker packages so their
+// dashboards are uniform across services. Every measurement is expected to
+// carry the MessagingAttributes.
+type MessagingInstruments struct {
+	// Consumed counts the processed messages, by status and error.type:
+	// messaging_messages_consumed_total
+	Consumed ResultCounter
+	// Produced counts the sent messages, by status and error.type:
+	// messaging_messages_produced_total
+	Produced ResultCounter
+	// ProcessDuration records the processing duration of messages:
+	// messaging_process_duration_ms
+	ProcessDuration DurationRecorder
+	// Lag records the time messages waited before being processed:
+	// messaging_queue_lag_seconds
+	Lag metric.Float64Histogram
+	// Retries counts the redelivered messages: messaging_retries_total
+	Retries metric.Int64Counter
+	// DeadLettered counts the messages sent to a dead letter queue:
+	// messaging_dead_lettered_total
+	DeadLettered metric.Int64Counter
+}
+
+// NewMessagingInstruments returns the messaging instruments of s.
+func NewMessagingInstruments(s Scope) (*MessagingInstruments, error) {
+	var (
+		m   MessagingInstruments
+		err error
+	)
+	if m.Consumed, err = newResultCounter(s, "messaging_messages_consumed_total", "Number of processed messages, by status"); err != nil {
+		return nil, err
+	}
+	if m.Produced, err = newResultCounter(s, "messaging_messages_produced_total", "Number of sent messages, by status"); err != nil {
+		return nil, err
+	}
+	if m.ProcessDuration, err = s.Duration("messaging_process_duration_ms", "Duration of message processing in milliseconds"); err != nil {
+		return nil, err
+	}
+	if m.Lag, err = s.Float64Histogram("messaging_queue_lag_seconds", "Time messages waited in the queue before being processed, in seconds",
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 3600),
+	); err != nil {
+		return nil, err
+	}
+	if m.Retries, err = s.Int64Counter("messaging_retries_total", "Number of redelivered messages"); err != nil {
+		return nil, err
+	}
+	if m.DeadLettered, err = s.Int64Counter("messaging_dead_lettered_total", "Number of messages sent to a dead letter queue"); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// MustMessagingInstruments returns the messaging instruments of the Global
+// scope. This function will trigger panic when err is occurred.
+func MustMessagingInstruments() *MessagingInstruments {
+	m, err := NewMessagingInstruments(Global())
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// RecordProcessed records a processed message: its result, processing
+// duration and, unless enqueuedAt is zero, its lag.
+func (m *MessagingInstruments) RecordProcessed(ctx context.Context, enqueuedAt time.Time, duration time.Duration, err error, attrs ...attribute.KeyValue) {
+	m.Consumed.Record(ctx, err, attrs...)
+	m.ProcessDuration.Record(ctx, duration, append(attrs[:len(attrs):len(attrs)], ResultAttributes(err)...)...)
+	if !enqueuedAt.IsZero() {
+		m.Lag.Record(ctx, max(time.Since(enqueuedAt).Seconds(), 0), metric.WithAttributes(attrs...))
+	}
+}
+
+// RecordRetry records a redelivered message.
+func (m *MessagingInstruments) RecordRetry(ctx context.Context, attrs ...attribute.KeyValue) {
+	m.Retries.Add(ctx, 1, metric.WithAttributes(attrs...))
+}
+
+// RecordDeadLettered records a message sent to a dead letter queue.
+func (m *MessagingInstruments) RecordDeadLettered(ctx context.Context, attrs ...attribute.KeyValue) {
+	m.DeadLettered.Add(ctx, 1, metric.WithAttributes(attrs...))
+}
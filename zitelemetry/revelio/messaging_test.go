@@ -0,0 +1,42 @@
+package revelio_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+)
+
+func TestMessagingInstruments(t *testing.T) {
+	s := reveliotest.NewTestScope()
+	m, err := revelio.NewMessagingInstruments(s)
+	if err != nil {
+		t.Fatalf("Failed to create messaging instruments: %v", err)
+	}
+
+	ctx := context.Background()
+	attrs := revelio.MessagingAttributes("kafka", "orders", "billing")
+	m.RecordProcessed(ctx, time.Now().Add(-2*time.Second), 15*time.Millisecond, nil, attrs...)
+	m.RecordProcessed(ctx, time.Time{}, 5*time.Millisecond, errors.New("boom"), attrs...)
+	m.RecordRetry(ctx, attrs...)
+	m.RecordDeadLettered(ctx, attrs...)
+	m.Produced.Success(ctx, revelio.MessagingAttributes("kafka", "invoices", "")...)
+
+	group := revelio.MessagingConsumerGroupKey.String("billing")
+	reveliotest.AssertCounterValue(t, s, "messaging_messages_consumed_total", 1, group, revelio.StatusKey.String(revelio.StatusSuccess))
+	reveliotest.AssertCounterValue(t, s, "messaging_messages_consumed_total", 1, group, revelio.StatusKey.String(revelio.StatusFailure))
+	reveliotest.AssertCounterValue(t, s, "messaging_messages_produced_total", 1, revelio.MessagingDestinationKey.String("invoices"))
+	reveliotest.AssertCounterValue(t, s, "messaging_retries_total", 1, group)
+	reveliotest.AssertCounterValue(t, s, "messaging_dead_lettered_total", 1, group)
+
+	if h := reveliotest.CollectHistogram(t, s, "messaging_process_duration_ms", revelio.StatusKey.String(revelio.StatusFailure)); h.Count != 1 || h.Sum != 5 {
+		t.Errorf("failed process duration count = %d, sum = %v", h.Count, h.Sum)
+	}
+	lag := reveliotest.CollectHistogram(t, s, "messaging_queue_lag_seconds", revelio.MessagingDestinationKey.String("orders"))
+	if lag.Count != 1 || lag.Sum < 2 || lag.Sum > 3 {
+		t.Errorf("lag count = %d, sum = %v", lag.Count, lag.Sum)
+	}
+}
@@ -24,32 +24,66 @@ type scopeHolder struct {
 var globalDefaultScope = initDefaultScope()
 
 func initDefaultScope() *atomic.Value {
-	meter := otel.GetMeterProvider().Meter("")
 	v := &atomic.Value{}
 	v.Store(scopeHolder{
-		scope: NewFromMeter(meter),
+		scope: newProviderScope(otel.GetMeterProvider(), "", nil),
 	})
 	return v
 }
 
-// GetDefault returns the global default Scope.
+// GetDefault returns the global default Scope. Instruments created from it
+// are bound to that Scope, use [Global] for instruments that follow
+// replacements of the default.
 func GetDefault() Scope {
 	return globalDefaultScope.Load().(scopeHolder).scope
 }
 
-// SetDefault replaces the global default Scope.
-func SetDefault(s Scope) {
+// SetDefault replaces the global default Scope. Instruments created through
+// [Global] and the package level functions are re-created against s.
+//
+// The options, e.g. [WithAttributeFilters], are added to those s was created
+// with. Scopes not created by this package, e.g. wrapping a Scope, are
+// replaced by a Scope of their meter with opts only.
+func SetDefault(s Scope, opts ...ScopeOption) {
 	if s == nil {
 		panic(packageName + ": SetDefault: cannot assign nil Meter for global default meter")
 	}
+	if s == Scope(globalScope) {
+		panic(packageName + ": SetDefault: cannot assign the Global scope as global default meter")
+	}
+	if len(opts) > 0 {
+		s = withOptions(s, opts)
+	}
 	globalDefaultScope.Store(scopeHolder{scope: s})
+	defaultGeneration.Add(1)
+	globalScope.rebind()
+	rebindLibraries()
+}
+
+// withOptions returns a Scope of the meter of s, with the options of s and
+// opts.
+func withOptions(s Scope, opts []ScopeOption) Scope {
+	d, ok := s.(*scope)
+	if !ok {
+		return NewFromMeter(s.GetMeter(), opts...)
+	}
+	opts = append(d.opts[:len(d.opts):len(d.opts)], opts...)
+	return &scope{
+		meter:    d.meter,
+		attrs:    newScopeAttributes(newScopeConfig(opts)),
+		provider: d.provider,
+		opts:     opts,
+	}
 }
 
 // NewFromMeter wraps OpenTelemetry's [go.opentelemetry.io/otel/metric.Meter]
 // into our own Scope.
-func NewFromMeter(meter metric.Meter) Scope {
+func NewFromMeter(meter metric.Meter, opts ...ScopeOption) Scope {
+	cfg := newScopeConfig(opts)
 	return &scope{
 		meter: meter,
+		attrs: newScopeAttributes(cfg),
+		opts:  opts,
 	}
 }
 
@@ -76,11 +110,7 @@ func validateScopeName(scopeName string) error {
 //
 // Returns error if name is empty or doesn't conform to the naming spec.
 func New(name string, opts ...metric.MeterOption) (Scope, error) {
-	if err := validateScopeName(name); err != nil {
-		return nil, errors.New(errStrFormatter("New: name must not be empty"))
-	}
-	met := otel.GetMeterProvider().Meter(name, opts...)
-	return NewFromMeter(met), nil
+	return NewWithOptions(name, WithMeterOptions(opts...))
 }
 
 // MustNew is a syntactic sugar for [New].
@@ -93,11 +123,43 @@ func MustNew(name string, opts ...metric.MeterOption) Scope {
 	return scope
 }
 
+// NewWithOptions is [New] configured with Scope options, such as
+// [WithDefaultAttributes]. Meter options are passed with [WithMeterOptions].
+func NewWithOptions(name string, opts ...ScopeOption) (Scope, error) {
+	if err := validateScopeName(name); err != nil {
+		return nil, errors.New(errStrFormatter("New: name must not be empty"))
+	}
+	return newProviderScope(otel.GetMeterProvider(), name, opts), nil
+}
+
+// MustNewWithOptions is a syntactic sugar for [NewWithOptions].
+// This function will trigger panic when err is occurred.
+func MustNewWithOptions(name string, opts ...ScopeOption) Scope {
+	scope, err := NewWithOptions(name, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return scope
+}
+
+// Must returns instr, and panics when err is not nil. It is a syntactic sugar
+// for the instruments of a Scope, e.g. revelio.Must(scope.Int64Counter(...)).
+func Must[T any](instr T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return instr
+}
+
 // Duration is an instrument to record duration thingy, such as process latencies.
 // It's basically a Float64Histogram instrument identified by
 // name, unit of `ms` and configured with additional options.
+//
+// Measurements recorded with a context holding a sampled span carry the trace
+// and span ID as exemplars, as long as the meter provider keeps exemplars (see
+// the exemplar filter of the observe metrics config).
 func Duration(name string, description string, options ...DurationOption) (DurationRecorder, error) {
-	return GetDefault().Duration(name, description, options...)
+	return Global().Duration(name, description, options...)
 }
 
 // MustDuration is a syntactic sugar for [Duration].
@@ -110,11 +172,28 @@ func MustDuration(name string, description string, options ...DurationOption) Du
 	return instr
 }
 
+// NewResultCounter returns a new ResultCounter instrument identified by name.
+// The instrument counts operation results with a normalized `status` and
+// `error.type` attribute, the error message is never used as an attribute.
+func NewResultCounter(name string, description string, options ...metric.Int64CounterOption) (ResultCounter, error) {
+	return globalScope.ResultCounter(name, description, options...)
+}
+
+// MustResultCounter is a syntactic sugar for [NewResultCounter].
+// This function will trigger panic when err is occurred.
+func MustResultCounter(name string, description string, options ...metric.Int64CounterOption) ResultCounter {
+	instr, err := NewResultCounter(name, description, options...)
+	if err != nil {
+		panic(err)
+	}
+	return instr
+}
+
 // Int64Counter returns a new Int64Counter instrument identified by name
 // and configured with options. The instrument is used to synchronously
 // record increasing int64 measurements during a computational operation.
 func Int64Counter(name string, description string, options ...metric.Int64CounterOption) (metric.Int64Counter, error) {
-	return GetDefault().Int64Counter(name, description, options...)
+	return Global().Int64Counter(name, description, options...)
 }
 
 // MustInt64Counter is a syntactic sugar for [Int64Counter].
@@ -132,7 +211,7 @@ func MustInt64Counter(name string, description string, options ...metric.Int64Co
 // to synchronously record int64 measurements during a computational
 // operation.
 func Int64UpDownCounter(name string, description string, options ...metric.Int64UpDownCounterOption) (metric.Int64UpDownCounter, error) {
-	return GetDefault().Int64UpDownCounter(name, description, options...)
+	return Global().Int64UpDownCounter(name, description, options...)
 }
 
 // MustInt64UpDownCounter is a syntactic sugar for [Int64UpDownCounter].
@@ -150,13 +229,13 @@ func MustInt64UpDownCounter(name string, description string, options ...metric.I
 // synchronously record the distribution of int64 measurements during a
 // computational operation.
 func Int64Histogram(name string, description string, options ...metric.Int64HistogramOption) (metric.Int64Histogram, error) {
-	return GetDefault().Int64Histogram(name, description, options...)
+	return Global().Int64Histogram(name, description, options...)
 }
 
 // MustInt64Histogram is a syntactic sugar for [Int64Histogram].
 // This function will trigger panic when err is occurred.
 func MustInt64Histogram(name string, description string, options ...metric.Int64HistogramOption) metric.Int64Histogram {
-	instr, err := GetDefault().Int64Histogram(name, description, options...)
+	instr, err := Global().Int64Histogram(name, description, options...)
 	if err != nil {
 		panic(err)
 	}
@@ -167,7 +246,7 @@ func MustInt64Histogram(name string, description string, options ...metric.Int64
 // configured with options. The instrument is used to synchronously record
 // instantaneous int64 measurements during a computational operation.
 func Int64Gauge(name string, description string, options ...metric.Int64GaugeOption) (metric.Int64Gauge, error) {
-	return GetDefault().Int64Gauge(name, description, options...)
+	return Global().Int64Gauge(name, description, options...)
 }
 
 // MustInt64Gauge is a syntactic sugar for [Int64Gauge].
@@ -190,7 +269,7 @@ func MustInt64Gauge(name string, description string, options ...metric.Int64Gaug
 // RegisterCallback method of this Meter to register one later. See the
 // Measurements section of the package documentation for more information.
 func Int64ObservableCounter(name string, description string, options ...metric.Int64ObservableCounterOption) (metric.Int64ObservableCounter, error) {
-	return GetDefault().Int64ObservableCounter(name, description, options...)
+	return Global().Int64ObservableCounter(name, description, options...)
 }
 
 // MustInt64ObservableCounter is a syntactic sugar for [Int64ObservableCounter].
@@ -213,7 +292,7 @@ func MustInt64ObservableCounter(name string, description string, options ...metr
 // RegisterCallback method of this Meter to register one later. See the
 // Measurements section of the package documentation for more information.
 func Int64ObservableUpDownCounter(name string, description string, options ...metric.Int64ObservableUpDownCounterOption) (metric.Int64ObservableUpDownCounter, error) {
-	return GetDefault().Int64ObservableUpDownCounter(name, description, options...)
+	return Global().Int64ObservableUpDownCounter(name, description, options...)
 }
 
 // MustInt64ObservableUpDownCounter is a syntactic sugar for [Int64ObservableUpDownCounter].
@@ -236,7 +315,7 @@ func MustInt64ObservableUpDownCounter(name string, description string, options .
 // RegisterCallback method of this Meter to register one later. See the
 // Measurements section of the package documentation for more information.
 func Int64ObservableGauge(name string, description string, options ...metric.Int64ObservableGaugeOption) (metric.Int64ObservableGauge, error) {
-	return GetDefault().Int64ObservableGauge(name, description, options...)
+	return Global().Int64ObservableGauge(name, description, options...)
 }
 
 // MustInt64ObservableGauge is a syntactic sugar for [Int64ObservableGauge].
@@ -254,7 +333,7 @@ func MustInt64ObservableGauge(name string, description string, options ...metric
 // synchronously record increasing float64 measurements during a
 // computational operation.
 func Float64Counter(name string, description string, options ...metric.Float64CounterOption) (metric.Float64Counter, error) {
-	return GetDefault().Float64Counter(name, description, options...)
+	return Global().Float64Counter(name, description, options...)
 }
 
 // MustFloat64Counter is a syntactic sugar for [Float64Counter].
@@ -272,7 +351,7 @@ func MustFloat64Counter(name string, description string, options ...metric.Float
 // to synchronously record float64 measurements during a computational
 // operation.
 func Float64UpDownCounter(name string, description string, options ...metric.Float64UpDownCounterOption) (metric.Float64UpDownCounter, error) {
-	return GetDefault().Float64UpDownCounter(name, description, options...)
+	return Global().Float64UpDownCounter(name, description, options...)
 }
 
 // MustFloat64UpDownCounter is a syntactic sugar for [Float64UpDownCounter].
@@ -290,7 +369,7 @@ func MustFloat64UpDownCounter(name string, description string, options ...metric
 // synchronously record the distribution of float64 measurements during a
 // computational operation.
 func Float64Histogram(name string, description string, options ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
-	return GetDefault().Float64Histogram(name, description, options...)
+	return Global().Float64Histogram(name, description, options...)
 }
 
 // MustFloat64Histogram is a syntactic sugar for [Float64Histogram].
@@ -307,7 +386,7 @@ func MustFloat64Histogram(name string, description string, options ...metric.Flo
 // configured with options. The instrument is used to synchronously record
 // instantaneous float64 measurements during a computational operation.
 func Float64Gauge(name string, description string, options ...metric.Float64GaugeOption) (metric.Float64Gauge, error) {
-	return GetDefault().Float64Gauge(name, description, options...)
+	return Global().Float64Gauge(name, description, options...)
 }
 
 // MustFloat64Gauge is a syntactic sugar for [Float64Gauge].
@@ -330,7 +409,7 @@ func MustFloat64Gauge(name string, description string, options ...metric.Float64
 // RegisterCallback method of this Meter to register one later. See the
 // Measurements section of the package documentation for more information.
 func Float64ObservableCounter(name string, description string, options ...metric.Float64ObservableCounterOption) (metric.Float64ObservableCounter, error) {
-	return GetDefault().Float64ObservableCounter(name, description, options...)
+	return Global().Float64ObservableCounter(name, description, options...)
 }
 
 // MustFloat64ObservableCounter is a syntactic sugar for [Float64ObservableCounter].
@@ -353,7 +432,7 @@ func MustFloat64ObservableCounter(name string, description string, options ...me
 // RegisterCallback method of this Meter to register one later. See the
 // Measurements section of the package documentation for more information.
 func Float64ObservableUpDownCounter(name string, description string, options ...metric.Float64ObservableUpDownCounterOption) (metric.Float64ObservableUpDownCounter, error) {
-	return GetDefault().Float64ObservableUpDownCounter(name, description, options...)
+	return Global().Float64ObservableUpDownCounter(name, description, options...)
 }
 
 // MustFloat64ObservableUpDownCounter is a syntactic sugar for [Float64ObservableUpDownCounter].
@@ -376,7 +455,7 @@ func MustFloat64ObservableUpDownCounter(name string, description string, options
 // RegisterCallback method of this Meter to register one later. See the
 // Measurements section of the package documentation for more information.
 func Float64ObservableGauge(name string, description string, options ...metric.Float64ObservableGaugeOption) (metric.Float64ObservableGauge, error) {
-	return GetDefault().Float64ObservableGauge(name, description, options...)
+	return Global().Float64ObservableGauge(name, description, options...)
 }
 
 // MustFloat64ObservableGauge is a syntactic sugar for [Float64ObservableGauge].
@@ -403,5 +482,42 @@ func MustFloat64ObservableGauge(name string, description string, options ...metr
 //
 // The function f needs to be concurrent safe.
 func RegisterCallback(f metric.Callback, instruments ...metric.Observable) (metric.Registration, error) {
-	return GetDefault().RegisterCallback(f, instruments...)
+	return Global().RegisterCallback(f, instruments...)
+}
+
+// GaugeFunc creates an Int64ObservableGauge identified by name that reports
+// the value returned by f once per a measurement collection cycle. It is handy
+// for exposing pool sizes, queue depths, cache sizes, and the like.
+//
+// Call Unregister on the returned Registration to stop reporting.
+func GaugeFunc(name string, description string, f func() int64, options ...metric.Int64ObservableGaugeOption) (metric.Registration, error) {
+	return globalScope.GaugeFunc(name, description, f, options...)
+}
+
+// MustGaugeFunc is a syntactic sugar for [GaugeFunc].
+// This function will trigger panic when err is occurred.
+func MustGaugeFunc(name string, description string, f func() int64, options ...metric.Int64ObservableGaugeOption) metric.Registration {
+	reg, err := GaugeFunc(name, description, f, options...)
+	if err != nil {
+		panic(err)
+	}
+	return reg
+}
+
+// Float64GaugeFunc creates a Float64ObservableGauge identified by name that
+// reports the value returned by f once per a measurement collection cycle.
+//
+// Call Unregister on the returned Registration to stop reporting.
+func Float64GaugeFunc(name string, description string, f func() float64, options ...metric.Float64ObservableGaugeOption) (metric.Registration, error) {
+	return globalScope.Float64GaugeFunc(name, description, f, options...)
+}
+
+// MustFloat64GaugeFunc is a syntactic sugar for [Float64GaugeFunc].
+// This function will trigger panic when err is occurred.
+func MustFloat64GaugeFunc(name string, description string, f func() float64, options ...metric.Float64ObservableGaugeOption) metric.Registration {
+	reg, err := Float64GaugeFunc(name, description, f, options...)
+	if err != nil {
+		panic(err)
+	}
+	return reg
 }
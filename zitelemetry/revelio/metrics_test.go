@@ -137,6 +137,26 @@ func TestScopeManagement(t *testing.T) {
 		}
 	})
 
+	t.Run("New", func(t *testing.T) {
+		scope, err := New("revelio-new-test", metric.WithInstrumentationVersion("1.0.0"))
+		if err != nil || scope == nil {
+			t.Fatalf("New() = %v, %v", scope, err)
+		}
+		if _, err := New(""); err == nil {
+			t.Error("New should reject an empty name")
+		}
+	})
+
+	t.Run("NewWithOptions", func(t *testing.T) {
+		scope := MustNewWithOptions("revelio-new-with-options-test",
+			WithMeterOptions(metric.WithInstrumentationVersion("1.0.0")),
+			WithDefaultAttributes(attribute.String("module", "billing")),
+		)
+		if _, ok := scope.(ExtendedScope); !ok {
+			t.Error("Scope should be an ExtendedScope")
+		}
+	})
+
 	t.Run("NewFromMeter", func(t *testing.T) {
 		meter := otel.GetMeterProvider().Meter("test")
 		scope := NewFromMeter(meter)
@@ -151,3 +171,46 @@ func TestScopeManagement(t *testing.T) {
 		}
 	})
 }
+
+func TestDelegatingScopeUnregister(t *testing.T) {
+	d := Library("github.com/divikraf/lumos/revelio-unregister-test").(*delegatingScope)
+
+	kept, err := d.GaugeFunc("kept_gauge", "gauge kept registered", func() int64 { return 1 })
+	if err != nil {
+		t.Fatalf("Failed to create gauge func: %v", err)
+	}
+	defer kept.Unregister()
+	for range 10 {
+		reg, err := d.GaugeFunc("dropped_gauge", "gauge unregistered right away", func() int64 { return 2 })
+		if err != nil {
+			t.Fatalf("Failed to create gauge func: %v", err)
+		}
+		if err := reg.Unregister(); err != nil {
+			t.Fatalf("Failed to unregister: %v", err)
+		}
+	}
+
+	d.mu.Lock()
+	observables, registrations := len(d.observables), len(d.registrations)
+	d.mu.Unlock()
+	if observables != 1 || registrations != 1 {
+		t.Errorf("%d observables and %d registrations left, want the kept gauge only", observables, registrations)
+	}
+
+	// An observable stays as long as a registration observes it
+	counter, err := d.Int64ObservableCounter("shared_counter", "counter observed twice")
+	if err != nil {
+		t.Fatalf("Failed to create counter: %v", err)
+	}
+	observe := func(ctx context.Context, o metric.Observer) error { return nil }
+	first, _ := d.RegisterCallback(observe, counter)
+	second, _ := d.RegisterCallback(observe, counter)
+	first.Unregister()
+	if _, ok := d.observables[counter.(delegatingObservable).observable()]; !ok {
+		t.Error("observable dropped while still observed")
+	}
+	second.Unregister()
+	if _, ok := d.observables[counter.(delegatingObservable).observable()]; ok {
+		t.Error("observable kept once no longer observed")
+	}
+}
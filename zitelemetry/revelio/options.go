@@ -0,0 +1,332 @@
+package revelio
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// scopeConfig holds configurable values for a Scope
+type scopeConfig struct {
+	meterOptions      []metric.MeterOption
+	defaultAttributes []attribute.KeyValue
+	contextExtractors []ContextAttributeExtractor
+	attributeFilters  []AttributeFilter
+}
+
+// ScopeOption is a functional option for [NewWithOptions] and [NewFromMeter].
+type ScopeOption func(cfg *scopeConfig)
+
+// WithMeterOptions sets the options used to create the underlying meter. It
+// only takes effect in [NewWithOptions].
+func WithMeterOptions(opts ...metric.MeterOption) ScopeOption {
+	return func(cfg *scopeConfig) {
+		cfg.meterOptions = append(cfg.meterOptions, opts...)
+	}
+}
+
+// WithDefaultAttributes sets attributes merged into every measurement made
+// through the Scope, such as module name, component, or tenant. Attributes
+// given at the call site take precedence over the defaults.
+//
+// The defaults are applied to synchronous instruments and to callbacks
+// registered with RegisterCallback, GaugeFunc, or Float64GaugeFunc. Callbacks
+// passed as instrument options (e.g. metric.WithInt64Callback) are not
+// covered.
+func WithDefaultAttributes(kv ...attribute.KeyValue) ScopeOption {
+	return func(cfg *scopeConfig) {
+		cfg.defaultAttributes = append(cfg.defaultAttributes, kv...)
+	}
+}
+
+// ContextAttributeExtractor derives attributes from the context a measurement
+// is recorded with, e.g. tenant, route, or locale.
+type ContextAttributeExtractor func(ctx context.Context) []attribute.KeyValue
+
+// WithContextAttributes sets extractors whose attributes are merged into every
+// measurement of synchronous instruments made through the Scope, so business
+// dimensions appear on metrics without touching every call site. Extracted
+// attributes take precedence over the defaults, attributes given at the call
+// site take precedence over both.
+//
+// Keep the extracted values low-cardinality, every distinct value creates a
+// new time series.
+func WithContextAttributes(extractors ...ContextAttributeExtractor) ScopeOption {
+	return func(cfg *scopeConfig) {
+		cfg.contextExtractors = append(cfg.contextExtractors, extractors...)
+	}
+}
+
+// BaggageAttributes returns a ContextAttributeExtractor reading the given
+// members from the OpenTelemetry baggage of the context. Missing members are
+// skipped.
+func BaggageAttributes(keys ...string) ContextAttributeExtractor {
+	return func(ctx context.Context) []attribute.KeyValue {
+		bag := baggage.FromContext(ctx)
+		if bag.Len() == 0 {
+			return nil
+		}
+		var attrs []attribute.KeyValue
+		for _, key := range keys {
+			if m := bag.Member(key); m.Key() != "" {
+				attrs = append(attrs, attribute.String(key, m.Value()))
+			}
+		}
+		return attrs
+	}
+}
+
+// AttributeFilter transforms the attributes of a measurement, e.g. to drop
+// high-cardinality keys or hash user IDs. It returns the attributes to
+// record, and may modify attrs.
+type AttributeFilter func(attrs []attribute.KeyValue) []attribute.KeyValue
+
+// WithAttributeFilters sets filters applied, in order, to the attributes of
+// every measurement made through the Scope, once merged with the default
+// and context attributes, so that policies such as dropping user IDs are
+// enforced in one place rather than at every call site. Given to
+// [SetDefault] or [SetMeterProvider], they apply to the instruments of the
+// package level functions, [Global] and [Library] too.
+//
+// The filters are applied to synchronous instruments and to callbacks
+// registered with RegisterCallback, GaugeFunc, or Float64GaugeFunc.
+// Callbacks passed as instrument options (e.g. metric.WithInt64Callback) are
+// not covered.
+func WithAttributeFilters(filters ...AttributeFilter) ScopeOption {
+	return func(cfg *scopeConfig) {
+		cfg.attributeFilters = append(cfg.attributeFilters, filters...)
+	}
+}
+
+// DropAttributes returns an AttributeFilter removing the attributes keys,
+// e.g. high-cardinality ones such as user or request IDs.
+func DropAttributes(keys ...attribute.Key) AttributeFilter {
+	drop := make(map[attribute.Key]bool, len(keys))
+	for _, key := range keys {
+		drop[key] = true
+	}
+	return func(attrs []attribute.KeyValue) []attribute.KeyValue {
+		kept := attrs[:0]
+		for _, kv := range attrs {
+			if !drop[kv.Key] {
+				kept = append(kept, kv)
+			}
+		}
+		return kept
+	}
+}
+
+// TransformAttribute returns an AttributeFilter replacing the value of the
+// attribute key by the one returned by f, e.g. to normalize route labels.
+func TransformAttribute(key attribute.Key, f func(attribute.Value) attribute.Value) AttributeFilter {
+	return func(attrs []attribute.KeyValue) []attribute.KeyValue {
+		for i, kv := range attrs {
+			if kv.Key == key {
+				attrs[i].Value = f(kv.Value)
+			}
+		}
+		return attrs
+	}
+}
+
+// HashAttributes returns an AttributeFilter replacing the values of the
+// attributes keys by the first 16 hex characters of their SHA-256 hash,
+// e.g. to keep user IDs distinct without recording them. Hashing doesn't
+// lower the cardinality, prefer DropAttributes when the values are
+// unbounded.
+func HashAttributes(keys ...attribute.Key) AttributeFilter {
+	hash := make(map[attribute.Key]bool, len(keys))
+	for _, key := range keys {
+		hash[key] = true
+	}
+	return func(attrs []attribute.KeyValue) []attribute.KeyValue {
+		for i, kv := range attrs {
+			if hash[kv.Key] {
+				sum := sha256.Sum256([]byte(kv.Value.Emit()))
+				attrs[i].Value = attribute.StringValue(hex.EncodeToString(sum[:8]))
+			}
+		}
+		return attrs
+	}
+}
+
+func newScopeConfig(opts []ScopeOption) scopeConfig {
+	var cfg scopeConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	return cfg
+}
+
+// scopeAttributes computes the attributes a Scope merges into measurements
+type scopeAttributes struct {
+	defaults   []attribute.KeyValue
+	extractors []ContextAttributeExtractor
+	filters    []AttributeFilter
+	// set holds the defaults, nil when there are none
+	set metric.MeasurementOption
+}
+
+func newScopeAttributes(cfg scopeConfig) *scopeAttributes {
+	if len(cfg.defaultAttributes) == 0 && len(cfg.contextExtractors) == 0 && len(cfg.attributeFilters) == 0 {
+		return nil
+	}
+	a := &scopeAttributes{
+		defaults:   cfg.defaultAttributes,
+		extractors: cfg.contextExtractors,
+		filters:    cfg.attributeFilters,
+	}
+	if len(a.defaults) > 0 {
+		a.set = metric.WithAttributeSet(attribute.NewSet(a.defaults...))
+	}
+	return a
+}
+
+// option returns the measurement option holding the attributes for ctx, nil
+// when there are none.
+func (a *scopeAttributes) option(ctx context.Context) metric.MeasurementOption {
+	if len(a.extractors) == 0 {
+		return a.set
+	}
+
+	attrs := make([]attribute.KeyValue, 0, len(a.defaults)+len(a.extractors))
+	attrs = append(attrs, a.defaults...)
+	for _, extract := range a.extractors {
+		attrs = append(attrs, extract(ctx)...)
+	}
+	return metric.WithAttributes(attrs...)
+}
+
+// filter returns the filtered attributes of set.
+func (a *scopeAttributes) filter(set attribute.Set) metric.MeasurementOption {
+	attrs := set.ToSlice()
+	for _, f := range a.filters {
+		attrs = f(attrs)
+	}
+	return metric.WithAttributeSet(attribute.NewSet(attrs...))
+}
+
+// addOptions returns options with the attributes of the scope merged in.
+func (a *scopeAttributes) addOptions(ctx context.Context, options []metric.AddOption) []metric.AddOption {
+	if o := a.option(ctx); o != nil {
+		options = append([]metric.AddOption{o}, options...)
+	}
+	if len(a.filters) == 0 {
+		return options
+	}
+	return []metric.AddOption{a.filter(metric.NewAddConfig(options).Attributes())}
+}
+
+// recordOptions returns options with the attributes of the scope merged in.
+func (a *scopeAttributes) recordOptions(ctx context.Context, options []metric.RecordOption) []metric.RecordOption {
+	if o := a.option(ctx); o != nil {
+		options = append([]metric.RecordOption{o}, options...)
+	}
+	if len(a.filters) == 0 {
+		return options
+	}
+	return []metric.RecordOption{a.filter(metric.NewRecordConfig(options).Attributes())}
+}
+
+// observeOptions returns options with the default attributes of the scope
+// merged in, the context ones being unavailable to callbacks.
+func (a *scopeAttributes) observeOptions(options []metric.ObserveOption) []metric.ObserveOption {
+	if a.set != nil {
+		options = append([]metric.ObserveOption{a.set}, options...)
+	}
+	if len(a.filters) == 0 {
+		return options
+	}
+	return []metric.ObserveOption{a.filter(metric.NewObserveConfig(options).Attributes())}
+}
+
+// The types below merge the scope attributes into every measurement.
+
+type attrInt64Counter struct {
+	metric.Int64Counter
+	attrs *scopeAttributes
+}
+
+func (c attrInt64Counter) Add(ctx context.Context, incr int64, options ...metric.AddOption) {
+	c.Int64Counter.Add(ctx, incr, c.attrs.addOptions(ctx, options)...)
+}
+
+type attrInt64UpDownCounter struct {
+	metric.Int64UpDownCounter
+	attrs *scopeAttributes
+}
+
+func (c attrInt64UpDownCounter) Add(ctx context.Context, incr int64, options ...metric.AddOption) {
+	c.Int64UpDownCounter.Add(ctx, incr, c.attrs.addOptions(ctx, options)...)
+}
+
+type attrInt64Histogram struct {
+	metric.Int64Histogram
+	attrs *scopeAttributes
+}
+
+func (h attrInt64Histogram) Record(ctx context.Context, incr int64, options ...metric.RecordOption) {
+	h.Int64Histogram.Record(ctx, incr, h.attrs.recordOptions(ctx, options)...)
+}
+
+type attrInt64Gauge struct {
+	metric.Int64Gauge
+	attrs *scopeAttributes
+}
+
+func (g attrInt64Gauge) Record(ctx context.Context, value int64, options ...metric.RecordOption) {
+	g.Int64Gauge.Record(ctx, value, g.attrs.recordOptions(ctx, options)...)
+}
+
+type attrFloat64Counter struct {
+	metric.Float64Counter
+	attrs *scopeAttributes
+}
+
+func (c attrFloat64Counter) Add(ctx context.Context, incr float64, options ...metric.AddOption) {
+	c.Float64Counter.Add(ctx, incr, c.attrs.addOptions(ctx, options)...)
+}
+
+type attrFloat64UpDownCounter struct {
+	metric.Float64UpDownCounter
+	attrs *scopeAttributes
+}
+
+func (c attrFloat64UpDownCounter) Add(ctx context.Context, incr float64, options ...metric.AddOption) {
+	c.Float64UpDownCounter.Add(ctx, incr, c.attrs.addOptions(ctx, options)...)
+}
+
+type attrFloat64Histogram struct {
+	metric.Float64Histogram
+	attrs *scopeAttributes
+}
+
+func (h attrFloat64Histogram) Record(ctx context.Context, incr float64, options ...metric.RecordOption) {
+	h.Float64Histogram.Record(ctx, incr, h.attrs.recordOptions(ctx, options)...)
+}
+
+type attrFloat64Gauge struct {
+	metric.Float64Gauge
+	attrs *scopeAttributes
+}
+
+func (g attrFloat64Gauge) Record(ctx context.Context, value float64, options ...metric.RecordOption) {
+	g.Float64Gauge.Record(ctx, value, g.attrs.recordOptions(ctx, options)...)
+}
+
+type attrObserver struct {
+	metric.Observer
+	attrs *scopeAttributes
+}
+
+func (o attrObserver) ObserveInt64(obsrv metric.Int64Observable, value int64, options ...metric.ObserveOption) {
+	o.Observer.ObserveInt64(obsrv, value, o.attrs.observeOptions(options)...)
+}
+
+func (o attrObserver) ObserveFloat64(obsrv metric.Float64Observable, value float64, options ...metric.ObserveOption) {
+	o.Observer.ObserveFloat64(obsrv, value, o.attrs.observeOptions(options)...)
+}
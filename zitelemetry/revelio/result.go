@@ -0,0 +1,134 @@
+package revelio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	// StatusKey is the attribute key holding the result status.
+	StatusKey = attribute.Key("status")
+	// ErrorTypeKey is the attribute key holding the normalized error type.
+	ErrorTypeKey = attribute.Key("error.type")
+
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+)
+
+// ErrorTyper can be implemented by errors to provide their own normalized,
+// low-cardinality error type.
+type ErrorTyper interface {
+	ErrorType() string
+}
+
+var (
+	knownErrorTypesMu sync.RWMutex
+	knownErrorTypes   = []knownErrorType{
+		{target: context.Canceled, name: "canceled"},
+		{target: context.DeadlineExceeded, name: "timeout"},
+	}
+)
+
+type knownErrorType struct {
+	target error
+	name   string
+}
+
+// RegisterErrorType registers name as the error type of every error matching
+// target with errors.Is. Use this for well-known sentinel errors, e.g.
+// sql.ErrNoRows.
+func RegisterErrorType(target error, name string) {
+	knownErrorTypesMu.Lock()
+	defer knownErrorTypesMu.Unlock()
+	knownErrorTypes = append(knownErrorTypes, knownErrorType{target: target, name: name})
+}
+
+// ErrorType returns the normalized error type of err. The error message is
+// never used, so the result is safe to be used as a metric attribute.
+func ErrorType(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var typer ErrorTyper
+	if errors.As(err, &typer) {
+		return typer.ErrorType()
+	}
+
+	knownErrorTypesMu.RLock()
+	for _, known := range knownErrorTypes {
+		if errors.Is(err, known.target) {
+			knownErrorTypesMu.RUnlock()
+			return known.name
+		}
+	}
+	knownErrorTypesMu.RUnlock()
+
+	for {
+		unwrapped := errors.Unwrap(err)
+		if unwrapped == nil {
+			break
+		}
+		err = unwrapped
+	}
+	return fmt.Sprintf("%T", err)
+}
+
+// ResultAttributes returns the status and error.type attributes for err.
+func ResultAttributes(err error) []attribute.KeyValue {
+	if err == nil {
+		return []attribute.KeyValue{StatusKey.String(StatusSuccess)}
+	}
+	return []attribute.KeyValue{
+		StatusKey.String(StatusFailure),
+		ErrorTypeKey.String(ErrorType(err)),
+	}
+}
+
+// ResultCounter is a counter of operation results, recorded with a normalized
+// `status` and `error.type` attribute.
+type ResultCounter interface {
+	// Success records a successful operation.
+	Success(ctx context.Context, attrs ...attribute.KeyValue)
+	// Failure records a failed operation caused by err.
+	Failure(ctx context.Context, err error, attrs ...attribute.KeyValue)
+	// Record records a successful operation when err is nil, and a failed one
+	// otherwise.
+	Record(ctx context.Context, err error, attrs ...attribute.KeyValue)
+}
+
+// resultCounter is the implementation of ResultCounter
+type resultCounter struct {
+	counter metric.Int64Counter
+}
+
+// Success records a successful operation
+func (rc *resultCounter) Success(ctx context.Context, attrs ...attribute.KeyValue) {
+	rc.Record(ctx, nil, attrs...)
+}
+
+// Failure records a failed operation
+func (rc *resultCounter) Failure(ctx context.Context, err error, attrs ...attribute.KeyValue) {
+	errType := "unknown"
+	if err != nil {
+		errType = ErrorType(err)
+	}
+	rc.add(ctx, attrs, StatusKey.String(StatusFailure), ErrorTypeKey.String(errType))
+}
+
+// Record records an operation result
+func (rc *resultCounter) Record(ctx context.Context, err error, attrs ...attribute.KeyValue) {
+	rc.add(ctx, attrs, ResultAttributes(err)...)
+}
+
+func (rc *resultCounter) add(ctx context.Context, attrs []attribute.KeyValue, result ...attribute.KeyValue) {
+	all := make([]attribute.KeyValue, 0, len(attrs)+len(result))
+	all = append(all, attrs...)
+	all = append(all, result...)
+	rc.counter.Add(ctx, 1, metric.WithAttributes(all...))
+}
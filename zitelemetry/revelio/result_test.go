@@ -0,0 +1,64 @@
+package revelio_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type typedError struct{}
+
+func (typedError) Error() string     { return "user 123 not found" }
+func (typedError) ErrorType() string { return "not_found" }
+
+var errSentinel = errors.New("sentinel with a message")
+
+func TestErrorType(t *testing.T) {
+	revelio.RegisterErrorType(errSentinel, "sentinel")
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil", nil, ""},
+		{"typer", fmt.Errorf("wrapped: %w", typedError{}), "not_found"},
+		{"canceled", fmt.Errorf("wrapped: %w", context.Canceled), "canceled"},
+		{"timeout", context.DeadlineExceeded, "timeout"},
+		{"registered", fmt.Errorf("wrapped: %w", errSentinel), "sentinel"},
+		{"fallback to type name", errors.New("some dynamic message 42"), "*errors.errorString"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := revelio.ErrorType(tt.err); got != tt.want {
+				t.Errorf("ErrorType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResultCounter(t *testing.T) {
+	s := reveliotest.NewTestScope()
+	rc, err := s.ResultCounter("operations_total", "Operations")
+	if err != nil {
+		t.Fatalf("Failed to create result counter: %v", err)
+	}
+
+	ctx := context.Background()
+	op := attribute.String("operation_name", "get_user")
+	rc.Success(ctx, op)
+	rc.Success(ctx, op)
+	rc.Failure(ctx, typedError{}, op)
+	rc.Record(ctx, nil, op)
+
+	reveliotest.AssertCounterValue(t, s, "operations_total", 3, revelio.StatusKey.String(revelio.StatusSuccess))
+	reveliotest.AssertCounterValue(t, s, "operations_total", 1,
+		revelio.StatusKey.String(revelio.StatusFailure),
+		revelio.ErrorTypeKey.String("not_found"),
+	)
+}
@@ -22,8 +22,9 @@ type ScopeResult struct {
 // DefaultScopeProvider provides the default scope
 var DefaultScopeProvider = fx.Provide(
 	func(params ScopeParams) ScopeResult {
-		// Get the global default scope
-		scope := revelio.GetDefault()
+		// Get the scope following the global default, so instruments created
+		// before telemetry is set up are not lost
+		scope := revelio.Global()
 
 		return ScopeResult{
 			Scope: scope,
@@ -0,0 +1,212 @@
+// Package reveliotest provides an in-memory revelio.Scope for unit tests.
+//
+// Measurements recorded through a test scope are kept by an OpenTelemetry
+// ManualReader, so tests can assert metrics emitted by middlewares and DB
+// wrappers without a real exporter.
+package reveliotest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const meterName = "reveliotest"
+
+// Scope is a revelio.ExtendedScope backed by an in-memory ManualReader.
+type Scope struct {
+	revelio.ExtendedScope
+
+	Reader   *sdkmetric.ManualReader
+	Provider *sdkmetric.MeterProvider
+}
+
+// NewTestScope creates a new Scope backed by an in-memory ManualReader. The
+// options are passed to the underlying MeterProvider, e.g. to configure an
+// exemplar filter.
+func NewTestScope(opts ...sdkmetric.Option) *Scope {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(append([]sdkmetric.Option{sdkmetric.WithReader(reader)}, opts...)...)
+	return &Scope{
+		ExtendedScope: revelio.Extend(revelio.NewFromMeterProvider(provider, meterName)),
+		Reader:        reader,
+		Provider:      provider,
+	}
+}
+
+// NewDefaultTestScope creates a new Scope and installs it as the revelio
+// global default, so instruments created through package level helpers (e.g.
+// revelio.MustInt64Counter) are recorded by it. The previous default is
+// restored when the test finishes.
+func NewDefaultTestScope(t testing.TB, opts ...sdkmetric.Option) *Scope {
+	t.Helper()
+
+	s := NewTestScope(opts...)
+	prev := revelio.GetDefault()
+	revelio.SetDefault(s)
+	t.Cleanup(func() {
+		revelio.SetDefault(prev)
+		_ = s.Provider.Shutdown(context.Background())
+	})
+	return s
+}
+
+// Collect gathers every measurement recorded so far.
+func (s *Scope) Collect(t testing.TB) metricdata.ResourceMetrics {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := s.Reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("reveliotest: failed to collect metrics: %v", err)
+	}
+	return rm
+}
+
+// Metric returns the collected metric identified by name, ok is false when no
+// such metric has been recorded.
+func (s *Scope) Metric(t testing.TB, name string) (metricdata.Metrics, bool) {
+	t.Helper()
+
+	rm := s.Collect(t)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m, true
+			}
+		}
+	}
+	return metricdata.Metrics{}, false
+}
+
+// AssertCounterValue asserts that the counter identified by name has the
+// value of want. When attrs are given, only data points having all of those
+// attributes are summed; otherwise every data point is summed.
+func AssertCounterValue(t testing.TB, s *Scope, name string, want int64, attrs ...attribute.KeyValue) {
+	t.Helper()
+
+	m, ok := s.Metric(t, name)
+	if !ok {
+		t.Fatalf("reveliotest: counter %q was not recorded", name)
+	}
+
+	var got float64
+	switch data := m.Data.(type) {
+	case metricdata.Sum[int64]:
+		for _, dp := range data.DataPoints {
+			if hasAttributes(dp.Attributes, attrs) {
+				got += float64(dp.Value)
+			}
+		}
+	case metricdata.Sum[float64]:
+		for _, dp := range data.DataPoints {
+			if hasAttributes(dp.Attributes, attrs) {
+				got += dp.Value
+			}
+		}
+	default:
+		t.Fatalf("reveliotest: metric %q is not a counter, got %T", name, m.Data)
+	}
+
+	if got != float64(want) {
+		t.Errorf("reveliotest: counter %q = %v, want %v", name, got, want)
+	}
+}
+
+// HistogramData is the aggregated view of a collected histogram.
+type HistogramData struct {
+	Count        uint64
+	Sum          float64
+	Bounds       []float64
+	BucketCounts []uint64
+	Exemplars    []Exemplar
+}
+
+// Exemplar is an example measurement of a histogram, linked to the span it was
+// recorded in.
+type Exemplar struct {
+	Value   float64
+	TraceID trace.TraceID
+	SpanID  trace.SpanID
+}
+
+// CollectHistogram returns the aggregated data of the histogram identified by
+// name. When attrs are given, only data points having all of those attributes
+// are aggregated.
+func CollectHistogram(t testing.TB, s *Scope, name string, attrs ...attribute.KeyValue) HistogramData {
+	t.Helper()
+
+	m, ok := s.Metric(t, name)
+	if !ok {
+		t.Fatalf("reveliotest: histogram %q was not recorded", name)
+	}
+
+	var out HistogramData
+	switch data := m.Data.(type) {
+	case metricdata.Histogram[int64]:
+		for _, dp := range data.DataPoints {
+			if hasAttributes(dp.Attributes, attrs) {
+				out.add(dp.Count, float64(dp.Sum), dp.Bounds, dp.BucketCounts)
+				for _, e := range dp.Exemplars {
+					out.addExemplar(float64(e.Value), e.TraceID, e.SpanID)
+				}
+			}
+		}
+	case metricdata.Histogram[float64]:
+		for _, dp := range data.DataPoints {
+			if hasAttributes(dp.Attributes, attrs) {
+				out.add(dp.Count, dp.Sum, dp.Bounds, dp.BucketCounts)
+				for _, e := range dp.Exemplars {
+					out.addExemplar(e.Value, e.TraceID, e.SpanID)
+				}
+			}
+		}
+	default:
+		t.Fatalf("reveliotest: metric %q is not a histogram, got %T", name, m.Data)
+	}
+	return out
+}
+
+func (h *HistogramData) add(count uint64, sum float64, bounds []float64, bucketCounts []uint64) {
+	h.Count += count
+	h.Sum += sum
+	if h.Bounds == nil {
+		h.Bounds = bounds
+		h.BucketCounts = make([]uint64, len(bucketCounts))
+	}
+	for i := range bucketCounts {
+		if i < len(h.BucketCounts) {
+			h.BucketCounts[i] += bucketCounts[i]
+		}
+	}
+}
+
+func (h *HistogramData) addExemplar(value float64, traceID, spanID []byte) {
+	e := Exemplar{Value: value}
+	copy(e.TraceID[:], traceID)
+	copy(e.SpanID[:], spanID)
+	h.Exemplars = append(h.Exemplars, e)
+}
+
+func hasAttributes(set attribute.Set, attrs []attribute.KeyValue) bool {
+	for _, kv := range attrs {
+		v, ok := set.Value(kv.Key)
+		if !ok || v != kv.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// LibraryScope returns the Scope of the instrumentation library name, backed
+// by the same ManualReader, see revelio.Library.
+func (s *Scope) LibraryScope(name string) revelio.Scope {
+	if l, ok := s.ExtendedScope.(revelio.LibraryScoper); ok {
+		return l.LibraryScope(name)
+	}
+	return s.ExtendedScope
+}
@@ -0,0 +1,96 @@
+package reveliotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestAssertCounterValue(t *testing.T) {
+	s := NewTestScope()
+	counter, err := s.Int64Counter("test_counter", "A test counter")
+	if err != nil {
+		t.Fatalf("Failed to create counter: %v", err)
+	}
+
+	ctx := context.Background()
+	counter.Add(ctx, 2, metric.WithAttributes(attribute.String("method", "GET")))
+	counter.Add(ctx, 3, metric.WithAttributes(attribute.String("method", "POST")))
+
+	AssertCounterValue(t, s, "test_counter", 5)
+	AssertCounterValue(t, s, "test_counter", 2, attribute.String("method", "GET"))
+	AssertCounterValue(t, s, "test_counter", 3, attribute.String("method", "POST"))
+}
+
+func TestCollectHistogram(t *testing.T) {
+	s := NewTestScope()
+	duration, err := s.Duration("test_duration", "A test duration")
+	if err != nil {
+		t.Fatalf("Failed to create duration: %v", err)
+	}
+
+	ctx := context.Background()
+	duration.Record(ctx, 10*time.Millisecond, attribute.String("operation", "query"))
+	duration.Record(ctx, 30*time.Millisecond, attribute.String("operation", "query"))
+	duration.Record(ctx, 5*time.Millisecond, attribute.String("operation", "exec"))
+
+	h := CollectHistogram(t, s, "test_duration", attribute.String("operation", "query"))
+	if h.Count != 2 {
+		t.Errorf("Count = %d, want 2", h.Count)
+	}
+	if h.Sum != 40 {
+		t.Errorf("Sum = %v, want 40", h.Sum)
+	}
+
+	all := CollectHistogram(t, s, "test_duration")
+	if all.Count != 3 {
+		t.Errorf("Count = %d, want 3", all.Count)
+	}
+}
+
+func TestCollectHistogramExemplars(t *testing.T) {
+	s := NewTestScope()
+	duration, err := s.Duration("exemplar_duration", "A duration with exemplars")
+	if err != nil {
+		t.Fatalf("Failed to create duration: %v", err)
+	}
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01},
+		SpanID:     trace.SpanID{0x02},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+	duration.Record(ctx, 12*time.Millisecond)
+
+	// Measurements outside of a sampled span are not kept by the default filter
+	duration.Record(context.Background(), 7*time.Millisecond)
+
+	h := CollectHistogram(t, s, "exemplar_duration")
+	if len(h.Exemplars) != 1 {
+		t.Fatalf("Exemplars = %d, want 1", len(h.Exemplars))
+	}
+	e := h.Exemplars[0]
+	if e.TraceID != sc.TraceID() || e.SpanID != sc.SpanID() || e.Value != 12 {
+		t.Errorf("Exemplar = %+v, want value 12 linked to %s/%s", e, sc.TraceID(), sc.SpanID())
+	}
+}
+
+func TestNewDefaultTestScope(t *testing.T) {
+	prev := revelio.GetDefault()
+
+	t.Run("records package level instruments", func(t *testing.T) {
+		s := NewDefaultTestScope(t)
+		revelio.MustInt64Counter("default_counter", "A default counter").Add(context.Background(), 1)
+		AssertCounterValue(t, s, "default_counter", 1)
+	})
+
+	if revelio.GetDefault() != prev {
+		t.Fatal("Default scope should be restored after the test")
+	}
+}
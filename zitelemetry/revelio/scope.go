@@ -37,9 +37,72 @@ type Scope interface {
 	RegisterCallback(f metric.Callback, instruments ...metric.Observable) (metric.Registration, error)
 }
 
+// ExtendedScope is a Scope with the conveniences built on top of the standard
+// metric creation methods. The Scopes of this package implement it, reach it
+// with a type assertion or with [Extend].
+//
+// The conveniences are kept out of Scope so that Scope can still be
+// implemented outside of this package.
+type ExtendedScope interface {
+	Scope
+
+	// ResultCounter creates a counter of operation results
+	ResultCounter(name string, description string, options ...metric.Int64CounterOption) (ResultCounter, error)
+
+	// Batch returns a Batch recording with ctx and attrs once flushed
+	Batch(ctx context.Context, attrs ...attribute.KeyValue) *Batch
+
+	// Callback backed gauge conveniences
+	GaugeFunc(name string, description string, f func() int64, options ...metric.Int64ObservableGaugeOption) (metric.Registration, error)
+	Float64GaugeFunc(name string, description string, f func() float64, options ...metric.Float64ObservableGaugeOption) (metric.Registration, error)
+}
+
+// Extend returns s as an ExtendedScope. Scopes not implementing it, e.g.
+// implemented outside of this package, get the conveniences built on top of
+// their standard metric creation methods.
+func Extend(s Scope) ExtendedScope {
+	if e, ok := s.(ExtendedScope); ok {
+		return e
+	}
+	return extendedScope{s}
+}
+
+// extendedScope adds the ExtendedScope conveniences to a Scope
+type extendedScope struct {
+	Scope
+}
+
+// ResultCounter creates a counter of operation results
+func (s extendedScope) ResultCounter(name string, description string, options ...metric.Int64CounterOption) (ResultCounter, error) {
+	return newResultCounter(s, name, description, options...)
+}
+
+// Batch returns a Batch recording with ctx and attrs once flushed
+func (s extendedScope) Batch(ctx context.Context, attrs ...attribute.KeyValue) *Batch {
+	return NewBatch(ctx, attrs...)
+}
+
+// GaugeFunc creates an Int64ObservableGauge reporting the value returned by f
+func (s extendedScope) GaugeFunc(name string, description string, f func() int64, options ...metric.Int64ObservableGaugeOption) (metric.Registration, error) {
+	return gaugeFunc(s, name, description, f, options...)
+}
+
+// Float64GaugeFunc creates a Float64ObservableGauge reporting the value
+// returned by f
+func (s extendedScope) Float64GaugeFunc(name string, description string, f func() float64, options ...metric.Float64ObservableGaugeOption) (metric.Registration, error) {
+	return float64GaugeFunc(s, name, description, f, options...)
+}
+
 // scope is the implementation of Scope interface
 type scope struct {
 	meter metric.Meter
+	// attrs holds the default and context attributes, nil when there are none
+	attrs *scopeAttributes
+	// provider derives the Scopes of libraries, nil when the Scope was
+	// created from a meter
+	provider metric.MeterProvider
+	// opts are the options the Scope was created with
+	opts []ScopeOption
 }
 
 // GetMeter returns the underlying meter
@@ -49,45 +112,54 @@ func (s *scope) GetMeter() metric.Meter {
 
 // Duration creates a duration recorder (Float64Histogram with ms unit)
 func (s *scope) Duration(name string, description string, options ...DurationOption) (DurationRecorder, error) {
-	opts := []metric.Float64HistogramOption{
-		metric.WithDescription(description),
-		metric.WithUnit("ms"),
-	}
-
-	// Apply custom options
-	for _, opt := range options {
-		opts = append(opts, opt.toFloat64HistogramOption())
-	}
+	return newDuration(s, name, description, options...)
+}
 
-	histogram, err := s.meter.Float64Histogram(name, opts...)
-	if err != nil {
-		return nil, err
-	}
+// ResultCounter creates a counter of operation results
+func (s *scope) ResultCounter(name string, description string, options ...metric.Int64CounterOption) (ResultCounter, error) {
+	return newResultCounter(s, name, description, options...)
+}
 
-	return &durationRecorder{
-		histogram: histogram,
-	}, nil
+// Batch returns a Batch recording with ctx and attrs once flushed
+func (s *scope) Batch(ctx context.Context, attrs ...attribute.KeyValue) *Batch {
+	return NewBatch(ctx, attrs...)
 }
 
 // Standard metric creation methods delegate to the underlying meter
 func (s *scope) Int64Counter(name string, description string, options ...metric.Int64CounterOption) (metric.Int64Counter, error) {
 	opts := append([]metric.Int64CounterOption{metric.WithDescription(description)}, options...)
-	return s.meter.Int64Counter(name, opts...)
+	instr, err := s.meter.Int64Counter(name, opts...)
+	if err != nil || s.attrs == nil {
+		return instr, err
+	}
+	return attrInt64Counter{instr, s.attrs}, nil
 }
 
 func (s *scope) Int64UpDownCounter(name string, description string, options ...metric.Int64UpDownCounterOption) (metric.Int64UpDownCounter, error) {
 	opts := append([]metric.Int64UpDownCounterOption{metric.WithDescription(description)}, options...)
-	return s.meter.Int64UpDownCounter(name, opts...)
+	instr, err := s.meter.Int64UpDownCounter(name, opts...)
+	if err != nil || s.attrs == nil {
+		return instr, err
+	}
+	return attrInt64UpDownCounter{instr, s.attrs}, nil
 }
 
 func (s *scope) Int64Histogram(name string, description string, options ...metric.Int64HistogramOption) (metric.Int64Histogram, error) {
 	opts := append([]metric.Int64HistogramOption{metric.WithDescription(description)}, options...)
-	return s.meter.Int64Histogram(name, opts...)
+	instr, err := s.meter.Int64Histogram(name, opts...)
+	if err != nil || s.attrs == nil {
+		return instr, err
+	}
+	return attrInt64Histogram{instr, s.attrs}, nil
 }
 
 func (s *scope) Int64Gauge(name string, description string, options ...metric.Int64GaugeOption) (metric.Int64Gauge, error) {
 	opts := append([]metric.Int64GaugeOption{metric.WithDescription(description)}, options...)
-	return s.meter.Int64Gauge(name, opts...)
+	instr, err := s.meter.Int64Gauge(name, opts...)
+	if err != nil || s.attrs == nil {
+		return instr, err
+	}
+	return attrInt64Gauge{instr, s.attrs}, nil
 }
 
 func (s *scope) Int64ObservableCounter(name string, description string, options ...metric.Int64ObservableCounterOption) (metric.Int64ObservableCounter, error) {
@@ -107,22 +179,38 @@ func (s *scope) Int64ObservableGauge(name string, description string, options ..
 
 func (s *scope) Float64Counter(name string, description string, options ...metric.Float64CounterOption) (metric.Float64Counter, error) {
 	opts := append([]metric.Float64CounterOption{metric.WithDescription(description)}, options...)
-	return s.meter.Float64Counter(name, opts...)
+	instr, err := s.meter.Float64Counter(name, opts...)
+	if err != nil || s.attrs == nil {
+		return instr, err
+	}
+	return attrFloat64Counter{instr, s.attrs}, nil
 }
 
 func (s *scope) Float64UpDownCounter(name string, description string, options ...metric.Float64UpDownCounterOption) (metric.Float64UpDownCounter, error) {
 	opts := append([]metric.Float64UpDownCounterOption{metric.WithDescription(description)}, options...)
-	return s.meter.Float64UpDownCounter(name, opts...)
+	instr, err := s.meter.Float64UpDownCounter(name, opts...)
+	if err != nil || s.attrs == nil {
+		return instr, err
+	}
+	return attrFloat64UpDownCounter{instr, s.attrs}, nil
 }
 
 func (s *scope) Float64Histogram(name string, description string, options ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
 	opts := append([]metric.Float64HistogramOption{metric.WithDescription(description)}, options...)
-	return s.meter.Float64Histogram(name, opts...)
+	instr, err := s.meter.Float64Histogram(name, opts...)
+	if err != nil || s.attrs == nil {
+		return instr, err
+	}
+	return attrFloat64Histogram{instr, s.attrs}, nil
 }
 
 func (s *scope) Float64Gauge(name string, description string, options ...metric.Float64GaugeOption) (metric.Float64Gauge, error) {
 	opts := append([]metric.Float64GaugeOption{metric.WithDescription(description)}, options...)
-	return s.meter.Float64Gauge(name, opts...)
+	instr, err := s.meter.Float64Gauge(name, opts...)
+	if err != nil || s.attrs == nil {
+		return instr, err
+	}
+	return attrFloat64Gauge{instr, s.attrs}, nil
 }
 
 func (s *scope) Float64ObservableCounter(name string, description string, options ...metric.Float64ObservableCounterOption) (metric.Float64ObservableCounter, error) {
@@ -141,7 +229,80 @@ func (s *scope) Float64ObservableGauge(name string, description string, options
 }
 
 func (s *scope) RegisterCallback(f metric.Callback, instruments ...metric.Observable) (metric.Registration, error) {
-	return s.meter.RegisterCallback(f, instruments...)
+	if s.attrs == nil || (s.attrs.set == nil && len(s.attrs.filters) == 0) {
+		return s.meter.RegisterCallback(f, instruments...)
+	}
+	return s.meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		return f(ctx, attrObserver{Observer: o, attrs: s.attrs})
+	}, instruments...)
+}
+
+// GaugeFunc creates an Int64ObservableGauge reporting the value returned by f
+// on every collection cycle.
+func (s *scope) GaugeFunc(name string, description string, f func() int64, options ...metric.Int64ObservableGaugeOption) (metric.Registration, error) {
+	return gaugeFunc(s, name, description, f, options...)
+}
+
+// Float64GaugeFunc creates a Float64ObservableGauge reporting the value
+// returned by f on every collection cycle.
+func (s *scope) Float64GaugeFunc(name string, description string, f func() float64, options ...metric.Float64ObservableGaugeOption) (metric.Registration, error) {
+	return float64GaugeFunc(s, name, description, f, options...)
+}
+
+// The helpers below implement the ExtendedScope conveniences on top of the
+// standard metric creation methods, so every Scope implementation shares them.
+
+func newDuration(s Scope, name string, description string, options ...DurationOption) (DurationRecorder, error) {
+	opts := []metric.Float64HistogramOption{
+		metric.WithUnit("ms"),
+	}
+
+	// Apply custom options
+	for _, opt := range options {
+		opts = append(opts, opt.toFloat64HistogramOption())
+	}
+
+	histogram, err := s.Float64Histogram(name, description, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &durationRecorder{
+		histogram: histogram,
+	}, nil
+}
+
+func newResultCounter(s Scope, name string, description string, options ...metric.Int64CounterOption) (ResultCounter, error) {
+	counter, err := s.Int64Counter(name, description, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resultCounter{
+		counter: counter,
+	}, nil
+}
+
+func gaugeFunc(s Scope, name string, description string, f func() int64, options ...metric.Int64ObservableGaugeOption) (metric.Registration, error) {
+	gauge, err := s.Int64ObservableGauge(name, description, options...)
+	if err != nil {
+		return nil, err
+	}
+	return s.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(gauge, f())
+		return nil
+	}, gauge)
+}
+
+func float64GaugeFunc(s Scope, name string, description string, f func() float64, options ...metric.Float64ObservableGaugeOption) (metric.Registration, error) {
+	gauge, err := s.Float64ObservableGauge(name, description, options...)
+	if err != nil {
+		return nil, err
+	}
+	return s.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveFloat64(gauge, f())
+		return nil
+	}, gauge)
 }
 
 // DurationRecorder is a specialized recorder for duration measurements
@@ -0,0 +1,250 @@
+package revelio_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestGaugeFunc(t *testing.T) {
+	s := reveliotest.NewTestScope()
+
+	depth := int64(7)
+	reg, err := s.GaugeFunc("queue_depth", "Queue depth", func() int64 { return depth })
+	if err != nil {
+		t.Fatalf("Failed to create gauge func: %v", err)
+	}
+
+	m, ok := s.Metric(t, "queue_depth")
+	if !ok {
+		t.Fatal("Gauge should be recorded")
+	}
+	gauge := m.Data.(metricdata.Gauge[int64])
+	if got := gauge.DataPoints[0].Value; got != 7 {
+		t.Errorf("Gauge = %d, want 7", got)
+	}
+
+	if err := reg.Unregister(); err != nil {
+		t.Fatalf("Failed to unregister: %v", err)
+	}
+	if m, ok := s.Metric(t, "queue_depth"); ok && len(m.Data.(metricdata.Gauge[int64]).DataPoints) > 0 {
+		t.Error("Gauge should not be reported after Unregister")
+	}
+}
+
+func TestFloat64GaugeFunc(t *testing.T) {
+	s := reveliotest.NewTestScope()
+
+	if _, err := s.Float64GaugeFunc("cache_ratio", "Cache ratio", func() float64 { return 0.5 }); err != nil {
+		t.Fatalf("Failed to create gauge func: %v", err)
+	}
+
+	m, ok := s.Metric(t, "cache_ratio")
+	if !ok {
+		t.Fatal("Gauge should be recorded")
+	}
+	if got := m.Data.(metricdata.Gauge[float64]).DataPoints[0].Value; got != 0.5 {
+		t.Errorf("Gauge = %v, want 0.5", got)
+	}
+}
+
+func TestWithDefaultAttributes(t *testing.T) {
+	base := reveliotest.NewTestScope()
+	s := revelio.NewFromMeter(base.GetMeter(), revelio.WithDefaultAttributes(
+		attribute.String("module", "billing"),
+		attribute.String("component", "worker"),
+	))
+
+	counter, err := s.Int64Counter("jobs_total", "Jobs")
+	if err != nil {
+		t.Fatalf("Failed to create counter: %v", err)
+	}
+	counter.Add(context.Background(), 1, metric.WithAttributes(attribute.String("component", "api")))
+
+	reveliotest.AssertCounterValue(t, base, "jobs_total", 1,
+		attribute.String("module", "billing"),
+		attribute.String("component", "api"),
+	)
+
+	if _, err := s.(revelio.ExtendedScope).GaugeFunc("pool_size", "Pool size", func() int64 { return 3 }); err != nil {
+		t.Fatalf("Failed to create gauge func: %v", err)
+	}
+	m, _ := base.Metric(t, "pool_size")
+	dp := m.Data.(metricdata.Gauge[int64]).DataPoints[0]
+	if v, _ := dp.Attributes.Value("module"); v.AsString() != "billing" {
+		t.Errorf("Gauge module attribute = %q, want %q", v.AsString(), "billing")
+	}
+}
+
+type tenantKey struct{}
+
+func TestWithContextAttributes(t *testing.T) {
+	base := reveliotest.NewTestScope()
+	tenant := func(ctx context.Context) []attribute.KeyValue {
+		if v, ok := ctx.Value(tenantKey{}).(string); ok {
+			return []attribute.KeyValue{attribute.String("tenant", v)}
+		}
+		return nil
+	}
+	s := revelio.NewFromMeter(base.GetMeter(),
+		revelio.WithDefaultAttributes(attribute.String("tenant", "unknown"), attribute.String("module", "billing")),
+		revelio.WithContextAttributes(tenant, revelio.BaggageAttributes("locale")),
+	)
+
+	duration, err := s.Duration("invoice_duration", "Invoice duration")
+	if err != nil {
+		t.Fatalf("Failed to create duration: %v", err)
+	}
+
+	member, _ := baggage.NewMember("locale", "id")
+	bag, _ := baggage.New(member)
+	ctx := baggage.ContextWithBaggage(context.WithValue(context.Background(), tenantKey{}, "acme"), bag)
+
+	duration.Record(ctx, 10*time.Millisecond)
+	duration.Record(context.Background(), 20*time.Millisecond)
+
+	h := reveliotest.CollectHistogram(t, base, "invoice_duration",
+		attribute.String("tenant", "acme"),
+		attribute.String("locale", "id"),
+		attribute.String("module", "billing"),
+	)
+	if h.Count != 1 || h.Sum != 10 {
+		t.Errorf("acme histogram count/sum = %d/%v, want 1/10", h.Count, h.Sum)
+	}
+
+	h = reveliotest.CollectHistogram(t, base, "invoice_duration", attribute.String("tenant", "unknown"))
+	if h.Count != 1 || h.Sum != 20 {
+		t.Errorf("default histogram count/sum = %d/%v, want 1/20", h.Count, h.Sum)
+	}
+}
+
+func TestWithAttributeFilters(t *testing.T) {
+	base := reveliotest.NewTestScope()
+	s := revelio.NewFromMeter(base.GetMeter(),
+		revelio.WithDefaultAttributes(attribute.String("module", "billing"), attribute.String("request_id", "default")),
+		revelio.WithAttributeFilters(
+			revelio.DropAttributes("request_id"),
+			revelio.HashAttributes("user_id"),
+			revelio.TransformAttribute("route", func(v attribute.Value) attribute.Value {
+				return attribute.StringValue(strings.TrimSuffix(v.AsString(), "/"))
+			}),
+		),
+	)
+
+	counter, err := s.Int64Counter("filtered_total", "Filtered")
+	if err != nil {
+		t.Fatalf("Failed to create counter: %v", err)
+	}
+	counter.Add(context.Background(), 1, metric.WithAttributes(
+		attribute.String("request_id", "r-1"),
+		attribute.String("user_id", "u-1"),
+		attribute.String("route", "/users/"),
+	))
+	reveliotest.AssertCounterValue(t, base, "filtered_total", 1,
+		attribute.String("module", "billing"),
+		attribute.String("user_id", "a24a7f55f278dd49"),
+		attribute.String("route", "/users"),
+	)
+	m, _ := base.Metric(t, "filtered_total")
+	if _, ok := m.Data.(metricdata.Sum[int64]).DataPoints[0].Attributes.Value("request_id"); ok {
+		t.Error("request_id was not dropped")
+	}
+
+	if _, err := s.(revelio.ExtendedScope).GaugeFunc("filtered_gauge", "Filtered gauge", func() int64 { return 3 }); err != nil {
+		t.Fatalf("Failed to create gauge func: %v", err)
+	}
+	m, _ = base.Metric(t, "filtered_gauge")
+	dp := m.Data.(metricdata.Gauge[int64]).DataPoints[0]
+	if _, ok := dp.Attributes.Value("request_id"); ok || dp.Attributes.Len() != 1 {
+		t.Errorf("gauge attributes = %v, want the module only", dp.Attributes.ToSlice())
+	}
+}
+
+func TestSetDefaultOptions(t *testing.T) {
+	counter := revelio.MustInt64Counter("default_filtered_total", "counter created before the default is set")
+	s := reveliotest.NewDefaultTestScope(t)
+	revelio.SetDefault(revelio.NewFromMeterProvider(s.Provider, "filtered"), revelio.WithAttributeFilters(revelio.DropAttributes("user_id")))
+
+	counter.Add(context.Background(), 1, metric.WithAttributes(attribute.String("user_id", "u-1"), attribute.String("plan", "pro")))
+	reveliotest.AssertCounterValue(t, s, "default_filtered_total", 1, attribute.String("plan", "pro"))
+	m, _ := s.Metric(t, "default_filtered_total")
+	if attrs := m.Data.(metricdata.Sum[int64]).DataPoints[0].Attributes; attrs.Len() != 1 {
+		t.Errorf("attributes = %v, want the plan only", attrs.ToSlice())
+	}
+}
+
+func TestGlobalFollowsDefault(t *testing.T) {
+	counter := revelio.MustInt64Counter("global_follow_counter", "counter created before the default is set")
+	gauge := revelio.MustGaugeFunc("global_follow_gauge", "gauge created before the default is set", func() int64 { return 7 })
+	defer gauge.Unregister()
+
+	observed := revelio.MustInt64ObservableCounter("global_follow_observed", "observable created before the default is set")
+	reg, err := revelio.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(observed, 3)
+		return nil
+	}, observed)
+	if err != nil {
+		t.Fatalf("RegisterCallback: %v", err)
+	}
+	defer reg.Unregister()
+
+	s := reveliotest.NewDefaultTestScope(t)
+	counter.Add(context.Background(), 2)
+
+	reveliotest.AssertCounterValue(t, s, "global_follow_counter", 2)
+	reveliotest.AssertCounterValue(t, s, "global_follow_observed", 3)
+	if _, ok := s.Metric(t, "global_follow_gauge"); !ok {
+		t.Fatal("expected gauge to be re-registered on the new default")
+	}
+
+	// A second swap moves everything again
+	next := reveliotest.NewDefaultTestScope(t)
+	counter.Add(context.Background(), 1)
+	reveliotest.AssertCounterValue(t, next, "global_follow_counter", 1)
+	reveliotest.AssertCounterValue(t, next, "global_follow_observed", 3)
+}
+
+func TestSetDefaultRejectsGlobal(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected SetDefault(Global()) to panic")
+		}
+	}()
+	revelio.SetDefault(revelio.Global())
+}
+
+// wrappedScope is a Scope implemented outside of revelio
+type wrappedScope struct {
+	revelio.Scope
+}
+
+func TestExtend(t *testing.T) {
+	base := reveliotest.NewTestScope()
+	if _, ok := revelio.Scope(base).(revelio.ExtendedScope); !ok {
+		t.Fatal("test Scope should be an ExtendedScope")
+	}
+
+	s := revelio.Extend(wrappedScope{base.ExtendedScope})
+	rc, err := s.ResultCounter("wrapped_operations_total", "Operations")
+	if err != nil {
+		t.Fatalf("Failed to create result counter: %v", err)
+	}
+	rc.Record(context.Background(), nil)
+	reveliotest.AssertCounterValue(t, base, "wrapped_operations_total", 1, revelio.StatusKey.String(revelio.StatusSuccess))
+
+	if _, err := s.GaugeFunc("wrapped_gauge", "Wrapped gauge", func() int64 { return 4 }); err != nil {
+		t.Fatalf("Failed to create gauge func: %v", err)
+	}
+	m, ok := base.Metric(t, "wrapped_gauge")
+	if !ok || m.Data.(metricdata.Gauge[int64]).DataPoints[0].Value != 4 {
+		t.Errorf("wrapped_gauge = %v, want 4", m.Data)
+	}
+}
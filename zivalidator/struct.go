@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/divikraf/lumos/i18n"
+	"github.com/go-playground/locales"
 	"github.com/go-playground/locales/en"
 	"github.com/go-playground/locales/id"
 	ut "github.com/go-playground/universal-translator"
@@ -14,8 +15,11 @@ import (
 )
 
 type Validator struct {
-	uni      *ut.UniversalTranslator
-	validate *validator.Validate
+	uni         *ut.UniversalTranslator
+	validate    *validator.Validate
+	matcher     *i18n.Matcher
+	locales     []language.Tag
+	translators []ut.Translator
 }
 
 var _ Validate = (*Validator)(nil)
@@ -53,30 +57,55 @@ func New(opts ...Option) *Validator {
 		}
 	}
 
-	return &Validator{
+	v := &Validator{
 		uni:      uni,
 		validate: validate,
 	}
+	v.addLocale(language.English, translatorEN)
+	v.addLocale(language.Indonesian, translatorID)
+	return v
+}
+
+// RegisterTranslationsFunc registers the translations of validation errors,
+// e.g. the RegisterDefaultTranslations functions of the validator
+// translations packages.
+type RegisterTranslationsFunc func(v *validator.Validate, trans ut.Translator) error
+
+// AddLocale adds the translations of tag, translated with the locale l, to the
+// languages matched by ValidateStruct. Like New, it is not safe to run
+// concurrently.
+func (v *Validator) AddLocale(tag language.Tag, l locales.Translator, register RegisterTranslationsFunc) error {
+	if err := v.uni.AddTranslator(l, true); err != nil {
+		return err
+	}
+	translator, _ := v.uni.GetTranslator(l.Locale())
+	if err := register(v.validate, translator); err != nil {
+		return err
+	}
+	v.addLocale(tag, translator)
+	return nil
+}
+
+func (v *Validator) addLocale(tag language.Tag, translator ut.Translator) {
+	v.locales = append(v.locales, tag)
+	v.translators = append(v.translators, translator)
+	v.matcher = i18n.NewMatcher(v.locales...)
 }
 
 // ValidateStruct will do a struct validation given ctx and arbitrary struct.
-// This function will automatically determine which language should the
-// validation string should be outputted from given ctx. Language defaults to
-// "id" when not found in the ctx.
+// Messages are translated in the supported language best matching the
+// language of ctx, e.g. "id" for "id-ID", see i18n.FromContext, and in
+// English when none matches. The resolved language is the Locale of the
+// result.
 func (v *Validator) ValidateStruct(ctx context.Context, s any) *ValidationResult {
 	err := v.validate.StructCtx(ctx, s)
 	if err == nil {
 		return nil
 	}
 
-	langStr := "id"
-	if i18n.FromContext(ctx) != language.Indonesian {
-		langStr = "en"
-	}
-
-	out := &ValidationResult{}
-	theTranslator, _ := v.uni.GetTranslator(langStr)
-	out.FieldErrors, out.Message = NewFieldErrors(theTranslator, err)
+	locale, i := v.matcher.Match(i18n.FromContext(ctx))
+	out := &ValidationResult{Locale: locale.String()}
+	out.FieldErrors, out.Message = NewFieldErrors(v.translators[i], err)
 
 	return out
 }
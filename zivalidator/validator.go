@@ -10,6 +10,8 @@ import (
 type ValidationResult struct {
 	Message     string       `json:"message"`
 	FieldErrors []FieldError `json:"field_errors"`
+	// Locale is the language of the messages, e.g. "en".
+	Locale string `json:"locale"`
 }
 
 type FieldError struct {
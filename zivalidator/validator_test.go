@@ -0,0 +1,44 @@
+package zivalidator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/divikraf/lumos/i18n"
+	"golang.org/x/text/language"
+)
+
+func TestValidateStructLocale(t *testing.T) {
+	v := New()
+	var s struct {
+		Name string `validate:"required"`
+	}
+
+	tests := []struct {
+		lang   string
+		locale string
+	}{
+		{lang: "id-ID", locale: "id"},
+		{lang: "en-GB", locale: "en"},
+		{lang: "en-US", locale: "en"},
+		{lang: "fr-FR", locale: "en"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			ctx := i18n.WithContext(context.Background(), language.MustParse(tt.lang))
+			result := v.ValidateStruct(ctx, &s)
+			if result == nil {
+				t.Fatal("expected a validation error")
+			}
+			if result.Locale != tt.locale {
+				t.Errorf("Locale = %q, want %q", result.Locale, tt.locale)
+			}
+		})
+	}
+
+	t.Run("default", func(t *testing.T) {
+		if result := v.ValidateStruct(context.Background(), &s); result.Locale != "id" {
+			t.Errorf("Locale = %q, want the fallback language id", result.Locale)
+		}
+	})
+}
@@ -0,0 +1,34 @@
+package ziworker
+
+import (
+	"github.com/divikraf/lumos/ziconf"
+	"github.com/rs/zerolog"
+	"go.uber.org/fx"
+)
+
+// DefaultPoolName is the name of the pool provided by NewConfiguredPool.
+const DefaultPoolName = "default"
+
+// workerConfig is implemented by configurations of the worker pool.
+type workerConfig interface {
+	GetWorker() Config
+}
+
+type NewConfiguredPoolParams struct {
+	fx.In
+	Config ziconf.Config
+	Logger *zerolog.Logger
+	LC     fx.Lifecycle
+}
+
+// NewConfiguredPool returns the default Pool, configured by the worker config
+// when the config has one, drained when the application stops.
+func NewConfiguredPool(params NewConfiguredPoolParams) *Pool {
+	var config Config
+	if c, ok := params.Config.(workerConfig); ok {
+		config = c.GetWorker()
+	}
+	p := New(DefaultPoolName, config, params.Logger)
+	params.LC.Append(fx.StopHook(p.Close))
+	return p
+}
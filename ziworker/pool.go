@@ -0,0 +1,452 @@
+// Package ziworker runs background tasks in bounded pools of goroutines,
+// instead of unmanaged goroutines spawned by the handlers.
+//
+// Tasks are queued, then run by the workers of the pool with the values of
+// the context submitting them, e.g. its span and logger, but not its
+// cancellation: a task outlives the request submitting it. Closing the pool
+// drains the queue. Every task is traced, recorded in the worker_tasks_total
+// and worker_task_duration_ms metrics, and its panics are recovered as
+// errors.
+//
+// The queue is split in lanes, e.g. an interactive lane and a bulk one for
+// backfills, sharing the workers in proportion to their weights, and the
+// tasks of a lane are split by tenant, sharing the lane in proportion to
+// their weights, so that neither a lane nor a tenant starves the others:
+//
+//	pool := ziworker.New("tasks", ziworker.Config{Lanes: []ziworker.LaneConfig{
+//		{Name: "interactive", Weight: 4},
+//		{Name: "bulk", Weight: 1},
+//	}}, logger)
+//	pool.Submit(ctx, "reindex", reindex, ziworker.InLane("bulk"), ziworker.ForTenant(tenant))
+package ziworker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/divikraf/lumos/zilog"
+	"github.com/divikraf/lumos/zitelemetry/observe"
+	"github.com/divikraf/lumos/zitelemetry/revelio"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/divikraf/lumos/ziworker"
+
+// DefaultLane is the lane of the pools without lanes configured, and of the
+// tasks submitted without InLane to them.
+const DefaultLane = "default"
+
+var (
+	// ErrPoolClosed is returned when submitting to a closed pool.
+	ErrPoolClosed = errors.New("ziworker: pool closed")
+	// ErrQueueFull is returned by TrySubmit when the queue is full.
+	ErrQueueFull = errors.New("ziworker: queue full")
+	// ErrUnknownLane is returned when submitting to a lane the pool doesn't
+	// have.
+	ErrUnknownLane = errors.New("ziworker: unknown lane")
+)
+
+// Task is a background task.
+type Task func(ctx context.Context) error
+
+// Config configures a Pool.
+type Config struct {
+	// Workers is the number of tasks run concurrently (default: 10).
+	Workers int `json:"workers" yaml:"workers"`
+	// QueueSize is the number of tasks waiting for a worker, per lane
+	// (default: 100).
+	QueueSize int `json:"queue_size" yaml:"queue_size"`
+	// Lanes are the lanes of the queue, the first one being the lane of the
+	// tasks submitted without InLane (default: a single DefaultLane).
+	Lanes []LaneConfig `json:"lanes" yaml:"lanes"`
+}
+
+// LaneConfig configures a lane of a Pool.
+type LaneConfig struct {
+	Name string `json:"name" yaml:"name"`
+	// Weight is the share of the workers of the lane while other lanes have
+	// tasks waiting (default: 1).
+	Weight int `json:"weight" yaml:"weight"`
+	// QueueSize is the number of tasks of the lane waiting for a worker
+	// (default: the QueueSize of the pool).
+	QueueSize int `json:"queue_size" yaml:"queue_size"`
+	// TenantWeights are the shares of the lane of tenants, while other
+	// tenants have tasks waiting (default: 1).
+	TenantWeights map[string]int `json:"tenant_weights" yaml:"tenant_weights"`
+}
+
+func (c Config) withDefaults() Config {
+	if c.Workers <= 0 {
+		c.Workers = 10
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = 100
+	}
+	if len(c.Lanes) == 0 {
+		c.Lanes = []LaneConfig{{Name: DefaultLane}}
+	}
+	lanes := make([]LaneConfig, len(c.Lanes))
+	for i, l := range c.Lanes {
+		if l.Weight <= 0 {
+			l.Weight = 1
+		}
+		if l.QueueSize <= 0 {
+			l.QueueSize = c.QueueSize
+		}
+		lanes[i] = l
+	}
+	c.Lanes = lanes
+	return c
+}
+
+// SubmitOption configures a submitted task.
+type SubmitOption func(*task)
+
+// InLane queues the task in lane, instead of the first lane of the pool.
+func InLane(lane string) SubmitOption {
+	return func(t *task) {
+		t.lane = lane
+	}
+}
+
+// ForTenant queues the task for tenant, sharing its lane fairly with the
+// tasks of the other tenants.
+func ForTenant(tenant string) SubmitOption {
+	return func(t *task) {
+		t.tenant = tenant
+	}
+}
+
+type task struct {
+	ctx    context.Context
+	name   string
+	run    Task
+	lane   string
+	tenant string
+	queued time.Time
+}
+
+// weighted is an item picked by smooth weighted round-robin, see pick.
+type weighted struct {
+	weight  int
+	current int
+}
+
+// pick returns the index of the item of items picked next, by smooth
+// weighted round-robin: every item is picked in proportion to its weight,
+// interleaved with the others.
+func pick[T any](items []T, w func(T) *weighted) int {
+	best, total := -1, 0
+	for i, item := range items {
+		c := w(item)
+		c.current += c.weight
+		total += c.weight
+		if best < 0 || c.current > w(items[best]).current {
+			best = i
+		}
+	}
+	w(items[best]).current -= total
+	return best
+}
+
+// tenantQueue is the queue of the tasks of a tenant in a lane.
+type tenantQueue struct {
+	weighted
+	tenant string
+	tasks  []task
+}
+
+// lane is a lane of the queue, its tenants taking turns.
+type lane struct {
+	weighted
+	LaneConfig
+	// tenants are the tenants with tasks waiting
+	tenants []*tenantQueue
+	size    int
+	// space is closed, and replaced, when a task leaves the lane, to wake
+	// the submitters waiting for space
+	space chan struct{}
+	attrs []attribute.KeyValue
+}
+
+func (l *lane) push(t task) {
+	for _, q := range l.tenants {
+		if q.tenant == t.tenant {
+			q.tasks = append(q.tasks, t)
+			l.size++
+			return
+		}
+	}
+	weight := l.TenantWeights[t.tenant]
+	if weight <= 0 {
+		weight = 1
+	}
+	l.tenants = append(l.tenants, &tenantQueue{weighted: weighted{weight: weight}, tenant: t.tenant, tasks: []task{t}})
+	l.size++
+}
+
+func (l *lane) pop() task {
+	i := pick(l.tenants, func(q *tenantQueue) *weighted { return &q.weighted })
+	q := l.tenants[i]
+	t := q.tasks[0]
+	q.tasks[0] = task{}
+	q.tasks = q.tasks[1:]
+	if len(q.tasks) == 0 {
+		l.tenants = append(l.tenants[:i], l.tenants[i+1:]...)
+	}
+	l.size--
+	if l.size == 0 {
+		// An idle lane doesn't bank turns
+		l.current = 0
+	}
+	close(l.space)
+	l.space = make(chan struct{})
+	return t
+}
+
+// Pool runs tasks with a fixed number of workers.
+type Pool struct {
+	name   string
+	logger *zerolog.Logger
+	attrs  []attribute.KeyValue
+
+	mu    sync.Mutex
+	lanes []*lane
+	// ready is signaled when a task is queued, or the pool closes
+	ready  *sync.Cond
+	closed bool
+	// closing is closed when the pool closes, to release the blocked
+	// submitters
+	closing   chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+	// ctx is canceled when Close gives up waiting for the tasks
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	runs     revelio.ResultCounter
+	duration revelio.DurationRecorder
+	wait     revelio.DurationRecorder
+	queued   metric.Int64UpDownCounter
+	rejected metric.Int64Counter
+}
+
+// New returns a Pool named name, its workers running until Close. The tasks
+// waiting are counted in the worker_tasks_queued metric, and the time they
+// waited recorded in worker_task_wait_duration_ms, by pool and lane.
+func New(name string, config Config, logger *zerolog.Logger) *Pool {
+	config = config.withDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{
+		name:     name,
+		logger:   logger,
+		attrs:    []attribute.KeyValue{attribute.String("pool", name)},
+		closing:  make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+		runs:     revelio.MustResultCounter("worker_tasks_total", "Number of background tasks run, by pool, lane, task and status"),
+		duration: revelio.MustDuration("worker_task_duration_ms", "Duration of the background tasks, by pool, lane, task and status"),
+		wait:     revelio.MustDuration("worker_task_wait_duration_ms", "Time the background tasks waited for a worker, by pool and lane"),
+		queued: revelio.MustInt64UpDownCounter(
+			"worker_tasks_queued",
+			"Number of background tasks waiting for a worker, by pool and lane",
+		),
+		rejected: revelio.MustInt64Counter(
+			"worker_tasks_rejected_total",
+			"Number of background tasks rejected by a full queue or a closed pool, by pool and lane",
+		),
+	}
+	p.ready = sync.NewCond(&p.mu)
+	for _, c := range config.Lanes {
+		p.lanes = append(p.lanes, &lane{
+			weighted:   weighted{weight: c.Weight},
+			LaneConfig: c,
+			space:      make(chan struct{}),
+			attrs:      append(p.attrs[:len(p.attrs):len(p.attrs)], attribute.String("lane", c.Name)),
+		})
+	}
+	for range config.Workers {
+		p.wg.Add(1)
+		go p.work()
+	}
+	return p
+}
+
+// Name returns the name of the pool.
+func (p *Pool) Name() string {
+	return p.name
+}
+
+// Submit queues task, named name in the telemetry, blocking while its lane
+// is full until ctx is done. The name must come from a small bounded set.
+func (p *Pool) Submit(ctx context.Context, name string, run Task, opts ...SubmitOption) error {
+	return p.submit(ctx, name, run, true, opts)
+}
+
+// TrySubmit queues task like Submit, or returns ErrQueueFull without
+// blocking.
+func (p *Pool) TrySubmit(ctx context.Context, name string, run Task, opts ...SubmitOption) error {
+	return p.submit(ctx, name, run, false, opts)
+}
+
+func (p *Pool) submit(ctx context.Context, name string, run Task, block bool, opts []SubmitOption) error {
+	t := task{ctx: context.WithoutCancel(ctx), name: name, run: run, lane: p.lanes[0].Name}
+	for _, o := range opts {
+		o(&t)
+	}
+	var l *lane
+	for _, candidate := range p.lanes {
+		if candidate.Name == t.lane {
+			l = candidate
+		}
+	}
+	if l == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownLane, t.lane)
+	}
+
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			p.rejected.Add(ctx, 1, metric.WithAttributes(l.attrs...))
+			return ErrPoolClosed
+		}
+		if l.size < l.QueueSize {
+			t.queued = time.Now()
+			l.push(t)
+			p.mu.Unlock()
+			p.ready.Signal()
+			p.queued.Add(ctx, 1, metric.WithAttributes(l.attrs...))
+			return nil
+		}
+		space := l.space
+		p.mu.Unlock()
+
+		if !block {
+			p.rejected.Add(ctx, 1, metric.WithAttributes(l.attrs...))
+			return ErrQueueFull
+		}
+		select {
+		case <-space:
+		case <-p.closing:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// next returns the next task, waiting for one, or false once the pool is
+// closed and drained. The lanes with tasks waiting take turns.
+func (p *Pool) next() (task, *lane, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		var waiting []*lane
+		for _, l := range p.lanes {
+			if l.size > 0 {
+				waiting = append(waiting, l)
+			}
+		}
+		if len(waiting) > 0 {
+			l := waiting[pick(waiting, func(l *lane) *weighted { return &l.weighted })]
+			return l.pop(), l, true
+		}
+		if p.closed {
+			return task{}, nil, false
+		}
+		p.ready.Wait()
+	}
+}
+
+// work runs the queued tasks until the pool is closed and drained.
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for {
+		t, l, ok := p.next()
+		if !ok {
+			return
+		}
+		p.queued.Add(t.ctx, -1, metric.WithAttributes(l.attrs...))
+		p.wait.Record(t.ctx, time.Since(t.queued), l.attrs...)
+		p.run(t, l)
+	}
+}
+
+// run runs t, canceling its context when the pool gives up waiting for it.
+func (p *Pool) run(t task, l *lane) {
+	ctx, cancel := context.WithCancel(t.ctx)
+	defer cancel()
+	stop := context.AfterFunc(p.ctx, cancel)
+	defer stop()
+
+	attrs := append(l.attrs[:len(l.attrs):len(l.attrs)], attribute.String("task", t.name))
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "worker "+t.name, trace.WithAttributes(attrs...))
+	defer span.End()
+
+	start := time.Now()
+	err := p.safeRun(ctx, t.run)
+	elapsed := time.Since(start)
+	p.duration.Record(ctx, elapsed, append(attrs, revelio.ResultAttributes(err)...)...)
+	p.runs.Record(ctx, err, attrs...)
+	if err != nil {
+		observe.RecordError(span, err)
+		zilog.FromContext(ctx).Error().Err(err).
+			Str("pool", p.name).
+			Str("lane", t.lane).
+			Str("task", t.name).
+			Dur("duration", elapsed).
+			Msg("background task failed")
+	}
+}
+
+// safeRun calls run, turning its panics into errors so that they don't crash
+// the process.
+func (p *Pool) safeRun(ctx context.Context, run Task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			observe.RecordPanic(trace.SpanFromContext(ctx), r)
+			zilog.FromContext(ctx).Error().
+				Str("panic", fmt.Sprint(r)).
+				Str("stack", string(debug.Stack())).
+				Msg("recovered from panic in background task")
+			err = fmt.Errorf("ziworker: task panicked: %v", r)
+		}
+	}()
+	return run(ctx)
+}
+
+// Close stops accepting tasks, then waits for the queued and running ones
+// until ctx is done. The context of the tasks still running is then
+// canceled, and ctx.Err() returned.
+func (p *Pool) Close(ctx context.Context) error {
+	p.closeOnce.Do(func() {
+		p.mu.Lock()
+		p.closed = true
+		p.mu.Unlock()
+		close(p.closing)
+		p.ready.Broadcast()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		p.logger.Warn().Str("pool", p.name).Msg("background tasks canceled on close")
+		return ctx.Err()
+	}
+}
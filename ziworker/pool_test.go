@@ -0,0 +1,188 @@
+package ziworker
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/divikraf/lumos/zitelemetry/revelio/reveliotest"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestPool(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	logger := zerolog.Nop()
+	p := New("test", Config{Workers: 2, QueueSize: 10}, &logger)
+
+	var ran atomic.Int32
+	// The tasks outlive the context submitting them
+	ctx, cancel := context.WithCancel(context.Background())
+	for range 5 {
+		if err := p.Submit(ctx, "ok", func(ctx context.Context) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			ran.Add(1)
+			return nil
+		}); err != nil {
+			t.Fatalf("Submit() = %v", err)
+		}
+	}
+	cancel()
+	if err := p.Submit(context.Background(), "failing", func(context.Context) error { return errors.New("boom") }); err != nil {
+		t.Fatalf("Submit() = %v", err)
+	}
+	if err := p.Submit(context.Background(), "panicking", func(context.Context) error { panic("boom") }); err != nil {
+		t.Fatalf("Submit() = %v", err)
+	}
+
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if n := ran.Load(); n != 5 {
+		t.Errorf("%d tasks ran, want 5 drained on close", n)
+	}
+	reveliotest.AssertCounterValue(t, s, "worker_tasks_total", 5, attribute.String("task", "ok"), attribute.String("status", "success"))
+	reveliotest.AssertCounterValue(t, s, "worker_tasks_total", 1, attribute.String("task", "failing"), attribute.String("status", "failure"))
+	reveliotest.AssertCounterValue(t, s, "worker_tasks_total", 1, attribute.String("task", "panicking"), attribute.String("status", "failure"))
+	reveliotest.AssertCounterValue(t, s, "worker_tasks_queued", 0, attribute.String("pool", "test"))
+
+	if err := p.Submit(context.Background(), "late", func(context.Context) error { return nil }); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Submit() to a closed pool = %v, want ErrPoolClosed", err)
+	}
+}
+
+func TestPoolBackpressure(t *testing.T) {
+	logger := zerolog.Nop()
+	p := New("test", Config{Workers: 1, QueueSize: 1}, &logger)
+	started := make(chan struct{})
+	block := func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	noop := func(context.Context) error { return nil }
+
+	if err := p.Submit(context.Background(), "block", block); err != nil {
+		t.Fatalf("Submit() = %v", err)
+	}
+	<-started
+	if err := p.TrySubmit(context.Background(), "queued", noop); err != nil {
+		t.Fatalf("TrySubmit() = %v", err)
+	}
+	if err := p.TrySubmit(context.Background(), "rejected", noop); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("TrySubmit() to a full queue = %v, want ErrQueueFull", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Submit(ctx, "waiting", noop); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Submit() to a full queue = %v, want the context error", err)
+	}
+
+	// The blocked task is canceled once Close gives up
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close() = %v, want the context error", err)
+	}
+	if err := p.Close(context.Background()); err != nil {
+		t.Errorf("Close() after the cancellation = %v", err)
+	}
+}
+
+// runOrder submits the tasks of submit to a pool of a single busy worker,
+// then returns the order they ran in.
+func runOrder(t *testing.T, config Config, submit func(p *Pool, task func(name string) Task)) []string {
+	t.Helper()
+	logger := zerolog.Nop()
+	config.Workers = 1
+	p := New("test", config, &logger)
+	started, release := make(chan struct{}), make(chan struct{})
+	p.Submit(context.Background(), "block", func(context.Context) error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	submit(p, func(name string) Task {
+		return func(context.Context) error {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			return nil
+		}
+	})
+	close(release)
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	return order
+}
+
+func TestPoolLanes(t *testing.T) {
+	s := reveliotest.NewDefaultTestScope(t)
+	config := Config{Lanes: []LaneConfig{{Name: "interactive", Weight: 3}, {Name: "bulk"}}}
+	order := runOrder(t, config, func(p *Pool, task func(string) Task) {
+		for range 8 {
+			if err := p.Submit(context.Background(), "backfill", task("bulk"), InLane("bulk")); err != nil {
+				t.Fatalf("Submit() = %v", err)
+			}
+		}
+		for range 4 {
+			if err := p.Submit(context.Background(), "notify", task("interactive")); err != nil {
+				t.Fatalf("Submit() = %v", err)
+			}
+		}
+		if err := p.Submit(context.Background(), "lost", task("lost"), InLane("missing")); !errors.Is(err, ErrUnknownLane) {
+			t.Errorf("Submit() to a missing lane = %v, want ErrUnknownLane", err)
+		}
+	})
+	// The bulk tasks queued first don't starve the interactive ones
+	if want := []string{"interactive", "interactive", "bulk", "interactive"}; !slices.Equal(order[:4], want) {
+		t.Errorf("order = %v, want %v first", order, want)
+	}
+	if len(order) != 12 {
+		t.Errorf("%d tasks ran, want 12", len(order))
+	}
+
+	lane := attribute.String("lane", "bulk")
+	reveliotest.AssertCounterValue(t, s, "worker_tasks_total", 8, lane, attribute.String("task", "backfill"))
+	reveliotest.AssertCounterValue(t, s, "worker_tasks_queued", 0, lane)
+	if h := reveliotest.CollectHistogram(t, s, "worker_task_wait_duration_ms", lane); h.Count != 8 {
+		t.Errorf("bulk waits = %d, want 8", h.Count)
+	}
+}
+
+func TestPoolTenants(t *testing.T) {
+	order := runOrder(t, Config{}, func(p *Pool, task func(string) Task) {
+		for range 6 {
+			p.Submit(context.Background(), "sync", task("acme"), ForTenant("acme"))
+		}
+		for range 2 {
+			p.Submit(context.Background(), "sync", task("globex"), ForTenant("globex"))
+		}
+	})
+	if want := []string{"acme", "globex", "acme", "globex", "acme", "acme", "acme", "acme"}; !slices.Equal(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+
+	config := Config{Lanes: []LaneConfig{{Name: DefaultLane, TenantWeights: map[string]int{"globex": 2}}}}
+	order = runOrder(t, config, func(p *Pool, task func(string) Task) {
+		for _, tenant := range []string{"acme", "acme", "acme", "globex", "globex", "globex"} {
+			p.Submit(context.Background(), "sync", task(tenant), ForTenant(tenant))
+		}
+	})
+	if want := []string{"globex", "acme", "globex", "globex", "acme", "acme"}; !slices.Equal(order, want) {
+		t.Errorf("weighted order = %v, want %v", order, want)
+	}
+}
@@ -0,0 +1,10 @@
+package ziworkerfx
+
+import (
+	"github.com/divikraf/lumos/ziworker"
+	"go.uber.org/fx"
+)
+
+// Provider provides the default *ziworker.Pool, drained when the application
+// stops.
+var Provider = fx.Provide(ziworker.NewConfiguredPool)